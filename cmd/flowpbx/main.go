@@ -3,12 +3,14 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"syscall"
 	"time"
 
@@ -19,14 +21,17 @@ import (
 
 	"github.com/flowpbx/flowpbx/internal/api"
 	"github.com/flowpbx/flowpbx/internal/api/middleware"
+	"github.com/flowpbx/flowpbx/internal/api/ws"
 	"github.com/flowpbx/flowpbx/internal/config"
 	"github.com/flowpbx/flowpbx/internal/database"
 	"github.com/flowpbx/flowpbx/internal/database/models"
 	"github.com/flowpbx/flowpbx/internal/email"
 	"github.com/flowpbx/flowpbx/internal/media"
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
 	fpmetrics "github.com/flowpbx/flowpbx/internal/metrics"
 	"github.com/flowpbx/flowpbx/internal/prompts"
 	"github.com/flowpbx/flowpbx/internal/recording"
+	"github.com/flowpbx/flowpbx/internal/schedule"
 	sipserver "github.com/flowpbx/flowpbx/internal/sip"
 	"github.com/flowpbx/flowpbx/internal/voicemail"
 )
@@ -49,6 +54,11 @@ func main() {
 		"tls", cfg.TLSEnabled(),
 	)
 
+	// Opus is only compiled in with the "opus" build tag, which make uses.
+	if codecs.Lookup("opus") == nil {
+		slog.Warn("built without opus, calls offering only opus will be refused")
+	}
+
 	// Open database and run migrations.
 	db, err := database.Open(cfg.DataDir)
 	if err != nil {
@@ -99,6 +109,12 @@ func main() {
 		slog.Error("failed to create sip server", "error", err)
 		os.Exit(1)
 	}
+
+	// Real-time event stream: publish SIP, conference and voicemail
+	// events to connected WebSocket and SSE clients.
+	events := ws.NewHub(slog.Default())
+	publishEvents(events, sipSrv, database.NewExtensionRepository(db))
+
 	if err := sipSrv.Start(appCtx); err != nil {
 		slog.Error("failed to start sip server", "error", err)
 		os.Exit(1)
@@ -114,6 +130,9 @@ func main() {
 	// Recording retention cleanup: delete recordings older than recording_max_days setting.
 	recording.StartCleanupTicker(appCtx, db, sysConfig, 1*time.Hour)
 
+	// Time switch calendars: fetch subscribed calendar URLs as their refresh intervals pass.
+	schedule.StartRefreshTicker(appCtx, sipSrv.Calendars(), 5*time.Minute)
+
 	// Create adapter for trunk status so the API can query SIP trunk state.
 	trunkStatus := &trunkStatusAdapter{registrar: sipSrv.TrunkRegistrar()}
 
@@ -133,6 +152,9 @@ func main() {
 		pendingMgr: sipSrv.PendingCallManager(),
 	}
 
+	// Adapter for hangup, transfer and supervision of active calls.
+	callControl := &callControlAdapter{srv: sipSrv}
+
 	// Config reloader for hot-reload without restart.
 	reloader := &configReloader{
 		db:        db,
@@ -147,7 +169,7 @@ func main() {
 	sipLogVerbosity := &sipLogVerbosityAdapter{tracer: sipSrv.MessageTracer()}
 
 	// HTTP server using the api package.
-	handler := api.NewServer(db, cfg, sessions, sysConfig, trunkStatus, trunkTester, trunkLifecycle, activeCalls, conferenceProv, enc, reloader, sipLogVerbosity)
+	handler := api.NewServer(db, cfg, sessions, sysConfig, trunkStatus, trunkTester, trunkLifecycle, activeCalls, callControl, conferenceProv, enc, reloader, sipLogVerbosity, events, sipSrv.Calendars())
 
 	// Prometheus metrics endpoint.
 	metricsCollector := fpmetrics.NewCollector(
@@ -345,6 +367,142 @@ func loadTrunks(ctx context.Context, db *database.DB, registrar *sipserver.Trunk
 	}
 }
 
+// publishEvents registers listeners on the SIP server's call, registration,
+// trunk, conference and voicemail state and publishes their changes to the
+// event hub. The call and trunk listeners run with their manager's lock
+// held, so they only read the state they are given.
+func publishEvents(hub *ws.Hub, sipSrv *sipserver.Server, extensions database.ExtensionRepository) {
+	sipSrv.PendingCallManager().OnRingingChange(func(pc *sipserver.PendingCall, ringing bool) {
+		typ := ws.EventCallRinging
+		if !ringing {
+			typ = ws.EventCallRingingStopped
+		}
+		data := ws.CallData{CallID: pc.CallID, State: "ringing"}
+		if pc.CallerReq != nil {
+			if from := pc.CallerReq.From(); from != nil {
+				data.CallerIDName = from.DisplayName
+				data.CallerIDNum = from.Address.User
+			}
+			data.CalledNum = pc.CallerReq.Recipient.User
+		}
+		hub.Publish(ws.Event{
+			Type:       typ,
+			Topic:      ws.TopicCalls,
+			Extensions: slices.Clone(pc.Extensions),
+			Data:       data,
+		})
+	})
+
+	sipSrv.DialogManager().OnCallStateChange(func(d *sipserver.Dialog) {
+		typ := ws.EventCallAnswered
+		if d.State == sipserver.CallStateTerminated {
+			typ = ws.EventCallEnded
+		}
+		start := d.StartTime
+		hub.Publish(ws.Event{
+			Type:       typ,
+			Topic:      ws.TopicCalls,
+			Extensions: legExtensions(d.Caller.Extension, d.Callee.Extension),
+			Data: ws.CallData{
+				CallID:       d.CallID,
+				State:        string(d.State),
+				Direction:    string(d.Direction),
+				CallerIDName: d.CallerIDName,
+				CallerIDNum:  d.CallerIDNum,
+				CalledNum:    d.CalledNum,
+				StartTime:    &start,
+				AnswerTime:   d.AnswerTime,
+				EndTime:      d.EndTime,
+				HangupCause:  d.HangupCause,
+			},
+		})
+	})
+
+	sipSrv.Registrar().OnRegistrationChange(func(ext *models.Extension, registered bool) {
+		typ := ws.EventRegistrationUp
+		if !registered {
+			typ = ws.EventRegistrationDown
+		}
+		hub.Publish(ws.Event{
+			Type:       typ,
+			Topic:      ws.TopicRegistrations,
+			Extensions: []string{ext.Extension},
+			Data: ws.RegistrationData{
+				ExtensionID: ext.ID,
+				Extension:   ext.Extension,
+				Name:        ext.Name,
+				Registered:  registered,
+			},
+		})
+	})
+
+	sipSrv.TrunkRegistrar().OnStatusChange(func(st sipserver.TrunkState) {
+		hub.Publish(ws.Event{
+			Type:  ws.EventTrunkStatus,
+			Topic: ws.TopicTrunks,
+			Data: ws.TrunkData{
+				TrunkID:   st.TrunkID,
+				Name:      st.Name,
+				Type:      st.Type,
+				Status:    string(st.Status),
+				LastError: st.LastError,
+			},
+		})
+	})
+
+	sipSrv.ConferenceManager().OnParticipantChange(func(bridgeName string, p media.ConferenceParticipant, joined bool) {
+		typ := ws.EventConferenceJoin
+		if !joined {
+			typ = ws.EventConferenceLeave
+		}
+		hub.Publish(ws.Event{
+			Type:  typ,
+			Topic: ws.TopicConferences,
+			Data: ws.ConferenceData{
+				BridgeID:      p.BridgeID,
+				BridgeName:    bridgeName,
+				ParticipantID: p.ID,
+				CallerIDName:  p.CallerIDName,
+				CallerIDNum:   p.CallerIDNum,
+				JoinedAt:      p.JoinedAt,
+			},
+		})
+	})
+
+	sipSrv.FlowActions().OnVoicemail(func(box *models.VoicemailBox, msg *models.VoicemailMessage) {
+		ev := ws.Event{
+			Type:  ws.EventVoicemailNew,
+			Topic: ws.TopicVoicemail,
+			Data: ws.VoicemailData{
+				MessageID:     msg.ID,
+				MailboxID:     box.ID,
+				MailboxNumber: box.MailboxNumber,
+				CallerIDName:  msg.CallerIDName,
+				CallerIDNum:   msg.CallerIDNum,
+				DurationSecs:  msg.Duration,
+			},
+		}
+		// App clients see messages left in the box their extension is notified for.
+		if box.NotifyExtensionID != nil {
+			if ext, err := extensions.GetByID(context.Background(), *box.NotifyExtensionID); err == nil && ext != nil {
+				ev.Extensions = []string{ext.Extension}
+			}
+		}
+		hub.Publish(ev)
+	})
+}
+
+// legExtensions returns the extension numbers of a call's local legs.
+func legExtensions(exts ...*models.Extension) []string {
+	var nums []string
+	for _, ext := range exts {
+		if ext != nil {
+			nums = append(nums, ext.Extension)
+		}
+	}
+	return nums
+}
+
 // trunkStatusAdapter bridges the SIP trunk registrar with the API's
 // TrunkStatusProvider interface, converting between SIP and API types.
 type trunkStatusAdapter struct {
@@ -462,6 +620,11 @@ func (a *activeCallsAdapter) GetActiveCalls() []api.ActiveCallEntry {
 			AnswerTime:   d.AnswerTime,
 			DurationSec:  int(now.Sub(d.StartTime).Seconds()),
 		}
+		if orbit, parkedBy := d.ParkOrbit(); orbit != "" {
+			entry.State = "parked"
+			entry.ParkOrbit = orbit
+			entry.ParkedBy = parkedBy
+		}
 		entries = append(entries, entry)
 	}
 
@@ -511,6 +674,55 @@ func (cr *configReloader) Reload(ctx context.Context) error {
 	return nil
 }
 
+// callControlAdapter bridges the SIP server's call control with the API's
+// CallController interface, translating its errors to the API's.
+type callControlAdapter struct {
+	srv *sipserver.Server
+}
+
+func (a *callControlAdapter) HangupCall(callID string) error {
+	return callControlError(a.srv.HangupCall(callID))
+}
+
+func (a *callControlAdapter) TransferCall(ctx context.Context, callID, destination string, replaceCaller bool) error {
+	return callControlError(a.srv.TransferCall(ctx, callID, destination, replaceCaller))
+}
+
+func (a *callControlAdapter) SuperviseCall(ctx context.Context, callID, extension, mode string) error {
+	return callControlError(a.srv.SuperviseCall(ctx, callID, extension, sipserver.SuperviseMode(mode)))
+}
+
+func (a *callControlAdapter) OriginateCall(ctx context.Context, extension, destination string) (string, error) {
+	callID, err := a.srv.OriginateCall(ctx, extension, destination)
+	return callID, callControlError(err)
+}
+
+func (a *callControlAdapter) OriginateFlowCall(ctx context.Context, extension string, flowID int64, entryNode string) (string, error) {
+	callID, err := a.srv.OriginateFlowCall(ctx, extension, flowID, entryNode)
+	return callID, callControlError(err)
+}
+
+// callControlError wraps SIP call control errors in the API's sentinels.
+func callControlError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sipserver.ErrCallNotFound):
+		return fmt.Errorf("%w: %w", api.ErrCallNotFound, err)
+	case errors.Is(err, sipserver.ErrCallUncontrollable),
+		errors.Is(err, sipserver.ErrCallSupervised),
+		errors.Is(err, sipserver.ErrNotSupervisor),
+		errors.Is(err, sipserver.ErrExtensionNotFound),
+		errors.Is(err, sipserver.ErrDND),
+		errors.Is(err, sipserver.ErrNoRegistrations),
+		errors.Is(err, sipserver.ErrCallNotPermitted),
+		errors.Is(err, sipserver.ErrNoOutboundRoute):
+		return fmt.Errorf("%w: %w", api.ErrCallRejected, err)
+	default:
+		return err
+	}
+}
+
 // conferenceProviderAdapter bridges the media.ConferenceManager with the
 // API's ConferenceProvider interface for runtime conference control.
 type conferenceProviderAdapter struct {
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/api/middleware"
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// minPasswordLen is the shortest admin password accepted.
+const minPasswordLen = 8
+
+// adminUserRequest is the JSON request body for creating/updating an admin
+// user. On update an empty password keeps the current one.
+type adminUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// adminUserResponse is the JSON response for a single admin user. The
+// password hash, TOTP secret and recovery codes are never returned.
+type adminUserResponse struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	TOTPEnabled bool   `json:"totp_enabled"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// toAdminUserResponse converts a models.AdminUser to the API response.
+func toAdminUserResponse(u *models.AdminUser) adminUserResponse {
+	return adminUserResponse{
+		ID:          u.ID,
+		Username:    u.Username,
+		Role:        u.Role,
+		TOTPEnabled: u.TOTPEnabled,
+		CreatedAt:   u.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   u.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// handleListAdminUsers returns all admin users.
+func (s *Server) handleListAdminUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.adminUsers.List(r.Context())
+	if err != nil {
+		slog.Error("list admin users: failed to query", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]adminUserResponse, len(users))
+	for i := range users {
+		items[i] = toAdminUserResponse(&users[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateAdminUser creates a new admin user.
+func (s *Server) handleCreateAdminUser(w http.ResponseWriter, r *http.Request) {
+	var req adminUserRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateAdminUserRequest(req, true); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkAdminUsername(w, r, req.Username, 0) {
+		return
+	}
+
+	hash, err := database.HashPassword(req.Password)
+	if err != nil {
+		slog.Error("create admin user: failed to hash password", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	user := &models.AdminUser{
+		Username:     req.Username,
+		PasswordHash: hash,
+		Role:         req.Role,
+	}
+	if err := s.adminUsers.Create(r.Context(), user); err != nil {
+		slog.Error("create admin user: failed to insert", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	created, err := s.adminUsers.GetByID(r.Context(), user.ID)
+	if err != nil || created == nil {
+		slog.Error("create admin user: failed to re-fetch", "error", err, "admin_user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("admin user created", "admin_user_id", created.ID, "username", created.Username, "role", created.Role, "by", actingUsername(r))
+
+	writeJSON(w, http.StatusCreated, toAdminUserResponse(created))
+}
+
+// handleGetAdminUser returns a single admin user by ID.
+func (s *Server) handleGetAdminUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAdminUserID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid admin user id")
+		return
+	}
+
+	user, err := s.adminUsers.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("get admin user: failed to query", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "admin user not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toAdminUserResponse(user))
+}
+
+// handleUpdateAdminUser updates an existing admin user. Changing the role
+// or password ends the user's sessions so the change applies at once.
+func (s *Server) handleUpdateAdminUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAdminUserID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid admin user id")
+		return
+	}
+
+	existing, err := s.adminUsers.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("update admin user: failed to query", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "admin user not found")
+		return
+	}
+
+	var req adminUserRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateAdminUserRequest(req, false); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkAdminUsername(w, r, req.Username, id) {
+		return
+	}
+	if existing.Role == middleware.RoleOwner && req.Role != middleware.RoleOwner && !s.checkNotLastOwner(w, r) {
+		return
+	}
+
+	roleChanged := existing.Role != req.Role
+	existing.Username = req.Username
+	existing.Role = req.Role
+	if req.Password != "" {
+		hash, err := database.HashPassword(req.Password)
+		if err != nil {
+			slog.Error("update admin user: failed to hash password", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		existing.PasswordHash = hash
+	}
+
+	if err := s.adminUsers.Update(r.Context(), existing); err != nil {
+		slog.Error("update admin user: failed to update", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if roleChanged || req.Password != "" {
+		s.sessions.DeleteByUserID(id)
+	}
+
+	updated, err := s.adminUsers.GetByID(r.Context(), id)
+	if err != nil || updated == nil {
+		slog.Error("update admin user: failed to re-fetch", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("admin user updated",
+		"admin_user_id", id,
+		"username", updated.Username,
+		"role", updated.Role,
+		"password_changed", req.Password != "",
+		"by", actingUsername(r),
+	)
+
+	writeJSON(w, http.StatusOK, toAdminUserResponse(updated))
+}
+
+// handleDeleteAdminUser removes an admin user by ID and ends their
+// sessions. Admins cannot delete themselves or the last owner.
+func (s *Server) handleDeleteAdminUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAdminUserID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid admin user id")
+		return
+	}
+
+	if current := middleware.AdminUserFromContext(r.Context()); current != nil && current.ID == id {
+		writeError(w, http.StatusBadRequest, "cannot delete your own account")
+		return
+	}
+
+	existing, err := s.adminUsers.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("delete admin user: failed to query", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "admin user not found")
+		return
+	}
+	if existing.Role == middleware.RoleOwner && !s.checkNotLastOwner(w, r) {
+		return
+	}
+
+	if err := s.adminUsers.Delete(r.Context(), id); err != nil {
+		slog.Error("delete admin user: failed to delete", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	s.sessions.DeleteByUserID(id)
+
+	slog.Info("admin user deleted", "admin_user_id", id, "username", existing.Username, "by", actingUsername(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResetAdminUserTOTP turns off two-factor authentication for an
+// admin user who has lost their authenticator and recovery codes. They can
+// enroll again after logging in with their password.
+func (s *Server) handleResetAdminUserTOTP(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAdminUserID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid admin user id")
+		return
+	}
+
+	existing, err := s.adminUsers.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("reset admin user totp: failed to query", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "admin user not found")
+		return
+	}
+
+	clearTOTP(existing)
+	if err := s.adminUsers.Update(r.Context(), existing); err != nil {
+		slog.Error("reset admin user totp: failed to update", "error", err, "admin_user_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Warn("admin user two-factor authentication reset", "admin_user_id", id, "username", existing.Username, "by", actingUsername(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkAdminUsername verifies that no other admin user has the username,
+// writing the error response and returning false if one does. selfID is
+// the user being updated, zero on create.
+func (s *Server) checkAdminUsername(w http.ResponseWriter, r *http.Request, username string, selfID int64) bool {
+	other, err := s.adminUsers.GetByUsername(r.Context(), username)
+	if err != nil {
+		slog.Error("admin user: failed to look up username", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	if other != nil && other.ID != selfID {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("an admin user named %q already exists", username))
+		return false
+	}
+	return true
+}
+
+// checkNotLastOwner verifies that more than one owner exists before an
+// owner is demoted or deleted, writing the error response and returning
+// false if not. Without an owner nobody could manage admin users.
+func (s *Server) checkNotLastOwner(w http.ResponseWriter, r *http.Request) bool {
+	owners, err := s.adminUsers.CountByRole(r.Context(), middleware.RoleOwner)
+	if err != nil {
+		slog.Error("admin user: failed to count owners", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	if owners <= 1 {
+		writeError(w, http.StatusBadRequest, "cannot remove the last owner")
+		return false
+	}
+	return true
+}
+
+// actingUsername returns the username of the admin making the request,
+// for audit logging.
+func actingUsername(r *http.Request) string {
+	if u := middleware.AdminUserFromContext(r.Context()); u != nil {
+		return u.Username
+	}
+	return ""
+}
+
+// parseAdminUserID extracts and parses the admin user ID from the URL
+// parameter.
+func parseAdminUserID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// validateAdminUserRequest checks the fields of an admin user
+// create/update. The password is required on create only.
+func validateAdminUserRequest(req adminUserRequest, create bool) string {
+	if msg := validateRequiredStringLen("username", req.Username, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("username", req.Username); msg != "" {
+		return msg
+	}
+	if strings.TrimSpace(req.Username) != req.Username {
+		return "username must not begin or end with spaces"
+	}
+
+	if create || req.Password != "" {
+		if msg := validatePassword(req.Password); msg != "" {
+			return msg
+		}
+	}
+
+	if !middleware.ValidAdminRole(req.Role) {
+		return fmt.Sprintf("role must be one of: %s", strings.Join(middleware.AdminRoles, ", "))
+	}
+	return ""
+}
+
+// validatePassword checks the length of a new admin password.
+func validatePassword(password string) string {
+	if len(password) < minPasswordLen {
+		return fmt.Sprintf("password must be at least %d characters", minPasswordLen)
+	}
+	return validateStringLen("password", password, maxPasswordLen)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/json"
 	"fmt"
@@ -94,9 +95,9 @@ func (s *Server) handleAppAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ext, err := s.extensions.GetByExtension(r.Context(), req.Extension)
+	ext, err := s.authenticateExtension(r.Context(), req.Extension, req.SIPPassword)
 	if err != nil {
-		slog.Error("app auth: failed to query extension", "error", err)
+		slog.Error("app auth: failed to authenticate extension", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -105,23 +106,6 @@ func (s *Server) handleAppAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Decrypt stored SIP password and compare.
-	storedPassword := ext.SIPPassword
-	if s.encryptor != nil && storedPassword != "" {
-		decrypted, err := s.encryptor.Decrypt(storedPassword)
-		if err != nil {
-			slog.Error("app auth: failed to decrypt sip password", "error", err, "extension_id", ext.ID)
-			writeError(w, http.StatusInternalServerError, "internal error")
-			return
-		}
-		storedPassword = decrypted
-	}
-
-	if subtle.ConstantTimeCompare([]byte(storedPassword), []byte(req.SIPPassword)) != 1 {
-		writeError(w, http.StatusUnauthorized, "invalid credentials")
-		return
-	}
-
 	// Generate JWT token.
 	token, expiresAt, err := middleware.GenerateAppToken(s.jwtSecret, ext.ID, ext.Extension)
 	if err != nil {
@@ -160,6 +144,34 @@ func (s *Server) handleAppAuth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// authenticateExtension checks an extension's SIP credentials, returning
+// the extension, or nil if the extension does not exist or the password
+// does not match.
+func (s *Server) authenticateExtension(ctx context.Context, extension, password string) (*models.Extension, error) {
+	ext, err := s.extensions.GetByExtension(ctx, extension)
+	if err != nil {
+		return nil, fmt.Errorf("querying extension: %w", err)
+	}
+	if ext == nil {
+		return nil, nil
+	}
+
+	// Decrypt stored SIP password and compare.
+	storedPassword := ext.SIPPassword
+	if s.encryptor != nil && storedPassword != "" {
+		decrypted, err := s.encryptor.Decrypt(storedPassword)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting sip password of extension %d: %w", ext.ID, err)
+		}
+		storedPassword = decrypted
+	}
+
+	if subtle.ConstantTimeCompare([]byte(storedPassword), []byte(password)) != 1 {
+		return nil, nil
+	}
+	return ext, nil
+}
+
 // handleAppGetMe handles GET /api/v1/app/me — returns the authenticated
 // extension's profile.
 func (s *Server) handleAppGetMe(w http.ResponseWriter, r *http.Request) {
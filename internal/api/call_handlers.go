@@ -0,0 +1,276 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/flowpbx/flowpbx/internal/api/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	// ErrCallNotFound is returned by a CallController when no active call
+	// has the given ID.
+	ErrCallNotFound = errors.New("call not found")
+
+	// ErrCallRejected is returned by a CallController when the call's
+	// state, or the extension acting on it, does not allow the action.
+	ErrCallRejected = errors.New("call control rejected")
+)
+
+// Transfer legs: the side of the call the destination replaces.
+const (
+	transferLegCaller = "caller"
+	transferLegCallee = "callee"
+)
+
+// Supervisor modes accepted by the supervise endpoints.
+const (
+	superviseMonitor = "monitor"
+	superviseWhisper = "whisper"
+	superviseBarge   = "barge"
+)
+
+// transferCallRequest is the body of POST /calls/{id}/transfer.
+type transferCallRequest struct {
+	Destination string `json:"destination"`
+	Leg         string `json:"leg"`
+}
+
+// originateCallRequest is the body of POST /calls/originate. The call
+// goes to either destination or the flow entry point flow_id and
+// flow_entry_node.
+type originateCallRequest struct {
+	Extension     string `json:"extension"`
+	Destination   string `json:"destination"`
+	FlowID        *int64 `json:"flow_id"`
+	FlowEntryNode string `json:"flow_entry_node"`
+}
+
+// appOriginateCallRequest is the body of POST /app/calls/originate. The
+// call is placed for the authenticated extension.
+type appOriginateCallRequest struct {
+	Destination   string `json:"destination"`
+	FlowID        *int64 `json:"flow_id"`
+	FlowEntryNode string `json:"flow_entry_node"`
+}
+
+// superviseCallRequest is the body of POST /calls/{id}/monitor, /whisper
+// and /barge.
+type superviseCallRequest struct {
+	Extension string `json:"extension"`
+}
+
+// handleHangupCall hangs up an active call, sending BYE to both parties of
+// an answered call and cancelling one still ringing.
+func (s *Server) handleHangupCall(w http.ResponseWriter, r *http.Request) {
+	if s.callControl == nil {
+		writeError(w, http.StatusServiceUnavailable, "call control not available")
+		return
+	}
+
+	callID := chi.URLParam(r, "id")
+	if err := s.callControl.HangupCall(callID); err != nil {
+		writeCallControlError(w, err, "hangup call", callID)
+		return
+	}
+
+	slog.Info("call hung up via api", "call_id", callID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleOriginateCall places a call on behalf of an extension
+// (click-to-call): the extension's phones ring first and, once one
+// answers, the destination is dialled and bridged to it, or the call
+// enters a flow entry point. The call proceeds
+// in the background; the response carries its ID.
+func (s *Server) handleOriginateCall(w http.ResponseWriter, r *http.Request) {
+	var req originateCallRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if msg := validateExtensionNumber("extension", req.Extension); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	s.originateCall(w, r, req.Extension, req.Destination, req.FlowID, req.FlowEntryNode)
+}
+
+// handleAppOriginateCall places a call from the authenticated app
+// extension to a destination. See handleOriginateCall.
+func (s *Server) handleAppOriginateCall(w http.ResponseWriter, r *http.Request) {
+	extension := middleware.AppExtensionFromContext(r.Context())
+	if extension == "" {
+		writeError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	var req appOriginateCallRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	s.originateCall(w, r, extension, req.Destination, req.FlowID, req.FlowEntryNode)
+}
+
+// originateCall validates an originate request's destination or flow
+// entry point and places the call, responding 202 with its ID.
+func (s *Server) originateCall(w http.ResponseWriter, r *http.Request, extension, destination string, flowID *int64, flowEntryNode string) {
+	if flowID != nil {
+		if destination != "" {
+			writeError(w, http.StatusBadRequest, "destination and flow_id cannot both be set")
+			return
+		}
+		if msg := validateRequiredStringLen("flow_entry_node", flowEntryNode, maxNameLen); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if !s.checkFlowEntry(w, r, *flowID, flowEntryNode) {
+			return
+		}
+	} else if msg := validateRequiredStringLen("destination", destination, maxURLLen); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if s.callControl == nil {
+		writeError(w, http.StatusServiceUnavailable, "call control not available")
+		return
+	}
+
+	var callID string
+	var err error
+	if flowID != nil {
+		callID, err = s.callControl.OriginateFlowCall(r.Context(), extension, *flowID, flowEntryNode)
+	} else {
+		callID, err = s.callControl.OriginateCall(r.Context(), extension, destination)
+	}
+	if err != nil {
+		writeCallControlError(w, err, "originate call", "")
+		return
+	}
+
+	slog.Info("call originated via api",
+		"call_id", callID,
+		"extension", extension,
+		"destination", destination,
+		"flow_id", flowID,
+		"flow_entry_node", flowEntryNode,
+	)
+
+	res := map[string]any{
+		"call_id":     callID,
+		"extension":   extension,
+		"destination": destination,
+	}
+	if flowID != nil {
+		res["flow_id"] = *flowID
+		res["flow_entry_node"] = flowEntryNode
+	}
+	writeJSON(w, http.StatusAccepted, res)
+}
+
+// handleTransferCall blindly transfers an answered call. The destination
+// replaces the callee, or the caller when leg is "caller"; the replaced
+// party is hung up once the destination answers.
+func (s *Server) handleTransferCall(w http.ResponseWriter, r *http.Request) {
+	var req transferCallRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if msg := validateRequiredStringLen("destination", req.Destination, maxURLLen); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if req.Leg == "" {
+		req.Leg = transferLegCallee
+	}
+	if req.Leg != transferLegCaller && req.Leg != transferLegCallee {
+		writeError(w, http.StatusBadRequest, "leg must be caller or callee")
+		return
+	}
+
+	if s.callControl == nil {
+		writeError(w, http.StatusServiceUnavailable, "call control not available")
+		return
+	}
+
+	callID := chi.URLParam(r, "id")
+	if err := s.callControl.TransferCall(r.Context(), callID, req.Destination, req.Leg == transferLegCaller); err != nil {
+		writeCallControlError(w, err, "transfer call", callID)
+		return
+	}
+
+	slog.Info("call transferred via api",
+		"call_id", callID,
+		"destination", req.Destination,
+		"leg", req.Leg,
+	)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"call_id":     callID,
+		"destination": req.Destination,
+		"leg":         req.Leg,
+	})
+}
+
+// handleSuperviseCall returns a handler that joins a supervisor's
+// extension to an answered call in the given mode, ringing the
+// supervisor's phones. Calling another mode's endpoint for a supervisor
+// already on the call switches its mode.
+func (s *Server) handleSuperviseCall(mode string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req superviseCallRequest
+		if errMsg := readJSON(r, &req); errMsg != "" {
+			writeError(w, http.StatusBadRequest, errMsg)
+			return
+		}
+		if msg := validateExtensionNumber("extension", req.Extension); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		if s.callControl == nil {
+			writeError(w, http.StatusServiceUnavailable, "call control not available")
+			return
+		}
+
+		callID := chi.URLParam(r, "id")
+		if err := s.callControl.SuperviseCall(r.Context(), callID, req.Extension, mode); err != nil {
+			writeCallControlError(w, err, mode+" call", callID)
+			return
+		}
+
+		slog.Info("call supervision started via api",
+			"call_id", callID,
+			"extension", req.Extension,
+			"mode", mode,
+		)
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"call_id":   callID,
+			"extension": req.Extension,
+			"mode":      mode,
+		})
+	}
+}
+
+// writeCallControlError writes the response for a failed call control
+// action: 404 for an unknown call, 409 when the action is not allowed,
+// and 502 when the PBX could not carry it out, e.g. a destination that
+// did not answer.
+func writeCallControlError(w http.ResponseWriter, err error, action, callID string) {
+	switch {
+	case errors.Is(err, ErrCallNotFound):
+		writeError(w, http.StatusNotFound, "call not found")
+	case errors.Is(err, ErrCallRejected):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error(action+": failed", "error", err, "call_id", callID)
+		writeError(w, http.StatusBadGateway, err.Error())
+	}
+}
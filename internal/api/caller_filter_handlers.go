@@ -0,0 +1,370 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// callerFilterRequest is the JSON request body for creating/updating a
+// caller filter entry.
+type callerFilterRequest struct {
+	InboundNumberID *int64 `json:"inbound_number_id"`
+	List            string `json:"list"`
+	MatchType       string `json:"match_type"`
+	Pattern         string `json:"pattern"`
+	Action          string `json:"action"`
+	VoicemailBoxID  *int64 `json:"voicemail_box_id"`
+	FlowID          *int64 `json:"flow_id"`
+	FlowNode        string `json:"flow_node"`
+	Description     string `json:"description"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+// callerFilterResponse is the JSON response for a single caller filter
+// entry.
+type callerFilterResponse struct {
+	ID              int64  `json:"id"`
+	InboundNumberID *int64 `json:"inbound_number_id"`
+	List            string `json:"list"`
+	MatchType       string `json:"match_type"`
+	Pattern         string `json:"pattern"`
+	Action          string `json:"action"`
+	VoicemailBoxID  *int64 `json:"voicemail_box_id"`
+	FlowID          *int64 `json:"flow_id"`
+	FlowNode        string `json:"flow_node"`
+	Description     string `json:"description"`
+	Enabled         bool   `json:"enabled"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+// toCallerFilterResponse converts a models.CallerFilter to the API response.
+func toCallerFilterResponse(f *models.CallerFilter) callerFilterResponse {
+	return callerFilterResponse{
+		ID:              f.ID,
+		InboundNumberID: f.InboundNumberID,
+		List:            f.List,
+		MatchType:       f.MatchType,
+		Pattern:         f.Pattern,
+		Action:          f.Action,
+		VoicemailBoxID:  f.VoicemailBoxID,
+		FlowID:          f.FlowID,
+		FlowNode:        f.FlowNode,
+		Description:     f.Description,
+		Enabled:         f.Enabled,
+		CreatedAt:       f.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:       f.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// handleListCallerFilters returns all blocklist and allowlist entries.
+func (s *Server) handleListCallerFilters(w http.ResponseWriter, r *http.Request) {
+	filters, err := s.callerFilters.List(r.Context())
+	if err != nil {
+		slog.Error("list caller filters: failed to query", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]callerFilterResponse, len(filters))
+	for i := range filters {
+		items[i] = toCallerFilterResponse(&filters[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateCallerFilter creates a new blocklist or allowlist entry.
+func (s *Server) handleCreateCallerFilter(w http.ResponseWriter, r *http.Request) {
+	var req callerFilterRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateCallerFilterRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkCallerFilterTargets(w, r, req) {
+		return
+	}
+
+	f := &models.CallerFilter{Enabled: true}
+	applyCallerFilterRequest(f, req)
+
+	if err := s.callerFilters.Create(r.Context(), f); err != nil {
+		slog.Error("create caller filter: failed to insert", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	created, err := s.callerFilters.GetByID(r.Context(), f.ID)
+	if err != nil || created == nil {
+		slog.Error("create caller filter: failed to re-fetch", "error", err, "filter_id", f.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("caller filter created", "filter_id", created.ID, "list", created.List, "pattern", created.Pattern)
+
+	writeJSON(w, http.StatusCreated, toCallerFilterResponse(created))
+}
+
+// handleGetCallerFilter returns a single caller filter entry by ID.
+func (s *Server) handleGetCallerFilter(w http.ResponseWriter, r *http.Request) {
+	id, err := parseCallerFilterID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid caller filter id")
+		return
+	}
+
+	f, err := s.callerFilters.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("get caller filter: failed to query", "error", err, "filter_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if f == nil {
+		writeError(w, http.StatusNotFound, "caller filter not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCallerFilterResponse(f))
+}
+
+// handleUpdateCallerFilter updates an existing caller filter entry.
+func (s *Server) handleUpdateCallerFilter(w http.ResponseWriter, r *http.Request) {
+	id, err := parseCallerFilterID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid caller filter id")
+		return
+	}
+
+	existing, err := s.callerFilters.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("update caller filter: failed to query", "error", err, "filter_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "caller filter not found")
+		return
+	}
+
+	var req callerFilterRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateCallerFilterRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkCallerFilterTargets(w, r, req) {
+		return
+	}
+
+	applyCallerFilterRequest(existing, req)
+
+	if err := s.callerFilters.Update(r.Context(), existing); err != nil {
+		slog.Error("update caller filter: failed to update", "error", err, "filter_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	updated, err := s.callerFilters.GetByID(r.Context(), id)
+	if err != nil || updated == nil {
+		slog.Error("update caller filter: failed to re-fetch", "error", err, "filter_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("caller filter updated", "filter_id", id, "list", updated.List, "pattern", updated.Pattern)
+
+	writeJSON(w, http.StatusOK, toCallerFilterResponse(updated))
+}
+
+// handleDeleteCallerFilter removes a caller filter entry by ID.
+func (s *Server) handleDeleteCallerFilter(w http.ResponseWriter, r *http.Request) {
+	id, err := parseCallerFilterID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid caller filter id")
+		return
+	}
+
+	existing, err := s.callerFilters.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("delete caller filter: failed to query", "error", err, "filter_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "caller filter not found")
+		return
+	}
+
+	if err := s.callerFilters.Delete(r.Context(), id); err != nil {
+		slog.Error("delete caller filter: failed to delete", "error", err, "filter_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("caller filter deleted", "filter_id", id, "list", existing.List, "pattern", existing.Pattern)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyCallerFilterRequest copies the fields of a validated caller filter
+// request onto f. Allow entries carry no action or target.
+func applyCallerFilterRequest(f *models.CallerFilter, req callerFilterRequest) {
+	f.InboundNumberID = req.InboundNumberID
+	f.List = req.List
+	f.MatchType = req.MatchType
+	f.Pattern = req.Pattern
+	f.Description = req.Description
+	if req.MatchType == "anonymous" {
+		f.Pattern = ""
+	}
+
+	f.Action = ""
+	f.VoicemailBoxID = nil
+	f.FlowID = nil
+	f.FlowNode = ""
+	if req.List == "block" {
+		f.Action = req.Action
+		switch req.Action {
+		case "voicemail":
+			f.VoicemailBoxID = req.VoicemailBoxID
+		case "flow":
+			f.FlowID = req.FlowID
+			f.FlowNode = req.FlowNode
+		}
+	}
+
+	if req.Enabled != nil {
+		f.Enabled = *req.Enabled
+	}
+}
+
+// checkCallerFilterTargets verifies that the inbound number, voicemail box
+// and flow a caller filter refers to exist, writing the error response and
+// returning false if one does not.
+func (s *Server) checkCallerFilterTargets(w http.ResponseWriter, r *http.Request, req callerFilterRequest) bool {
+	ctx := r.Context()
+
+	if req.InboundNumberID != nil {
+		num, err := s.inboundNumbers.GetByID(ctx, *req.InboundNumberID)
+		if err != nil {
+			slog.Error("caller filter: failed to look up inbound number", "error", err, "number_id", *req.InboundNumberID)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return false
+		}
+		if num == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("inbound number %d not found", *req.InboundNumberID))
+			return false
+		}
+	}
+
+	if req.List != "block" {
+		return true
+	}
+
+	switch req.Action {
+	case "voicemail":
+		box, err := s.voicemailBoxes.GetByID(ctx, *req.VoicemailBoxID)
+		if err != nil {
+			slog.Error("caller filter: failed to look up voicemail box", "error", err, "voicemail_box_id", *req.VoicemailBoxID)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return false
+		}
+		if box == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("voicemail box %d not found", *req.VoicemailBoxID))
+			return false
+		}
+	case "flow":
+		cf, err := s.callFlows.GetByID(ctx, *req.FlowID)
+		if err != nil {
+			slog.Error("caller filter: failed to look up call flow", "error", err, "flow_id", *req.FlowID)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return false
+		}
+		if cf == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("call flow %d not found", *req.FlowID))
+			return false
+		}
+	}
+	return true
+}
+
+// parseCallerFilterID extracts and parses the caller filter ID from the URL
+// parameter.
+func parseCallerFilterID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// validateCallerFilterRequest checks the fields of a caller filter
+// create/update. Exact and prefix patterns are dialled digits; regex
+// patterns must compile.
+func validateCallerFilterRequest(req callerFilterRequest) string {
+	if req.List != "block" && req.List != "allow" {
+		return "list must be \"block\" or \"allow\""
+	}
+
+	switch req.MatchType {
+	case "exact", "prefix":
+		if msg := validateRequiredStringLen("pattern", req.Pattern, maxShortStringLen); msg != "" {
+			return msg
+		}
+		if strings.Trim(req.Pattern, "0123456789+*#") != "" {
+			return "pattern must contain only digits, +, * and #"
+		}
+	case "regex":
+		if msg := validateRequiredStringLen("pattern", req.Pattern, maxNameLen); msg != "" {
+			return msg
+		}
+		if _, err := regexp.Compile(req.Pattern); err != nil {
+			return fmt.Sprintf("pattern is not a valid regular expression: %v", err)
+		}
+	case "anonymous":
+	default:
+		return "match_type must be \"exact\", \"prefix\", \"regex\", or \"anonymous\""
+	}
+
+	if msg := validateStringLen("description", req.Description, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("description", req.Description); msg != "" {
+		return msg
+	}
+
+	if req.List == "allow" {
+		return ""
+	}
+	switch req.Action {
+	case "reject":
+	case "voicemail":
+		if req.VoicemailBoxID == nil {
+			return "voicemail_box_id is required for the voicemail action"
+		}
+	case "flow":
+		if req.FlowID == nil {
+			return "flow_id is required for the flow action"
+		}
+		if msg := validateRequiredStringLen("flow_node", req.FlowNode, maxNameLen); msg != "" {
+			return msg
+		}
+	default:
+		return "action must be \"reject\", \"voicemail\", or \"flow\""
+	}
+	return ""
+}
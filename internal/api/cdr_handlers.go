@@ -15,22 +15,25 @@ import (
 
 // cdrResponse is the JSON response for a single CDR.
 type cdrResponse struct {
-	ID            int64   `json:"id"`
-	CallID        string  `json:"call_id"`
-	StartTime     string  `json:"start_time"`
-	AnswerTime    *string `json:"answer_time"`
-	EndTime       *string `json:"end_time"`
-	Duration      *int    `json:"duration"`
-	BillableDur   *int    `json:"billable_dur"`
-	CallerIDName  string  `json:"caller_id_name"`
-	CallerIDNum   string  `json:"caller_id_num"`
-	Callee        string  `json:"callee"`
-	TrunkID       *int64  `json:"trunk_id"`
-	Direction     string  `json:"direction"`
-	Disposition   string  `json:"disposition"`
-	RecordingFile string  `json:"recording_file,omitempty"`
-	FlowPath      string  `json:"flow_path,omitempty"`
-	HangupCause   string  `json:"hangup_cause"`
+	ID            int64    `json:"id"`
+	CallID        string   `json:"call_id"`
+	StartTime     string   `json:"start_time"`
+	AnswerTime    *string  `json:"answer_time"`
+	EndTime       *string  `json:"end_time"`
+	Duration      *int     `json:"duration"`
+	BillableDur   *int     `json:"billable_dur"`
+	CallerIDName  string   `json:"caller_id_name"`
+	CallerIDNum   string   `json:"caller_id_num"`
+	Callee        string   `json:"callee"`
+	TrunkID       *int64   `json:"trunk_id"`
+	Direction     string   `json:"direction"`
+	Disposition   string   `json:"disposition"`
+	RecordingFile string   `json:"recording_file,omitempty"`
+	FlowPath      string   `json:"flow_path,omitempty"`
+	HangupCause   string   `json:"hangup_cause"`
+	TransferredTo string   `json:"transferred_to,omitempty"`
+	Rate          *float64 `json:"rate"`
+	Cost          *float64 `json:"cost"`
 }
 
 // toCDRResponse converts a models.CDR to the API response.
@@ -50,6 +53,9 @@ func toCDRResponse(c *models.CDR) cdrResponse {
 		RecordingFile: c.RecordingFile,
 		FlowPath:      c.FlowPath,
 		HangupCause:   c.HangupCause,
+		TransferredTo: c.TransferredTo,
+		Rate:          c.Rate,
+		Cost:          c.Cost,
 	}
 	if c.AnswerTime != nil {
 		s := c.AnswerTime.Format(time.RFC3339)
@@ -73,8 +79,8 @@ func (s *Server) handleListCDRs(w http.ResponseWriter, r *http.Request) {
 
 	q := r.URL.Query()
 	direction := q.Get("direction")
-	if direction != "" && direction != "inbound" && direction != "outbound" && direction != "internal" {
-		writeError(w, http.StatusBadRequest, "direction must be \"inbound\", \"outbound\", or \"internal\"")
+	if direction != "" && !validCDRDirection(direction) {
+		writeError(w, http.StatusBadRequest, "direction must be \"inbound\", \"outbound\", \"internal\", \"originate\", or \"flow\"")
 		return
 	}
 
@@ -133,8 +139,8 @@ func (s *Server) handleGetCDR(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleExportCDRs(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	direction := q.Get("direction")
-	if direction != "" && direction != "inbound" && direction != "outbound" && direction != "internal" {
-		writeError(w, http.StatusBadRequest, "direction must be \"inbound\", \"outbound\", or \"internal\"")
+	if direction != "" && !validCDRDirection(direction) {
+		writeError(w, http.StatusBadRequest, "direction must be \"inbound\", \"outbound\", \"internal\", \"originate\", or \"flow\"")
 		return
 	}
 
@@ -164,7 +170,7 @@ func (s *Server) handleExportCDRs(w http.ResponseWriter, r *http.Request) {
 		"ID", "Call-ID", "Start Time", "Answer Time", "End Time",
 		"Duration", "Billable Duration", "Caller Name", "Caller Number",
 		"Callee", "Trunk ID", "Direction", "Disposition", "Hangup Cause",
-		"Recording File",
+		"Recording File", "Transferred To", "Rate", "Cost",
 	})
 
 	for _, c := range cdrs {
@@ -188,6 +194,14 @@ func (s *Server) handleExportCDRs(w http.ResponseWriter, r *http.Request) {
 		if c.TrunkID != nil {
 			trunkID = strconv.FormatInt(*c.TrunkID, 10)
 		}
+		rate := ""
+		if c.Rate != nil {
+			rate = strconv.FormatFloat(*c.Rate, 'f', -1, 64)
+		}
+		cost := ""
+		if c.Cost != nil {
+			cost = strconv.FormatFloat(*c.Cost, 'f', 4, 64)
+		}
 
 		cw.Write([]string{
 			strconv.FormatInt(c.ID, 10),
@@ -205,6 +219,9 @@ func (s *Server) handleExportCDRs(w http.ResponseWriter, r *http.Request) {
 			c.Disposition,
 			c.HangupCause,
 			c.RecordingFile,
+			c.TransferredTo,
+			rate,
+			cost,
 		})
 	}
 
@@ -214,6 +231,51 @@ func (s *Server) handleExportCDRs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// extensionCostResponse is the JSON response for the call cost of one
+// extension.
+type extensionCostResponse struct {
+	Extension   string  `json:"extension"`
+	Calls       int     `json:"calls"`
+	BillableSec int     `json:"billable_sec"`
+	Cost        float64 `json:"cost"`
+}
+
+// handleCDRCosts returns the cost of rated calls per calling extension,
+// most expensive first. Query params: search, direction, start_date,
+// end_date.
+func (s *Server) handleCDRCosts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	direction := q.Get("direction")
+	if direction != "" && !validCDRDirection(direction) {
+		writeError(w, http.StatusBadRequest, "direction must be \"inbound\", \"outbound\", \"internal\", \"originate\", or \"flow\"")
+		return
+	}
+
+	costs, err := s.cdrs.CostByExtension(r.Context(), database.CDRListFilter{
+		Search:    q.Get("search"),
+		Direction: direction,
+		StartDate: q.Get("start_date"),
+		EndDate:   q.Get("end_date"),
+	})
+	if err != nil {
+		slog.Error("cdr costs: failed to query", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]extensionCostResponse, len(costs))
+	for i, c := range costs {
+		items[i] = extensionCostResponse{
+			Extension:   c.Extension,
+			Calls:       c.Calls,
+			BillableSec: c.BillableSec,
+			Cost:        c.Cost,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
 // handleDashboardStats returns aggregate statistics for the admin dashboard.
 func (s *Server) handleDashboardStats(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -297,3 +359,13 @@ func (s *Server) handleDashboardStats(w http.ResponseWriter, r *http.Request) {
 		"recent_cdrs":        cdrEntries,
 	})
 }
+
+// validCDRDirection reports whether direction is one a CDR can be filtered
+// by.
+func validCDRDirection(direction string) bool {
+	switch direction {
+	case "inbound", "outbound", "internal", "originate", "flow":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/flowpbx/flowpbx/internal/api/middleware"
+	"github.com/flowpbx/flowpbx/internal/api/ws"
+)
+
+// handleEvents streams real-time events over a WebSocket, or as server-sent
+// events when the request is not a WebSocket upgrade. The optional topics
+// query parameter is a comma-separated list of topics (default all). Admin
+// sessions receive every event; app clients only receive events that
+// concern their own extension.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.events == nil {
+		writeError(w, http.StatusServiceUnavailable, "event stream not available")
+		return
+	}
+
+	topics, err := ws.ParseTopics(r.URL.Query().Get("topics"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sub := ws.Subscription{Topics: topics}
+	if middleware.AdminUserFromContext(r.Context()) == nil {
+		sub.Extension = middleware.AppExtensionFromContext(r.Context())
+		if sub.Extension == "" {
+			writeError(w, http.StatusForbidden, "token has no extension")
+			return
+		}
+	}
+
+	if ws.IsWebSocketUpgrade(r) {
+		s.events.ServeWebSocket(w, r, sub)
+		return
+	}
+	s.events.ServeSSE(w, r, sub)
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/dialplan"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -27,6 +28,12 @@ type extensionRequest struct {
 	FollowMeConfirm  *bool           `json:"follow_me_confirm"`
 	RecordingMode    string          `json:"recording_mode"`
 	MaxRegistrations *int            `json:"max_registrations"`
+	PickupGroup      *string         `json:"pickup_group"`
+	SRTPMode         string          `json:"srtp_mode"`
+	Supervisor       *bool           `json:"supervisor"`
+	ClassOfService   string          `json:"class_of_service"`
+	MOHClassID       *int64          `json:"moh_class_id"` // 0 clears it on update
+	DirectoryExclude *bool           `json:"directory_exclude"`
 }
 
 // extensionResponse is the JSON response for a single extension.
@@ -45,6 +52,12 @@ type extensionResponse struct {
 	FollowMeConfirm  bool            `json:"follow_me_confirm"`
 	RecordingMode    string          `json:"recording_mode"`
 	MaxRegistrations int             `json:"max_registrations"`
+	PickupGroup      string          `json:"pickup_group"`
+	SRTPMode         string          `json:"srtp_mode"`
+	Supervisor       bool            `json:"supervisor"`
+	ClassOfService   string          `json:"class_of_service"`
+	MOHClassID       *int64          `json:"moh_class_id"`
+	DirectoryExclude bool            `json:"directory_exclude"`
 	CreatedAt        string          `json:"created_at"`
 	UpdatedAt        string          `json:"updated_at"`
 }
@@ -68,6 +81,12 @@ func toExtensionResponse(e *models.Extension) extensionResponse {
 		FollowMeConfirm:  e.FollowMeConfirm,
 		RecordingMode:    e.RecordingMode,
 		MaxRegistrations: e.MaxRegistrations,
+		PickupGroup:      e.PickupGroup,
+		SRTPMode:         e.SRTPMode,
+		Supervisor:       e.Supervisor,
+		ClassOfService:   e.ClassOfService,
+		MOHClassID:       e.MOHClassID,
+		DirectoryExclude: e.DirectoryExclude,
 		CreatedAt:        e.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:        e.UpdatedAt.Format(time.RFC3339),
 	}
@@ -132,6 +151,12 @@ func (s *Server) handleCreateExtension(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, errMsg)
 		return
 	}
+	if req.MOHClassID != nil && *req.MOHClassID == 0 {
+		req.MOHClassID = nil
+	}
+	if !s.checkMOHClass(w, r, req.MOHClassID) {
+		return
+	}
 
 	// Encrypt SIP password at rest if encryptor is available.
 	sipPassword := req.SIPPassword
@@ -158,6 +183,8 @@ func (s *Server) handleCreateExtension(w http.ResponseWriter, r *http.Request) {
 		FollowMeStrategy: "sequential",
 		RecordingMode:    "off",
 		MaxRegistrations: 5,
+		SRTPMode:         "optional",
+		ClassOfService:   dialplan.ClassInternational,
 	}
 
 	// Apply optional fields.
@@ -185,6 +212,22 @@ func (s *Server) handleCreateExtension(w http.ResponseWriter, r *http.Request) {
 	if req.MaxRegistrations != nil {
 		ext.MaxRegistrations = *req.MaxRegistrations
 	}
+	if req.PickupGroup != nil {
+		ext.PickupGroup = *req.PickupGroup
+	}
+	if req.SRTPMode != "" {
+		ext.SRTPMode = req.SRTPMode
+	}
+	if req.Supervisor != nil {
+		ext.Supervisor = *req.Supervisor
+	}
+	if req.ClassOfService != "" {
+		ext.ClassOfService = req.ClassOfService
+	}
+	ext.MOHClassID = req.MOHClassID
+	if req.DirectoryExclude != nil {
+		ext.DirectoryExclude = *req.DirectoryExclude
+	}
 
 	if err := s.extensions.Create(r.Context(), ext); err != nil {
 		slog.Error("create extension: failed to insert", "error", err)
@@ -256,6 +299,9 @@ func (s *Server) handleUpdateExtension(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, errMsg)
 		return
 	}
+	if req.MOHClassID != nil && *req.MOHClassID != 0 && !s.checkMOHClass(w, r, req.MOHClassID) {
+		return
+	}
 
 	// Update fields from request.
 	existing.Extension = req.Extension
@@ -302,6 +348,27 @@ func (s *Server) handleUpdateExtension(w http.ResponseWriter, r *http.Request) {
 	if req.MaxRegistrations != nil {
 		existing.MaxRegistrations = *req.MaxRegistrations
 	}
+	if req.PickupGroup != nil {
+		existing.PickupGroup = *req.PickupGroup
+	}
+	if req.SRTPMode != "" {
+		existing.SRTPMode = req.SRTPMode
+	}
+	if req.Supervisor != nil {
+		existing.Supervisor = *req.Supervisor
+	}
+	if req.ClassOfService != "" {
+		existing.ClassOfService = req.ClassOfService
+	}
+	if req.MOHClassID != nil {
+		existing.MOHClassID = req.MOHClassID
+		if *req.MOHClassID == 0 {
+			existing.MOHClassID = nil
+		}
+	}
+	if req.DirectoryExclude != nil {
+		existing.DirectoryExclude = *req.DirectoryExclude
+	}
 
 	if err := s.extensions.Update(r.Context(), existing); err != nil {
 		slog.Error("update extension: failed to update", "error", err, "extension_id", id)
@@ -449,6 +516,12 @@ func validateExtensionRequest(req extensionRequest, isCreate bool) string {
 	if req.RecordingMode != "" && req.RecordingMode != "off" && req.RecordingMode != "always" && req.RecordingMode != "on_demand" {
 		return "recording_mode must be \"off\", \"always\", or \"on_demand\""
 	}
+	if req.SRTPMode != "" && req.SRTPMode != "off" && req.SRTPMode != "optional" && req.SRTPMode != "required" {
+		return "srtp_mode must be \"off\", \"optional\", or \"required\""
+	}
+	if req.ClassOfService != "" && !dialplan.ValidClassOfService(req.ClassOfService) {
+		return "class_of_service must be \"internal\", \"local\", \"national\", \"international\", or \"premium\""
+	}
 	if msg := validateIntRange("ring_timeout", req.RingTimeout, 1, 600); msg != "" {
 		return msg
 	}
@@ -487,5 +560,13 @@ func validateExtensionRequest(req extensionRequest, isCreate bool) string {
 	if req.FollowMeStrategy != "" && req.FollowMeStrategy != "sequential" && req.FollowMeStrategy != "simultaneous" {
 		return "follow_me_strategy must be \"sequential\" or \"simultaneous\""
 	}
+	if req.PickupGroup != nil {
+		if msg := validateStringLen("pickup_group", *req.PickupGroup, maxShortStringLen); msg != "" {
+			return msg
+		}
+		if msg := validateNoControlChars("pickup_group", *req.PickupGroup); msg != "" {
+			return msg
+		}
+	}
 	return ""
 }
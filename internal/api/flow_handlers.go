@@ -2,10 +2,12 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/flowpbx/flowpbx/internal/database/models"
@@ -27,20 +29,24 @@ type flowResponse struct {
 	Version     int    `json:"version"`
 	Published   bool   `json:"published"`
 	PublishedAt string `json:"published_at,omitempty"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	// PublishedRevision is the revision live calls run, zero if the flow
+	// has never been published.
+	PublishedRevision int    `json:"published_revision,omitempty"`
+	CreatedAt         string `json:"created_at"`
+	UpdatedAt         string `json:"updated_at"`
 }
 
 // toFlowResponse converts a models.CallFlow to the API response.
 func toFlowResponse(f *models.CallFlow) flowResponse {
 	resp := flowResponse{
-		ID:        f.ID,
-		Name:      f.Name,
-		FlowData:  f.FlowData,
-		Version:   f.Version,
-		Published: f.Published,
-		CreatedAt: f.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: f.UpdatedAt.Format(time.RFC3339),
+		ID:                f.ID,
+		Name:              f.Name,
+		FlowData:          f.FlowData,
+		Version:           f.Version,
+		Published:         f.Published,
+		PublishedRevision: f.PublishedRevision,
+		CreatedAt:         f.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:         f.UpdatedAt.Format(time.RFC3339),
 	}
 	if f.PublishedAt != nil {
 		resp.PublishedAt = f.PublishedAt.Format(time.RFC3339)
@@ -216,6 +222,10 @@ func (s *Server) handleDeleteFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.checkFlowParkLots(w, r, id, nil) {
+		return
+	}
+
 	if err := s.callFlows.Delete(r.Context(), id); err != nil {
 		slog.Error("delete flow: failed to delete", "error", err, "flow_id", id)
 		writeError(w, http.StatusInternalServerError, "internal error")
@@ -227,7 +237,9 @@ func (s *Server) handleDeleteFlow(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// handlePublishFlow publishes a call flow, making it available for live call routing.
+// handlePublishFlow publishes a call flow, storing its current draft as a
+// new revision and pinning live call routing to it. A draft that fails
+// validation with errors is rejected.
 func (s *Server) handlePublishFlow(w http.ResponseWriter, r *http.Request) {
 	id, err := parseFlowID(r)
 	if err != nil {
@@ -246,14 +258,40 @@ func (s *Server) handlePublishFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.callFlows.Publish(r.Context(), id); err != nil {
+	// Refuse to publish a draft that would fail on live calls, such as one
+	// with an expression or template that does not parse.
+	graph, err := flow.ParseFlowGraph(existing.FlowData)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid flow data: "+err.Error())
+		return
+	}
+	if result := s.flowValidator.Validate(r.Context(), existing.ID, graph, ""); !result.Valid {
+		var msgs []string
+		for _, issue := range result.Issues {
+			if issue.Severity == flow.SeverityError {
+				msgs = append(msgs, issue.Message)
+			}
+		}
+		writeError(w, http.StatusBadRequest, "flow has errors: "+strings.Join(msgs, "; "))
+		return
+	}
+	if !s.checkFlowParkLots(w, r, existing.ID, graph) {
+		return
+	}
+
+	rev, err := s.callFlows.Publish(r.Context(), id, actingUsername(r))
+	if err != nil {
 		slog.Error("publish flow: failed to publish", "error", err, "flow_id", id)
 		writeError(w, http.StatusInternalServerError, "internal error")
 		return
 	}
+	if rev == nil {
+		writeError(w, http.StatusNotFound, "flow not found")
+		return
+	}
 
 	// Link inbound numbers referenced in the flow graph back to this flow.
-	if err := s.linkInboundNumbersToFlow(r.Context(), id, existing.FlowData); err != nil {
+	if err := s.linkInboundNumbersToFlow(r.Context(), id, rev.FlowData); err != nil {
 		slog.Error("publish flow: failed to link inbound numbers", "error", err, "flow_id", id)
 		writeError(w, http.StatusInternalServerError, "internal error")
 		return
@@ -266,7 +304,8 @@ func (s *Server) handlePublishFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info("call flow published", "flow_id", id, "name", published.Name, "version", published.Version)
+	slog.Info("call flow published", "flow_id", id, "name", published.Name, "version", published.Version,
+		"revision", rev.Revision, "by", rev.Author)
 
 	writeJSON(w, http.StatusOK, toFlowResponse(published))
 }
@@ -308,7 +347,7 @@ func (s *Server) handleValidateFlow(w http.ResponseWriter, r *http.Request) {
 	// For validation purposes, use empty string if no specific entry is set.
 	entryNode := ""
 
-	result := s.flowValidator.Validate(r.Context(), graph, entryNode)
+	result := s.flowValidator.Validate(r.Context(), existing.ID, graph, entryNode)
 
 	writeJSON(w, http.StatusOK, result)
 }
@@ -389,6 +428,25 @@ func (s *Server) linkInboundNumbersToFlow(ctx context.Context, flowID int64, flo
 	return nil
 }
 
+// checkFlowEntry verifies that a call can enter the published revision of
+// flow flowID at node entryNode. It writes the error response and returns
+// false if it cannot.
+func (s *Server) checkFlowEntry(w http.ResponseWriter, r *http.Request, flowID int64, entryNode string) bool {
+	err := flow.CheckEntry(r.Context(), s.callFlows, flowID, entryNode)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, flow.ErrFlowNotFound),
+		errors.Is(err, flow.ErrFlowNotPublished),
+		errors.Is(err, flow.ErrEntryNodeNotFound):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("failed to check flow entry", "error", err, "flow_id", flowID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+	return false
+}
+
 // parseFlowID extracts and parses the call flow ID from the URL parameter.
 func parseFlowID(r *http.Request) (int64, error) {
 	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/go-chi/chi/v5"
+)
+
+// diffDraft names the unpublished working copy of a flow in the from and
+// to parameters of the diff endpoint.
+const diffDraft = "draft"
+
+// flowRevisionResponse is the JSON response for a call flow revision. The
+// flow data is included only when a single revision is fetched.
+type flowRevisionResponse struct {
+	ID           int64  `json:"id"`
+	FlowID       int64  `json:"flow_id"`
+	Revision     int    `json:"revision"`
+	Name         string `json:"name"`
+	FlowData     string `json:"flow_data,omitempty"`
+	Author       string `json:"author"`
+	RestoredFrom *int   `json:"restored_from,omitempty"`
+	Published    bool   `json:"published"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// toFlowRevisionResponse converts a models.CallFlowRevision to the API
+// response. published marks the revision live calls run.
+func toFlowRevisionResponse(v *models.CallFlowRevision, published bool, withData bool) flowRevisionResponse {
+	resp := flowRevisionResponse{
+		ID:           v.ID,
+		FlowID:       v.FlowID,
+		Revision:     v.Revision,
+		Name:         v.Name,
+		Author:       v.Author,
+		RestoredFrom: v.RestoredFrom,
+		Published:    published,
+		CreatedAt:    v.CreatedAt.Format(time.RFC3339),
+	}
+	if withData {
+		resp.FlowData = v.FlowData
+	}
+	return resp
+}
+
+// flowDiffResponse is the JSON response for GET /flows/{id}/diff.
+type flowDiffResponse struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	flow.GraphDiff
+}
+
+// handleListFlowRevisions returns the published revisions of a call flow,
+// newest first.
+func (s *Server) handleListFlowRevisions(w http.ResponseWriter, r *http.Request) {
+	f := s.loadFlow(w, r, "list flow revisions")
+	if f == nil {
+		return
+	}
+
+	revs, err := s.callFlows.ListRevisions(r.Context(), f.ID)
+	if err != nil {
+		slog.Error("list flow revisions: failed to query", "error", err, "flow_id", f.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]flowRevisionResponse, len(revs))
+	for i := range revs {
+		items[i] = toFlowRevisionResponse(&revs[i], revs[i].Revision == f.PublishedRevision, false)
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleGetFlowRevision returns a single revision of a call flow with its
+// flow data.
+func (s *Server) handleGetFlowRevision(w http.ResponseWriter, r *http.Request) {
+	f := s.loadFlow(w, r, "get flow revision")
+	if f == nil {
+		return
+	}
+	revision, err := parseFlowRevision(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid revision")
+		return
+	}
+
+	rev, err := s.callFlows.GetRevision(r.Context(), f.ID, revision)
+	if err != nil {
+		slog.Error("get flow revision: failed to query", "error", err, "flow_id", f.ID, "revision", revision)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if rev == nil {
+		writeError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toFlowRevisionResponse(rev, rev.Revision == f.PublishedRevision, true))
+}
+
+// handleRollbackFlow handles POST /flows/{id}/revisions/{revision}/rollback —
+// publishes a copy of an earlier revision as a new revision and resets the
+// draft to it. Inbound numbers are relinked to match the restored graph.
+func (s *Server) handleRollbackFlow(w http.ResponseWriter, r *http.Request) {
+	f := s.loadFlow(w, r, "rollback flow")
+	if f == nil {
+		return
+	}
+	revision, err := parseFlowRevision(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid revision")
+		return
+	}
+
+	target, err := s.callFlows.GetRevision(r.Context(), f.ID, revision)
+	if err != nil {
+		slog.Error("rollback flow: failed to load revision", "error", err, "flow_id", f.ID, "revision", revision)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if target == nil {
+		writeError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	graph, err := flow.ParseFlowGraph(target.FlowData)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid flow data: "+err.Error())
+		return
+	}
+	if !s.checkFlowParkLots(w, r, f.ID, graph) {
+		return
+	}
+
+	rev, err := s.callFlows.Rollback(r.Context(), f.ID, revision, actingUsername(r))
+	if err != nil {
+		slog.Error("rollback flow: failed to roll back", "error", err, "flow_id", f.ID, "revision", revision)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if rev == nil {
+		writeError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+
+	if err := s.linkInboundNumbersToFlow(r.Context(), f.ID, rev.FlowData); err != nil {
+		slog.Error("rollback flow: failed to link inbound numbers", "error", err, "flow_id", f.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	updated, err := s.callFlows.GetByID(r.Context(), f.ID)
+	if err != nil || updated == nil {
+		slog.Error("rollback flow: failed to re-fetch", "error", err, "flow_id", f.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Warn("call flow rolled back",
+		"flow_id", f.ID,
+		"name", updated.Name,
+		"restored_revision", revision,
+		"revision", rev.Revision,
+		"by", rev.Author,
+	)
+
+	writeJSON(w, http.StatusOK, toFlowResponse(updated))
+}
+
+// handleDiffFlow handles GET /flows/{id}/diff?from=&to= — compares two
+// versions of a call flow node by node and edge by edge. Each of from and
+// to is a revision number or "draft". from defaults to the published
+// revision and to to the draft, showing what publishing would change.
+func (s *Server) handleDiffFlow(w http.ResponseWriter, r *http.Request) {
+	f := s.loadFlow(w, r, "diff flow")
+	if f == nil {
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		if f.PublishedRevision == 0 {
+			writeError(w, http.StatusBadRequest, "flow has not been published; from is required")
+			return
+		}
+		from = strconv.Itoa(f.PublishedRevision)
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = diffDraft
+	}
+
+	fromGraph := s.loadFlowVersion(w, r, f, from)
+	if fromGraph == nil {
+		return
+	}
+	toGraph := s.loadFlowVersion(w, r, f, to)
+	if toGraph == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, flowDiffResponse{
+		From:      from,
+		To:        to,
+		GraphDiff: flow.DiffGraphs(fromGraph, toGraph),
+	})
+}
+
+// loadFlow fetches the call flow named by the URL, writing the error
+// response and returning nil if it is invalid or does not exist. op
+// prefixes log messages.
+func (s *Server) loadFlow(w http.ResponseWriter, r *http.Request, op string) *models.CallFlow {
+	id, err := parseFlowID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid flow id")
+		return nil
+	}
+
+	f, err := s.callFlows.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error(op+": failed to query flow", "error", err, "flow_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return nil
+	}
+	if f == nil {
+		writeError(w, http.StatusNotFound, "flow not found")
+		return nil
+	}
+	return f
+}
+
+// loadFlowVersion parses the graph of a flow's draft or of one of its
+// revisions, writing the error response and returning nil on failure.
+func (s *Server) loadFlowVersion(w http.ResponseWriter, r *http.Request, f *models.CallFlow, version string) *flow.FlowGraph {
+	data := f.FlowData
+	if version != diffDraft {
+		revision, err := strconv.Atoi(version)
+		if err != nil || revision < 1 {
+			writeError(w, http.StatusBadRequest, "from and to must be a revision number or \"draft\"")
+			return nil
+		}
+		rev, err := s.callFlows.GetRevision(r.Context(), f.ID, revision)
+		if err != nil {
+			slog.Error("diff flow: failed to query revision", "error", err, "flow_id", f.ID, "revision", revision)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return nil
+		}
+		if rev == nil {
+			writeError(w, http.StatusNotFound, "revision "+version+" not found")
+			return nil
+		}
+		data = rev.FlowData
+	}
+
+	graph, err := flow.ParseFlowGraph(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid flow data in "+version+": "+err.Error())
+		return nil
+	}
+	return graph
+}
+
+// parseFlowRevision extracts and parses the revision number from the URL
+// parameter.
+func parseFlowRevision(r *http.Request) (int, error) {
+	return strconv.Atoi(chi.URLParam(r, "revision"))
+}
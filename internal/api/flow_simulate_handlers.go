@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// simulationTimeout bounds a single flow simulation.
+const simulationTimeout = 10 * time.Second
+
+// maxSimulatedInputs limits the DTMF entries, answering numbers and webhook
+// responses in a simulation request.
+const maxSimulatedInputs = 100
+
+// simulatedDTMFRe validates the digits entered at one simulated prompt.
+var simulatedDTMFRe = regexp.MustCompile(`^[0-9*#A-D]{0,32}$`)
+
+// flowSimulateRequest is the JSON request body for POST /flows/{id}/simulate.
+// It scripts the caller; see flow.SimulatedCall.
+type flowSimulateRequest struct {
+	// Revision selects a published revision to run; zero runs the draft.
+	Revision int `json:"revision"`
+	// EntryNode defaults to the inbound number node for the DID, or the
+	// graph's only inbound number node.
+	EntryNode    string                             `json:"entry_node"`
+	CallerIDName string                             `json:"caller_id_name"`
+	CallerIDNum  string                             `json:"caller_id_num"`
+	DID          string                             `json:"did"`
+	Time         string                             `json:"time"` // RFC 3339, defaults to now
+	DTMF         []string                           `json:"dtmf"`
+	Answer       []string                           `json:"answer"`
+	Webhooks     map[string]simulatedWebhookRequest `json:"webhooks"`
+}
+
+// simulatedWebhookRequest is the scripted response of a webhook node. The
+// body may be given as a JSON value or as a string.
+type simulatedWebhookRequest struct {
+	Status   int             `json:"status"`
+	Body     json.RawMessage `json:"body"`
+	TimedOut bool            `json:"timed_out"`
+}
+
+// flowSimulateResponse is the JSON response for POST /flows/{id}/simulate.
+type flowSimulateResponse struct {
+	Revision  int    `json:"revision,omitempty"`
+	EntryNode string `json:"entry_node"`
+	*flow.SimulationResult
+}
+
+// handleSimulateFlow handles POST /flows/{id}/simulate — a dry run of a
+// call flow for a scripted caller. Nothing is dialled, recorded or stored
+// and no webhooks are sent. The draft is run unless a revision is given.
+func (s *Server) handleSimulateFlow(w http.ResponseWriter, r *http.Request) {
+	f := s.loadFlow(w, r, "simulate flow")
+	if f == nil {
+		return
+	}
+
+	var req flowSimulateRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if errMsg := validateFlowSimulateRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	call := flow.SimulatedCall{
+		FlowID:       f.ID,
+		CallerIDName: req.CallerIDName,
+		CallerIDNum:  req.CallerIDNum,
+		DID:          req.DID,
+		DTMF:         req.DTMF,
+		Answer:       req.Answer,
+		Webhooks:     make(map[string]flow.SimulatedWebhook, len(req.Webhooks)),
+	}
+	if req.Time != "" {
+		call.Time, _ = time.Parse(time.RFC3339, req.Time)
+	}
+	for nodeID, hook := range req.Webhooks {
+		call.Webhooks[nodeID] = flow.SimulatedWebhook{
+			Status:   hook.Status,
+			Body:     simulatedWebhookBody(hook.Body),
+			TimedOut: hook.TimedOut,
+		}
+	}
+
+	version := diffDraft
+	if req.Revision != 0 {
+		version = strconv.Itoa(req.Revision)
+	}
+	graph := s.loadFlowVersion(w, r, f, version)
+	if graph == nil {
+		return
+	}
+
+	entryNode, ok := s.resolveSimulationEntry(w, r, graph, req.EntryNode, &call)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), simulationTimeout)
+	defer cancel()
+
+	res, err := s.flowSimulator.Simulate(ctx, graph, entryNode, call)
+	if err != nil {
+		slog.Error("simulate flow: failed to run", "error", err, "flow_id", f.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, flowSimulateResponse{
+		Revision:         req.Revision,
+		EntryNode:        entryNode,
+		SimulationResult: res,
+	})
+}
+
+// resolveSimulationEntry picks the node a simulated call enters the graph
+// at and fills in the call's inbound number. An explicit entry node wins;
+// otherwise the inbound number node for the DID is used, or the graph's
+// only inbound number node. It writes the error response and returns false
+// if no entry node can be found.
+func (s *Server) resolveSimulationEntry(w http.ResponseWriter, r *http.Request, graph *flow.FlowGraph, entryNode string, call *flow.SimulatedCall) (string, bool) {
+	var inbound []flow.Node
+	for _, n := range graph.Nodes {
+		if n.Type == "inbound_number" {
+			inbound = append(inbound, n)
+		}
+	}
+
+	if call.DID != "" {
+		num, err := s.inboundNumbers.GetByNumber(r.Context(), call.DID)
+		if err != nil {
+			slog.Error("simulate flow: failed to look up did", "error", err, "did", call.DID)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return "", false
+		}
+		call.InboundNumber = num
+		if num != nil && entryNode == "" {
+			for _, n := range inbound {
+				if n.Data.EntityID != nil && *n.Data.EntityID == num.ID {
+					entryNode = n.ID
+					break
+				}
+			}
+		}
+	}
+
+	if entryNode == "" {
+		if len(inbound) != 1 {
+			writeError(w, http.StatusBadRequest, "entry_node is required: the flow has no single inbound number node for the call")
+			return "", false
+		}
+		entryNode = inbound[0].ID
+	}
+
+	var entry *flow.Node
+	for i := range graph.Nodes {
+		if graph.Nodes[i].ID == entryNode {
+			entry = &graph.Nodes[i]
+			break
+		}
+	}
+	if entry == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("entry node %q not found in flow", entryNode))
+		return "", false
+	}
+
+	// Entering at an inbound number node without a DID calls that number.
+	if call.InboundNumber == nil && entry.Type == "inbound_number" && entry.Data.EntityID != nil {
+		num, err := s.inboundNumbers.GetByID(r.Context(), *entry.Data.EntityID)
+		if err != nil {
+			slog.Error("simulate flow: failed to query inbound number", "error", err, "inbound_number_id", *entry.Data.EntityID)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return "", false
+		}
+		if num != nil {
+			call.InboundNumber = num
+			if call.DID == "" {
+				call.DID = num.Number
+			}
+		}
+	}
+	return entryNode, true
+}
+
+// simulatedWebhookBody returns a scripted webhook body: a JSON string is
+// used as is, any other JSON value as its encoding.
+func simulatedWebhookBody(raw json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+	return string(raw)
+}
+
+// validateFlowSimulateRequest checks the fields of a flow simulation.
+func validateFlowSimulateRequest(req flowSimulateRequest) string {
+	if req.Revision < 0 {
+		return "revision must not be negative"
+	}
+	if msg := validateStringLen("entry_node", req.EntryNode, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateStringLen("caller_id_name", req.CallerIDName, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("caller_id_name", req.CallerIDName); msg != "" {
+		return msg
+	}
+	if msg := validateStringLen("caller_id_num", req.CallerIDNum, maxShortStringLen); msg != "" {
+		return msg
+	}
+	if msg := validateStringLen("did", req.DID, maxShortStringLen); msg != "" {
+		return msg
+	}
+	if req.Time != "" {
+		if _, err := time.Parse(time.RFC3339, req.Time); err != nil {
+			return "time must be an RFC 3339 timestamp"
+		}
+	}
+
+	if len(req.DTMF) > maxSimulatedInputs {
+		return fmt.Sprintf("dtmf must have at most %d entries", maxSimulatedInputs)
+	}
+	for _, d := range req.DTMF {
+		if !simulatedDTMFRe.MatchString(d) {
+			return "dtmf entries must be up to 32 of the digits 0-9, *, # and A-D"
+		}
+	}
+
+	if len(req.Answer) > maxSimulatedInputs {
+		return fmt.Sprintf("answer must have at most %d numbers", maxSimulatedInputs)
+	}
+	for _, a := range req.Answer {
+		if msg := validateRequiredStringLen("answer number", a, maxShortStringLen); msg != "" {
+			return msg
+		}
+	}
+
+	if len(req.Webhooks) > maxSimulatedInputs {
+		return fmt.Sprintf("webhooks must have at most %d responses", maxSimulatedInputs)
+	}
+	for nodeID, hook := range req.Webhooks {
+		if hook.Status != 0 && (hook.Status < 100 || hook.Status > 599) {
+			return fmt.Sprintf("webhook %q: status must be between 100 and 599", nodeID)
+		}
+		if len(hook.Body) > maxLongStringLen*64 {
+			return fmt.Sprintf("webhook %q: body exceeds maximum length", nodeID)
+		}
+	}
+	return ""
+}
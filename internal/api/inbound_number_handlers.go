@@ -18,6 +18,7 @@ type inboundNumberRequest struct {
 	FlowID        *int64 `json:"flow_id"`
 	FlowEntryNode string `json:"flow_entry_node"`
 	Enabled       *bool  `json:"enabled"`
+	MOHClassID    *int64 `json:"moh_class_id"`
 }
 
 // inboundNumberResponse is the JSON response for a single inbound number.
@@ -29,6 +30,7 @@ type inboundNumberResponse struct {
 	FlowID        *int64 `json:"flow_id"`
 	FlowEntryNode string `json:"flow_entry_node"`
 	Enabled       bool   `json:"enabled"`
+	MOHClassID    *int64 `json:"moh_class_id"`
 	CreatedAt     string `json:"created_at"`
 	UpdatedAt     string `json:"updated_at"`
 }
@@ -43,6 +45,7 @@ func toInboundNumberResponse(n *models.InboundNumber) inboundNumberResponse {
 		FlowID:        n.FlowID,
 		FlowEntryNode: n.FlowEntryNode,
 		Enabled:       n.Enabled,
+		MOHClassID:    n.MOHClassID,
 		CreatedAt:     n.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:     n.UpdatedAt.Format(time.RFC3339),
 	}
@@ -98,6 +101,9 @@ func (s *Server) handleCreateInboundNumber(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusBadRequest, errMsg)
 		return
 	}
+	if !s.checkMOHClass(w, r, req.MOHClassID) {
+		return
+	}
 
 	enabled := true
 	if req.Enabled != nil {
@@ -111,6 +117,7 @@ func (s *Server) handleCreateInboundNumber(w http.ResponseWriter, r *http.Reques
 		FlowID:        req.FlowID,
 		FlowEntryNode: req.FlowEntryNode,
 		Enabled:       enabled,
+		MOHClassID:    req.MOHClassID,
 	}
 
 	if err := s.inboundNumbers.Create(r.Context(), num); err != nil {
@@ -183,6 +190,9 @@ func (s *Server) handleUpdateInboundNumber(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusBadRequest, errMsg)
 		return
 	}
+	if !s.checkMOHClass(w, r, req.MOHClassID) {
+		return
+	}
 
 	enabled := existing.Enabled
 	if req.Enabled != nil {
@@ -195,6 +205,7 @@ func (s *Server) handleUpdateInboundNumber(w http.ResponseWriter, r *http.Reques
 	existing.FlowID = req.FlowID
 	existing.FlowEntryNode = req.FlowEntryNode
 	existing.Enabled = enabled
+	existing.MOHClassID = req.MOHClassID
 
 	if err := s.inboundNumbers.Update(r.Context(), existing); err != nil {
 		slog.Error("update inbound number: failed to update", "error", err, "number_id", id)
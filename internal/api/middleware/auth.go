@@ -37,16 +37,22 @@ const (
 type AdminUser struct {
 	ID       int64
 	Username string
+	Role     string
 }
 
-// Session represents an active admin session.
+// Session represents an active admin session, or a self-service session of
+// an extension user (Role RoleExtension, with ExtensionID set and UserID
+// zero).
 type Session struct {
-	ID        string
-	UserID    int64
-	Username  string
-	CSRFToken string
-	ExpiresAt time.Time
-	CreatedAt time.Time
+	ID          string
+	UserID      int64
+	Username    string
+	Role        string
+	ExtensionID int64
+	Extension   string
+	CSRFToken   string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
 }
 
 // SessionStore manages in-memory admin sessions.
@@ -63,7 +69,23 @@ func NewSessionStore() *SessionStore {
 }
 
 // Create generates a new session for the given admin user and returns it.
-func (s *SessionStore) Create(userID int64, username string) (*Session, error) {
+func (s *SessionStore) Create(userID int64, username, role string) (*Session, error) {
+	return s.create(&Session{UserID: userID, Username: username, Role: role})
+}
+
+// CreateExtension generates a new self-service session for an extension
+// user and returns it.
+func (s *SessionStore) CreateExtension(extensionID int64, extension string) (*Session, error) {
+	return s.create(&Session{
+		Username:    extension,
+		Role:        RoleExtension,
+		ExtensionID: extensionID,
+		Extension:   extension,
+	})
+}
+
+// create fills in the ID, CSRF token and lifetime of sess and stores it.
+func (s *SessionStore) create(sess *Session) (*Session, error) {
 	sessionID, err := generateToken(32)
 	if err != nil {
 		return nil, err
@@ -74,14 +96,10 @@ func (s *SessionStore) Create(userID int64, username string) (*Session, error) {
 		return nil, err
 	}
 
-	sess := &Session{
-		ID:        sessionID,
-		UserID:    userID,
-		Username:  username,
-		CSRFToken: csrfToken,
-		ExpiresAt: time.Now().Add(sessionTTL),
-		CreatedAt: time.Now(),
-	}
+	sess.ID = sessionID
+	sess.CSRFToken = csrfToken
+	sess.ExpiresAt = time.Now().Add(sessionTTL)
+	sess.CreatedAt = time.Now()
 
 	s.mu.Lock()
 	s.sessions[sessionID] = sess
@@ -115,11 +133,11 @@ func (s *SessionStore) Delete(sessionID string) {
 	s.mu.Unlock()
 }
 
-// DeleteByUserID removes all sessions for a given user.
+// DeleteByUserID removes all sessions for a given admin user.
 func (s *SessionStore) DeleteByUserID(userID int64) {
 	s.mu.Lock()
 	for id, sess := range s.sessions {
-		if sess.UserID == userID {
+		if sess.ExtensionID == 0 && sess.UserID == userID {
 			delete(s.sessions, id)
 		}
 	}
@@ -145,7 +163,8 @@ func (s *SessionStore) CleanExpired() int {
 
 // RequireAuth returns middleware that validates the session cookie and CSRF
 // token on state-changing requests. On success it sets the AdminUser in the
-// request context. On failure it writes a 401 JSON error.
+// request context, or for an extension user's session the extension as
+// RequireAppAuth does. On failure it writes a 401 JSON error.
 func RequireAuth(store *SessionStore, secureCookie bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -170,11 +189,18 @@ func RequireAuth(store *SessionStore, secureCookie bool) func(http.Handler) http
 				}
 			}
 
-			// Store admin user in context for downstream handlers.
-			ctx := context.WithValue(r.Context(), adminUserKey, &AdminUser{
-				ID:       sess.UserID,
-				Username: sess.Username,
-			})
+			// Store admin user (or extension) in context for downstream handlers.
+			ctx := r.Context()
+			if sess.ExtensionID != 0 {
+				ctx = context.WithValue(ctx, appExtensionIDKey, sess.ExtensionID)
+				ctx = context.WithValue(ctx, appExtensionKey, sess.Extension)
+			} else {
+				ctx = context.WithValue(ctx, adminUserKey, &AdminUser{
+					ID:       sess.UserID,
+					Username: sess.Username,
+					Role:     sess.Role,
+				})
+			}
 			ctx = context.WithValue(ctx, sessionIDKey, sess.ID)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
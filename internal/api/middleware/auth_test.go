@@ -10,7 +10,7 @@ import (
 func TestSessionStoreCreateAndGet(t *testing.T) {
 	store := NewSessionStore()
 
-	sess, err := store.Create(1, "admin")
+	sess, err := store.Create(1, "admin", RoleOwner)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -40,7 +40,7 @@ func TestSessionStoreCreateAndGet(t *testing.T) {
 func TestSessionStoreGetExpired(t *testing.T) {
 	store := NewSessionStore()
 
-	sess, err := store.Create(1, "admin")
+	sess, err := store.Create(1, "admin", RoleOwner)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -67,7 +67,7 @@ func TestSessionStoreGetNotFound(t *testing.T) {
 func TestSessionStoreDelete(t *testing.T) {
 	store := NewSessionStore()
 
-	sess, err := store.Create(1, "admin")
+	sess, err := store.Create(1, "admin", RoleOwner)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -83,9 +83,9 @@ func TestSessionStoreDelete(t *testing.T) {
 func TestSessionStoreDeleteByUserID(t *testing.T) {
 	store := NewSessionStore()
 
-	s1, _ := store.Create(1, "admin")
-	s2, _ := store.Create(1, "admin")
-	s3, _ := store.Create(2, "other")
+	s1, _ := store.Create(1, "admin", RoleOwner)
+	s2, _ := store.Create(1, "admin", RoleOwner)
+	s3, _ := store.Create(2, "other", RoleOwner)
 
 	store.DeleteByUserID(1)
 
@@ -103,8 +103,8 @@ func TestSessionStoreDeleteByUserID(t *testing.T) {
 func TestSessionStoreCleanExpired(t *testing.T) {
 	store := NewSessionStore()
 
-	s1, _ := store.Create(1, "admin")
-	store.Create(2, "other")
+	s1, _ := store.Create(1, "admin", RoleOwner)
+	store.Create(2, "other", RoleOwner)
 
 	// Expire s1.
 	store.mu.Lock()
@@ -150,7 +150,7 @@ func TestRequireAuthInvalidSession(t *testing.T) {
 
 func TestRequireAuthValidGetRequest(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	var gotUser *AdminUser
 	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -176,7 +176,7 @@ func TestRequireAuthValidGetRequest(t *testing.T) {
 
 func TestRequireAuthPostWithoutCSRF(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -194,7 +194,7 @@ func TestRequireAuthPostWithoutCSRF(t *testing.T) {
 
 func TestRequireAuthPostWithWrongCSRF(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -213,7 +213,7 @@ func TestRequireAuthPostWithWrongCSRF(t *testing.T) {
 
 func TestRequireAuthPostWithValidCSRF(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -232,7 +232,7 @@ func TestRequireAuthPostWithValidCSRF(t *testing.T) {
 
 func TestRequireAuthPutRequiresCSRF(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -262,7 +262,7 @@ func TestRequireAuthPutRequiresCSRF(t *testing.T) {
 
 func TestRequireAuthDeleteRequiresCSRF(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -280,7 +280,7 @@ func TestRequireAuthDeleteRequiresCSRF(t *testing.T) {
 
 func TestSetSessionCookie(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	rr := httptest.NewRecorder()
 	SetSessionCookie(rr, sess, false)
@@ -350,7 +350,7 @@ func TestAdminUserFromContextNil(t *testing.T) {
 
 func TestSessionIDFromContext(t *testing.T) {
 	store := NewSessionStore()
-	sess, _ := store.Create(1, "admin")
+	sess, _ := store.Create(1, "admin", RoleOwner)
 
 	var gotSessionID string
 	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -367,3 +367,55 @@ func TestSessionIDFromContext(t *testing.T) {
 		t.Fatalf("expected session ID %s, got %s", sess.ID, gotSessionID)
 	}
 }
+
+func TestRequireAuthExtensionSession(t *testing.T) {
+	store := NewSessionStore()
+	sess, err := store.CreateExtension(42, "1001")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sess.Role != RoleExtension {
+		t.Fatalf("expected role %q, got %q", RoleExtension, sess.Role)
+	}
+
+	var gotUser *AdminUser
+	var gotExtID int64
+	var gotExt string
+	handler := RequireAuth(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = AdminUserFromContext(r.Context())
+		gotExtID = AppExtensionIDFromContext(r.Context())
+		gotExt = AppExtensionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sess.ID})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if gotUser != nil {
+		t.Fatalf("expected no admin user for extension session, got %+v", gotUser)
+	}
+	if gotExtID != 42 || gotExt != "1001" {
+		t.Fatalf("unexpected extension in context: %d %q", gotExtID, gotExt)
+	}
+}
+
+func TestSessionStoreDeleteByUserIDKeepsExtensionSessions(t *testing.T) {
+	store := NewSessionStore()
+
+	admin, _ := store.Create(0, "admin", RoleOwner)
+	ext, _ := store.CreateExtension(5, "1005")
+
+	store.DeleteByUserID(0)
+
+	if store.Get(admin.ID) != nil {
+		t.Fatal("admin session should be deleted")
+	}
+	if store.Get(ext.ID) == nil {
+		t.Fatal("extension session should remain")
+	}
+}
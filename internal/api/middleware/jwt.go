@@ -14,7 +14,10 @@ import (
 // appExtensionKey is the context key for the authenticated app extension.
 type appContextKey string
 
-const appExtensionIDKey appContextKey = "app_extension_id"
+const (
+	appExtensionIDKey appContextKey = "app_extension_id"
+	appExtensionKey   appContextKey = "app_extension"
+)
 
 // jwtTokenTTL is the lifetime of an app JWT token (7 days).
 const jwtTokenTTL = 7 * 24 * time.Hour
@@ -52,7 +55,8 @@ func GenerateAppToken(secret []byte, extensionID int64, extension string) (strin
 }
 
 // RequireAppAuth returns middleware that validates JWT bearer tokens for mobile
-// app endpoints. On success it stores the extension ID in the request context.
+// app endpoints. On success it stores the extension ID and number in the
+// request context.
 func RequireAppAuth(secret []byte) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -89,6 +93,7 @@ func RequireAppAuth(secret []byte) func(http.Handler) http.Handler {
 			}
 
 			ctx := context.WithValue(r.Context(), appExtensionIDKey, claims.ExtensionID)
+			ctx = context.WithValue(ctx, appExtensionKey, claims.Extension)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -101,6 +106,31 @@ func AppExtensionIDFromContext(ctx context.Context) int64 {
 	return id
 }
 
+// AppExtensionFromContext retrieves the authenticated extension number from
+// the request context. Returns "" if not set.
+func AppExtensionFromContext(ctx context.Context) string {
+	ext, _ := ctx.Value(appExtensionKey).(string)
+	return ext
+}
+
+// RequireAuthOrAppAuth returns middleware for endpoints shared by the admin
+// UI and app clients. A request with an Authorization header is validated
+// as an app JWT (see RequireAppAuth); any other request must carry an admin
+// session (see RequireAuth).
+func RequireAuthOrAppAuth(store *SessionStore, secureCookie bool, secret []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		session := RequireAuth(store, secureCookie)(next)
+		app := RequireAppAuth(secret)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				app.ServeHTTP(w, r)
+				return
+			}
+			session.ServeHTTP(w, r)
+		})
+	}
+}
+
 // jwtEnvelope matches the api package's envelope format for error responses.
 type jwtEnvelope struct {
 	Error string `json:"error,omitempty"`
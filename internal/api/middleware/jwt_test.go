@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAuthOrAppAuth(t *testing.T) {
+	secret := []byte("test-secret")
+	store := NewSessionStore()
+	sess, _ := store.Create(1, "admin", RoleOwner)
+	token, _, err := GenerateAppToken(secret, 7, "101")
+	if err != nil {
+		t.Fatalf("GenerateAppToken: %v", err)
+	}
+
+	var gotUser *AdminUser
+	var gotExt string
+	handler := RequireAuthOrAppAuth(store, false, secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = AdminUserFromContext(r.Context())
+		gotExt = AppExtensionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		cookie   string
+		auth     string
+		wantCode int
+		wantUser bool
+		wantExt  string
+	}{
+		{"session", sess.ID, "", http.StatusOK, true, ""},
+		{"app token", "", "Bearer " + token, http.StatusOK, false, "101"},
+		{"bad token with session", sess.ID, "Bearer bogus", http.StatusUnauthorized, false, ""},
+		{"neither", "", "", http.StatusUnauthorized, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUser, gotExt = nil, ""
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.cookie})
+			}
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d", tt.wantCode, rr.Code)
+			}
+			if (gotUser != nil) != tt.wantUser || gotExt != tt.wantExt {
+				t.Errorf("context user %+v, extension %q; want user %v, extension %q", gotUser, gotExt, tt.wantUser, tt.wantExt)
+			}
+		})
+	}
+}
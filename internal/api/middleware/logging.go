@@ -27,6 +27,12 @@ func (w *wrapResponseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap returns the underlying ResponseWriter so http.ResponseController
+// can reach Flush and Hijack, which the event stream needs.
+func (w *wrapResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // StructuredLogger returns middleware that logs each request using log/slog.
 // It captures request ID (set by chi's RequestID middleware), HTTP method,
 // path, response status, and duration.
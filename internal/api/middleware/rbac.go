@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Admin roles. Every admin user has exactly one role, which decides the
+// permissions of their sessions.
+const (
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleReadOnly = "read-only"
+	RoleBilling  = "billing"
+)
+
+// RoleExtension is the role of a self-service session of an extension
+// user. It has no admin permissions; its requests are scoped to the
+// extension's own voicemail and settings.
+const RoleExtension = "extension"
+
+// AdminRoles lists the roles that can be assigned to admin users.
+var AdminRoles = []string{RoleOwner, RoleAdmin, RoleOperator, RoleReadOnly, RoleBilling}
+
+// ValidAdminRole reports whether role can be assigned to an admin user.
+func ValidAdminRole(role string) bool {
+	return slices.Contains(AdminRoles, role)
+}
+
+// Permission is an action on a group of admin API routes.
+type Permission string
+
+// Admin API permissions.
+const (
+	PermConfigRead       Permission = "config:read"       // view PBX configuration
+	PermConfigWrite      Permission = "config:write"      // change PBX configuration
+	PermCallsRead        Permission = "calls:read"        // active calls, dashboard and event stream
+	PermCallsControl     Permission = "calls:control"     // hang up, transfer, supervise and originate calls
+	PermCDRsRead         Permission = "cdrs:read"         // call detail records
+	PermRecordingsRead   Permission = "recordings:read"   // list and play call recordings
+	PermRecordingsDelete Permission = "recordings:delete" // delete call recordings
+	PermBillingRead      Permission = "billing:read"      // trunk rate decks and call costs
+	PermBillingWrite     Permission = "billing:write"     // import and remove trunk rate decks
+	PermSystem           Permission = "system:manage"     // system settings and reload
+	PermUsers            Permission = "users:manage"      // admin user management
+)
+
+// rolePermissions maps each admin role to its permissions. The owner has
+// every permission; admin has all but user management.
+var rolePermissions = map[string][]Permission{
+	RoleOwner: {
+		PermConfigRead, PermConfigWrite, PermCallsRead, PermCallsControl,
+		PermCDRsRead, PermRecordingsRead, PermRecordingsDelete,
+		PermBillingRead, PermBillingWrite, PermSystem, PermUsers,
+	},
+	RoleAdmin: {
+		PermConfigRead, PermConfigWrite, PermCallsRead, PermCallsControl,
+		PermCDRsRead, PermRecordingsRead, PermRecordingsDelete,
+		PermBillingRead, PermBillingWrite, PermSystem,
+	},
+	RoleOperator: {
+		PermConfigRead, PermCallsRead, PermCallsControl, PermCDRsRead, PermRecordingsRead,
+	},
+	RoleReadOnly: {
+		PermConfigRead, PermCallsRead, PermCDRsRead,
+	},
+	RoleBilling: {
+		PermConfigRead, PermCDRsRead, PermBillingRead, PermBillingWrite,
+	},
+}
+
+// RolePermissions returns the permissions of a role. Unknown roles have
+// none.
+func RolePermissions(role string) []Permission {
+	return rolePermissions[role]
+}
+
+// HasPermission reports whether a role grants a permission.
+func HasPermission(role string, perm Permission) bool {
+	return slices.Contains(rolePermissions[role], perm)
+}
+
+// RequirePermission returns middleware that allows only admin sessions
+// whose role grants perm. It must run after RequireAuth. Other requests
+// get a 403 JSON error.
+func RequirePermission(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := AdminUserFromContext(r.Context())
+			if user == nil || !HasPermission(user.Role, perm) {
+				writeAuthError(w, http.StatusForbidden, "permission denied")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAccess returns middleware for a group of routes that are read
+// with GET and changed with any other method: GET and HEAD requests need
+// the read permission, all others the write permission.
+func RequireAccess(read, write Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		readH := RequirePermission(read)(next)
+		writeH := RequirePermission(write)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				readH.ServeHTTP(w, r)
+				return
+			}
+			writeH.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermissionOrExtension is RequirePermission for routes shared
+// with extension users: requests authenticated as an extension, by app
+// JWT or self-service session, are let through for the handler to scope
+// to that extension.
+func RequirePermissionOrExtension(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		admin := RequirePermission(perm)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if AdminUserFromContext(r.Context()) == nil && AppExtensionIDFromContext(r.Context()) != 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			admin.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireExtensionUser returns middleware that allows only self-service
+// sessions of extension users. It must run after RequireAuth.
+func RequireExtensionUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if AdminUserFromContext(r.Context()) != nil || AppExtensionIDFromContext(r.Context()) == 0 {
+			writeAuthError(w, http.StatusForbidden, "extension login required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleOwner, PermUsers, true},
+		{RoleOwner, PermSystem, true},
+		{RoleAdmin, PermUsers, false},
+		{RoleAdmin, PermConfigWrite, true},
+		{RoleOperator, PermCallsControl, true},
+		{RoleOperator, PermConfigWrite, false},
+		{RoleReadOnly, PermConfigRead, true},
+		{RoleReadOnly, PermCallsControl, false},
+		{RoleReadOnly, PermRecordingsRead, false},
+		{RoleBilling, PermBillingWrite, true},
+		{RoleBilling, PermCallsRead, false},
+		{RoleExtension, PermConfigRead, false},
+		{"unknown", PermConfigRead, false},
+	}
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.perm); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestValidAdminRole(t *testing.T) {
+	for _, role := range AdminRoles {
+		if !ValidAdminRole(role) {
+			t.Errorf("ValidAdminRole(%q) = false", role)
+		}
+	}
+	for _, role := range []string{"", RoleExtension, "root"} {
+		if ValidAdminRole(role) {
+			t.Errorf("ValidAdminRole(%q) = true", role)
+		}
+	}
+}
+
+// serveAs runs a request through h with the given admin user (or none)
+// and extension ID in the context and returns the status code.
+func serveAs(h http.Handler, method string, user *AdminUser, extID int64) int {
+	req := httptest.NewRequest(method, "/", nil)
+	ctx := req.Context()
+	if user != nil {
+		ctx = context.WithValue(ctx, adminUserKey, user)
+	}
+	if extID != 0 {
+		ctx = context.WithValue(ctx, appExtensionIDKey, extID)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req.WithContext(ctx))
+	return rr.Code
+}
+
+func TestRequireAccess(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := RequireAccess(PermConfigRead, PermConfigWrite)(ok)
+
+	readOnly := &AdminUser{ID: 1, Username: "viewer", Role: RoleReadOnly}
+	admin := &AdminUser{ID: 2, Username: "admin", Role: RoleAdmin}
+
+	if code := serveAs(h, http.MethodGet, readOnly, 0); code != http.StatusOK {
+		t.Errorf("read-only GET: got %d, want 200", code)
+	}
+	if code := serveAs(h, http.MethodPut, readOnly, 0); code != http.StatusForbidden {
+		t.Errorf("read-only PUT: got %d, want 403", code)
+	}
+	if code := serveAs(h, http.MethodDelete, admin, 0); code != http.StatusOK {
+		t.Errorf("admin DELETE: got %d, want 200", code)
+	}
+	if code := serveAs(h, http.MethodGet, nil, 7); code != http.StatusForbidden {
+		t.Errorf("extension GET: got %d, want 403", code)
+	}
+}
+
+func TestRequirePermissionOrExtension(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := RequirePermissionOrExtension(PermCallsRead)(ok)
+
+	if code := serveAs(h, http.MethodGet, nil, 7); code != http.StatusOK {
+		t.Errorf("extension: got %d, want 200", code)
+	}
+	if code := serveAs(h, http.MethodGet, &AdminUser{ID: 1, Role: RoleOperator}, 0); code != http.StatusOK {
+		t.Errorf("operator: got %d, want 200", code)
+	}
+	if code := serveAs(h, http.MethodGet, &AdminUser{ID: 1, Role: RoleBilling}, 0); code != http.StatusForbidden {
+		t.Errorf("billing: got %d, want 403", code)
+	}
+	if code := serveAs(h, http.MethodGet, nil, 0); code != http.StatusForbidden {
+		t.Errorf("anonymous: got %d, want 403", code)
+	}
+}
+
+func TestRequireExtensionUser(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := RequireExtensionUser(ok)
+
+	if code := serveAs(h, http.MethodGet, nil, 7); code != http.StatusOK {
+		t.Errorf("extension: got %d, want 200", code)
+	}
+	if code := serveAs(h, http.MethodGet, &AdminUser{ID: 1, Role: RoleOwner}, 0); code != http.StatusForbidden {
+		t.Errorf("owner: got %d, want 403", code)
+	}
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxMOHPrompts caps the number of prompts in a music on hold playlist.
+const maxMOHPrompts = 100
+
+// mohClassRequest is the JSON request body for creating/updating a music
+// on hold class.
+type mohClassRequest struct {
+	Name      string  `json:"name"`
+	Mode      string  `json:"mode"`
+	PromptIDs []int64 `json:"prompt_ids"`
+}
+
+// mohClassResponse is the JSON response for a single music on hold class.
+type mohClassResponse struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Mode      string  `json:"mode"`
+	PromptIDs []int64 `json:"prompt_ids"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+// toMOHClassResponse converts a models.MOHClass to the API response.
+func toMOHClassResponse(c *models.MOHClass) mohClassResponse {
+	resp := mohClassResponse{
+		ID:        c.ID,
+		Name:      c.Name,
+		Mode:      c.Mode,
+		PromptIDs: []int64{},
+		CreatedAt: c.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: c.UpdatedAt.Format(time.RFC3339),
+	}
+	if err := json.Unmarshal([]byte(c.PromptIDs), &resp.PromptIDs); err != nil {
+		slog.Warn("moh class: invalid prompt ids", "error", err, "moh_class_id", c.ID)
+	}
+	return resp
+}
+
+// handleListMOHClasses returns all music on hold classes.
+func (s *Server) handleListMOHClasses(w http.ResponseWriter, r *http.Request) {
+	classes, err := s.mohClasses.List(r.Context())
+	if err != nil {
+		slog.Error("list moh classes: failed to query", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]mohClassResponse, len(classes))
+	for i := range classes {
+		items[i] = toMOHClassResponse(&classes[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateMOHClass creates a new music on hold class.
+func (s *Server) handleCreateMOHClass(w http.ResponseWriter, r *http.Request) {
+	var req mohClassRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateMOHClassRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkMOHClassName(w, r, req.Name, 0) || !s.checkMOHPrompts(w, r, req.PromptIDs) {
+		return
+	}
+
+	class := &models.MOHClass{}
+	applyMOHClassRequest(class, req)
+
+	if err := s.mohClasses.Create(r.Context(), class); err != nil {
+		slog.Error("create moh class: failed to insert", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	created, err := s.mohClasses.GetByID(r.Context(), class.ID)
+	if err != nil || created == nil {
+		slog.Error("create moh class: failed to re-fetch", "error", err, "moh_class_id", class.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("moh class created", "moh_class_id", created.ID, "name", created.Name, "prompts", len(req.PromptIDs))
+
+	writeJSON(w, http.StatusCreated, toMOHClassResponse(created))
+}
+
+// handleGetMOHClass returns a single music on hold class by ID.
+func (s *Server) handleGetMOHClass(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMOHClassID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid moh class id")
+		return
+	}
+
+	class, err := s.mohClasses.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("get moh class: failed to query", "error", err, "moh_class_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if class == nil {
+		writeError(w, http.StatusNotFound, "moh class not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toMOHClassResponse(class))
+}
+
+// handleUpdateMOHClass updates an existing music on hold class. Calls
+// already listening keep the old playlist until their hold ends.
+func (s *Server) handleUpdateMOHClass(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMOHClassID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid moh class id")
+		return
+	}
+
+	existing, err := s.mohClasses.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("update moh class: failed to query", "error", err, "moh_class_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "moh class not found")
+		return
+	}
+
+	var req mohClassRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateMOHClassRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkMOHClassName(w, r, req.Name, id) || !s.checkMOHPrompts(w, r, req.PromptIDs) {
+		return
+	}
+
+	applyMOHClassRequest(existing, req)
+
+	if err := s.mohClasses.Update(r.Context(), existing); err != nil {
+		slog.Error("update moh class: failed to update", "error", err, "moh_class_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	updated, err := s.mohClasses.GetByID(r.Context(), id)
+	if err != nil || updated == nil {
+		slog.Error("update moh class: failed to re-fetch", "error", err, "moh_class_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("moh class updated", "moh_class_id", id, "name", updated.Name, "prompts", len(req.PromptIDs))
+
+	writeJSON(w, http.StatusOK, toMOHClassResponse(updated))
+}
+
+// handleDeleteMOHClass removes a music on hold class by ID. Inbound
+// numbers, queues and extensions using it go back to the default music.
+func (s *Server) handleDeleteMOHClass(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMOHClassID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid moh class id")
+		return
+	}
+
+	existing, err := s.mohClasses.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("delete moh class: failed to query", "error", err, "moh_class_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "moh class not found")
+		return
+	}
+
+	if err := s.mohClasses.Delete(r.Context(), id); err != nil {
+		slog.Error("delete moh class: failed to delete", "error", err, "moh_class_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("moh class deleted", "moh_class_id", id, "name", existing.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyMOHClassRequest copies the fields of a validated music on hold
+// class request onto class.
+func applyMOHClassRequest(class *models.MOHClass, req mohClassRequest) {
+	class.Name = req.Name
+	class.Mode = req.Mode
+	if class.Mode == "" {
+		class.Mode = "sequential"
+	}
+	ids, _ := json.Marshal(req.PromptIDs)
+	class.PromptIDs = string(ids)
+}
+
+// checkMOHClassName verifies that no other music on hold class has the
+// name, writing the error response and returning false if one does.
+// selfID is the class being updated, zero on create.
+func (s *Server) checkMOHClassName(w http.ResponseWriter, r *http.Request, name string, selfID int64) bool {
+	classes, err := s.mohClasses.List(r.Context())
+	if err != nil {
+		slog.Error("moh class: failed to list moh classes", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	for _, c := range classes {
+		if c.ID != selfID && c.Name == name {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("a moh class named %q already exists", name))
+			return false
+		}
+	}
+	return true
+}
+
+// checkMOHPrompts verifies that every prompt of a playlist exists, writing
+// the error response and returning false if one does not.
+func (s *Server) checkMOHPrompts(w http.ResponseWriter, r *http.Request, ids []int64) bool {
+	for _, id := range ids {
+		p, err := s.audioPrompts.GetByID(r.Context(), id)
+		if err != nil {
+			slog.Error("moh class: failed to look up audio prompt", "error", err, "prompt_id", id)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return false
+		}
+		if p == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("audio prompt %d not found", id))
+			return false
+		}
+	}
+	return true
+}
+
+// checkMOHClass verifies that a music on hold class assigned to an inbound
+// number, queue or extension exists, writing the error response and
+// returning false if not. A nil ID means the default music.
+func (s *Server) checkMOHClass(w http.ResponseWriter, r *http.Request, id *int64) bool {
+	if id == nil {
+		return true
+	}
+	class, err := s.mohClasses.GetByID(r.Context(), *id)
+	if err != nil {
+		slog.Error("failed to look up moh class", "error", err, "moh_class_id", *id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	if class == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("moh class %d not found", *id))
+		return false
+	}
+	return true
+}
+
+// parseMOHClassID extracts and parses the music on hold class ID from the
+// URL parameter.
+func parseMOHClassID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// validateMOHClassRequest checks the fields of a music on hold class
+// create/update.
+func validateMOHClassRequest(req mohClassRequest) string {
+	if msg := validateRequiredStringLen("name", req.Name, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("name", req.Name); msg != "" {
+		return msg
+	}
+
+	switch req.Mode {
+	case "", "sequential", "shuffle":
+	default:
+		return "mode must be \"sequential\" or \"shuffle\""
+	}
+
+	if len(req.PromptIDs) == 0 {
+		return "prompt_ids must list at least one audio prompt"
+	}
+	if len(req.PromptIDs) > maxMOHPrompts {
+		return fmt.Sprintf("a music on hold class can have at most %d prompts", maxMOHPrompts)
+	}
+	return ""
+}
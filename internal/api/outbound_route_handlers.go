@@ -0,0 +1,334 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/dialplan"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxRoutePatterns and maxRouteTrunks bound the lists of an outbound route.
+const (
+	maxRoutePatterns = 100
+	maxRouteTrunks   = 20
+)
+
+// outboundRouteRequest is the JSON request body for creating/updating an
+// outbound route.
+type outboundRouteRequest struct {
+	Name        string   `json:"name"`
+	Patterns    []string `json:"patterns"`
+	TrunkIDs    []int64  `json:"trunk_ids"`
+	Class       string   `json:"class"`
+	PrefixStrip *int     `json:"prefix_strip"`
+	PrefixAdd   string   `json:"prefix_add"`
+	Priority    *int     `json:"priority"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// outboundRouteResponse is the JSON response for a single outbound route.
+type outboundRouteResponse struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Patterns    []string `json:"patterns"`
+	TrunkIDs    []int64  `json:"trunk_ids"`
+	Class       string   `json:"class"`
+	PrefixStrip int      `json:"prefix_strip"`
+	PrefixAdd   string   `json:"prefix_add"`
+	Priority    int      `json:"priority"`
+	Enabled     bool     `json:"enabled"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// toOutboundRouteResponse converts a models.OutboundRoute to the API response.
+func toOutboundRouteResponse(o *models.OutboundRoute) outboundRouteResponse {
+	resp := outboundRouteResponse{
+		ID:          o.ID,
+		Name:        o.Name,
+		Class:       o.Class,
+		PrefixStrip: o.PrefixStrip,
+		PrefixAdd:   o.PrefixAdd,
+		Priority:    o.Priority,
+		Enabled:     o.Enabled,
+		CreatedAt:   o.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   o.UpdatedAt.Format(time.RFC3339),
+	}
+
+	if err := json.Unmarshal([]byte(o.Patterns), &resp.Patterns); err != nil || resp.Patterns == nil {
+		resp.Patterns = []string{}
+	}
+	if err := json.Unmarshal([]byte(o.TrunkIDs), &resp.TrunkIDs); err != nil || resp.TrunkIDs == nil {
+		resp.TrunkIDs = []int64{}
+	}
+
+	return resp
+}
+
+// handleListOutboundRoutes returns all outbound routes in the order they
+// are matched.
+func (s *Server) handleListOutboundRoutes(w http.ResponseWriter, r *http.Request) {
+	routes, err := s.outboundRoutes.List(r.Context())
+	if err != nil {
+		slog.Error("list outbound routes: failed to query", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]outboundRouteResponse, len(routes))
+	for i := range routes {
+		items[i] = toOutboundRouteResponse(&routes[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateOutboundRoute creates a new outbound route.
+func (s *Server) handleCreateOutboundRoute(w http.ResponseWriter, r *http.Request) {
+	var req outboundRouteRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateOutboundRouteRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkRouteTrunks(w, r, req.TrunkIDs) {
+		return
+	}
+
+	route := &models.OutboundRoute{
+		Name:     req.Name,
+		Class:    req.Class,
+		Priority: 10,
+		Enabled:  true,
+	}
+	applyOutboundRouteRequest(route, req)
+
+	if err := s.outboundRoutes.Create(r.Context(), route); err != nil {
+		slog.Error("create outbound route: failed to insert", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	created, err := s.outboundRoutes.GetByID(r.Context(), route.ID)
+	if err != nil || created == nil {
+		slog.Error("create outbound route: failed to re-fetch", "error", err, "route_id", route.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("outbound route created", "route_id", created.ID, "name", created.Name)
+
+	writeJSON(w, http.StatusCreated, toOutboundRouteResponse(created))
+}
+
+// handleGetOutboundRoute returns a single outbound route by ID.
+func (s *Server) handleGetOutboundRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOutboundRouteID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid outbound route id")
+		return
+	}
+
+	route, err := s.outboundRoutes.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("get outbound route: failed to query", "error", err, "route_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if route == nil {
+		writeError(w, http.StatusNotFound, "outbound route not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toOutboundRouteResponse(route))
+}
+
+// handleUpdateOutboundRoute updates an existing outbound route.
+func (s *Server) handleUpdateOutboundRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOutboundRouteID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid outbound route id")
+		return
+	}
+
+	existing, err := s.outboundRoutes.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("update outbound route: failed to query", "error", err, "route_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "outbound route not found")
+		return
+	}
+
+	var req outboundRouteRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateOutboundRouteRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkRouteTrunks(w, r, req.TrunkIDs) {
+		return
+	}
+
+	existing.Name = req.Name
+	existing.Class = req.Class
+	applyOutboundRouteRequest(existing, req)
+
+	if err := s.outboundRoutes.Update(r.Context(), existing); err != nil {
+		slog.Error("update outbound route: failed to update", "error", err, "route_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	updated, err := s.outboundRoutes.GetByID(r.Context(), id)
+	if err != nil || updated == nil {
+		slog.Error("update outbound route: failed to re-fetch", "error", err, "route_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("outbound route updated", "route_id", id, "name", updated.Name)
+
+	writeJSON(w, http.StatusOK, toOutboundRouteResponse(updated))
+}
+
+// handleDeleteOutboundRoute removes an outbound route by ID.
+func (s *Server) handleDeleteOutboundRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOutboundRouteID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid outbound route id")
+		return
+	}
+
+	existing, err := s.outboundRoutes.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("delete outbound route: failed to query", "error", err, "route_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "outbound route not found")
+		return
+	}
+
+	if err := s.outboundRoutes.Delete(r.Context(), id); err != nil {
+		slog.Error("delete outbound route: failed to delete", "error", err, "route_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("outbound route deleted", "route_id", id, "name", existing.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyOutboundRouteRequest copies the lists and optional fields of a
+// validated outbound route request onto route.
+func applyOutboundRouteRequest(route *models.OutboundRoute, req outboundRouteRequest) {
+	patterns, _ := json.Marshal(req.Patterns)
+	route.Patterns = string(patterns)
+	trunkIDs, _ := json.Marshal(req.TrunkIDs)
+	route.TrunkIDs = string(trunkIDs)
+	route.PrefixAdd = req.PrefixAdd
+
+	if req.PrefixStrip != nil {
+		route.PrefixStrip = *req.PrefixStrip
+	}
+	if req.Priority != nil {
+		route.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		route.Enabled = *req.Enabled
+	}
+}
+
+// checkRouteTrunks verifies that every trunk an outbound route lists
+// exists, writing the error response and returning false if one does not.
+func (s *Server) checkRouteTrunks(w http.ResponseWriter, r *http.Request, ids []int64) bool {
+	for _, id := range ids {
+		trunk, err := s.trunks.GetByID(r.Context(), id)
+		if err != nil {
+			slog.Error("outbound route: failed to look up trunk", "error", err, "trunk_id", id)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return false
+		}
+		if trunk == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("trunk %d not found", id))
+			return false
+		}
+	}
+	return true
+}
+
+// parseOutboundRouteID extracts and parses the outbound route ID from the
+// URL parameter.
+func parseOutboundRouteID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// validateOutboundRouteRequest checks the fields of an outbound route
+// create/update, including that every pattern compiles.
+func validateOutboundRouteRequest(req outboundRouteRequest) string {
+	if msg := validateRequiredStringLen("name", req.Name, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("name", req.Name); msg != "" {
+		return msg
+	}
+	if len(req.Patterns) == 0 {
+		return "patterns must contain at least one pattern"
+	}
+	if len(req.Patterns) > maxRoutePatterns {
+		return fmt.Sprintf("patterns must contain at most %d entries", maxRoutePatterns)
+	}
+	for _, p := range req.Patterns {
+		if msg := validateRequiredStringLen("pattern", p, maxNameLen); msg != "" {
+			return msg
+		}
+		if _, err := dialplan.Compile(p); err != nil {
+			return err.Error()
+		}
+	}
+	if len(req.TrunkIDs) == 0 {
+		return "trunk_ids must contain at least one trunk"
+	}
+	if len(req.TrunkIDs) > maxRouteTrunks {
+		return fmt.Sprintf("trunk_ids must contain at most %d entries", maxRouteTrunks)
+	}
+	seen := make(map[int64]bool, len(req.TrunkIDs))
+	for _, id := range req.TrunkIDs {
+		if seen[id] {
+			return fmt.Sprintf("trunk_ids lists trunk %d more than once", id)
+		}
+		seen[id] = true
+	}
+	if !dialplan.ValidRouteClass(req.Class) {
+		return "class must be \"emergency\", \"local\", \"national\", \"international\", or \"premium\""
+	}
+	if msg := validateIntRange("prefix_strip", req.PrefixStrip, 0, 20); msg != "" {
+		return msg
+	}
+	if msg := validateStringLen("prefix_add", req.PrefixAdd, maxShortStringLen); msg != "" {
+		return msg
+	}
+	if msg := validateIntRange("priority", req.Priority, 0, 1000); msg != "" {
+		return msg
+	}
+	return ""
+}
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxParkOrbits caps the number of orbits in one park lot.
+const maxParkOrbits = 100
+
+// parkLotRequest is the JSON request body for creating/updating a park
+// lot.
+type parkLotRequest struct {
+	Name            string `json:"name"`
+	ParkCode        string `json:"park_code"`
+	OrbitStart      int    `json:"orbit_start"`
+	OrbitEnd        int    `json:"orbit_end"`
+	Timeout         int    `json:"timeout"`
+	TimeoutAction   string `json:"timeout_action"`
+	TimeoutFlowID   *int64 `json:"timeout_flow_id"`
+	TimeoutFlowNode string `json:"timeout_flow_node"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+// parkLotResponse is the JSON response for a single park lot.
+type parkLotResponse struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	ParkCode        string `json:"park_code"`
+	OrbitStart      int    `json:"orbit_start"`
+	OrbitEnd        int    `json:"orbit_end"`
+	Timeout         int    `json:"timeout"`
+	TimeoutAction   string `json:"timeout_action"`
+	TimeoutFlowID   *int64 `json:"timeout_flow_id"`
+	TimeoutFlowNode string `json:"timeout_flow_node"`
+	Enabled         bool   `json:"enabled"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+// toParkLotResponse converts a models.ParkLot to the API response.
+func toParkLotResponse(lot *models.ParkLot) parkLotResponse {
+	return parkLotResponse{
+		ID:              lot.ID,
+		Name:            lot.Name,
+		ParkCode:        lot.ParkCode,
+		OrbitStart:      lot.OrbitStart,
+		OrbitEnd:        lot.OrbitEnd,
+		Timeout:         lot.Timeout,
+		TimeoutAction:   lot.TimeoutAction,
+		TimeoutFlowID:   lot.TimeoutFlowID,
+		TimeoutFlowNode: lot.TimeoutFlowNode,
+		Enabled:         lot.Enabled,
+		CreatedAt:       lot.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:       lot.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// handleListParkLots returns all park lots.
+func (s *Server) handleListParkLots(w http.ResponseWriter, r *http.Request) {
+	lots, err := s.parkLots.List(r.Context())
+	if err != nil {
+		slog.Error("list park lots: failed to query", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]parkLotResponse, len(lots))
+	for i := range lots {
+		items[i] = toParkLotResponse(&lots[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateParkLot creates a new park lot.
+func (s *Server) handleCreateParkLot(w http.ResponseWriter, r *http.Request) {
+	var req parkLotRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateParkLotRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkParkLotNumbers(w, r, req, 0) || !s.checkParkLotFlow(w, r, req) {
+		return
+	}
+
+	lot := &models.ParkLot{Enabled: true}
+	applyParkLotRequest(lot, req)
+
+	if err := s.parkLots.Create(r.Context(), lot); err != nil {
+		slog.Error("create park lot: failed to insert", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	created, err := s.parkLots.GetByID(r.Context(), lot.ID)
+	if err != nil || created == nil {
+		slog.Error("create park lot: failed to re-fetch", "error", err, "park_lot_id", lot.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("park lot created", "park_lot_id", created.ID, "name", created.Name, "park_code", created.ParkCode)
+
+	writeJSON(w, http.StatusCreated, toParkLotResponse(created))
+}
+
+// handleGetParkLot returns a single park lot by ID.
+func (s *Server) handleGetParkLot(w http.ResponseWriter, r *http.Request) {
+	id, err := parseParkLotID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid park lot id")
+		return
+	}
+
+	lot, err := s.parkLots.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("get park lot: failed to query", "error", err, "park_lot_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if lot == nil {
+		writeError(w, http.StatusNotFound, "park lot not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toParkLotResponse(lot))
+}
+
+// handleUpdateParkLot updates an existing park lot. Calls already parked
+// keep the settings they were parked with.
+func (s *Server) handleUpdateParkLot(w http.ResponseWriter, r *http.Request) {
+	id, err := parseParkLotID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid park lot id")
+		return
+	}
+
+	existing, err := s.parkLots.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("update park lot: failed to query", "error", err, "park_lot_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "park lot not found")
+		return
+	}
+
+	var req parkLotRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateParkLotRequest(req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkParkLotNumbers(w, r, req, id) || !s.checkParkLotFlow(w, r, req) {
+		return
+	}
+
+	applyParkLotRequest(existing, req)
+
+	if err := s.parkLots.Update(r.Context(), existing); err != nil {
+		slog.Error("update park lot: failed to update", "error", err, "park_lot_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	updated, err := s.parkLots.GetByID(r.Context(), id)
+	if err != nil || updated == nil {
+		slog.Error("update park lot: failed to re-fetch", "error", err, "park_lot_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("park lot updated", "park_lot_id", id, "name", updated.Name, "park_code", updated.ParkCode)
+
+	writeJSON(w, http.StatusOK, toParkLotResponse(updated))
+}
+
+// handleDeleteParkLot removes a park lot by ID. Calls parked in it stay
+// parked until they are retrieved or time out.
+func (s *Server) handleDeleteParkLot(w http.ResponseWriter, r *http.Request) {
+	id, err := parseParkLotID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid park lot id")
+		return
+	}
+
+	existing, err := s.parkLots.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("delete park lot: failed to query", "error", err, "park_lot_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "park lot not found")
+		return
+	}
+
+	if err := s.parkLots.Delete(r.Context(), id); err != nil {
+		slog.Error("delete park lot: failed to delete", "error", err, "park_lot_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("park lot deleted", "park_lot_id", id, "name", existing.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyParkLotRequest copies the fields of a validated park lot request
+// onto lot. Only the flow timeout action keeps a flow target.
+func applyParkLotRequest(lot *models.ParkLot, req parkLotRequest) {
+	lot.Name = req.Name
+	lot.ParkCode = req.ParkCode
+	lot.OrbitStart = req.OrbitStart
+	lot.OrbitEnd = req.OrbitEnd
+	lot.Timeout = req.Timeout
+	if lot.Timeout == 0 {
+		lot.Timeout = 120
+	}
+
+	lot.TimeoutAction = "return"
+	lot.TimeoutFlowID = nil
+	lot.TimeoutFlowNode = ""
+	if req.TimeoutAction == "flow" {
+		lot.TimeoutAction = "flow"
+		lot.TimeoutFlowID = req.TimeoutFlowID
+		lot.TimeoutFlowNode = req.TimeoutFlowNode
+	}
+
+	if req.Enabled != nil {
+		lot.Enabled = *req.Enabled
+	}
+}
+
+// checkParkLotNumbers verifies that a park lot's park code and orbits do
+// not collide with an extension or with another lot, writing the error
+// response and returning false if they do. selfID is the lot being
+// updated, zero on create.
+func (s *Server) checkParkLotNumbers(w http.ResponseWriter, r *http.Request, req parkLotRequest, selfID int64) bool {
+	ctx := r.Context()
+
+	inRange := func(number string, start, end int) bool {
+		n, err := strconv.Atoi(number)
+		return err == nil && strconv.Itoa(n) == number && n >= start && n <= end
+	}
+
+	exts, err := s.extensions.List(ctx)
+	if err != nil {
+		slog.Error("park lot: failed to list extensions", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	for _, ext := range exts {
+		if ext.Extension == req.ParkCode || inRange(ext.Extension, req.OrbitStart, req.OrbitEnd) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("extension %s is inside the park lot's numbers", ext.Extension))
+			return false
+		}
+	}
+
+	lots, err := s.parkLots.List(ctx)
+	if err != nil {
+		slog.Error("park lot: failed to list park lots", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	for _, lot := range lots {
+		if lot.ID == selfID {
+			continue
+		}
+		if lot.ParkCode == req.ParkCode || inRange(lot.ParkCode, req.OrbitStart, req.OrbitEnd) ||
+			inRange(req.ParkCode, lot.OrbitStart, lot.OrbitEnd) ||
+			(req.OrbitStart <= lot.OrbitEnd && lot.OrbitStart <= req.OrbitEnd) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("park lot %q already uses some of these numbers", lot.Name))
+			return false
+		}
+	}
+	return true
+}
+
+// checkParkLotFlow verifies that timed out calls can enter the published
+// revision of a park lot's timeout flow at its timeout node, which is what
+// the lot uses when a call times out. It writes the error response and
+// returns false if not.
+func (s *Server) checkParkLotFlow(w http.ResponseWriter, r *http.Request, req parkLotRequest) bool {
+	if req.TimeoutAction != "flow" {
+		return true
+	}
+	return s.checkFlowEntry(w, r, *req.TimeoutFlowID, req.TimeoutFlowNode)
+}
+
+// checkFlowParkLots verifies that every park lot sending timed out calls
+// into a flow can still do so once graph is the flow's published revision,
+// so publishing, rolling back or deleting (graph nil) a flow cannot leave a
+// lot returning calls to the parker instead. It writes the error response
+// and returns false if a lot would be broken.
+func (s *Server) checkFlowParkLots(w http.ResponseWriter, r *http.Request, flowID int64, graph *flow.FlowGraph) bool {
+	lots, err := s.parkLots.List(r.Context())
+	if err != nil {
+		slog.Error("failed to list park lots", "error", err, "flow_id", flowID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	for _, lot := range lots {
+		if lot.TimeoutAction != "flow" || lot.TimeoutFlowID == nil || *lot.TimeoutFlowID != flowID {
+			continue
+		}
+		if graph == nil {
+			writeError(w, http.StatusConflict, fmt.Sprintf("park lot %q sends timed out calls to this flow", lot.Name))
+			return false
+		}
+		if err := flow.CheckEntryNode(graph, lot.TimeoutFlowNode); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("park lot %q sends timed out calls here: %v", lot.Name, err))
+			return false
+		}
+	}
+	return true
+}
+
+// parseParkLotID extracts and parses the park lot ID from the URL
+// parameter.
+func parseParkLotID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// validateParkLotRequest checks the fields of a park lot create/update.
+// The park code and orbits are dialled numbers; the park code must not be
+// one of the lot's own orbits.
+func validateParkLotRequest(req parkLotRequest) string {
+	if msg := validateRequiredStringLen("name", req.Name, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("name", req.Name); msg != "" {
+		return msg
+	}
+	if msg := validateExtensionNumber("park_code", req.ParkCode); msg != "" {
+		return msg
+	}
+
+	if req.OrbitStart <= 0 || req.OrbitEnd <= 0 {
+		return "orbit_start and orbit_end must be positive numbers"
+	}
+	if req.OrbitEnd < req.OrbitStart {
+		return "orbit_end must not be less than orbit_start"
+	}
+	if req.OrbitEnd-req.OrbitStart >= maxParkOrbits {
+		return fmt.Sprintf("a park lot can have at most %d orbits", maxParkOrbits)
+	}
+	if code, _ := strconv.Atoi(req.ParkCode); strconv.Itoa(code) == req.ParkCode && code >= req.OrbitStart && code <= req.OrbitEnd {
+		return "park_code must not be one of the lot's orbits"
+	}
+
+	if req.Timeout != 0 && (req.Timeout < 10 || req.Timeout > 3600) {
+		return "timeout must be between 10 and 3600 seconds"
+	}
+
+	switch req.TimeoutAction {
+	case "", "return":
+	case "flow":
+		if req.TimeoutFlowID == nil {
+			return "timeout_flow_id is required for the flow timeout action"
+		}
+		if msg := validateRequiredStringLen("timeout_flow_node", req.TimeoutFlowNode, maxNameLen); msg != "" {
+			return msg
+		}
+	default:
+		return "timeout_action must be \"return\" or \"flow\""
+	}
+	return ""
+}
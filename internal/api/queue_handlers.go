@@ -0,0 +1,318 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// queueRequest is the JSON request body for creating/updating a queue.
+type queueRequest struct {
+	Name             string          `json:"name"`
+	Strategy         string          `json:"strategy"`
+	RingTimeout      *int            `json:"ring_timeout"`
+	Members          json.RawMessage `json:"members"`
+	MaxWaitTime      *int            `json:"max_wait_time"`
+	MaxCallers       *int            `json:"max_callers"`
+	AnnouncePosition *bool           `json:"announce_position"`
+	AnnounceInterval *int            `json:"announce_interval"`
+	HoldMusicFile    string          `json:"hold_music_file"`
+	MOHClassID       *int64          `json:"moh_class_id"`
+}
+
+// queueResponse is the JSON response for a single queue.
+type queueResponse struct {
+	ID               int64           `json:"id"`
+	Name             string          `json:"name"`
+	Strategy         string          `json:"strategy"`
+	RingTimeout      int             `json:"ring_timeout"`
+	Members          json.RawMessage `json:"members"`
+	MaxWaitTime      int             `json:"max_wait_time"`
+	MaxCallers       int             `json:"max_callers"`
+	AnnouncePosition bool            `json:"announce_position"`
+	AnnounceInterval int             `json:"announce_interval"`
+	HoldMusicFile    string          `json:"hold_music_file"`
+	MOHClassID       *int64          `json:"moh_class_id"`
+	CreatedAt        string          `json:"created_at"`
+	UpdatedAt        string          `json:"updated_at"`
+}
+
+// toQueueResponse converts a models.Queue to the API response.
+func toQueueResponse(q *models.Queue) queueResponse {
+	resp := queueResponse{
+		ID:               q.ID,
+		Name:             q.Name,
+		Strategy:         q.Strategy,
+		RingTimeout:      q.RingTimeout,
+		MaxWaitTime:      q.MaxWaitTime,
+		MaxCallers:       q.MaxCallers,
+		AnnouncePosition: q.AnnouncePosition,
+		AnnounceInterval: q.AnnounceInterval,
+		HoldMusicFile:    q.HoldMusicFile,
+		MOHClassID:       q.MOHClassID,
+		CreatedAt:        q.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:        q.UpdatedAt.Format(time.RFC3339),
+	}
+
+	if q.Members != "" {
+		resp.Members = json.RawMessage(q.Members)
+	} else {
+		resp.Members = json.RawMessage("[]")
+	}
+
+	return resp
+}
+
+// handleListQueues returns all queues.
+func (s *Server) handleListQueues(w http.ResponseWriter, r *http.Request) {
+	queues, err := s.queues.List(r.Context())
+	if err != nil {
+		slog.Error("list queues: failed to query", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]queueResponse, len(queues))
+	for i := range queues {
+		items[i] = toQueueResponse(&queues[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateQueue creates a new queue.
+func (s *Server) handleCreateQueue(w http.ResponseWriter, r *http.Request) {
+	var req queueRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateQueueRequest(req, true); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkMOHClass(w, r, req.MOHClassID) {
+		return
+	}
+
+	q := &models.Queue{
+		Name:             req.Name,
+		Strategy:         "ring_all",
+		RingTimeout:      20,
+		Members:          string(req.Members),
+		MaxWaitTime:      300,
+		MaxCallers:       0,
+		AnnouncePosition: true,
+		AnnounceInterval: 30,
+		HoldMusicFile:    req.HoldMusicFile,
+		MOHClassID:       req.MOHClassID,
+	}
+	applyQueueRequest(q, req)
+
+	if err := s.queues.Create(r.Context(), q); err != nil {
+		slog.Error("create queue: failed to insert", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	created, err := s.queues.GetByID(r.Context(), q.ID)
+	if err != nil || created == nil {
+		slog.Error("create queue: failed to re-fetch", "error", err, "queue_id", q.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("queue created", "queue_id", created.ID, "name", created.Name)
+
+	writeJSON(w, http.StatusCreated, toQueueResponse(created))
+}
+
+// handleGetQueue returns a single queue by ID.
+func (s *Server) handleGetQueue(w http.ResponseWriter, r *http.Request) {
+	id, err := parseQueueID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid queue id")
+		return
+	}
+
+	q, err := s.queues.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("get queue: failed to query", "error", err, "queue_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if q == nil {
+		writeError(w, http.StatusNotFound, "queue not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toQueueResponse(q))
+}
+
+// handleUpdateQueue updates an existing queue.
+func (s *Server) handleUpdateQueue(w http.ResponseWriter, r *http.Request) {
+	id, err := parseQueueID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid queue id")
+		return
+	}
+
+	existing, err := s.queues.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("update queue: failed to query", "error", err, "queue_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "queue not found")
+		return
+	}
+
+	var req queueRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateQueueRequest(req, false); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if !s.checkMOHClass(w, r, req.MOHClassID) {
+		return
+	}
+
+	existing.Name = req.Name
+	existing.HoldMusicFile = req.HoldMusicFile
+	existing.MOHClassID = req.MOHClassID
+	if req.Members != nil {
+		existing.Members = string(req.Members)
+	}
+	applyQueueRequest(existing, req)
+
+	if err := s.queues.Update(r.Context(), existing); err != nil {
+		slog.Error("update queue: failed to update", "error", err, "queue_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	updated, err := s.queues.GetByID(r.Context(), id)
+	if err != nil || updated == nil {
+		slog.Error("update queue: failed to re-fetch", "error", err, "queue_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("queue updated", "queue_id", id, "name", updated.Name)
+
+	writeJSON(w, http.StatusOK, toQueueResponse(updated))
+}
+
+// handleDeleteQueue removes a queue by ID.
+func (s *Server) handleDeleteQueue(w http.ResponseWriter, r *http.Request) {
+	id, err := parseQueueID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid queue id")
+		return
+	}
+
+	existing, err := s.queues.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("delete queue: failed to query", "error", err, "queue_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "queue not found")
+		return
+	}
+
+	if err := s.queues.Delete(r.Context(), id); err != nil {
+		slog.Error("delete queue: failed to delete", "error", err, "queue_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("queue deleted", "queue_id", id, "name", existing.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyQueueRequest copies the optional fields of a queue request onto q.
+func applyQueueRequest(q *models.Queue, req queueRequest) {
+	if req.Strategy != "" {
+		q.Strategy = req.Strategy
+	}
+	if req.RingTimeout != nil {
+		q.RingTimeout = *req.RingTimeout
+	}
+	if req.MaxWaitTime != nil {
+		q.MaxWaitTime = *req.MaxWaitTime
+	}
+	if req.MaxCallers != nil {
+		q.MaxCallers = *req.MaxCallers
+	}
+	if req.AnnouncePosition != nil {
+		q.AnnouncePosition = *req.AnnouncePosition
+	}
+	if req.AnnounceInterval != nil {
+		q.AnnounceInterval = *req.AnnounceInterval
+	}
+}
+
+// parseQueueID extracts and parses the queue ID from the URL parameter.
+func parseQueueID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// validateQueueRequest checks required fields for a queue create/update.
+func validateQueueRequest(req queueRequest, isCreate bool) string {
+	if msg := validateRequiredStringLen("name", req.Name, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("name", req.Name); msg != "" {
+		return msg
+	}
+	if req.Strategy != "" {
+		switch req.Strategy {
+		case "ring_all", "round_robin", "longest_idle":
+			// valid
+		default:
+			return "strategy must be \"ring_all\", \"round_robin\", or \"longest_idle\""
+		}
+	}
+	if msg := validateIntRange("ring_timeout", req.RingTimeout, 1, 600); msg != "" {
+		return msg
+	}
+	if msg := validateIntRange("max_wait_time", req.MaxWaitTime, 0, 86400); msg != "" {
+		return msg
+	}
+	if msg := validateIntRange("max_callers", req.MaxCallers, 0, 1000); msg != "" {
+		return msg
+	}
+	if msg := validateIntRange("announce_interval", req.AnnounceInterval, 10, 3600); msg != "" {
+		return msg
+	}
+	if msg := validateStringLen("hold_music_file", req.HoldMusicFile, maxLongStringLen); msg != "" {
+		return msg
+	}
+	if isCreate && req.Members == nil {
+		return "members is required"
+	}
+	if req.Members != nil {
+		var arr []json.RawMessage
+		if err := json.Unmarshal(req.Members, &arr); err != nil {
+			return "members must be a valid JSON array"
+		}
+		if len(arr) > 100 {
+			return "members must contain at most 100 entries"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/flowpbx/flowpbx/internal/api/middleware"
+)
+
+// extensionLoginRequest is the JSON request body for
+// POST /api/v1/auth/extension-login.
+type extensionLoginRequest struct {
+	Extension string `json:"extension"`
+	Password  string `json:"password"`
+}
+
+// handleExtensionLogin handles POST /api/v1/auth/extension-login — a
+// self-service web login for extension users with their SIP credentials.
+// The session is limited to the /self routes: the extension's own
+// voicemail and settings. The handlers behind those routes are shared with
+// the mobile app, which authenticates the same credentials for a JWT.
+func (s *Server) handleExtensionLogin(w http.ResponseWriter, r *http.Request) {
+	var req extensionLoginRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if req.Extension == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "extension and password are required")
+		return
+	}
+	if msg := validateStringLen("extension", req.Extension, maxShortStringLen); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := validateStringLen("password", req.Password, maxPasswordLen); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	ext, err := s.authenticateExtension(r.Context(), req.Extension, req.Password)
+	if err != nil {
+		slog.Error("extension login: failed to authenticate extension", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if ext == nil {
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	sess, err := s.sessions.CreateExtension(ext.ID, ext.Extension)
+	if err != nil {
+		slog.Error("extension login: failed to create session", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	middleware.SetSessionCookie(w, sess, s.cfg.TLSEnabled())
+
+	slog.Info("extension self-service login", "extension_id", ext.ID, "extension", ext.Extension)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"role":         middleware.RoleExtension,
+		"extension_id": ext.ID,
+		"extension":    ext.Extension,
+	})
+}
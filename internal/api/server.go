@@ -10,10 +10,13 @@ import (
 	"time"
 
 	"github.com/flowpbx/flowpbx/internal/api/middleware"
+	"github.com/flowpbx/flowpbx/internal/api/ws"
 	"github.com/flowpbx/flowpbx/internal/config"
 	"github.com/flowpbx/flowpbx/internal/database"
 	"github.com/flowpbx/flowpbx/internal/database/models"
 	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/flow/nodes"
+	"github.com/flowpbx/flowpbx/internal/schedule"
 	"github.com/flowpbx/flowpbx/internal/web"
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
@@ -68,6 +71,12 @@ type ActiveCallEntry struct {
 	StartTime    time.Time  `json:"start_time"`
 	AnswerTime   *time.Time `json:"answer_time,omitempty"`
 	DurationSec  int        `json:"duration_sec"`
+
+	// ParkOrbit and ParkedBy are set for a call waiting in a park orbit
+	// (state "parked"): the orbit to dial to retrieve it and the
+	// extension that parked it.
+	ParkOrbit string `json:"park_orbit,omitempty"`
+	ParkedBy  string `json:"parked_by,omitempty"`
 }
 
 // ActiveCallsProvider exposes active call state. Implemented by
@@ -77,6 +86,17 @@ type ActiveCallsProvider interface {
 	GetActiveCallCount() int
 }
 
+// CallController acts on active calls and places calls for extensions.
+// Implemented by an adapter that wraps the SIP server. Errors wrap ErrCallNotFound for a call that is not
+// active and ErrCallRejected for an action the call does not allow.
+type CallController interface {
+	HangupCall(callID string) error
+	TransferCall(ctx context.Context, callID, destination string, replaceCaller bool) error
+	SuperviseCall(ctx context.Context, callID, extension, mode string) error
+	OriginateCall(ctx context.Context, extension, destination string) (string, error)
+	OriginateFlowCall(ctx context.Context, extension string, flowID int64, entryNode string) (string, error)
+}
+
 // ConfigReloader performs a hot-reload of system configuration without
 // restarting the process. Implemented in cmd/flowpbx to coordinate the
 // SIP trunk registrar, flow engine, and other subsystems.
@@ -120,24 +140,35 @@ type Server struct {
 	systemConfig      database.SystemConfigRepository
 	extensions        database.ExtensionRepository
 	trunks            database.TrunkRepository
+	trunkRates        database.TrunkRateRepository
+	outboundRoutes    database.OutboundRouteRepository
 	inboundNumbers    database.InboundNumberRepository
+	callerFilters     database.CallerFilterRepository
+	parkLots          database.ParkLotRepository
+	mohClasses        database.MOHClassRepository
 	registrations     database.RegistrationRepository
 	cdrs              database.CDRRepository
 	callFlows         database.CallFlowRepository
 	flowValidator     *flow.Validator
+	flowSimulator     *flow.Engine
 	trunkStatus       TrunkStatusProvider
 	trunkTester       TrunkTester
 	trunkLifecycle    TrunkLifecycleManager
 	activeCalls       ActiveCallsProvider
+	callControl       CallController
 	conferenceProv    ConferenceProvider
 	configReloader    ConfigReloader
 	sipLogVerbosity   SIPLogVerbositySetter
+	events            *ws.Hub
 	audioPrompts      database.AudioPromptRepository
 	voicemailBoxes    database.VoicemailBoxRepository
 	voicemailMessages database.VoicemailMessageRepository
 	ringGroups        database.RingGroupRepository
+	queues            database.QueueRepository
 	ivrMenus          database.IVRMenuRepository
 	timeSwitches      database.TimeSwitchRepository
+	timeSwitchCals    database.TimeSwitchCalendarRepository
+	calendars         *schedule.Calendars
 	conferenceBridges database.ConferenceBridgeRepository
 	pushTokens        database.PushTokenRepository
 	encryptor         *database.Encryptor
@@ -145,7 +176,7 @@ type Server struct {
 }
 
 // NewServer creates the HTTP handler with all routes mounted.
-func NewServer(db *database.DB, cfg *config.Config, sessions *middleware.SessionStore, sysConfig database.SystemConfigRepository, trunkStatus TrunkStatusProvider, trunkTester TrunkTester, trunkLifecycle TrunkLifecycleManager, activeCalls ActiveCallsProvider, conferenceProv ConferenceProvider, enc *database.Encryptor, reloader ConfigReloader, sipLogVerbosity SIPLogVerbositySetter) *Server {
+func NewServer(db *database.DB, cfg *config.Config, sessions *middleware.SessionStore, sysConfig database.SystemConfigRepository, trunkStatus TrunkStatusProvider, trunkTester TrunkTester, trunkLifecycle TrunkLifecycleManager, activeCalls ActiveCallsProvider, callControl CallController, conferenceProv ConferenceProvider, enc *database.Encryptor, reloader ConfigReloader, sipLogVerbosity SIPLogVerbositySetter, events *ws.Hub, calendars *schedule.Calendars) *Server {
 	s := &Server{
 		router:            chi.NewRouter(),
 		db:                db,
@@ -156,7 +187,12 @@ func NewServer(db *database.DB, cfg *config.Config, sessions *middleware.Session
 		systemConfig:      sysConfig,
 		extensions:        database.NewExtensionRepository(db),
 		trunks:            database.NewTrunkRepository(db),
+		trunkRates:        database.NewTrunkRateRepository(db),
+		outboundRoutes:    database.NewOutboundRouteRepository(db),
 		inboundNumbers:    database.NewInboundNumberRepository(db),
+		callerFilters:     database.NewCallerFilterRepository(db),
+		parkLots:          database.NewParkLotRepository(db),
+		mohClasses:        database.NewMOHClassRepository(db),
 		registrations:     database.NewRegistrationRepository(db),
 		cdrs:              database.NewCDRRepository(db),
 		callFlows:         database.NewCallFlowRepository(db),
@@ -164,21 +200,36 @@ func NewServer(db *database.DB, cfg *config.Config, sessions *middleware.Session
 		voicemailBoxes:    database.NewVoicemailBoxRepository(db),
 		voicemailMessages: database.NewVoicemailMessageRepository(db),
 		ringGroups:        database.NewRingGroupRepository(db),
+		queues:            database.NewQueueRepository(db),
 		ivrMenus:          database.NewIVRMenuRepository(db),
 		timeSwitches:      database.NewTimeSwitchRepository(db),
+		timeSwitchCals:    database.NewTimeSwitchCalendarRepository(db),
+		calendars:         calendars,
 		conferenceBridges: database.NewConferenceBridgeRepository(db),
 		pushTokens:        database.NewPushTokenRepository(db),
-		flowValidator:     flow.NewValidator(nil),
 		trunkStatus:       trunkStatus,
 		trunkTester:       trunkTester,
 		trunkLifecycle:    trunkLifecycle,
 		activeCalls:       activeCalls,
+		callControl:       callControl,
 		conferenceProv:    conferenceProv,
 		configReloader:    reloader,
 		sipLogVerbosity:   sipLogVerbosity,
+		events:            events,
 		encryptor:         enc,
 	}
 
+	s.flowValidator = flow.NewValidator(nil, s.callFlows)
+
+	// Flow simulator: the node handlers of live calls against scripted SIP
+	// actions, for dry runs from the flow editor.
+	s.flowSimulator = flow.NewSimulator(s.callFlows, flow.NewEntityResolver(s.extensions, s.ringGroups, s.queues, s.voicemailBoxes, s.ivrMenus, s.timeSwitches, s.conferenceBridges, s.inboundNumbers), slog.Default())
+	var simCalendars nodes.TimeSwitchCalendars
+	if calendars != nil {
+		simCalendars = calendars
+	}
+	nodes.RegisterAll(s.flowSimulator, flow.SimulatedSIPActions{}, s.extensions, s.voicemailBoxes, s.voicemailMessages, sysConfig, enc, nil, nil, simCalendars, cfg.DataDir, slog.Default())
+
 	// Initialize JWT secret for mobile app auth.
 	jwtKey, err := cfg.JWTSecretBytes()
 	if err != nil {
@@ -221,161 +272,315 @@ func (s *Server) routes() {
 		r.Get("/health", s.handleHealth)
 		r.Post("/setup", s.handleSetup)
 
-		// Auth routes (login is unauthenticated, logout/me require auth).
+		// Auth routes (login is unauthenticated, the rest require a session).
 		// Login has additional stricter rate limiting.
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.RateLimit(s.authLimiter))
 			r.Post("/auth/login", s.handleLogin)
+			r.Post("/auth/extension-login", s.handleExtensionLogin)
 		})
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.RequireAuth(s.sessions, s.cfg.TLSEnabled()))
 			r.Post("/auth/logout", s.handleLogout)
 			r.Get("/auth/me", s.handleMe)
-		})
-
-		// Protected admin routes — auth middleware will be added in a
-		// subsequent sprint task when session middleware is implemented.
-		r.Route("/extensions", func(r chi.Router) {
-			r.Get("/", s.handleListExtensions)
-			r.Post("/", s.handleCreateExtension)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetExtension)
-				r.Put("/", s.handleUpdateExtension)
-				r.Delete("/", s.handleDeleteExtension)
-				r.Get("/registrations", s.handleListExtensionRegistrations)
+			r.Post("/auth/totp/enroll", s.handleEnrollTOTP)
+			r.Post("/auth/totp/verify", s.handleVerifyTOTP)
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RateLimit(s.authLimiter))
+				r.Post("/auth/totp/disable", s.handleDisableTOTP)
+				r.Post("/auth/totp/recovery-codes", s.handleRegenerateRecoveryCodes)
 			})
 		})
 
-		r.Route("/trunks", func(r chi.Router) {
-			r.Get("/", s.handleListTrunks)
-			r.Post("/", s.handleCreateTrunk)
-			r.Get("/status", s.handleListTrunkStatus)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetTrunk)
-				r.Put("/", s.handleUpdateTrunk)
-				r.Delete("/", s.handleDeleteTrunk)
-				r.Post("/test", s.handleTestTrunk)
-			})
-		})
+		// Admin routes require an admin session whose role grants the
+		// permission of the route: configuration is read with config:read
+		// and changed with config:write, and so on.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireAuth(s.sessions, s.cfg.TLSEnabled()))
 
-		r.Route("/numbers", func(r chi.Router) {
-			r.Get("/", s.handleListInboundNumbers)
-			r.Post("/", s.handleCreateInboundNumber)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetInboundNumber)
-				r.Put("/", s.handleUpdateInboundNumber)
-				r.Delete("/", s.handleDeleteInboundNumber)
-			})
-		})
+			configAccess := middleware.RequireAccess(middleware.PermConfigRead, middleware.PermConfigWrite)
+			billingAccess := middleware.RequireAccess(middleware.PermBillingRead, middleware.PermBillingWrite)
+			callsAccess := middleware.RequireAccess(middleware.PermCallsRead, middleware.PermCallsControl)
 
-		r.Route("/voicemail-boxes", func(r chi.Router) {
-			r.Get("/", s.handleListVoicemailBoxes)
-			r.Post("/", s.handleCreateVoicemailBox)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetVoicemailBox)
-				r.Put("/", s.handleUpdateVoicemailBox)
-				r.Delete("/", s.handleDeleteVoicemailBox)
-				r.Get("/messages", s.handleListVoicemailMessages)
-				r.Post("/greeting", s.handleUploadGreeting)
-				r.Route("/messages/{msgID}", func(r chi.Router) {
-					r.Delete("/", s.handleDeleteVoicemailMessage)
-					r.Put("/read", s.handleMarkVoicemailMessageRead)
-					r.Get("/audio", s.handleGetVoicemailMessageAudio)
+			r.Group(func(r chi.Router) {
+				r.Use(configAccess)
+
+				r.Route("/extensions", func(r chi.Router) {
+					r.Get("/", s.handleListExtensions)
+					r.Post("/", s.handleCreateExtension)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetExtension)
+						r.Put("/", s.handleUpdateExtension)
+						r.Delete("/", s.handleDeleteExtension)
+						r.Get("/registrations", s.handleListExtensionRegistrations)
+					})
 				})
-			})
-		})
 
-		r.Route("/ring-groups", func(r chi.Router) {
-			r.Get("/", s.handleListRingGroups)
-			r.Post("/", s.handleCreateRingGroup)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetRingGroup)
-				r.Put("/", s.handleUpdateRingGroup)
-				r.Delete("/", s.handleDeleteRingGroup)
-			})
-		})
+				r.Route("/outbound-routes", func(r chi.Router) {
+					r.Get("/", s.handleListOutboundRoutes)
+					r.Post("/", s.handleCreateOutboundRoute)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetOutboundRoute)
+						r.Put("/", s.handleUpdateOutboundRoute)
+						r.Delete("/", s.handleDeleteOutboundRoute)
+					})
+				})
 
-		r.Route("/ivr-menus", func(r chi.Router) {
-			r.Get("/", s.handleListIVRMenus)
-			r.Post("/", s.handleCreateIVRMenu)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetIVRMenu)
-				r.Put("/", s.handleUpdateIVRMenu)
-				r.Delete("/", s.handleDeleteIVRMenu)
+				r.Route("/numbers", func(r chi.Router) {
+					r.Get("/", s.handleListInboundNumbers)
+					r.Post("/", s.handleCreateInboundNumber)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetInboundNumber)
+						r.Put("/", s.handleUpdateInboundNumber)
+						r.Delete("/", s.handleDeleteInboundNumber)
+					})
+				})
+
+				r.Route("/caller-filters", func(r chi.Router) {
+					r.Get("/", s.handleListCallerFilters)
+					r.Post("/", s.handleCreateCallerFilter)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetCallerFilter)
+						r.Put("/", s.handleUpdateCallerFilter)
+						r.Delete("/", s.handleDeleteCallerFilter)
+					})
+				})
+
+				r.Route("/park-lots", func(r chi.Router) {
+					r.Get("/", s.handleListParkLots)
+					r.Post("/", s.handleCreateParkLot)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetParkLot)
+						r.Put("/", s.handleUpdateParkLot)
+						r.Delete("/", s.handleDeleteParkLot)
+					})
+				})
+
+				r.Route("/voicemail-boxes", func(r chi.Router) {
+					r.Get("/", s.handleListVoicemailBoxes)
+					r.Post("/", s.handleCreateVoicemailBox)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetVoicemailBox)
+						r.Put("/", s.handleUpdateVoicemailBox)
+						r.Delete("/", s.handleDeleteVoicemailBox)
+						r.Get("/messages", s.handleListVoicemailMessages)
+						r.Post("/greeting", s.handleUploadGreeting)
+						r.Route("/messages/{msgID}", func(r chi.Router) {
+							r.Delete("/", s.handleDeleteVoicemailMessage)
+							r.Put("/read", s.handleMarkVoicemailMessageRead)
+							r.Get("/audio", s.handleGetVoicemailMessageAudio)
+						})
+					})
+				})
+
+				r.Route("/ring-groups", func(r chi.Router) {
+					r.Get("/", s.handleListRingGroups)
+					r.Post("/", s.handleCreateRingGroup)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetRingGroup)
+						r.Put("/", s.handleUpdateRingGroup)
+						r.Delete("/", s.handleDeleteRingGroup)
+					})
+				})
+
+				r.Route("/queues", func(r chi.Router) {
+					r.Get("/", s.handleListQueues)
+					r.Post("/", s.handleCreateQueue)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetQueue)
+						r.Put("/", s.handleUpdateQueue)
+						r.Delete("/", s.handleDeleteQueue)
+					})
+				})
+
+				r.Route("/ivr-menus", func(r chi.Router) {
+					r.Get("/", s.handleListIVRMenus)
+					r.Post("/", s.handleCreateIVRMenu)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetIVRMenu)
+						r.Put("/", s.handleUpdateIVRMenu)
+						r.Delete("/", s.handleDeleteIVRMenu)
+					})
+				})
+
+				r.Route("/time-switches", func(r chi.Router) {
+					r.Get("/", s.handleListTimeSwitches)
+					r.Post("/", s.handleCreateTimeSwitch)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetTimeSwitch)
+						r.Put("/", s.handleUpdateTimeSwitch)
+						r.Delete("/", s.handleDeleteTimeSwitch)
+						r.Get("/preview", s.handlePreviewTimeSwitch)
+						r.Get("/calendars", s.handleListTimeSwitchCalendars)
+						r.Post("/calendars", s.handleCreateTimeSwitchCalendar)
+						r.Post("/calendars/upload", s.handleUploadTimeSwitchCalendar)
+						r.Route("/calendars/{calendarID}", func(r chi.Router) {
+							r.Get("/", s.handleGetTimeSwitchCalendar)
+							r.Put("/", s.handleUpdateTimeSwitchCalendar)
+							r.Delete("/", s.handleDeleteTimeSwitchCalendar)
+							r.Post("/refresh", s.handleRefreshTimeSwitchCalendar)
+						})
+					})
+				})
+
+				r.Route("/flows", func(r chi.Router) {
+					r.Get("/", s.handleListFlows)
+					r.Post("/", s.handleCreateFlow)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetFlow)
+						r.Put("/", s.handleUpdateFlow)
+						r.Delete("/", s.handleDeleteFlow)
+						r.Post("/publish", s.handlePublishFlow)
+						r.Post("/validate", s.handleValidateFlow)
+						r.Get("/revisions", s.handleListFlowRevisions)
+						r.Get("/revisions/{revision}", s.handleGetFlowRevision)
+						r.Post("/revisions/{revision}/rollback", s.handleRollbackFlow)
+						r.Get("/diff", s.handleDiffFlow)
+						r.Post("/simulate", s.handleSimulateFlow)
+					})
+				})
+
+				r.Route("/moh-classes", func(r chi.Router) {
+					r.Get("/", s.handleListMOHClasses)
+					r.Post("/", s.handleCreateMOHClass)
+					r.Route("/{id}", func(r chi.Router) {
+						r.Get("/", s.handleGetMOHClass)
+						r.Put("/", s.handleUpdateMOHClass)
+						r.Delete("/", s.handleDeleteMOHClass)
+					})
+				})
+
+				r.Route("/prompts", func(r chi.Router) {
+					r.Get("/", s.handleListPrompts)
+					r.Post("/", s.handleUploadPrompt)
+					r.Get("/{id}/audio", s.handleGetPromptAudio)
+					r.Delete("/{id}", s.handleDeletePrompt)
+				})
 			})
-		})
 
-		r.Route("/time-switches", func(r chi.Router) {
-			r.Get("/", s.handleListTimeSwitches)
-			r.Post("/", s.handleCreateTimeSwitch)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetTimeSwitch)
-				r.Put("/", s.handleUpdateTimeSwitch)
-				r.Delete("/", s.handleDeleteTimeSwitch)
+			// Trunk rate decks are billing data; the trunks themselves
+			// are configuration.
+			r.Route("/trunks", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(configAccess)
+					r.Get("/", s.handleListTrunks)
+					r.Post("/", s.handleCreateTrunk)
+					r.Get("/status", s.handleListTrunkStatus)
+				})
+				r.Route("/{id}", func(r chi.Router) {
+					r.Group(func(r chi.Router) {
+						r.Use(configAccess)
+						r.Get("/", s.handleGetTrunk)
+						r.Put("/", s.handleUpdateTrunk)
+						r.Delete("/", s.handleDeleteTrunk)
+						r.Post("/test", s.handleTestTrunk)
+					})
+					r.Group(func(r chi.Router) {
+						r.Use(billingAccess)
+						r.Get("/rates", s.handleListTrunkRates)
+						r.Post("/rates", s.handleImportTrunkRates)
+						r.Delete("/rates", s.handleDeleteTrunkRates)
+					})
+				})
 			})
-		})
 
-		r.Route("/conferences", func(r chi.Router) {
-			r.Get("/", s.handleListConferenceBridges)
-			r.Post("/", s.handleCreateConferenceBridge)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetConferenceBridge)
-				r.Put("/", s.handleUpdateConferenceBridge)
-				r.Delete("/", s.handleDeleteConferenceBridge)
-				r.Get("/participants", s.handleListConferenceParticipants)
-				r.Put("/participants/{participantID}/mute", s.handleMuteConferenceParticipant)
-				r.Delete("/participants/{participantID}", s.handleKickConferenceParticipant)
+			// Conference rooms are configuration; their live participants
+			// are call control.
+			r.Route("/conferences", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(configAccess)
+					r.Get("/", s.handleListConferenceBridges)
+					r.Post("/", s.handleCreateConferenceBridge)
+				})
+				r.Route("/{id}", func(r chi.Router) {
+					r.Group(func(r chi.Router) {
+						r.Use(configAccess)
+						r.Get("/", s.handleGetConferenceBridge)
+						r.Put("/", s.handleUpdateConferenceBridge)
+						r.Delete("/", s.handleDeleteConferenceBridge)
+					})
+					r.Group(func(r chi.Router) {
+						r.Use(callsAccess)
+						r.Get("/participants", s.handleListConferenceParticipants)
+						r.Put("/participants/{participantID}/mute", s.handleMuteConferenceParticipant)
+						r.Delete("/participants/{participantID}", s.handleKickConferenceParticipant)
+					})
+				})
 			})
-		})
 
-		r.Route("/flows", func(r chi.Router) {
-			r.Get("/", s.handleListFlows)
-			r.Post("/", s.handleCreateFlow)
-			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", s.handleGetFlow)
-				r.Put("/", s.handleUpdateFlow)
-				r.Delete("/", s.handleDeleteFlow)
-				r.Post("/publish", s.handlePublishFlow)
-				r.Post("/validate", s.handleValidateFlow)
+			r.Route("/cdrs", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.RequirePermission(middleware.PermCDRsRead))
+					r.Get("/", s.handleListCDRs)
+					r.Get("/export", s.handleExportCDRs)
+					r.Get("/{id}", s.handleGetCDR)
+				})
+				r.With(middleware.RequirePermission(middleware.PermBillingRead)).Get("/costs", s.handleCDRCosts)
 			})
-		})
 
-		r.Route("/cdrs", func(r chi.Router) {
-			r.Get("/", s.handleListCDRs)
-			r.Get("/export", s.handleExportCDRs)
-			r.Get("/{id}", s.handleGetCDR)
-		})
+			r.Route("/recordings", func(r chi.Router) {
+				r.Use(middleware.RequireAccess(middleware.PermRecordingsRead, middleware.PermRecordingsDelete))
+				r.Get("/", s.handleListRecordings)
+				r.Get("/storage", s.handleRecordingStorageUsage)
+				r.Get("/{id}", s.handleGetRecording)
+				r.Get("/{id}/stream", s.handleStreamRecording)
+				r.Get("/{id}/download", s.handleDownloadRecording)
+				r.Delete("/{id}", s.handleDeleteRecording)
+			})
 
-		r.Route("/recordings", func(r chi.Router) {
-			r.Get("/", s.handleListRecordings)
-			r.Get("/storage", s.handleRecordingStorageUsage)
-			r.Get("/{id}", s.handleGetRecording)
-			r.Get("/{id}/stream", s.handleStreamRecording)
-			r.Get("/{id}/download", s.handleDownloadRecording)
-			r.Delete("/{id}", s.handleDeleteRecording)
-		})
+			r.With(middleware.RequirePermission(middleware.PermConfigRead)).Get("/settings", s.handleGetSettings)
+			r.With(middleware.RequirePermission(middleware.PermSystem)).Put("/settings", s.handleUpdateSettings)
 
-		r.Route("/prompts", func(r chi.Router) {
-			r.Get("/", s.handleListPrompts)
-			r.Post("/", s.handleUploadPrompt)
-			r.Get("/{id}/audio", s.handleGetPromptAudio)
-			r.Delete("/{id}", s.handleDeletePrompt)
-		})
+			r.Route("/system", func(r chi.Router) {
+				r.With(middleware.RequirePermission(middleware.PermConfigRead)).Get("/status", s.handleSystemStatus)
+				r.With(middleware.RequirePermission(middleware.PermSystem)).Post("/reload", s.handleSystemReload)
+			})
 
-		r.Get("/settings", s.handleGetSettings)
-		r.Put("/settings", s.handleUpdateSettings)
+			r.With(middleware.RequirePermission(middleware.PermCallsRead)).Get("/dashboard/stats", s.handleDashboardStats)
+
+			r.Route("/calls", func(r chi.Router) {
+				r.Use(callsAccess)
+				r.Get("/active", s.handleListActiveCalls)
+				r.Post("/originate", s.handleOriginateCall)
+				r.Post("/{id}/hangup", s.handleHangupCall)
+				r.Post("/{id}/transfer", s.handleTransferCall)
+				r.Post("/{id}/monitor", s.handleSuperviseCall(superviseMonitor))
+				r.Post("/{id}/whisper", s.handleSuperviseCall(superviseWhisper))
+				r.Post("/{id}/barge", s.handleSuperviseCall(superviseBarge))
+			})
 
-		r.Route("/system", func(r chi.Router) {
-			r.Get("/status", s.handleSystemStatus)
-			r.Post("/reload", s.handleSystemReload)
+			r.Route("/admin-users", func(r chi.Router) {
+				r.Use(middleware.RequirePermission(middleware.PermUsers))
+				r.Get("/", s.handleListAdminUsers)
+				r.Post("/", s.handleCreateAdminUser)
+				r.Route("/{id}", func(r chi.Router) {
+					r.Get("/", s.handleGetAdminUser)
+					r.Put("/", s.handleUpdateAdminUser)
+					r.Delete("/", s.handleDeleteAdminUser)
+					r.Delete("/totp", s.handleResetAdminUserTOTP)
+				})
+			})
 		})
 
-		r.Get("/dashboard/stats", s.handleDashboardStats)
+		// Real-time event stream for the admin UI (session) and app
+		// clients (JWT, limited to their own extension's events).
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireAuthOrAppAuth(s.sessions, s.cfg.TLSEnabled(), s.jwtSecret))
+			r.Use(middleware.RequirePermissionOrExtension(middleware.PermCallsRead))
+			r.Get("/events", s.handleEvents)
+		})
 
-		r.Route("/calls", func(r chi.Router) {
-			r.Get("/active", s.handleListActiveCalls)
-			r.Post("/{id}/hangup", s.handleNotImplemented)
-			r.Post("/{id}/transfer", s.handleNotImplemented)
+		// Self-service routes for extension users logged in through
+		// /auth/extension-login, limited to their own extension. The
+		// handlers are shared with the mobile app.
+		r.Route("/self", func(r chi.Router) {
+			r.Use(middleware.RequireAuth(s.sessions, s.cfg.TLSEnabled()))
+			r.Use(middleware.RequireExtensionUser)
+			r.Get("/me", s.handleAppGetMe)
+			r.Put("/me", s.handleAppUpdateMe)
+			r.Get("/voicemail", s.handleAppListVoicemail)
+			r.Put("/voicemail/{id}/read", s.handleAppMarkVoicemailRead)
+			r.Get("/voicemail/{id}/audio", s.handleAppGetVoicemailAudio)
 		})
 
 		// Mobile app endpoints.
@@ -397,6 +602,7 @@ func (s *Server) routes() {
 				r.Get("/history", s.handleAppHistory)
 				r.Get("/directory", s.handleAppDirectory)
 				r.Post("/push-token", s.handleAppPushToken)
+				r.Post("/calls/originate", s.handleAppOriginateCall)
 			})
 		})
 	})
@@ -489,10 +695,11 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create the admin user.
+	// Create the admin user. The first admin owns the system.
 	user := &models.AdminUser{
 		Username:     req.Username,
 		PasswordHash: hash,
+		Role:         middleware.RoleOwner,
 	}
 	if err := s.adminUsers.Create(r.Context(), user); err != nil {
 		slog.Error("setup: failed to create admin user", "error", err)
@@ -532,11 +739,16 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleLogin validates admin credentials and creates a session.
+// handleLogin validates admin credentials and creates a session. When the
+// admin has two-factor login turned on, a valid password alone gets a
+// totp_required response and no session; the client repeats the login
+// with a totp_code (or a recovery_code) as well.
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username     string `json:"username"`
+		Password     string `json:"password"`
+		TOTPCode     string `json:"totp_code"`
+		RecoveryCode string `json:"recovery_code"`
 	}
 	if errMsg := readJSON(r, &req); errMsg != "" {
 		writeError(w, http.StatusBadRequest, errMsg)
@@ -569,7 +781,19 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sess, err := s.sessions.Create(user.ID, user.Username)
+	if user.TOTPEnabled {
+		if req.TOTPCode == "" && req.RecoveryCode == "" {
+			writeJSON(w, http.StatusOK, map[string]any{
+				"totp_required": true,
+			})
+			return
+		}
+		if !s.checkSecondFactor(w, r, user, req.TOTPCode, req.RecoveryCode) {
+			return
+		}
+	}
+
+	sess, err := s.sessions.Create(user.ID, user.Username, user.Role)
 	if err != nil {
 		slog.Error("login: failed to create session", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal error")
@@ -578,11 +802,12 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	middleware.SetSessionCookie(w, sess, s.cfg.TLSEnabled())
 
-	slog.Info("admin login", "username", user.Username, "user_id", user.ID)
+	slog.Info("admin login", "username", user.Username, "user_id", user.ID, "role", user.Role)
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"user_id":  user.ID,
 		"username": user.Username,
+		"role":     user.Role,
 	})
 }
 
@@ -598,17 +823,47 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, nil)
 }
 
-// handleMe returns the currently authenticated admin user.
+// handleMe returns the currently authenticated admin user with their role
+// and permissions, or the extension of a self-service session.
 func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
-	user := middleware.AdminUserFromContext(r.Context())
+	if extID := middleware.AppExtensionIDFromContext(r.Context()); extID != 0 {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"role":         middleware.RoleExtension,
+			"extension_id": extID,
+			"extension":    middleware.AppExtensionFromContext(r.Context()),
+			"permissions":  []middleware.Permission{},
+		})
+		return
+	}
+
+	current := middleware.AdminUserFromContext(r.Context())
+	if current == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	user, err := s.adminUsers.GetByID(r.Context(), current.ID)
+	if err != nil {
+		slog.Error("me: failed to query user", "error", err, "user_id", current.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
 	if user == nil {
 		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
+	perms := middleware.RolePermissions(current.Role)
+	if perms == nil {
+		perms = []middleware.Permission{}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]any{
-		"user_id":  user.ID,
-		"username": user.Username,
+		"user_id":      current.ID,
+		"username":     current.Username,
+		"role":         current.Role,
+		"permissions":  perms,
+		"totp_enabled": user.TOTPEnabled,
 	})
 }
 
@@ -681,11 +936,6 @@ func (s *Server) handleListActiveCalls(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, items)
 }
 
-// handleNotImplemented returns 501 for endpoints not yet wired up.
-func (s *Server) handleNotImplemented(w http.ResponseWriter, r *http.Request) {
-	writeError(w, http.StatusNotImplemented, "not implemented")
-}
-
 // mountSPA configures static file serving from the embedded web.DistFS with
 // SPA fallback: requests for files that exist in the bundle are served directly;
 // all other non-API paths receive index.html so client-side routing works.
@@ -0,0 +1,452 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/schedule"
+	"github.com/go-chi/chi/v5"
+)
+
+// Bounds and default of a URL calendar's refresh interval, in minutes.
+const (
+	minCalendarRefreshMinutes     = 15
+	maxCalendarRefreshMinutes     = 43200
+	defaultCalendarRefreshMinutes = 1440
+)
+
+// calendarFetchTimeout bounds the fetch of a URL calendar made while
+// handling a request.
+const calendarFetchTimeout = 30 * time.Second
+
+// timeSwitchCalendarRequest is the JSON request body for creating/updating
+// a calendar imported into a time switch. URL and RefreshMinutes apply to
+// URL calendars only.
+type timeSwitchCalendarRequest struct {
+	Name           string `json:"name"`
+	Label          string `json:"label"`
+	URL            string `json:"url"`
+	RefreshMinutes int    `json:"refresh_minutes"`
+}
+
+// timeSwitchCalendarResponse is the JSON response for a single time switch
+// calendar.
+type timeSwitchCalendarResponse struct {
+	ID             int64   `json:"id"`
+	TimeSwitchID   int64   `json:"time_switch_id"`
+	Name           string  `json:"name"`
+	Label          string  `json:"label"`
+	Source         string  `json:"source"`
+	URL            string  `json:"url"`
+	RefreshMinutes int     `json:"refresh_minutes"`
+	EventCount     int     `json:"event_count"`
+	RefreshedAt    *string `json:"refreshed_at"`
+	LastError      string  `json:"last_error"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+}
+
+// toTimeSwitchCalendarResponse converts a models.TimeSwitchCalendar to the
+// API response.
+func toTimeSwitchCalendarResponse(cal *models.TimeSwitchCalendar) timeSwitchCalendarResponse {
+	resp := timeSwitchCalendarResponse{
+		ID:             cal.ID,
+		TimeSwitchID:   cal.TimeSwitchID,
+		Name:           cal.Name,
+		Label:          cal.Label,
+		Source:         cal.Source,
+		URL:            cal.URL,
+		RefreshMinutes: cal.RefreshMinutes,
+		EventCount:     cal.EventCount,
+		LastError:      cal.LastError,
+		CreatedAt:      cal.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      cal.UpdatedAt.Format(time.RFC3339),
+	}
+	if cal.RefreshedAt != nil {
+		v := cal.RefreshedAt.Format(time.RFC3339)
+		resp.RefreshedAt = &v
+	}
+	return resp
+}
+
+// handleListTimeSwitchCalendars returns the calendars imported into a time
+// switch, in the order their events are checked.
+func (s *Server) handleListTimeSwitchCalendars(w http.ResponseWriter, r *http.Request) {
+	ts, ok := s.timeSwitchFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	cals, err := s.timeSwitchCals.ListByTimeSwitch(r.Context(), ts.ID)
+	if err != nil {
+		slog.Error("list time switch calendars: failed to query", "error", err, "time_switch_id", ts.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]timeSwitchCalendarResponse, len(cals))
+	for i := range cals {
+		items[i] = toTimeSwitchCalendarResponse(&cals[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleCreateTimeSwitchCalendar subscribes a time switch to a calendar
+// URL. The calendar is fetched straight away and is not created if the
+// fetch fails.
+func (s *Server) handleCreateTimeSwitchCalendar(w http.ResponseWriter, r *http.Request) {
+	ts, ok := s.timeSwitchFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req timeSwitchCalendarRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateTimeSwitchCalendarRequest(req, schedule.SourceURL); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	cal := &models.TimeSwitchCalendar{
+		TimeSwitchID:   ts.ID,
+		Name:           req.Name,
+		Label:          req.Label,
+		Source:         schedule.SourceURL,
+		URL:            req.URL,
+		RefreshMinutes: req.RefreshMinutes,
+	}
+	if cal.RefreshMinutes == 0 {
+		cal.RefreshMinutes = defaultCalendarRefreshMinutes
+	}
+
+	if err := s.timeSwitchCals.Create(r.Context(), cal); err != nil {
+		slog.Error("create time switch calendar: failed to insert", "error", err, "time_switch_id", ts.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), calendarFetchTimeout)
+	defer cancel()
+	if err := s.calendars.Refresh(ctx, cal); err != nil {
+		s.discardTimeSwitchCalendar(r.Context(), cal.ID)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("calendar could not be imported: %s", err))
+		return
+	}
+
+	created, err := s.timeSwitchCals.GetByID(r.Context(), cal.ID)
+	if err != nil || created == nil {
+		slog.Error("create time switch calendar: failed to re-fetch", "error", err, "calendar_id", cal.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("time switch calendar subscribed", "calendar_id", created.ID, "time_switch_id", ts.ID,
+		"name", created.Name, "events", created.EventCount)
+
+	writeJSON(w, http.StatusCreated, toTimeSwitchCalendarResponse(created))
+}
+
+// handleUploadTimeSwitchCalendar imports an uploaded .ics file into a time
+// switch via multipart form data: the file, its name and its label.
+func (s *Server) handleUploadTimeSwitchCalendar(w http.ResponseWriter, r *http.Request) {
+	ts, ok := s.timeSwitchFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, schedule.MaxCalendarSize+(64<<10))
+	if err := r.ParseMultipartForm(schedule.MaxCalendarSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid multipart form")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file field is required")
+		return
+	}
+	defer file.Close()
+
+	req := timeSwitchCalendarRequest{
+		Name:  r.FormValue("name"),
+		Label: r.FormValue("label"),
+	}
+	if req.Name == "" {
+		req.Name = header.Filename
+	}
+	if errMsg := validateTimeSwitchCalendarRequest(req, schedule.SourceUpload); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		slog.Error("upload time switch calendar: failed to read file", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read uploaded file")
+		return
+	}
+
+	// Validate before creating the calendar, so a bad file leaves nothing
+	// behind.
+	parsed, err := schedule.ParseICS(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid calendar file: %s", err))
+		return
+	}
+
+	cal := &models.TimeSwitchCalendar{
+		TimeSwitchID: ts.ID,
+		Name:         req.Name,
+		Label:        req.Label,
+		Source:       schedule.SourceUpload,
+		EventCount:   len(parsed.Events),
+	}
+	if err := s.timeSwitchCals.Create(r.Context(), cal); err != nil {
+		slog.Error("upload time switch calendar: failed to insert", "error", err, "time_switch_id", ts.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if _, err := s.calendars.Save(cal.ID, data); err != nil {
+		slog.Error("upload time switch calendar: failed to save file", "error", err, "calendar_id", cal.ID)
+		s.discardTimeSwitchCalendar(r.Context(), cal.ID)
+		writeError(w, http.StatusInternalServerError, "failed to save file")
+		return
+	}
+
+	created, err := s.timeSwitchCals.GetByID(r.Context(), cal.ID)
+	if err != nil || created == nil {
+		slog.Error("upload time switch calendar: failed to re-fetch", "error", err, "calendar_id", cal.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("time switch calendar uploaded", "calendar_id", created.ID, "time_switch_id", ts.ID,
+		"name", created.Name, "events", len(parsed.Events), "skipped", parsed.Skipped)
+
+	writeJSON(w, http.StatusCreated, toTimeSwitchCalendarResponse(created))
+}
+
+// handleGetTimeSwitchCalendar returns a single calendar of a time switch.
+func (s *Server) handleGetTimeSwitchCalendar(w http.ResponseWriter, r *http.Request) {
+	cal, ok := s.timeSwitchCalendarFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toTimeSwitchCalendarResponse(cal))
+}
+
+// handleUpdateTimeSwitchCalendar updates the name and label of a calendar,
+// and the URL and refresh interval of a URL calendar. A URL calendar whose
+// URL changes is fetched again straight away.
+func (s *Server) handleUpdateTimeSwitchCalendar(w http.ResponseWriter, r *http.Request) {
+	existing, ok := s.timeSwitchCalendarFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req timeSwitchCalendarRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if errMsg := validateTimeSwitchCalendarRequest(req, existing.Source); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	urlChanged := false
+	existing.Name = req.Name
+	existing.Label = req.Label
+	if existing.Source == schedule.SourceURL {
+		urlChanged = req.URL != existing.URL
+		existing.URL = req.URL
+		if req.RefreshMinutes != 0 {
+			existing.RefreshMinutes = req.RefreshMinutes
+		}
+	}
+
+	if err := s.timeSwitchCals.Update(r.Context(), existing); err != nil {
+		slog.Error("update time switch calendar: failed to update", "error", err, "calendar_id", existing.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if urlChanged {
+		ctx, cancel := context.WithTimeout(r.Context(), calendarFetchTimeout)
+		defer cancel()
+		if err := s.calendars.Refresh(ctx, existing); err != nil {
+			// The new URL is kept; the error is recorded on the calendar
+			// and the refresh ticker retries it.
+			slog.Warn("update time switch calendar: fetch failed", "error", err, "calendar_id", existing.ID)
+		}
+	}
+
+	updated, err := s.timeSwitchCals.GetByID(r.Context(), existing.ID)
+	if err != nil || updated == nil {
+		slog.Error("update time switch calendar: failed to re-fetch", "error", err, "calendar_id", existing.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("time switch calendar updated", "calendar_id", updated.ID, "name", updated.Name)
+
+	writeJSON(w, http.StatusOK, toTimeSwitchCalendarResponse(updated))
+}
+
+// handleDeleteTimeSwitchCalendar removes a calendar from a time switch.
+func (s *Server) handleDeleteTimeSwitchCalendar(w http.ResponseWriter, r *http.Request) {
+	cal, ok := s.timeSwitchCalendarFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.timeSwitchCals.Delete(r.Context(), cal.ID); err != nil {
+		slog.Error("delete time switch calendar: failed to delete", "error", err, "calendar_id", cal.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if err := s.calendars.Remove(cal.ID); err != nil {
+		slog.Warn("delete time switch calendar: failed to remove file", "error", err, "calendar_id", cal.ID)
+	}
+
+	slog.Info("time switch calendar deleted", "calendar_id", cal.ID, "name", cal.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRefreshTimeSwitchCalendar fetches a URL calendar now rather than
+// waiting for its refresh interval.
+func (s *Server) handleRefreshTimeSwitchCalendar(w http.ResponseWriter, r *http.Request) {
+	cal, ok := s.timeSwitchCalendarFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if cal.Source != schedule.SourceURL {
+		writeError(w, http.StatusBadRequest, "only url calendars can be refreshed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), calendarFetchTimeout)
+	defer cancel()
+	if err := s.calendars.Refresh(ctx, cal); err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("calendar refresh failed: %s", err))
+		return
+	}
+
+	refreshed, err := s.timeSwitchCals.GetByID(r.Context(), cal.ID)
+	if err != nil || refreshed == nil {
+		slog.Error("refresh time switch calendar: failed to re-fetch", "error", err, "calendar_id", cal.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toTimeSwitchCalendarResponse(refreshed))
+}
+
+// timeSwitchFromRequest looks up the time switch named by the URL,
+// writing the error response and returning false if it does not exist.
+func (s *Server) timeSwitchFromRequest(w http.ResponseWriter, r *http.Request) (*models.TimeSwitch, bool) {
+	id, err := parseTimeSwitchID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid time switch id")
+		return nil, false
+	}
+
+	ts, err := s.timeSwitches.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("time switch calendar: failed to query time switch", "error", err, "time_switch_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return nil, false
+	}
+	if ts == nil {
+		writeError(w, http.StatusNotFound, "time switch not found")
+		return nil, false
+	}
+	return ts, true
+}
+
+// timeSwitchCalendarFromRequest looks up the calendar named by the URL,
+// writing the error response and returning false if it does not exist or
+// belongs to another time switch.
+func (s *Server) timeSwitchCalendarFromRequest(w http.ResponseWriter, r *http.Request) (*models.TimeSwitchCalendar, bool) {
+	tsID, err := parseTimeSwitchID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid time switch id")
+		return nil, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "calendarID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid calendar id")
+		return nil, false
+	}
+
+	cal, err := s.timeSwitchCals.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("time switch calendar: failed to query", "error", err, "calendar_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return nil, false
+	}
+	if cal == nil || cal.TimeSwitchID != tsID {
+		writeError(w, http.StatusNotFound, "calendar not found")
+		return nil, false
+	}
+	return cal, true
+}
+
+// discardTimeSwitchCalendar removes a calendar whose import failed.
+func (s *Server) discardTimeSwitchCalendar(ctx context.Context, id int64) {
+	if err := s.timeSwitchCals.Delete(ctx, id); err != nil {
+		slog.Error("time switch calendar: failed to discard failed import", "error", err, "calendar_id", id)
+	}
+	if err := s.calendars.Remove(id); err != nil {
+		slog.Warn("time switch calendar: failed to remove file of failed import", "error", err, "calendar_id", id)
+	}
+}
+
+// validateTimeSwitchCalendarRequest checks the fields of a time switch
+// calendar create/update for a calendar of the given source.
+func validateTimeSwitchCalendarRequest(req timeSwitchCalendarRequest, source string) string {
+	if msg := validateRequiredStringLen("name", req.Name, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("name", req.Name); msg != "" {
+		return msg
+	}
+	if msg := validateRequiredStringLen("label", req.Label, maxNameLen); msg != "" {
+		return msg
+	}
+	if msg := validateNoControlChars("label", req.Label); msg != "" {
+		return msg
+	}
+
+	if source != schedule.SourceURL {
+		return ""
+	}
+	if msg := validateRequiredStringLen("url", req.URL, 2048); msg != "" {
+		return msg
+	}
+	if _, err := schedule.FetchURL(req.URL); err != nil {
+		return err.Error()
+	}
+	if req.RefreshMinutes != 0 {
+		refresh := req.RefreshMinutes
+		if msg := validateIntRange("refresh_minutes", &refresh, minCalendarRefreshMinutes, maxCalendarRefreshMinutes); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
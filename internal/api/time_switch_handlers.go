@@ -2,12 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow/nodes"
+	"github.com/flowpbx/flowpbx/internal/schedule"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -224,17 +227,113 @@ func (s *Server) handleDeleteTimeSwitch(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	cals, err := s.timeSwitchCals.ListByTimeSwitch(r.Context(), id)
+	if err != nil {
+		slog.Error("delete time switch: failed to list calendars", "error", err, "time_switch_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
 	if err := s.timeSwitches.Delete(r.Context(), id); err != nil {
 		slog.Error("delete time switch: failed to delete", "error", err, "time_switch_id", id)
 		writeError(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
+	// The calendar rows are deleted with the time switch; remove their files.
+	for _, cal := range cals {
+		if err := s.calendars.Remove(cal.ID); err != nil {
+			slog.Warn("delete time switch: failed to remove calendar file", "error", err, "calendar_id", cal.ID)
+		}
+	}
+
 	slog.Info("time switch deleted", "time_switch_id", id, "name", existing.Name)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// timeSwitchPreviewResponse is the JSON response of a time switch preview:
+// the edge the time switch takes at a moment and what selected it.
+type timeSwitchPreviewResponse struct {
+	Edge      string `json:"edge"`
+	Match     string `json:"match"`
+	Label     string `json:"label,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Calendar  string `json:"calendar,omitempty"`
+	Event     string `json:"event,omitempty"`
+	LocalTime string `json:"local_time"`
+	Timezone  string `json:"timezone"`
+}
+
+// handlePreviewTimeSwitch returns the edge a time switch would take at the
+// time given by the "at" query parameter, or now if it is omitted. "at" is
+// an RFC 3339 timestamp, or a local date and time ("2006-01-02T15:04") in
+// the time switch's timezone.
+func (s *Server) handlePreviewTimeSwitch(w http.ResponseWriter, r *http.Request) {
+	id, err := parseTimeSwitchID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid time switch id")
+		return
+	}
+
+	ts, err := s.timeSwitches.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error("preview time switch: failed to query", "error", err, "time_switch_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if ts == nil {
+		writeError(w, http.StatusNotFound, "time switch not found")
+		return
+	}
+
+	tz := ts.Timezone
+	if tz == "" {
+		tz = "Australia/Sydney"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("time switch timezone %q is not valid", tz))
+		return
+	}
+
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		at, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			at, err = time.ParseInLocation("2006-01-02T15:04", v, loc)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "at must be an RFC 3339 timestamp or a local time like 2006-01-02T15:04")
+			return
+		}
+	}
+
+	sets, err := s.calendars.OverrideSets(r.Context(), id)
+	if err != nil {
+		slog.Error("preview time switch: failed to load calendars", "error", err, "time_switch_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	res, err := nodes.EvaluateTimeSwitch(ts, sets, at)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("time switch cannot be evaluated: %s", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, timeSwitchPreviewResponse{
+		Edge:      res.Edge,
+		Match:     res.Match,
+		Label:     res.Label,
+		Date:      res.Date,
+		Calendar:  res.Calendar,
+		Event:     res.Event,
+		LocalTime: res.LocalTime.Format(time.RFC3339),
+		Timezone:  tz,
+	})
+}
+
 // parseTimeSwitchID extracts and parses the time switch ID from the URL parameter.
 func parseTimeSwitchID(r *http.Request) (int64, error) {
 	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
@@ -265,6 +364,9 @@ func validateTimeSwitchRequest(req timeSwitchRequest, isCreate bool) string {
 		if len(arr) > 100 {
 			return "rules must contain at most 100 entries"
 		}
+		if msg := validateTimeSwitchRecurrences(req.Rules); msg != "" {
+			return msg
+		}
 	}
 	if req.Overrides != nil {
 		var arr []json.RawMessage
@@ -277,3 +379,27 @@ func validateTimeSwitchRequest(req timeSwitchRequest, isCreate bool) string {
 	}
 	return ""
 }
+
+// validateTimeSwitchRecurrences checks the recurrence rules of the rules
+// of a time switch that have one.
+func validateTimeSwitchRecurrences(raw json.RawMessage) string {
+	var rules []struct {
+		RRule   string `json:"rrule"`
+		DTStart string `json:"dtstart"`
+	}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return "rules must be an array of rule objects"
+	}
+	for i, rule := range rules {
+		if rule.RRule == "" {
+			continue
+		}
+		if _, err := schedule.ParseRRule(rule.RRule); err != nil {
+			return fmt.Sprintf("rules[%d].rrule is invalid: %s", i, err)
+		}
+		if _, err := time.Parse("2006-01-02", rule.DTStart); err != nil {
+			return fmt.Sprintf("rules[%d].dtstart must be a date (YYYY-MM-DD) when rrule is set", i)
+		}
+	}
+	return ""
+}
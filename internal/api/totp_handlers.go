@@ -0,0 +1,352 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/api/middleware"
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/totp"
+)
+
+// totpIssuer names the PBX in authenticator apps.
+const totpIssuer = "FlowPBX"
+
+// recoveryCodeCount is the number of recovery codes issued at a time.
+const recoveryCodeCount = 10
+
+// totpEnrollResponse is the JSON response for POST /auth/totp/enroll.
+type totpEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// totpCodeRequest is the JSON request body for POST /auth/totp/verify.
+type totpCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// totpPasswordRequest is the JSON request body for the TOTP endpoints that
+// need the user's password.
+type totpPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// recoveryCodesResponse is the JSON response listing newly issued recovery
+// codes. They are shown once; only their hashes are stored.
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// handleEnrollTOTP handles POST /auth/totp/enroll — generates a new TOTP
+// secret for the current admin. Two-factor login is turned on only once a
+// code from it is verified, so an abandoned enrollment locks nobody out.
+func (s *Server) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user := s.currentAdminUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.TOTPEnabled {
+		writeError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		slog.Error("totp enroll: failed to generate secret", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	sealed, err := s.sealTOTPSecret(secret)
+	if err != nil {
+		slog.Error("totp enroll: failed to encrypt secret", "error", err, "admin_user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	user.TOTPSecret = &sealed
+	if err := s.adminUsers.Update(r.Context(), user); err != nil {
+		slog.Error("totp enroll: failed to update", "error", err, "admin_user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, totpEnrollResponse{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Username, secret),
+	})
+}
+
+// handleVerifyTOTP handles POST /auth/totp/verify — checks a code from the
+// secret being enrolled, turns on two-factor login and issues recovery
+// codes.
+func (s *Server) handleVerifyTOTP(w http.ResponseWriter, r *http.Request) {
+	user := s.currentAdminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var req totpCodeRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+	if user.TOTPEnabled {
+		writeError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+	if user.TOTPSecret == nil {
+		writeError(w, http.StatusBadRequest, "start enrollment before verifying a code")
+		return
+	}
+
+	secret, err := s.openTOTPSecret(*user.TOTPSecret)
+	if err != nil {
+		slog.Error("totp verify: failed to decrypt secret", "error", err, "admin_user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	step, ok := totp.Validate(secret, req.Code, time.Now())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid authentication code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		slog.Error("totp verify: failed to generate recovery codes", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	if err := s.adminUsers.Update(r.Context(), user); err != nil {
+		slog.Error("totp verify: failed to update", "error", err, "admin_user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("admin two-factor authentication enabled", "admin_user_id", user.ID, "username", user.Username)
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// handleDisableTOTP handles POST /auth/totp/disable — turns off two-factor
+// login for the current admin after confirming their password.
+func (s *Server) handleDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	user := s.currentAdminUser(w, r)
+	if user == nil {
+		return
+	}
+	if !s.checkTOTPPassword(w, r, user) {
+		return
+	}
+
+	clearTOTP(user)
+	if err := s.adminUsers.Update(r.Context(), user); err != nil {
+		slog.Error("totp disable: failed to update", "error", err, "admin_user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("admin two-factor authentication disabled", "admin_user_id", user.ID, "username", user.Username)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRegenerateRecoveryCodes handles POST /auth/totp/recovery-codes —
+// replaces the current admin's recovery codes after confirming their
+// password. The previous codes stop working.
+func (s *Server) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := s.currentAdminUser(w, r)
+	if user == nil {
+		return
+	}
+	if !s.checkTOTPPassword(w, r, user) {
+		return
+	}
+	if !user.TOTPEnabled {
+		writeError(w, http.StatusBadRequest, "two-factor authentication is not enabled")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		slog.Error("regenerate recovery codes: failed to generate", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	user.RecoveryCodes = hashes
+	if err := s.adminUsers.Update(r.Context(), user); err != nil {
+		slog.Error("regenerate recovery codes: failed to update", "error", err, "admin_user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("admin recovery codes regenerated", "admin_user_id", user.ID, "username", user.Username)
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// checkSecondFactor verifies the TOTP code or, failing that, the recovery
+// code given at login by an admin with two-factor login turned on. A
+// recovery code is used up. Writes the error response and returns false
+// if neither is valid.
+func (s *Server) checkSecondFactor(w http.ResponseWriter, r *http.Request, user *models.AdminUser, code, recoveryCode string) bool {
+	if code != "" {
+		if user.TOTPSecret == nil {
+			writeError(w, http.StatusUnauthorized, "invalid authentication code")
+			return false
+		}
+		secret, err := s.openTOTPSecret(*user.TOTPSecret)
+		if err != nil {
+			slog.Error("login: failed to decrypt totp secret", "error", err, "user_id", user.ID)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return false
+		}
+		step, ok := totp.Validate(secret, code, time.Now())
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "invalid authentication code")
+			return false
+		}
+		claimed, err := s.adminUsers.ClaimTOTPStep(r.Context(), user.ID, step)
+		if err != nil {
+			slog.Error("login: failed to record totp step", "error", err, "user_id", user.ID)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return false
+		}
+		if !claimed {
+			// The code was already used to log in.
+			writeError(w, http.StatusUnauthorized, "invalid authentication code")
+			return false
+		}
+		return true
+	}
+
+	var hashes []string
+	if err := json.Unmarshal([]byte(user.RecoveryCodes), &hashes); err != nil {
+		slog.Error("login: invalid recovery codes", "error", err, "user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	i := totp.MatchRecoveryCode(hashes, recoveryCode)
+	if i < 0 {
+		writeError(w, http.StatusUnauthorized, "invalid recovery code")
+		return false
+	}
+
+	claimed, err := s.adminUsers.ClaimRecoveryCode(r.Context(), user.ID, hashes[i])
+	if err != nil {
+		slog.Error("login: failed to use up recovery code", "error", err, "user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	if !claimed {
+		// A concurrent login used the code first.
+		writeError(w, http.StatusUnauthorized, "invalid recovery code")
+		return false
+	}
+
+	slog.Warn("admin login with recovery code", "username", user.Username, "user_id", user.ID, "remaining", len(hashes)-1)
+	return true
+}
+
+// currentAdminUser loads the admin user of the request's session, writing
+// the error response and returning nil if there is none.
+func (s *Server) currentAdminUser(w http.ResponseWriter, r *http.Request) *models.AdminUser {
+	current := middleware.AdminUserFromContext(r.Context())
+	if current == nil {
+		writeError(w, http.StatusForbidden, "admin login required")
+		return nil
+	}
+
+	user, err := s.adminUsers.GetByID(r.Context(), current.ID)
+	if err != nil {
+		slog.Error("failed to query current admin user", "error", err, "user_id", current.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return nil
+	}
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "session expired or invalid")
+		return nil
+	}
+	return user
+}
+
+// checkTOTPPassword reads a password confirmation from the request body
+// and checks it against the user's password, writing the error response
+// and returning false if it does not match.
+func (s *Server) checkTOTPPassword(w http.ResponseWriter, r *http.Request, user *models.AdminUser) bool {
+	var req totpPasswordRequest
+	if errMsg := readJSON(r, &req); errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg)
+		return false
+	}
+	if req.Password == "" {
+		writeError(w, http.StatusBadRequest, "password is required")
+		return false
+	}
+
+	match, err := database.CheckPassword(req.Password, user.PasswordHash)
+	if err != nil {
+		slog.Error("totp: failed to verify password", "error", err, "user_id", user.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+	if !match {
+		writeError(w, http.StatusForbidden, "incorrect password")
+		return false
+	}
+	return true
+}
+
+// clearTOTP turns off two-factor login for a user and forgets their
+// secret and recovery codes.
+func clearTOTP(user *models.AdminUser) {
+	user.TOTPSecret = nil
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = "[]"
+}
+
+// newRecoveryCodes generates a set of recovery codes, returning the codes
+// and the JSON array of their hashes to store.
+func newRecoveryCodes() ([]string, string, error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, "", err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = totp.HashRecoveryCode(c)
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", fmt.Errorf("encoding recovery codes: %w", err)
+	}
+	return codes, string(data), nil
+}
+
+// sealTOTPSecret encrypts a TOTP secret for storage when an encryption key
+// is configured, as is done for SIP passwords.
+func (s *Server) sealTOTPSecret(secret string) (string, error) {
+	if s.encryptor == nil {
+		return secret, nil
+	}
+	return s.encryptor.Encrypt(secret)
+}
+
+// openTOTPSecret decrypts a stored TOTP secret.
+func (s *Server) openTOTPSecret(stored string) (string, error) {
+	if s.encryptor == nil {
+		return stored, nil
+	}
+	return s.encryptor.Decrypt(stored)
+}
@@ -34,6 +34,7 @@ type trunkRequest struct {
 	PrefixAdd      string   `json:"prefix_add"`
 	Priority       int      `json:"priority"`
 	RecordingMode  string   `json:"recording_mode"`
+	SRTPMode       string   `json:"srtp_mode"`
 }
 
 // trunkResponse is the JSON response for a single trunk. Password is never returned.
@@ -58,6 +59,7 @@ type trunkResponse struct {
 	PrefixAdd      string   `json:"prefix_add"`
 	Priority       int      `json:"priority"`
 	RecordingMode  string   `json:"recording_mode"`
+	SRTPMode       string   `json:"srtp_mode"`
 	CreatedAt      string   `json:"created_at"`
 	UpdatedAt      string   `json:"updated_at"`
 }
@@ -97,6 +99,7 @@ func toTrunkResponse(t *models.Trunk) trunkResponse {
 		PrefixAdd:      t.PrefixAdd,
 		Priority:       t.Priority,
 		RecordingMode:  t.RecordingMode,
+		SRTPMode:       t.SRTPMode,
 		CreatedAt:      t.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:      t.UpdatedAt.Format(time.RFC3339),
 	}
@@ -205,6 +208,10 @@ func (s *Server) handleCreateTrunk(w http.ResponseWriter, r *http.Request) {
 	if recordingMode == "" {
 		recordingMode = "off"
 	}
+	srtpMode := req.SRTPMode
+	if srtpMode == "" {
+		srtpMode = "off"
+	}
 
 	trunk := &models.Trunk{
 		Name:           req.Name,
@@ -227,6 +234,7 @@ func (s *Server) handleCreateTrunk(w http.ResponseWriter, r *http.Request) {
 		PrefixAdd:      req.PrefixAdd,
 		Priority:       req.Priority,
 		RecordingMode:  recordingMode,
+		SRTPMode:       srtpMode,
 	}
 
 	// Apply defaults.
@@ -400,6 +408,10 @@ func (s *Server) handleUpdateTrunk(w http.ResponseWriter, r *http.Request) {
 	if recordingMode == "" {
 		recordingMode = existing.RecordingMode
 	}
+	srtpMode := req.SRTPMode
+	if srtpMode == "" {
+		srtpMode = existing.SRTPMode
+	}
 
 	existing.Name = req.Name
 	existing.Type = req.Type
@@ -421,6 +433,7 @@ func (s *Server) handleUpdateTrunk(w http.ResponseWriter, r *http.Request) {
 	existing.PrefixAdd = req.PrefixAdd
 	existing.Priority = priority
 	existing.RecordingMode = recordingMode
+	existing.SRTPMode = srtpMode
 
 	if err := s.trunks.Update(r.Context(), existing); err != nil {
 		slog.Error("update trunk: failed to update", "error", err, "trunk_id", id)
@@ -688,5 +701,8 @@ func validateTrunkRequest(req trunkRequest) string {
 	if req.RecordingMode != "" && req.RecordingMode != "off" && req.RecordingMode != "always" && req.RecordingMode != "on_demand" {
 		return "recording_mode must be \"off\", \"always\", or \"on_demand\""
 	}
+	if req.SRTPMode != "" && req.SRTPMode != "off" && req.SRTPMode != "optional" && req.SRTPMode != "required" {
+		return "srtp_mode must be \"off\", \"optional\", or \"required\""
+	}
 	return ""
 }
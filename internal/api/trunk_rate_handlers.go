@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// maxRateDeckUploadSize is the upper limit for rate deck CSV uploads (10 MB).
+const maxRateDeckUploadSize = 10 << 20
+
+// maxRateDeckRows bounds the number of prefixes in one rate deck.
+const maxRateDeckRows = 100000
+
+// trunkRateResponse is the JSON response for a single rate deck entry.
+type trunkRateResponse struct {
+	ID          int64   `json:"id"`
+	TrunkID     int64   `json:"trunk_id"`
+	Prefix      string  `json:"prefix"`
+	Description string  `json:"description"`
+	Rate        float64 `json:"rate"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// toTrunkRateResponse converts a models.TrunkRate to the API response.
+func toTrunkRateResponse(t *models.TrunkRate) trunkRateResponse {
+	return trunkRateResponse{
+		ID:          t.ID,
+		TrunkID:     t.TrunkID,
+		Prefix:      t.Prefix,
+		Description: t.Description,
+		Rate:        t.Rate,
+		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// handleListTrunkRates returns the rate deck of a trunk ordered by prefix.
+func (s *Server) handleListTrunkRates(w http.ResponseWriter, r *http.Request) {
+	trunk, ok := s.rateDeckTrunk(w, r, "list trunk rates")
+	if !ok {
+		return
+	}
+
+	rates, err := s.trunkRates.ListByTrunk(r.Context(), trunk.ID)
+	if err != nil {
+		slog.Error("list trunk rates: failed to query", "error", err, "trunk_id", trunk.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := make([]trunkRateResponse, len(rates))
+	for i := range rates {
+		items[i] = toTrunkRateResponse(&rates[i])
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleImportTrunkRates replaces the rate deck of a trunk with a CSV file
+// uploaded as the "file" field of a multipart form. Each row holds a
+// prefix, a rate per minute and an optional description; a header row is
+// skipped.
+func (s *Server) handleImportTrunkRates(w http.ResponseWriter, r *http.Request) {
+	trunk, ok := s.rateDeckTrunk(w, r, "import trunk rates")
+	if !ok {
+		return
+	}
+
+	// Limit request body size.
+	r.Body = http.MaxBytesReader(w, r.Body, maxRateDeckUploadSize)
+
+	if err := r.ParseMultipartForm(maxRateDeckUploadSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid multipart form")
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file field is required")
+		return
+	}
+	defer file.Close()
+
+	rates, err := parseRateDeck(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.trunkRates.ReplaceForTrunk(r.Context(), trunk.ID, rates); err != nil {
+		slog.Error("import trunk rates: failed to replace", "error", err, "trunk_id", trunk.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("trunk rate deck imported", "trunk_id", trunk.ID, "name", trunk.Name, "prefixes", len(rates))
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"trunk_id": trunk.ID,
+		"imported": len(rates),
+	})
+}
+
+// handleDeleteTrunkRates removes the whole rate deck of a trunk.
+func (s *Server) handleDeleteTrunkRates(w http.ResponseWriter, r *http.Request) {
+	trunk, ok := s.rateDeckTrunk(w, r, "delete trunk rates")
+	if !ok {
+		return
+	}
+
+	if err := s.trunkRates.DeleteByTrunk(r.Context(), trunk.ID); err != nil {
+		slog.Error("delete trunk rates: failed to delete", "error", err, "trunk_id", trunk.ID)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	slog.Info("trunk rate deck deleted", "trunk_id", trunk.ID, "name", trunk.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rateDeckTrunk looks up the trunk a rate deck request is for, writing the
+// error response and returning false if the ID is invalid or the trunk
+// does not exist. op prefixes the log message of a failed query.
+func (s *Server) rateDeckTrunk(w http.ResponseWriter, r *http.Request, op string) (*models.Trunk, bool) {
+	id, err := parseTrunkID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid trunk id")
+		return nil, false
+	}
+
+	trunk, err := s.trunks.GetByID(r.Context(), id)
+	if err != nil {
+		slog.Error(op+": failed to query trunk", "error", err, "trunk_id", id)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return nil, false
+	}
+	if trunk == nil {
+		writeError(w, http.StatusNotFound, "trunk not found")
+		return nil, false
+	}
+	return trunk, true
+}
+
+// parseRateDeck reads a rate deck CSV of prefix, rate per minute and
+// optional description rows. A first row whose rate is not a number is
+// taken as a header and skipped. Errors name the offending line.
+func parseRateDeck(r io.Reader) ([]models.TrunkRate, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var rates []models.TrunkRate
+	seen := make(map[string]bool)
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %v", err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("line %d: expected prefix, rate and optional description", line)
+		}
+
+		prefix := strings.TrimSpace(record[0])
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: rate %q is not a number", line, record[1])
+		}
+		if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("line %d: rate must not be negative", line)
+		}
+		if prefix == "" || len(prefix) > maxShortStringLen || strings.Trim(prefix, "0123456789+*#") != "" {
+			return nil, fmt.Errorf("line %d: prefix %q must be 1-%d digits", line, prefix, maxShortStringLen)
+		}
+		if seen[prefix] {
+			return nil, fmt.Errorf("line %d: prefix %s is listed more than once", line, prefix)
+		}
+		seen[prefix] = true
+
+		description := ""
+		if len(record) == 3 {
+			description = strings.TrimSpace(record[2])
+			if msg := validateStringLen("description", description, maxNameLen); msg != "" {
+				return nil, fmt.Errorf("line %d: %s", line, msg)
+			}
+		}
+
+		if len(rates) == maxRateDeckRows {
+			return nil, fmt.Errorf("rate deck must contain at most %d prefixes", maxRateDeckRows)
+		}
+		rates = append(rates, models.TrunkRate{Prefix: prefix, Description: description, Rate: rate})
+	}
+
+	if len(rates) == 0 {
+		return nil, errors.New("rate deck contains no rates")
+	}
+	return rates, nil
+}
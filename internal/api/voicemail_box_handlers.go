@@ -48,6 +48,7 @@ type voicemailBoxResponse struct {
 	MaxMessages        int    `json:"max_messages"`
 	RetentionDays      int    `json:"retention_days"`
 	NotifyExtensionID  *int64 `json:"notify_extension_id"`
+	NameFile           string `json:"name_file"`
 	CreatedAt          string `json:"created_at"`
 	UpdatedAt          string `json:"updated_at"`
 }
@@ -67,6 +68,7 @@ func toVoicemailBoxResponse(b *models.VoicemailBox) voicemailBoxResponse {
 		MaxMessages:        b.MaxMessages,
 		RetentionDays:      b.RetentionDays,
 		NotifyExtensionID:  b.NotifyExtensionID,
+		NameFile:           b.NameFile,
 		CreatedAt:          b.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:          b.UpdatedAt.Format(time.RFC3339),
 	}
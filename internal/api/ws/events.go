@@ -0,0 +1,91 @@
+package ws
+
+import "time"
+
+// Event types, grouped by topic.
+const (
+	// EventCallRinging is published on TopicCalls when a call starts
+	// ringing.
+	EventCallRinging = "call.ringing"
+	// EventCallRingingStopped is published on TopicCalls when a call stops
+	// ringing. It is followed by EventCallAnswered if the call was
+	// answered; otherwise the call is over.
+	EventCallRingingStopped = "call.ringing_stopped"
+	// EventCallAnswered is published on TopicCalls when a call is answered.
+	EventCallAnswered = "call.answered"
+	// EventCallEnded is published on TopicCalls when an answered call ends.
+	EventCallEnded = "call.ended"
+
+	// EventRegistrationUp is published on TopicRegistrations when an
+	// extension registers its first device.
+	EventRegistrationUp = "registration.up"
+	// EventRegistrationDown is published on TopicRegistrations when an
+	// extension's last device unregisters or expires.
+	EventRegistrationDown = "registration.down"
+
+	// EventTrunkStatus is published on TopicTrunks when a trunk's status
+	// changes.
+	EventTrunkStatus = "trunk.status"
+
+	// EventConferenceJoin is published on TopicConferences when a
+	// participant joins a conference.
+	EventConferenceJoin = "conference.join"
+	// EventConferenceLeave is published on TopicConferences when a
+	// participant leaves a conference.
+	EventConferenceLeave = "conference.leave"
+
+	// EventVoicemailNew is published on TopicVoicemail when a voicemail
+	// message is left.
+	EventVoicemailNew = "voicemail.new"
+)
+
+// CallData is the payload of call events.
+type CallData struct {
+	CallID       string     `json:"call_id"`
+	State        string     `json:"state"`
+	Direction    string     `json:"direction,omitempty"`
+	CallerIDName string     `json:"caller_id_name"`
+	CallerIDNum  string     `json:"caller_id_num"`
+	CalledNum    string     `json:"called_num"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	AnswerTime   *time.Time `json:"answer_time,omitempty"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	HangupCause  string     `json:"hangup_cause,omitempty"`
+}
+
+// RegistrationData is the payload of registration events.
+type RegistrationData struct {
+	ExtensionID int64  `json:"extension_id"`
+	Extension   string `json:"extension"`
+	Name        string `json:"name"`
+	Registered  bool   `json:"registered"`
+}
+
+// TrunkData is the payload of trunk status events.
+type TrunkData struct {
+	TrunkID   int64  `json:"trunk_id"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// ConferenceData is the payload of conference events.
+type ConferenceData struct {
+	BridgeID      int64     `json:"bridge_id"`
+	BridgeName    string    `json:"bridge_name"`
+	ParticipantID string    `json:"participant_id"`
+	CallerIDName  string    `json:"caller_id_name"`
+	CallerIDNum   string    `json:"caller_id_num"`
+	JoinedAt      time.Time `json:"joined_at"`
+}
+
+// VoicemailData is the payload of voicemail events.
+type VoicemailData struct {
+	MessageID     int64  `json:"message_id"`
+	MailboxID     int64  `json:"mailbox_id"`
+	MailboxNumber string `json:"mailbox_number"`
+	CallerIDName  string `json:"caller_id_name"`
+	CallerIDNum   string `json:"caller_id_num"`
+	DurationSecs  int    `json:"duration_secs"`
+}
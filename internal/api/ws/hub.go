@@ -0,0 +1,189 @@
+// Package ws streams real-time PBX events (calls, registrations, trunk
+// status, conferences and voicemail) to the admin UI and integrations over
+// WebSocket, with a server-sent events fallback.
+package ws
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event topics. Clients subscribe to one or more topics.
+const (
+	TopicCalls         = "calls"
+	TopicRegistrations = "registrations"
+	TopicTrunks        = "trunks"
+	TopicConferences   = "conferences"
+	TopicVoicemail     = "voicemail"
+)
+
+// topics lists every topic a client may subscribe to.
+var topics = []string{TopicCalls, TopicRegistrations, TopicTrunks, TopicConferences, TopicVoicemail}
+
+// clientBuffer is the number of events queued for a client before it is
+// considered too slow and disconnected.
+const clientBuffer = 64
+
+// Event is a single real-time event delivered to subscribed clients.
+type Event struct {
+	// Type identifies the event, e.g. "call.answered".
+	Type string `json:"type"`
+	// Topic is the topic the event is published on.
+	Topic string `json:"topic"`
+	// Time is when the event occurred.
+	Time time.Time `json:"time"`
+	// Extensions lists the extension numbers the event concerns. Clients
+	// authenticated as an extension only receive events that list it.
+	Extensions []string `json:"extensions,omitempty"`
+	// Data holds the event payload, one of the *Data types in this package.
+	Data any `json:"data"`
+}
+
+// Subscription selects the events a client receives.
+type Subscription struct {
+	// Topics lists the topics to stream; empty means all topics.
+	Topics []string
+	// Extension, if set, limits the stream to events that concern this
+	// extension number. Used for clients authenticated with an app token.
+	Extension string
+}
+
+// ParseTopics parses a comma-separated topic list, as given in the topics
+// query parameter. An empty list selects all topics.
+func ParseTopics(s string) ([]string, error) {
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !slices.Contains(topics, t) {
+			return nil, fmt.Errorf("unknown topic %q", t)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// Hub fans published events out to connected clients. Publishing never
+// blocks: a client that falls clientBuffer events behind is disconnected.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+	logger  *slog.Logger
+}
+
+// NewHub creates an event hub with no clients.
+func NewHub(logger *slog.Logger) *Hub {
+	return &Hub{
+		clients: make(map[*client]struct{}),
+		logger:  logger.With("subsystem", "events"),
+	}
+}
+
+// Publish delivers an event to every client subscribed to its topic. Time
+// is set to now if zero. Safe to call from any goroutine, including with
+// other locks held.
+func (h *Hub) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if !c.wants(ev) {
+			continue
+		}
+		select {
+		case c.events <- ev:
+		default:
+			h.logger.Warn("event client too slow, disconnecting",
+				"extension", c.extension,
+			)
+			c.close()
+		}
+	}
+}
+
+// ClientCount returns the number of connected clients.
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// subscribe registers a new client for sub.
+func (h *Hub) subscribe(sub Subscription) *client {
+	c := &client{
+		extension: sub.Extension,
+		events:    make(chan Event, clientBuffer),
+		done:      make(chan struct{}),
+		topics:    make(map[string]bool),
+	}
+	if len(sub.Topics) == 0 {
+		c.setTopics(topics, true)
+	} else {
+		c.setTopics(sub.Topics, true)
+	}
+
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+// unsubscribe removes a client and closes it.
+func (h *Hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// client is one connected event stream.
+type client struct {
+	extension string
+	events    chan Event
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+// wants reports whether the client receives ev.
+func (c *client) wants(ev Event) bool {
+	if c.extension != "" && !slices.Contains(ev.Extensions, c.extension) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[ev.Topic]
+}
+
+// setTopics subscribes the client to (on is true) or unsubscribes it from
+// the given topics. Unknown topics are ignored.
+func (c *client) setTopics(list []string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range list {
+		if !slices.Contains(topics, t) {
+			continue
+		}
+		if on {
+			c.topics[t] = true
+		} else {
+			delete(c.topics, t)
+		}
+	}
+}
+
+// close signals the client's stream to end.
+func (c *client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
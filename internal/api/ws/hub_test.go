@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func testHub() *Hub {
+	return NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestParseTopics(t *testing.T) {
+	got, err := ParseTopics(" calls, trunks ,")
+	if err != nil {
+		t.Fatalf("ParseTopics: %v", err)
+	}
+	if len(got) != 2 || got[0] != TopicCalls || got[1] != TopicTrunks {
+		t.Errorf("topics = %v, want [calls trunks]", got)
+	}
+	if got, err := ParseTopics(""); err != nil || got != nil {
+		t.Errorf("empty list = %v, %v; want nil, nil", got, err)
+	}
+	if _, err := ParseTopics("calls,bogus"); err == nil {
+		t.Error("expected error for unknown topic")
+	}
+}
+
+func TestHubPublishFiltering(t *testing.T) {
+	hub := testHub()
+	all := hub.subscribe(Subscription{})
+	calls := hub.subscribe(Subscription{Topics: []string{TopicCalls}})
+	ext := hub.subscribe(Subscription{Extension: "101"})
+
+	hub.Publish(Event{Type: EventCallAnswered, Topic: TopicCalls, Extensions: []string{"100", "101"}})
+	hub.Publish(Event{Type: EventTrunkStatus, Topic: TopicTrunks})
+	hub.Publish(Event{Type: EventCallRinging, Topic: TopicCalls, Extensions: []string{"102"}})
+
+	tests := []struct {
+		name string
+		c    *client
+		want []string
+	}{
+		{"all topics", all, []string{EventCallAnswered, EventTrunkStatus, EventCallRinging}},
+		{"calls only", calls, []string{EventCallAnswered, EventCallRinging}},
+		{"extension", ext, []string{EventCallAnswered}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for len(tt.c.events) > 0 {
+			ev := <-tt.c.events
+			if ev.Time.IsZero() {
+				t.Errorf("%s: event time not set", tt.name)
+			}
+			got = append(got, ev.Type)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	calls.setTopics([]string{TopicCalls}, false)
+	calls.setTopics([]string{TopicVoicemail}, true)
+	hub.Publish(Event{Type: EventCallEnded, Topic: TopicCalls})
+	hub.Publish(Event{Type: EventVoicemailNew, Topic: TopicVoicemail})
+	if ev := <-calls.events; ev.Type != EventVoicemailNew || len(calls.events) != 0 {
+		t.Errorf("after resubscribing got %s, want only %s", ev.Type, EventVoicemailNew)
+	}
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	hub := testHub()
+	c := hub.subscribe(Subscription{})
+
+	for range clientBuffer + 1 {
+		hub.Publish(Event{Type: EventTrunkStatus, Topic: TopicTrunks})
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("slow client was not closed")
+	}
+
+	hub.unsubscribe(c)
+	if n := hub.ClientCount(); n != 0 {
+		t.Errorf("client count = %d, want 0", n)
+	}
+}
@@ -0,0 +1,67 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ServeSSE streams events for sub as server-sent events until the client
+// disconnects. Each event is sent with its type as the SSE event name and
+// its JSON encoding as the data line. It is the fallback for clients that
+// cannot open a WebSocket.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request, sub Subscription) {
+	rc := http.NewResponseController(w)
+
+	// The stream outlives the HTTP server's read and write timeouts.
+	rc.SetReadDeadline(time.Time{})  //nolint:errcheck
+	rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.logger.Error("event stream not supported", "error", err)
+		return
+	}
+
+	c := h.subscribe(sub)
+	defer h.unsubscribe(c)
+
+	h.logger.Debug("sse client connected",
+		"remote_addr", r.RemoteAddr,
+		"extension", sub.Extension,
+	)
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			return
+		case ev := <-c.events:
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				h.logger.Error("failed to encode event", "type", ev.Type, "error", err)
+				continue
+			}
+			rc.SetWriteDeadline(time.Now().Add(writeTimeout)) //nolint:errcheck
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(writeTimeout)) //nolint:errcheck
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeSSE(t *testing.T) {
+	hub := testHub()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeSSE(w, r, Subscription{Extension: "101"})
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+	waitForClients(t, hub, 1)
+
+	hub.Publish(Event{Type: EventRegistrationUp, Topic: TopicRegistrations, Extensions: []string{"100"}})
+	hub.Publish(Event{Type: EventRegistrationUp, Topic: TopicRegistrations, Extensions: []string{"101"}, Data: RegistrationData{Extension: "101"}})
+
+	rd := bufio.NewReader(res.Body)
+	name, _ := rd.ReadString('\n')
+	data, _ := rd.ReadString('\n')
+	if name != "event: registration.up\n" {
+		t.Fatalf("event line = %q", name)
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &ev); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if len(ev.Extensions) != 1 || ev.Extensions[0] != "101" {
+		t.Errorf("got event for %v, want only extension 101's", ev.Extensions)
+	}
+}
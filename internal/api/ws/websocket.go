@@ -0,0 +1,150 @@
+package ws
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	// pingInterval is how often an idle stream is kept alive: a ping frame
+	// for WebSocket clients, a comment line for SSE clients.
+	pingInterval = 30 * time.Second
+
+	// writeTimeout bounds each write to a client.
+	writeTimeout = 10 * time.Second
+
+	// maxCommandSize is the largest message a WebSocket client may send.
+	maxCommandSize = 4096
+)
+
+// command is a message sent by a WebSocket client to change its topics.
+type command struct {
+	Action string   `json:"action"` // "subscribe" or "unsubscribe"
+	Topics []string `json:"topics"`
+}
+
+// IsWebSocketUpgrade reports whether r asks to upgrade to a WebSocket.
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// ServeWebSocket upgrades the request to a WebSocket and streams events
+// for sub as JSON text messages until the client disconnects. The client
+// may send {"action": "subscribe"|"unsubscribe", "topics": [...]} to
+// change its topics.
+func (h *Hub) ServeWebSocket(w http.ResponseWriter, r *http.Request, sub Subscription) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		h.logger.Debug("websocket upgrade failed", "remote_addr", r.RemoteAddr, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// The HTTP server's read and write timeouts still apply to the
+	// hijacked connection; the stream sets its own write deadlines.
+	conn.SetDeadline(time.Time{}) //nolint:errcheck
+
+	c := h.subscribe(sub)
+	defer h.unsubscribe(c)
+
+	h.logger.Debug("websocket client connected",
+		"remote_addr", r.RemoteAddr,
+		"extension", sub.Extension,
+	)
+
+	// All writes happen on this goroutine; the reader hands it pongs.
+	pongs := make(chan []byte, 1)
+	go h.readWebSocket(conn, c, pongs)
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		var frame ws.Frame
+		select {
+		case <-c.done:
+			writeFrame(conn, ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusGoingAway, ""))) //nolint:errcheck
+			return
+		case ev := <-c.events:
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				h.logger.Error("failed to encode event", "type", ev.Type, "error", err)
+				continue
+			}
+			frame = ws.NewTextFrame(payload)
+		case p := <-pongs:
+			frame = ws.NewPongFrame(p)
+		case <-ticker.C:
+			frame = ws.NewPingFrame(nil)
+		}
+		if err := writeFrame(conn, frame); err != nil {
+			h.logger.Debug("websocket write failed", "remote_addr", r.RemoteAddr, "error", err)
+			return
+		}
+	}
+}
+
+// readWebSocket reads client frames until the connection closes, applying
+// topic commands and passing ping payloads to the writer. It closes the
+// client when it returns.
+func (h *Hub) readWebSocket(conn net.Conn, c *client, pongs chan<- []byte) {
+	defer c.close()
+
+	rd := &wsutil.Reader{
+		Source:    conn,
+		State:     ws.StateServerSide,
+		CheckUTF8: true,
+	}
+	for {
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return
+		}
+		if hdr.Length > maxCommandSize {
+			return
+		}
+		payload, err := io.ReadAll(rd)
+		if err != nil {
+			return
+		}
+
+		switch hdr.OpCode {
+		case ws.OpClose:
+			return
+		case ws.OpPing:
+			select {
+			case pongs <- payload:
+			default:
+			}
+		case ws.OpText:
+			var cmd command
+			if err := json.Unmarshal(payload, &cmd); err != nil {
+				h.logger.Debug("ignoring malformed websocket command", "error", err)
+				continue
+			}
+			switch cmd.Action {
+			case "subscribe":
+				c.setTopics(cmd.Topics, true)
+			case "unsubscribe":
+				c.setTopics(cmd.Topics, false)
+			default:
+				h.logger.Debug("ignoring unknown websocket command", "action", cmd.Action)
+			}
+		}
+	}
+}
+
+// writeFrame writes one frame to conn within writeTimeout.
+func writeFrame(conn net.Conn, f ws.Frame) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return ws.WriteFrame(conn, f)
+}
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// waitForClients waits until the hub has n clients.
+func waitForClients(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.ClientCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("client count = %d, want %d", hub.ClientCount(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func readEvent(t *testing.T, conn net.Conn) Event {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	msg, err := wsutil.ReadServerText(conn)
+	if err != nil {
+		t.Fatalf("reading event: %v", err)
+	}
+	var ev Event
+	if err := json.Unmarshal(msg, &ev); err != nil {
+		t.Fatalf("decoding event %q: %v", msg, err)
+	}
+	return ev
+}
+
+func TestServeWebSocket(t *testing.T) {
+	hub := testHub()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsWebSocketUpgrade(r) {
+			t.Error("request is not a websocket upgrade")
+		}
+		hub.ServeWebSocket(w, r, Subscription{Topics: []string{TopicTrunks}})
+	}))
+	defer srv.Close()
+
+	conn, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	waitForClients(t, hub, 1)
+
+	hub.Publish(Event{Type: EventCallRinging, Topic: TopicCalls})
+	hub.Publish(Event{Type: EventTrunkStatus, Topic: TopicTrunks, Data: TrunkData{Name: "carrier", Status: "registered"}})
+	if ev := readEvent(t, conn); ev.Type != EventTrunkStatus || ev.Topic != TopicTrunks {
+		t.Fatalf("got %s on %s, want the trunk event", ev.Type, ev.Topic)
+	}
+
+	// Switch topics with a command.
+	cmd := `{"action":"subscribe","topics":["calls"]}`
+	if err := wsutil.WriteClientText(conn, []byte(cmd)); err != nil {
+		t.Fatalf("writing command: %v", err)
+	}
+	cmd = `{"action":"unsubscribe","topics":["trunks"]}`
+	if err := wsutil.WriteClientText(conn, []byte(cmd)); err != nil {
+		t.Fatalf("writing command: %v", err)
+	}
+
+	// The commands are applied asynchronously; publish until one arrives.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hub.Publish(Event{Type: EventCallAnswered, Topic: TopicCalls})
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		msg, err := wsutil.ReadServerText(conn)
+		if err == nil {
+			var ev Event
+			json.Unmarshal(msg, &ev) //nolint:errcheck
+			if ev.Type != EventCallAnswered {
+				t.Fatalf("got %s, want %s", ev.Type, EventCallAnswered)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no call event after subscribing")
+		}
+	}
+
+	conn.Close()
+	waitForClients(t, hub, 0)
+}
@@ -33,6 +33,11 @@ type Config struct {
 	ACMEDomain     string // domain for automatic Let's Encrypt certificate (e.g., "pbx.example.com")
 	ACMEEmail      string // contact email for Let's Encrypt account notifications
 	LogFormat      string // log output format: "text" or "json"
+	TTSEngine      string // text-to-speech engine: "command", "http", or empty to disable
+	TTSCommand     string // command line for the "command" TTS engine (e.g., "espeak-ng --stdout --stdin")
+	TTSURL         string // endpoint for the "http" TTS engine
+	TTSVoice       string // voice passed to the TTS engine
+	TTSAPIKey      string // bearer token for the "http" TTS engine
 }
 
 // defaults
@@ -75,6 +80,11 @@ func Load() (*Config, error) {
 	fs.StringVar(&cfg.ACMEDomain, "acme-domain", "", "domain for automatic Let's Encrypt TLS certificate (e.g., pbx.example.com)")
 	fs.StringVar(&cfg.ACMEEmail, "acme-email", "", "contact email for Let's Encrypt account notifications")
 	fs.StringVar(&cfg.LogFormat, "log-format", defaultLogFormat, "log output format (text, json)")
+	fs.StringVar(&cfg.TTSEngine, "tts-engine", "", "text-to-speech engine for TTS prompts (command, http; empty disables)")
+	fs.StringVar(&cfg.TTSCommand, "tts-command", "", "command line for the command TTS engine; {text}, {voice} and {output} are substituted")
+	fs.StringVar(&cfg.TTSURL, "tts-url", "", "URL of the http TTS engine")
+	fs.StringVar(&cfg.TTSVoice, "tts-voice", "", "voice passed to the TTS engine")
+	fs.StringVar(&cfg.TTSAPIKey, "tts-api-key", "", "bearer token for the http TTS engine")
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return nil, fmt.Errorf("parsing flags: %w", err)
@@ -121,6 +131,11 @@ func applyEnvOverrides(fs *flag.FlagSet, cfg *Config) {
 		"acme-domain":      envPrefix + "ACME_DOMAIN",
 		"acme-email":       envPrefix + "ACME_EMAIL",
 		"log-format":       envPrefix + "LOG_FORMAT",
+		"tts-engine":       envPrefix + "TTS_ENGINE",
+		"tts-command":      envPrefix + "TTS_COMMAND",
+		"tts-url":          envPrefix + "TTS_URL",
+		"tts-voice":        envPrefix + "TTS_VOICE",
+		"tts-api-key":      envPrefix + "TTS_API_KEY",
 	}
 
 	for flagName, envVar := range envMap {
@@ -178,6 +193,16 @@ func applyEnvOverrides(fs *flag.FlagSet, cfg *Config) {
 			cfg.ACMEEmail = val
 		case "log-format":
 			cfg.LogFormat = val
+		case "tts-engine":
+			cfg.TTSEngine = val
+		case "tts-command":
+			cfg.TTSCommand = val
+		case "tts-url":
+			cfg.TTSURL = val
+		case "tts-voice":
+			cfg.TTSVoice = val
+		case "tts-api-key":
+			cfg.TTSAPIKey = val
 		}
 	}
 }
@@ -225,6 +250,21 @@ func (c *Config) validate() error {
 		return fmt.Errorf("acme-domain and tls-cert/tls-key are mutually exclusive")
 	}
 
+	c.TTSEngine = strings.ToLower(c.TTSEngine)
+	switch c.TTSEngine {
+	case "":
+	case "command":
+		if c.TTSCommand == "" {
+			return fmt.Errorf("tts-engine command requires tts-command")
+		}
+	case "http":
+		if c.TTSURL == "" {
+			return fmt.Errorf("tts-engine http requires tts-url")
+		}
+	default:
+		return fmt.Errorf("tts-engine must be one of command, http; got %q", c.TTSEngine)
+	}
+
 	return nil
 }
 
@@ -111,6 +111,27 @@ func TestValidateTLSMismatch(t *testing.T) {
 	}
 }
 
+func TestValidateTTSEngine(t *testing.T) {
+	os.Args = []string{"flowpbx", "--tts-engine", "http"}
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error when tts-engine http provided without tts-url")
+	}
+
+	os.Args = []string{"flowpbx", "--tts-engine", "festival"}
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for unknown tts engine")
+	}
+
+	os.Args = []string{"flowpbx", "--tts-engine", "command", "--tts-command", "espeak-ng --stdout {text}"}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TTSEngine != "command" || cfg.TTSCommand != "espeak-ng --stdout {text}" {
+		t.Errorf("tts config = %q %q", cfg.TTSEngine, cfg.TTSCommand)
+	}
+}
+
 func TestSlogLevel(t *testing.T) {
 	tests := []struct {
 		level string
@@ -21,9 +21,11 @@ func NewAdminUserRepository(db *DB) AdminUserRepository {
 // Create inserts a new admin user.
 func (r *adminUserRepo) Create(ctx context.Context, user *models.AdminUser) error {
 	result, err := r.db.ExecContext(ctx,
-		`INSERT INTO admin_users (username, password_hash, totp_secret, created_at, updated_at)
-		 VALUES (?, ?, ?, datetime('now'), datetime('now'))`,
-		user.Username, user.PasswordHash, user.TOTPSecret,
+		`INSERT INTO admin_users (username, password_hash, role, totp_secret, totp_enabled,
+		                          totp_last_step, recovery_codes, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		user.Username, user.PasswordHash, user.Role, user.TOTPSecret, user.TOTPEnabled,
+		user.TOTPLastStep, recoveryCodesOrEmpty(user.RecoveryCodes),
 	)
 	if err != nil {
 		return fmt.Errorf("inserting admin user: %w", err)
@@ -41,9 +43,11 @@ func (r *adminUserRepo) Create(ctx context.Context, user *models.AdminUser) erro
 func (r *adminUserRepo) GetByID(ctx context.Context, id int64) (*models.AdminUser, error) {
 	var u models.AdminUser
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, username, password_hash, totp_secret, created_at, updated_at
+		`SELECT id, username, password_hash, role, totp_secret, totp_enabled,
+		        totp_last_step, recovery_codes, created_at, updated_at
 		 FROM admin_users WHERE id = ?`, id,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.TOTPSecret, &u.CreatedAt, &u.UpdatedAt)
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.TOTPSecret, &u.TOTPEnabled,
+		&u.TOTPLastStep, &u.RecoveryCodes, &u.CreatedAt, &u.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -57,9 +61,11 @@ func (r *adminUserRepo) GetByID(ctx context.Context, id int64) (*models.AdminUse
 func (r *adminUserRepo) GetByUsername(ctx context.Context, username string) (*models.AdminUser, error) {
 	var u models.AdminUser
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, username, password_hash, totp_secret, created_at, updated_at
+		`SELECT id, username, password_hash, role, totp_secret, totp_enabled,
+		        totp_last_step, recovery_codes, created_at, updated_at
 		 FROM admin_users WHERE username = ?`, username,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.TOTPSecret, &u.CreatedAt, &u.UpdatedAt)
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.TOTPSecret, &u.TOTPEnabled,
+		&u.TOTPLastStep, &u.RecoveryCodes, &u.CreatedAt, &u.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -72,7 +78,8 @@ func (r *adminUserRepo) GetByUsername(ctx context.Context, username string) (*mo
 // List returns all admin users.
 func (r *adminUserRepo) List(ctx context.Context) ([]models.AdminUser, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, username, password_hash, totp_secret, created_at, updated_at
+		`SELECT id, username, password_hash, role, totp_secret, totp_enabled,
+		        totp_last_step, recovery_codes, created_at, updated_at
 		 FROM admin_users ORDER BY username`)
 	if err != nil {
 		return nil, fmt.Errorf("querying admin users: %w", err)
@@ -82,7 +89,8 @@ func (r *adminUserRepo) List(ctx context.Context) ([]models.AdminUser, error) {
 	var users []models.AdminUser
 	for rows.Next() {
 		var u models.AdminUser
-		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.TOTPSecret, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.TOTPSecret, &u.TOTPEnabled,
+			&u.TOTPLastStep, &u.RecoveryCodes, &u.CreatedAt, &u.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scanning admin user row: %w", err)
 		}
 		users = append(users, u)
@@ -93,9 +101,11 @@ func (r *adminUserRepo) List(ctx context.Context) ([]models.AdminUser, error) {
 // Update modifies an existing admin user.
 func (r *adminUserRepo) Update(ctx context.Context, user *models.AdminUser) error {
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE admin_users SET username = ?, password_hash = ?, totp_secret = ?, updated_at = datetime('now')
+		`UPDATE admin_users SET username = ?, password_hash = ?, role = ?, totp_secret = ?,
+		 totp_enabled = ?, totp_last_step = ?, recovery_codes = ?, updated_at = datetime('now')
 		 WHERE id = ?`,
-		user.Username, user.PasswordHash, user.TOTPSecret, user.ID,
+		user.Username, user.PasswordHash, user.Role, user.TOTPSecret,
+		user.TOTPEnabled, user.TOTPLastStep, recoveryCodesOrEmpty(user.RecoveryCodes), user.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("updating admin user: %w", err)
@@ -121,3 +131,63 @@ func (r *adminUserRepo) Count(ctx context.Context) (int64, error) {
 	}
 	return count, nil
 }
+
+// CountByRole returns the number of admin users with the given role.
+func (r *adminUserRepo) CountByRole(ctx context.Context, role string) (int64, error) {
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM admin_users WHERE role = ?`, role).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting admin users by role: %w", err)
+	}
+	return count, nil
+}
+
+// ClaimTOTPStep records step as the time step of the last TOTP code the
+// user logged in with. It returns false, without recording it, if a code
+// of the same or a later step was already used, so that a code cannot be
+// replayed.
+func (r *adminUserRepo) ClaimTOTPStep(ctx context.Context, id, step int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE admin_users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`,
+		step, id, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("claiming totp step: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("getting rows affected: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ClaimRecoveryCode removes the recovery code with the given hash from the
+// user's unused codes. It returns false if the code is not among them, e.g.
+// because a concurrent login has just used it, so that each code logs in
+// only once.
+func (r *adminUserRepo) ClaimRecoveryCode(ctx context.Context, id int64, hash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE admin_users SET
+		 recovery_codes = (SELECT json_group_array(value) FROM json_each(admin_users.recovery_codes) WHERE value != ?),
+		 updated_at = datetime('now')
+		 WHERE id = ? AND EXISTS (SELECT 1 FROM json_each(admin_users.recovery_codes) WHERE value = ?)`,
+		hash, id, hash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("claiming recovery code: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("getting rows affected: %w", err)
+	}
+	return n == 1, nil
+}
+
+// recoveryCodesOrEmpty returns the recovery codes column value, an empty
+// JSON array if unset.
+func recoveryCodesOrEmpty(codes string) string {
+	if codes == "" {
+		return "[]"
+	}
+	return codes
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// callerFilterRepo implements CallerFilterRepository.
+type callerFilterRepo struct {
+	db *DB
+}
+
+// NewCallerFilterRepository creates a new CallerFilterRepository.
+func NewCallerFilterRepository(db *DB) CallerFilterRepository {
+	return &callerFilterRepo{db: db}
+}
+
+// Create inserts a new caller filter entry.
+func (r *callerFilterRepo) Create(ctx context.Context, f *models.CallerFilter) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO caller_filters (inbound_number_id, list, match_type, pattern,
+		 action, voicemail_box_id, flow_id, flow_node, description, enabled,
+		 created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		f.InboundNumberID, f.List, f.MatchType, f.Pattern, f.Action,
+		f.VoicemailBoxID, f.FlowID, f.FlowNode, f.Description, f.Enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting caller filter: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting last insert id: %w", err)
+	}
+	f.ID = id
+	return nil
+}
+
+// GetByID returns a caller filter entry by ID.
+func (r *callerFilterRepo) GetByID(ctx context.Context, id int64) (*models.CallerFilter, error) {
+	return r.scanOne(r.db.QueryRowContext(ctx,
+		`SELECT id, inbound_number_id, list, match_type, pattern, action,
+		 voicemail_box_id, flow_id, flow_node, description, enabled,
+		 created_at, updated_at
+		 FROM caller_filters WHERE id = ?`, id,
+	))
+}
+
+// List returns all caller filter entries ordered by list then ID.
+func (r *callerFilterRepo) List(ctx context.Context) ([]models.CallerFilter, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, inbound_number_id, list, match_type, pattern, action,
+		 voicemail_box_id, flow_id, flow_node, description, enabled,
+		 created_at, updated_at
+		 FROM caller_filters ORDER BY list, id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying caller filters: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// ListEnabled returns all enabled caller filter entries, global and per
+// inbound number, ordered by ID.
+func (r *callerFilterRepo) ListEnabled(ctx context.Context) ([]models.CallerFilter, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, inbound_number_id, list, match_type, pattern, action,
+		 voicemail_box_id, flow_id, flow_node, description, enabled,
+		 created_at, updated_at
+		 FROM caller_filters WHERE enabled = 1 ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying enabled caller filters: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// Update modifies an existing caller filter entry.
+func (r *callerFilterRepo) Update(ctx context.Context, f *models.CallerFilter) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE caller_filters SET inbound_number_id = ?, list = ?, match_type = ?,
+		 pattern = ?, action = ?, voicemail_box_id = ?, flow_id = ?, flow_node = ?,
+		 description = ?, enabled = ?, updated_at = datetime('now')
+		 WHERE id = ?`,
+		f.InboundNumberID, f.List, f.MatchType, f.Pattern, f.Action,
+		f.VoicemailBoxID, f.FlowID, f.FlowNode, f.Description, f.Enabled, f.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating caller filter: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a caller filter entry by ID.
+func (r *callerFilterRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM caller_filters WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting caller filter: %w", err)
+	}
+	return nil
+}
+
+func (r *callerFilterRepo) scanOne(row *sql.Row) (*models.CallerFilter, error) {
+	var f models.CallerFilter
+	err := row.Scan(&f.ID, &f.InboundNumberID, &f.List, &f.MatchType, &f.Pattern,
+		&f.Action, &f.VoicemailBoxID, &f.FlowID, &f.FlowNode, &f.Description,
+		&f.Enabled, &f.CreatedAt, &f.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning caller filter: %w", err)
+	}
+	return &f, nil
+}
+
+func (r *callerFilterRepo) scanMany(rows *sql.Rows) ([]models.CallerFilter, error) {
+	var filters []models.CallerFilter
+	for rows.Next() {
+		var f models.CallerFilter
+		if err := rows.Scan(&f.ID, &f.InboundNumberID, &f.List, &f.MatchType, &f.Pattern,
+			&f.Action, &f.VoicemailBoxID, &f.FlowID, &f.FlowNode, &f.Description,
+			&f.Enabled, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning caller filter row: %w", err)
+		}
+		filters = append(filters, f)
+	}
+	return filters, rows.Err()
+}
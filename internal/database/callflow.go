@@ -8,6 +8,11 @@ import (
 	"github.com/flowpbx/flowpbx/internal/database/models"
 )
 
+// callFlowColumns are the columns scanned into a models.CallFlow, from
+// call_flows f joined with its pinned revision r.
+const callFlowColumns = `f.id, f.name, f.flow_data, f.version, f.published, f.published_at,
+		 f.created_at, f.updated_at, f.published_revision_id, COALESCE(r.revision, 0)`
+
 // callFlowRepo implements CallFlowRepository.
 type callFlowRepo struct {
 	db *DB
@@ -41,27 +46,32 @@ func (r *callFlowRepo) Create(ctx context.Context, flow *models.CallFlow) error
 // GetByID returns a call flow by ID.
 func (r *callFlowRepo) GetByID(ctx context.Context, id int64) (*models.CallFlow, error) {
 	return r.scanOne(r.db.QueryRowContext(ctx,
-		`SELECT id, name, flow_data, version, published, published_at,
-		 created_at, updated_at
-		 FROM call_flows WHERE id = ?`, id,
+		`SELECT `+callFlowColumns+`
+		 FROM call_flows f
+		 LEFT JOIN call_flow_revisions r ON r.id = f.published_revision_id
+		 WHERE f.id = ?`, id,
 	))
 }
 
-// GetPublished returns a call flow by ID only if it is published.
+// GetPublished returns a call flow by ID only if it is published, with
+// FlowData set to the graph of its pinned revision rather than the draft.
 func (r *callFlowRepo) GetPublished(ctx context.Context, id int64) (*models.CallFlow, error) {
 	return r.scanOne(r.db.QueryRowContext(ctx,
-		`SELECT id, name, flow_data, version, published, published_at,
-		 created_at, updated_at
-		 FROM call_flows WHERE id = ? AND published = 1`, id,
+		`SELECT f.id, f.name, r.flow_data, f.version, f.published, f.published_at,
+		 f.created_at, f.updated_at, f.published_revision_id, r.revision
+		 FROM call_flows f
+		 JOIN call_flow_revisions r ON r.id = f.published_revision_id
+		 WHERE f.id = ? AND f.published = 1`, id,
 	))
 }
 
 // List returns all call flows ordered by name.
 func (r *callFlowRepo) List(ctx context.Context) ([]models.CallFlow, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, name, flow_data, version, published, published_at,
-		 created_at, updated_at
-		 FROM call_flows ORDER BY name`)
+		`SELECT `+callFlowColumns+`
+		 FROM call_flows f
+		 LEFT JOIN call_flow_revisions r ON r.id = f.published_revision_id
+		 ORDER BY f.name`)
 	if err != nil {
 		return nil, fmt.Errorf("querying call flows: %w", err)
 	}
@@ -71,7 +81,8 @@ func (r *callFlowRepo) List(ctx context.Context) ([]models.CallFlow, error) {
 	for rows.Next() {
 		var f models.CallFlow
 		if err := rows.Scan(&f.ID, &f.Name, &f.FlowData, &f.Version,
-			&f.Published, &f.PublishedAt, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			&f.Published, &f.PublishedAt, &f.CreatedAt, &f.UpdatedAt,
+			&f.PublishedRevisionID, &f.PublishedRevision); err != nil {
 			return nil, fmt.Errorf("scanning call flow row: %w", err)
 		}
 		flows = append(flows, f)
@@ -93,17 +104,154 @@ func (r *callFlowRepo) Update(ctx context.Context, flow *models.CallFlow) error
 	return nil
 }
 
-// Publish marks a call flow as published, snapshotting the current time.
-func (r *callFlowRepo) Publish(ctx context.Context, id int64) error {
-	_, err := r.db.ExecContext(ctx,
+// Publish stores the current draft of a call flow as a new revision by
+// author and pins live calls to it. Returns the new revision, or nil if
+// the flow does not exist.
+func (r *callFlowRepo) Publish(ctx context.Context, id int64, author string) (*models.CallFlowRevision, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning call flow publish: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name, data string
+	err = tx.QueryRowContext(ctx, `SELECT name, flow_data FROM call_flows WHERE id = ?`, id).Scan(&name, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying call flow to publish: %w", err)
+	}
+
+	revID, err := insertCallFlowRevision(ctx, tx, id, name, data, author, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
 		`UPDATE call_flows SET published = 1, published_at = datetime('now'),
-		 updated_at = datetime('now')
-		 WHERE id = ?`, id,
+		 published_revision_id = ?, updated_at = datetime('now')
+		 WHERE id = ?`, revID, id,
+	); err != nil {
+		return nil, fmt.Errorf("publishing call flow: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing call flow publish: %w", err)
+	}
+	return r.getRevisionByID(ctx, revID)
+}
+
+// Rollback publishes a copy of an earlier revision of a call flow as a new
+// revision by author and resets the draft to it, so the next publish does
+// not bring back the rolled back changes. Returns the new revision, or nil
+// if the flow has no such revision.
+func (r *callFlowRepo) Rollback(ctx context.Context, id int64, revision int, author string) (*models.CallFlowRevision, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning call flow rollback: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name, data string
+	err = tx.QueryRowContext(ctx,
+		`SELECT f.name, r.flow_data FROM call_flow_revisions r
+		 JOIN call_flows f ON f.id = r.flow_id
+		 WHERE r.flow_id = ? AND r.revision = ?`, id, revision,
+	).Scan(&name, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying call flow revision to restore: %w", err)
+	}
+
+	revID, err := insertCallFlowRevision(ctx, tx, id, name, data, author, &revision)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE call_flows SET flow_data = ?, version = version + 1, published = 1,
+		 published_at = datetime('now'), published_revision_id = ?, updated_at = datetime('now')
+		 WHERE id = ?`, data, revID, id,
+	); err != nil {
+		return nil, fmt.Errorf("rolling back call flow: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing call flow rollback: %w", err)
+	}
+	return r.getRevisionByID(ctx, revID)
+}
+
+// insertCallFlowRevision stores the next revision of a call flow and
+// returns its ID. The revision number is computed in the insert itself so
+// that concurrent publishes cannot take the same number.
+func insertCallFlowRevision(ctx context.Context, tx *sql.Tx, flowID int64, name, data, author string, restoredFrom *int) (int64, error) {
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO call_flow_revisions (flow_id, revision, name, flow_data, author, restored_from, created_at)
+		 SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ?, datetime('now')
+		 FROM call_flow_revisions WHERE flow_id = ?`,
+		flowID, name, data, author, restoredFrom, flowID,
 	)
 	if err != nil {
-		return fmt.Errorf("publishing call flow: %w", err)
+		return 0, fmt.Errorf("inserting call flow revision: %w", err)
 	}
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("getting last insert id: %w", err)
+	}
+	return id, nil
+}
+
+// ListRevisions returns the revisions of a call flow, newest first.
+func (r *callFlowRepo) ListRevisions(ctx context.Context, flowID int64) ([]models.CallFlowRevision, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, flow_id, revision, name, flow_data, author, restored_from, created_at
+		 FROM call_flow_revisions WHERE flow_id = ? ORDER BY revision DESC`, flowID)
+	if err != nil {
+		return nil, fmt.Errorf("querying call flow revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revs []models.CallFlowRevision
+	for rows.Next() {
+		var v models.CallFlowRevision
+		if err := rows.Scan(&v.ID, &v.FlowID, &v.Revision, &v.Name, &v.FlowData,
+			&v.Author, &v.RestoredFrom, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning call flow revision row: %w", err)
+		}
+		revs = append(revs, v)
+	}
+	return revs, rows.Err()
+}
+
+// GetRevision returns a revision of a call flow by number.
+func (r *callFlowRepo) GetRevision(ctx context.Context, flowID int64, revision int) (*models.CallFlowRevision, error) {
+	return r.scanRevision(r.db.QueryRowContext(ctx,
+		`SELECT id, flow_id, revision, name, flow_data, author, restored_from, created_at
+		 FROM call_flow_revisions WHERE flow_id = ? AND revision = ?`, flowID, revision,
+	))
+}
+
+// getRevisionByID returns a call flow revision by its row ID.
+func (r *callFlowRepo) getRevisionByID(ctx context.Context, id int64) (*models.CallFlowRevision, error) {
+	return r.scanRevision(r.db.QueryRowContext(ctx,
+		`SELECT id, flow_id, revision, name, flow_data, author, restored_from, created_at
+		 FROM call_flow_revisions WHERE id = ?`, id,
+	))
+}
+
+func (r *callFlowRepo) scanRevision(row *sql.Row) (*models.CallFlowRevision, error) {
+	var v models.CallFlowRevision
+	err := row.Scan(&v.ID, &v.FlowID, &v.Revision, &v.Name, &v.FlowData,
+		&v.Author, &v.RestoredFrom, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning call flow revision: %w", err)
+	}
+	return &v, nil
 }
 
 // Delete removes a call flow by ID.
@@ -118,7 +266,8 @@ func (r *callFlowRepo) Delete(ctx context.Context, id int64) error {
 func (r *callFlowRepo) scanOne(row *sql.Row) (*models.CallFlow, error) {
 	var f models.CallFlow
 	err := row.Scan(&f.ID, &f.Name, &f.FlowData, &f.Version,
-		&f.Published, &f.PublishedAt, &f.CreatedAt, &f.UpdatedAt)
+		&f.Published, &f.PublishedAt, &f.CreatedAt, &f.UpdatedAt,
+		&f.PublishedRevisionID, &f.PublishedRevision)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 
 	"github.com/flowpbx/flowpbx/internal/database/models"
 )
@@ -23,12 +24,13 @@ func (r *cdrRepo) Create(ctx context.Context, cdr *models.CDR) error {
 	result, err := r.db.ExecContext(ctx,
 		`INSERT INTO cdrs (call_id, start_time, answer_time, end_time, duration,
 		 billable_dur, caller_id_name, caller_id_num, callee, trunk_id,
-		 direction, disposition, recording_file, flow_path, hangup_cause)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		 direction, disposition, recording_file, flow_path, hangup_cause,
+		 transferred_to, rate, cost)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		cdr.CallID, cdr.StartTime, cdr.AnswerTime, cdr.EndTime, cdr.Duration,
 		cdr.BillableDur, cdr.CallerIDName, cdr.CallerIDNum, cdr.Callee,
 		cdr.TrunkID, cdr.Direction, cdr.Disposition, cdr.RecordingFile,
-		cdr.FlowPath, cdr.HangupCause,
+		cdr.FlowPath, cdr.HangupCause, cdr.TransferredTo, cdr.Rate, cdr.Cost,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting cdr: %w", err)
@@ -47,7 +49,8 @@ func (r *cdrRepo) GetByID(ctx context.Context, id int64) (*models.CDR, error) {
 	return r.scanOne(r.db.QueryRowContext(ctx,
 		`SELECT id, call_id, start_time, answer_time, end_time, duration,
 		 billable_dur, caller_id_name, caller_id_num, callee, trunk_id,
-		 direction, disposition, recording_file, flow_path, hangup_cause
+		 direction, disposition, recording_file, flow_path, hangup_cause,
+		 transferred_to, rate, cost
 		 FROM cdrs WHERE id = ?`, id,
 	))
 }
@@ -57,7 +60,8 @@ func (r *cdrRepo) GetByCallID(ctx context.Context, callID string) (*models.CDR,
 	return r.scanOne(r.db.QueryRowContext(ctx,
 		`SELECT id, call_id, start_time, answer_time, end_time, duration,
 		 billable_dur, caller_id_name, caller_id_num, callee, trunk_id,
-		 direction, disposition, recording_file, flow_path, hangup_cause
+		 direction, disposition, recording_file, flow_path, hangup_cause,
+		 transferred_to, rate, cost
 		 FROM cdrs WHERE call_id = ?`, callID,
 	))
 }
@@ -68,12 +72,13 @@ func (r *cdrRepo) Update(ctx context.Context, cdr *models.CDR) error {
 		`UPDATE cdrs SET call_id = ?, start_time = ?, answer_time = ?, end_time = ?,
 		 duration = ?, billable_dur = ?, caller_id_name = ?, caller_id_num = ?,
 		 callee = ?, trunk_id = ?, direction = ?, disposition = ?,
-		 recording_file = ?, flow_path = ?, hangup_cause = ?
+		 recording_file = ?, flow_path = ?, hangup_cause = ?,
+		 transferred_to = ?, rate = ?, cost = ?
 		 WHERE id = ?`,
 		cdr.CallID, cdr.StartTime, cdr.AnswerTime, cdr.EndTime, cdr.Duration,
 		cdr.BillableDur, cdr.CallerIDName, cdr.CallerIDNum, cdr.Callee,
 		cdr.TrunkID, cdr.Direction, cdr.Disposition, cdr.RecordingFile,
-		cdr.FlowPath, cdr.HangupCause, cdr.ID,
+		cdr.FlowPath, cdr.HangupCause, cdr.TransferredTo, cdr.Rate, cdr.Cost, cdr.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("updating cdr: %w", err)
@@ -96,7 +101,8 @@ func (r *cdrRepo) ListByExtension(ctx context.Context, extension string, limit,
 
 	query := `SELECT id, call_id, start_time, answer_time, end_time, duration,
 		 billable_dur, caller_id_name, caller_id_num, callee, trunk_id,
-		 direction, disposition, recording_file, flow_path, hangup_cause
+		 direction, disposition, recording_file, flow_path, hangup_cause,
+		 transferred_to, rate, cost
 		 FROM cdrs WHERE ` + where + ` ORDER BY start_time DESC LIMIT ? OFFSET ?`
 	args = append(args, limit, offset)
 
@@ -112,7 +118,8 @@ func (r *cdrRepo) ListByExtension(ctx context.Context, extension string, limit,
 		if err := rows.Scan(&c.ID, &c.CallID, &c.StartTime, &c.AnswerTime, &c.EndTime,
 			&c.Duration, &c.BillableDur, &c.CallerIDName, &c.CallerIDNum,
 			&c.Callee, &c.TrunkID, &c.Direction, &c.Disposition,
-			&c.RecordingFile, &c.FlowPath, &c.HangupCause); err != nil {
+			&c.RecordingFile, &c.FlowPath, &c.HangupCause, &c.TransferredTo,
+			&c.Rate, &c.Cost); err != nil {
 			return nil, 0, fmt.Errorf("scanning cdr row: %w", err)
 		}
 		cdrs = append(cdrs, c)
@@ -157,7 +164,8 @@ func (r *cdrRepo) List(ctx context.Context, filter CDRListFilter) ([]models.CDR,
 	// Fetch the page of results.
 	query := `SELECT id, call_id, start_time, answer_time, end_time, duration,
 		 billable_dur, caller_id_name, caller_id_num, callee, trunk_id,
-		 direction, disposition, recording_file, flow_path, hangup_cause
+		 direction, disposition, recording_file, flow_path, hangup_cause,
+		 transferred_to, rate, cost
 		 FROM cdrs WHERE ` + where + ` ORDER BY start_time DESC LIMIT ? OFFSET ?`
 	args = append(args, filter.Limit, filter.Offset)
 
@@ -173,7 +181,8 @@ func (r *cdrRepo) List(ctx context.Context, filter CDRListFilter) ([]models.CDR,
 		if err := rows.Scan(&c.ID, &c.CallID, &c.StartTime, &c.AnswerTime, &c.EndTime,
 			&c.Duration, &c.BillableDur, &c.CallerIDName, &c.CallerIDNum,
 			&c.Callee, &c.TrunkID, &c.Direction, &c.Disposition,
-			&c.RecordingFile, &c.FlowPath, &c.HangupCause); err != nil {
+			&c.RecordingFile, &c.FlowPath, &c.HangupCause, &c.TransferredTo,
+			&c.Rate, &c.Cost); err != nil {
 			return nil, 0, fmt.Errorf("scanning cdr row: %w", err)
 		}
 		cdrs = append(cdrs, c)
@@ -215,7 +224,8 @@ func (r *cdrRepo) ListWithRecordings(ctx context.Context, filter CDRListFilter)
 	// Fetch the page of results.
 	query := `SELECT id, call_id, start_time, answer_time, end_time, duration,
 		 billable_dur, caller_id_name, caller_id_num, callee, trunk_id,
-		 direction, disposition, recording_file, flow_path, hangup_cause
+		 direction, disposition, recording_file, flow_path, hangup_cause,
+		 transferred_to, rate, cost
 		 FROM cdrs WHERE ` + where + ` ORDER BY start_time DESC LIMIT ? OFFSET ?`
 	args = append(args, filter.Limit, filter.Offset)
 
@@ -231,7 +241,8 @@ func (r *cdrRepo) ListWithRecordings(ctx context.Context, filter CDRListFilter)
 		if err := rows.Scan(&c.ID, &c.CallID, &c.StartTime, &c.AnswerTime, &c.EndTime,
 			&c.Duration, &c.BillableDur, &c.CallerIDName, &c.CallerIDNum,
 			&c.Callee, &c.TrunkID, &c.Direction, &c.Disposition,
-			&c.RecordingFile, &c.FlowPath, &c.HangupCause); err != nil {
+			&c.RecordingFile, &c.FlowPath, &c.HangupCause, &c.TransferredTo,
+			&c.Rate, &c.Cost); err != nil {
 			return nil, 0, fmt.Errorf("scanning recording row: %w", err)
 		}
 		cdrs = append(cdrs, c)
@@ -248,7 +259,8 @@ func (r *cdrRepo) ListRecent(ctx context.Context, limit int) ([]models.CDR, erro
 	rows, err := r.db.QueryContext(ctx,
 		`SELECT id, call_id, start_time, answer_time, end_time, duration,
 		 billable_dur, caller_id_name, caller_id_num, callee, trunk_id,
-		 direction, disposition, recording_file, flow_path, hangup_cause
+		 direction, disposition, recording_file, flow_path, hangup_cause,
+		 transferred_to, rate, cost
 		 FROM cdrs ORDER BY start_time DESC LIMIT ?`, limit,
 	)
 	if err != nil {
@@ -262,7 +274,8 @@ func (r *cdrRepo) ListRecent(ctx context.Context, limit int) ([]models.CDR, erro
 		if err := rows.Scan(&c.ID, &c.CallID, &c.StartTime, &c.AnswerTime, &c.EndTime,
 			&c.Duration, &c.BillableDur, &c.CallerIDName, &c.CallerIDNum,
 			&c.Callee, &c.TrunkID, &c.Direction, &c.Disposition,
-			&c.RecordingFile, &c.FlowPath, &c.HangupCause); err != nil {
+			&c.RecordingFile, &c.FlowPath, &c.HangupCause, &c.TransferredTo,
+			&c.Rate, &c.Cost); err != nil {
 			return nil, fmt.Errorf("scanning recent cdr row: %w", err)
 		}
 		cdrs = append(cdrs, c)
@@ -310,6 +323,60 @@ func (r *cdrRepo) CountByDirection(ctx context.Context) (map[string]int64, error
 	return counts, nil
 }
 
+// CostByExtension totals the cost of rated calls per calling extension,
+// most expensive first. The filter's search, direction and date range
+// apply; its pagination is ignored.
+func (r *cdrRepo) CostByExtension(ctx context.Context, filter CDRListFilter) ([]ExtensionCost, error) {
+	where := "cost IS NOT NULL"
+	args := []any{}
+
+	if filter.Direction != "" {
+		where += " AND direction = ?"
+		args = append(args, filter.Direction)
+	}
+	if filter.Search != "" {
+		where += " AND (caller_id_name LIKE ? OR caller_id_num LIKE ? OR callee LIKE ?)"
+		s := "%" + filter.Search + "%"
+		args = append(args, s, s, s)
+	}
+	if filter.StartDate != "" {
+		where += " AND start_time >= ?"
+		args = append(args, filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		where += " AND start_time <= ?"
+		args = append(args, filter.EndDate)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT caller_id_num, COUNT(*), COALESCE(SUM(billable_dur), 0), SUM(cost)
+		 FROM cdrs WHERE `+where+`
+		 GROUP BY caller_id_num ORDER BY SUM(cost) DESC, caller_id_num`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("summing cdr cost by extension: %w", err)
+	}
+	defer rows.Close()
+
+	var costs []ExtensionCost
+	for rows.Next() {
+		var c ExtensionCost
+		if err := rows.Scan(&c.Extension, &c.Calls, &c.BillableSec, &c.Cost); err != nil {
+			return nil, fmt.Errorf("scanning extension cost row: %w", err)
+		}
+		costs = append(costs, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating extension cost rows: %w", err)
+	}
+	return costs, nil
+}
+
+// CallCost returns the cost of billableSec seconds at rate per minute,
+// billed per second and rounded to four decimal places.
+func CallCost(rate float64, billableSec int) float64 {
+	return math.Round(rate*float64(billableSec)/60*10000) / 10000
+}
+
 // DeleteExpiredRecordings clears the recording_file field on CDRs whose
 // start_time is older than the given number of days and that have a non-empty
 // recording_file. Returns the file paths of the cleared recordings so callers
@@ -358,7 +425,8 @@ func (r *cdrRepo) scanOne(row *sql.Row) (*models.CDR, error) {
 	err := row.Scan(&c.ID, &c.CallID, &c.StartTime, &c.AnswerTime, &c.EndTime,
 		&c.Duration, &c.BillableDur, &c.CallerIDName, &c.CallerIDNum,
 		&c.Callee, &c.TrunkID, &c.Direction, &c.Disposition,
-		&c.RecordingFile, &c.FlowPath, &c.HangupCause)
+		&c.RecordingFile, &c.FlowPath, &c.HangupCause, &c.TransferredTo,
+		&c.Rate, &c.Cost)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
 )
 
 func TestOpenAndMigrate(t *testing.T) {
@@ -36,7 +38,9 @@ func TestOpenAndMigrate(t *testing.T) {
 		"schema_migrations", "system_config", "extensions", "trunks",
 		"inbound_numbers", "voicemail_boxes", "voicemail_messages",
 		"ring_groups", "ivr_menus", "time_switches", "call_flows",
-		"cdrs", "registrations", "conference_bridges",
+		"cdrs", "registrations", "conference_bridges", "queues",
+		"outbound_routes", "trunk_rates", "caller_filters", "park_lots",
+		"moh_classes", "time_switch_calendars", "call_flow_revisions",
 	}
 	for _, table := range tables {
 		var count int
@@ -54,8 +58,8 @@ func TestOpenAndMigrate(t *testing.T) {
 	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&migrationCount); err != nil {
 		t.Fatalf("counting migrations: %v", err)
 	}
-	if migrationCount != 20 {
-		t.Errorf("migration count = %d, want 20", migrationCount)
+	if migrationCount != 34 {
+		t.Errorf("migration count = %d, want 34", migrationCount)
 	}
 }
 
@@ -174,3 +178,325 @@ func TestEncryptorInvalidKeyLength(t *testing.T) {
 		t.Fatal("expected error for short key")
 	}
 }
+
+func TestTrunkRateRepository(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	result, err := db.ExecContext(ctx, `INSERT INTO trunks (name, type) VALUES ('carrier', 'register')`)
+	if err != nil {
+		t.Fatalf("inserting trunk: %v", err)
+	}
+	trunkID, _ := result.LastInsertId()
+
+	repo := NewTrunkRateRepository(db)
+	if err := repo.ReplaceForTrunk(ctx, trunkID, []models.TrunkRate{
+		{Prefix: "61", Description: "Australia", Rate: 0.05},
+		{Prefix: "614", Description: "Australia mobile", Rate: 0.12},
+	}); err != nil {
+		t.Fatalf("ReplaceForTrunk() error: %v", err)
+	}
+
+	rate, err := repo.LongestPrefix(ctx, trunkID, "61412345678")
+	if err != nil {
+		t.Fatalf("LongestPrefix() error: %v", err)
+	}
+	if rate == nil || rate.Prefix != "614" {
+		t.Errorf("LongestPrefix(61412345678) = %+v, want prefix 614", rate)
+	}
+
+	rate, err = repo.LongestPrefix(ctx, trunkID, "6129876543")
+	if err != nil {
+		t.Fatalf("LongestPrefix() error: %v", err)
+	}
+	if rate == nil || rate.Prefix != "61" {
+		t.Errorf("LongestPrefix(6129876543) = %+v, want prefix 61", rate)
+	}
+
+	rate, err = repo.LongestPrefix(ctx, trunkID, "4420794600")
+	if err != nil {
+		t.Fatalf("LongestPrefix() error: %v", err)
+	}
+	if rate != nil {
+		t.Errorf("LongestPrefix(4420794600) = %+v, want no rate", rate)
+	}
+
+	// A re-import replaces the whole deck.
+	if err := repo.ReplaceForTrunk(ctx, trunkID, []models.TrunkRate{{Prefix: "44", Rate: 0.02}}); err != nil {
+		t.Fatalf("ReplaceForTrunk() re-import error: %v", err)
+	}
+	rates, err := repo.ListByTrunk(ctx, trunkID)
+	if err != nil {
+		t.Fatalf("ListByTrunk() error: %v", err)
+	}
+	if len(rates) != 1 || rates[0].Prefix != "44" {
+		t.Errorf("ListByTrunk() = %+v, want only prefix 44", rates)
+	}
+}
+
+func TestCallCost(t *testing.T) {
+	if got := CallCost(0.12, 90); got != 0.18 {
+		t.Errorf("CallCost(0.12, 90) = %v, want 0.18", got)
+	}
+	if got := CallCost(0.05, 0); got != 0 {
+		t.Errorf("CallCost(0.05, 0) = %v, want 0", got)
+	}
+}
+
+func TestMOHClassDeleteClearsAssignments(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	classes := NewMOHClassRepository(db)
+	class := &models.MOHClass{Name: "jazz", Mode: "shuffle", PromptIDs: "[]"}
+	if err := classes.Create(ctx, class); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	numbers := NewInboundNumberRepository(db)
+	num := &models.InboundNumber{Number: "0299990000", Name: "main", Enabled: true, MOHClassID: &class.ID}
+	if err := numbers.Create(ctx, num); err != nil {
+		t.Fatalf("creating inbound number: %v", err)
+	}
+
+	got, err := numbers.GetByID(ctx, num.ID)
+	if err != nil || got == nil || got.MOHClassID == nil || *got.MOHClassID != class.ID {
+		t.Fatalf("GetByID() = %+v, %v, want moh class %d", got, err, class.ID)
+	}
+
+	if err := classes.Delete(ctx, class.ID); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	got, err = numbers.GetByID(ctx, num.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetByID() after delete = %+v, %v", got, err)
+	}
+	if got.MOHClassID != nil {
+		t.Errorf("MOHClassID after class delete = %d, want nil", *got.MOHClassID)
+	}
+}
+
+func TestTimeSwitchCalendarRepository(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	switches := NewTimeSwitchRepository(db)
+	ts := &models.TimeSwitch{Name: "hours", Timezone: "Australia/Sydney", Rules: "[]", Overrides: "[]"}
+	if err := switches.Create(ctx, ts); err != nil {
+		t.Fatalf("creating time switch: %v", err)
+	}
+
+	cals := NewTimeSwitchCalendarRepository(db)
+	cal := &models.TimeSwitchCalendar{
+		TimeSwitchID:   ts.ID,
+		Name:           "public holidays",
+		Label:          "Closed",
+		Source:         "url",
+		URL:            "https://example.com/holidays.ics",
+		RefreshMinutes: 1440,
+	}
+	if err := cals.Create(ctx, cal); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	if err := cals.UpdateRefresh(ctx, cal.ID, 12, ""); err != nil {
+		t.Fatalf("UpdateRefresh() error: %v", err)
+	}
+	if err := cals.UpdateRefresh(ctx, cal.ID, 0, "fetch failed"); err != nil {
+		t.Fatalf("UpdateRefresh() error: %v", err)
+	}
+	got, err := cals.GetByID(ctx, cal.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetByID() = %+v, %v", got, err)
+	}
+	if got.EventCount != 12 || got.LastError != "fetch failed" || got.RefreshedAt == nil {
+		t.Errorf("after failed refresh: event_count = %d, last_error = %q, refreshed_at = %v; want 12, %q, set",
+			got.EventCount, got.LastError, got.RefreshedAt, "fetch failed")
+	}
+
+	list, err := cals.ListBySource(ctx, "url")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListBySource() = %d calendars, %v, want 1", len(list), err)
+	}
+
+	if err := switches.Delete(ctx, ts.ID); err != nil {
+		t.Fatalf("deleting time switch: %v", err)
+	}
+	list, err = cals.ListByTimeSwitch(ctx, ts.ID)
+	if err != nil || len(list) != 0 {
+		t.Errorf("ListByTimeSwitch() after time switch delete = %d calendars, %v, want 0", len(list), err)
+	}
+}
+
+func TestAdminUserRolesAndTOTPStep(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	users := NewAdminUserRepository(db)
+	owner := &models.AdminUser{Username: "alice", PasswordHash: "x", Role: "owner"}
+	if err := users.Create(ctx, owner); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	viewer := &models.AdminUser{Username: "bob", PasswordHash: "x", Role: "read-only"}
+	if err := users.Create(ctx, viewer); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	got, err := users.GetByUsername(ctx, "bob")
+	if err != nil || got == nil {
+		t.Fatalf("GetByUsername() = %+v, %v", got, err)
+	}
+	if got.Role != "read-only" || got.TOTPEnabled || got.RecoveryCodes != "[]" {
+		t.Errorf("new user: role = %q, totp_enabled = %v, recovery_codes = %q", got.Role, got.TOTPEnabled, got.RecoveryCodes)
+	}
+
+	if n, err := users.CountByRole(ctx, "owner"); err != nil || n != 1 {
+		t.Errorf("CountByRole(owner) = %d, %v, want 1", n, err)
+	}
+
+	ok, err := users.ClaimTOTPStep(ctx, owner.ID, 100)
+	if err != nil || !ok {
+		t.Fatalf("ClaimTOTPStep(100) = %v, %v, want true", ok, err)
+	}
+	if ok, _ := users.ClaimTOTPStep(ctx, owner.ID, 100); ok {
+		t.Error("ClaimTOTPStep(100) again = true, want replay refused")
+	}
+	if ok, _ := users.ClaimTOTPStep(ctx, owner.ID, 99); ok {
+		t.Error("ClaimTOTPStep(99) = true, want earlier step refused")
+	}
+	if ok, _ := users.ClaimTOTPStep(ctx, owner.ID, 101); !ok {
+		t.Error("ClaimTOTPStep(101) = false, want true")
+	}
+
+	owner.RecoveryCodes = `["aa","bb","cc"]`
+	if err := users.Update(ctx, owner); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if ok, err := users.ClaimRecoveryCode(ctx, owner.ID, "bb"); err != nil || !ok {
+		t.Fatalf("ClaimRecoveryCode(bb) = %v, %v, want true", ok, err)
+	}
+	if ok, _ := users.ClaimRecoveryCode(ctx, owner.ID, "bb"); ok {
+		t.Error("ClaimRecoveryCode(bb) again = true, want reuse refused")
+	}
+	if ok, _ := users.ClaimRecoveryCode(ctx, viewer.ID, "aa"); ok {
+		t.Error("ClaimRecoveryCode(aa) for another user = true, want false")
+	}
+	if ok, _ := users.ClaimRecoveryCode(ctx, owner.ID, "aa"); !ok {
+		t.Error("ClaimRecoveryCode(aa) = false, want true")
+	}
+	if ok, _ := users.ClaimRecoveryCode(ctx, owner.ID, "cc"); !ok {
+		t.Error("ClaimRecoveryCode(cc) = false, want true")
+	}
+	if got, _ := users.GetByID(ctx, owner.ID); got == nil || got.RecoveryCodes != "[]" {
+		t.Errorf("recovery codes after all used = %+v, want []", got)
+	}
+}
+
+func TestCallFlowRevisions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	flows := NewCallFlowRepository(db)
+	f := &models.CallFlow{Name: "Main", FlowData: `{"nodes":[{"id":"a"}]}`}
+	if err := flows.Create(ctx, f); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	if got, err := flows.GetPublished(ctx, f.ID); err != nil || got != nil {
+		t.Fatalf("GetPublished() before publish = %+v, %v, want nil", got, err)
+	}
+
+	rev1, err := flows.Publish(ctx, f.ID, "alice")
+	if err != nil || rev1 == nil {
+		t.Fatalf("Publish() = %+v, %v", rev1, err)
+	}
+	if rev1.Revision != 1 || rev1.Author != "alice" || rev1.FlowData != f.FlowData {
+		t.Errorf("first revision = %+v", rev1)
+	}
+
+	// Editing the draft does not change what live calls run.
+	f.FlowData = `{"nodes":[{"id":"b"}]}`
+	if err := flows.Update(ctx, f); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	pub, err := flows.GetPublished(ctx, f.ID)
+	if err != nil || pub == nil {
+		t.Fatalf("GetPublished() = %+v, %v", pub, err)
+	}
+	if pub.FlowData != rev1.FlowData || pub.PublishedRevision != 1 {
+		t.Errorf("published flow data = %s (revision %d), want revision 1", pub.FlowData, pub.PublishedRevision)
+	}
+
+	rev2, err := flows.Publish(ctx, f.ID, "bob")
+	if err != nil || rev2 == nil || rev2.Revision != 2 {
+		t.Fatalf("second Publish() = %+v, %v", rev2, err)
+	}
+	if pub, _ := flows.GetPublished(ctx, f.ID); pub.FlowData != `{"nodes":[{"id":"b"}]}` {
+		t.Errorf("published flow data after second publish = %s", pub.FlowData)
+	}
+
+	rev3, err := flows.Rollback(ctx, f.ID, 1, "carol")
+	if err != nil || rev3 == nil {
+		t.Fatalf("Rollback() = %+v, %v", rev3, err)
+	}
+	if rev3.Revision != 3 || rev3.RestoredFrom == nil || *rev3.RestoredFrom != 1 || rev3.FlowData != rev1.FlowData {
+		t.Errorf("rollback revision = %+v", rev3)
+	}
+	got, err := flows.GetByID(ctx, f.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetByID() = %+v, %v", got, err)
+	}
+	if got.FlowData != rev1.FlowData || got.PublishedRevision != 3 {
+		t.Errorf("after rollback: draft = %s, published revision = %d", got.FlowData, got.PublishedRevision)
+	}
+
+	if rev, err := flows.Rollback(ctx, f.ID, 9, "carol"); err != nil || rev != nil {
+		t.Errorf("Rollback() to missing revision = %+v, %v, want nil", rev, err)
+	}
+
+	revs, err := flows.ListRevisions(ctx, f.ID)
+	if err != nil {
+		t.Fatalf("ListRevisions() error: %v", err)
+	}
+	if len(revs) != 3 || revs[0].Revision != 3 || revs[2].Revision != 1 {
+		t.Errorf("ListRevisions() = %+v, want revisions 3, 2, 1", revs)
+	}
+
+	// Revisions go with the flow.
+	if err := flows.Delete(ctx, f.ID); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if revs, _ := flows.ListRevisions(ctx, f.ID); len(revs) != 0 {
+		t.Errorf("%d revisions left after deleting flow", len(revs))
+	}
+}
@@ -22,9 +22,10 @@ func NewInboundNumberRepository(db *DB) InboundNumberRepository {
 func (r *inboundNumberRepo) Create(ctx context.Context, num *models.InboundNumber) error {
 	result, err := r.db.ExecContext(ctx,
 		`INSERT INTO inbound_numbers (number, name, trunk_id, flow_id, flow_entry_node,
-		 enabled, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		 enabled, moh_class_id, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
 		num.Number, num.Name, num.TrunkID, num.FlowID, num.FlowEntryNode, num.Enabled,
+		num.MOHClassID,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting inbound number: %w", err)
@@ -42,7 +43,7 @@ func (r *inboundNumberRepo) Create(ctx context.Context, num *models.InboundNumbe
 func (r *inboundNumberRepo) GetByID(ctx context.Context, id int64) (*models.InboundNumber, error) {
 	return r.scanOne(r.db.QueryRowContext(ctx,
 		`SELECT id, number, name, trunk_id, flow_id, flow_entry_node,
-		 enabled, created_at, updated_at
+		 enabled, moh_class_id, created_at, updated_at
 		 FROM inbound_numbers WHERE id = ?`, id,
 	))
 }
@@ -51,7 +52,7 @@ func (r *inboundNumberRepo) GetByID(ctx context.Context, id int64) (*models.Inbo
 func (r *inboundNumberRepo) GetByNumber(ctx context.Context, number string) (*models.InboundNumber, error) {
 	return r.scanOne(r.db.QueryRowContext(ctx,
 		`SELECT id, number, name, trunk_id, flow_id, flow_entry_node,
-		 enabled, created_at, updated_at
+		 enabled, moh_class_id, created_at, updated_at
 		 FROM inbound_numbers WHERE number = ?`, number,
 	))
 }
@@ -60,7 +61,7 @@ func (r *inboundNumberRepo) GetByNumber(ctx context.Context, number string) (*mo
 func (r *inboundNumberRepo) List(ctx context.Context) ([]models.InboundNumber, error) {
 	rows, err := r.db.QueryContext(ctx,
 		`SELECT id, number, name, trunk_id, flow_id, flow_entry_node,
-		 enabled, created_at, updated_at
+		 enabled, moh_class_id, created_at, updated_at
 		 FROM inbound_numbers ORDER BY number`)
 	if err != nil {
 		return nil, fmt.Errorf("querying inbound numbers: %w", err)
@@ -71,7 +72,7 @@ func (r *inboundNumberRepo) List(ctx context.Context) ([]models.InboundNumber, e
 	for rows.Next() {
 		var n models.InboundNumber
 		if err := rows.Scan(&n.ID, &n.Number, &n.Name, &n.TrunkID, &n.FlowID,
-			&n.FlowEntryNode, &n.Enabled, &n.CreatedAt, &n.UpdatedAt); err != nil {
+			&n.FlowEntryNode, &n.Enabled, &n.MOHClassID, &n.CreatedAt, &n.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scanning inbound number row: %w", err)
 		}
 		nums = append(nums, n)
@@ -83,10 +84,10 @@ func (r *inboundNumberRepo) List(ctx context.Context) ([]models.InboundNumber, e
 func (r *inboundNumberRepo) Update(ctx context.Context, num *models.InboundNumber) error {
 	_, err := r.db.ExecContext(ctx,
 		`UPDATE inbound_numbers SET number = ?, name = ?, trunk_id = ?, flow_id = ?,
-		 flow_entry_node = ?, enabled = ?, updated_at = datetime('now')
+		 flow_entry_node = ?, enabled = ?, moh_class_id = ?, updated_at = datetime('now')
 		 WHERE id = ?`,
 		num.Number, num.Name, num.TrunkID, num.FlowID, num.FlowEntryNode,
-		num.Enabled, num.ID,
+		num.Enabled, num.MOHClassID, num.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("updating inbound number: %w", err)
@@ -106,7 +107,7 @@ func (r *inboundNumberRepo) Delete(ctx context.Context, id int64) error {
 func (r *inboundNumberRepo) scanOne(row *sql.Row) (*models.InboundNumber, error) {
 	var n models.InboundNumber
 	err := row.Scan(&n.ID, &n.Number, &n.Name, &n.TrunkID, &n.FlowID,
-		&n.FlowEntryNode, &n.Enabled, &n.CreatedAt, &n.UpdatedAt)
+		&n.FlowEntryNode, &n.Enabled, &n.MOHClassID, &n.CreatedAt, &n.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -0,0 +1,15 @@
+-- Call queues (ACD) with ring strategy, wait limits and position announcements
+CREATE TABLE queues (
+    id                INTEGER PRIMARY KEY,
+    name              TEXT    NOT NULL,
+    strategy          TEXT    DEFAULT 'ring_all',
+    ring_timeout      INTEGER DEFAULT 20,
+    members           TEXT    NOT NULL,
+    max_wait_time     INTEGER DEFAULT 300,
+    max_callers       INTEGER DEFAULT 0,
+    announce_position BOOLEAN DEFAULT 1,
+    announce_interval INTEGER DEFAULT 30,
+    hold_music_file   TEXT,
+    created_at        DATETIME DEFAULT (datetime('now')),
+    updated_at        DATETIME DEFAULT (datetime('now'))
+);
@@ -0,0 +1,2 @@
+-- Record the destination a call was transferred to (blind or attended)
+ALTER TABLE cdrs ADD COLUMN transferred_to TEXT NOT NULL DEFAULT '';
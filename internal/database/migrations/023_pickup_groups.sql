@@ -0,0 +1,2 @@
+-- Call pickup group: *8 picks up calls ringing extensions in the same group
+ALTER TABLE extensions ADD COLUMN pickup_group TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- SRTP media encryption policy: 'off', 'optional' or 'required'
+ALTER TABLE extensions ADD COLUMN srtp_mode TEXT NOT NULL DEFAULT 'optional';
+ALTER TABLE trunks ADD COLUMN srtp_mode TEXT NOT NULL DEFAULT 'off';
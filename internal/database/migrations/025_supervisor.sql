@@ -0,0 +1,2 @@
+-- Extensions allowed to monitor, whisper to and barge into other calls
+ALTER TABLE extensions ADD COLUMN supervisor BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Outbound dial plan: matching numbers are sent over the route's trunks in order
+CREATE TABLE outbound_routes (
+    id           INTEGER PRIMARY KEY,
+    name         TEXT    NOT NULL,
+    patterns     TEXT    NOT NULL,
+    trunk_ids    TEXT    NOT NULL,
+    class        TEXT    NOT NULL DEFAULT 'national',
+    prefix_strip INTEGER DEFAULT 0,
+    prefix_add   TEXT    DEFAULT '',
+    priority     INTEGER DEFAULT 10,
+    enabled      BOOLEAN DEFAULT 1,
+    created_at   DATETIME DEFAULT (datetime('now')),
+    updated_at   DATETIME DEFAULT (datetime('now'))
+);
+
+-- Widest class of number an extension may dial
+ALTER TABLE extensions ADD COLUMN class_of_service TEXT NOT NULL DEFAULT 'international';
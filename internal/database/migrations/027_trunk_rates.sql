@@ -0,0 +1,16 @@
+-- Per-trunk rate decks for least-cost routing, matched by longest prefix
+CREATE TABLE trunk_rates (
+    id          INTEGER PRIMARY KEY,
+    trunk_id    INTEGER NOT NULL REFERENCES trunks(id) ON DELETE CASCADE,
+    prefix      TEXT    NOT NULL,
+    description TEXT    DEFAULT '',
+    rate        REAL    NOT NULL,
+    created_at  DATETIME DEFAULT (datetime('now')),
+    UNIQUE (trunk_id, prefix)
+);
+
+CREATE INDEX idx_trunk_rates_trunk_id ON trunk_rates(trunk_id);
+
+-- Rate per minute and cost of an outbound call
+ALTER TABLE cdrs ADD COLUMN rate REAL;
+ALTER TABLE cdrs ADD COLUMN cost REAL;
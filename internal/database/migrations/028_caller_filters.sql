@@ -0,0 +1,18 @@
+-- Inbound caller screening: block and allow entries matched against the caller
+CREATE TABLE caller_filters (
+    id                INTEGER PRIMARY KEY,
+    inbound_number_id INTEGER REFERENCES inbound_numbers(id) ON DELETE CASCADE,
+    list              TEXT    NOT NULL DEFAULT 'block',
+    match_type        TEXT    NOT NULL DEFAULT 'exact',
+    pattern           TEXT    NOT NULL DEFAULT '',
+    action            TEXT    NOT NULL DEFAULT 'reject',
+    voicemail_box_id  INTEGER REFERENCES voicemail_boxes(id) ON DELETE SET NULL,
+    flow_id           INTEGER REFERENCES call_flows(id) ON DELETE SET NULL,
+    flow_node         TEXT    DEFAULT '',
+    description       TEXT    DEFAULT '',
+    enabled           BOOLEAN DEFAULT 1,
+    created_at        DATETIME DEFAULT (datetime('now')),
+    updated_at        DATETIME DEFAULT (datetime('now'))
+);
+
+CREATE INDEX idx_caller_filters_inbound_number_id ON caller_filters(inbound_number_id);
@@ -0,0 +1,15 @@
+-- Call parking: a park code and a range of orbit numbers
+CREATE TABLE park_lots (
+    id                INTEGER PRIMARY KEY,
+    name              TEXT    NOT NULL,
+    park_code         TEXT    NOT NULL UNIQUE,
+    orbit_start       INTEGER NOT NULL,
+    orbit_end         INTEGER NOT NULL,
+    timeout           INTEGER DEFAULT 120,
+    timeout_action    TEXT    NOT NULL DEFAULT 'return',
+    timeout_flow_id   INTEGER REFERENCES call_flows(id) ON DELETE SET NULL,
+    timeout_flow_node TEXT    DEFAULT '',
+    enabled           BOOLEAN DEFAULT 1,
+    created_at        DATETIME DEFAULT (datetime('now')),
+    updated_at        DATETIME DEFAULT (datetime('now'))
+);
@@ -0,0 +1,13 @@
+-- Music on hold classes: playlists of uploaded audio prompts
+CREATE TABLE moh_classes (
+    id         INTEGER PRIMARY KEY,
+    name       TEXT    NOT NULL UNIQUE,
+    mode       TEXT    NOT NULL DEFAULT 'sequential',
+    prompt_ids TEXT    NOT NULL DEFAULT '[]',
+    created_at DATETIME DEFAULT (datetime('now')),
+    updated_at DATETIME DEFAULT (datetime('now'))
+);
+
+ALTER TABLE inbound_numbers ADD COLUMN moh_class_id INTEGER REFERENCES moh_classes(id) ON DELETE SET NULL;
+ALTER TABLE queues ADD COLUMN moh_class_id INTEGER REFERENCES moh_classes(id) ON DELETE SET NULL;
+ALTER TABLE extensions ADD COLUMN moh_class_id INTEGER REFERENCES moh_classes(id) ON DELETE SET NULL;
@@ -0,0 +1,17 @@
+-- Calendars (uploaded .ics or fetched URL) imported into a time switch
+CREATE TABLE time_switch_calendars (
+    id              INTEGER PRIMARY KEY,
+    time_switch_id  INTEGER NOT NULL REFERENCES time_switches(id) ON DELETE CASCADE,
+    name            TEXT    NOT NULL,
+    label           TEXT    NOT NULL,
+    source          TEXT    NOT NULL DEFAULT 'upload',
+    url             TEXT    NOT NULL DEFAULT '',
+    refresh_minutes INTEGER NOT NULL DEFAULT 1440,
+    event_count     INTEGER NOT NULL DEFAULT 0,
+    refreshed_at    DATETIME,
+    last_error      TEXT    NOT NULL DEFAULT '',
+    created_at      DATETIME DEFAULT (datetime('now')),
+    updated_at      DATETIME DEFAULT (datetime('now'))
+);
+
+CREATE INDEX idx_time_switch_calendars_time_switch ON time_switch_calendars(time_switch_id);
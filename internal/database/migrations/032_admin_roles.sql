@@ -0,0 +1,9 @@
+-- Admin roles and TOTP two-factor login; existing admins become owners
+ALTER TABLE admin_users ADD COLUMN role TEXT NOT NULL DEFAULT 'owner';
+ALTER TABLE admin_users ADD COLUMN totp_enabled INTEGER NOT NULL DEFAULT 0;
+
+-- The time step of the last accepted code, so a code cannot be replayed.
+ALTER TABLE admin_users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+
+-- JSON array of SHA-256 hashes of the unused recovery codes.
+ALTER TABLE admin_users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT '[]';
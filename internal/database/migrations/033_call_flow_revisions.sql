@@ -0,0 +1,23 @@
+-- Call flow revisions: each publish stores an immutable, numbered flow graph
+CREATE TABLE call_flow_revisions (
+    id            INTEGER PRIMARY KEY,
+    flow_id       INTEGER NOT NULL REFERENCES call_flows(id) ON DELETE CASCADE,
+    revision      INTEGER NOT NULL,
+    name          TEXT    NOT NULL,
+    flow_data     TEXT    NOT NULL,
+    author        TEXT    NOT NULL DEFAULT '',
+    restored_from INTEGER,
+    created_at    DATETIME DEFAULT (datetime('now')),
+    UNIQUE (flow_id, revision)
+);
+
+ALTER TABLE call_flows ADD COLUMN published_revision_id INTEGER REFERENCES call_flow_revisions(id) ON DELETE SET NULL;
+
+-- Flows published before revisions existed get their current graph as revision 1
+INSERT INTO call_flow_revisions (flow_id, revision, name, flow_data, created_at)
+    SELECT id, 1, name, flow_data, COALESCE(published_at, datetime('now'))
+    FROM call_flows WHERE published = 1;
+
+UPDATE call_flows SET published_revision_id =
+    (SELECT r.id FROM call_flow_revisions r WHERE r.flow_id = call_flows.id AND r.revision = 1)
+    WHERE published = 1;
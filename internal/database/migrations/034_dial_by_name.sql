@@ -0,0 +1,3 @@
+-- Dial-by-name directory opt-out and recorded voicemail box names
+ALTER TABLE extensions ADD COLUMN directory_exclude BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE voicemail_boxes ADD COLUMN name_file TEXT NOT NULL DEFAULT '';
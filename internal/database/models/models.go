@@ -29,6 +29,12 @@ type Extension struct {
 	FollowMeConfirm  bool   // require "Press 1 to accept" on external legs
 	RecordingMode    string
 	MaxRegistrations int
+	PickupGroup      string // calls ringing extensions in the same group can be picked up with *8
+	SRTPMode         string // "off", "optional" or "required"
+	Supervisor       bool   // may monitor, whisper to and barge into other calls
+	ClassOfService   string // widest class of number it may dial: "internal", "local", "national", "international" or "premium"
+	MOHClassID       *int64 // music on hold its held and parked callers hear
+	DirectoryExclude bool   // left out of the dial-by-name directory
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 }
@@ -76,10 +82,38 @@ type Trunk struct {
 	PrefixAdd      string
 	Priority       int
 	RecordingMode  string
+	SRTPMode       string // "off", "optional" or "required"
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
 
+// OutboundRoute represents an outbound dial plan entry. Numbers matching
+// any of its patterns are sent over its trunks in order.
+type OutboundRoute struct {
+	ID          int64
+	Name        string
+	Patterns    string // JSON array of dial patterns
+	TrunkIDs    string // JSON array of trunk IDs, in the order they are tried
+	Class       string // "emergency", "local", "national", "international" or "premium"
+	PrefixStrip int
+	PrefixAdd   string
+	Priority    int
+	Enabled     bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TrunkRate is one entry of a trunk's rate deck: the rate per minute of
+// calls to numbers starting with Prefix.
+type TrunkRate struct {
+	ID          int64
+	TrunkID     int64
+	Prefix      string
+	Description string
+	Rate        float64
+	CreatedAt   time.Time
+}
+
 // InboundNumber represents a DID/inbound number mapping.
 type InboundNumber struct {
 	ID            int64
@@ -89,10 +123,49 @@ type InboundNumber struct {
 	FlowID        *int64
 	FlowEntryNode string
 	Enabled       bool
+	MOHClassID    *int64 // music on hold for calls to this number
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 }
 
+// CallerFilter is a caller screening entry: a block or allow list entry
+// matched against the number of inbound callers, either globally or, when
+// InboundNumberID is set, for calls to one inbound number.
+type CallerFilter struct {
+	ID              int64
+	InboundNumberID *int64
+	List            string // "block" or "allow"
+	MatchType       string // "exact", "prefix", "regex" or "anonymous"
+	Pattern         string
+	Action          string // "reject", "voicemail" or "flow"; block entries only
+	VoicemailBoxID  *int64
+	FlowID          *int64
+	FlowNode        string
+	Description     string
+	Enabled         bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// ParkLot is a call parking lot: a park code and the range of orbit
+// numbers calls are parked in. A parked call that is not retrieved within
+// Timeout seconds is returned to the extension that parked it or, with
+// TimeoutAction "flow", sent to a node of a call flow.
+type ParkLot struct {
+	ID              int64
+	Name            string
+	ParkCode        string
+	OrbitStart      int
+	OrbitEnd        int
+	Timeout         int
+	TimeoutAction   string // "return" or "flow"
+	TimeoutFlowID   *int64
+	TimeoutFlowNode string
+	Enabled         bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
 // VoicemailBox represents a voicemail box configuration.
 type VoicemailBox struct {
 	ID                 int64
@@ -108,6 +181,7 @@ type VoicemailBox struct {
 	MaxMessages        int
 	RetentionDays      int
 	NotifyExtensionID  *int64
+	NameFile           string // owner's recorded name, announced by the dial-by-name directory
 	CreatedAt          time.Time
 	UpdatedAt          time.Time
 }
@@ -139,6 +213,23 @@ type RingGroup struct {
 	UpdatedAt    time.Time
 }
 
+// Queue represents an ACD call queue configuration.
+type Queue struct {
+	ID               int64
+	Name             string
+	Strategy         string
+	RingTimeout      int
+	Members          string // JSON array of extension IDs
+	MaxWaitTime      int
+	MaxCallers       int
+	AnnouncePosition bool
+	AnnounceInterval int
+	HoldMusicFile    string
+	MOHClassID       *int64 // takes precedence over HoldMusicFile
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
 // IVRMenu represents an IVR menu configuration.
 type IVRMenu struct {
 	ID           int64
@@ -165,16 +256,54 @@ type TimeSwitch struct {
 	UpdatedAt   time.Time
 }
 
+// TimeSwitchCalendar is an iCalendar file imported into a time switch as
+// an override set: while any of its events is in progress the time switch
+// follows the Label edge. Source "upload" is a file uploaded once; source
+// "url" is fetched from URL every RefreshMinutes. RefreshedAt and
+// LastError record the last fetch of a URL calendar.
+type TimeSwitchCalendar struct {
+	ID             int64
+	TimeSwitchID   int64
+	Name           string
+	Label          string
+	Source         string // "upload" or "url"
+	URL            string
+	RefreshMinutes int
+	EventCount     int
+	RefreshedAt    *time.Time
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
 // CallFlow represents a visual call flow graph.
 type CallFlow struct {
 	ID          int64
 	Name        string
-	FlowData    string // React Flow JSON
+	FlowData    string // React Flow JSON; the working draft, except from GetPublished
 	Version     int
 	Published   bool
 	PublishedAt *time.Time
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// PublishedRevisionID pins the revision live calls run; nil until
+	// the flow is first published. PublishedRevision is its number.
+	PublishedRevisionID *int64
+	PublishedRevision   int
+}
+
+// CallFlowRevision is an immutable snapshot of a call flow taken when it
+// was published.
+type CallFlowRevision struct {
+	ID           int64
+	FlowID       int64
+	Revision     int // 1, 2, ... per flow
+	Name         string
+	FlowData     string
+	Author       string
+	RestoredFrom *int // the revision a rollback republished
+	CreatedAt    time.Time
 }
 
 // CDR represents a call detail record.
@@ -195,6 +324,9 @@ type CDR struct {
 	RecordingFile string
 	FlowPath      string // JSON
 	HangupCause   string
+	TransferredTo string
+	Rate          *float64 // per minute, for calls answered on a rated trunk
+	Cost          *float64
 }
 
 // Registration represents an active SIP registration.
@@ -215,12 +347,16 @@ type Registration struct {
 
 // AdminUser represents an admin panel user.
 type AdminUser struct {
-	ID           int64
-	Username     string
-	PasswordHash string
-	TOTPSecret   *string // nullable, for Phase 2 TOTP 2FA
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID            int64
+	Username      string
+	PasswordHash  string
+	Role          string  // owner, admin, operator, read-only or billing
+	TOTPSecret    *string // nullable, encrypted TOTP secret once enrollment starts
+	TOTPEnabled   bool
+	TOTPLastStep  int64  // time step of the last accepted TOTP code
+	RecoveryCodes string // JSON array of recovery code hashes
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
 // AudioPrompt represents a custom audio prompt file.
@@ -234,6 +370,17 @@ type AudioPrompt struct {
 	CreatedAt time.Time
 }
 
+// MOHClass is a music on hold class: a playlist of audio prompts played
+// to held, parked and queued callers.
+type MOHClass struct {
+	ID        int64
+	Name      string
+	Mode      string // "sequential" or "shuffle"
+	PromptIDs string // JSON array of audio prompt IDs, in play order
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 // ConferenceBridge represents a conference bridge configuration.
 type ConferenceBridge struct {
 	ID            int64
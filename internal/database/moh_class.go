@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// mohClassRepo implements MOHClassRepository.
+type mohClassRepo struct {
+	db *DB
+}
+
+// NewMOHClassRepository creates a new MOHClassRepository.
+func NewMOHClassRepository(db *DB) MOHClassRepository {
+	return &mohClassRepo{db: db}
+}
+
+// Create inserts a new music on hold class.
+func (r *mohClassRepo) Create(ctx context.Context, class *models.MOHClass) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO moh_classes (name, mode, prompt_ids, created_at, updated_at)
+		 VALUES (?, ?, ?, datetime('now'), datetime('now'))`,
+		class.Name, class.Mode, class.PromptIDs,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting moh class: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting last insert id: %w", err)
+	}
+	class.ID = id
+	return nil
+}
+
+// GetByID returns a music on hold class by ID.
+func (r *mohClassRepo) GetByID(ctx context.Context, id int64) (*models.MOHClass, error) {
+	return r.scanOne(r.db.QueryRowContext(ctx,
+		`SELECT id, name, mode, prompt_ids, created_at, updated_at
+		 FROM moh_classes WHERE id = ?`, id,
+	))
+}
+
+// List returns all music on hold classes ordered by name.
+func (r *mohClassRepo) List(ctx context.Context) ([]models.MOHClass, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, mode, prompt_ids, created_at, updated_at
+		 FROM moh_classes ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying moh classes: %w", err)
+	}
+	defer rows.Close()
+
+	var classes []models.MOHClass
+	for rows.Next() {
+		var c models.MOHClass
+		if err := rows.Scan(&c.ID, &c.Name, &c.Mode, &c.PromptIDs,
+			&c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning moh class row: %w", err)
+		}
+		classes = append(classes, c)
+	}
+	return classes, rows.Err()
+}
+
+// Update modifies an existing music on hold class.
+func (r *mohClassRepo) Update(ctx context.Context, class *models.MOHClass) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE moh_classes SET name = ?, mode = ?, prompt_ids = ?,
+		 updated_at = datetime('now')
+		 WHERE id = ?`,
+		class.Name, class.Mode, class.PromptIDs, class.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating moh class: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a music on hold class by ID. Inbound numbers, queues and
+// extensions using it fall back to the default hold music.
+func (r *mohClassRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM moh_classes WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting moh class: %w", err)
+	}
+	return nil
+}
+
+func (r *mohClassRepo) scanOne(row *sql.Row) (*models.MOHClass, error) {
+	var c models.MOHClass
+	err := row.Scan(&c.ID, &c.Name, &c.Mode, &c.PromptIDs, &c.CreatedAt, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning moh class: %w", err)
+	}
+	return &c, nil
+}
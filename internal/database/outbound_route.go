@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// outboundRouteRepo implements OutboundRouteRepository.
+type outboundRouteRepo struct {
+	db *DB
+}
+
+// NewOutboundRouteRepository creates a new OutboundRouteRepository.
+func NewOutboundRouteRepository(db *DB) OutboundRouteRepository {
+	return &outboundRouteRepo{db: db}
+}
+
+// Create inserts a new outbound route.
+func (r *outboundRouteRepo) Create(ctx context.Context, route *models.OutboundRoute) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO outbound_routes (name, patterns, trunk_ids, class, prefix_strip,
+		 prefix_add, priority, enabled, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		route.Name, route.Patterns, route.TrunkIDs, route.Class, route.PrefixStrip,
+		route.PrefixAdd, route.Priority, route.Enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting outbound route: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting last insert id: %w", err)
+	}
+	route.ID = id
+	return nil
+}
+
+// GetByID returns an outbound route by ID.
+func (r *outboundRouteRepo) GetByID(ctx context.Context, id int64) (*models.OutboundRoute, error) {
+	return r.scanOne(r.db.QueryRowContext(ctx,
+		`SELECT id, name, patterns, trunk_ids, class, prefix_strip, prefix_add,
+		 priority, enabled, created_at, updated_at
+		 FROM outbound_routes WHERE id = ?`, id,
+	))
+}
+
+// List returns all outbound routes ordered by priority then name.
+func (r *outboundRouteRepo) List(ctx context.Context) ([]models.OutboundRoute, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, patterns, trunk_ids, class, prefix_strip, prefix_add,
+		 priority, enabled, created_at, updated_at
+		 FROM outbound_routes ORDER BY priority, name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying outbound routes: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// ListEnabled returns all enabled outbound routes ordered by priority then
+// name, the order they are matched in.
+func (r *outboundRouteRepo) ListEnabled(ctx context.Context) ([]models.OutboundRoute, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, patterns, trunk_ids, class, prefix_strip, prefix_add,
+		 priority, enabled, created_at, updated_at
+		 FROM outbound_routes WHERE enabled = 1 ORDER BY priority, name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying enabled outbound routes: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// Update modifies an existing outbound route.
+func (r *outboundRouteRepo) Update(ctx context.Context, route *models.OutboundRoute) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE outbound_routes SET name = ?, patterns = ?, trunk_ids = ?, class = ?,
+		 prefix_strip = ?, prefix_add = ?, priority = ?, enabled = ?,
+		 updated_at = datetime('now')
+		 WHERE id = ?`,
+		route.Name, route.Patterns, route.TrunkIDs, route.Class, route.PrefixStrip,
+		route.PrefixAdd, route.Priority, route.Enabled, route.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating outbound route: %w", err)
+	}
+	return nil
+}
+
+// Delete removes an outbound route by ID.
+func (r *outboundRouteRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM outbound_routes WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting outbound route: %w", err)
+	}
+	return nil
+}
+
+func (r *outboundRouteRepo) scanOne(row *sql.Row) (*models.OutboundRoute, error) {
+	var o models.OutboundRoute
+	err := row.Scan(&o.ID, &o.Name, &o.Patterns, &o.TrunkIDs, &o.Class,
+		&o.PrefixStrip, &o.PrefixAdd, &o.Priority, &o.Enabled,
+		&o.CreatedAt, &o.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning outbound route: %w", err)
+	}
+	return &o, nil
+}
+
+func (r *outboundRouteRepo) scanMany(rows *sql.Rows) ([]models.OutboundRoute, error) {
+	var routes []models.OutboundRoute
+	for rows.Next() {
+		var o models.OutboundRoute
+		if err := rows.Scan(&o.ID, &o.Name, &o.Patterns, &o.TrunkIDs, &o.Class,
+			&o.PrefixStrip, &o.PrefixAdd, &o.Priority, &o.Enabled,
+			&o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning outbound route row: %w", err)
+		}
+		routes = append(routes, o)
+	}
+	return routes, rows.Err()
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// parkLotRepo implements ParkLotRepository.
+type parkLotRepo struct {
+	db *DB
+}
+
+// NewParkLotRepository creates a new ParkLotRepository.
+func NewParkLotRepository(db *DB) ParkLotRepository {
+	return &parkLotRepo{db: db}
+}
+
+// Create inserts a new park lot.
+func (r *parkLotRepo) Create(ctx context.Context, lot *models.ParkLot) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO park_lots (name, park_code, orbit_start, orbit_end, timeout,
+		 timeout_action, timeout_flow_id, timeout_flow_node, enabled,
+		 created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		lot.Name, lot.ParkCode, lot.OrbitStart, lot.OrbitEnd, lot.Timeout,
+		lot.TimeoutAction, lot.TimeoutFlowID, lot.TimeoutFlowNode, lot.Enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting park lot: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting last insert id: %w", err)
+	}
+	lot.ID = id
+	return nil
+}
+
+// GetByID returns a park lot by ID.
+func (r *parkLotRepo) GetByID(ctx context.Context, id int64) (*models.ParkLot, error) {
+	return r.scanOne(r.db.QueryRowContext(ctx,
+		`SELECT id, name, park_code, orbit_start, orbit_end, timeout,
+		 timeout_action, timeout_flow_id, timeout_flow_node, enabled,
+		 created_at, updated_at
+		 FROM park_lots WHERE id = ?`, id,
+	))
+}
+
+// List returns all park lots ordered by ID.
+func (r *parkLotRepo) List(ctx context.Context) ([]models.ParkLot, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, park_code, orbit_start, orbit_end, timeout,
+		 timeout_action, timeout_flow_id, timeout_flow_node, enabled,
+		 created_at, updated_at
+		 FROM park_lots ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying park lots: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// ListEnabled returns all enabled park lots ordered by ID.
+func (r *parkLotRepo) ListEnabled(ctx context.Context) ([]models.ParkLot, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, park_code, orbit_start, orbit_end, timeout,
+		 timeout_action, timeout_flow_id, timeout_flow_node, enabled,
+		 created_at, updated_at
+		 FROM park_lots WHERE enabled = 1 ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying enabled park lots: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// Update modifies an existing park lot.
+func (r *parkLotRepo) Update(ctx context.Context, lot *models.ParkLot) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE park_lots SET name = ?, park_code = ?, orbit_start = ?,
+		 orbit_end = ?, timeout = ?, timeout_action = ?, timeout_flow_id = ?,
+		 timeout_flow_node = ?, enabled = ?, updated_at = datetime('now')
+		 WHERE id = ?`,
+		lot.Name, lot.ParkCode, lot.OrbitStart, lot.OrbitEnd, lot.Timeout,
+		lot.TimeoutAction, lot.TimeoutFlowID, lot.TimeoutFlowNode, lot.Enabled,
+		lot.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating park lot: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a park lot by ID.
+func (r *parkLotRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM park_lots WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting park lot: %w", err)
+	}
+	return nil
+}
+
+func (r *parkLotRepo) scanOne(row *sql.Row) (*models.ParkLot, error) {
+	var lot models.ParkLot
+	err := row.Scan(&lot.ID, &lot.Name, &lot.ParkCode, &lot.OrbitStart,
+		&lot.OrbitEnd, &lot.Timeout, &lot.TimeoutAction, &lot.TimeoutFlowID,
+		&lot.TimeoutFlowNode, &lot.Enabled, &lot.CreatedAt, &lot.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning park lot: %w", err)
+	}
+	return &lot, nil
+}
+
+func (r *parkLotRepo) scanMany(rows *sql.Rows) ([]models.ParkLot, error) {
+	var lots []models.ParkLot
+	for rows.Next() {
+		var lot models.ParkLot
+		if err := rows.Scan(&lot.ID, &lot.Name, &lot.ParkCode, &lot.OrbitStart,
+			&lot.OrbitEnd, &lot.Timeout, &lot.TimeoutAction, &lot.TimeoutFlowID,
+			&lot.TimeoutFlowNode, &lot.Enabled, &lot.CreatedAt, &lot.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning park lot row: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
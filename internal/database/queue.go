@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// queueRepo implements QueueRepository.
+type queueRepo struct {
+	db *DB
+}
+
+// NewQueueRepository creates a new QueueRepository.
+func NewQueueRepository(db *DB) QueueRepository {
+	return &queueRepo{db: db}
+}
+
+// Create inserts a new queue.
+func (r *queueRepo) Create(ctx context.Context, q *models.Queue) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO queues (name, strategy, ring_timeout, members, max_wait_time,
+		 max_callers, announce_position, announce_interval, hold_music_file,
+		 moh_class_id, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		q.Name, q.Strategy, q.RingTimeout, q.Members, q.MaxWaitTime,
+		q.MaxCallers, q.AnnouncePosition, q.AnnounceInterval, q.HoldMusicFile,
+		q.MOHClassID,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting queue: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting last insert id: %w", err)
+	}
+	q.ID = id
+	return nil
+}
+
+// GetByID returns a queue by ID.
+func (r *queueRepo) GetByID(ctx context.Context, id int64) (*models.Queue, error) {
+	return r.scanOne(r.db.QueryRowContext(ctx,
+		`SELECT id, name, strategy, ring_timeout, members, max_wait_time,
+		 max_callers, announce_position, announce_interval, hold_music_file,
+		 moh_class_id, created_at, updated_at
+		 FROM queues WHERE id = ?`, id,
+	))
+}
+
+// List returns all queues ordered by name.
+func (r *queueRepo) List(ctx context.Context) ([]models.Queue, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, strategy, ring_timeout, members, max_wait_time,
+		 max_callers, announce_position, announce_interval, hold_music_file,
+		 moh_class_id, created_at, updated_at
+		 FROM queues ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying queues: %w", err)
+	}
+	defer rows.Close()
+
+	var queues []models.Queue
+	for rows.Next() {
+		var q models.Queue
+		if err := rows.Scan(&q.ID, &q.Name, &q.Strategy, &q.RingTimeout,
+			&q.Members, &q.MaxWaitTime, &q.MaxCallers, &q.AnnouncePosition,
+			&q.AnnounceInterval, &q.HoldMusicFile, &q.MOHClassID, &q.CreatedAt, &q.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning queue row: %w", err)
+		}
+		queues = append(queues, q)
+	}
+	return queues, rows.Err()
+}
+
+// Update modifies an existing queue.
+func (r *queueRepo) Update(ctx context.Context, q *models.Queue) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE queues SET name = ?, strategy = ?, ring_timeout = ?, members = ?,
+		 max_wait_time = ?, max_callers = ?, announce_position = ?,
+		 announce_interval = ?, hold_music_file = ?, moh_class_id = ?, updated_at = datetime('now')
+		 WHERE id = ?`,
+		q.Name, q.Strategy, q.RingTimeout, q.Members, q.MaxWaitTime,
+		q.MaxCallers, q.AnnouncePosition, q.AnnounceInterval, q.HoldMusicFile, q.MOHClassID, q.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating queue: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a queue by ID.
+func (r *queueRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM queues WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting queue: %w", err)
+	}
+	return nil
+}
+
+func (r *queueRepo) scanOne(row *sql.Row) (*models.Queue, error) {
+	var q models.Queue
+	err := row.Scan(&q.ID, &q.Name, &q.Strategy, &q.RingTimeout,
+		&q.Members, &q.MaxWaitTime, &q.MaxCallers, &q.AnnouncePosition,
+		&q.AnnounceInterval, &q.HoldMusicFile, &q.MOHClassID, &q.CreatedAt, &q.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning queue: %w", err)
+	}
+	return &q, nil
+}
@@ -22,6 +22,9 @@ type AdminUserRepository interface {
 	Update(ctx context.Context, user *models.AdminUser) error
 	Delete(ctx context.Context, id int64) error
 	Count(ctx context.Context) (int64, error)
+	CountByRole(ctx context.Context, role string) (int64, error)
+	ClaimTOTPStep(ctx context.Context, id, step int64) (bool, error)
+	ClaimRecoveryCode(ctx context.Context, id int64, hash string) (bool, error)
 }
 
 // ExtensionRepository manages PBX extensions/users.
@@ -45,6 +48,44 @@ type TrunkRepository interface {
 	Delete(ctx context.Context, id int64) error
 }
 
+// OutboundRouteRepository manages outbound dial plan routes.
+type OutboundRouteRepository interface {
+	Create(ctx context.Context, route *models.OutboundRoute) error
+	GetByID(ctx context.Context, id int64) (*models.OutboundRoute, error)
+	List(ctx context.Context) ([]models.OutboundRoute, error)
+	ListEnabled(ctx context.Context) ([]models.OutboundRoute, error)
+	Update(ctx context.Context, route *models.OutboundRoute) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// TrunkRateRepository manages the rate decks of trunks.
+type TrunkRateRepository interface {
+	ListByTrunk(ctx context.Context, trunkID int64) ([]models.TrunkRate, error)
+	ReplaceForTrunk(ctx context.Context, trunkID int64, rates []models.TrunkRate) error
+	DeleteByTrunk(ctx context.Context, trunkID int64) error
+	LongestPrefix(ctx context.Context, trunkID int64, number string) (*models.TrunkRate, error)
+}
+
+// CallerFilterRepository manages inbound caller screening entries.
+type CallerFilterRepository interface {
+	Create(ctx context.Context, filter *models.CallerFilter) error
+	GetByID(ctx context.Context, id int64) (*models.CallerFilter, error)
+	List(ctx context.Context) ([]models.CallerFilter, error)
+	ListEnabled(ctx context.Context) ([]models.CallerFilter, error)
+	Update(ctx context.Context, filter *models.CallerFilter) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// ParkLotRepository manages call parking lots.
+type ParkLotRepository interface {
+	Create(ctx context.Context, lot *models.ParkLot) error
+	GetByID(ctx context.Context, id int64) (*models.ParkLot, error)
+	List(ctx context.Context) ([]models.ParkLot, error)
+	ListEnabled(ctx context.Context) ([]models.ParkLot, error)
+	Update(ctx context.Context, lot *models.ParkLot) error
+	Delete(ctx context.Context, id int64) error
+}
+
 // InboundNumberRepository manages DID/inbound number mappings.
 type InboundNumberRepository interface {
 	Create(ctx context.Context, num *models.InboundNumber) error
@@ -86,6 +127,15 @@ type RingGroupRepository interface {
 	Delete(ctx context.Context, id int64) error
 }
 
+// QueueRepository manages ACD call queues.
+type QueueRepository interface {
+	Create(ctx context.Context, q *models.Queue) error
+	GetByID(ctx context.Context, id int64) (*models.Queue, error)
+	List(ctx context.Context) ([]models.Queue, error)
+	Update(ctx context.Context, q *models.Queue) error
+	Delete(ctx context.Context, id int64) error
+}
+
 // IVRMenuRepository manages IVR menus.
 type IVRMenuRepository interface {
 	Create(ctx context.Context, ivr *models.IVRMenu) error
@@ -104,6 +154,18 @@ type TimeSwitchRepository interface {
 	Delete(ctx context.Context, id int64) error
 }
 
+// TimeSwitchCalendarRepository manages calendars imported into time
+// switches.
+type TimeSwitchCalendarRepository interface {
+	Create(ctx context.Context, cal *models.TimeSwitchCalendar) error
+	GetByID(ctx context.Context, id int64) (*models.TimeSwitchCalendar, error)
+	ListByTimeSwitch(ctx context.Context, timeSwitchID int64) ([]models.TimeSwitchCalendar, error)
+	ListBySource(ctx context.Context, source string) ([]models.TimeSwitchCalendar, error)
+	Update(ctx context.Context, cal *models.TimeSwitchCalendar) error
+	UpdateRefresh(ctx context.Context, id int64, eventCount int, lastError string) error
+	Delete(ctx context.Context, id int64) error
+}
+
 // CallFlowRepository manages call flow graphs.
 type CallFlowRepository interface {
 	Create(ctx context.Context, flow *models.CallFlow) error
@@ -111,7 +173,10 @@ type CallFlowRepository interface {
 	GetPublished(ctx context.Context, id int64) (*models.CallFlow, error)
 	List(ctx context.Context) ([]models.CallFlow, error)
 	Update(ctx context.Context, flow *models.CallFlow) error
-	Publish(ctx context.Context, id int64) error
+	Publish(ctx context.Context, id int64, author string) (*models.CallFlowRevision, error)
+	Rollback(ctx context.Context, id int64, revision int, author string) (*models.CallFlowRevision, error)
+	ListRevisions(ctx context.Context, flowID int64) ([]models.CallFlowRevision, error)
+	GetRevision(ctx context.Context, flowID int64, revision int) (*models.CallFlowRevision, error)
 	Delete(ctx context.Context, id int64) error
 }
 
@@ -125,6 +190,14 @@ type CDRListFilter struct {
 	EndDate   string // RFC3339 or YYYY-MM-DD
 }
 
+// ExtensionCost is the call cost of one extension over a CDR query.
+type ExtensionCost struct {
+	Extension   string
+	Calls       int
+	BillableSec int
+	Cost        float64
+}
+
 // CDRRepository manages call detail records.
 type CDRRepository interface {
 	Create(ctx context.Context, cdr *models.CDR) error
@@ -137,6 +210,7 @@ type CDRRepository interface {
 	ListWithRecordings(ctx context.Context, filter CDRListFilter) ([]models.CDR, int, error)
 	CountRecordings(ctx context.Context) (int, error)
 	CountByDirection(ctx context.Context) (map[string]int64, error)
+	CostByExtension(ctx context.Context, filter CDRListFilter) ([]ExtensionCost, error)
 	DeleteExpiredRecordings(ctx context.Context, days int) ([]string, error)
 }
 
@@ -164,6 +238,15 @@ type PushTokenRepository interface {
 	DeleteByExtensionID(ctx context.Context, extensionID int64) error
 }
 
+// MOHClassRepository manages music on hold classes.
+type MOHClassRepository interface {
+	Create(ctx context.Context, class *models.MOHClass) error
+	GetByID(ctx context.Context, id int64) (*models.MOHClass, error)
+	List(ctx context.Context) ([]models.MOHClass, error)
+	Update(ctx context.Context, class *models.MOHClass) error
+	Delete(ctx context.Context, id int64) error
+}
+
 // AudioPromptRepository manages custom audio prompts.
 type AudioPromptRepository interface {
 	Create(ctx context.Context, prompt *models.AudioPrompt) error
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// timeSwitchCalendarRepo implements TimeSwitchCalendarRepository.
+type timeSwitchCalendarRepo struct {
+	db *DB
+}
+
+// NewTimeSwitchCalendarRepository creates a new TimeSwitchCalendarRepository.
+func NewTimeSwitchCalendarRepository(db *DB) TimeSwitchCalendarRepository {
+	return &timeSwitchCalendarRepo{db: db}
+}
+
+// Create inserts a new time switch calendar.
+func (r *timeSwitchCalendarRepo) Create(ctx context.Context, cal *models.TimeSwitchCalendar) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO time_switch_calendars (time_switch_id, name, label, source,
+		 url, refresh_minutes, event_count, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		cal.TimeSwitchID, cal.Name, cal.Label, cal.Source, cal.URL,
+		cal.RefreshMinutes, cal.EventCount,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting time switch calendar: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting last insert id: %w", err)
+	}
+	cal.ID = id
+	return nil
+}
+
+// GetByID returns a time switch calendar by ID.
+func (r *timeSwitchCalendarRepo) GetByID(ctx context.Context, id int64) (*models.TimeSwitchCalendar, error) {
+	return r.scanOne(r.db.QueryRowContext(ctx,
+		`SELECT id, time_switch_id, name, label, source, url, refresh_minutes,
+		 event_count, refreshed_at, last_error, created_at, updated_at
+		 FROM time_switch_calendars WHERE id = ?`, id,
+	))
+}
+
+// ListByTimeSwitch returns the calendars of a time switch ordered by ID,
+// which is the order their events are checked in.
+func (r *timeSwitchCalendarRepo) ListByTimeSwitch(ctx context.Context, timeSwitchID int64) ([]models.TimeSwitchCalendar, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, time_switch_id, name, label, source, url, refresh_minutes,
+		 event_count, refreshed_at, last_error, created_at, updated_at
+		 FROM time_switch_calendars WHERE time_switch_id = ? ORDER BY id`, timeSwitchID)
+	if err != nil {
+		return nil, fmt.Errorf("querying time switch calendars: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// ListBySource returns all calendars with the given source ordered by ID.
+func (r *timeSwitchCalendarRepo) ListBySource(ctx context.Context, source string) ([]models.TimeSwitchCalendar, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, time_switch_id, name, label, source, url, refresh_minutes,
+		 event_count, refreshed_at, last_error, created_at, updated_at
+		 FROM time_switch_calendars WHERE source = ? ORDER BY id`, source)
+	if err != nil {
+		return nil, fmt.Errorf("querying time switch calendars by source: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMany(rows)
+}
+
+// Update modifies the settings of an existing time switch calendar.
+func (r *timeSwitchCalendarRepo) Update(ctx context.Context, cal *models.TimeSwitchCalendar) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE time_switch_calendars SET name = ?, label = ?, url = ?,
+		 refresh_minutes = ?, event_count = ?, updated_at = datetime('now')
+		 WHERE id = ?`,
+		cal.Name, cal.Label, cal.URL, cal.RefreshMinutes, cal.EventCount, cal.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating time switch calendar: %w", err)
+	}
+	return nil
+}
+
+// UpdateRefresh records a fetch of a URL calendar. The event count is
+// only changed by a successful fetch, one with an empty lastError.
+func (r *timeSwitchCalendarRepo) UpdateRefresh(ctx context.Context, id int64, eventCount int, lastError string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE time_switch_calendars SET refreshed_at = datetime('now'),
+		 last_error = ?,
+		 event_count = CASE WHEN ? = '' THEN ? ELSE event_count END
+		 WHERE id = ?`,
+		lastError, lastError, eventCount, id,
+	)
+	if err != nil {
+		return fmt.Errorf("updating time switch calendar refresh: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a time switch calendar by ID.
+func (r *timeSwitchCalendarRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM time_switch_calendars WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting time switch calendar: %w", err)
+	}
+	return nil
+}
+
+func (r *timeSwitchCalendarRepo) scanOne(row *sql.Row) (*models.TimeSwitchCalendar, error) {
+	var cal models.TimeSwitchCalendar
+	err := row.Scan(&cal.ID, &cal.TimeSwitchID, &cal.Name, &cal.Label,
+		&cal.Source, &cal.URL, &cal.RefreshMinutes, &cal.EventCount,
+		&cal.RefreshedAt, &cal.LastError, &cal.CreatedAt, &cal.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning time switch calendar: %w", err)
+	}
+	return &cal, nil
+}
+
+func (r *timeSwitchCalendarRepo) scanMany(rows *sql.Rows) ([]models.TimeSwitchCalendar, error) {
+	var cals []models.TimeSwitchCalendar
+	for rows.Next() {
+		var cal models.TimeSwitchCalendar
+		if err := rows.Scan(&cal.ID, &cal.TimeSwitchID, &cal.Name, &cal.Label,
+			&cal.Source, &cal.URL, &cal.RefreshMinutes, &cal.EventCount,
+			&cal.RefreshedAt, &cal.LastError, &cal.CreatedAt, &cal.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning time switch calendar row: %w", err)
+		}
+		cals = append(cals, cal)
+	}
+	return cals, rows.Err()
+}
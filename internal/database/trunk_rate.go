@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// trunkRateRepo implements TrunkRateRepository.
+type trunkRateRepo struct {
+	db *DB
+}
+
+// NewTrunkRateRepository creates a new TrunkRateRepository.
+func NewTrunkRateRepository(db *DB) TrunkRateRepository {
+	return &trunkRateRepo{db: db}
+}
+
+// ListByTrunk returns the rate deck of a trunk ordered by prefix.
+func (r *trunkRateRepo) ListByTrunk(ctx context.Context, trunkID int64) ([]models.TrunkRate, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, trunk_id, prefix, description, rate, created_at
+		 FROM trunk_rates WHERE trunk_id = ? ORDER BY prefix`, trunkID)
+	if err != nil {
+		return nil, fmt.Errorf("querying trunk rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []models.TrunkRate
+	for rows.Next() {
+		var t models.TrunkRate
+		if err := rows.Scan(&t.ID, &t.TrunkID, &t.Prefix, &t.Description,
+			&t.Rate, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning trunk rate row: %w", err)
+		}
+		rates = append(rates, t)
+	}
+	return rates, rows.Err()
+}
+
+// ReplaceForTrunk replaces the whole rate deck of a trunk with rates in a
+// single transaction, so routing never sees a partly imported deck.
+func (r *trunkRateRepo) ReplaceForTrunk(ctx context.Context, trunkID int64, rates []models.TrunkRate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning trunk rate import: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM trunk_rates WHERE trunk_id = ?`, trunkID); err != nil {
+		return fmt.Errorf("clearing trunk rates: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO trunk_rates (trunk_id, prefix, description, rate, created_at)
+		 VALUES (?, ?, ?, ?, datetime('now'))`)
+	if err != nil {
+		return fmt.Errorf("preparing trunk rate insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, rate := range rates {
+		if _, err := stmt.ExecContext(ctx, trunkID, rate.Prefix, rate.Description, rate.Rate); err != nil {
+			return fmt.Errorf("inserting trunk rate %q: %w", rate.Prefix, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing trunk rate import: %w", err)
+	}
+	return nil
+}
+
+// DeleteByTrunk removes the whole rate deck of a trunk.
+func (r *trunkRateRepo) DeleteByTrunk(ctx context.Context, trunkID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM trunk_rates WHERE trunk_id = ?`, trunkID)
+	if err != nil {
+		return fmt.Errorf("deleting trunk rates: %w", err)
+	}
+	return nil
+}
+
+// LongestPrefix returns the entry of a trunk's rate deck with the longest
+// prefix of number, or nil if no prefix matches.
+func (r *trunkRateRepo) LongestPrefix(ctx context.Context, trunkID int64, number string) (*models.TrunkRate, error) {
+	var t models.TrunkRate
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, trunk_id, prefix, description, rate, created_at
+		 FROM trunk_rates
+		 WHERE trunk_id = ? AND substr(?, 1, length(prefix)) = prefix
+		 ORDER BY length(prefix) DESC LIMIT 1`, trunkID, number,
+	).Scan(&t.ID, &t.TrunkID, &t.Prefix, &t.Description, &t.Rate, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("looking up trunk rate: %w", err)
+	}
+	return &t, nil
+}
@@ -24,11 +24,12 @@ func (r *voicemailBoxRepo) Create(ctx context.Context, box *models.VoicemailBox)
 		`INSERT INTO voicemail_boxes (name, mailbox_number, pin, greeting_file,
 		 greeting_type, email_notify, email_address, email_attach_audio,
 		 max_message_duration, max_messages, retention_days, notify_extension_id,
-		 created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
+		 name_file, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`,
 		box.Name, box.MailboxNumber, box.PIN, box.GreetingFile,
 		box.GreetingType, box.EmailNotify, box.EmailAddress, box.EmailAttachAudio,
 		box.MaxMessageDuration, box.MaxMessages, box.RetentionDays, box.NotifyExtensionID,
+		box.NameFile,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting voicemail box: %w", err)
@@ -47,7 +48,7 @@ func (r *voicemailBoxRepo) GetByID(ctx context.Context, id int64) (*models.Voice
 	return r.scanOne(r.db.QueryRowContext(ctx,
 		`SELECT id, name, mailbox_number, pin, greeting_file, greeting_type,
 		 email_notify, email_address, email_attach_audio, max_message_duration,
-		 max_messages, retention_days, notify_extension_id, created_at, updated_at
+		 max_messages, retention_days, notify_extension_id, name_file, created_at, updated_at
 		 FROM voicemail_boxes WHERE id = ?`, id,
 	))
 }
@@ -57,7 +58,7 @@ func (r *voicemailBoxRepo) List(ctx context.Context) ([]models.VoicemailBox, err
 	rows, err := r.db.QueryContext(ctx,
 		`SELECT id, name, mailbox_number, pin, greeting_file, greeting_type,
 		 email_notify, email_address, email_attach_audio, max_message_duration,
-		 max_messages, retention_days, notify_extension_id, created_at, updated_at
+		 max_messages, retention_days, notify_extension_id, name_file, created_at, updated_at
 		 FROM voicemail_boxes ORDER BY name`)
 	if err != nil {
 		return nil, fmt.Errorf("querying voicemail boxes: %w", err)
@@ -70,7 +71,7 @@ func (r *voicemailBoxRepo) List(ctx context.Context) ([]models.VoicemailBox, err
 		if err := rows.Scan(&b.ID, &b.Name, &b.MailboxNumber, &b.PIN, &b.GreetingFile,
 			&b.GreetingType, &b.EmailNotify, &b.EmailAddress, &b.EmailAttachAudio,
 			&b.MaxMessageDuration, &b.MaxMessages, &b.RetentionDays, &b.NotifyExtensionID,
-			&b.CreatedAt, &b.UpdatedAt); err != nil {
+			&b.NameFile, &b.CreatedAt, &b.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scanning voicemail box row: %w", err)
 		}
 		boxes = append(boxes, b)
@@ -83,7 +84,7 @@ func (r *voicemailBoxRepo) ListByNotifyExtensionID(ctx context.Context, extensio
 	rows, err := r.db.QueryContext(ctx,
 		`SELECT id, name, mailbox_number, pin, greeting_file, greeting_type,
 		 email_notify, email_address, email_attach_audio, max_message_duration,
-		 max_messages, retention_days, notify_extension_id, created_at, updated_at
+		 max_messages, retention_days, notify_extension_id, name_file, created_at, updated_at
 		 FROM voicemail_boxes WHERE notify_extension_id = ? ORDER BY name`, extensionID,
 	)
 	if err != nil {
@@ -97,7 +98,7 @@ func (r *voicemailBoxRepo) ListByNotifyExtensionID(ctx context.Context, extensio
 		if err := rows.Scan(&b.ID, &b.Name, &b.MailboxNumber, &b.PIN, &b.GreetingFile,
 			&b.GreetingType, &b.EmailNotify, &b.EmailAddress, &b.EmailAttachAudio,
 			&b.MaxMessageDuration, &b.MaxMessages, &b.RetentionDays, &b.NotifyExtensionID,
-			&b.CreatedAt, &b.UpdatedAt); err != nil {
+			&b.NameFile, &b.CreatedAt, &b.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scanning voicemail box row: %w", err)
 		}
 		boxes = append(boxes, b)
@@ -111,12 +112,12 @@ func (r *voicemailBoxRepo) Update(ctx context.Context, box *models.VoicemailBox)
 		`UPDATE voicemail_boxes SET name = ?, mailbox_number = ?, pin = ?,
 		 greeting_file = ?, greeting_type = ?, email_notify = ?, email_address = ?,
 		 email_attach_audio = ?, max_message_duration = ?, max_messages = ?,
-		 retention_days = ?, notify_extension_id = ?, updated_at = datetime('now')
+		 retention_days = ?, notify_extension_id = ?, name_file = ?, updated_at = datetime('now')
 		 WHERE id = ?`,
 		box.Name, box.MailboxNumber, box.PIN, box.GreetingFile, box.GreetingType,
 		box.EmailNotify, box.EmailAddress, box.EmailAttachAudio,
 		box.MaxMessageDuration, box.MaxMessages, box.RetentionDays,
-		box.NotifyExtensionID, box.ID,
+		box.NotifyExtensionID, box.NameFile, box.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("updating voicemail box: %w", err)
@@ -138,7 +139,7 @@ func (r *voicemailBoxRepo) scanOne(row *sql.Row) (*models.VoicemailBox, error) {
 	err := row.Scan(&b.ID, &b.Name, &b.MailboxNumber, &b.PIN, &b.GreetingFile,
 		&b.GreetingType, &b.EmailNotify, &b.EmailAddress, &b.EmailAttachAudio,
 		&b.MaxMessageDuration, &b.MaxMessages, &b.RetentionDays, &b.NotifyExtensionID,
-		&b.CreatedAt, &b.UpdatedAt)
+		&b.NameFile, &b.CreatedAt, &b.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
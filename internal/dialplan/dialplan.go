@@ -0,0 +1,175 @@
+// Package dialplan matches dialled numbers against outbound route patterns
+// and decides which classes of number an extension may call.
+package dialplan
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Call classes. An outbound route carries the class of the numbers it
+// matches; an extension's class of service is the widest class it may
+// call. Internal is only an extension class: such extensions may call
+// other extensions and emergency numbers.
+const (
+	ClassInternal      = "internal"
+	ClassEmergency     = "emergency"
+	ClassLocal         = "local"
+	ClassNational      = "national"
+	ClassInternational = "international"
+	ClassPremium       = "premium"
+)
+
+// classRank orders the classes an extension can be permitted, narrowest
+// first. Each permits every class before it.
+var classRank = map[string]int{
+	ClassInternal:      0,
+	ClassLocal:         1,
+	ClassNational:      2,
+	ClassInternational: 3,
+	ClassPremium:       4,
+}
+
+// ValidRouteClass reports whether class can be assigned to an outbound
+// route.
+func ValidRouteClass(class string) bool {
+	if class == ClassEmergency {
+		return true
+	}
+	_, ok := classRank[class]
+	return ok && class != ClassInternal
+}
+
+// ValidClassOfService reports whether class can be assigned to an
+// extension.
+func ValidClassOfService(class string) bool {
+	_, ok := classRank[class]
+	return ok
+}
+
+// Permits reports whether an extension with the given class of service
+// may call a number of the given class. Emergency numbers are always
+// permitted.
+func Permits(classOfService, class string) bool {
+	if class == ClassEmergency {
+		return true
+	}
+	have, ok := classRank[classOfService]
+	if !ok {
+		return false
+	}
+	want, ok := classRank[class]
+	return ok && want <= have
+}
+
+// regexPrefix marks a pattern as a regular expression.
+const regexPrefix = "re:"
+
+// ErrEmptyPattern is returned when compiling an empty pattern.
+var ErrEmptyPattern = errors.New("dialplan: empty pattern")
+
+// Pattern is a compiled dial pattern. Three forms are accepted:
+//
+//   - Asterisk-style, starting with "_": X matches any digit, Z 1-9, N 2-9,
+//     [15-7] any listed digit, "." one or more further characters and "!"
+//     zero or more. Other characters match themselves. "_1NXXNXXXXXX"
+//     matches a NANP number with a leading 1.
+//   - A regular expression, prefixed with "re:". It must match the whole
+//     number.
+//   - Anything else matches that exact number, e.g. "000" or "112".
+type Pattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// Compile parses a dial pattern. See Pattern for its syntax.
+func Compile(pattern string) (*Pattern, error) {
+	switch {
+	case pattern == "":
+		return nil, ErrEmptyPattern
+	case strings.HasPrefix(pattern, regexPrefix):
+		expr := strings.TrimPrefix(pattern, regexPrefix)
+		if expr == "" {
+			return nil, ErrEmptyPattern
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("dialplan: invalid regular expression %q: %w", expr, err)
+		}
+		return &Pattern{raw: pattern, re: re}, nil
+	case strings.HasPrefix(pattern, "_"):
+		expr, err := asteriskExpr(pattern[1:])
+		if err != nil {
+			return nil, fmt.Errorf("dialplan: invalid pattern %q: %w", pattern, err)
+		}
+		return &Pattern{raw: pattern, re: regexp.MustCompile(expr)}, nil
+	default:
+		return &Pattern{raw: pattern}, nil
+	}
+}
+
+// Match reports whether number matches the pattern.
+func (p *Pattern) Match(number string) bool {
+	if p.re == nil {
+		return number == p.raw
+	}
+	return p.re.MatchString(number)
+}
+
+// String returns the pattern as written.
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// asteriskExpr translates the body of an Asterisk-style pattern, without
+// its leading "_", to an anchored regular expression.
+func asteriskExpr(body string) (string, error) {
+	if body == "" {
+		return "", errors.New("nothing after \"_\"")
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch c {
+		case 'X', 'x':
+			b.WriteString("[0-9]")
+		case 'Z', 'z':
+			b.WriteString("[1-9]")
+		case 'N', 'n':
+			b.WriteString("[2-9]")
+		case '.':
+			b.WriteString(".+")
+		case '!':
+			b.WriteString(".*")
+		case '[':
+			end := strings.IndexByte(body[i:], ']')
+			if end < 2 {
+				return "", errors.New("unterminated or empty [ ]")
+			}
+			set := body[i+1 : i+end]
+			for j := 0; j < len(set); j++ {
+				if !isDialChar(set[j]) && set[j] != '-' {
+					return "", fmt.Errorf("invalid character %q in [ ]", set[j])
+				}
+			}
+			b.WriteString("[" + set + "]")
+			i += end
+		default:
+			if !isDialChar(c) {
+				return "", fmt.Errorf("invalid character %q", c)
+			}
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// isDialChar reports whether c can appear literally in a dialled number.
+func isDialChar(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '*' || c == '#' || c == '+'
+}
@@ -0,0 +1,85 @@
+package dialplan
+
+import "testing"
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		number  string
+		want    bool
+	}{
+		{"_1NXXNXXXXXX", "12125551234", true},
+		{"_1NXXNXXXXXX", "11125551234", false},
+		{"_1NXXNXXXXXX", "1212555123", false},
+		{"_0[2378]XXXXXXXX", "0298765432", true},
+		{"_0[2378]XXXXXXXX", "0498765432", false},
+		{"_0[4-5]XXXXXXXX", "0412345678", true},
+		{"_0011.", "00114420794600", true},
+		{"_0011.", "0011", false},
+		{"_190!", "190", true},
+		{"_190!", "1900123456", true},
+		{"_Z!", "0", false},
+		{"_+X.", "+61298765432", true},
+		{"re:13\\d{4}|1300\\d{6}", "131234", true},
+		{"re:13\\d{4}|1300\\d{6}", "1300123456", true},
+		{"re:13\\d{4}", "1312345", false},
+		{"000", "000", true},
+		{"000", "0000", false},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.number); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, pattern := range []string{"", "_", "_1[", "_1[]", "_1a", "re:", "re:(", "_[2a]"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestPermits(t *testing.T) {
+	tests := []struct {
+		classOfService string
+		class          string
+		want           bool
+	}{
+		{ClassInternal, ClassEmergency, true},
+		{ClassInternal, ClassLocal, false},
+		{ClassLocal, ClassLocal, true},
+		{ClassLocal, ClassNational, false},
+		{ClassNational, ClassLocal, true},
+		{ClassInternational, ClassInternational, true},
+		{ClassInternational, ClassPremium, false},
+		{ClassPremium, ClassInternational, true},
+		{"", ClassLocal, false},
+		{ClassPremium, "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := Permits(tt.classOfService, tt.class); got != tt.want {
+			t.Errorf("Permits(%q, %q) = %v, want %v", tt.classOfService, tt.class, got, tt.want)
+		}
+	}
+}
+
+func TestValidClasses(t *testing.T) {
+	if ValidRouteClass(ClassInternal) {
+		t.Error("internal should not be a route class")
+	}
+	if !ValidRouteClass(ClassEmergency) || !ValidRouteClass(ClassPremium) {
+		t.Error("emergency and premium should be route classes")
+	}
+	if ValidClassOfService(ClassEmergency) {
+		t.Error("emergency should not be a class of service")
+	}
+	if !ValidClassOfService(ClassInternal) {
+		t.Error("internal should be a class of service")
+	}
+}
@@ -1,6 +1,8 @@
 package flow
 
 import (
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -41,12 +43,25 @@ type CallContext struct {
 	Variables map[string]string
 
 	// FlowPath records the ordered list of node IDs visited during traversal.
+	// Nodes of a sub-flow are recorded as "<flow id>/<node id>".
 	FlowPath []string
 
 	// StartTime is when the flow execution began.
 	StartTime time.Time
 
-	// mu protects concurrent access to mutable fields (DTMF, Variables, FlowPath).
+	// sim is set on calls run by the flow simulator.
+	sim *simulation
+
+	// flows is the stack of flows the call is executing, outermost first.
+	// Sub-flow nodes push onto it.
+	flows []int64
+
+	// flowExit is the exit named by the Return node that ended the
+	// current sub-flow.
+	flowExit string
+
+	// mu protects concurrent access to mutable fields (DTMF, Variables,
+	// FlowPath, flows, flowExit).
 	mu sync.Mutex
 }
 
@@ -111,6 +126,17 @@ func (c *CallContext) GetVariable(key string) string {
 	return c.Variables[key]
 }
 
+// GetVariables returns a copy of all variables in the call context.
+func (c *CallContext) GetVariables() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vars := make(map[string]string, len(c.Variables))
+	for k, v := range c.Variables {
+		vars[k] = v
+	}
+	return vars
+}
+
 // RecordNode appends a node ID to the traversal path.
 func (c *CallContext) RecordNode(nodeID string) {
 	c.mu.Lock()
@@ -126,3 +152,67 @@ func (c *CallContext) GetFlowPath() []string {
 	copy(path, c.FlowPath)
 	return path
 }
+
+// ReturnFromSubFlow ends the sub-flow the call is executing once the
+// current node returns, resuming the calling flow on the output edge named
+// exit.
+func (c *CallContext) ReturnFromSubFlow(exit string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flowExit = exit
+}
+
+// takeFlowExit returns and clears the exit set by ReturnFromSubFlow.
+func (c *CallContext) takeFlowExit() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exit := c.flowExit
+	c.flowExit = ""
+	return exit
+}
+
+// enterFlow pushes a flow onto the call's flow stack. It fails if the flow
+// is already on the stack or the stack is full. A zero flow ID stands for
+// an unsaved graph and is not checked for loops.
+func (c *CallContext) enterFlow(flowID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.flows) > maxSubFlowDepth {
+		return ErrSubFlowDepth
+	}
+	if flowID != 0 && slices.Contains(c.flows, flowID) {
+		return fmt.Errorf("%w: flow %d", ErrSubFlowLoop, flowID)
+	}
+	c.flows = append(c.flows, flowID)
+	return nil
+}
+
+// leaveFlow pops the innermost flow from the call's flow stack.
+func (c *CallContext) leaveFlow() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.flows) > 0 {
+		c.flows = c.flows[:len(c.flows)-1]
+	}
+}
+
+// pathNodeID returns the ID under which a node of the innermost flow is
+// recorded in the flow path.
+func (c *CallContext) pathNodeID(nodeID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.flows) < 2 {
+		return nodeID
+	}
+	return fmt.Sprintf("%d/%s", c.flows[len(c.flows)-1], nodeID)
+}
+
+// Simulation returns the script of a call run by the flow simulator, or nil
+// for a real call. Node handlers use it to skip side effects such as
+// storing voicemail or sending webhooks on a dry run.
+func (c *CallContext) Simulation() *SimulatedCall {
+	if c.sim == nil {
+		return nil
+	}
+	return &c.sim.call
+}
@@ -0,0 +1,160 @@
+package flow
+
+import "reflect"
+
+// ChangeKind describes how a node or edge differs between two flow graphs.
+type ChangeKind string
+
+const (
+	// ChangeAdded marks a node or edge only in the newer graph.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved marks a node or edge only in the older graph.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified marks a node or edge in both graphs that differs.
+	ChangeModified ChangeKind = "modified"
+)
+
+// NodeChange describes a node that differs between two flow graphs. Fields
+// lists what changed on a modified node: "type", "label", "entity",
+// "config" and "position". A change of position alone moves the node on
+// the canvas without affecting call routing.
+type NodeChange struct {
+	NodeID string     `json:"node_id"`
+	Change ChangeKind `json:"change"`
+	Fields []string   `json:"fields,omitempty"`
+	From   *Node      `json:"from,omitempty"`
+	To     *Node      `json:"to,omitempty"`
+}
+
+// EdgeChange describes an edge that differs between two flow graphs.
+// Fields lists what changed on a modified edge: "source", "target",
+// "source_handle", "target_handle" and "label".
+type EdgeChange struct {
+	EdgeID string     `json:"edge_id"`
+	Change ChangeKind `json:"change"`
+	Fields []string   `json:"fields,omitempty"`
+	From   *Edge      `json:"from,omitempty"`
+	To     *Edge      `json:"to,omitempty"`
+}
+
+// GraphDiff holds the node and edge changes from one flow graph to another.
+type GraphDiff struct {
+	Nodes []NodeChange `json:"nodes"`
+	Edges []EdgeChange `json:"edges"`
+}
+
+// DiffGraphs compares two flow graphs node by node and edge by edge,
+// matching them by ID. Removed and modified entries come first, in the
+// order of from, followed by added entries in the order of to.
+func DiffGraphs(from, to *FlowGraph) GraphDiff {
+	d := GraphDiff{Nodes: []NodeChange{}, Edges: []EdgeChange{}}
+
+	toNodes := make(map[string]*Node, len(to.Nodes))
+	for i := range to.Nodes {
+		toNodes[to.Nodes[i].ID] = &to.Nodes[i]
+	}
+	fromNodes := make(map[string]bool, len(from.Nodes))
+	for i := range from.Nodes {
+		a := &from.Nodes[i]
+		fromNodes[a.ID] = true
+		b, ok := toNodes[a.ID]
+		if !ok {
+			d.Nodes = append(d.Nodes, NodeChange{NodeID: a.ID, Change: ChangeRemoved, From: a})
+			continue
+		}
+		if fields := nodeFieldChanges(a, b); len(fields) > 0 {
+			d.Nodes = append(d.Nodes, NodeChange{NodeID: a.ID, Change: ChangeModified, Fields: fields, From: a, To: b})
+		}
+	}
+	for i := range to.Nodes {
+		b := &to.Nodes[i]
+		if !fromNodes[b.ID] {
+			d.Nodes = append(d.Nodes, NodeChange{NodeID: b.ID, Change: ChangeAdded, To: b})
+		}
+	}
+
+	toEdges := make(map[string]*Edge, len(to.Edges))
+	for i := range to.Edges {
+		toEdges[to.Edges[i].ID] = &to.Edges[i]
+	}
+	fromEdges := make(map[string]bool, len(from.Edges))
+	for i := range from.Edges {
+		a := &from.Edges[i]
+		fromEdges[a.ID] = true
+		b, ok := toEdges[a.ID]
+		if !ok {
+			d.Edges = append(d.Edges, EdgeChange{EdgeID: a.ID, Change: ChangeRemoved, From: a})
+			continue
+		}
+		if fields := edgeFieldChanges(a, b); len(fields) > 0 {
+			d.Edges = append(d.Edges, EdgeChange{EdgeID: a.ID, Change: ChangeModified, Fields: fields, From: a, To: b})
+		}
+	}
+	for i := range to.Edges {
+		b := &to.Edges[i]
+		if !fromEdges[b.ID] {
+			d.Edges = append(d.Edges, EdgeChange{EdgeID: b.ID, Change: ChangeAdded, To: b})
+		}
+	}
+
+	return d
+}
+
+// nodeFieldChanges returns the names of the fields that differ between two
+// versions of a node.
+func nodeFieldChanges(a, b *Node) []string {
+	var fields []string
+	if a.Type != b.Type {
+		fields = append(fields, "type")
+	}
+	if a.Data.Label != b.Data.Label {
+		fields = append(fields, "label")
+	}
+	if a.Data.EntityType != b.Data.EntityType || !equalEntityID(a.Data.EntityID, b.Data.EntityID) {
+		fields = append(fields, "entity")
+	}
+	if !equalConfig(a.Data.Config, b.Data.Config) {
+		fields = append(fields, "config")
+	}
+	if a.Position != b.Position {
+		fields = append(fields, "position")
+	}
+	return fields
+}
+
+// edgeFieldChanges returns the names of the fields that differ between two
+// versions of an edge.
+func edgeFieldChanges(a, b *Edge) []string {
+	var fields []string
+	if a.Source != b.Source {
+		fields = append(fields, "source")
+	}
+	if a.Target != b.Target {
+		fields = append(fields, "target")
+	}
+	if a.SourceHandle != b.SourceHandle {
+		fields = append(fields, "source_handle")
+	}
+	if a.TargetHandle != b.TargetHandle {
+		fields = append(fields, "target_handle")
+	}
+	if a.Label != b.Label {
+		fields = append(fields, "label")
+	}
+	return fields
+}
+
+func equalEntityID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// equalConfig compares node configs, treating a missing config as empty.
+func equalConfig(a, b map[string]any) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
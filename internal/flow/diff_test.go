@@ -0,0 +1,105 @@
+package flow
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffGraphs(t *testing.T) {
+	from, err := ParseFlowGraph(`{
+		"nodes": [
+			{"id": "in", "type": "inbound_number", "position": {"x": 0, "y": 0}, "data": {"label": "Main", "entity_id": 1, "entity_type": "inbound_number"}},
+			{"id": "ivr", "type": "ivr_menu", "position": {"x": 100, "y": 0}, "data": {"label": "Menu", "config": {"timeout": 5}}},
+			{"id": "vm", "type": "voicemail", "position": {"x": 200, "y": 0}, "data": {"label": "Voicemail"}},
+			{"id": "hup", "type": "hangup", "position": {"x": 300, "y": 0}, "data": {"label": "Bye"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "in", "target": "ivr", "sourceHandle": "next"},
+			{"id": "e2", "source": "ivr", "target": "vm", "sourceHandle": "1"},
+			{"id": "e3", "source": "ivr", "target": "hup", "sourceHandle": "timeout"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := ParseFlowGraph(`{
+		"nodes": [
+			{"id": "in", "type": "inbound_number", "position": {"x": 0, "y": 0}, "data": {"label": "Main", "entity_id": 1, "entity_type": "inbound_number"}},
+			{"id": "ivr", "type": "ivr_menu", "position": {"x": 100, "y": 0}, "data": {"label": "Menu", "config": {"timeout": 10}}},
+			{"id": "vm", "type": "voicemail", "position": {"x": 250, "y": 40}, "data": {"label": "Voicemail"}},
+			{"id": "rg", "type": "ring_group", "position": {"x": 200, "y": 100}, "data": {"label": "Sales", "entity_id": 3, "entity_type": "ring_group"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "in", "target": "ivr", "sourceHandle": "next"},
+			{"id": "e2", "source": "ivr", "target": "rg", "sourceHandle": "1"},
+			{"id": "e4", "source": "ivr", "target": "vm", "sourceHandle": "timeout"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := DiffGraphs(from, to)
+
+	wantNodes := []struct {
+		id     string
+		change ChangeKind
+		fields []string
+	}{
+		{"ivr", ChangeModified, []string{"config"}},
+		{"vm", ChangeModified, []string{"position"}},
+		{"hup", ChangeRemoved, nil},
+		{"rg", ChangeAdded, nil},
+	}
+	if len(d.Nodes) != len(wantNodes) {
+		t.Fatalf("got %d node changes, want %d: %+v", len(d.Nodes), len(wantNodes), d.Nodes)
+	}
+	for i, w := range wantNodes {
+		got := d.Nodes[i]
+		if got.NodeID != w.id || got.Change != w.change || !slices.Equal(got.Fields, w.fields) {
+			t.Errorf("node change %d = %s %s %v, want %s %s %v", i, got.NodeID, got.Change, got.Fields, w.id, w.change, w.fields)
+		}
+	}
+	if d.Nodes[2].From == nil || d.Nodes[2].To != nil {
+		t.Error("removed node should carry only its old version")
+	}
+	if d.Nodes[3].To == nil || d.Nodes[3].From != nil {
+		t.Error("added node should carry only its new version")
+	}
+
+	wantEdges := []struct {
+		id     string
+		change ChangeKind
+		fields []string
+	}{
+		{"e2", ChangeModified, []string{"target"}},
+		{"e3", ChangeRemoved, nil},
+		{"e4", ChangeAdded, nil},
+	}
+	if len(d.Edges) != len(wantEdges) {
+		t.Fatalf("got %d edge changes, want %d: %+v", len(d.Edges), len(wantEdges), d.Edges)
+	}
+	for i, w := range wantEdges {
+		got := d.Edges[i]
+		if got.EdgeID != w.id || got.Change != w.change || !slices.Equal(got.Fields, w.fields) {
+			t.Errorf("edge change %d = %s %s %v, want %s %s %v", i, got.EdgeID, got.Change, got.Fields, w.id, w.change, w.fields)
+		}
+	}
+}
+
+func TestDiffGraphsIdentical(t *testing.T) {
+	g, err := ParseFlowGraph(`{
+		"nodes": [{"id": "a", "type": "hangup", "data": {"label": "Bye", "config": {}}}],
+		"edges": []
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A missing config is the same as an empty one.
+	h, _ := ParseFlowGraph(`{"nodes": [{"id": "a", "type": "hangup", "data": {"label": "Bye"}}]}`)
+
+	d := DiffGraphs(g, h)
+	if len(d.Nodes) != 0 || len(d.Edges) != 0 {
+		t.Errorf("expected no changes, got %+v", d)
+	}
+}
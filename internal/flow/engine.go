@@ -16,6 +16,14 @@ import (
 // Default timeout per node if none is specified in node config.
 const defaultNodeTimeout = 30 * time.Second
 
+// maxFlowSteps bounds the number of nodes a call may visit. Nodes such as
+// conditions do not wait on the caller, so a loop of them would otherwise
+// spin forever.
+const maxFlowSteps = 1000
+
+// maxSubFlowDepth bounds how deeply sub-flow nodes may nest.
+const maxSubFlowDepth = 8
+
 // ErrFlowNotFound is returned when the specified flow does not exist.
 var ErrFlowNotFound = errors.New("flow not found")
 
@@ -31,6 +39,18 @@ var ErrNoMatchingEdge = errors.New("no matching output edge")
 // ErrNodeHandlerNotFound is returned when no handler is registered for a node type.
 var ErrNodeHandlerNotFound = errors.New("no handler registered for node type")
 
+// ErrFlowStepLimit is returned when a call visits more than maxFlowSteps
+// nodes, which means the flow loops without waiting on the caller.
+var ErrFlowStepLimit = errors.New("call visited too many flow nodes")
+
+// ErrSubFlowLoop is returned when a sub-flow node enters a flow the call is
+// already executing.
+var ErrSubFlowLoop = errors.New("sub-flow re-enters a calling flow")
+
+// ErrSubFlowDepth is returned when sub-flows nest more than maxSubFlowDepth
+// deep.
+var ErrSubFlowDepth = errors.New("sub-flows nested too deeply")
+
 // Node represents a single node in the flow graph, parsed from the React Flow JSON.
 type Node struct {
 	ID       string   `json:"id"`
@@ -77,6 +97,22 @@ type NodeHandler interface {
 	Execute(ctx context.Context, callCtx *CallContext, node Node) (outputEdge string, err error)
 }
 
+// NodeTimeoutProvider is an optional interface for node handlers whose
+// execution is expected to outlast the default per-node timeout (e.g. a
+// queue holding callers for minutes). An explicit "timeout" in the node
+// config still takes precedence.
+type NodeTimeoutProvider interface {
+	NodeTimeout(node Node) time.Duration
+}
+
+// FallbackEdgeProvider is an optional interface for node handlers with
+// output edges to follow when the edge they return is not wired, e.g. so
+// flows saved before the node gained more specific outputs keep working.
+// FallbackEdges returns the edges to try, in order, after outputEdge.
+type FallbackEdgeProvider interface {
+	FallbackEdges(callCtx *CallContext, node Node, outputEdge string) []string
+}
+
 // EntityResolver loads a database entity by ID and type. This allows node
 // handlers to look up the entity (extension, ring group, voicemail box, etc.)
 // referenced by the node's entity_id/entity_type fields.
@@ -92,6 +128,9 @@ type Engine struct {
 	handlers map[string]NodeHandler
 	resolver EntityResolver
 	logger   *slog.Logger
+
+	// simulator marks an engine created by NewSimulator.
+	simulator bool
 }
 
 // NewEngine creates a new flow engine.
@@ -115,33 +154,15 @@ func (e *Engine) RegisterHandler(nodeType string, handler NodeHandler) {
 	e.handlers[nodeType] = handler
 }
 
-// ExecuteFlow loads the published flow, finds the entry node, and walks the graph.
+// ExecuteFlow loads the pinned published revision of the flow, finds the entry
+// node, and walks the graph. Unpublished edits to the flow do not affect calls.
 // It updates the CDR with the flow traversal path when complete.
 func (e *Engine) ExecuteFlow(callCtx *CallContext, flowID int64, entryNodeID string) error {
 	ctx := context.Background()
 
-	// Load the published flow.
-	flow, err := e.flows.GetPublished(ctx, flowID)
+	flow, graph, nodeMap, err := e.loadPublishedGraph(ctx, flowID)
 	if err != nil {
-		return fmt.Errorf("loading flow: %w", err)
-	}
-	if flow == nil {
-		return ErrFlowNotFound
-	}
-	if !flow.Published {
-		return ErrFlowNotPublished
-	}
-
-	// Parse the flow graph JSON.
-	graph, err := ParseFlowGraph(flow.FlowData)
-	if err != nil {
-		return fmt.Errorf("parsing flow graph: %w", err)
-	}
-
-	// Build lookup maps for fast access.
-	nodeMap := make(map[string]Node, len(graph.Nodes))
-	for _, n := range graph.Nodes {
-		nodeMap[n.ID] = n
+		return err
 	}
 
 	// Find the entry node.
@@ -150,9 +171,15 @@ func (e *Engine) ExecuteFlow(callCtx *CallContext, flowID int64, entryNodeID str
 		return ErrEntryNodeNotFound
 	}
 
+	if err := callCtx.enterFlow(flowID); err != nil {
+		return err
+	}
+	defer callCtx.leaveFlow()
+
 	e.logger.Info("starting flow execution",
 		"call_id", callCtx.CallID,
 		"flow_id", flowID,
+		"flow_revision", flow.PublishedRevision,
 		"entry_node", entryNodeID,
 	)
 
@@ -173,6 +200,14 @@ func (e *Engine) ExecuteFlow(callCtx *CallContext, flowID int64, entryNodeID str
 		return err
 	}
 
+	if exit := callCtx.takeFlowExit(); exit != "" {
+		e.logger.Warn("return node reached outside a sub-flow",
+			"call_id", callCtx.CallID,
+			"flow_id", flowID,
+			"exit", exit,
+		)
+	}
+
 	e.logger.Info("flow execution completed",
 		"call_id", callCtx.CallID,
 		"flow_id", flowID,
@@ -182,11 +217,158 @@ func (e *Engine) ExecuteFlow(callCtx *CallContext, flowID int64, entryNodeID str
 	return nil
 }
 
+// ExecuteSubFlow runs the pinned published revision of another flow for a
+// call that reached a sub-flow node, starting at entryNodeID. The sub-flow
+// shares the call's variables. It returns the exit named by the Return
+// node that ended the sub-flow, or "" if the sub-flow ended the call. A
+// sub-flow may not enter a flow the call is already executing.
+func (e *Engine) ExecuteSubFlow(ctx context.Context, callCtx *CallContext, flowID int64, entryNodeID string) (string, error) {
+	if err := callCtx.enterFlow(flowID); err != nil {
+		return "", fmt.Errorf("sub-flow %d: %w", flowID, err)
+	}
+	defer callCtx.leaveFlow()
+
+	flow, graph, nodeMap, err := e.loadPublishedGraph(ctx, flowID)
+	if err != nil {
+		return "", fmt.Errorf("sub-flow %d: %w", flowID, err)
+	}
+	entryNode, ok := nodeMap[entryNodeID]
+	if !ok {
+		return "", fmt.Errorf("sub-flow %d: %w: %s", flowID, ErrEntryNodeNotFound, entryNodeID)
+	}
+
+	e.logger.Info("entering sub-flow",
+		"call_id", callCtx.CallID,
+		"flow_id", flowID,
+		"flow_revision", flow.PublishedRevision,
+		"entry_node", entryNodeID,
+	)
+
+	if err := e.walkGraph(ctx, callCtx, entryNode, nodeMap, graph.Edges); err != nil {
+		return "", fmt.Errorf("sub-flow %d: %w", flowID, err)
+	}
+
+	exit := callCtx.takeFlowExit()
+	e.logger.Info("leaving sub-flow",
+		"call_id", callCtx.CallID,
+		"flow_id", flowID,
+		"exit", exit,
+	)
+	return exit, nil
+}
+
+// loadPublishedGraph loads and parses the pinned published revision of a
+// flow, indexing its nodes by ID.
+func (e *Engine) loadPublishedGraph(ctx context.Context, flowID int64) (*models.CallFlow, *FlowGraph, map[string]Node, error) {
+	if e.flows == nil {
+		return nil, nil, nil, fmt.Errorf("loading flow: no flow repository configured")
+	}
+	flow, err := e.flows.GetPublished(ctx, flowID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loading flow: %w", err)
+	}
+	if flow == nil {
+		return nil, nil, nil, ErrFlowNotFound
+	}
+	if !flow.Published {
+		return nil, nil, nil, ErrFlowNotPublished
+	}
+
+	graph, err := ParseFlowGraph(flow.FlowData)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing flow graph: %w", err)
+	}
+
+	nodeMap := make(map[string]Node, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		nodeMap[n.ID] = n
+	}
+	return flow, graph, nodeMap, nil
+}
+
+// CheckEntry reports whether a call can enter the published revision of
+// flow flowID at node entryNodeID, as ExecuteFlow would. It returns an
+// error wrapping ErrFlowNotFound, ErrFlowNotPublished or
+// ErrEntryNodeNotFound if it cannot, or another error if the flow could
+// not be loaded.
+func CheckEntry(ctx context.Context, flows database.CallFlowRepository, flowID int64, entryNodeID string) error {
+	f, err := flows.GetPublished(ctx, flowID)
+	if err != nil {
+		return fmt.Errorf("loading flow %d: %w", flowID, err)
+	}
+	if f == nil || !f.Published {
+		draft, err := flows.GetByID(ctx, flowID)
+		if err != nil {
+			return fmt.Errorf("loading flow %d: %w", flowID, err)
+		}
+		if draft == nil {
+			return fmt.Errorf("flow %d: %w", flowID, ErrFlowNotFound)
+		}
+		return fmt.Errorf("flow %q: %w", draft.Name, ErrFlowNotPublished)
+	}
+
+	graph, err := ParseFlowGraph(f.FlowData)
+	if err != nil {
+		return fmt.Errorf("parsing flow %d: %w", flowID, err)
+	}
+	if err := CheckEntryNode(graph, entryNodeID); err != nil {
+		return fmt.Errorf("flow %q: %w", f.Name, err)
+	}
+	return nil
+}
+
+// CheckEntryNode reports whether a call can enter graph at node
+// entryNodeID. It returns an error wrapping ErrEntryNodeNotFound if the
+// graph has no such node.
+func CheckEntryNode(graph *FlowGraph, entryNodeID string) error {
+	for _, n := range graph.Nodes {
+		if n.ID == entryNodeID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrEntryNodeNotFound, entryNodeID)
+}
+
+// ExecuteNode runs a single node handler outside of a flow graph, e.g. for a
+// feature code that reuses a node's behaviour. The node's timeout applies
+// as it would inside a flow. It returns the handler's output edge.
+func (e *Engine) ExecuteNode(callCtx *CallContext, node Node) (string, error) {
+	handler, ok := e.handlers[node.Type]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNodeHandlerNotFound, node.Type)
+	}
+
+	callCtx.RecordNode(node.ID)
+
+	e.logger.Debug("executing standalone node",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+		"node_type", node.Type,
+	)
+
+	nodeCtx, cancel := context.WithTimeout(context.Background(), e.nodeTimeout(node, handler))
+	defer cancel()
+
+	outputEdge, err := handler.Execute(nodeCtx, callCtx, node)
+	if err != nil {
+		return "", fmt.Errorf("executing node %s (%s): %w", node.ID, node.Type, err)
+	}
+	return outputEdge, nil
+}
+
 // walkGraph executes nodes sequentially, following edges after each execution.
 func (e *Engine) walkGraph(ctx context.Context, callCtx *CallContext, currentNode Node, nodeMap map[string]Node, edges []Edge) error {
 	for {
+		steps := len(callCtx.GetFlowPath())
+		if callCtx.sim != nil && steps >= maxSimulatedSteps {
+			return ErrSimulationStepLimit
+		}
+		if steps >= maxFlowSteps {
+			return ErrFlowStepLimit
+		}
+
 		// Record this node in the traversal path.
-		callCtx.RecordNode(currentNode.ID)
+		callCtx.RecordNode(callCtx.pathNodeID(currentNode.ID))
 
 		e.logger.Debug("executing node",
 			"call_id", callCtx.CallID,
@@ -202,7 +384,7 @@ func (e *Engine) walkGraph(ctx context.Context, callCtx *CallContext, currentNod
 		}
 
 		// Determine the timeout for this node.
-		timeout := e.nodeTimeout(currentNode)
+		timeout := e.nodeTimeout(currentNode, handler)
 		nodeCtx, cancel := context.WithTimeout(ctx, timeout)
 
 		// Execute the node handler.
@@ -236,6 +418,22 @@ func (e *Engine) walkGraph(ctx context.Context, callCtx *CallContext, currentNod
 
 		// Find the matching edge from this node with the given sourceHandle.
 		nextNodeID, err := e.followEdge(currentNode.ID, outputEdge, edges)
+		if p, ok := handler.(FallbackEdgeProvider); ok && errors.Is(err, ErrNoMatchingEdge) {
+			for _, fallback := range p.FallbackEdges(callCtx, currentNode, outputEdge) {
+				id, ferr := e.followEdge(currentNode.ID, fallback, edges)
+				if ferr != nil {
+					continue
+				}
+				e.logger.Debug("output edge not wired, following fallback edge",
+					"call_id", callCtx.CallID,
+					"node_id", currentNode.ID,
+					"output_edge", outputEdge,
+					"fallback_edge", fallback,
+				)
+				nextNodeID, err = id, nil
+				break
+			}
+		}
 		if err != nil {
 			return fmt.Errorf("following edge from node %s handle %q: %w", currentNode.ID, outputEdge, err)
 		}
@@ -261,8 +459,9 @@ func (e *Engine) followEdge(sourceNodeID, sourceHandle string, edges []Edge) (st
 }
 
 // nodeTimeout returns the timeout duration for a node. If the node's config
-// specifies a "timeout" value (in seconds), that is used; otherwise the default.
-func (e *Engine) nodeTimeout(node Node) time.Duration {
+// specifies a "timeout" value (in seconds), that is used; otherwise the
+// handler's own timeout if it implements NodeTimeoutProvider, or the default.
+func (e *Engine) nodeTimeout(node Node, handler NodeHandler) time.Duration {
 	if node.Data.Config != nil {
 		if v, ok := node.Data.Config["timeout"]; ok {
 			switch t := v.(type) {
@@ -277,6 +476,11 @@ func (e *Engine) nodeTimeout(node Node) time.Duration {
 			}
 		}
 	}
+	if p, ok := handler.(NodeTimeoutProvider); ok {
+		if t := p.NodeTimeout(node); t > 0 {
+			return t
+		}
+	}
 	return defaultNodeTimeout
 }
 
@@ -366,6 +570,7 @@ func (e *Engine) ResolveNodeEntity(ctx context.Context, node Node) (any, error)
 type defaultEntityResolver struct {
 	extensions     database.ExtensionRepository
 	ringGroups     database.RingGroupRepository
+	queues         database.QueueRepository
 	voicemailBoxes database.VoicemailBoxRepository
 	ivrMenus       database.IVRMenuRepository
 	timeSwitches   database.TimeSwitchRepository
@@ -377,6 +582,7 @@ type defaultEntityResolver struct {
 func NewEntityResolver(
 	extensions database.ExtensionRepository,
 	ringGroups database.RingGroupRepository,
+	queues database.QueueRepository,
 	voicemailBoxes database.VoicemailBoxRepository,
 	ivrMenus database.IVRMenuRepository,
 	timeSwitches database.TimeSwitchRepository,
@@ -386,6 +592,7 @@ func NewEntityResolver(
 	return &defaultEntityResolver{
 		extensions:     extensions,
 		ringGroups:     ringGroups,
+		queues:         queues,
 		voicemailBoxes: voicemailBoxes,
 		ivrMenus:       ivrMenus,
 		timeSwitches:   timeSwitches,
@@ -401,6 +608,8 @@ func (r *defaultEntityResolver) ResolveEntity(ctx context.Context, entityType st
 		return r.extensions.GetByID(ctx, entityID)
 	case "ring_group":
 		return r.ringGroups.GetByID(ctx, entityID)
+	case "queue":
+		return r.queues.GetByID(ctx, entityID)
 	case "voicemail_box":
 		return r.voicemailBoxes.GetByID(ctx, entityID)
 	case "ivr_menu":
@@ -0,0 +1,898 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxExprLen limits the length of an expression's source.
+	maxExprLen = 2000
+
+	// maxExprDepth limits the nesting of an expression.
+	maxExprDepth = 32
+
+	// maxExprPatternLen limits the length of a regular expression.
+	maxExprPatternLen = 500
+)
+
+// exprCallFields are the names that read call fields rather than flow
+// variables. Set Variable nodes cannot assign them.
+var exprCallFields = map[string]func(*CallContext) string{
+	"call_id":        func(c *CallContext) string { return c.CallID },
+	"caller_id_name": func(c *CallContext) string { return c.CallerIDName },
+	"caller_id_num":  func(c *CallContext) string { return c.CallerIDNum },
+	"callee":         func(c *CallContext) string { return c.Callee },
+	"did":            callDID,
+	"dtmf":           func(c *CallContext) string { return c.GetDTMF() },
+}
+
+// Expr is a parsed expression, evaluated by the Condition and Set Variable
+// nodes against the state of a call. The language has no loops or function
+// definitions, so evaluation always finishes in time linear in the length
+// of the expression; regular expressions use RE2 syntax, which is linear
+// in the input as well.
+//
+//	caller_id_num                       call fields and flow variables by name
+//	"gold"  'gold'  42  1.5  true       string, number and boolean literals
+//	== != < <= > >=                     comparisons
+//	=~ !~                               regular expression match and non-match
+//	+ - * / %                           arithmetic; + joins two strings
+//	&& || !  (or: and or not)           logic, short-circuited
+//	starts_with(caller_id_num, "04", "+614")
+//
+// Comparisons and + are numeric when either side is a number, so
+// webhook_status == 200 and retries + 1 work on variables, which are always
+// strings; otherwise they compare and join strings, so caller IDs keep
+// their leading zeros.
+//
+// The call fields are call_id, caller_id_name, caller_id_num, callee, did
+// and dtmf. Any other name is a flow variable; a variable that is not set
+// is the empty string. var("name") reads a variable whose name is not a
+// valid identifier.
+//
+// Functions:
+//
+//	starts_with(s, prefix...)  s starts with any of the prefixes
+//	ends_with(s, suffix...)    s ends with any of the suffixes
+//	contains(s, substr)        s contains substr
+//	matches(s, pattern)        s matches the regular expression
+//	len(s)                     number of characters in s
+//	lower(s), upper(s), trim(s)
+//	default(s, fallback)       s, or fallback when s is empty
+//	var(name)                  the flow variable called name
+//
+// An Expr is safe for concurrent use.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// ParseExpr parses an expression, reporting syntax errors, unknown
+// functions and invalid regular expression literals.
+func ParseExpr(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, errors.New("expression is empty")
+	}
+	if len(src) > maxExprLen {
+		return nil, fmt.Errorf("expression is longer than %d characters", maxExprLen)
+	}
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression for a call and returns its value as a
+// string. Booleans are "true" or "false".
+func (e *Expr) Eval(callCtx *CallContext) (string, error) {
+	v, err := e.root.eval(callCtx)
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// EvalBool evaluates the expression for a call as a condition. false, 0,
+// the empty string and the strings "0" and "false" are false; every other
+// value is true.
+func (e *Expr) EvalBool(callCtx *CallContext) (bool, error) {
+	v, err := e.root.eval(callCtx)
+	if err != nil {
+		return false, err
+	}
+	return v.truthy(), nil
+}
+
+// ValidateVariableName reports whether name can be assigned by a Set
+// Variable node: an identifier that is not one of the call fields.
+func ValidateVariableName(name string) error {
+	if !isExprIdent(name) {
+		return fmt.Errorf("variable name %q must start with a letter or underscore and contain only letters, digits and underscores", name)
+	}
+	if _, ok := exprCallFields[name]; ok {
+		return fmt.Errorf("variable name %q is reserved for the call field", name)
+	}
+	if _, ok := exprKeywords[name]; ok {
+		return fmt.Errorf("variable name %q is reserved", name)
+	}
+	return nil
+}
+
+// --- values ---
+
+type exprKind int
+
+const (
+	kindString exprKind = iota
+	kindNumber
+	kindBool
+)
+
+// exprValue is the result of evaluating an expression node.
+type exprValue struct {
+	kind exprKind
+	str  string
+	num  float64
+	b    bool
+}
+
+func stringValue(s string) exprValue  { return exprValue{kind: kindString, str: s} }
+func numberValue(n float64) exprValue { return exprValue{kind: kindNumber, num: n} }
+func boolValue(b bool) exprValue      { return exprValue{kind: kindBool, b: b} }
+
+func (v exprValue) String() string {
+	switch v.kind {
+	case kindNumber:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case kindBool:
+		return strconv.FormatBool(v.b)
+	default:
+		return v.str
+	}
+}
+
+// number converts the value to a number. Strings convert when they hold a
+// decimal number, ignoring surrounding space.
+func (v exprValue) number() (float64, bool) {
+	switch v.kind {
+	case kindNumber:
+		return v.num, true
+	case kindString:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, false
+		}
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func (v exprValue) truthy() bool {
+	switch v.kind {
+	case kindBool:
+		return v.b
+	case kindNumber:
+		return v.num != 0
+	default:
+		return v.str != "" && v.str != "0" && !strings.EqualFold(v.str, "false")
+	}
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type exprToken struct {
+	kind tokKind
+	text string // identifier, operator, or decoded string literal
+	num  float64
+	pos  int
+}
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// exprKeywords are the identifiers with a meaning of their own.
+var exprKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "true": true, "false": true,
+}
+
+// exprOps lists the operators, longest first so that "==" is not read as
+// two tokens.
+var exprOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%"}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, exprToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, exprToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			toks = append(toks, exprToken{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at position %d", err, i+1)
+			}
+			toks = append(toks, exprToken{kind: tokString, text: s, pos: i})
+			i += n
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", src[i:j], i+1)
+			}
+			toks = append(toks, exprToken{kind: tokNumber, text: src[i:j], num: n, pos: i})
+			i = j
+		case c == '_' || c < utf8.RuneSelf && unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] < utf8.RuneSelf && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])))) {
+				j++
+			}
+			toks = append(toks, exprToken{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range exprOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i+1)
+			}
+			toks = append(toks, exprToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, exprToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString decodes the string literal at the start of s and returns it
+// with the number of bytes consumed. Backslash escapes \\, \", \', \n and
+// \t are recognised; any other escaped character stands for itself, so
+// regular expressions such as "\d" need no doubled backslash.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '"', '\'':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+func isExprIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r < utf8.RuneSelf && unicode.IsLetter(r) || i > 0 && r < utf8.RuneSelf && unicode.IsDigit(r) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// --- parser ---
+
+type exprParser struct {
+	toks []exprToken
+	pos  int
+}
+
+func (p *exprParser) peek() exprToken { return p.toks[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// acceptOp consumes the next token if it is one of the given operators or
+// keywords.
+func (p *exprParser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.next()
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr(depth int) (exprNode, error) {
+	if depth > maxExprDepth {
+		return nil, fmt.Errorf("expression is nested more than %d levels deep", maxExprDepth)
+	}
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: false, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd(depth int) (exprNode, error) {
+	left, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot(depth int) (exprNode, error) {
+	if _, ok := p.acceptOp("!", "not"); ok {
+		if depth > maxExprDepth {
+			return nil, fmt.Errorf("expression is nested more than %d levels deep", maxExprDepth)
+		}
+		operand, err := p.parseNot(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseCompare(depth)
+}
+
+func (p *exprParser) parseCompare(depth int) (exprNode, error) {
+	left, err := p.parseAdd(depth)
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.acceptOp("==", "!=", "<=", ">=", "<", ">", "=~", "!~")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseAdd(depth)
+	if err != nil {
+		return nil, err
+	}
+	if op == "=~" || op == "!~" {
+		m := &matchNode{negate: op == "!~", subject: left, pattern: right}
+		if lit, ok := right.(*literalNode); ok {
+			re, err := compileExprPattern(lit.val.String())
+			if err != nil {
+				return nil, err
+			}
+			m.re = re
+		}
+		return m, nil
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseAdd(depth int) (exprNode, error) {
+	left, err := p.parseMul(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMul(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMul(depth int) (exprNode, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary(depth int) (exprNode, error) {
+	if _, ok := p.acceptOp("-"); ok {
+		if depth > maxExprDepth {
+			return nil, fmt.Errorf("expression is nested more than %d levels deep", maxExprDepth)
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &arithNode{op: "-", left: &literalNode{val: numberValue(0)}, right: operand}, nil
+	}
+	if _, ok := p.acceptOp("!", "not"); ok {
+		if depth > maxExprDepth {
+			return nil, fmt.Errorf("expression is nested more than %d levels deep", maxExprDepth)
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+func (p *exprParser) parsePrimary(depth int) (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &literalNode{val: numberValue(t.num)}, nil
+	case tokString:
+		return &literalNode{val: stringValue(t.text)}, nil
+	case tokLParen:
+		inner, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if r := p.next(); r.kind != tokRParen {
+			return nil, fmt.Errorf("expected \")\" at position %d, found %s", r.pos+1, r)
+		}
+		return inner, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{val: boolValue(true)}, nil
+		case "false":
+			return &literalNode{val: boolValue(false)}, nil
+		case "and", "or", "not":
+			return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+		}
+		if p.peek().kind == tokLParen {
+			p.next()
+			return p.parseCall(t, depth)
+		}
+		if field, ok := exprCallFields[t.text]; ok {
+			return &fieldNode{get: field}, nil
+		}
+		return &varNode{name: &literalNode{val: stringValue(t.text)}}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+	}
+}
+
+// parseCall parses the arguments of a function call after its opening
+// parenthesis.
+func (p *exprParser) parseCall(name exprToken, depth int) (exprNode, error) {
+	fn, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos+1)
+	}
+	var args []exprNode
+	if p.peek().kind == tokRParen {
+		p.next()
+	} else {
+		for {
+			arg, err := p.parseOr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			t := p.next()
+			if t.kind == tokRParen {
+				break
+			}
+			if t.kind != tokComma {
+				return nil, fmt.Errorf("expected \",\" or \")\" at position %d, found %s", t.pos+1, t)
+			}
+		}
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("%s() takes %s, got %d", name.text, fn.arity(), len(args))
+	}
+
+	if name.text == "var" {
+		return &varNode{name: args[0]}, nil
+	}
+	call := &callNode{name: name.text, fn: fn, args: args}
+	if name.text == "matches" {
+		if lit, ok := args[1].(*literalNode); ok {
+			re, err := compileExprPattern(lit.val.String())
+			if err != nil {
+				return nil, err
+			}
+			call.re = re
+		}
+	}
+	return call, nil
+}
+
+func compileExprPattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxExprPatternLen {
+		return nil, fmt.Errorf("regular expression is longer than %d characters", maxExprPatternLen)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// --- evaluation ---
+
+type exprNode interface {
+	eval(callCtx *CallContext) (exprValue, error)
+}
+
+type literalNode struct{ val exprValue }
+
+func (n *literalNode) eval(*CallContext) (exprValue, error) { return n.val, nil }
+
+type fieldNode struct{ get func(*CallContext) string }
+
+func (n *fieldNode) eval(c *CallContext) (exprValue, error) { return stringValue(n.get(c)), nil }
+
+// varNode reads a flow variable whose name is given by an expression.
+type varNode struct{ name exprNode }
+
+func (n *varNode) eval(c *CallContext) (exprValue, error) {
+	name, err := n.name.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	return stringValue(c.GetVariable(name.String())), nil
+}
+
+type notNode struct{ operand exprNode }
+
+func (n *notNode) eval(c *CallContext) (exprValue, error) {
+	v, err := n.operand.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	return boolValue(!v.truthy()), nil
+}
+
+type logicNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (n *logicNode) eval(c *CallContext) (exprValue, error) {
+	l, err := n.left.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if l.truthy() != n.and {
+		return boolValue(l.truthy()), nil
+	}
+	r, err := n.right.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	return boolValue(r.truthy()), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(c *CallContext) (exprValue, error) {
+	l, err := n.left.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	r, err := n.right.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+
+	var cmp int
+	if l.kind == kindNumber || r.kind == kindNumber {
+		ln, lok := l.number()
+		rn, rok := r.number()
+		if !lok || !rok {
+			// A non-number is never equal to a number and cannot be
+			// ordered against one.
+			switch n.op {
+			case "==":
+				return boolValue(false), nil
+			case "!=":
+				return boolValue(true), nil
+			}
+			return exprValue{}, fmt.Errorf("cannot compare %q with %q as numbers", l.String(), r.String())
+		}
+		switch {
+		case ln < rn:
+			cmp = -1
+		case ln > rn:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(l.String(), r.String())
+	}
+
+	switch n.op {
+	case "==":
+		return boolValue(cmp == 0), nil
+	case "!=":
+		return boolValue(cmp != 0), nil
+	case "<":
+		return boolValue(cmp < 0), nil
+	case "<=":
+		return boolValue(cmp <= 0), nil
+	case ">":
+		return boolValue(cmp > 0), nil
+	default:
+		return boolValue(cmp >= 0), nil
+	}
+}
+
+type arithNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *arithNode) eval(c *CallContext) (exprValue, error) {
+	l, err := n.left.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	r, err := n.right.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if n.op == "+" && l.kind != kindNumber && r.kind != kindNumber {
+		return stringValue(l.String() + r.String()), nil
+	}
+
+	ln, lok := l.number()
+	rn, rok := r.number()
+	if !lok || !rok {
+		bad := l
+		if lok {
+			bad = r
+		}
+		return exprValue{}, fmt.Errorf("%q is not a number", bad.String())
+	}
+	switch n.op {
+	case "+":
+		return numberValue(ln + rn), nil
+	case "-":
+		return numberValue(ln - rn), nil
+	case "*":
+		return numberValue(ln * rn), nil
+	case "/":
+		if rn == 0 {
+			return exprValue{}, errors.New("division by zero")
+		}
+		return numberValue(ln / rn), nil
+	default:
+		if rn == 0 {
+			return exprValue{}, errors.New("division by zero")
+		}
+		return numberValue(math.Mod(ln, rn)), nil
+	}
+}
+
+type matchNode struct {
+	negate           bool
+	subject, pattern exprNode
+	re               *regexp.Regexp // compiled at parse time for literals
+}
+
+func (n *matchNode) eval(c *CallContext) (exprValue, error) {
+	s, err := n.subject.eval(c)
+	if err != nil {
+		return exprValue{}, err
+	}
+	re, err := patternFor(c, n.re, n.pattern)
+	if err != nil {
+		return exprValue{}, err
+	}
+	return boolValue(re.MatchString(s.String()) != n.negate), nil
+}
+
+// patternFor returns the compiled regular expression of a match, compiling
+// a pattern computed at run time.
+func patternFor(c *CallContext, re *regexp.Regexp, pattern exprNode) (*regexp.Regexp, error) {
+	if re != nil {
+		return re, nil
+	}
+	p, err := pattern.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	return compileExprPattern(p.String())
+}
+
+// exprFunc is a built-in function. maxArgs is -1 for variadic functions.
+type exprFunc struct {
+	minArgs, maxArgs int
+	call             func(args []exprValue) exprValue
+}
+
+func (f exprFunc) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	case f.minArgs == 1 && f.maxArgs == 1:
+		return "1 argument"
+	default:
+		return fmt.Sprintf("%d arguments", f.maxArgs)
+	}
+}
+
+var exprFuncs = map[string]exprFunc{
+	"starts_with": {2, -1, func(args []exprValue) exprValue {
+		s := args[0].String()
+		for _, prefix := range args[1:] {
+			if strings.HasPrefix(s, prefix.String()) {
+				return boolValue(true)
+			}
+		}
+		return boolValue(false)
+	}},
+	"ends_with": {2, -1, func(args []exprValue) exprValue {
+		s := args[0].String()
+		for _, suffix := range args[1:] {
+			if strings.HasSuffix(s, suffix.String()) {
+				return boolValue(true)
+			}
+		}
+		return boolValue(false)
+	}},
+	"contains": {2, 2, func(args []exprValue) exprValue {
+		return boolValue(strings.Contains(args[0].String(), args[1].String()))
+	}},
+	"len": {1, 1, func(args []exprValue) exprValue {
+		return numberValue(float64(utf8.RuneCountInString(args[0].String())))
+	}},
+	"lower": {1, 1, func(args []exprValue) exprValue {
+		return stringValue(strings.ToLower(args[0].String()))
+	}},
+	"upper": {1, 1, func(args []exprValue) exprValue {
+		return stringValue(strings.ToUpper(args[0].String()))
+	}},
+	"trim": {1, 1, func(args []exprValue) exprValue {
+		return stringValue(strings.TrimSpace(args[0].String()))
+	}},
+	"default": {2, 2, func(args []exprValue) exprValue {
+		if args[0].String() != "" {
+			return args[0]
+		}
+		return args[1]
+	}},
+	// matches and var are evaluated by callNode and varNode; their entries
+	// give their arity.
+	"matches": {2, 2, nil},
+	"var":     {1, 1, nil},
+}
+
+type callNode struct {
+	name string
+	fn   exprFunc
+	args []exprNode
+	re   *regexp.Regexp // matches() with a literal pattern
+}
+
+func (n *callNode) eval(c *CallContext) (exprValue, error) {
+	if n.name == "matches" {
+		s, err := n.args[0].eval(c)
+		if err != nil {
+			return exprValue{}, err
+		}
+		re, err := patternFor(c, n.re, n.args[1])
+		if err != nil {
+			return exprValue{}, err
+		}
+		return boolValue(re.MatchString(s.String())), nil
+	}
+
+	args := make([]exprValue, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(c)
+		if err != nil {
+			return exprValue{}, err
+		}
+		args[i] = v
+	}
+	return n.fn.call(args), nil
+}
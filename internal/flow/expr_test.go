@@ -0,0 +1,222 @@
+package flow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+func newExprTestCall() *CallContext {
+	c := NewCallContext("call-1", "Alice", "0412345678", "1300123456", &models.InboundNumber{Number: "0290000000"}, 0, nil, nil)
+	c.SetVariable("tier", "gold")
+	c.SetVariable("retries", "2")
+	c.SetVariable("webhook_status", "200")
+	c.SetVariable("crm-id", "A17")
+	c.AppendDTMF("3")
+	return c
+}
+
+func TestExprEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`tier == "gold"`, "true"},
+		{`tier != 'gold'`, "false"},
+		{`webhook_status == 200`, "true"},
+		{`webhook_status >= 500`, "false"},
+		{`retries + 1`, "3"},
+		{`retries * 2 - 1`, "3"},
+		{`7 % 4`, "3"},
+		{`-retries`, "-2"},
+		{`"A" + "B"`, "AB"},
+		{`retries + "1"`, "21"},
+		{`caller_id_num == "0412345678"`, "true"},
+		{`caller_id_num == "412345678"`, "false"},
+		{`caller_id_num =~ "^04\d{8}$"`, "true"},
+		{`caller_id_num !~ "^04"`, "false"},
+		{`matches(did, "^02")`, "true"},
+		{`starts_with(caller_id_num, "+614", "04")`, "true"},
+		{`ends_with(callee, "99")`, "false"},
+		{`contains(caller_id_name, "lic")`, "true"},
+		{`len(caller_id_num)`, "10"},
+		{`upper(tier) + lower("X")`, "GOLDx"},
+		{`default(unset, "none")`, "none"},
+		{`var("crm-id")`, "A17"},
+		{`unset == ""`, "true"},
+		{`dtmf == "3" && (tier == "gold" or not true)`, "true"},
+		{`!(retries > 5) and call_id == "call-1"`, "true"},
+		{`tier == "silver" || retries < 1`, "false"},
+		{`"b" > "a"`, "true"},
+		{`tier == 1`, "false"},
+	}
+
+	c := newExprTestCall()
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("ParseExpr(%q) error: %v", tt.expr, err)
+			continue
+		}
+		got, err := e.Eval(c)
+		if err != nil {
+			t.Errorf("Eval(%q) error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestExprEvalBool(t *testing.T) {
+	c := newExprTestCall()
+	for expr, want := range map[string]bool{
+		`tier`:         true,
+		`unset`:        false,
+		`"0"`:          false,
+		`"false"`:      false,
+		`retries - 2`:  false,
+		`retries`:      true,
+		`tier == "x"`:  false,
+		`not unset`:    true,
+		`unset or "y"`: true,
+	} {
+		e, err := ParseExpr(expr)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q) error: %v", expr, err)
+		}
+		got, err := e.EvalBool(c)
+		if err != nil {
+			t.Fatalf("EvalBool(%q) error: %v", expr, err)
+		}
+		if got != want {
+			t.Errorf("EvalBool(%q) = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestExprShortCircuit(t *testing.T) {
+	// The right-hand side would fail with a division by zero.
+	e, err := ParseExpr(`tier == "gold" || 1 / 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := e.EvalBool(newExprTestCall()); err != nil || !ok {
+		t.Errorf("EvalBool() = %v, %v, want true", ok, err)
+	}
+}
+
+func TestExprParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`tier ==`,
+		`(tier == "gold"`,
+		`tier == "gold`,
+		`lookup(tier)`,
+		`starts_with(tier)`,
+		`len(tier, 1)`,
+		`caller_id_num =~ "(["`,
+		`matches(tier, "a)")`,
+		`tier = "gold"`,
+		`tier == "a" "b"`,
+		`1.2.3`,
+		`and`,
+		`tier @ 1`,
+		strings.Repeat("(", maxExprDepth+2) + "1" + strings.Repeat(")", maxExprDepth+2),
+		strings.Repeat("a", maxExprLen+1),
+	} {
+		if _, err := ParseExpr(expr); err == nil {
+			t.Errorf("ParseExpr(%.40q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestExprEvalErrors(t *testing.T) {
+	c := newExprTestCall()
+	for _, expr := range []string{
+		`retries / 0`,
+		`tier + 1`,
+		`tier > 1`,
+		`tier =~ var("crm-id") + "("`,
+	} {
+		e, err := ParseExpr(expr)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q) error: %v", expr, err)
+		}
+		if _, err := e.Eval(c); err == nil {
+			t.Errorf("Eval(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestValidateVariableName(t *testing.T) {
+	for _, name := range []string{"tier", "_x", "crm_id2"} {
+		if err := ValidateVariableName(name); err != nil {
+			t.Errorf("ValidateVariableName(%q) error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "2x", "crm-id", "caller_id_num", "did", "true", "and"} {
+		if err := ValidateVariableName(name); err == nil {
+			t.Errorf("ValidateVariableName(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	c := newExprTestCall()
+	got, err := Interpolate(c, "prompt", "Welcome {{.CallerIDName}}, you are a {{.Variables.tier}} customer calling {{.DID}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Welcome Alice, you are a gold customer calling 0290000000"; got != want {
+		t.Errorf("Interpolate() = %q, want %q", got, want)
+	}
+	if err := CheckTemplate("prompt", "{{.Variables.tier"); err == nil {
+		t.Error("CheckTemplate() accepted an unterminated action")
+	}
+}
+
+func TestValidateExpressions(t *testing.T) {
+	graph, err := ParseFlowGraph(`{
+		"nodes": [
+			{"id": "set", "type": "set_variable", "data": {"label": "Set", "config": {"variables": [
+				{"name": "tries", "expression": "tries + 1"},
+				{"name": "did", "expression": "\"x\""},
+				{"name": "bad", "expression": "tries +"}
+			]}}},
+			{"id": "cond", "type": "condition", "data": {"label": "Cond", "config": {"cases": [
+				{"label": "vip", "expression": "tier == \"gold\""},
+				{"label": "vip", "expression": "matches(caller_id_num, \"(\")"},
+				{"label": "default", "expression": "true"}
+			]}}},
+			{"id": "say", "type": "play_message", "data": {"label": "Say", "config": {"tts": "Hello {{.CallerIDName"}}}
+		],
+		"edges": [
+			{"id": "e1", "source": "set", "target": "cond", "sourceHandle": "next"},
+			{"id": "e2", "source": "cond", "target": "say", "sourceHandle": "vip"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := NewValidator(nil, nil).Validate(context.Background(), 0, graph, "set")
+	if result.Valid {
+		t.Fatal("Validate() accepted invalid expressions")
+	}
+	var errs []string
+	for _, issue := range result.Issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue.NodeID+": "+issue.Message)
+		}
+	}
+	// did is reserved, "tries +" does not parse, vip is repeated, the
+	// second vip pattern is invalid, default is reserved and the prompt
+	// template is unterminated.
+	if len(errs) != 6 {
+		t.Errorf("got %d errors, want 6:\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+}
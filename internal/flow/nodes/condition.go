@@ -0,0 +1,114 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// ConditionHandler handles the Condition node type. It evaluates an ordered
+// list of cases against the call and routes to the first case that holds.
+//
+// Node config:
+//   - "cases": array of {"label": output edge, "expression": condition},
+//     evaluated in order. Expressions use the flow expression language,
+//     e.g. `tier == "gold"` or `starts_with(caller_id_num, "04")`.
+//
+// Output edges:
+//   - the label of the first case whose expression is true
+//   - "default": no case matched
+//
+// A case whose expression fails to evaluate, e.g. comparing a non-numeric
+// variable with a number, is logged and treated as not matching.
+type ConditionHandler struct {
+	logger *slog.Logger
+}
+
+// NewConditionHandler creates a new ConditionHandler.
+func NewConditionHandler(logger *slog.Logger) *ConditionHandler {
+	return &ConditionHandler{
+		logger: logger.With("handler", "condition"),
+	}
+}
+
+// conditionCase is one parsed case of a condition node.
+type conditionCase struct {
+	label string
+	expr  *flow.Expr
+}
+
+// Execute returns the label of the first matching case, or "default".
+func (h *ConditionHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
+	h.logger.Debug("condition node executing",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+	)
+
+	cases, err := parseConditionCases(node)
+	if err != nil {
+		return "", fmt.Errorf("condition node %s: %w", node.ID, err)
+	}
+
+	for _, c := range cases {
+		ok, err := c.expr.EvalBool(callCtx)
+		if err != nil {
+			h.logger.Warn("condition case failed to evaluate",
+				"call_id", callCtx.CallID,
+				"node_id", node.ID,
+				"case", c.label,
+				"expression", c.expr.String(),
+				"error", err,
+			)
+			continue
+		}
+		if ok {
+			h.logger.Info("condition case matched",
+				"call_id", callCtx.CallID,
+				"node_id", node.ID,
+				"case", c.label,
+			)
+			return c.label, nil
+		}
+	}
+
+	h.logger.Info("no condition case matched, using default",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+	)
+	return "default", nil
+}
+
+// parseConditionCases reads and parses the cases of a condition node.
+func parseConditionCases(node flow.Node) ([]conditionCase, error) {
+	if node.Data.Config == nil {
+		return nil, fmt.Errorf("no config specified")
+	}
+	raw, ok := node.Data.Config["cases"].([]any)
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("no cases configured")
+	}
+
+	cases := make([]conditionCase, 0, len(raw))
+	for i, item := range raw {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("case %d must be an object", i+1)
+		}
+		label, _ := obj["label"].(string)
+		if label == "" {
+			return nil, fmt.Errorf("case %d has no label", i+1)
+		}
+		src, _ := obj["expression"].(string)
+		expr, err := flow.ParseExpr(src)
+		if err != nil {
+			return nil, fmt.Errorf("case %q: %w", label, err)
+		}
+		cases = append(cases, conditionCase{label: label, expr: expr})
+	}
+	return cases, nil
+}
+
+// Ensure ConditionHandler satisfies the NodeHandler interface.
+var _ flow.NodeHandler = (*ConditionHandler)(nil)
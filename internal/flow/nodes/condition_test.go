@@ -0,0 +1,129 @@
+package nodes
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+func makeConditionNode(cases ...map[string]any) flow.Node {
+	raw := make([]any, len(cases))
+	for i, c := range cases {
+		raw[i] = c
+	}
+	return flow.Node{
+		ID:   "node_cond",
+		Type: "condition",
+		Data: flow.NodeData{Label: "Route", Config: map[string]any{"cases": raw}},
+	}
+}
+
+func TestConditionFirstMatchingCase(t *testing.T) {
+	h := NewConditionHandler(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	node := makeConditionNode(
+		map[string]any{"label": "broken", "expression": `tier > 3`},
+		map[string]any{"label": "mobile", "expression": `starts_with(caller_id_num, "04", "+614")`},
+		map[string]any{"label": "vip", "expression": `tier == "gold"`},
+	)
+
+	tests := []struct {
+		caller string
+		tier   string
+		want   string
+	}{
+		{"0412345678", "gold", "mobile"},
+		{"0290000000", "gold", "vip"},
+		{"0290000000", "", "default"},
+	}
+	for _, tt := range tests {
+		callCtx := flow.NewCallContext("test-cond", "", tt.caller, "100", nil, 0, nil, nil)
+		callCtx.SetVariable("tier", tt.tier)
+		edge, err := h.Execute(context.Background(), callCtx, node)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if edge != tt.want {
+			t.Errorf("caller %s tier %q: edge = %q, want %q", tt.caller, tt.tier, edge, tt.want)
+		}
+	}
+}
+
+func TestConditionInvalidConfig(t *testing.T) {
+	h := NewConditionHandler(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	callCtx := flow.NewCallContext("test-cond", "", "100", "200", nil, 0, nil, nil)
+
+	for _, node := range []flow.Node{
+		makeConditionNode(),
+		makeConditionNode(map[string]any{"expression": `true`}),
+		makeConditionNode(map[string]any{"label": "x", "expression": `tier ==`}),
+	} {
+		if _, err := h.Execute(context.Background(), callCtx, node); err == nil {
+			t.Errorf("config %v: expected error", node.Data.Config)
+		}
+	}
+}
+
+func TestSimulateVariablesAndConditions(t *testing.T) {
+	// Mobile callers are greeted by name; other callers pass a counter loop
+	// three times and are transferred to the operator variable.
+	graph, err := flow.ParseFlowGraph(`{
+		"nodes": [
+			{"id": "init", "type": "set_variable", "data": {"label": "Init", "config": {"variables": [
+				{"name": "tries", "expression": "0"},
+				{"name": "operator", "expression": "\"2\" + \"00\""}
+			]}}},
+			{"id": "route", "type": "condition", "data": {"label": "Route", "config": {"cases": [
+				{"label": "mobile", "expression": "caller_id_num =~ \"^04\""}
+			]}}},
+			{"id": "count", "type": "set_variable", "data": {"label": "Count", "config": {"variables": [
+				{"name": "tries", "expression": "tries + 1"}
+			]}}},
+			{"id": "check", "type": "condition", "data": {"label": "Check", "config": {"cases": [
+				{"label": "retry", "expression": "tries < 3"}
+			]}}},
+			{"id": "hello", "type": "play_message", "data": {"label": "Hello", "config": {"tts": "Hello {{.CallerIDName}}"}}},
+			{"id": "op", "type": "transfer", "data": {"label": "Operator", "config": {"destination": "{{.Variables.operator}}"}}},
+			{"id": "bye", "type": "hangup", "data": {"label": "Bye"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "init", "target": "route", "sourceHandle": "next"},
+			{"id": "e2", "source": "route", "target": "hello", "sourceHandle": "mobile"},
+			{"id": "e3", "source": "route", "target": "count", "sourceHandle": "default"},
+			{"id": "e4", "source": "count", "target": "check", "sourceHandle": "next"},
+			{"id": "e5", "source": "check", "target": "count", "sourceHandle": "retry"},
+			{"id": "e6", "source": "check", "target": "op", "sourceHandle": "default"},
+			{"id": "e7", "source": "hello", "target": "bye", "sourceHandle": "next"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := newTestSimulator(t)
+
+	res, err := engine.Simulate(context.Background(), graph, "init", flow.SimulatedCall{CallerIDName: "Alice", CallerIDNum: "0412345678"})
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	if want := []string{"init", "route", "hello", "bye"}; !slices.Equal(res.FlowPath, want) {
+		t.Errorf("mobile: path = %v, want %v", res.FlowPath, want)
+	}
+	if len(res.Actions) == 0 || res.Actions[0].Detail != "tts: Hello Alice" {
+		t.Errorf("mobile: actions = %+v, want the interpolated prompt", res.Actions)
+	}
+
+	res, err = engine.Simulate(context.Background(), graph, "init", flow.SimulatedCall{CallerIDNum: "0290000000"})
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	want := []string{"init", "route", "count", "check", "count", "check", "count", "check", "op"}
+	if !slices.Equal(res.FlowPath, want) {
+		t.Errorf("landline: path = %v, want %v", res.FlowPath, want)
+	}
+	if res.Variables["tries"] != "3" || res.Outcome != flow.OutcomeTransferred || res.Detail != "200" {
+		t.Errorf("landline: outcome = %s (%s), variables = %v; error %q", res.Outcome, res.Detail, res.Variables, res.Error)
+	}
+}
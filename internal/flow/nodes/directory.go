@@ -0,0 +1,383 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+const (
+	// directoryNodeTimeout bounds how long a caller can spend searching the
+	// directory, including ringing the chosen extension.
+	directoryNodeTimeout = 15 * time.Minute
+
+	// directoryDefaultLetters is how many letters are collected when the
+	// node does not set "letters".
+	directoryDefaultLetters = 3
+
+	// directoryMaxLetters limits the "letters" setting.
+	directoryMaxLetters = 10
+
+	// directoryDefaultAttempts is how many searches a caller may make when
+	// the node does not set "max_attempts".
+	directoryDefaultAttempts = 3
+
+	// dirFirstDigitTimeout and dirInterDigitTimeout are the digit
+	// collection timeouts in seconds.
+	dirFirstDigitTimeout = 10
+	dirInterDigitTimeout = 5
+)
+
+// keypadLetters maps letters to the digit of their phone keypad key.
+var keypadLetters = map[rune]byte{
+	'a': '2', 'b': '2', 'c': '2',
+	'd': '3', 'e': '3', 'f': '3',
+	'g': '4', 'h': '4', 'i': '4',
+	'j': '5', 'k': '5', 'l': '5',
+	'm': '6', 'n': '6', 'o': '6',
+	'p': '7', 'q': '7', 'r': '7', 's': '7',
+	't': '8', 'u': '8', 'v': '8',
+	'w': '9', 'x': '9', 'y': '9', 'z': '9',
+}
+
+// DirectoryHandler handles the Directory node type, a dial-by-name company
+// directory. The caller spells the start of a name on the keypad (2 for
+// A, B or C, and so on) and hears the matching extensions one at a time,
+// pressing 1 to call the one announced, * for the next name or # to search
+// again. Each extension is announced with the name its owner recorded in
+// voicemail, or with text-to-speech of the extension name if there is none.
+// Extensions without a name or excluded from the directory are not listed.
+//
+// Node config:
+//   - "search_by": "first" or "last" (default) name
+//   - "letters": letters to collect, 1-10 (default 3); # ends entry early
+//   - "max_attempts": searches before giving up (default 3)
+//   - "ring_timeout": seconds to ring the chosen extension (default: the
+//     extension's ring timeout)
+//
+// The chosen extension is stored in the "directory_extension" and
+// "directory_name" call variables.
+//
+// Output edges:
+//   - "answered": the chosen extension answered
+//   - "no_answer": the chosen extension did not answer
+//   - "not_found": the caller did not choose anyone
+type DirectoryHandler struct {
+	sip        flow.SIPActions
+	extensions database.ExtensionRepository
+	boxes      database.VoicemailBoxRepository
+	dataDir    string
+	logger     *slog.Logger
+}
+
+// NewDirectoryHandler creates a new DirectoryHandler.
+func NewDirectoryHandler(
+	sip flow.SIPActions,
+	extensions database.ExtensionRepository,
+	boxes database.VoicemailBoxRepository,
+	dataDir string,
+	logger *slog.Logger,
+) *DirectoryHandler {
+	return &DirectoryHandler{
+		sip:        sip,
+		extensions: extensions,
+		boxes:      boxes,
+		dataDir:    dataDir,
+		logger:     logger.With("handler", "directory"),
+	}
+}
+
+// NodeTimeout allows callers to search the directory and ring the chosen
+// extension for longer than the engine's default node timeout.
+func (h *DirectoryHandler) NodeTimeout(_ flow.Node) time.Duration {
+	return directoryNodeTimeout
+}
+
+// directoryConfig is the parsed config of a directory node.
+type directoryConfig struct {
+	searchBy    string
+	letters     int
+	maxAttempts int
+	ringTimeout int
+}
+
+// Execute searches the directory with the caller and rings their choice.
+func (h *DirectoryHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
+	h.logger.Debug("directory node executing",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+	)
+
+	cfg, err := parseDirectoryConfig(node)
+	if err != nil {
+		return "", fmt.Errorf("directory node %s: %w", node.ID, err)
+	}
+
+	edge, err := h.run(ctx, callCtx, node, cfg)
+	if errors.Is(err, flow.ErrCallerHungUp) {
+		h.logger.Info("caller hung up in directory",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+		)
+		return "", nil
+	}
+	return edge, err
+}
+
+// run is Execute without the hangup handling.
+func (h *DirectoryHandler) run(ctx context.Context, callCtx *flow.CallContext, node flow.Node, cfg directoryConfig) (string, error) {
+	prompt := "dir_enter_last_name"
+	if cfg.searchBy == "first" {
+		prompt = "dir_enter_first_name"
+	}
+
+	for attempt := 0; attempt < cfg.maxAttempts; attempt++ {
+		res, err := h.sip.PlayAndCollect(ctx, callCtx, h.promptPath(prompt), false, dirFirstDigitTimeout, dirInterDigitTimeout, cfg.letters)
+		if err != nil {
+			return "", fmt.Errorf("collecting name: %w", err)
+		}
+		if res.Digits == "" {
+			continue
+		}
+
+		matches, err := h.search(ctx, res.Digits, cfg.searchBy)
+		if err != nil {
+			return "", err
+		}
+		h.logger.Info("directory searched",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"digits", res.Digits,
+			"matches", len(matches),
+		)
+		if len(matches) == 0 {
+			if err := h.play(ctx, callCtx, h.promptPath("dir_no_match")); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		ext, err := h.choose(ctx, callCtx, matches)
+		if err != nil {
+			return "", err
+		}
+		if ext != nil {
+			return h.ring(ctx, callCtx, node, ext, cfg.ringTimeout)
+		}
+	}
+
+	h.logger.Info("no directory entry chosen",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+	)
+	return "not_found", nil
+}
+
+// search returns the listed extensions whose first or last name starts
+// with letters spelling digits on the keypad, ordered by name.
+func (h *DirectoryHandler) search(ctx context.Context, digits, searchBy string) ([]*models.Extension, error) {
+	exts, err := h.extensions.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing extensions: %w", err)
+	}
+
+	var matches []*models.Extension
+	for i := range exts {
+		ext := &exts[i]
+		if ext.DirectoryExclude {
+			continue
+		}
+		key := directoryKey(ext.Name, searchBy)
+		if key != "" && strings.HasPrefix(keypadDigits(key), digits) {
+			matches = append(matches, ext)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		ki, kj := directoryKey(matches[i].Name, searchBy), directoryKey(matches[j].Name, searchBy)
+		if !strings.EqualFold(ki, kj) {
+			return strings.ToLower(ki) < strings.ToLower(kj)
+		}
+		return strings.ToLower(matches[i].Name) < strings.ToLower(matches[j].Name)
+	})
+	return matches, nil
+}
+
+// choose announces the matches one at a time and returns the one the
+// caller picks, or nil if they passed over them all or asked to search
+// again.
+func (h *DirectoryHandler) choose(ctx context.Context, callCtx *flow.CallContext, matches []*models.Extension) (*models.Extension, error) {
+	for _, ext := range matches {
+		prompt, isTTS := h.promptPath("dir_candidate_menu"), false
+		if nameFile := h.recordedName(ctx, callCtx, ext); nameFile != "" {
+			if err := h.play(ctx, callCtx, nameFile); err != nil {
+				return nil, err
+			}
+		} else {
+			prompt = fmt.Sprintf("%s, extension %s. Press 1 to call, or star for the next name.", ext.Name, ext.Extension)
+			isTTS = true
+		}
+
+		res, err := h.sip.PlayAndCollect(ctx, callCtx, prompt, isTTS, dirFirstDigitTimeout, dirInterDigitTimeout, 1)
+		if err != nil {
+			return nil, fmt.Errorf("announcing %s: %w", ext.Extension, err)
+		}
+		switch res.Digits {
+		case "1":
+			return ext, nil
+		case "":
+			if res.TimedOut {
+				continue
+			}
+			// # ends collection with no digits: search again.
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// ring calls the chosen extension and returns "answered" or "no_answer".
+func (h *DirectoryHandler) ring(ctx context.Context, callCtx *flow.CallContext, node flow.Node, ext *models.Extension, ringTimeout int) (string, error) {
+	callCtx.SetVariable("directory_extension", ext.Extension)
+	callCtx.SetVariable("directory_name", ext.Name)
+
+	if ringTimeout <= 0 {
+		ringTimeout = ext.RingTimeout
+	}
+	if ringTimeout <= 0 {
+		ringTimeout = 30
+	}
+
+	h.logger.Info("ringing directory entry",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+		"extension", ext.Extension,
+		"ring_timeout", ringTimeout,
+	)
+
+	result, err := h.sip.RingExtension(ctx, callCtx, ext, ringTimeout)
+	if err != nil {
+		return "", fmt.Errorf("ringing extension %s: %w", ext.Extension, err)
+	}
+	if result.Answered {
+		return "answered", nil
+	}
+	return "no_answer", nil
+}
+
+// recordedName returns the name recorded for the extension in its
+// voicemail box, or "" if there is none.
+func (h *DirectoryHandler) recordedName(ctx context.Context, callCtx *flow.CallContext, ext *models.Extension) string {
+	if h.boxes == nil {
+		return ""
+	}
+	boxes, err := h.boxes.ListByNotifyExtensionID(ctx, ext.ID)
+	if err != nil {
+		h.logger.Warn("failed to look up recorded name, using text-to-speech",
+			"call_id", callCtx.CallID,
+			"extension", ext.Extension,
+			"error", err,
+		)
+		return ""
+	}
+	for _, box := range boxes {
+		if box.NameFile == "" {
+			continue
+		}
+		if _, err := os.Stat(box.NameFile); err == nil {
+			return box.NameFile
+		}
+	}
+	return ""
+}
+
+// play plays an audio file. Playback failures other than the caller
+// hanging up are logged and skipped.
+func (h *DirectoryHandler) play(ctx context.Context, callCtx *flow.CallContext, path string) error {
+	err := h.sip.PlayPrompt(ctx, callCtx, path)
+	if err == nil || errors.Is(err, flow.ErrCallerHungUp) {
+		return err
+	}
+	h.logger.Warn("directory prompt playback failed",
+		"call_id", callCtx.CallID,
+		"prompt", path,
+		"error", err,
+	)
+	return nil
+}
+
+// promptPath returns the path of a system prompt.
+func (h *DirectoryHandler) promptPath(name string) string {
+	return filepath.Join(h.dataDir, "prompts", "system", name+".wav")
+}
+
+// parseDirectoryConfig reads the config of a directory node, applying
+// defaults.
+func parseDirectoryConfig(node flow.Node) (directoryConfig, error) {
+	cfg := directoryConfig{
+		searchBy:    "last",
+		letters:     directoryDefaultLetters,
+		maxAttempts: directoryDefaultAttempts,
+	}
+	if v, ok := node.Data.Config["search_by"].(string); ok && v != "" {
+		if v != "first" && v != "last" {
+			return cfg, fmt.Errorf("search_by must be \"first\" or \"last\", got %q", v)
+		}
+		cfg.searchBy = v
+	}
+	if v, ok := node.Data.Config["letters"].(float64); ok {
+		if v < 1 || v > directoryMaxLetters {
+			return cfg, fmt.Errorf("letters must be between 1 and %d", directoryMaxLetters)
+		}
+		cfg.letters = int(v)
+	}
+	if v, ok := node.Data.Config["max_attempts"].(float64); ok && v >= 1 {
+		cfg.maxAttempts = int(v)
+	}
+	if v, ok := node.Data.Config["ring_timeout"].(float64); ok && v > 0 {
+		cfg.ringTimeout = int(v)
+	}
+	return cfg, nil
+}
+
+// directoryKey returns the part of name searched: its first word, or its
+// last word for a last name search.
+func directoryKey(name, searchBy string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return ""
+	}
+	if searchBy == "first" {
+		return words[0]
+	}
+	return words[len(words)-1]
+}
+
+// keypadDigits spells s on a phone keypad. Digits are kept; other
+// characters, including accented letters, are dropped.
+func keypadDigits(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if d, ok := keypadLetters[r]; ok {
+			b.WriteByte(d)
+		} else if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Ensure DirectoryHandler satisfies the NodeHandler and NodeTimeoutProvider interfaces.
+var (
+	_ flow.NodeHandler         = (*DirectoryHandler)(nil)
+	_ flow.NodeTimeoutProvider = (*DirectoryHandler)(nil)
+)
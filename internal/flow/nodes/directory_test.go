@@ -0,0 +1,153 @@
+package nodes
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// directoryExtensionRepo lists a fixed set of extensions.
+type directoryExtensionRepo struct {
+	mockExtensionRepo
+	list []models.Extension
+}
+
+func (m *directoryExtensionRepo) List(_ context.Context) ([]models.Extension, error) {
+	return append([]models.Extension(nil), m.list...), nil
+}
+
+func TestKeypadDigits(t *testing.T) {
+	for in, want := range map[string]string{
+		"Smith":      "76484",
+		"O'Brien-2":  "6274362",
+		"ZOË":        "96",
+		"":           "",
+		"Mary Jones": "627956637",
+	} {
+		if got := keypadDigits(in); got != want {
+			t.Errorf("keypadDigits(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSimulateDirectory(t *testing.T) {
+	dataDir := t.TempDir()
+	nameFile := filepath.Join(dataDir, "box_1_name.wav")
+	if err := os.WriteFile(nameFile, []byte("fake-wav"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	aliceID := int64(1)
+	extensions := &directoryExtensionRepo{list: []models.Extension{
+		{ID: 1, Extension: "101", Name: "Alice Smith"},
+		{ID: 2, Extension: "102", Name: "Bob Smithers"},
+		{ID: 3, Extension: "103", Name: "Carol Jones"},
+		{ID: 4, Extension: "104", Name: "Dan Smith", DirectoryExclude: true},
+		{ID: 5, Extension: "105"},
+	}}
+	boxes := &mockVoicemailBoxRepo{boxes: []models.VoicemailBox{
+		{ID: 1, NotifyExtensionID: &aliceID, NameFile: nameFile},
+	}}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	engine := flow.NewSimulator(nil, nil, logger)
+	engine.RegisterHandler("directory", NewDirectoryHandler(flow.SimulatedSIPActions{}, extensions, boxes, dataDir, logger))
+	engine.RegisterHandler("hangup", NewHangupHandler(flow.SimulatedSIPActions{}, logger))
+
+	graph, err := flow.ParseFlowGraph(`{
+		"nodes": [
+			{"id": "dir", "type": "directory", "data": {"label": "Directory", "config": {"search_by": "last"}}},
+			{"id": "first", "type": "directory", "data": {"label": "First names", "config": {"search_by": "first", "letters": 2}}},
+			{"id": "missed", "type": "hangup", "data": {"label": "Missed"}},
+			{"id": "nobody", "type": "hangup", "data": {"label": "Nobody"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "dir", "target": "missed", "sourceHandle": "no_answer"},
+			{"id": "e2", "source": "dir", "target": "nobody", "sourceHandle": "not_found"},
+			{"id": "e3", "source": "first", "target": "nobody", "sourceHandle": "not_found"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		entry   string
+		call    flow.SimulatedCall
+		path    []string
+		outcome flow.SimulationOutcome
+		chosen  string
+		played  []string
+	}{
+		{
+			name:    "pass over recorded name, choose second",
+			entry:   "dir",
+			call:    flow.SimulatedCall{DTMF: []string{"764", "", "1"}, Answer: []string{"102"}},
+			path:    []string{"dir"},
+			outcome: flow.OutcomeAnswered,
+			chosen:  "102",
+			played:  []string{nameFile, "tts: Bob Smithers, extension 102. Press 1 to call, or star for the next name."},
+		},
+		{
+			name:    "chosen does not answer",
+			entry:   "dir",
+			call:    flow.SimulatedCall{DTMF: []string{"566", "1"}},
+			path:    []string{"dir", "missed"},
+			outcome: flow.OutcomeHangup,
+			chosen:  "103",
+		},
+		{
+			name:    "no match",
+			entry:   "dir",
+			call:    flow.SimulatedCall{DTMF: []string{"999", "764", "*", "*"}},
+			path:    []string{"dir", "nobody"},
+			outcome: flow.OutcomeHangup,
+			played:  []string{filepath.Join(dataDir, "prompts", "system", "dir_no_match.wav")},
+		},
+		{
+			name:    "first name",
+			entry:   "first",
+			call:    flow.SimulatedCall{DTMF: []string{"25", "1"}, Answer: []string{"101"}},
+			path:    []string{"first"},
+			outcome: flow.OutcomeAnswered,
+			chosen:  "101",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := engine.Simulate(context.Background(), graph, tt.entry, tt.call)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.FlowPath, tt.path) {
+				t.Errorf("path = %v, want %v", res.FlowPath, tt.path)
+			}
+			if res.Outcome != tt.outcome {
+				t.Errorf("outcome = %q, want %q (error %q)", res.Outcome, tt.outcome, res.Error)
+			}
+			if got := res.Variables["directory_extension"]; got != tt.chosen {
+				t.Errorf("directory_extension = %q, want %q", got, tt.chosen)
+			}
+
+			var played []string
+			for _, a := range res.Actions {
+				if a.Action == "play" || a.Action == "collect" {
+					played = append(played, a.Detail)
+				}
+			}
+			for _, want := range tt.played {
+				if !slices.ContainsFunc(played, func(p string) bool { return strings.HasPrefix(p, want) }) {
+					t.Errorf("played = %q, want %q", played, want)
+				}
+			}
+		})
+	}
+}
@@ -41,6 +41,10 @@ func (m *mockSIPActions) PlayAndCollect(_ context.Context, callCtx *flow.CallCon
 	return &flow.CollectResult{TimedOut: true}, nil
 }
 
+func (m *mockSIPActions) AnswerCall(_ context.Context, _ *flow.CallContext) error {
+	return nil
+}
+
 func (m *mockSIPActions) RecordMessage(_ context.Context, _ *flow.CallContext, _ string, _ int, _ string) (*flow.RecordResult, error) {
 	return &flow.RecordResult{}, nil
 }
@@ -49,6 +53,9 @@ func (m *mockSIPActions) SendMWI(_ context.Context, _ *models.Extension, _ int,
 	return nil
 }
 
+func (m *mockSIPActions) VoicemailReceived(_ context.Context, _ *models.VoicemailBox, _ *models.VoicemailMessage) {
+}
+
 func (m *mockSIPActions) HangupCall(_ context.Context, _ *flow.CallContext, _ int, _ string) error {
 	return nil
 }
@@ -69,6 +76,18 @@ func (m *mockSIPActions) RingFollowMeSimultaneous(_ context.Context, _ *flow.Cal
 	return &flow.RingResult{Answered: false}, nil
 }
 
+func (m *mockSIPActions) StartEarlyMedia(_ context.Context, _ *flow.CallContext) error {
+	return nil
+}
+
+func (m *mockSIPActions) PlayPrompt(_ context.Context, _ *flow.CallContext, _ string) error {
+	return nil
+}
+
+func (m *mockSIPActions) PlayHoldMusic(_ context.Context, _ *flow.CallContext, _ *int64, _ string) error {
+	return nil
+}
+
 func newTestIVRHandler(menu *models.IVRMenu, sip *mockSIPActions) *IVRMenuHandler {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	resolver := &mockEntityResolver{entity: menu}
@@ -28,7 +28,8 @@ func NewPlayMessageHandler(engine *flow.Engine, sip flow.SIPActions, logger *slo
 // Execute plays the configured audio file or TTS text, then returns "next"
 // to continue the flow. The prompt is configured via the node's Config map
 // with keys "file" (audio file path) or "tts" (TTS text). If both are set,
-// "file" takes precedence.
+// "file" takes precedence. Either may interpolate call fields and flow
+// variables as a template, e.g. "Your balance is {{.Variables.balance}}".
 func (h *PlayMessageHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
 	h.logger.Debug("play message node executing",
 		"call_id", callCtx.CallID,
@@ -40,6 +41,10 @@ func (h *PlayMessageHandler) Execute(ctx context.Context, callCtx *flow.CallCont
 	if err != nil {
 		return "", fmt.Errorf("play message node %s: %w", node.ID, err)
 	}
+	prompt, err = flow.Interpolate(callCtx, "prompt", prompt)
+	if err != nil {
+		return "", fmt.Errorf("play message node %s: %w", node.ID, err)
+	}
 
 	h.logger.Info("playing message",
 		"call_id", callCtx.CallID,
@@ -2,36 +2,543 @@ package nodes
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
 	"github.com/flowpbx/flowpbx/internal/flow"
 )
 
-// QueueHandler is a stub for the Queue (ACD) node type. Queue nodes will
-// place callers in a hold queue with music and distribute calls to agents.
-// This is reserved for a future phase; the current implementation logs a
-// warning and follows the "timeout" output edge since no agents are available.
+const (
+	// queueNodeTimeout bounds how long a caller can stay in a queue node when
+	// the queue has no max_wait_time and the node has no explicit timeout.
+	queueNodeTimeout = 4 * time.Hour
+
+	// queueRetryDelay is the pause between offering the head caller to the
+	// agents again after nobody answered.
+	queueRetryDelay = 5 * time.Second
+
+	// defaultQueueHoldMusic is the system prompt played when the queue has
+	// no hold_music_file configured.
+	defaultQueueHoldMusic = "prompts/system/queue_hold_music.wav"
+
+	// queuePositionPrompt introduces the position announcement ("you are
+	// caller number..."), followed by one digit_N prompt per digit.
+	queuePositionPrompt = "prompts/system/queue_position.wav"
+)
+
+// QueueHandler handles the Queue (ACD) node type. Callers wait in a per-queue
+// FIFO listening to hold music with optional position announcements, and the
+// caller at the head of the queue is offered to the member extensions (agents)
+// using the queue's ring strategy (ring_all, round_robin or longest_idle).
+//
+// Output edges:
+//   - "answered": an agent answered the call
+//   - "timeout":  max_wait_time elapsed before an agent answered
+//   - "full":     the queue already had max_callers waiting
 type QueueHandler struct {
-	logger *slog.Logger
+	engine     *flow.Engine
+	sip        flow.SIPActions
+	extensions database.ExtensionRepository
+	dataDir    string
+	logger     *slog.Logger
+
+	// waiting holds the in-memory FIFO of callers per queue ID. Each entry
+	// is a *callQueue. State resets on process restart.
+	waiting sync.Map
+
+	// rrCounters tracks round-robin state per queue ID, in the same way as
+	// RingGroupHandler.
+	rrCounters sync.Map
+
+	// lastAnswered tracks the last time each agent answered a queue call,
+	// keyed by extension ID. Used by the longest_idle strategy.
+	lastAnswered sync.Map
 }
 
 // NewQueueHandler creates a new QueueHandler.
-func NewQueueHandler(logger *slog.Logger) *QueueHandler {
+func NewQueueHandler(engine *flow.Engine, sip flow.SIPActions, extensions database.ExtensionRepository, dataDir string, logger *slog.Logger) *QueueHandler {
 	return &QueueHandler{
-		logger: logger.With("handler", "queue"),
+		engine:     engine,
+		sip:        sip,
+		extensions: extensions,
+		dataDir:    dataDir,
+		logger:     logger.With("handler", "queue"),
 	}
 }
 
-// Execute logs that the queue node is not yet implemented and follows the
-// "timeout" output edge to allow the flow to continue gracefully.
+// NodeTimeout allows queue nodes to run far longer than the engine's default
+// per-node timeout. The queue's own max_wait_time is enforced by Execute.
+func (h *QueueHandler) NodeTimeout(_ flow.Node) time.Duration {
+	return queueNodeTimeout
+}
+
+// Execute resolves the queue entity, places the caller in the queue FIFO and
+// offers the call to agents once the caller reaches the head of the queue.
+// Returns "answered", "timeout" or "full". If the caller hangs up while
+// waiting, the node is terminal and returns an empty edge.
 func (h *QueueHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
-	h.logger.Warn("queue node not yet implemented, following timeout edge",
+	h.logger.Debug("queue node executing",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+	)
+
+	entity, err := h.engine.ResolveNodeEntity(ctx, node)
+	if err != nil {
+		return "", fmt.Errorf("resolving queue entity: %w", err)
+	}
+	if entity == nil {
+		return "", fmt.Errorf("queue node %s: no entity reference configured", node.ID)
+	}
+
+	q, ok := entity.(*models.Queue)
+	if !ok || q == nil {
+		return "", fmt.Errorf("queue node %s: entity is %T, expected *models.Queue", node.ID, entity)
+	}
+
+	members, err := h.loadMembers(ctx, callCtx, q)
+	if err != nil {
+		return "", err
+	}
+
+	fifo := h.callQueue(q.ID)
+	position, ok := fifo.join(callCtx.CallID, q.MaxCallers)
+	if !ok {
+		h.logger.Info("queue full, rejecting caller",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"queue", q.Name,
+			"max_callers", q.MaxCallers,
+		)
+		return "full", nil
+	}
+	defer fifo.leave(callCtx.CallID)
+
+	h.logger.Info("caller joined queue",
 		"call_id", callCtx.CallID,
 		"node_id", node.ID,
+		"queue", q.Name,
+		"position", position,
+		"strategy", q.Strategy,
+		"members", len(members),
 	)
 
+	start := time.Now()
+	waitCtx := ctx
+	if q.MaxWaitTime > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, time.Duration(q.MaxWaitTime)*time.Second)
+		defer cancel()
+	}
+
+	// Play hold music and position announcements while the caller waits.
+	// The hold goroutine reports a caller hangup on holdDone.
+	holdCtx, stopHold := context.WithCancel(waitCtx)
+	holdDone := make(chan error, 1)
+	if err := h.sip.StartEarlyMedia(holdCtx, callCtx); err != nil {
+		h.logger.Warn("queue hold music unavailable, caller will wait in silence",
+			"call_id", callCtx.CallID,
+			"queue", q.Name,
+			"error", err,
+		)
+	} else {
+		go func() {
+			holdDone <- h.hold(holdCtx, callCtx, q, fifo)
+		}()
+	}
+	defer stopHold()
+
+	ringTimeout := q.RingTimeout
+	if ringTimeout <= 0 {
+		ringTimeout = 20
+	}
+
+	for {
+		// Wait until this caller reaches the head of the queue.
+		for {
+			pos, changed := fifo.position(callCtx.CallID)
+			if pos == 1 {
+				break
+			}
+			select {
+			case <-changed:
+			case err := <-holdDone:
+				if errors.Is(err, flow.ErrCallerHungUp) {
+					return h.abandoned(callCtx, node, q, start)
+				}
+				holdDone = nil
+			case <-waitCtx.Done():
+				return h.waitExpired(ctx, callCtx, node, q, start)
+			}
+		}
+
+		answered, agent, err := h.offer(waitCtx, callCtx, q, members, ringTimeout)
+		if err != nil {
+			if errors.Is(err, flow.ErrCallerHungUp) {
+				return h.abandoned(callCtx, node, q, start)
+			}
+			return "", fmt.Errorf("offering queue %s call: %w", q.Name, err)
+		}
+		if answered {
+			stopHold()
+			wait := time.Since(start).Round(time.Second)
+			callCtx.SetVariable("queue_wait_time", strconv.Itoa(int(wait.Seconds())))
+			answeredBy := ""
+			if agent != nil {
+				answeredBy = agent.Extension
+				callCtx.SetVariable("queue_answered_by", answeredBy)
+			}
+			h.logger.Info("queue call answered",
+				"call_id", callCtx.CallID,
+				"node_id", node.ID,
+				"queue", q.Name,
+				"answered_by", answeredBy,
+				"wait", wait,
+			)
+			return "answered", nil
+		}
+
+		// A simulated caller waits for a single round of offers; if
+		// nobody answers, the wait runs out.
+		if callCtx.Simulation() != nil {
+			return h.waitExpired(ctx, callCtx, node, q, start)
+		}
+
+		// Nobody answered — keep holding and try again shortly.
+		select {
+		case <-time.After(queueRetryDelay):
+		case err := <-holdDone:
+			if errors.Is(err, flow.ErrCallerHungUp) {
+				return h.abandoned(callCtx, node, q, start)
+			}
+			holdDone = nil
+		case <-waitCtx.Done():
+			return h.waitExpired(ctx, callCtx, node, q, start)
+		}
+	}
+}
+
+// loadMembers parses the queue's member list and loads the agent extensions.
+// Missing extensions are skipped.
+func (h *QueueHandler) loadMembers(ctx context.Context, callCtx *flow.CallContext, q *models.Queue) ([]*models.Extension, error) {
+	var memberIDs []int64
+	if err := json.Unmarshal([]byte(q.Members), &memberIDs); err != nil {
+		return nil, fmt.Errorf("queue %s: parsing members json: %w", q.Name, err)
+	}
+
+	members := make([]*models.Extension, 0, len(memberIDs))
+	for _, extID := range memberIDs {
+		ext, err := h.extensions.GetByID(ctx, extID)
+		if err != nil {
+			h.logger.Error("failed to load queue member extension",
+				"call_id", callCtx.CallID,
+				"queue", q.Name,
+				"extension_id", extID,
+				"error", err,
+			)
+			continue
+		}
+		if ext == nil {
+			h.logger.Warn("queue member extension not found",
+				"call_id", callCtx.CallID,
+				"queue", q.Name,
+				"extension_id", extID,
+			)
+			continue
+		}
+		members = append(members, ext)
+	}
+
+	if len(members) == 0 {
+		h.logger.Warn("queue has no valid member extensions, callers will wait until timeout",
+			"call_id", callCtx.CallID,
+			"queue", q.Name,
+		)
+	}
+
+	return members, nil
+}
+
+// offer rings the queue's agents once using the configured strategy. It
+// reports whether the call was answered and, for the sequential strategies,
+// which agent answered (ring_all does not identify the answering member).
+func (h *QueueHandler) offer(ctx context.Context, callCtx *flow.CallContext, q *models.Queue, members []*models.Extension, ringTimeout int) (bool, *models.Extension, error) {
+	if len(members) == 0 {
+		return false, nil, nil
+	}
+
+	if q.Strategy != "round_robin" && q.Strategy != "longest_idle" {
+		// ring_all is the default strategy (also used for unrecognized values).
+		result, err := h.sip.RingGroup(ctx, callCtx, members, ringTimeout)
+		if err != nil {
+			return false, nil, err
+		}
+		return result.Answered, nil, nil
+	}
+
+	for _, member := range h.orderMembers(q, members) {
+		if ctx.Err() != nil {
+			return false, nil, nil
+		}
+
+		h.logger.Debug("offering queue call to agent",
+			"call_id", callCtx.CallID,
+			"queue", q.Name,
+			"extension", member.Extension,
+			"strategy", q.Strategy,
+		)
+
+		result, err := h.sip.RingExtension(ctx, callCtx, member, ringTimeout)
+		if err != nil {
+			if errors.Is(err, flow.ErrCallerHungUp) {
+				return false, nil, err
+			}
+			h.logger.Error("queue ring agent failed",
+				"call_id", callCtx.CallID,
+				"queue", q.Name,
+				"extension", member.Extension,
+				"error", err,
+			)
+			continue
+		}
+
+		if result.Answered {
+			h.lastAnswered.Store(member.ID, time.Now())
+			return true, member, nil
+		}
+	}
+
+	return false, nil, nil
+}
+
+// orderMembers returns the order in which agents are tried for the
+// sequential strategies. round_robin rotates the starting agent on every
+// offer; longest_idle starts with the agent whose last queue call was
+// answered the longest time ago.
+func (h *QueueHandler) orderMembers(q *models.Queue, members []*models.Extension) []*models.Extension {
+	ordered := make([]*models.Extension, 0, len(members))
+
+	switch q.Strategy {
+	case "round_robin":
+		val, _ := h.rrCounters.LoadOrStore(q.ID, &atomic.Uint64{})
+		seq := val.(*atomic.Uint64).Add(1) - 1
+		startIdx := int(seq % uint64(len(members)))
+		for i := range members {
+			ordered = append(ordered, members[(startIdx+i)%len(members)])
+		}
+	case "longest_idle":
+		ordered = append(ordered, members...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			ti := h.getLastAnswered(ordered[i].ID)
+			tj := h.getLastAnswered(ordered[j].ID)
+			return ti.Before(tj)
+		})
+	}
+
+	return ordered
+}
+
+// getLastAnswered returns the last time the agent answered a queue call, or
+// the zero time if it never has (which sorts first for longest_idle).
+func (h *QueueHandler) getLastAnswered(extensionID int64) time.Time {
+	val, ok := h.lastAnswered.Load(extensionID)
+	if !ok {
+		return time.Time{}
+	}
+	return val.(time.Time)
+}
+
+// hold plays hold music to the caller until ctx is cancelled, interrupting
+// it every announce_interval seconds to announce the caller's position.
+// The music is the queue's music on hold class, else that of the inbound
+// number called, else the queue's hold music file.
+// Returns flow.ErrCallerHungUp if the caller abandons the call.
+func (h *QueueHandler) hold(ctx context.Context, callCtx *flow.CallContext, q *models.Queue, fifo *callQueue) error {
+	music := q.HoldMusicFile
+	if music == "" {
+		music = filepath.Join(h.dataDir, defaultQueueHoldMusic)
+	}
+	class := q.MOHClassID
+	if class == nil && callCtx.InboundNumber != nil {
+		class = callCtx.InboundNumber.MOHClassID
+	}
+
+	interval := time.Duration(q.AnnounceInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	for ctx.Err() == nil {
+		if q.AnnouncePosition {
+			if pos, _ := fifo.position(callCtx.CallID); pos > 0 {
+				if err := h.announcePosition(ctx, callCtx, pos); err != nil {
+					return err
+				}
+			}
+		}
+
+		var err error
+		var expired bool
+		if q.AnnouncePosition {
+			musicCtx, cancel := context.WithTimeout(ctx, interval)
+			err = h.sip.PlayHoldMusic(musicCtx, callCtx, class, music)
+			expired = musicCtx.Err() != nil
+			cancel()
+		} else {
+			err = h.sip.PlayHoldMusic(ctx, callCtx, class, music)
+			expired = ctx.Err() != nil
+		}
+
+		if errors.Is(err, flow.ErrCallerHungUp) {
+			return err
+		}
+		if err != nil {
+			h.logger.Warn("queue hold music failed, continuing in silence",
+				"call_id", callCtx.CallID,
+				"queue", q.Name,
+				"file", music,
+				"error", err,
+			)
+			<-ctx.Done()
+			return nil
+		}
+		if !expired {
+			// Playback stopped without our deadline firing: the call was
+			// answered by an agent and early media has been detached.
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// announcePosition plays "you are caller number" followed by the digits of
+// the caller's position in the queue.
+func (h *QueueHandler) announcePosition(ctx context.Context, callCtx *flow.CallContext, pos int) error {
+	files := []string{filepath.Join(h.dataDir, queuePositionPrompt)}
+	for _, d := range strconv.Itoa(pos) {
+		files = append(files, filepath.Join(h.dataDir, "prompts", "system", "digit_"+string(d)+".wav"))
+	}
+
+	for _, f := range files {
+		if err := h.sip.PlayPrompt(ctx, callCtx, f); err != nil {
+			if errors.Is(err, flow.ErrCallerHungUp) {
+				return err
+			}
+			h.logger.Warn("queue position announcement failed",
+				"call_id", callCtx.CallID,
+				"file", f,
+				"error", err,
+			)
+			return nil
+		}
+	}
+	return nil
+}
+
+// waitExpired returns the "timeout" edge once max_wait_time has elapsed. If
+// the node context itself expired, the error is passed to the engine so it
+// follows the "timeout" edge in the usual way.
+func (h *QueueHandler) waitExpired(ctx context.Context, callCtx *flow.CallContext, node flow.Node, q *models.Queue, start time.Time) (string, error) {
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return "", err
+	}
+
+	h.logger.Info("queue max wait time reached",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+		"queue", q.Name,
+		"wait", time.Since(start).Round(time.Second),
+	)
 	return "timeout", nil
 }
 
-// Ensure QueueHandler satisfies the NodeHandler interface.
-var _ flow.NodeHandler = (*QueueHandler)(nil)
+// abandoned logs a caller hanging up while waiting and ends the flow.
+func (h *QueueHandler) abandoned(callCtx *flow.CallContext, node flow.Node, q *models.Queue, start time.Time) (string, error) {
+	h.logger.Info("caller abandoned queue",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+		"queue", q.Name,
+		"wait", time.Since(start).Round(time.Second),
+	)
+	return "", nil
+}
+
+// callQueue returns the FIFO for the given queue ID, creating it if needed.
+func (h *QueueHandler) callQueue(queueID int64) *callQueue {
+	val, _ := h.waiting.LoadOrStore(queueID, newCallQueue())
+	return val.(*callQueue)
+}
+
+// callQueue is the in-memory FIFO of callers waiting in a single queue.
+type callQueue struct {
+	mu      sync.Mutex
+	callers []string      // call IDs in arrival order
+	changed chan struct{} // closed and replaced whenever callers changes
+}
+
+func newCallQueue() *callQueue {
+	return &callQueue{changed: make(chan struct{})}
+}
+
+// join appends the caller to the queue and returns its 1-based position.
+// Returns false if maxCallers (> 0) callers are already waiting.
+func (q *callQueue) join(callID string, maxCallers int) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if maxCallers > 0 && len(q.callers) >= maxCallers {
+		return 0, false
+	}
+	q.callers = append(q.callers, callID)
+	q.notifyLocked()
+	return len(q.callers), true
+}
+
+// leave removes the caller from the queue, moving everyone behind it up.
+func (q *callQueue) leave(callID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, id := range q.callers {
+		if id == callID {
+			q.callers = append(q.callers[:i], q.callers[i+1:]...)
+			q.notifyLocked()
+			return
+		}
+	}
+}
+
+// position returns the caller's 1-based position (0 if not queued) and a
+// channel that is closed the next time the queue changes.
+func (q *callQueue) position(callID string) (int, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, id := range q.callers {
+		if id == callID {
+			return i + 1, q.changed
+		}
+	}
+	return 0, q.changed
+}
+
+func (q *callQueue) notifyLocked() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// Ensure QueueHandler satisfies the NodeHandler and NodeTimeoutProvider interfaces.
+var (
+	_ flow.NodeHandler         = (*QueueHandler)(nil)
+	_ flow.NodeTimeoutProvider = (*QueueHandler)(nil)
+)
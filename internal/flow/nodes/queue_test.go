@@ -0,0 +1,175 @@
+package nodes
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// mockQueueSIPActions extends mockSIPActions with configurable ring results
+// and hold music that blocks until cancelled, like the real implementation.
+type mockQueueSIPActions struct {
+	mockSIPActions
+
+	mu        sync.Mutex
+	answerExt string // extension that answers RingExtension ("" = nobody)
+	groupAns  bool   // whether RingGroup is answered
+	rung      []string
+}
+
+func (m *mockQueueSIPActions) RingExtension(_ context.Context, _ *flow.CallContext, ext *models.Extension, _ int) (*flow.RingResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rung = append(m.rung, ext.Extension)
+	return &flow.RingResult{Answered: ext.Extension == m.answerExt}, nil
+}
+
+func (m *mockQueueSIPActions) RingGroup(_ context.Context, _ *flow.CallContext, exts []*models.Extension, _ int) (*flow.RingResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ext := range exts {
+		m.rung = append(m.rung, ext.Extension)
+	}
+	return &flow.RingResult{Answered: m.groupAns}, nil
+}
+
+func (m *mockQueueSIPActions) PlayHoldMusic(ctx context.Context, _ *flow.CallContext, _ *int64, _ string) error {
+	<-ctx.Done()
+	return nil
+}
+
+func newTestQueueHandler(q *models.Queue, sip *mockQueueSIPActions) *QueueHandler {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	resolver := &mockEntityResolver{entity: q}
+	engine := flow.NewEngine(nil, nil, resolver, logger)
+	extRepo := &mockExtensionRepo{extensions: map[int64]*models.Extension{
+		1: {ID: 1, Extension: "101", Name: "Alice"},
+		2: {ID: 2, Extension: "102", Name: "Bob"},
+	}}
+	return NewQueueHandler(engine, sip, extRepo, os.TempDir(), logger)
+}
+
+func makeQueueNode(entityID int64) flow.Node {
+	id := entityID
+	return flow.Node{
+		ID:   "node_queue",
+		Type: "queue",
+		Data: flow.NodeData{
+			Label:      "Support Queue",
+			EntityID:   &id,
+			EntityType: "queue",
+		},
+	}
+}
+
+func TestQueueRingAllAnswered(t *testing.T) {
+	q := &models.Queue{ID: 1, Name: "Support", Strategy: "ring_all", RingTimeout: 20, Members: "[1,2]", MaxWaitTime: 60}
+	sip := &mockQueueSIPActions{groupAns: true}
+
+	h := newTestQueueHandler(q, sip)
+	callCtx := flow.NewCallContext("test-queue-1", "", "", "", nil, 0, nil, nil)
+
+	edge, err := h.Execute(context.Background(), callCtx, makeQueueNode(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "answered" {
+		t.Errorf("expected edge %q, got %q", "answered", edge)
+	}
+	if len(sip.rung) != 2 {
+		t.Errorf("expected both members rung, got %v", sip.rung)
+	}
+}
+
+func TestQueueRoundRobinRotates(t *testing.T) {
+	q := &models.Queue{ID: 1, Name: "Support", Strategy: "round_robin", RingTimeout: 20, Members: "[1,2]", MaxWaitTime: 60}
+	sip := &mockQueueSIPActions{answerExt: "102"}
+
+	h := newTestQueueHandler(q, sip)
+
+	callCtx := flow.NewCallContext("test-queue-2", "", "", "", nil, 0, nil, nil)
+	edge, err := h.Execute(context.Background(), callCtx, makeQueueNode(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "answered" {
+		t.Fatalf("expected edge %q, got %q", "answered", edge)
+	}
+	if got := callCtx.GetVariable("queue_answered_by"); got != "102" {
+		t.Errorf("expected queue_answered_by %q, got %q", "102", got)
+	}
+
+	// The second call starts with the next agent, who answers immediately.
+	sip.rung = nil
+	callCtx = flow.NewCallContext("test-queue-3", "", "", "", nil, 0, nil, nil)
+	if _, err := h.Execute(context.Background(), callCtx, makeQueueNode(1)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sip.rung) != 1 || sip.rung[0] != "102" {
+		t.Errorf("expected only 102 rung on second call, got %v", sip.rung)
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	q := &models.Queue{ID: 1, Name: "Support", Strategy: "ring_all", RingTimeout: 20, Members: "[1]", MaxWaitTime: 1}
+	sip := &mockQueueSIPActions{}
+
+	h := newTestQueueHandler(q, sip)
+	callCtx := flow.NewCallContext("test-queue-4", "", "", "", nil, 0, nil, nil)
+
+	edge, err := h.Execute(context.Background(), callCtx, makeQueueNode(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "timeout" {
+		t.Errorf("expected edge %q, got %q", "timeout", edge)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	q := &models.Queue{ID: 1, Name: "Support", Strategy: "ring_all", RingTimeout: 20, Members: "[1]", MaxCallers: 1}
+	sip := &mockQueueSIPActions{}
+
+	h := newTestQueueHandler(q, sip)
+	h.callQueue(q.ID).join("already-waiting", 0)
+
+	callCtx := flow.NewCallContext("test-queue-5", "", "", "", nil, 0, nil, nil)
+	edge, err := h.Execute(context.Background(), callCtx, makeQueueNode(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "full" {
+		t.Errorf("expected edge %q, got %q", "full", edge)
+	}
+}
+
+func TestCallQueuePositions(t *testing.T) {
+	q := newCallQueue()
+	q.join("a", 0)
+	q.join("b", 0)
+	q.join("c", 0)
+
+	_, changed := q.position("c")
+	q.leave("a")
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected change notification after leave")
+	}
+
+	if pos, _ := q.position("b"); pos != 1 {
+		t.Errorf("expected b at position 1, got %d", pos)
+	}
+	if pos, _ := q.position("c"); pos != 2 {
+		t.Errorf("expected c at position 2, got %d", pos)
+	}
+	if pos, _ := q.position("a"); pos != 0 {
+		t.Errorf("expected a to be removed, got position %d", pos)
+	}
+}
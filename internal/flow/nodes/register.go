@@ -6,6 +6,7 @@ import (
 	"github.com/flowpbx/flowpbx/internal/database"
 	"github.com/flowpbx/flowpbx/internal/email"
 	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/tts"
 )
 
 // RegisterAll registers all implemented node handlers on the flow engine.
@@ -13,33 +14,45 @@ import (
 // interact with the call (ringing extensions, media bridging, etc.).
 // The extensions parameter provides access to the extension repository for
 // handlers that need to resolve member extensions (e.g. ring groups).
+// The voicemailBoxes parameter provides mailbox lookup for voicemail retrieval.
 // The voicemailMessages parameter provides voicemail message storage.
 // The sysConfig parameter provides access to system configuration (SMTP etc.).
 // The enc parameter provides encryption/decryption for sensitive config values.
 // The emailSend parameter provides email sending capability.
+// The speech parameter renders TTS prompts; it is nil if no TTS engine is configured.
+// The calendars parameter provides the calendars imported into time switches.
 // The dataDir parameter is the root data directory for file storage.
 func RegisterAll(
 	engine *flow.Engine,
 	sipActions flow.SIPActions,
 	extensions database.ExtensionRepository,
+	voicemailBoxes database.VoicemailBoxRepository,
 	voicemailMessages database.VoicemailMessageRepository,
 	sysConfig database.SystemConfigRepository,
 	enc *database.Encryptor,
 	emailSend *email.Sender,
+	speech *tts.Cache,
+	calendars TimeSwitchCalendars,
 	dataDir string,
 	logger *slog.Logger,
 ) {
 	engine.RegisterHandler("inbound_number", NewInboundNumberHandler(logger))
 	engine.RegisterHandler("extension", NewExtensionHandler(engine, sipActions, logger))
 	engine.RegisterHandler("ring_group", NewRingGroupHandler(engine, sipActions, extensions, logger))
-	engine.RegisterHandler("time_switch", NewTimeSwitchHandler(engine, logger))
+	engine.RegisterHandler("directory", NewDirectoryHandler(sipActions, extensions, voicemailBoxes, dataDir, logger))
+	engine.RegisterHandler("time_switch", NewTimeSwitchHandler(engine, calendars, logger))
 	engine.RegisterHandler("ivr_menu", NewIVRMenuHandler(engine, sipActions, logger))
-	engine.RegisterHandler("voicemail", NewVoicemailHandler(engine, sipActions, voicemailMessages, extensions, sysConfig, enc, emailSend, logger, dataDir))
+	engine.RegisterHandler("voicemail", NewVoicemailHandler(engine, sipActions, voicemailMessages, extensions, sysConfig, enc, emailSend, speech, logger, dataDir))
+	engine.RegisterHandler("voicemail_retrieval", NewVoicemailRetrievalHandler(engine, sipActions, voicemailBoxes, voicemailMessages, extensions, dataDir, logger))
 	engine.RegisterHandler("play_message", NewPlayMessageHandler(engine, sipActions, logger))
 	engine.RegisterHandler("hangup", NewHangupHandler(sipActions, logger))
 	engine.RegisterHandler("set_caller_id", NewSetCallerIDHandler(logger))
+	engine.RegisterHandler("set_variable", NewSetVariableHandler(logger))
+	engine.RegisterHandler("condition", NewConditionHandler(logger))
+	engine.RegisterHandler("sub_flow", NewSubFlowHandler(engine, logger))
+	engine.RegisterHandler("return", NewReturnHandler(logger))
 	engine.RegisterHandler("transfer", NewTransferHandler(sipActions, logger))
 	engine.RegisterHandler("conference", NewConferenceHandler(engine, sipActions, logger))
 	engine.RegisterHandler("webhook", NewWebhookHandler(logger))
-	engine.RegisterHandler("queue", NewQueueHandler(logger))
+	engine.RegisterHandler("queue", NewQueueHandler(engine, sipActions, extensions, dataDir, logger))
 }
@@ -0,0 +1,108 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// SetVariableHandler handles the Set Variable node type. It assigns flow
+// variables from expressions for later nodes to branch on or interpolate.
+// This is a passthrough node — it modifies state and returns "next".
+//
+// Node config:
+//   - "variables": array of {"name": variable, "expression": value},
+//     assigned in order, so later expressions see earlier assignments.
+//     Expressions use the flow expression language; a literal string is
+//     quoted, e.g. `"gold"`, and `retries + 1` increments a counter.
+//
+// An expression that fails to evaluate is logged and its variable left
+// unchanged.
+type SetVariableHandler struct {
+	logger *slog.Logger
+}
+
+// NewSetVariableHandler creates a new SetVariableHandler.
+func NewSetVariableHandler(logger *slog.Logger) *SetVariableHandler {
+	return &SetVariableHandler{
+		logger: logger.With("handler", "set_variable"),
+	}
+}
+
+// assignment is one parsed variable assignment of a set variable node.
+type assignment struct {
+	name string
+	expr *flow.Expr
+}
+
+// Execute evaluates and stores each configured variable, then returns
+// "next".
+func (h *SetVariableHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
+	h.logger.Debug("set variable node executing",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+	)
+
+	assignments, err := parseAssignments(node)
+	if err != nil {
+		return "", fmt.Errorf("set variable node %s: %w", node.ID, err)
+	}
+
+	for _, a := range assignments {
+		value, err := a.expr.Eval(callCtx)
+		if err != nil {
+			h.logger.Warn("variable expression failed to evaluate",
+				"call_id", callCtx.CallID,
+				"node_id", node.ID,
+				"variable", a.name,
+				"expression", a.expr.String(),
+				"error", err,
+			)
+			continue
+		}
+		callCtx.SetVariable(a.name, value)
+		h.logger.Debug("variable set",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"variable", a.name,
+			"value", value,
+		)
+	}
+
+	return "next", nil
+}
+
+// parseAssignments reads and parses the variables of a set variable node.
+func parseAssignments(node flow.Node) ([]assignment, error) {
+	if node.Data.Config == nil {
+		return nil, fmt.Errorf("no config specified")
+	}
+	raw, ok := node.Data.Config["variables"].([]any)
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("no variables configured")
+	}
+
+	assignments := make([]assignment, 0, len(raw))
+	for i, item := range raw {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("variable %d must be an object", i+1)
+		}
+		name, _ := obj["name"].(string)
+		if err := flow.ValidateVariableName(name); err != nil {
+			return nil, err
+		}
+		src, _ := obj["expression"].(string)
+		expr, err := flow.ParseExpr(src)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q: %w", name, err)
+		}
+		assignments = append(assignments, assignment{name: name, expr: expr})
+	}
+	return assignments, nil
+}
+
+// Ensure SetVariableHandler satisfies the NodeHandler interface.
+var _ flow.NodeHandler = (*SetVariableHandler)(nil)
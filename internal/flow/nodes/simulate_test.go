@@ -0,0 +1,242 @@
+package nodes
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// typedEntityResolver returns entities by type, ignoring the ID.
+type typedEntityResolver map[string]any
+
+func (m typedEntityResolver) ResolveEntity(_ context.Context, entityType string, _ int64) (any, error) {
+	return m[entityType], nil
+}
+
+// simulationGraph is a business hours flow: during opening hours callers
+// get a menu where 1 rings extension 101, 2 is not connected, and anything
+// else goes to voicemail; after hours callers go straight to voicemail.
+const simulationGraph = `{
+	"nodes": [
+		{"id": "in", "type": "inbound_number", "data": {"label": "Main", "entity_id": 1, "entity_type": "inbound_number"}},
+		{"id": "hours", "type": "time_switch", "data": {"label": "Hours", "entity_id": 1, "entity_type": "time_switch"}},
+		{"id": "menu", "type": "ivr_menu", "data": {"label": "Menu", "entity_id": 1, "entity_type": "ivr_menu"}},
+		{"id": "sales", "type": "extension", "data": {"label": "Sales", "entity_id": 1, "entity_type": "extension"}},
+		{"id": "vm", "type": "voicemail", "data": {"label": "Voicemail", "entity_id": 1, "entity_type": "voicemail_box"}},
+		{"id": "bye", "type": "hangup", "data": {"label": "Bye"}}
+	],
+	"edges": [
+		{"id": "e1", "source": "in", "target": "hours", "sourceHandle": "next"},
+		{"id": "e2", "source": "hours", "target": "menu", "sourceHandle": "Open"},
+		{"id": "e3", "source": "hours", "target": "vm", "sourceHandle": "default"},
+		{"id": "e4", "source": "menu", "target": "sales", "sourceHandle": "1"},
+		{"id": "e5", "source": "menu", "target": "vm", "sourceHandle": "timeout"},
+		{"id": "e6", "source": "menu", "target": "vm", "sourceHandle": "invalid"},
+		{"id": "e7", "source": "sales", "target": "vm", "sourceHandle": "no_answer"},
+		{"id": "e8", "source": "vm", "target": "bye", "sourceHandle": "next"}
+	]
+}`
+
+func newTestSimulator(t *testing.T) *flow.Engine {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	resolver := typedEntityResolver{
+		"time_switch": &models.TimeSwitch{
+			ID:       1,
+			Name:     "Hours",
+			Timezone: "Australia/Sydney",
+			Rules:    `[{"label":"Open","days":["mon","tue","wed","thu","fri"],"start":"09:00","end":"17:00"}]`,
+		},
+		"ivr_menu": &models.IVRMenu{
+			ID:           1,
+			Name:         "Menu",
+			GreetingFile: "/audio/menu.wav",
+			Timeout:      5,
+			MaxRetries:   1,
+			DigitTimeout: 3,
+			Options:      `{"1":"sales","2":"support"}`,
+		},
+		"extension":     &models.Extension{ID: 1, Extension: "101", Name: "Sales"},
+		"voicemail_box": &models.VoicemailBox{ID: 1, Name: "Main", MailboxNumber: "100"},
+	}
+	engine := flow.NewSimulator(nil, resolver, logger)
+	RegisterAll(engine, flow.SimulatedSIPActions{}, nil, nil, nil, nil, nil, nil, nil, nil, t.TempDir(), logger)
+	return engine
+}
+
+func TestSimulateBusinessHoursFlow(t *testing.T) {
+	graph, err := flow.ParseFlowGraph(simulationGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := time.LoadLocation("Australia/Sydney")
+	wednesday := time.Date(2025, 3, 12, 10, 0, 0, 0, loc)
+	saturday := time.Date(2025, 3, 15, 10, 0, 0, 0, loc)
+	did := &models.InboundNumber{ID: 1, Number: "0290000000"}
+
+	tests := []struct {
+		name    string
+		call    flow.SimulatedCall
+		path    []string
+		outcome flow.SimulationOutcome
+		nodeID  string
+		detail  string
+	}{
+		{
+			name:    "open, press 1, answered",
+			call:    flow.SimulatedCall{Time: wednesday, DTMF: []string{"1"}, Answer: []string{"101"}},
+			path:    []string{"in", "hours", "menu", "sales"},
+			outcome: flow.OutcomeAnswered,
+			nodeID:  "sales",
+			detail:  "extension 101",
+		},
+		{
+			name:    "open, press 1, no answer",
+			call:    flow.SimulatedCall{Time: wednesday, DTMF: []string{"1"}},
+			path:    []string{"in", "hours", "menu", "sales", "vm", "bye"},
+			outcome: flow.OutcomeVoicemail,
+			nodeID:  "vm",
+		},
+		{
+			name:    "open, no input",
+			call:    flow.SimulatedCall{Time: wednesday},
+			path:    []string{"in", "hours", "menu", "vm", "bye"},
+			outcome: flow.OutcomeVoicemail,
+			nodeID:  "vm",
+		},
+		{
+			name:    "open, press unconnected option",
+			call:    flow.SimulatedCall{Time: wednesday, DTMF: []string{"2"}},
+			path:    []string{"in", "hours", "menu"},
+			outcome: flow.OutcomeDeadEnd,
+			nodeID:  "menu",
+		},
+		{
+			name:    "closed",
+			call:    flow.SimulatedCall{Time: saturday, DTMF: []string{"1"}, Answer: []string{"101"}},
+			path:    []string{"in", "hours", "vm", "bye"},
+			outcome: flow.OutcomeVoicemail,
+			nodeID:  "vm",
+		},
+	}
+
+	engine := newTestSimulator(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.call.DID = did.Number
+			tt.call.InboundNumber = did
+			res, err := engine.Simulate(context.Background(), graph, "in", tt.call)
+			if err != nil {
+				t.Fatalf("Simulate() error: %v", err)
+			}
+			if !slices.Equal(res.FlowPath, tt.path) {
+				t.Errorf("path = %v, want %v", res.FlowPath, tt.path)
+			}
+			if res.Outcome != tt.outcome || res.NodeID != tt.nodeID || res.Detail != tt.detail {
+				t.Errorf("outcome = %s at %s (%q), want %s at %s (%q); error %q",
+					res.Outcome, res.NodeID, res.Detail, tt.outcome, tt.nodeID, tt.detail, res.Error)
+			}
+		})
+	}
+}
+
+func TestSimulateWebhookResponse(t *testing.T) {
+	graph, err := flow.ParseFlowGraph(`{
+		"nodes": [
+			{"id": "hook", "type": "webhook", "data": {"label": "CRM", "config": {
+				"url": "https://crm.invalid/lookup",
+				"response_map": {"tier": "customer.tier"},
+				"branch_field": "route"
+			}}},
+			{"id": "vip", "type": "transfer", "data": {"label": "VIP", "config": {"destination": "200"}}},
+			{"id": "bye", "type": "hangup", "data": {"label": "Bye"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "hook", "target": "vip", "sourceHandle": "vip"},
+			{"id": "e2", "source": "hook", "target": "bye", "sourceHandle": "timeout"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := newTestSimulator(t)
+
+	res, err := engine.Simulate(context.Background(), graph, "hook", flow.SimulatedCall{
+		Webhooks: map[string]flow.SimulatedWebhook{
+			"hook": {Status: 200, Body: `{"route":"vip","customer":{"tier":"gold"}}`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	if res.Outcome != flow.OutcomeTransferred || res.Detail != "200" {
+		t.Errorf("outcome = %s (%s), want transferred to 200; error %q", res.Outcome, res.Detail, res.Error)
+	}
+	if res.Variables["tier"] != "gold" || res.Variables["webhook_status"] != "200" {
+		t.Errorf("variables = %v", res.Variables)
+	}
+
+	res, err = engine.Simulate(context.Background(), graph, "hook", flow.SimulatedCall{
+		Webhooks: map[string]flow.SimulatedWebhook{"hook": {TimedOut: true}},
+	})
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	if res.Outcome != flow.OutcomeHangup {
+		t.Errorf("timed out webhook: outcome = %s, want hangup", res.Outcome)
+	}
+}
+
+func TestSimulateWebhookFallbackEdges(t *testing.T) {
+	// Webhook nodes saved before they made requests are wired from "next"
+	// only; every response continues there, but a wired edge still wins.
+	// A branch value without an edge falls back to the status class edge.
+	graph, err := flow.ParseFlowGraph(`{
+		"nodes": [
+			{"id": "hook", "type": "webhook", "data": {"label": "CRM", "config": {"url": "https://crm.invalid/lookup", "branch_field": "route"}}},
+			{"id": "vip", "type": "transfer", "data": {"label": "VIP", "config": {"destination": "200"}}},
+			{"id": "ok", "type": "transfer", "data": {"label": "OK", "config": {"destination": "300"}}},
+			{"id": "bye", "type": "hangup", "data": {"label": "Bye"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "hook", "target": "bye", "sourceHandle": "next"},
+			{"id": "e2", "source": "hook", "target": "vip", "sourceHandle": "vip"},
+			{"id": "e3", "source": "hook", "target": "ok", "sourceHandle": "2xx"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := newTestSimulator(t)
+
+	tests := []struct {
+		name    string
+		webhook flow.SimulatedWebhook
+		path    []string
+	}{
+		{"wired branch", flow.SimulatedWebhook{Status: 200, Body: `{"route":"vip"}`}, []string{"hook", "vip"}},
+		{"unwired branch", flow.SimulatedWebhook{Status: 200, Body: `{"route":"gold"}`}, []string{"hook", "ok"}},
+		{"unwired branch and status", flow.SimulatedWebhook{Status: 503, Body: `{"route":"gold"}`}, []string{"hook", "bye"}},
+		{"wired status", flow.SimulatedWebhook{Status: 200}, []string{"hook", "ok"}},
+		{"timeout", flow.SimulatedWebhook{TimedOut: true}, []string{"hook", "bye"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := engine.Simulate(context.Background(), graph, "hook", flow.SimulatedCall{
+				Webhooks: map[string]flow.SimulatedWebhook{"hook": tt.webhook},
+			})
+			if err != nil {
+				t.Fatalf("Simulate() error: %v", err)
+			}
+			if !slices.Equal(res.FlowPath, tt.path) {
+				t.Errorf("path = %v, want %v (error %q)", res.FlowPath, tt.path, res.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// subFlowNodeTimeout bounds how long a call can spend inside a sub-flow
+// node when the node has no explicit timeout. The nodes of the sub-flow
+// keep their own timeouts.
+const subFlowNodeTimeout = 4 * time.Hour
+
+// SubFlowHandler handles the Sub-Flow node type. It jumps into the
+// published revision of another call flow, so that a fragment such as an
+// after-hours menu can be shared by several flows. The sub-flow sees and
+// sets the same call variables as the calling flow.
+//
+// Node config:
+//   - "flow_id": ID of the flow to enter
+//   - "entry_node": ID of the node in that flow to start at
+//
+// Output edges:
+//   - the exit named by the Return node that ended the sub-flow
+//
+// If the sub-flow ends without a Return node (e.g. it hangs up or sends the
+// caller to voicemail), so does the calling flow. A Sub-Flow node without
+// output edges is therefore a plain "goto" into the other flow.
+type SubFlowHandler struct {
+	engine *flow.Engine
+	logger *slog.Logger
+}
+
+// NewSubFlowHandler creates a new SubFlowHandler.
+func NewSubFlowHandler(engine *flow.Engine, logger *slog.Logger) *SubFlowHandler {
+	return &SubFlowHandler{
+		engine: engine,
+		logger: logger.With("handler", "sub_flow"),
+	}
+}
+
+// NodeTimeout allows a sub-flow node to run for as long as the nodes of the
+// sub-flow keep the caller.
+func (h *SubFlowHandler) NodeTimeout(_ flow.Node) time.Duration {
+	return subFlowNodeTimeout
+}
+
+// Execute runs the sub-flow and returns the exit it left through.
+func (h *SubFlowHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
+	flowID, entryNode, err := parseSubFlowConfig(node)
+	if err != nil {
+		return "", fmt.Errorf("sub-flow node %s: %w", node.ID, err)
+	}
+
+	h.logger.Info("entering sub-flow",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+		"flow_id", flowID,
+		"entry_node", entryNode,
+	)
+
+	exit, err := h.engine.ExecuteSubFlow(ctx, callCtx, flowID, entryNode)
+	if err != nil {
+		return "", fmt.Errorf("sub-flow node %s: %w", node.ID, err)
+	}
+
+	if exit == "" {
+		h.logger.Info("sub-flow ended the call",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"flow_id", flowID,
+		)
+	} else {
+		h.logger.Info("returned from sub-flow",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"flow_id", flowID,
+			"exit", exit,
+		)
+	}
+	return exit, nil
+}
+
+// parseSubFlowConfig reads the target flow and entry node of a sub-flow node.
+func parseSubFlowConfig(node flow.Node) (int64, string, error) {
+	if node.Data.Config == nil {
+		return 0, "", fmt.Errorf("no config specified")
+	}
+	id, ok := node.Data.Config["flow_id"].(float64)
+	if !ok || id < 1 || id != math.Trunc(id) {
+		return 0, "", fmt.Errorf("flow_id must be a positive integer")
+	}
+	entryNode, _ := node.Data.Config["entry_node"].(string)
+	if entryNode == "" {
+		return 0, "", fmt.Errorf("no entry_node specified")
+	}
+	return int64(id), entryNode, nil
+}
+
+// ReturnHandler handles the Return node type. It ends the sub-flow the call
+// is in and resumes the calling flow on the Sub-Flow node's output edge
+// named by the exit.
+//
+// Node config:
+//   - "exit": name of the exit, e.g. "done" or "failed"
+//
+// Output edges: none (terminal node).
+//
+// A Return node reached in a flow that was not entered through a Sub-Flow
+// node ends the flow.
+type ReturnHandler struct {
+	logger *slog.Logger
+}
+
+// NewReturnHandler creates a new ReturnHandler.
+func NewReturnHandler(logger *slog.Logger) *ReturnHandler {
+	return &ReturnHandler{
+		logger: logger.With("handler", "return"),
+	}
+}
+
+// Execute records the exit and ends the current flow.
+func (h *ReturnHandler) Execute(_ context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
+	exit, _ := node.Data.Config["exit"].(string)
+	if exit == "" {
+		return "", fmt.Errorf("return node %s: no exit specified", node.ID)
+	}
+
+	h.logger.Debug("return node executing",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+		"exit", exit,
+	)
+
+	callCtx.ReturnFromSubFlow(exit)
+	return "", nil
+}
+
+// Ensure the handlers satisfy the NodeHandler and NodeTimeoutProvider interfaces.
+var (
+	_ flow.NodeHandler         = (*SubFlowHandler)(nil)
+	_ flow.NodeTimeoutProvider = (*SubFlowHandler)(nil)
+	_ flow.NodeHandler         = (*ReturnHandler)(nil)
+)
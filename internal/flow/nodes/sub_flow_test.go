@@ -0,0 +1,264 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// mockCallFlowRepo implements database.CallFlowRepository for testing.
+// FlowData holds the published graph of published flows.
+type mockCallFlowRepo struct {
+	flows map[int64]*models.CallFlow
+}
+
+func (m *mockCallFlowRepo) Create(_ context.Context, _ *models.CallFlow) error { return nil }
+func (m *mockCallFlowRepo) List(_ context.Context) ([]models.CallFlow, error)  { return nil, nil }
+func (m *mockCallFlowRepo) Update(_ context.Context, _ *models.CallFlow) error { return nil }
+func (m *mockCallFlowRepo) Delete(_ context.Context, _ int64) error            { return nil }
+func (m *mockCallFlowRepo) Publish(_ context.Context, _ int64, _ string) (*models.CallFlowRevision, error) {
+	return nil, nil
+}
+func (m *mockCallFlowRepo) Rollback(_ context.Context, _ int64, _ int, _ string) (*models.CallFlowRevision, error) {
+	return nil, nil
+}
+func (m *mockCallFlowRepo) ListRevisions(_ context.Context, _ int64) ([]models.CallFlowRevision, error) {
+	return nil, nil
+}
+func (m *mockCallFlowRepo) GetRevision(_ context.Context, _ int64, _ int) (*models.CallFlowRevision, error) {
+	return nil, nil
+}
+
+func (m *mockCallFlowRepo) GetByID(_ context.Context, id int64) (*models.CallFlow, error) {
+	return m.flows[id], nil
+}
+
+func (m *mockCallFlowRepo) GetPublished(_ context.Context, id int64) (*models.CallFlow, error) {
+	if f := m.flows[id]; f != nil && f.Published {
+		return f, nil
+	}
+	return nil, nil
+}
+
+// subFlowRepo holds the flows of the sub-flow tests. Flow 1 is the flow
+// under test, built by subFlowGraph and never published. Flow 2 marks
+// gold callers as VIPs and returns on "done"; flow 3 hangs up; flow 4
+// enters flow 1; flow 5 is unpublished.
+func subFlowRepo() *mockCallFlowRepo {
+	return &mockCallFlowRepo{flows: map[int64]*models.CallFlow{
+		1: {ID: 1, Name: "Main"},
+		2: {ID: 2, Name: "VIP check", Published: true, FlowData: `{
+			"nodes": [
+				{"id": "start", "type": "condition", "data": {"label": "Gold?", "config": {"cases": [{"label": "gold", "expression": "tier == \"gold\""}]}}},
+				{"id": "mark", "type": "set_variable", "data": {"label": "Mark VIP", "config": {"variables": [{"name": "vip", "expression": "\"yes\""}]}}},
+				{"id": "ret", "type": "return", "data": {"label": "Done", "config": {"exit": "done"}}},
+				{"id": "busy", "type": "return", "data": {"label": "Busy", "config": {"exit": "busy"}}}
+			],
+			"edges": [
+				{"id": "e1", "source": "start", "target": "mark", "sourceHandle": "gold"},
+				{"id": "e2", "source": "start", "target": "ret", "sourceHandle": "default"},
+				{"id": "e3", "source": "mark", "target": "ret", "sourceHandle": "next"}
+			]
+		}`},
+		3: {ID: 3, Name: "Goodbye", Published: true, FlowData: `{
+			"nodes": [{"id": "bye", "type": "hangup", "data": {"label": "Bye"}}],
+			"edges": []
+		}`},
+		4: {ID: 4, Name: "Back to main", Published: true, FlowData: `{
+			"nodes": [{"id": "main", "type": "sub_flow", "data": {"label": "Main", "config": {"flow_id": 1, "entry_node": "in"}}}],
+			"edges": []
+		}`},
+		5: {ID: 5, Name: "Draft only", FlowData: `{"nodes": [], "edges": []}`},
+	}}
+}
+
+// subFlowGraph is flow 1: it sets a variable, enters the given flow at
+// the given node and hangs up when the sub-flow returns on "done".
+func subFlowGraph(t *testing.T, flowID int64, entry string) *flow.FlowGraph {
+	t.Helper()
+	graph, err := flow.ParseFlowGraph(fmt.Sprintf(`{
+		"nodes": [
+			{"id": "in", "type": "set_variable", "data": {"label": "Tier", "config": {"variables": [{"name": "tier", "expression": "\"gold\""}]}}},
+			{"id": "sub", "type": "sub_flow", "data": {"label": "Sub", "config": {"flow_id": %d, "entry_node": %q}}},
+			{"id": "bye", "type": "hangup", "data": {"label": "Bye"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "in", "target": "sub", "sourceHandle": "next"},
+			{"id": "e2", "source": "sub", "target": "bye", "sourceHandle": "done"}
+		]
+	}`, flowID, entry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+func TestSimulateSubFlow(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	engine := flow.NewSimulator(subFlowRepo(), nil, logger)
+	RegisterAll(engine, flow.SimulatedSIPActions{}, nil, nil, nil, nil, nil, nil, nil, nil, t.TempDir(), logger)
+
+	tests := []struct {
+		name    string
+		flowID  int64
+		entry   string
+		path    []string
+		outcome flow.SimulationOutcome
+		err     error
+		vip     string
+	}{
+		{
+			name:    "returns on exit",
+			flowID:  2,
+			entry:   "start",
+			path:    []string{"in", "sub", "2/start", "2/mark", "2/ret", "bye"},
+			outcome: flow.OutcomeHangup,
+			vip:     "yes",
+		},
+		{
+			name:    "ends the call",
+			flowID:  3,
+			entry:   "bye",
+			path:    []string{"in", "sub", "3/bye"},
+			outcome: flow.OutcomeHangup,
+		},
+		{
+			name:    "loops back",
+			flowID:  4,
+			entry:   "main",
+			path:    []string{"in", "sub", "4/main"},
+			outcome: flow.OutcomeError,
+			err:     flow.ErrSubFlowLoop,
+		},
+		{
+			name:    "unpublished",
+			flowID:  5,
+			entry:   "in",
+			path:    []string{"in", "sub"},
+			outcome: flow.OutcomeError,
+			err:     flow.ErrFlowNotFound,
+		},
+		{
+			name:    "missing entry node",
+			flowID:  2,
+			entry:   "nope",
+			path:    []string{"in", "sub"},
+			outcome: flow.OutcomeError,
+			err:     flow.ErrEntryNodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := engine.Simulate(context.Background(), subFlowGraph(t, tt.flowID, tt.entry), "in", flow.SimulatedCall{FlowID: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.FlowPath, tt.path) {
+				t.Errorf("path = %v, want %v", res.FlowPath, tt.path)
+			}
+			if res.Outcome != tt.outcome {
+				t.Errorf("outcome = %q, want %q (error %q)", res.Outcome, tt.outcome, res.Error)
+			}
+			if tt.err != nil && !strings.Contains(res.Error, tt.err.Error()) {
+				t.Errorf("error = %q, want %q", res.Error, tt.err)
+			}
+			if res.Variables["vip"] != tt.vip {
+				t.Errorf("vip = %q, want %q", res.Variables["vip"], tt.vip)
+			}
+		})
+	}
+}
+
+func TestValidateSubFlows(t *testing.T) {
+	v := flow.NewValidator(nil, subFlowRepo())
+
+	tests := []struct {
+		name     string
+		flowID   int64
+		entry    string
+		errors   []string
+		warnings []string
+	}{
+		{
+			name:     "valid",
+			flowID:   2,
+			entry:    "start",
+			warnings: []string{`may return on exit "busy"`},
+		},
+		{name: "cycle", flowID: 4, entry: "main", errors: []string{`sub-flows form a cycle: "Main" → "Back to main" → "Main"`}},
+		{name: "unpublished", flowID: 5, entry: "in", errors: []string{`flow "Draft only" is not published`}},
+		{name: "missing", flowID: 9, entry: "in", errors: []string{"flow 9 not found"}},
+		{name: "missing entry node", flowID: 2, entry: "nope", errors: []string{`entry node "nope" not found in flow "VIP check"`}},
+		{name: "self", flowID: 1, entry: "in", errors: []string{"sub-flow enters its own flow"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := v.Validate(context.Background(), 1, subFlowGraph(t, tt.flowID, tt.entry), "in")
+
+			var errs, warnings []string
+			for _, issue := range result.Issues {
+				if issue.NodeID != "sub" {
+					continue
+				}
+				if issue.Severity == flow.SeverityError {
+					errs = append(errs, issue.Message)
+				} else {
+					warnings = append(warnings, issue.Message)
+				}
+			}
+			if result.Valid != (len(tt.errors) == 0) {
+				t.Errorf("Valid = %v, issues %v", result.Valid, result.Issues)
+			}
+			check := func(kind string, got, want []string) {
+				if len(got) != len(want) {
+					t.Errorf("%s = %q, want %q", kind, got, want)
+					return
+				}
+				for i := range want {
+					if !strings.Contains(got[i], want[i]) {
+						t.Errorf("%s[%d] = %q, want it to contain %q", kind, i, got[i], want[i])
+					}
+				}
+			}
+			check("errors", errs, tt.errors)
+			check("warnings", warnings, tt.warnings)
+		})
+	}
+}
+
+func TestSubFlowDepthLimit(t *testing.T) {
+	// Flows 1..20 each enter the next, so the call nests until the
+	// depth limit stops it.
+	flows := &mockCallFlowRepo{flows: map[int64]*models.CallFlow{}}
+	for id := int64(1); id <= 20; id++ {
+		flows.flows[id] = &models.CallFlow{ID: id, Name: fmt.Sprintf("Flow %d", id), Published: true, FlowData: fmt.Sprintf(`{
+			"nodes": [{"id": "next", "type": "sub_flow", "data": {"label": "Next", "config": {"flow_id": %d, "entry_node": "next"}}}],
+			"edges": []
+		}`, id+1)}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	engine := flow.NewSimulator(flows, nil, logger)
+	engine.RegisterHandler("sub_flow", NewSubFlowHandler(engine, logger))
+
+	graph, err := flow.ParseFlowGraph(flows.flows[1].FlowData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := engine.Simulate(context.Background(), graph, "next", flow.SimulatedCall{FlowID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Error, flow.ErrSubFlowDepth.Error()) {
+		t.Errorf("error = %q, want %q", res.Error, flow.ErrSubFlowDepth)
+	}
+	if len(res.FlowPath) != 9 {
+		t.Errorf("path = %v, want 9 nodes", res.FlowPath)
+	}
+}
@@ -10,15 +10,21 @@ import (
 
 	"github.com/flowpbx/flowpbx/internal/database/models"
 	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/schedule"
 )
 
 // timeRule represents a single time-based routing rule parsed from the
-// time switch entity's Rules JSON field.
+// time switch entity's Rules JSON field. A rule with an RRule recurs on
+// the days its iCalendar recurrence rule selects, counted from DTStart,
+// instead of on Days; each occurrence runs from Start to End, ending the
+// next day if End is before Start.
 type timeRule struct {
-	Label string   `json:"label"`
-	Days  []string `json:"days"`  // e.g. ["mon","tue","wed","thu","fri"]
-	Start string   `json:"start"` // "HH:MM" format
-	End   string   `json:"end"`   // "HH:MM" format
+	Label   string   `json:"label"`
+	Days    []string `json:"days"`              // e.g. ["mon","tue","wed","thu","fri"]
+	Start   string   `json:"start"`             // "HH:MM" format
+	End     string   `json:"end"`               // "HH:MM" format
+	RRule   string   `json:"rrule,omitempty"`   // e.g. "FREQ=MONTHLY;BYDAY=1MO"
+	DTStart string   `json:"dtstart,omitempty"` // "YYYY-MM-DD" format, required with RRule
 }
 
 // timeOverride represents a holiday or specific-date override that takes
@@ -31,22 +37,51 @@ type timeOverride struct {
 	End   string `json:"end"`   // "HH:MM" format, optional — empty means all day
 }
 
+// Time switch match kinds, reported in TimeSwitchResult.Match.
+const (
+	TimeSwitchMatchOverride = "override"
+	TimeSwitchMatchCalendar = "calendar"
+	TimeSwitchMatchRule     = "rule"
+	TimeSwitchMatchDefault  = "default"
+)
+
+// TimeSwitchCalendars provides the calendars imported into time switches
+// as override sets. Implemented by schedule.Calendars.
+type TimeSwitchCalendars interface {
+	OverrideSets(ctx context.Context, timeSwitchID int64) ([]schedule.OverrideSet, error)
+}
+
+// TimeSwitchResult is the outcome of evaluating a time switch at a given
+// moment: the edge followed and what selected it.
+type TimeSwitchResult struct {
+	Edge      string
+	Match     string    // one of the TimeSwitchMatch kinds
+	Label     string    // label of the matched override, calendar or rule
+	Date      string    // date of the matched override
+	Calendar  string    // name of the matched calendar
+	Event     string    // summary of the matched calendar event
+	LocalTime time.Time // the moment in the time switch's timezone
+}
+
 // TimeSwitchHandler handles the Time Switch node type. It evaluates time-based
 // rules against the current time in the configured timezone and follows the
 // matching rule's output edge, or the "default" edge if no rule matches.
 type TimeSwitchHandler struct {
-	engine *flow.Engine
-	logger *slog.Logger
+	engine    *flow.Engine
+	calendars TimeSwitchCalendars
+	logger    *slog.Logger
 	// nowFunc allows overriding the current time for testing.
 	nowFunc func() time.Time
 }
 
-// NewTimeSwitchHandler creates a new TimeSwitchHandler.
-func NewTimeSwitchHandler(engine *flow.Engine, logger *slog.Logger) *TimeSwitchHandler {
+// NewTimeSwitchHandler creates a new TimeSwitchHandler. calendars may be
+// nil if time switches have no imported calendars.
+func NewTimeSwitchHandler(engine *flow.Engine, calendars TimeSwitchCalendars, logger *slog.Logger) *TimeSwitchHandler {
 	return &TimeSwitchHandler{
-		engine:  engine,
-		logger:  logger.With("handler", "time_switch"),
-		nowFunc: time.Now,
+		engine:    engine,
+		calendars: calendars,
+		logger:    logger.With("handler", "time_switch"),
+		nowFunc:   time.Now,
 	}
 }
 
@@ -73,17 +108,83 @@ func (h *TimeSwitchHandler) Execute(ctx context.Context, callCtx *flow.CallConte
 		return "", fmt.Errorf("time switch node %s: entity is %T, expected *models.TimeSwitch", node.ID, entity)
 	}
 
+	// Load imported calendars. A calendar that cannot be loaded must not
+	// stop the call, so the switch is evaluated without them.
+	var sets []schedule.OverrideSet
+	if h.calendars != nil {
+		sets, err = h.calendars.OverrideSets(ctx, ts.ID)
+		if err != nil {
+			h.logger.Error("time switch calendars unavailable, evaluating without them",
+				"call_id", callCtx.CallID,
+				"node_id", node.ID,
+				"error", err,
+			)
+			sets = nil
+		}
+	}
+
+	now := h.nowFunc()
+	if sim := callCtx.Simulation(); sim != nil {
+		now = sim.Time
+	}
+
+	res, err := EvaluateTimeSwitch(ts, sets, now)
+	if err != nil {
+		return "", fmt.Errorf("time switch node %s: %w", node.ID, err)
+	}
+
+	switch res.Match {
+	case TimeSwitchMatchOverride:
+		h.logger.Info("time switch override matched",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"override_label", res.Label,
+			"override_date", res.Date,
+			"local_time", res.LocalTime.Format("Mon 2006-01-02 15:04"),
+		)
+	case TimeSwitchMatchCalendar:
+		h.logger.Info("time switch calendar event matched",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"calendar", res.Calendar,
+			"event", res.Event,
+			"label", res.Label,
+			"local_time", res.LocalTime.Format("Mon 2006-01-02 15:04"),
+		)
+	case TimeSwitchMatchRule:
+		h.logger.Info("time switch rule matched",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"rule_label", res.Label,
+			"local_time", res.LocalTime.Format("Mon 15:04"),
+		)
+	default:
+		h.logger.Info("time switch no rule matched, using default",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"local_time", res.LocalTime.Format("Mon 15:04"),
+		)
+	}
+
+	return res.Edge, nil
+}
+
+// EvaluateTimeSwitch returns the edge a time switch takes at now. Date
+// overrides are checked first, then the events of the imported calendars
+// in sets, then the rules top-to-bottom; the first match wins and
+// "default" is taken if nothing matches.
+func EvaluateTimeSwitch(ts *models.TimeSwitch, sets []schedule.OverrideSet, now time.Time) (TimeSwitchResult, error) {
 	// Parse the rules JSON.
 	var rules []timeRule
 	if err := json.Unmarshal([]byte(ts.Rules), &rules); err != nil {
-		return "", fmt.Errorf("time switch node %s: parsing rules: %w", node.ID, err)
+		return TimeSwitchResult{}, fmt.Errorf("parsing rules: %w", err)
 	}
 
 	// Parse the overrides JSON (may be empty or "[]").
 	var overrides []timeOverride
 	if ts.Overrides != "" && ts.Overrides != "[]" {
 		if err := json.Unmarshal([]byte(ts.Overrides), &overrides); err != nil {
-			return "", fmt.Errorf("time switch node %s: parsing overrides: %w", node.ID, err)
+			return TimeSwitchResult{}, fmt.Errorf("parsing overrides: %w", err)
 		}
 	}
 
@@ -94,56 +195,57 @@ func (h *TimeSwitchHandler) Execute(ctx context.Context, callCtx *flow.CallConte
 	}
 	loc, err := time.LoadLocation(tz)
 	if err != nil {
-		return "", fmt.Errorf("time switch node %s: loading timezone %q: %w", node.ID, tz, err)
+		return TimeSwitchResult{}, fmt.Errorf("loading timezone %q: %w", tz, err)
 	}
 
-	// Get current time in the configured timezone.
-	now := h.nowFunc().In(loc)
-
-	h.logger.Debug("evaluating time switch rules",
-		"call_id", callCtx.CallID,
-		"node_id", node.ID,
-		"timezone", tz,
-		"local_time", now.Format("Mon 2006-01-02 15:04"),
-		"rule_count", len(rules),
-		"override_count", len(overrides),
-	)
+	// Evaluate in the configured timezone.
+	now = now.In(loc)
 
 	// Check overrides first — specific date overrides take priority.
 	for _, ov := range overrides {
 		if matchesOverride(now, ov) {
-			h.logger.Info("time switch override matched",
-				"call_id", callCtx.CallID,
-				"node_id", node.ID,
-				"override_label", ov.Label,
-				"override_date", ov.Date,
-				"local_time", now.Format("Mon 2006-01-02 15:04"),
-			)
-			return ov.Label, nil
+			return TimeSwitchResult{
+				Edge:      ov.Label,
+				Match:     TimeSwitchMatchOverride,
+				Label:     ov.Label,
+				Date:      ov.Date,
+				LocalTime: now,
+			}, nil
+		}
+	}
+
+	// Then imported calendars, in the order they were added.
+	for i := range sets {
+		if ev, ok := sets[i].Covering(now); ok {
+			return TimeSwitchResult{
+				Edge:      sets[i].Label,
+				Match:     TimeSwitchMatchCalendar,
+				Label:     sets[i].Label,
+				Calendar:  sets[i].Name,
+				Event:     ev.Summary,
+				LocalTime: now,
+			}, nil
 		}
 	}
 
 	// Evaluate rules top-to-bottom; first match wins.
 	for _, rule := range rules {
 		if matchesRule(now, rule) {
-			h.logger.Info("time switch rule matched",
-				"call_id", callCtx.CallID,
-				"node_id", node.ID,
-				"rule_label", rule.Label,
-				"local_time", now.Format("Mon 15:04"),
-			)
-			return rule.Label, nil
+			return TimeSwitchResult{
+				Edge:      rule.Label,
+				Match:     TimeSwitchMatchRule,
+				Label:     rule.Label,
+				LocalTime: now,
+			}, nil
 		}
 	}
 
 	// No rule matched — follow the default edge.
-	h.logger.Info("time switch no rule matched, using default",
-		"call_id", callCtx.CallID,
-		"node_id", node.ID,
-		"local_time", now.Format("Mon 15:04"),
-	)
-
-	return "default", nil
+	return TimeSwitchResult{
+		Edge:      "default",
+		Match:     TimeSwitchMatchDefault,
+		LocalTime: now,
+	}, nil
 }
 
 // matchesOverride checks whether the given time matches a date override.
@@ -188,6 +290,10 @@ func matchesOverride(now time.Time, ov timeOverride) bool {
 // and the current time is within the start–end range (inclusive of start,
 // exclusive of end).
 func matchesRule(now time.Time, rule timeRule) bool {
+	if rule.RRule != "" {
+		return matchesRecurrence(now, rule)
+	}
+
 	// Check day of week.
 	currentDay := strings.ToLower(now.Weekday().String()[:3])
 	dayMatch := false
@@ -224,6 +330,38 @@ func matchesRule(now time.Time, rule timeRule) bool {
 	return nowMinutes >= startMinutes && nowMinutes < endMinutes
 }
 
+// matchesRecurrence checks whether the given time falls within an
+// occurrence of a rule with a recurrence rule. An occurrence starts at
+// Start on each day the recurrence selects and lasts until End, on the
+// next day for an overnight range.
+func matchesRecurrence(now time.Time, rule timeRule) bool {
+	rr, err := schedule.ParseRRule(rule.RRule)
+	if err != nil {
+		return false
+	}
+	startH, startM, ok := parseHHMM(rule.Start)
+	if !ok {
+		return false
+	}
+	endH, endM, ok := parseHHMM(rule.End)
+	if !ok {
+		return false
+	}
+	day, err := time.ParseInLocation("2006-01-02", rule.DTStart, now.Location())
+	if err != nil {
+		return false
+	}
+
+	dtstart := time.Date(day.Year(), day.Month(), day.Day(), startH, startM, 0, 0, now.Location())
+	minutes := (endH*60 + endM) - (startH*60 + startM)
+	if minutes < 0 {
+		minutes += 24 * 60
+	}
+
+	_, ok = rr.Covering(dtstart, time.Duration(minutes)*time.Minute, now)
+	return ok
+}
+
 // parseHHMM parses a "HH:MM" time string into hours and minutes.
 func parseHHMM(s string) (int, int, bool) {
 	var h, m int
@@ -2,6 +2,7 @@ package nodes
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/flowpbx/flowpbx/internal/database/models"
 	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/schedule"
 )
 
 // mockEntityResolver returns a fixed entity for any resolve call.
@@ -27,7 +29,7 @@ func newTestTimeSwitchHandler(ts *models.TimeSwitch, nowFunc func() time.Time) *
 	resolver := &mockEntityResolver{entity: ts}
 	engine := flow.NewEngine(nil, nil, resolver, logger)
 
-	h := NewTimeSwitchHandler(engine, logger)
+	h := NewTimeSwitchHandler(engine, nil, logger)
 	if nowFunc != nil {
 		h.nowFunc = nowFunc
 	}
@@ -379,3 +381,107 @@ func TestParseHHMM(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchesRuleRecurrence(t *testing.T) {
+	sydLoc, _ := time.LoadLocation("Australia/Sydney")
+
+	// First Monday of each month, 09:00–12:00, from January 2025.
+	monthly := timeRule{RRule: "FREQ=MONTHLY;BYDAY=1MO", DTStart: "2025-01-01", Start: "09:00", End: "12:00"}
+	// Every Friday night, 22:00–06:00.
+	overnight := timeRule{RRule: "FREQ=WEEKLY;BYDAY=FR", DTStart: "2025-01-01", Start: "22:00", End: "06:00"}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		rule     timeRule
+		expected bool
+	}{
+		{"first monday in range", time.Date(2025, 6, 2, 10, 0, 0, 0, sydLoc), monthly, true},
+		{"second monday", time.Date(2025, 6, 9, 10, 0, 0, 0, sydLoc), monthly, false},
+		{"first monday after end", time.Date(2025, 6, 2, 12, 0, 0, 0, sydLoc), monthly, false},
+		{"days ignored with rrule", time.Date(2025, 6, 2, 10, 0, 0, 0, sydLoc), timeRule{Days: []string{"tue"}, RRule: monthly.RRule, DTStart: monthly.DTStart, Start: "09:00", End: "12:00"}, true},
+		{"overnight into saturday", time.Date(2025, 3, 15, 3, 0, 0, 0, sydLoc), overnight, true},
+		{"friday early morning", time.Date(2025, 3, 14, 3, 0, 0, 0, sydLoc), overnight, false},
+		{"before dtstart", time.Date(2024, 12, 2, 10, 0, 0, 0, sydLoc), monthly, false},
+		{"missing dtstart", time.Date(2025, 6, 2, 10, 0, 0, 0, sydLoc), timeRule{RRule: monthly.RRule, Start: "09:00", End: "12:00"}, false},
+		{"invalid rrule", time.Date(2025, 6, 2, 10, 0, 0, 0, sydLoc), timeRule{RRule: "FREQ=SOMETIMES", DTStart: "2025-01-01", Start: "09:00", End: "12:00"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesRule(tt.now, tt.rule); got != tt.expected {
+				t.Errorf("matchesRule() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// mockTimeSwitchCalendars returns fixed override sets for any time switch.
+type mockTimeSwitchCalendars struct {
+	sets []schedule.OverrideSet
+	err  error
+}
+
+func (m *mockTimeSwitchCalendars) OverrideSets(_ context.Context, _ int64) ([]schedule.OverrideSet, error) {
+	return m.sets, m.err
+}
+
+func TestTimeSwitchCalendarOverride(t *testing.T) {
+	ts := &models.TimeSwitch{
+		ID:        1,
+		Name:      "Business Hours",
+		Timezone:  "Australia/Sydney",
+		Rules:     `[{"label":"Business Hours","days":["mon","tue","wed","thu","fri"],"start":"08:30","end":"17:00"}]`,
+		Overrides: `[{"label":"Stocktake","date":"2025-12-26","start":"09:00","end":"12:00"}]`,
+	}
+
+	cal, err := schedule.ParseICS([]byte("BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20251225\r\nSUMMARY:Christmas Day\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20251226\r\nSUMMARY:Boxing Day\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatalf("ParseICS() error: %v", err)
+	}
+	sets := []schedule.OverrideSet{{CalendarID: 1, Name: "Public Holidays", Label: "Holiday", Events: cal.Events}}
+
+	loc, _ := time.LoadLocation("Australia/Sydney")
+	tests := []struct {
+		name  string
+		now   time.Time
+		edge  string
+		match string
+	}{
+		{"calendar event beats rules", time.Date(2025, 12, 25, 10, 0, 0, 0, loc), "Holiday", TimeSwitchMatchCalendar},
+		{"override beats calendar", time.Date(2025, 12, 26, 10, 0, 0, 0, loc), "Stocktake", TimeSwitchMatchOverride},
+		{"calendar outside override hours", time.Date(2025, 12, 26, 14, 0, 0, 0, loc), "Holiday", TimeSwitchMatchCalendar},
+		{"rules on other days", time.Date(2025, 12, 29, 10, 0, 0, 0, loc), "Business Hours", TimeSwitchMatchRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := EvaluateTimeSwitch(ts, sets, tt.now)
+			if err != nil {
+				t.Fatalf("EvaluateTimeSwitch() error: %v", err)
+			}
+			if res.Edge != tt.edge || res.Match != tt.match {
+				t.Errorf("EvaluateTimeSwitch() = %q (%s), want %q (%s)", res.Edge, res.Match, tt.edge, tt.match)
+			}
+		})
+	}
+
+	// The node uses the calendars of the switch, and evaluates without
+	// them if they cannot be loaded.
+	now := func() time.Time { return time.Date(2025, 12, 25, 10, 0, 0, 0, loc) }
+	h := newTestTimeSwitchHandler(ts, now)
+	h.calendars = &mockTimeSwitchCalendars{sets: sets}
+	edge, err := h.Execute(context.Background(), &flow.CallContext{CallID: "test-call-cal"}, makeNode(1))
+	if err != nil || edge != "Holiday" {
+		t.Errorf("Execute() = %q, %v, want %q", edge, err, "Holiday")
+	}
+
+	h.calendars = &mockTimeSwitchCalendars{err: errors.New("disk error")}
+	edge, err = h.Execute(context.Background(), &flow.CallContext{CallID: "test-call-cal"}, makeNode(1))
+	if err != nil || edge != "Business Hours" {
+		t.Errorf("Execute() with failing calendars = %q, %v, want %q", edge, err, "Business Hours")
+	}
+}
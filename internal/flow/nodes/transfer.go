@@ -25,8 +25,9 @@ func NewTransferHandler(sip flow.SIPActions, logger *slog.Logger) *TransferHandl
 }
 
 // Execute performs a blind transfer to the destination specified in the node
-// config's "destination" field. Returns an empty output edge to indicate
-// this is a terminal node.
+// config's "destination" field, which may interpolate call fields and flow
+// variables as a template, e.g. "{{.Variables.account_manager}}". Returns
+// an empty output edge to indicate this is a terminal node.
 func (h *TransferHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
 	h.logger.Debug("transfer node executing",
 		"call_id", callCtx.CallID,
@@ -46,6 +47,13 @@ func (h *TransferHandler) Execute(ctx context.Context, callCtx *flow.CallContext
 	if !ok || dest == "" {
 		return "", fmt.Errorf("transfer node %s: destination must be a non-empty string", node.ID)
 	}
+	dest, err := flow.Interpolate(callCtx, "destination", dest)
+	if err != nil {
+		return "", fmt.Errorf("transfer node %s: %w", node.ID, err)
+	}
+	if dest == "" {
+		return "", fmt.Errorf("transfer node %s: destination is empty after interpolation", node.ID)
+	}
 
 	h.logger.Info("transferring call",
 		"call_id", callCtx.CallID,
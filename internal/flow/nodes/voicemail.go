@@ -13,12 +13,18 @@ import (
 	"github.com/flowpbx/flowpbx/internal/email"
 	"github.com/flowpbx/flowpbx/internal/flow"
 	"github.com/flowpbx/flowpbx/internal/prompts"
+	"github.com/flowpbx/flowpbx/internal/tts"
 )
 
 // defaultMaxMessageDuration is the default maximum voicemail recording length
 // in seconds, used when the voicemail box has no override configured.
 const defaultMaxMessageDuration = 120
 
+// voicemailNodeTimeout bounds how long a voicemail node can run. It leaves
+// room for the greeting and a long message; the recording itself stops at
+// the box's max message duration.
+const voicemailNodeTimeout = 15 * time.Minute
+
 // defaultGreetingFile is the path to the built-in greeting played when a
 // voicemail box has no custom greeting configured.
 const defaultGreetingFile = "prompts/system/default_voicemail_greeting.wav"
@@ -28,7 +34,8 @@ const defaultGreetingFile = "prompts/system/default_voicemail_greeting.wav"
 // stores the message metadata, and triggers MWI notification to the linked
 // extension if configured. When the voicemail box has email notification
 // enabled and SMTP is configured, it sends an email with optional WAV
-// attachment.
+// attachment. A box with the "name_only" greeting type announces its name
+// with text-to-speech when a TTS engine is configured.
 type VoicemailHandler struct {
 	engine     *flow.Engine
 	sip        flow.SIPActions
@@ -37,6 +44,7 @@ type VoicemailHandler struct {
 	sysConfig  database.SystemConfigRepository
 	enc        *database.Encryptor
 	emailSend  *email.Sender
+	speech     *tts.Cache
 	logger     *slog.Logger
 	dataDir    string
 	nowFunc    func() time.Time // injectable for testing
@@ -51,6 +59,7 @@ func NewVoicemailHandler(
 	sysConfig database.SystemConfigRepository,
 	enc *database.Encryptor,
 	emailSend *email.Sender,
+	speech *tts.Cache,
 	logger *slog.Logger,
 	dataDir string,
 ) *VoicemailHandler {
@@ -62,12 +71,19 @@ func NewVoicemailHandler(
 		sysConfig:  sysConfig,
 		enc:        enc,
 		emailSend:  emailSend,
+		speech:     speech,
 		logger:     logger.With("handler", "voicemail"),
 		dataDir:    dataDir,
 		nowFunc:    time.Now,
 	}
 }
 
+// NodeTimeout allows voicemail nodes to run longer than the engine's default
+// so the greeting and a full-length message fit.
+func (h *VoicemailHandler) NodeTimeout(_ flow.Node) time.Duration {
+	return voicemailNodeTimeout
+}
+
 // Execute resolves the voicemail box entity, plays its greeting, records the
 // caller's message, stores the message metadata, and sends MWI if a linked
 // extension is configured. Voicemail is a terminal node — it returns "next"
@@ -110,7 +126,7 @@ func (h *VoicemailHandler) Execute(ctx context.Context, callCtx *flow.CallContex
 	}
 
 	// Determine the greeting to play.
-	greeting := h.resolveGreeting(box)
+	greeting := h.greeting(ctx, callCtx, box)
 
 	// Determine max recording duration.
 	maxDuration := box.MaxMessageDuration
@@ -118,6 +134,14 @@ func (h *VoicemailHandler) Execute(ctx context.Context, callCtx *flow.CallContex
 		maxDuration = defaultMaxMessageDuration
 	}
 
+	// A simulated caller hears the greeting but nothing is recorded or stored.
+	if callCtx.Simulation() != nil {
+		if _, err := h.sip.RecordMessage(ctx, callCtx, greeting, maxDuration, ""); err != nil {
+			return "", fmt.Errorf("recording voicemail: %w", err)
+		}
+		return "next", nil
+	}
+
 	// Build the recording file path: voicemail/box_<id>/msg_<timestamp>.wav
 	now := h.nowFunc()
 	recordingDir := filepath.Join(h.dataDir, "voicemail", fmt.Sprintf("box_%d", box.ID))
@@ -165,12 +189,11 @@ func (h *VoicemailHandler) Execute(ctx context.Context, callCtx *flow.CallContex
 			"message_id", msg.ID,
 			"duration", result.DurationSecs,
 		)
+		h.sip.VoicemailReceived(ctx, box, msg)
 	}
 
 	// Send MWI notification to the linked extension, if configured.
-	if box.NotifyExtensionID != nil {
-		h.sendMWI(ctx, box, *box.NotifyExtensionID)
-	}
+	sendMailboxMWI(ctx, h.sip, h.extensions, h.messages, box, h.logger)
 
 	// Send email notification if enabled for this box.
 	if box.EmailNotify && box.EmailAddress != "" {
@@ -180,6 +203,25 @@ func (h *VoicemailHandler) Execute(ctx context.Context, callCtx *flow.CallContex
 	return "next", nil
 }
 
+// greeting returns the greeting file to play for the voicemail box. A
+// "name_only" box is announced by name with text-to-speech if possible;
+// otherwise the greeting comes from resolveGreeting.
+func (h *VoicemailHandler) greeting(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox) string {
+	if box.GreetingType == "name_only" && h.speech != nil && box.Name != "" {
+		text := fmt.Sprintf("You have reached %s. Please leave a message after the tone.", box.Name)
+		path, err := h.speech.Render(ctx, text)
+		if err == nil {
+			return path
+		}
+		h.logger.Warn("failed to speak voicemail greeting, falling back",
+			"call_id", callCtx.CallID,
+			"mailbox_id", box.ID,
+			"error", err,
+		)
+	}
+	return h.resolveGreeting(box)
+}
+
 // resolveGreeting returns the greeting file path for the voicemail box.
 // When the greeting type is "custom", the handler checks for a greeting file
 // at the standard path $DATA_DIR/greetings/box_{id}.wav. If that file exists,
@@ -198,12 +240,25 @@ func (h *VoicemailHandler) resolveGreeting(box *models.VoicemailBox) string {
 	return filepath.Join(h.dataDir, defaultGreetingFile)
 }
 
-// sendMWI looks up the linked extension and sends a SIP NOTIFY to update
-// the message waiting indicator. Errors are logged but do not fail the node.
-func (h *VoicemailHandler) sendMWI(ctx context.Context, box *models.VoicemailBox, extensionID int64) {
-	ext, err := h.extensions.GetByID(ctx, extensionID)
+// sendMailboxMWI looks up the extension linked to a voicemail box and sends
+// a SIP NOTIFY to update its message waiting indicator. Boxes without a
+// linked extension are skipped. Errors are logged but do not fail the node.
+func sendMailboxMWI(
+	ctx context.Context,
+	sip flow.SIPActions,
+	extensions database.ExtensionRepository,
+	messages database.VoicemailMessageRepository,
+	box *models.VoicemailBox,
+	logger *slog.Logger,
+) {
+	if box.NotifyExtensionID == nil {
+		return
+	}
+	extensionID := *box.NotifyExtensionID
+
+	ext, err := extensions.GetByID(ctx, extensionID)
 	if err != nil {
-		h.logger.Error("failed to resolve MWI extension",
+		logger.Error("failed to resolve MWI extension",
 			"mailbox_id", box.ID,
 			"extension_id", extensionID,
 			"error", err,
@@ -211,7 +266,7 @@ func (h *VoicemailHandler) sendMWI(ctx context.Context, box *models.VoicemailBox
 		return
 	}
 	if ext == nil {
-		h.logger.Warn("MWI extension not found",
+		logger.Warn("MWI extension not found",
 			"mailbox_id", box.ID,
 			"extension_id", extensionID,
 		)
@@ -219,9 +274,9 @@ func (h *VoicemailHandler) sendMWI(ctx context.Context, box *models.VoicemailBox
 	}
 
 	// Count messages in the mailbox for the MWI summary.
-	messages, err := h.messages.ListByMailbox(ctx, box.ID)
+	msgs, err := messages.ListByMailbox(ctx, box.ID)
 	if err != nil {
-		h.logger.Error("failed to count voicemail messages for MWI",
+		logger.Error("failed to count voicemail messages for MWI",
 			"mailbox_id", box.ID,
 			"error", err,
 		)
@@ -230,7 +285,7 @@ func (h *VoicemailHandler) sendMWI(ctx context.Context, box *models.VoicemailBox
 
 	newCount := 0
 	oldCount := 0
-	for _, m := range messages {
+	for _, m := range msgs {
 		if m.Read {
 			oldCount++
 		} else {
@@ -238,8 +293,8 @@ func (h *VoicemailHandler) sendMWI(ctx context.Context, box *models.VoicemailBox
 		}
 	}
 
-	if err := h.sip.SendMWI(ctx, ext, newCount, oldCount); err != nil {
-		h.logger.Error("failed to send MWI notification",
+	if err := sip.SendMWI(ctx, ext, newCount, oldCount); err != nil {
+		logger.Error("failed to send MWI notification",
 			"mailbox_id", box.ID,
 			"extension", ext.Extension,
 			"error", err,
@@ -247,7 +302,7 @@ func (h *VoicemailHandler) sendMWI(ctx context.Context, box *models.VoicemailBox
 		return
 	}
 
-	h.logger.Info("MWI notification sent",
+	logger.Info("MWI notification sent",
 		"mailbox_id", box.ID,
 		"extension", ext.Extension,
 		"new_messages", newCount,
@@ -338,5 +393,8 @@ func (h *VoicemailHandler) loadSMTPConfig(ctx context.Context) (email.SMTPConfig
 	return cfg, nil
 }
 
-// Ensure VoicemailHandler satisfies the NodeHandler interface.
-var _ flow.NodeHandler = (*VoicemailHandler)(nil)
+// Ensure VoicemailHandler satisfies the NodeHandler and NodeTimeoutProvider interfaces.
+var (
+	_ flow.NodeHandler         = (*VoicemailHandler)(nil)
+	_ flow.NodeTimeoutProvider = (*VoicemailHandler)(nil)
+)
@@ -0,0 +1,629 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/prompts"
+)
+
+const (
+	// voicemailRetrievalNodeTimeout bounds how long a caller can stay in
+	// their mailbox.
+	voicemailRetrievalNodeTimeout = time.Hour
+
+	// vmLoginAttempts is how many times a caller may enter a mailbox
+	// number and PIN before being disconnected.
+	vmLoginAttempts = 3
+
+	// vmMenuAttempts is how many times a menu is repeated when the caller
+	// presses nothing.
+	vmMenuAttempts = 3
+
+	// vmMaxDigits limits mailbox number and PIN entry. Entry ends early
+	// with '#'.
+	vmMaxDigits = 10
+
+	// vmFirstDigitTimeout and vmInterDigitTimeout are the digit collection
+	// timeouts in seconds.
+	vmFirstDigitTimeout = 10
+	vmInterDigitTimeout = 5
+
+	// vmGreetingMaxDuration is the longest greeting a caller can record,
+	// in seconds.
+	vmGreetingMaxDuration = 60
+
+	// vmNameMaxDuration is the longest recorded name, in seconds.
+	vmNameMaxDuration = 5
+)
+
+// VoicemailRetrievalHandler handles the Voicemail Retrieval node type, also
+// run directly by the voicemail feature codes. It answers the call, logs
+// the caller into a mailbox with its PIN and runs the mailbox menu:
+//
+//	main menu:    1 listen to messages, 2 record name, 0 record greeting, * exit
+//	message menu: 5 replay, 6 next, 7 delete, 9 save, 3 call back, * main menu
+//
+// New messages are played before saved ones. A message is marked read once
+// it has been heard in full or saved, and MWI is updated after every
+// change. The recorded name is announced by the dial-by-name directory.
+//
+// The mailbox is the node's voicemail box entity if set. With config
+// "mailbox": "caller" it is the box linked to the calling extension;
+// otherwise the caller is asked for the mailbox number. A box without a PIN
+// can only be opened from its linked extension.
+//
+// Output edges: "next" when the caller leaves the mailbox, "failed" when
+// login fails. Hanging up or calling a message's sender back ends the flow.
+type VoicemailRetrievalHandler struct {
+	engine     *flow.Engine
+	sip        flow.SIPActions
+	boxes      database.VoicemailBoxRepository
+	messages   database.VoicemailMessageRepository
+	extensions database.ExtensionRepository
+	dataDir    string
+	logger     *slog.Logger
+}
+
+// NewVoicemailRetrievalHandler creates a new VoicemailRetrievalHandler.
+func NewVoicemailRetrievalHandler(
+	engine *flow.Engine,
+	sip flow.SIPActions,
+	boxes database.VoicemailBoxRepository,
+	messages database.VoicemailMessageRepository,
+	extensions database.ExtensionRepository,
+	dataDir string,
+	logger *slog.Logger,
+) *VoicemailRetrievalHandler {
+	return &VoicemailRetrievalHandler{
+		engine:     engine,
+		sip:        sip,
+		boxes:      boxes,
+		messages:   messages,
+		extensions: extensions,
+		dataDir:    dataDir,
+		logger:     logger.With("handler", "voicemail_retrieval"),
+	}
+}
+
+// NodeTimeout allows callers to spend longer in their mailbox than the
+// engine's default node timeout.
+func (h *VoicemailRetrievalHandler) NodeTimeout(_ flow.Node) time.Duration {
+	return voicemailRetrievalNodeTimeout
+}
+
+// Execute answers the call, logs in to the mailbox and runs the mailbox
+// menu until the caller exits, hangs up or is transferred.
+func (h *VoicemailRetrievalHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
+	h.logger.Debug("voicemail retrieval node executing",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+	)
+
+	edge, err := h.run(ctx, callCtx, node)
+	if errors.Is(err, flow.ErrCallerHungUp) {
+		h.logger.Info("caller hung up in voicemail retrieval",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+		)
+		return "", nil
+	}
+	return edge, err
+}
+
+// run is Execute without the hangup handling.
+func (h *VoicemailRetrievalHandler) run(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
+	if err := h.sip.AnswerCall(ctx, callCtx); err != nil {
+		return "", fmt.Errorf("answering call: %w", err)
+	}
+
+	box, err := h.login(ctx, callCtx, node)
+	if err != nil {
+		return "", err
+	}
+	if box == nil {
+		h.logger.Info("voicemail login failed",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+		)
+		if err := h.play(ctx, callCtx, "vm_goodbye"); err != nil {
+			return "", err
+		}
+		return "failed", nil
+	}
+
+	h.logger.Info("voicemail login",
+		"call_id", callCtx.CallID,
+		"mailbox", box.MailboxNumber,
+		"mailbox_id", box.ID,
+	)
+
+	if err := h.announceCounts(ctx, callCtx, box); err != nil {
+		return "", err
+	}
+
+	for timeouts := 0; timeouts < vmMenuAttempts; {
+		digit, err := h.collect(ctx, callCtx, "vm_main_menu", 1)
+		if err != nil {
+			return "", err
+		}
+
+		switch digit {
+		case "":
+			timeouts++
+			continue
+		case "1":
+			calledBack, err := h.listen(ctx, callCtx, box)
+			if err != nil {
+				return "", err
+			}
+			if calledBack {
+				return "", nil
+			}
+		case "2":
+			if err := h.recordName(ctx, callCtx, box); err != nil {
+				return "", err
+			}
+		case "0":
+			if err := h.recordGreeting(ctx, callCtx, box); err != nil {
+				return "", err
+			}
+		case "*":
+			return "next", h.play(ctx, callCtx, "vm_goodbye")
+		default:
+			if err := h.play(ctx, callCtx, "ivr_invalid_option"); err != nil {
+				return "", err
+			}
+		}
+		timeouts = 0
+	}
+
+	return "next", h.play(ctx, callCtx, "vm_goodbye")
+}
+
+// login identifies the caller's mailbox and checks its PIN. It returns nil
+// if the caller fails every attempt. When the caller types the mailbox
+// number, the PIN is asked for even if no such mailbox exists so valid
+// numbers cannot be discovered.
+func (h *VoicemailRetrievalHandler) login(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (*models.VoicemailBox, error) {
+	fixed, err := h.fixedMailbox(ctx, callCtx, node)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < vmLoginAttempts; attempt++ {
+		box := fixed
+		if box == nil {
+			number, err := h.collect(ctx, callCtx, "vm_enter_mailbox", vmMaxDigits)
+			if err != nil {
+				return nil, err
+			}
+			if number == "" {
+				continue
+			}
+			if box, err = h.findMailbox(ctx, number); err != nil {
+				return nil, err
+			}
+		}
+
+		ok, err := h.checkPIN(ctx, callCtx, box)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return box, nil
+		}
+		if err := h.play(ctx, callCtx, "vm_invalid_pin"); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// fixedMailbox returns the mailbox the node is configured for: its voicemail
+// box entity, or with "mailbox": "caller" the box linked to the calling
+// extension. It returns nil if the caller has to enter a mailbox number.
+func (h *VoicemailRetrievalHandler) fixedMailbox(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (*models.VoicemailBox, error) {
+	entity, err := h.engine.ResolveNodeEntity(ctx, node)
+	if err != nil {
+		return nil, fmt.Errorf("resolving voicemail box entity: %w", err)
+	}
+	if entity != nil {
+		box, ok := entity.(*models.VoicemailBox)
+		if !ok {
+			return nil, fmt.Errorf("voicemail retrieval node %s: entity is %T, expected *models.VoicemailBox", node.ID, entity)
+		}
+		return box, nil
+	}
+
+	if mode, _ := node.Data.Config["mailbox"].(string); mode != "caller" {
+		return nil, nil
+	}
+
+	ext, err := h.callerExtension(ctx, callCtx)
+	if err != nil || ext == nil {
+		return nil, err
+	}
+	boxes, err := h.boxes.ListByNotifyExtensionID(ctx, ext.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing voicemail boxes for extension %s: %w", ext.Extension, err)
+	}
+	if len(boxes) == 0 {
+		h.logger.Debug("calling extension has no voicemail box, asking for mailbox",
+			"call_id", callCtx.CallID,
+			"extension", ext.Extension,
+		)
+		return nil, nil
+	}
+	return &boxes[0], nil
+}
+
+// callerExtension returns the local extension placing the call, or nil if
+// the call came in over a trunk.
+func (h *VoicemailRetrievalHandler) callerExtension(ctx context.Context, callCtx *flow.CallContext) (*models.Extension, error) {
+	if callCtx.TrunkID != 0 || callCtx.CallerIDNum == "" {
+		return nil, nil
+	}
+	ext, err := h.extensions.GetByExtension(ctx, callCtx.CallerIDNum)
+	if err != nil {
+		return nil, fmt.Errorf("looking up calling extension: %w", err)
+	}
+	return ext, nil
+}
+
+// findMailbox returns the voicemail box with the given mailbox number, or
+// nil if there is none.
+func (h *VoicemailRetrievalHandler) findMailbox(ctx context.Context, number string) (*models.VoicemailBox, error) {
+	boxes, err := h.boxes.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing voicemail boxes: %w", err)
+	}
+	for i := range boxes {
+		if boxes[i].MailboxNumber == number {
+			return &boxes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// checkPIN asks for the mailbox PIN and verifies it against the stored
+// hash. box may be nil for an unknown mailbox number, which always fails.
+// A box without a PIN is only opened for its linked extension.
+func (h *VoicemailRetrievalHandler) checkPIN(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox) (bool, error) {
+	if box != nil && box.PIN == "" {
+		ext, err := h.callerExtension(ctx, callCtx)
+		if err != nil {
+			return false, err
+		}
+		return ext != nil && box.NotifyExtensionID != nil && *box.NotifyExtensionID == ext.ID, nil
+	}
+
+	pin, err := h.collect(ctx, callCtx, "vm_enter_pin", vmMaxDigits)
+	if err != nil {
+		return false, err
+	}
+	if box == nil || pin == "" {
+		return false, nil
+	}
+
+	ok, err := database.CheckPassword(pin, box.PIN)
+	if err != nil {
+		h.logger.Error("failed to verify voicemail pin",
+			"call_id", callCtx.CallID,
+			"mailbox_id", box.ID,
+			"error", err,
+		)
+		return false, nil
+	}
+	return ok, nil
+}
+
+// announceCounts tells the caller how many new and saved messages the
+// mailbox holds.
+func (h *VoicemailRetrievalHandler) announceCounts(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox) error {
+	msgs, err := h.messages.ListByMailbox(ctx, box.ID)
+	if err != nil {
+		return fmt.Errorf("listing voicemail messages: %w", err)
+	}
+	newCount, oldCount := 0, 0
+	for _, m := range msgs {
+		if m.Read {
+			oldCount++
+		} else {
+			newCount++
+		}
+	}
+
+	var names []string
+	names = append(names, digitPrompts(newCount)...)
+	names = append(names, "vm_new_messages")
+	names = append(names, digitPrompts(oldCount)...)
+	names = append(names, "vm_old_messages")
+	for _, name := range names {
+		if err := h.play(ctx, callCtx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listen plays the mailbox's messages, new ones first, and handles the
+// message menu for each. It reports whether the caller was transferred to
+// call a message's sender back.
+func (h *VoicemailRetrievalHandler) listen(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox) (bool, error) {
+	msgs, err := h.messages.ListByMailbox(ctx, box.ID)
+	if err != nil {
+		return false, fmt.Errorf("listing voicemail messages: %w", err)
+	}
+	sortForPlayback(msgs)
+
+	for i := 0; i < len(msgs); {
+		msg := &msgs[i]
+
+		// Play the message; a key pressed during playback is handled as
+		// a message menu choice.
+		res, err := h.sip.PlayAndCollect(ctx, callCtx, msg.FilePath, false, 1, 1, 1)
+		if err != nil {
+			return false, err
+		}
+		digit := res.Digits
+		if digit == "" {
+			h.markRead(ctx, callCtx, box, msg)
+		}
+
+		for attempts := 0; digit == "" && attempts < vmMenuAttempts; attempts++ {
+			if digit, err = h.collect(ctx, callCtx, "vm_message_menu", 1); err != nil {
+				return false, err
+			}
+		}
+
+		switch digit {
+		case "5":
+			continue
+		case "", "*":
+			return false, nil
+		case "6":
+			i++
+		case "9":
+			h.markRead(ctx, callCtx, box, msg)
+			if err := h.play(ctx, callCtx, "vm_message_saved"); err != nil {
+				return false, err
+			}
+			i++
+		case "7":
+			h.deleteMessage(ctx, callCtx, box, msg)
+			if err := h.play(ctx, callCtx, "vm_message_deleted"); err != nil {
+				return false, err
+			}
+			i++
+		case "3":
+			if h.callBack(ctx, callCtx, msg) {
+				return true, nil
+			}
+			if err := h.play(ctx, callCtx, "ivr_invalid_option"); err != nil {
+				return false, err
+			}
+		default:
+			if err := h.play(ctx, callCtx, "ivr_invalid_option"); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return false, h.play(ctx, callCtx, "vm_no_more_messages")
+}
+
+// markRead marks a message as heard and updates MWI.
+func (h *VoicemailRetrievalHandler) markRead(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox, msg *models.VoicemailMessage) {
+	if msg.Read || callCtx.Simulation() != nil {
+		return
+	}
+	if err := h.messages.MarkRead(ctx, msg.ID); err != nil {
+		h.logger.Error("failed to mark voicemail message read",
+			"call_id", callCtx.CallID,
+			"message_id", msg.ID,
+			"error", err,
+		)
+		return
+	}
+	msg.Read = true
+	sendMailboxMWI(ctx, h.sip, h.extensions, h.messages, box, h.logger)
+}
+
+// deleteMessage removes a message and its recording and updates MWI. A
+// simulated call deletes nothing.
+func (h *VoicemailRetrievalHandler) deleteMessage(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox, msg *models.VoicemailMessage) {
+	if callCtx.Simulation() != nil {
+		return
+	}
+	if err := h.messages.Delete(ctx, msg.ID); err != nil {
+		h.logger.Error("failed to delete voicemail message",
+			"call_id", callCtx.CallID,
+			"message_id", msg.ID,
+			"error", err,
+		)
+		return
+	}
+	if msg.FilePath != "" {
+		if err := os.Remove(msg.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			h.logger.Warn("failed to remove voicemail recording",
+				"call_id", callCtx.CallID,
+				"message_id", msg.ID,
+				"file", msg.FilePath,
+				"error", err,
+			)
+		}
+	}
+
+	h.logger.Info("voicemail message deleted",
+		"call_id", callCtx.CallID,
+		"mailbox_id", box.ID,
+		"message_id", msg.ID,
+	)
+	sendMailboxMWI(ctx, h.sip, h.extensions, h.messages, box, h.logger)
+}
+
+// callBack transfers the caller to the number that left a message. It
+// reports whether the transfer succeeded.
+func (h *VoicemailRetrievalHandler) callBack(ctx context.Context, callCtx *flow.CallContext, msg *models.VoicemailMessage) bool {
+	if msg.CallerIDNum == "" {
+		return false
+	}
+	if err := h.sip.BlindTransfer(ctx, callCtx, msg.CallerIDNum); err != nil {
+		h.logger.Warn("voicemail call back failed",
+			"call_id", callCtx.CallID,
+			"message_id", msg.ID,
+			"destination", msg.CallerIDNum,
+			"error", err,
+		)
+		return false
+	}
+	h.logger.Info("voicemail call back transferred",
+		"call_id", callCtx.CallID,
+		"message_id", msg.ID,
+		"destination", msg.CallerIDNum,
+	)
+	return true
+}
+
+// recordGreeting records a new greeting for the mailbox and makes it the
+// box's custom greeting. The previous greeting is kept if nothing was
+// recorded, as it is for a simulated call.
+func (h *VoicemailRetrievalHandler) recordGreeting(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox) error {
+	greetingPath := prompts.GreetingPath(h.dataDir, box.ID)
+	duration, err := h.recordPrompt(ctx, callCtx, "vm_record_greeting", greetingPath, vmGreetingMaxDuration)
+	if err != nil || duration == 0 {
+		return err
+	}
+
+	box.GreetingFile = greetingPath
+	box.GreetingType = "custom"
+	if err := h.boxes.Update(ctx, box); err != nil {
+		return fmt.Errorf("updating voicemail box greeting: %w", err)
+	}
+
+	h.logger.Info("voicemail greeting recorded",
+		"call_id", callCtx.CallID,
+		"mailbox_id", box.ID,
+		"duration", duration,
+	)
+	return h.play(ctx, callCtx, "vm_greeting_saved")
+}
+
+// recordName records the spoken name of the mailbox's owner. The previous
+// recording is kept if nothing was recorded, as it is for a simulated call.
+func (h *VoicemailRetrievalHandler) recordName(ctx context.Context, callCtx *flow.CallContext, box *models.VoicemailBox) error {
+	namePath := prompts.NamePath(h.dataDir, box.ID)
+	duration, err := h.recordPrompt(ctx, callCtx, "vm_record_name", namePath, vmNameMaxDuration)
+	if err != nil || duration == 0 {
+		return err
+	}
+
+	box.NameFile = namePath
+	if err := h.boxes.Update(ctx, box); err != nil {
+		return fmt.Errorf("updating voicemail box name: %w", err)
+	}
+
+	h.logger.Info("voicemail name recorded",
+		"call_id", callCtx.CallID,
+		"mailbox_id", box.ID,
+		"duration", duration,
+	)
+	return h.play(ctx, callCtx, "vm_name_saved")
+}
+
+// recordPrompt plays the named system prompt and records the caller to
+// path, replacing the file only once something has been recorded. It
+// returns the length of the recording in seconds, or 0 if nothing was
+// recorded.
+func (h *VoicemailRetrievalHandler) recordPrompt(ctx context.Context, callCtx *flow.CallContext, prompt, path string, maxDuration int) (int, error) {
+	if callCtx.Simulation() != nil {
+		return 0, h.play(ctx, callCtx, prompt)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return 0, fmt.Errorf("creating greetings directory: %w", err)
+	}
+	tmpPath := path + ".new"
+
+	result, err := h.sip.RecordMessage(ctx, callCtx, h.promptPath(prompt), maxDuration, tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("recording %s: %w", filepath.Base(path), err)
+	}
+	if result.DurationSecs <= 0 {
+		os.Remove(tmpPath)
+		return 0, h.play(ctx, callCtx, "ivr_invalid_option")
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("saving %s: %w", filepath.Base(path), err)
+	}
+	return result.DurationSecs, nil
+}
+
+// collect plays a system prompt and collects up to maxDigits digits. It
+// returns "" if the caller pressed nothing.
+func (h *VoicemailRetrievalHandler) collect(ctx context.Context, callCtx *flow.CallContext, name string, maxDigits int) (string, error) {
+	res, err := h.sip.PlayAndCollect(ctx, callCtx, h.promptPath(name), false, vmFirstDigitTimeout, vmInterDigitTimeout, maxDigits)
+	if err != nil {
+		return "", err
+	}
+	return res.Digits, nil
+}
+
+// play plays a system prompt. Playback failures other than the caller
+// hanging up are logged and skipped.
+func (h *VoicemailRetrievalHandler) play(ctx context.Context, callCtx *flow.CallContext, name string) error {
+	err := h.sip.PlayPrompt(ctx, callCtx, h.promptPath(name))
+	if err == nil || errors.Is(err, flow.ErrCallerHungUp) {
+		return err
+	}
+	h.logger.Warn("voicemail prompt playback failed",
+		"call_id", callCtx.CallID,
+		"prompt", name,
+		"error", err,
+	)
+	return nil
+}
+
+// promptPath returns the path of a system prompt.
+func (h *VoicemailRetrievalHandler) promptPath(name string) string {
+	return filepath.Join(h.dataDir, "prompts", "system", name+".wav")
+}
+
+// digitPrompts returns the digit prompts that read out n.
+func digitPrompts(n int) []string {
+	var names []string
+	for _, d := range strconv.Itoa(n) {
+		names = append(names, "digit_"+string(d))
+	}
+	return names
+}
+
+// sortForPlayback orders messages for listening: unread before read, oldest
+// first within each group.
+func sortForPlayback(msgs []models.VoicemailMessage) {
+	sort.SliceStable(msgs, func(i, j int) bool {
+		if msgs[i].Read != msgs[j].Read {
+			return !msgs[i].Read
+		}
+		return msgs[i].Timestamp.Before(msgs[j].Timestamp)
+	})
+}
+
+// Ensure VoicemailRetrievalHandler satisfies the NodeHandler and NodeTimeoutProvider interfaces.
+var (
+	_ flow.NodeHandler         = (*VoicemailRetrievalHandler)(nil)
+	_ flow.NodeTimeoutProvider = (*VoicemailRetrievalHandler)(nil)
+)
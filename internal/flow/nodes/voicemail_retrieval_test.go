@@ -0,0 +1,320 @@
+package nodes
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/prompts"
+)
+
+// mockRetrievalSIPActions implements flow.SIPActions for voicemail
+// retrieval testing. Digit entries are returned in order by PlayAndCollect;
+// every prompt played or collected against is recorded by name.
+type mockRetrievalSIPActions struct {
+	mockVoicemailSIPActions
+	digits      []string
+	played      []string
+	answered    int
+	transferred []string
+	recordDur   int
+}
+
+func (m *mockRetrievalSIPActions) PlayAndCollect(_ context.Context, _ *flow.CallContext, prompt string, _ bool, _ int, _ int, _ int) (*flow.CollectResult, error) {
+	m.played = append(m.played, promptName(prompt))
+	if len(m.digits) == 0 {
+		return &flow.CollectResult{TimedOut: true}, nil
+	}
+	d := m.digits[0]
+	m.digits = m.digits[1:]
+	return &flow.CollectResult{Digits: d, TimedOut: d == ""}, nil
+}
+
+func (m *mockRetrievalSIPActions) PlayPrompt(_ context.Context, _ *flow.CallContext, filePath string) error {
+	m.played = append(m.played, promptName(filePath))
+	return nil
+}
+
+func (m *mockRetrievalSIPActions) AnswerCall(_ context.Context, _ *flow.CallContext) error {
+	m.answered++
+	return nil
+}
+
+func (m *mockRetrievalSIPActions) RecordMessage(_ context.Context, _ *flow.CallContext, greeting string, _ int, filePath string) (*flow.RecordResult, error) {
+	m.played = append(m.played, promptName(greeting))
+	if err := os.WriteFile(filePath, []byte("fake-wav"), 0640); err != nil {
+		return nil, err
+	}
+	return &flow.RecordResult{FilePath: filePath, DurationSecs: m.recordDur}, nil
+}
+
+func (m *mockRetrievalSIPActions) BlindTransfer(_ context.Context, _ *flow.CallContext, destination string) error {
+	m.transferred = append(m.transferred, destination)
+	return nil
+}
+
+// promptName strips the directory and extension from a prompt path.
+func promptName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".wav")
+}
+
+// mockVoicemailBoxRepo implements database.VoicemailBoxRepository for testing.
+type mockVoicemailBoxRepo struct {
+	boxes []models.VoicemailBox
+}
+
+func (m *mockVoicemailBoxRepo) Create(_ context.Context, _ *models.VoicemailBox) error { return nil }
+func (m *mockVoicemailBoxRepo) Delete(_ context.Context, _ int64) error                { return nil }
+
+func (m *mockVoicemailBoxRepo) GetByID(_ context.Context, id int64) (*models.VoicemailBox, error) {
+	for i := range m.boxes {
+		if m.boxes[i].ID == id {
+			return &m.boxes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockVoicemailBoxRepo) List(_ context.Context) ([]models.VoicemailBox, error) {
+	return append([]models.VoicemailBox(nil), m.boxes...), nil
+}
+
+func (m *mockVoicemailBoxRepo) ListByNotifyExtensionID(_ context.Context, extensionID int64) ([]models.VoicemailBox, error) {
+	var result []models.VoicemailBox
+	for _, b := range m.boxes {
+		if b.NotifyExtensionID != nil && *b.NotifyExtensionID == extensionID {
+			result = append(result, b)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockVoicemailBoxRepo) Update(_ context.Context, box *models.VoicemailBox) error {
+	for i := range m.boxes {
+		if m.boxes[i].ID == box.ID {
+			m.boxes[i] = *box
+		}
+	}
+	return nil
+}
+
+// retrievalFixture holds a mailbox 200 with PIN 1234 linked to extension
+// 101, holding one read and one unread message.
+type retrievalFixture struct {
+	sip      *mockRetrievalSIPActions
+	boxes    *mockVoicemailBoxRepo
+	messages *mockVoicemailMessageRepo
+	dataDir  string
+	handler  *VoicemailRetrievalHandler
+}
+
+func newRetrievalFixture(t *testing.T, digits ...string) *retrievalFixture {
+	t.Helper()
+
+	pin, err := database.HashPassword("1234")
+	if err != nil {
+		t.Fatalf("hashing pin: %v", err)
+	}
+	extID := int64(5)
+	dataDir := t.TempDir()
+	base := time.Date(2025, 6, 15, 10, 0, 0, 0, time.UTC)
+
+	f := &retrievalFixture{
+		sip: &mockRetrievalSIPActions{digits: digits, recordDur: 5},
+		boxes: &mockVoicemailBoxRepo{boxes: []models.VoicemailBox{
+			{ID: 1, MailboxNumber: "200", PIN: pin, NotifyExtensionID: &extID},
+		}},
+		messages: &mockVoicemailMessageRepo{messages: []models.VoicemailMessage{
+			{ID: 1, MailboxID: 1, CallerIDNum: "0400000001", Timestamp: base, FilePath: filepath.Join(dataDir, "old.wav"), Read: true},
+			{ID: 2, MailboxID: 1, CallerIDNum: "0400000002", Timestamp: base.Add(time.Hour), FilePath: filepath.Join(dataDir, "new.wav")},
+		}},
+		dataDir: dataDir,
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	engine := flow.NewEngine(nil, nil, &mockEntityResolver{}, logger)
+	extRepo := &mockExtensionRepo{extensions: map[int64]*models.Extension{
+		extID: {ID: extID, Extension: "101"},
+	}}
+	f.handler = NewVoicemailRetrievalHandler(engine, f.sip, f.boxes, f.messages, extRepo, dataDir, logger)
+	return f
+}
+
+func (f *retrievalFixture) run(t *testing.T, callerNum string, config map[string]any) string {
+	t.Helper()
+
+	callCtx := &flow.CallContext{CallID: "test-vmr", CallerIDNum: callerNum}
+	node := flow.Node{ID: "node_vmr", Type: "voicemail_retrieval", Data: flow.NodeData{Config: config}}
+	edge, err := f.handler.Execute(context.Background(), callCtx, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.sip.answered != 1 {
+		t.Errorf("AnswerCall called %d times, want 1", f.sip.answered)
+	}
+	return edge
+}
+
+func (f *retrievalFixture) playedIndex(name string) int {
+	for i, p := range f.sip.played {
+		if p == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestVoicemailRetrievalListenAndDelete(t *testing.T) {
+	// Mailbox, PIN, listen, delete the new message, save the old one
+	// (heard in full), back at the end, exit.
+	f := newRetrievalFixture(t, "200", "1234", "1", "7", "", "9", "*")
+	if err := os.WriteFile(f.messages.messages[1].FilePath, []byte("fake-wav"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	edge := f.run(t, "0299999999", nil)
+	if edge != "next" {
+		t.Errorf("edge = %q, want next", edge)
+	}
+
+	// 1 new and 1 old message announced.
+	want := []string{"vm_enter_mailbox", "vm_enter_pin", "digit_1", "vm_new_messages", "digit_1", "vm_old_messages", "vm_main_menu", "new"}
+	for i, name := range want {
+		if i >= len(f.sip.played) || f.sip.played[i] != name {
+			t.Fatalf("played = %v, want prefix %v", f.sip.played, want)
+		}
+	}
+	if f.playedIndex("vm_message_deleted") < 0 || f.playedIndex("vm_message_saved") < 0 || f.playedIndex("vm_no_more_messages") < 0 {
+		t.Errorf("played = %v, missing message menu confirmations", f.sip.played)
+	}
+	if f.playedIndex("new") > f.playedIndex("old") {
+		t.Errorf("played = %v, want new message before old", f.sip.played)
+	}
+
+	if len(f.messages.messages) != 1 || f.messages.messages[0].ID != 1 {
+		t.Fatalf("messages = %+v, want only message 1", f.messages.messages)
+	}
+	if _, err := os.Stat(filepath.Join(f.dataDir, "new.wav")); !os.IsNotExist(err) {
+		t.Errorf("deleted message recording still exists: %v", err)
+	}
+	if len(f.sip.mwiCalls) == 0 {
+		t.Fatal("expected MWI update")
+	}
+	last := f.sip.mwiCalls[len(f.sip.mwiCalls)-1]
+	if last.NewMessages != 0 || last.OldMessages != 1 {
+		t.Errorf("last MWI = %d new, %d old; want 0 new, 1 old", last.NewMessages, last.OldMessages)
+	}
+}
+
+func TestVoicemailRetrievalHeardMarksRead(t *testing.T) {
+	// Own mailbox, PIN, listen: new message plays out, then exit.
+	f := newRetrievalFixture(t, "1234", "1", "", "*", "*")
+
+	if edge := f.run(t, "101", map[string]any{"mailbox": "caller"}); edge != "next" {
+		t.Errorf("edge = %q, want next", edge)
+	}
+	if f.playedIndex("vm_enter_mailbox") >= 0 {
+		t.Errorf("played = %v, own mailbox should not ask for a mailbox number", f.sip.played)
+	}
+	for _, m := range f.messages.messages {
+		if !m.Read {
+			t.Errorf("message %d not marked read", m.ID)
+		}
+	}
+}
+
+func TestVoicemailRetrievalWrongPIN(t *testing.T) {
+	f := newRetrievalFixture(t, "200", "1111", "999", "0000", "200", "4321")
+
+	if edge := f.run(t, "0299999999", nil); edge != "failed" {
+		t.Errorf("edge = %q, want failed", edge)
+	}
+	invalid := 0
+	for _, p := range f.sip.played {
+		if p == "vm_invalid_pin" {
+			invalid++
+		}
+	}
+	if invalid != vmLoginAttempts {
+		t.Errorf("vm_invalid_pin played %d times, want %d", invalid, vmLoginAttempts)
+	}
+	if f.playedIndex("vm_main_menu") >= 0 {
+		t.Error("main menu reached without a valid PIN")
+	}
+}
+
+func TestVoicemailRetrievalCallBack(t *testing.T) {
+	f := newRetrievalFixture(t, "200", "1234", "1", "3")
+
+	if edge := f.run(t, "0299999999", nil); edge != "" {
+		t.Errorf("edge = %q, want empty after call back", edge)
+	}
+	if len(f.sip.transferred) != 1 || f.sip.transferred[0] != "0400000002" {
+		t.Errorf("transferred = %v, want [0400000002]", f.sip.transferred)
+	}
+}
+
+func TestVoicemailRetrievalRecordGreeting(t *testing.T) {
+	f := newRetrievalFixture(t, "200", "1234", "0", "*")
+
+	if edge := f.run(t, "0299999999", nil); edge != "next" {
+		t.Errorf("edge = %q, want next", edge)
+	}
+
+	box := f.boxes.boxes[0]
+	want := prompts.GreetingPath(f.dataDir, 1)
+	if box.GreetingType != "custom" || box.GreetingFile != want {
+		t.Errorf("greeting = %q (%s), want %q (custom)", box.GreetingFile, box.GreetingType, want)
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("greeting file not saved: %v", err)
+	}
+	if f.playedIndex("vm_greeting_saved") < 0 {
+		t.Errorf("played = %v, want vm_greeting_saved", f.sip.played)
+	}
+}
+
+func TestVoicemailRetrievalRecordName(t *testing.T) {
+	f := newRetrievalFixture(t, "200", "1234", "2", "*")
+
+	if edge := f.run(t, "0299999999", nil); edge != "next" {
+		t.Errorf("edge = %q, want next", edge)
+	}
+
+	box := f.boxes.boxes[0]
+	want := prompts.NamePath(f.dataDir, 1)
+	if box.NameFile != want {
+		t.Errorf("name file = %q, want %q", box.NameFile, want)
+	}
+	if box.GreetingType == "custom" {
+		t.Error("recording a name changed the greeting")
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("name file not saved: %v", err)
+	}
+	if f.playedIndex("vm_record_name") < 0 || f.playedIndex("vm_name_saved") < 0 {
+		t.Errorf("played = %v, want vm_record_name and vm_name_saved", f.sip.played)
+	}
+}
+
+func TestVoicemailRetrievalNoPINRequiresOwnExtension(t *testing.T) {
+	f := newRetrievalFixture(t, "200", "200", "200")
+	f.boxes.boxes[0].PIN = ""
+
+	if edge := f.run(t, "0299999999", nil); edge != "failed" {
+		t.Errorf("edge = %q, want failed for another caller", edge)
+	}
+
+	f = newRetrievalFixture(t, "*")
+	f.boxes.boxes[0].PIN = ""
+	if edge := f.run(t, "101", map[string]any{"mailbox": "caller"}); edge != "next" {
+		t.Errorf("edge = %q, want next for the linked extension", edge)
+	}
+}
@@ -1,7 +1,9 @@
 package nodes
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
 	"log/slog"
 	"os"
@@ -13,6 +15,8 @@ import (
 	"github.com/flowpbx/flowpbx/internal/database/models"
 	"github.com/flowpbx/flowpbx/internal/email"
 	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/media"
+	"github.com/flowpbx/flowpbx/internal/tts"
 )
 
 // mockVoicemailSIPActions implements flow.SIPActions with configurable
@@ -22,6 +26,7 @@ type mockVoicemailSIPActions struct {
 	recordErr    error
 	mwiCalls     []mwiCall
 	mwiErr       error
+	received     []*models.VoicemailMessage
 }
 
 type mwiCall struct {
@@ -42,6 +47,10 @@ func (m *mockVoicemailSIPActions) PlayAndCollect(_ context.Context, _ *flow.Call
 	return nil, nil
 }
 
+func (m *mockVoicemailSIPActions) AnswerCall(_ context.Context, _ *flow.CallContext) error {
+	return nil
+}
+
 func (m *mockVoicemailSIPActions) RecordMessage(_ context.Context, _ *flow.CallContext, _ string, _ int, _ string) (*flow.RecordResult, error) {
 	if m.recordErr != nil {
 		return nil, m.recordErr
@@ -54,6 +63,10 @@ func (m *mockVoicemailSIPActions) SendMWI(_ context.Context, ext *models.Extensi
 	return m.mwiErr
 }
 
+func (m *mockVoicemailSIPActions) VoicemailReceived(_ context.Context, _ *models.VoicemailBox, msg *models.VoicemailMessage) {
+	m.received = append(m.received, msg)
+}
+
 func (m *mockVoicemailSIPActions) HangupCall(_ context.Context, _ *flow.CallContext, _ int, _ string) error {
 	return nil
 }
@@ -74,6 +87,18 @@ func (m *mockVoicemailSIPActions) RingFollowMeSimultaneous(_ context.Context, _
 	return &flow.RingResult{Answered: false}, nil
 }
 
+func (m *mockVoicemailSIPActions) StartEarlyMedia(_ context.Context, _ *flow.CallContext) error {
+	return nil
+}
+
+func (m *mockVoicemailSIPActions) PlayPrompt(_ context.Context, _ *flow.CallContext, _ string) error {
+	return nil
+}
+
+func (m *mockVoicemailSIPActions) PlayHoldMusic(_ context.Context, _ *flow.CallContext, _ *int64, _ string) error {
+	return nil
+}
+
 // mockVoicemailMessageRepo implements database.VoicemailMessageRepository
 // for testing. It stores created messages in memory.
 type mockVoicemailMessageRepo struct {
@@ -112,10 +137,21 @@ func (m *mockVoicemailMessageRepo) ListByMailbox(_ context.Context, mailboxID in
 }
 
 func (m *mockVoicemailMessageRepo) MarkRead(_ context.Context, id int64) error {
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			m.messages[i].Read = true
+		}
+	}
 	return nil
 }
 
 func (m *mockVoicemailMessageRepo) Delete(_ context.Context, id int64) error {
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			m.messages = append(m.messages[:i], m.messages[i+1:]...)
+			break
+		}
+	}
 	return nil
 }
 
@@ -146,7 +182,12 @@ func (m *mockExtensionRepo) Create(_ context.Context, _ *models.Extension) error
 func (m *mockExtensionRepo) List(_ context.Context) ([]models.Extension, error)  { return nil, nil }
 func (m *mockExtensionRepo) Update(_ context.Context, _ *models.Extension) error { return nil }
 func (m *mockExtensionRepo) Delete(_ context.Context, _ int64) error             { return nil }
-func (m *mockExtensionRepo) GetByExtension(_ context.Context, _ string) (*models.Extension, error) {
+func (m *mockExtensionRepo) GetByExtension(_ context.Context, extension string) (*models.Extension, error) {
+	for _, ext := range m.extensions {
+		if ext.Extension == extension {
+			return ext, nil
+		}
+	}
 	return nil, nil
 }
 func (m *mockExtensionRepo) GetBySIPUsername(_ context.Context, _ string) (*models.Extension, error) {
@@ -164,7 +205,7 @@ func newTestVoicemailHandler(box *models.VoicemailBox, sipActions *mockVoicemail
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	resolver := &mockEntityResolver{entity: box}
 	engine := flow.NewEngine(nil, nil, resolver, logger)
-	h := NewVoicemailHandler(engine, sipActions, msgRepo, extRepo, nil, nil, nil, logger, dataDir)
+	h := NewVoicemailHandler(engine, sipActions, msgRepo, extRepo, nil, nil, nil, nil, logger, dataDir)
 	return h
 }
 
@@ -248,6 +289,9 @@ func TestVoicemailRecordAndStore(t *testing.T) {
 	if msg.Duration != 15 {
 		t.Errorf("expected duration 15, got %d", msg.Duration)
 	}
+	if len(sipActions.received) != 1 || sipActions.received[0].ID != msg.ID {
+		t.Errorf("expected the stored message to be reported, got %d reports", len(sipActions.received))
+	}
 
 	// Verify recording directory was created.
 	expectedDir := filepath.Join(dataDir, "voicemail", "box_1")
@@ -348,6 +392,58 @@ func TestVoicemailCustomGreetingFallback(t *testing.T) {
 	}
 }
 
+// stubSynthesizer renders every text as a short u-law silence and records
+// the last text spoken.
+type stubSynthesizer struct {
+	text string
+}
+
+func (s *stubSynthesizer) Synthesize(_ context.Context, text string, _ int) ([]byte, error) {
+	s.text = text
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+800))
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(7), uint16(1), uint32(8000), uint32(8000), uint16(1), uint16(8)} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(800))
+	buf.Write(bytes.Repeat([]byte{0xFF}, 800))
+	return buf.Bytes(), nil
+}
+
+func (s *stubSynthesizer) CacheKey() string { return "stub" }
+
+func TestVoicemailNameOnlyGreetingTTS(t *testing.T) {
+	dataDir := t.TempDir()
+
+	box := &models.VoicemailBox{
+		ID:            5,
+		Name:          "Sales",
+		MailboxNumber: "500",
+		GreetingType:  "name_only",
+	}
+	callCtx := &flow.CallContext{CallID: "test-vm-tts"}
+
+	h := newTestVoicemailHandler(box, &mockVoicemailSIPActions{}, &mockVoicemailMessageRepo{}, &mockExtensionRepo{}, dataDir)
+
+	// Without a TTS engine the default greeting plays.
+	if got, want := h.greeting(context.Background(), callCtx, box), filepath.Join(dataDir, defaultGreetingFile); got != want {
+		t.Errorf("greeting without tts = %q, want %q", got, want)
+	}
+
+	synth := &stubSynthesizer{}
+	h.speech = tts.NewCache(synth, dataDir, media.PayloadPCMU, h.logger)
+	got := h.greeting(context.Background(), callCtx, box)
+	if filepath.Dir(got) != tts.CacheDir(dataDir) {
+		t.Errorf("greeting with tts = %q, want a file in %s", got, tts.CacheDir(dataDir))
+	}
+	if !strings.Contains(synth.text, "Sales") {
+		t.Errorf("spoken greeting %q does not name the box", synth.text)
+	}
+}
+
 func TestVoicemailDefaultMaxDuration(t *testing.T) {
 	dataDir := t.TempDir()
 
@@ -485,7 +581,7 @@ func TestVoicemailNoEntityError(t *testing.T) {
 	msgRepo := &mockVoicemailMessageRepo{}
 	extRepo := &mockExtensionRepo{extensions: map[int64]*models.Extension{}}
 
-	h := NewVoicemailHandler(engine, sipActions, msgRepo, extRepo, nil, nil, nil, logger, dataDir)
+	h := NewVoicemailHandler(engine, sipActions, msgRepo, extRepo, nil, nil, nil, nil, logger, dataDir)
 	callCtx := &flow.CallContext{CallID: "test-vm-noentity"}
 
 	_, err := h.Execute(context.Background(), callCtx, makeVoicemailNode(1))
@@ -658,7 +754,7 @@ func TestVoicemailEmailNotificationAttempted(t *testing.T) {
 
 	resolver := &mockEntityResolver{entity: box}
 	engine := flow.NewEngine(nil, nil, resolver, logger)
-	h := NewVoicemailHandler(engine, sipActions, msgRepo, extRepo, sysConfig, nil, emailSend, logger, dataDir)
+	h := NewVoicemailHandler(engine, sipActions, msgRepo, extRepo, sysConfig, nil, emailSend, nil, logger, dataDir)
 
 	callCtx := &flow.CallContext{
 		CallID:       "test-vm-email",
@@ -834,7 +930,7 @@ func TestVoicemailLoadSMTPConfig(t *testing.T) {
 
 	resolver := &mockEntityResolver{entity: nil}
 	engine := flow.NewEngine(nil, nil, resolver, logger)
-	h := NewVoicemailHandler(engine, nil, nil, nil, sysConfig, nil, nil, logger, dataDir)
+	h := NewVoicemailHandler(engine, nil, nil, nil, sysConfig, nil, nil, nil, logger, dataDir)
 
 	cfg, err := h.loadSMTPConfig(context.Background())
 	if err != nil {
@@ -1,37 +1,499 @@
 package nodes
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/flowpbx/flowpbx/internal/flow"
 )
 
-// WebhookHandler is a stub for the Webhook node type. Webhook nodes will
-// make HTTP callouts to external APIs and route based on the response.
-// This is reserved for a future phase; the current implementation logs a
-// warning and continues to the "next" output edge.
+const (
+	// defaultWebhookTimeout is the HTTP timeout used when the node config
+	// does not specify one. Callers hear silence while the request is in
+	// flight, so this is deliberately short.
+	defaultWebhookTimeout = 5 * time.Second
+
+	// maxWebhookResponseSize limits how much of the response body is read.
+	maxWebhookResponseSize = 1 << 20
+
+	// WebhookSignatureHeader carries the hex HMAC-SHA256 signature of the
+	// request when the node has a secret configured.
+	WebhookSignatureHeader = "X-FlowPBX-Signature"
+
+	// WebhookTimestampHeader carries the Unix timestamp included in the
+	// signed content, allowing receivers to reject replayed requests.
+	WebhookTimestampHeader = "X-FlowPBX-Timestamp"
+)
+
+// WebhookHandler handles the Webhook node type. It makes an HTTP callout to
+// an external API with details of the call, maps fields from the JSON
+// response into call variables, and routes based on the response.
+//
+// Node config:
+//   - "url":           request URL (template, required)
+//   - "method":        "POST" (default) or "GET"
+//   - "timeout":       request timeout in seconds (default 5)
+//   - "headers":       object of extra request headers (values are templates)
+//   - "payload":       object whose string values are templates, or a raw
+//     template string; defaults to the call details. For GET requests the
+//     top-level object fields are sent as query parameters.
+//   - "secret":        HMAC-SHA256 key used to sign the request
+//   - "response_map":  object of variable name to response field path
+//     (dot separated, e.g. "customer.tier" or "agents.0.extension")
+//   - "branch_field":  response field path whose value is used as the
+//     output edge
+//
+// Templates use Go text/template syntax with the fields of
+// flow.TemplateData, e.g. "{{.CallerIDNum}}" or
+// "{{.Variables.queue_wait_time}}". The json function quotes a value for
+// use in a raw JSON payload: {"from": {{json .CallerIDNum}}}.
+//
+// Output edges:
+//   - the value of branch_field, if configured and present in the response
+//   - "2xx", "4xx" or "5xx" based on the response status class
+//   - "timeout": the request did not complete within the timeout
+//   - "error":   the request failed or returned another status
+//   - "next":    followed when none of the above is wired, as webhook
+//     nodes only had this output before they made requests
+//
+// A branch_field value without a wired edge falls back to the status class
+// edge, then to "next".
 type WebhookHandler struct {
+	client *http.Client
 	logger *slog.Logger
 }
 
 // NewWebhookHandler creates a new WebhookHandler.
 func NewWebhookHandler(logger *slog.Logger) *WebhookHandler {
 	return &WebhookHandler{
+		// Timeouts are applied per request from the node config.
+		client: &http.Client{},
 		logger: logger.With("handler", "webhook"),
 	}
 }
 
-// Execute logs that the webhook node is not yet implemented and continues
-// to the "next" output edge.
+// webhookConfig is the parsed configuration of a webhook node.
+type webhookConfig struct {
+	url         string
+	method      string
+	timeout     time.Duration
+	headers     map[string]string
+	payload     any
+	secret      string
+	responseMap map[string]string
+	branchField string
+}
+
+// Execute sends the configured HTTP request and returns the output edge
+// selected from the response. The response status code is stored in the
+// "webhook_status" variable.
 func (h *WebhookHandler) Execute(ctx context.Context, callCtx *flow.CallContext, node flow.Node) (string, error) {
-	h.logger.Warn("webhook node not yet implemented, continuing to next",
+	h.logger.Debug("webhook node executing",
 		"call_id", callCtx.CallID,
 		"node_id", node.ID,
 	)
 
-	return "next", nil
+	cfg, err := parseWebhookConfig(node)
+	if err != nil {
+		return "", fmt.Errorf("webhook node %s: %w", node.ID, err)
+	}
+
+	req, err := h.buildRequest(ctx, cfg, flow.NewTemplateData(callCtx))
+	if err != nil {
+		return "", fmt.Errorf("webhook node %s: %w", node.ID, err)
+	}
+
+	// A simulated call takes its response from the caller's script
+	// instead of sending the request.
+	if sim := callCtx.Simulation(); sim != nil {
+		resp := sim.Webhooks[node.ID]
+		if resp.TimedOut {
+			return "timeout", nil
+		}
+		if resp.Status == 0 {
+			resp.Status = http.StatusOK
+		}
+		return h.applyResponse(callCtx, node, cfg, resp.Status, []byte(resp.Body)), nil
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := h.client.Do(req.WithContext(reqCtx))
+	if err != nil {
+		if errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+			h.logger.Warn("webhook request timed out",
+				"call_id", callCtx.CallID,
+				"node_id", node.ID,
+				"url", req.URL.Redacted(),
+				"timeout", cfg.timeout,
+			)
+			return "timeout", nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		h.logger.Warn("webhook request failed",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"url", req.URL.Redacted(),
+			"error", err,
+		)
+		return "error", nil
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseSize))
+	if err != nil {
+		if errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+			return "timeout", nil
+		}
+		h.logger.Warn("webhook response read failed",
+			"call_id", callCtx.CallID,
+			"node_id", node.ID,
+			"error", err,
+		)
+		return "error", nil
+	}
+
+	h.logger.Info("webhook request completed",
+		"call_id", callCtx.CallID,
+		"node_id", node.ID,
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+	)
+
+	return h.applyResponse(callCtx, node, cfg, resp.StatusCode, body), nil
+}
+
+// FallbackEdges returns the edges to try when the edge Execute returned is
+// not wired: for a branch_field value, the response's status class edge,
+// then "next", which webhook nodes in older flows are wired from.
+func (h *WebhookHandler) FallbackEdges(callCtx *flow.CallContext, _ flow.Node, outputEdge string) []string {
+	if outputEdge == "timeout" || outputEdge == "error" {
+		return []string{"next"}
+	}
+	var edges []string
+	if status, err := strconv.Atoi(callCtx.GetVariable("webhook_status")); err == nil {
+		if class := statusClassEdge(status); class != outputEdge {
+			edges = append(edges, class)
+		}
+	}
+	return append(edges, "next")
+}
+
+// applyResponse stores the response status and mapped response fields in
+// call variables and returns the output edge the response selects.
+func (h *WebhookHandler) applyResponse(callCtx *flow.CallContext, node flow.Node, cfg *webhookConfig, status int, body []byte) string {
+	callCtx.SetVariable("webhook_status", strconv.Itoa(status))
+
+	var result any
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &result); err != nil {
+			h.logger.Debug("webhook response is not json, skipping response mapping",
+				"call_id", callCtx.CallID,
+				"node_id", node.ID,
+			)
+			result = nil
+		}
+	}
+
+	for varName, path := range cfg.responseMap {
+		if v, ok := lookupJSONPath(result, path); ok {
+			callCtx.SetVariable(varName, jsonValueString(v))
+		}
+	}
+
+	if cfg.branchField != "" {
+		if v, ok := lookupJSONPath(result, cfg.branchField); ok {
+			if edge := jsonValueString(v); edge != "" {
+				return edge
+			}
+		}
+	}
+
+	return statusClassEdge(status)
+}
+
+// buildRequest renders the URL, headers and payload templates and returns
+// the signed HTTP request.
+func (h *WebhookHandler) buildRequest(ctx context.Context, cfg *webhookConfig, data flow.TemplateData) (*http.Request, error) {
+	rawURL, err := flow.RenderTemplate("url", cfg.url, data)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", rawURL)
+	}
+
+	payload, err := renderWebhookPayload(cfg.payload, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	var signed []byte
+	if cfg.method == http.MethodGet {
+		if fields, ok := payload.(map[string]any); ok {
+			q := u.Query()
+			for k, v := range fields {
+				q.Set(k, jsonValueString(v))
+			}
+			u.RawQuery = q.Encode()
+		}
+		signed = []byte(u.RawQuery)
+	} else {
+		switch p := payload.(type) {
+		case string:
+			body = []byte(p)
+		default:
+			body, err = json.Marshal(p)
+			if err != nil {
+				return nil, fmt.Errorf("marshalling payload: %w", err)
+			}
+		}
+		signed = body
+	}
+
+	req, err := http.NewRequestWithContext(ctx, cfg.method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if cfg.method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "FlowPBX-Webhook/1.0")
+
+	for name, value := range cfg.headers {
+		rendered, err := flow.RenderTemplate("header "+name, value, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, rendered)
+	}
+
+	if cfg.secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, ts)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(cfg.secret, ts, signed))
+	}
+
+	return req, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of timestamp + "." + content using
+// secret as the key. The content is the request body, or the encoded query
+// string for GET requests. Receivers compute the same value to verify the
+// X-FlowPBX-Signature header.
+func SignWebhook(secret, timestamp string, content []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseWebhookConfig reads and validates the webhook node config.
+func parseWebhookConfig(node flow.Node) (*webhookConfig, error) {
+	if node.Data.Config == nil {
+		return nil, fmt.Errorf("no config specified")
+	}
+	c := node.Data.Config
+
+	cfg := &webhookConfig{
+		method:  http.MethodPost,
+		timeout: defaultWebhookTimeout,
+	}
+
+	cfg.url, _ = c["url"].(string)
+	if strings.TrimSpace(cfg.url) == "" {
+		return nil, fmt.Errorf("no url configured")
+	}
+
+	if m, ok := c["method"].(string); ok && m != "" {
+		m = strings.ToUpper(m)
+		if m != http.MethodGet && m != http.MethodPost {
+			return nil, fmt.Errorf("unsupported method %q", m)
+		}
+		cfg.method = m
+	}
+
+	// The engine also applies "timeout" as the node deadline, so only
+	// whole seconds are honoured here as well.
+	if t, ok := c["timeout"].(float64); ok && t >= 1 {
+		cfg.timeout = time.Duration(t) * time.Second
+	}
+
+	headers, err := stringMapConfig(c, "headers")
+	if err != nil {
+		return nil, err
+	}
+	cfg.headers = headers
+
+	responseMap, err := stringMapConfig(c, "response_map")
+	if err != nil {
+		return nil, err
+	}
+	cfg.responseMap = responseMap
+
+	cfg.payload = c["payload"]
+	cfg.secret, _ = c["secret"].(string)
+	cfg.branchField, _ = c["branch_field"].(string)
+
+	return cfg, nil
+}
+
+// stringMapConfig reads an object of string values from the node config.
+func stringMapConfig(config map[string]any, key string) (map[string]string, error) {
+	v, ok := config[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", key)
+	}
+	result := make(map[string]string, len(obj))
+	for k, val := range obj {
+		s, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a string", key, k)
+		}
+		result[k] = s
+	}
+	return result, nil
+}
+
+// renderWebhookPayload renders the configured payload. Object and array
+// payloads have every string value rendered as a template; a string payload
+// is rendered as a raw body template. With no payload configured, the call
+// details are sent.
+func renderWebhookPayload(payload any, data flow.TemplateData) (any, error) {
+	if payload == nil {
+		return map[string]any{
+			"call_id":        data.CallID,
+			"caller_id_name": data.CallerIDName,
+			"caller_id_num":  data.CallerIDNum,
+			"callee":         data.Callee,
+			"dtmf":           data.DTMF,
+			"variables":      data.Variables,
+		}, nil
+	}
+	return renderPayloadValue(payload, data)
+}
+
+func renderPayloadValue(v any, data flow.TemplateData) (any, error) {
+	switch val := v.(type) {
+	case string:
+		return flow.RenderTemplate("payload", val, data)
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			rendered, err := renderPayloadValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			rendered, err := renderPayloadValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
 }
 
-// Ensure WebhookHandler satisfies the NodeHandler interface.
-var _ flow.NodeHandler = (*WebhookHandler)(nil)
+// lookupJSONPath returns the value at a dot-separated path in a decoded JSON
+// document. Numeric segments index into arrays.
+func lookupJSONPath(doc any, path string) (any, bool) {
+	if doc == nil || path == "" {
+		return nil, false
+	}
+	cur := doc
+	for _, seg := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]any:
+			next, ok := v[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			cur = v[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, cur != nil
+}
+
+// jsonValueString converts a decoded JSON value to a variable string.
+// Objects and arrays are re-encoded as JSON.
+func jsonValueString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	}
+}
+
+// statusClassEdge returns the output edge for an HTTP status code.
+func statusClassEdge(status int) string {
+	switch {
+	case status >= 200 && status < 300:
+		return "2xx"
+	case status >= 400 && status < 500:
+		return "4xx"
+	case status >= 500 && status < 600:
+		return "5xx"
+	default:
+		return "error"
+	}
+}
+
+// Ensure WebhookHandler satisfies the NodeHandler and FallbackEdgeProvider interfaces.
+var (
+	_ flow.NodeHandler          = (*WebhookHandler)(nil)
+	_ flow.FallbackEdgeProvider = (*WebhookHandler)(nil)
+)
@@ -0,0 +1,210 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+func newTestWebhookHandler() *WebhookHandler {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	return NewWebhookHandler(logger)
+}
+
+func makeWebhookNode(config map[string]any) flow.Node {
+	return flow.Node{
+		ID:   "node_webhook",
+		Type: "webhook",
+		Data: flow.NodeData{
+			Label:  "CRM Lookup",
+			Config: config,
+		},
+	}
+}
+
+func TestWebhookPayloadSignatureAndBranch(t *testing.T) {
+	var gotBody map[string]any
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		ts := r.Header.Get(WebhookTimestampHeader)
+		want := "sha256=" + SignWebhook("s3cret", ts, body)
+		if got := r.Header.Get(WebhookSignatureHeader); got != want {
+			t.Errorf("signature mismatch: got %q, want %q", got, want)
+		}
+		gotAuth = r.Header.Get("Authorization")
+		if err := json.Unmarshal(body, &gotBody); err != nil {
+			t.Errorf("invalid json payload: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"customer":{"tier":"vip","name":"Acme"},"agents":[{"ext":"101"}]}`))
+	}))
+	defer srv.Close()
+
+	node := makeWebhookNode(map[string]any{
+		"url":     srv.URL + "/lookup",
+		"secret":  "s3cret",
+		"headers": map[string]any{"Authorization": "Bearer {{.Variables.token}}"},
+		"payload": map[string]any{
+			"from":  "{{.CallerIDNum}}",
+			"to":    "{{.Callee}}",
+			"digit": "{{.DTMF}}",
+			"fixed": float64(42),
+		},
+		"response_map": map[string]any{
+			"customer_name": "customer.name",
+			"first_agent":   "agents.0.ext",
+		},
+		"branch_field": "customer.tier",
+	})
+
+	callCtx := flow.NewCallContext("test-webhook-1", "Alice", "0412345678", "1300123456", nil, 0, nil, nil)
+	callCtx.SetVariable("token", "abc")
+	callCtx.AppendDTMF("2")
+
+	edge, err := newTestWebhookHandler().Execute(context.Background(), callCtx, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "vip" {
+		t.Errorf("expected edge %q, got %q", "vip", edge)
+	}
+
+	if gotBody["from"] != "0412345678" || gotBody["to"] != "1300123456" || gotBody["digit"] != "2" || gotBody["fixed"] != float64(42) {
+		t.Errorf("unexpected payload: %v", gotBody)
+	}
+	if gotAuth != "Bearer abc" {
+		t.Errorf("expected templated Authorization header, got %q", gotAuth)
+	}
+	if got := callCtx.GetVariable("customer_name"); got != "Acme" {
+		t.Errorf("expected customer_name %q, got %q", "Acme", got)
+	}
+	if got := callCtx.GetVariable("first_agent"); got != "101" {
+		t.Errorf("expected first_agent %q, got %q", "101", got)
+	}
+	if got := callCtx.GetVariable("webhook_status"); got != "200" {
+		t.Errorf("expected webhook_status %q, got %q", "200", got)
+	}
+}
+
+func TestWebhookStatusClassEdges(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, "2xx"},
+		{http.StatusNoContent, "2xx"},
+		{http.StatusNotFound, "4xx"},
+		{http.StatusServiceUnavailable, "5xx"},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+
+		node := makeWebhookNode(map[string]any{
+			"url":          srv.URL,
+			"branch_field": "route",
+		})
+		callCtx := flow.NewCallContext("test-webhook-2", "", "100", "200", nil, 0, nil, nil)
+
+		edge, err := newTestWebhookHandler().Execute(context.Background(), callCtx, node)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("status %d: unexpected error: %v", tt.status, err)
+		}
+		if edge != tt.want {
+			t.Errorf("status %d: expected edge %q, got %q", tt.status, tt.want, edge)
+		}
+	}
+}
+
+func TestWebhookGetQueryParams(t *testing.T) {
+	var gotQuery map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("expected GET, got %s", r.Method)
+		}
+		gotQuery = r.URL.Query()
+	}))
+	defer srv.Close()
+
+	node := makeWebhookNode(map[string]any{
+		"url":     srv.URL + "/lookup?key=1",
+		"method":  "get",
+		"payload": map[string]any{"number": "{{.CallerIDNum}}"},
+	})
+	callCtx := flow.NewCallContext("test-webhook-3", "", "0299998888", "100", nil, 0, nil, nil)
+
+	edge, err := newTestWebhookHandler().Execute(context.Background(), callCtx, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "2xx" {
+		t.Errorf("expected edge %q, got %q", "2xx", edge)
+	}
+	if gotQuery["number"][0] != "0299998888" || gotQuery["key"][0] != "1" {
+		t.Errorf("unexpected query: %v", gotQuery)
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	node := makeWebhookNode(map[string]any{
+		"url":     srv.URL,
+		"timeout": float64(1),
+	})
+	callCtx := flow.NewCallContext("test-webhook-4", "", "100", "200", nil, 0, nil, nil)
+
+	edge, err := newTestWebhookHandler().Execute(context.Background(), callCtx, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "timeout" {
+		t.Errorf("expected edge %q, got %q", "timeout", edge)
+	}
+}
+
+func TestWebhookConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	node := makeWebhookNode(map[string]any{"url": url})
+	callCtx := flow.NewCallContext("test-webhook-5", "", "100", "200", nil, 0, nil, nil)
+
+	edge, err := newTestWebhookHandler().Execute(context.Background(), callCtx, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edge != "error" {
+		t.Errorf("expected edge %q, got %q", "error", edge)
+	}
+}
+
+func TestWebhookMissingURL(t *testing.T) {
+	callCtx := flow.NewCallContext("test-webhook-6", "", "100", "200", nil, 0, nil, nil)
+
+	_, err := newTestWebhookHandler().Execute(context.Background(), callCtx, makeWebhookNode(map[string]any{}))
+	if err == nil {
+		t.Fatal("expected error for missing url, got nil")
+	}
+}
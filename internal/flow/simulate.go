@@ -0,0 +1,478 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// maxSimulatedSteps bounds the number of nodes a simulated call may visit,
+// so that a loop in the graph ends the dry run rather than spinning.
+const maxSimulatedSteps = 200
+
+// ErrNotSimulator is returned by Simulate on an engine that routes real calls.
+var ErrNotSimulator = errors.New("flow engine is not a simulator")
+
+// ErrSimulationStepLimit is returned when a simulated call visits more than
+// maxSimulatedSteps nodes, which usually means the graph loops.
+var ErrSimulationStepLimit = errors.New("simulated call visited too many nodes")
+
+// simulationSeq numbers simulated calls to give each a unique call ID.
+var simulationSeq atomic.Uint64
+
+// SimulatedCall scripts the caller of a flow simulation (a dry run of a
+// flow graph in which nothing is dialled, played or stored).
+type SimulatedCall struct {
+	// FlowID is the flow being simulated, used to detect sub-flows that
+	// loop back into it. Zero for an unsaved graph.
+	FlowID int64
+
+	CallerIDName string
+	CallerIDNum  string
+
+	// DID is the number dialled and InboundNumber its record, if any.
+	DID           string
+	InboundNumber *models.InboundNumber
+
+	// Time is the moment the call arrives. Time switches are evaluated at
+	// it. The zero time means now.
+	Time time.Time
+
+	// DTMF lists the digits the caller enters at each prompt, in order.
+	// An empty entry, or a prompt after the list runs out, times out.
+	DTMF []string
+
+	// Answer lists the extensions and external numbers that answer when
+	// rung. All others ring out; extensions in Do Not Disturb never answer.
+	Answer []string
+
+	// Webhooks holds the responses of webhook nodes by node ID. A webhook
+	// node without one gets an empty 200 response.
+	Webhooks map[string]SimulatedWebhook
+}
+
+// SimulatedWebhook is the scripted response to a webhook node's request.
+type SimulatedWebhook struct {
+	Status   int
+	Body     string
+	TimedOut bool // the request times out, following the "timeout" edge
+}
+
+// SimulationOutcome is how a simulated call ended.
+type SimulationOutcome string
+
+const (
+	// OutcomeAnswered means an extension or follow-me number answered.
+	OutcomeAnswered SimulationOutcome = "answered"
+	// OutcomeVoicemail means the caller was sent to leave a message.
+	OutcomeVoicemail SimulationOutcome = "voicemail"
+	// OutcomeTransferred means the call was blind transferred.
+	OutcomeTransferred SimulationOutcome = "transferred"
+	// OutcomeConference means the caller joined a conference bridge.
+	OutcomeConference SimulationOutcome = "conference"
+	// OutcomeHangup means a node hung up the call.
+	OutcomeHangup SimulationOutcome = "hangup"
+	// OutcomeEnded means the flow finished without ending the call itself.
+	OutcomeEnded SimulationOutcome = "ended"
+	// OutcomeDeadEnd means a node's output handle has no edge, so a live
+	// call would be dropped there.
+	OutcomeDeadEnd SimulationOutcome = "dead_end"
+	// OutcomeError means a node failed.
+	OutcomeError SimulationOutcome = "error"
+)
+
+// SimulatedAction is a SIP operation a node performed on a simulated call.
+type SimulatedAction struct {
+	NodeID string `json:"node_id"`
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SimulationResult is the outcome of a flow simulation. NodeID is the node
+// at which the call ended and Detail says how, e.g. which extension
+// answered. Error is set if the walk stopped on an error.
+type SimulationResult struct {
+	FlowPath  []string          `json:"flow_path"`
+	Variables map[string]string `json:"variables"`
+	Actions   []SimulatedAction `json:"actions"`
+	Outcome   SimulationOutcome `json:"outcome"`
+	NodeID    string            `json:"node_id,omitempty"`
+	Detail    string            `json:"detail,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// simulation is the state of a simulated call, shared by the node handlers
+// through its CallContext.
+type simulation struct {
+	call   SimulatedCall
+	answer map[string]bool
+
+	mu      sync.Mutex
+	dtmf    int
+	actions []SimulatedAction
+	outcome SimulationOutcome
+	nodeID  string
+	detail  string
+}
+
+// record logs a SIP operation performed by the current node.
+func (s *simulation) record(callCtx *CallContext, action, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, SimulatedAction{NodeID: currentNode(callCtx), Action: action, Detail: detail})
+}
+
+// end records how the call ended. Only the first outcome counts: once a
+// call is answered, later nodes cannot change how it was handled.
+func (s *simulation) end(callCtx *CallContext, outcome SimulationOutcome, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.outcome != "" {
+		return
+	}
+	s.outcome = outcome
+	s.nodeID = currentNode(callCtx)
+	s.detail = detail
+}
+
+// nextDTMF returns the caller's input at the next prompt.
+func (s *simulation) nextDTMF() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dtmf >= len(s.call.DTMF) {
+		return ""
+	}
+	d := s.call.DTMF[s.dtmf]
+	s.dtmf++
+	return d
+}
+
+// result builds the simulation result once the walk has stopped with err.
+func (s *simulation) result(callCtx *CallContext, err error) *SimulationResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := &SimulationResult{
+		FlowPath:  callCtx.GetFlowPath(),
+		Variables: callCtx.GetVariables(),
+		Actions:   append([]SimulatedAction{}, s.actions...),
+		Outcome:   s.outcome,
+		NodeID:    s.nodeID,
+		Detail:    s.detail,
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNoMatchingEdge) && s.outcome != "":
+		// The call had already ended, e.g. an answered extension with
+		// nothing connected to its "answered" handle.
+	case errors.Is(err, ErrNoMatchingEdge):
+		res.Outcome = OutcomeDeadEnd
+		res.NodeID = currentNode(callCtx)
+		res.Error = err.Error()
+	default:
+		if res.Outcome == "" {
+			res.Outcome = OutcomeError
+			res.NodeID = currentNode(callCtx)
+		}
+		res.Error = err.Error()
+	}
+	if res.Outcome == "" {
+		res.Outcome = OutcomeEnded
+		res.NodeID = currentNode(callCtx)
+	}
+	return res
+}
+
+// currentNode returns the ID of the node being executed.
+func currentNode(callCtx *CallContext) string {
+	path := callCtx.GetFlowPath()
+	if len(path) == 0 {
+		return ""
+	}
+	return path[len(path)-1]
+}
+
+// NewSimulator creates a flow engine for dry runs with Simulate. Its node
+// handlers must be registered with SimulatedSIPActions so that nothing is
+// dialled; handlers skip their other side effects on simulated calls.
+// Sub-flow nodes run the published revisions of the flows they reference
+// in flows, which may be nil if the graph has none.
+func NewSimulator(flows database.CallFlowRepository, resolver EntityResolver, logger *slog.Logger) *Engine {
+	return &Engine{
+		flows:     flows,
+		handlers:  make(map[string]NodeHandler),
+		resolver:  resolver,
+		logger:    logger.With("subsystem", "flow_simulator"),
+		simulator: true,
+	}
+}
+
+// Simulate walks graph from entryNodeID for a scripted caller and reports
+// the nodes visited, the variables set, the SIP operations performed and
+// how the call ended. A failing node ends the walk and is reported in the
+// result rather than returned as an error.
+func (e *Engine) Simulate(ctx context.Context, graph *FlowGraph, entryNodeID string, call SimulatedCall) (*SimulationResult, error) {
+	if !e.simulator {
+		return nil, ErrNotSimulator
+	}
+
+	nodeMap := make(map[string]Node, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		nodeMap[n.ID] = n
+	}
+	entryNode, ok := nodeMap[entryNodeID]
+	if !ok {
+		return nil, ErrEntryNodeNotFound
+	}
+
+	if call.Time.IsZero() {
+		call.Time = time.Now()
+	}
+	sim := &simulation{call: call, answer: make(map[string]bool, len(call.Answer))}
+	for _, a := range call.Answer {
+		sim.answer[a] = true
+	}
+
+	callID := fmt.Sprintf("simulated-%d", simulationSeq.Add(1))
+	callCtx := NewCallContext(callID, call.CallerIDName, call.CallerIDNum, call.DID, call.InboundNumber, 0, nil, nil)
+	callCtx.StartTime = call.Time
+	callCtx.sim = sim
+	callCtx.flows = []int64{call.FlowID}
+
+	e.logger.Debug("starting flow simulation",
+		"call_id", callID,
+		"flow_id", call.FlowID,
+		"entry_node", entryNodeID,
+	)
+
+	err := e.walkGraph(ctx, callCtx, entryNode, nodeMap, graph.Edges)
+	return sim.result(callCtx, err), nil
+}
+
+// SimulatedSIPActions implements SIPActions for the flow simulator. Instead
+// of signalling, it records each operation on the simulated call and
+// answers from the caller's script. It must only be used with calls run by
+// Simulate.
+type SimulatedSIPActions struct{}
+
+var _ SIPActions = SimulatedSIPActions{}
+
+// errNotSimulatedCall is returned when SimulatedSIPActions is given a real call.
+var errNotSimulatedCall = errors.New("simulated sip actions used on a real call")
+
+// state returns the simulation of a call.
+func (SimulatedSIPActions) state(callCtx *CallContext) (*simulation, error) {
+	if callCtx.sim == nil {
+		return nil, errNotSimulatedCall
+	}
+	return callCtx.sim, nil
+}
+
+// RingExtension answers if the extension is in the script's answer list.
+func (a SimulatedSIPActions) RingExtension(_ context.Context, callCtx *CallContext, ext *models.Extension, _ int) (*RingResult, error) {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ext.DND:
+		s.record(callCtx, "ring_extension", ext.Extension+": do not disturb")
+		return &RingResult{DND: true}, nil
+	case s.answer[ext.Extension]:
+		s.record(callCtx, "ring_extension", ext.Extension+": answered")
+		s.end(callCtx, OutcomeAnswered, "extension "+ext.Extension)
+		return &RingResult{Answered: true}, nil
+	}
+	s.record(callCtx, "ring_extension", ext.Extension+": no answer")
+	return &RingResult{}, nil
+}
+
+// RingGroup answers with the first extension in the script's answer list.
+func (a SimulatedSIPActions) RingGroup(_ context.Context, callCtx *CallContext, extensions []*models.Extension, _ int) (*RingResult, error) {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		if ext.DND {
+			continue
+		}
+		numbers = append(numbers, ext.Extension)
+	}
+	for _, n := range numbers {
+		if s.answer[n] {
+			s.record(callCtx, "ring_group", strings.Join(numbers, ",")+": answered by "+n)
+			s.end(callCtx, OutcomeAnswered, "extension "+n)
+			return &RingResult{Answered: true}, nil
+		}
+	}
+	s.record(callCtx, "ring_group", strings.Join(numbers, ",")+": no answer")
+	return &RingResult{AllBusy: len(numbers) == 0 && len(extensions) > 0}, nil
+}
+
+// PlayAndCollect returns the caller's next scripted input. A prompt that
+// collects nothing (no timeout and no digits) only plays.
+func (a SimulatedSIPActions) PlayAndCollect(_ context.Context, callCtx *CallContext, prompt string, isTTS bool, timeout int, _ int, maxDigits int) (*CollectResult, error) {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return nil, err
+	}
+	if isTTS {
+		prompt = "tts: " + prompt
+	}
+	if timeout == 0 && maxDigits == 0 {
+		s.record(callCtx, "play", prompt)
+		return &CollectResult{}, nil
+	}
+
+	digits := s.nextDTMF()
+	if maxDigits <= 0 {
+		maxDigits = 1
+	}
+	if len(digits) > maxDigits {
+		digits = digits[:maxDigits]
+	}
+	if digits == "" {
+		s.record(callCtx, "collect", prompt+": timed out")
+		return &CollectResult{TimedOut: true}, nil
+	}
+	s.record(callCtx, "collect", prompt+": "+digits)
+	return &CollectResult{Digits: digits}, nil
+}
+
+// AnswerCall records the PBX answering the call.
+func (a SimulatedSIPActions) AnswerCall(_ context.Context, callCtx *CallContext) error {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return err
+	}
+	s.record(callCtx, "answer", "")
+	return nil
+}
+
+// RecordMessage sends the caller to voicemail without recording anything.
+func (a SimulatedSIPActions) RecordMessage(_ context.Context, callCtx *CallContext, greeting string, _ int, filePath string) (*RecordResult, error) {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return nil, err
+	}
+	s.record(callCtx, "record_message", greeting)
+	s.end(callCtx, OutcomeVoicemail, "")
+	return &RecordResult{FilePath: filePath}, nil
+}
+
+// SendMWI does nothing.
+func (SimulatedSIPActions) SendMWI(context.Context, *models.Extension, int, int) error {
+	return nil
+}
+
+// VoicemailReceived does nothing.
+func (SimulatedSIPActions) VoicemailReceived(context.Context, *models.VoicemailBox, *models.VoicemailMessage) {
+}
+
+// HangupCall ends the call with the given cause.
+func (a SimulatedSIPActions) HangupCall(_ context.Context, callCtx *CallContext, cause int, reason string) error {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return err
+	}
+	detail := fmt.Sprintf("%d %s", cause, reason)
+	s.record(callCtx, "hangup", detail)
+	s.end(callCtx, OutcomeHangup, detail)
+	return nil
+}
+
+// BlindTransfer ends the call with a transfer to destination.
+func (a SimulatedSIPActions) BlindTransfer(_ context.Context, callCtx *CallContext, destination string) error {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return err
+	}
+	s.record(callCtx, "transfer", destination)
+	s.end(callCtx, OutcomeTransferred, destination)
+	return nil
+}
+
+// JoinConference joins the caller to the bridge, who leaves at once.
+func (a SimulatedSIPActions) JoinConference(_ context.Context, callCtx *CallContext, bridge *models.ConferenceBridge) error {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return err
+	}
+	s.record(callCtx, "conference", bridge.Name)
+	s.end(callCtx, OutcomeConference, bridge.Name)
+	return nil
+}
+
+// RingFollowMe answers with the first number in the script's answer list.
+func (a SimulatedSIPActions) RingFollowMe(_ context.Context, callCtx *CallContext, numbers []models.FollowMeNumber, _ string, _ string, _ bool) (*RingResult, error) {
+	return a.ringFollowMe(callCtx, numbers)
+}
+
+// RingFollowMeSimultaneous answers with the first number in the script's
+// answer list.
+func (a SimulatedSIPActions) RingFollowMeSimultaneous(_ context.Context, callCtx *CallContext, numbers []models.FollowMeNumber, _ string, _ string, _ bool) (*RingResult, error) {
+	return a.ringFollowMe(callCtx, numbers)
+}
+
+func (a SimulatedSIPActions) ringFollowMe(callCtx *CallContext, numbers []models.FollowMeNumber) (*RingResult, error) {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range numbers {
+		if s.answer[n.Number] {
+			s.record(callCtx, "ring_follow_me", n.Number+": answered")
+			s.end(callCtx, OutcomeAnswered, "follow-me "+n.Number)
+			return &RingResult{Answered: true}, nil
+		}
+		s.record(callCtx, "ring_follow_me", n.Number+": no answer")
+	}
+	return &RingResult{}, nil
+}
+
+// StartEarlyMedia records early media starting.
+func (a SimulatedSIPActions) StartEarlyMedia(_ context.Context, callCtx *CallContext) error {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return err
+	}
+	s.record(callCtx, "early_media", "")
+	return nil
+}
+
+// PlayPrompt records the prompt being played.
+func (a SimulatedSIPActions) PlayPrompt(_ context.Context, callCtx *CallContext, filePath string) error {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return err
+	}
+	s.record(callCtx, "play", filePath)
+	return nil
+}
+
+// PlayHoldMusic records hold music starting and waits until ctx is
+// cancelled, as the real music does.
+func (a SimulatedSIPActions) PlayHoldMusic(ctx context.Context, callCtx *CallContext, classID *int64, filePath string) error {
+	s, err := a.state(callCtx)
+	if err != nil {
+		return err
+	}
+	detail := filePath
+	if classID != nil {
+		detail = fmt.Sprintf("class %d", *classID)
+	}
+	s.record(callCtx, "hold_music", detail)
+	<-ctx.Done()
+	return nil
+}
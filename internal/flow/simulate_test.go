@@ -0,0 +1,90 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// edgeHandler returns a fixed output edge.
+type edgeHandler string
+
+func (h edgeHandler) Execute(_ context.Context, _ *CallContext, _ Node) (string, error) {
+	return string(h), nil
+}
+
+// hangupHandler hangs up through the engine's SIP actions.
+type hangupHandler struct{ sip SIPActions }
+
+func (h hangupHandler) Execute(ctx context.Context, callCtx *CallContext, _ Node) (string, error) {
+	return "", h.sip.HangupCall(ctx, callCtx, 486, "Busy Here")
+}
+
+func newTestSimulator() *Engine {
+	e := NewSimulator(nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	e.RegisterHandler("step", edgeHandler("next"))
+	e.RegisterHandler("hangup", hangupHandler{sip: SimulatedSIPActions{}})
+	return e
+}
+
+func TestSimulateHangup(t *testing.T) {
+	graph := &FlowGraph{
+		Nodes: []Node{{ID: "a", Type: "step"}, {ID: "b", Type: "hangup"}},
+		Edges: []Edge{{ID: "e1", Source: "a", Target: "b", SourceHandle: "next"}},
+	}
+
+	res, err := newTestSimulator().Simulate(context.Background(), graph, "a", SimulatedCall{CallerIDNum: "0400000000"})
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	if res.Outcome != OutcomeHangup || res.NodeID != "b" || res.Detail != "486 Busy Here" {
+		t.Errorf("outcome = %s at %s (%s), want hangup at b", res.Outcome, res.NodeID, res.Detail)
+	}
+	if len(res.FlowPath) != 2 || len(res.Actions) != 1 || res.Actions[0].NodeID != "b" {
+		t.Errorf("path = %v, actions = %+v", res.FlowPath, res.Actions)
+	}
+}
+
+func TestSimulateDeadEnd(t *testing.T) {
+	graph := &FlowGraph{Nodes: []Node{{ID: "a", Type: "step"}}}
+
+	res, err := newTestSimulator().Simulate(context.Background(), graph, "a", SimulatedCall{})
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	if res.Outcome != OutcomeDeadEnd || res.NodeID != "a" || res.Error == "" {
+		t.Errorf("result = %+v, want dead end at a", res)
+	}
+}
+
+func TestSimulateLoopStops(t *testing.T) {
+	graph := &FlowGraph{
+		Nodes: []Node{{ID: "a", Type: "step"}, {ID: "b", Type: "step"}},
+		Edges: []Edge{
+			{ID: "e1", Source: "a", Target: "b", SourceHandle: "next"},
+			{ID: "e2", Source: "b", Target: "a", SourceHandle: "next"},
+		},
+	}
+
+	res, err := newTestSimulator().Simulate(context.Background(), graph, "a", SimulatedCall{})
+	if err != nil {
+		t.Fatalf("Simulate() error: %v", err)
+	}
+	if res.Outcome != OutcomeError || len(res.FlowPath) != maxSimulatedSteps {
+		t.Errorf("outcome = %s after %d nodes, want error after %d", res.Outcome, len(res.FlowPath), maxSimulatedSteps)
+	}
+}
+
+func TestSimulateRequiresSimulator(t *testing.T) {
+	e := NewEngine(nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	graph := &FlowGraph{Nodes: []Node{{ID: "a", Type: "step"}}}
+	if _, err := e.Simulate(context.Background(), graph, "a", SimulatedCall{}); !errors.Is(err, ErrNotSimulator) {
+		t.Errorf("Simulate() on a call routing engine: err = %v, want ErrNotSimulator", err)
+	}
+
+	if _, err := newTestSimulator().Simulate(context.Background(), graph, "missing", SimulatedCall{}); !errors.Is(err, ErrEntryNodeNotFound) {
+		t.Errorf("Simulate() with missing entry node: err = %v, want ErrEntryNodeNotFound", err)
+	}
+}
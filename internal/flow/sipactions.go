@@ -2,10 +2,15 @@ package flow
 
 import (
 	"context"
+	"errors"
 
 	"github.com/flowpbx/flowpbx/internal/database/models"
 )
 
+// ErrCallerHungUp is returned by SIPActions methods when the caller abandons
+// the call (CANCEL or BYE) while the operation is in progress.
+var ErrCallerHungUp = errors.New("caller hung up")
+
 // RingResult describes the outcome of ringing an extension or set of extensions.
 type RingResult struct {
 	// Answered is true if a device answered the call.
@@ -65,6 +70,12 @@ type SIPActions interface {
 	// The terminator character (e.g. "#") ends collection early if pressed.
 	PlayAndCollect(ctx context.Context, callCtx *CallContext, prompt string, isTTS bool, timeout int, digitTimeout int, maxDigits int) (*CollectResult, error)
 
+	// AnswerCall answers the call with 200 OK so the PBX itself becomes the
+	// far end (e.g. for voicemail), keeping the caller-facing RTP stream
+	// used for early media. Prompts and digit collection continue to work
+	// on the answered call. Calling it again for the same call is a no-op.
+	AnswerCall(ctx context.Context, callCtx *CallContext) error
+
 	// RecordMessage plays a greeting prompt and then records the caller's
 	// message to a WAV file. Recording stops when the caller hangs up,
 	// presses '#', or the maxDuration (seconds) is reached. The filePath
@@ -76,6 +87,10 @@ type SIPActions interface {
 	// and oldMessages indicate the counts for the mailbox summary.
 	SendMWI(ctx context.Context, ext *models.Extension, newMessages int, oldMessages int) error
 
+	// VoicemailReceived reports a message that has just been stored in a
+	// voicemail box, e.g. to the real-time event stream.
+	VoicemailReceived(ctx context.Context, box *models.VoicemailBox, msg *models.VoicemailMessage)
+
 	// HangupCall terminates the call with the given SIP cause code and
 	// reason phrase. For answered calls this sends BYE; for unanswered
 	// calls this sends the appropriate error response.
@@ -105,4 +120,22 @@ type SIPActions interface {
 	// must press "1" before being bridged. Returns a RingResult indicating
 	// whether any external number answered.
 	RingFollowMeSimultaneous(ctx context.Context, callCtx *CallContext, numbers []models.FollowMeNumber, callerIDName string, callerIDNum string, confirm bool) (*RingResult, error)
+
+	// StartEarlyMedia sends 183 Session Progress with SDP so the caller
+	// can hear audio before the call is answered. The caller-facing RTP
+	// stream is reused when a later RingExtension or RingGroup is answered.
+	// Calling it again for the same call is a no-op.
+	StartEarlyMedia(ctx context.Context, callCtx *CallContext) error
+
+	// PlayPrompt plays an audio file to the caller once, starting early
+	// media first if necessary. It returns when playback completes or ctx
+	// is cancelled, or ErrCallerHungUp if the caller abandons the call.
+	PlayPrompt(ctx context.Context, callCtx *CallContext, filePath string) error
+
+	// PlayHoldMusic plays the music on hold class to the caller, or loops
+	// the audio file if classID is nil or the class has no prompts, until
+	// ctx is cancelled or the call is answered by another leg, starting
+	// early media first if necessary. Returns ErrCallerHungUp if the caller
+	// abandons the call.
+	PlayHoldMusic(ctx context.Context, callCtx *CallContext, classID *int64, filePath string) error
 }
@@ -0,0 +1,99 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is the data available to node config templates such as
+// webhook URLs, prompt text and transfer destinations. Templates use Go
+// text/template syntax, e.g. "{{.CallerIDNum}}" or "{{.Variables.account}}".
+type TemplateData struct {
+	CallID       string
+	CallerIDName string
+	CallerIDNum  string
+	Callee       string
+	DID          string
+	DTMF         string
+	Variables    map[string]string
+}
+
+// NewTemplateData captures the current state of a call for rendering
+// templates.
+func NewTemplateData(callCtx *CallContext) TemplateData {
+	return TemplateData{
+		CallID:       callCtx.CallID,
+		CallerIDName: callCtx.CallerIDName,
+		CallerIDNum:  callCtx.CallerIDNum,
+		Callee:       callCtx.Callee,
+		DID:          callDID(callCtx),
+		DTMF:         callCtx.GetDTMF(),
+		Variables:    callCtx.GetVariables(),
+	}
+}
+
+// templateFuncs are the functions available to templates. The json
+// function quotes a value for use in a raw JSON body:
+// {"from": {{json .CallerIDNum}}}.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// RenderTemplate executes text as a template against data. Text without
+// "{{" is returned unchanged. Missing variables render as empty strings.
+func RenderTemplate(name, text string, data TemplateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Interpolate renders text as a template against the current state of the
+// call.
+func Interpolate(callCtx *CallContext, name, text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	return RenderTemplate(name, text, NewTemplateData(callCtx))
+}
+
+// CheckTemplate reports whether text is a valid template, without
+// rendering it.
+func CheckTemplate(name, text string) error {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+	_, err := parseTemplate(name, text)
+	return err
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// callDID returns the number the caller dialled: the matched inbound
+// number, or the Request-URI user when the call did not arrive on a DID.
+func callDID(callCtx *CallContext) string {
+	if callCtx.InboundNumber != nil {
+		return callCtx.InboundNumber.Number
+	}
+	return callCtx.Callee
+}
@@ -3,6 +3,13 @@ package flow
 import (
 	"context"
 	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
 )
 
 // ValidationSeverity indicates the severity of a validation issue.
@@ -31,11 +38,14 @@ type ValidationResult struct {
 // Validator checks a flow graph for structural and referential integrity.
 type Validator struct {
 	resolver EntityResolver
+	flows    database.CallFlowRepository
 }
 
-// NewValidator creates a new flow graph validator.
-func NewValidator(resolver EntityResolver) *Validator {
-	return &Validator{resolver: resolver}
+// NewValidator creates a new flow graph validator. Entity references are
+// only checked if resolver is non-nil, and sub-flow references only if
+// flows is non-nil.
+func NewValidator(resolver EntityResolver, flows database.CallFlowRepository) *Validator {
+	return &Validator{resolver: resolver, flows: flows}
 }
 
 // Validate checks the flow graph for common issues:
@@ -43,8 +53,15 @@ func NewValidator(resolver EntityResolver) *Validator {
 //   - Nodes with no outgoing edges (dead ends that aren't terminal types)
 //   - Missing entity references (entity_id points to a non-existent record)
 //   - Orphan edges (edges referencing non-existent nodes)
+//   - Invalid node-specific configuration (e.g. webhook without a URL)
+//   - Expressions and templates that do not parse
+//   - Sub-flow nodes entering unpublished flows or missing entry nodes
+//   - Sub-flows that form a cycle
 //   - Empty graph
-func (v *Validator) Validate(ctx context.Context, graph *FlowGraph, entryNodeID string) *ValidationResult {
+//
+// flowID is the ID of the flow the graph belongs to, or 0 for an unsaved
+// graph; sub-flow cycles are traced through it.
+func (v *Validator) Validate(ctx context.Context, flowID int64, graph *FlowGraph, entryNodeID string) *ValidationResult {
 	result := &ValidationResult{Valid: true, Issues: []ValidationIssue{}}
 
 	if len(graph.Nodes) == 0 {
@@ -104,6 +121,8 @@ func (v *Validator) Validate(ctx context.Context, graph *FlowGraph, entryNodeID
 	terminalTypes := map[string]bool{
 		"hangup":    true,
 		"voicemail": true,
+		"return":    true,
+		"sub_flow":  true, // without edges, a goto into the other flow
 	}
 
 	// Check for disconnected and dead-end nodes.
@@ -129,6 +148,11 @@ func (v *Validator) Validate(ctx context.Context, graph *FlowGraph, entryNodeID
 		}
 	}
 
+	// Validate node-specific configuration.
+	for _, node := range graph.Nodes {
+		result.Issues = append(result.Issues, validateNodeConfig(node)...)
+	}
+
 	// Validate entity references.
 	if v.resolver != nil {
 		for _, node := range graph.Nodes {
@@ -157,6 +181,11 @@ func (v *Validator) Validate(ctx context.Context, graph *FlowGraph, entryNodeID
 		}
 	}
 
+	// Validate sub-flow references.
+	if v.flows != nil {
+		result.Issues = append(result.Issues, v.validateSubFlows(ctx, flowID, graph)...)
+	}
+
 	// If any issues are errors, mark the result as invalid.
 	for _, issue := range result.Issues {
 		if issue.Severity == SeverityError {
@@ -167,3 +196,309 @@ func (v *Validator) Validate(ctx context.Context, graph *FlowGraph, entryNodeID
 
 	return result
 }
+
+// validateNodeConfig checks the config of node types that cannot run
+// without specific settings.
+func validateNodeConfig(node Node) []ValidationIssue {
+	var issues []ValidationIssue
+	errorf := func(format string, args ...any) {
+		issues = append(issues, ValidationIssue{
+			Severity: SeverityError,
+			NodeID:   node.ID,
+			Message:  fmt.Sprintf("node %q: ", node.Data.Label) + fmt.Sprintf(format, args...),
+		})
+	}
+	checkTemplate := func(name, text string) {
+		if err := CheckTemplate(name, text); err != nil {
+			errorf("%v", err)
+		}
+	}
+
+	switch node.Type {
+	case "webhook":
+		rawURL, _ := node.Data.Config["url"].(string)
+		if strings.TrimSpace(rawURL) == "" {
+			errorf("webhook url is required")
+		} else if !strings.Contains(rawURL, "{{") {
+			u, err := url.Parse(rawURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errorf("webhook url %q must be an absolute http or https url", rawURL)
+			}
+		}
+		if m, ok := node.Data.Config["method"].(string); ok && m != "" {
+			if m = strings.ToUpper(m); m != "GET" && m != "POST" {
+				errorf("webhook method %q is not supported (use GET or POST)", m)
+			}
+		}
+		checkTemplate("url", rawURL)
+		if headers, ok := node.Data.Config["headers"].(map[string]any); ok {
+			for name, v := range headers {
+				text, _ := v.(string)
+				checkTemplate("header "+name, text)
+			}
+		}
+		checkPayloadTemplates(node.Data.Config["payload"], checkTemplate)
+
+	case "play_message":
+		for _, key := range []string{"file", "tts"} {
+			text, _ := node.Data.Config[key].(string)
+			checkTemplate(key, text)
+		}
+
+	case "transfer":
+		dest, _ := node.Data.Config["destination"].(string)
+		checkTemplate("destination", dest)
+
+	case "condition":
+		cases, _ := node.Data.Config["cases"].([]any)
+		if len(cases) == 0 {
+			errorf("condition has no cases")
+		}
+		labels := make(map[string]bool, len(cases))
+		for i, item := range cases {
+			c, _ := item.(map[string]any)
+			label, _ := c["label"].(string)
+			switch {
+			case label == "":
+				errorf("case %d has no label", i+1)
+			case label == "default":
+				errorf("case label %q is reserved for the edge taken when no case matches", label)
+			case labels[label]:
+				errorf("case label %q is used more than once", label)
+			}
+			labels[label] = true
+			src, _ := c["expression"].(string)
+			if _, err := ParseExpr(src); err != nil {
+				errorf("case %q: %v", label, err)
+			}
+		}
+
+	case "directory":
+		if by, ok := node.Data.Config["search_by"].(string); ok && by != "" && by != "first" && by != "last" {
+			errorf("directory search_by %q must be \"first\" or \"last\"", by)
+		}
+		if n, ok := node.Data.Config["letters"].(float64); ok && (n < 1 || n > 10) {
+			errorf("directory letters must be between 1 and 10")
+		}
+
+	case "sub_flow":
+		if id, ok := node.Data.Config["flow_id"].(float64); !ok || id < 1 || id != math.Trunc(id) {
+			errorf("sub-flow has no flow selected")
+		}
+		if entry, _ := node.Data.Config["entry_node"].(string); entry == "" {
+			errorf("sub-flow has no entry node")
+		}
+
+	case "return":
+		if exit, _ := node.Data.Config["exit"].(string); exit == "" {
+			errorf("return has no exit name")
+		}
+
+	case "set_variable":
+		vars, _ := node.Data.Config["variables"].([]any)
+		if len(vars) == 0 {
+			errorf("no variables to set")
+		}
+		for _, item := range vars {
+			v, _ := item.(map[string]any)
+			name, _ := v["name"].(string)
+			if err := ValidateVariableName(name); err != nil {
+				errorf("%v", err)
+				continue
+			}
+			src, _ := v["expression"].(string)
+			if _, err := ParseExpr(src); err != nil {
+				errorf("variable %q: %v", name, err)
+			}
+		}
+	}
+
+	return issues
+}
+
+// subFlowRef returns the flow and entry node a sub-flow node enters, or a
+// zero flow ID if its config is incomplete.
+func subFlowRef(node Node) (int64, string) {
+	id, _ := node.Data.Config["flow_id"].(float64)
+	entry, _ := node.Data.Config["entry_node"].(string)
+	if id < 1 || id != math.Trunc(id) || entry == "" {
+		return 0, ""
+	}
+	return int64(id), entry
+}
+
+// publishedFlow is a flow entered by a sub-flow node, as loaded by
+// validateSubFlows.
+type publishedFlow struct {
+	flow  *models.CallFlow // nil if not found or not published
+	graph *FlowGraph       // nil if flow is nil or its graph does not parse
+	issue string           // why the flow cannot be entered
+}
+
+// validateSubFlows checks that every sub-flow node enters an existing node
+// of a published flow, warns about exits of the sub-flow that the node has
+// no edge for, and reports sub-flows that lead back into a flow the call is
+// already in. Calls are checked against published revisions, as that is
+// what live calls run; the graph being validated stands in for flowID.
+func (v *Validator) validateSubFlows(ctx context.Context, flowID int64, graph *FlowGraph) []ValidationIssue {
+	var issues []ValidationIssue
+
+	loaded := make(map[int64]*publishedFlow)
+	load := func(id int64) *publishedFlow {
+		if pf, ok := loaded[id]; ok {
+			return pf
+		}
+		pf := &publishedFlow{}
+		loaded[id] = pf
+		f, err := v.flows.GetPublished(ctx, id)
+		switch {
+		case err != nil:
+			pf.issue = fmt.Sprintf("failed to load flow %d: %v", id, err)
+		case f == nil:
+			if draft, err := v.flows.GetByID(ctx, id); err == nil && draft != nil {
+				pf.issue = fmt.Sprintf("flow %q is not published", draft.Name)
+			} else {
+				pf.issue = fmt.Sprintf("flow %d not found", id)
+			}
+		default:
+			pf.flow = f
+			if pf.graph, err = ParseFlowGraph(f.FlowData); err != nil {
+				pf.issue = fmt.Sprintf("flow %q: %v", f.Name, err)
+			}
+		}
+		return pf
+	}
+	flowName := func(id int64) string {
+		if pf, ok := loaded[id]; ok && pf.flow != nil {
+			return fmt.Sprintf("%q", pf.flow.Name)
+		}
+		if f, err := v.flows.GetByID(ctx, id); err == nil && f != nil {
+			return fmt.Sprintf("%q", f.Name)
+		}
+		return fmt.Sprintf("flow %d", id)
+	}
+
+	// findCycle walks the sub-flows entered from flow id and returns the
+	// first chain of flows that leads back to a flow on path.
+	noCycle := make(map[int64]bool)
+	var findCycle func(id int64, path []int64) []int64
+	findCycle = func(id int64, path []int64) []int64 {
+		if i := slices.Index(path, id); i >= 0 {
+			return append(slices.Clone(path[i:]), id)
+		}
+		if noCycle[id] {
+			return nil
+		}
+		g := graph
+		if id != flowID {
+			if g = load(id).graph; g == nil {
+				return nil
+			}
+		}
+		path = append(path, id)
+		for _, n := range g.Nodes {
+			if n.Type != "sub_flow" {
+				continue
+			}
+			if target, _ := subFlowRef(n); target != 0 {
+				if cycle := findCycle(target, path); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		noCycle[id] = true
+		return nil
+	}
+
+	reported := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		if node.Type != "sub_flow" {
+			continue
+		}
+		target, entry := subFlowRef(node)
+		if target == 0 {
+			continue // reported by validateNodeConfig
+		}
+		errorf := func(format string, args ...any) {
+			issues = append(issues, ValidationIssue{
+				Severity: SeverityError,
+				NodeID:   node.ID,
+				Message:  fmt.Sprintf("node %q: ", node.Data.Label) + fmt.Sprintf(format, args...),
+			})
+		}
+
+		if target == flowID {
+			errorf("sub-flow enters its own flow")
+			continue
+		}
+		pf := load(target)
+		if pf.graph == nil {
+			errorf("%s", pf.issue)
+			continue
+		}
+
+		var entryFound bool
+		var exits []string
+		for _, n := range pf.graph.Nodes {
+			if n.ID == entry {
+				entryFound = true
+			}
+			if exit, _ := n.Data.Config["exit"].(string); n.Type == "return" && exit != "" && !slices.Contains(exits, exit) {
+				exits = append(exits, exit)
+			}
+		}
+		if !entryFound {
+			errorf("entry node %q not found in flow %q", entry, pf.flow.Name)
+			continue
+		}
+		slices.Sort(exits)
+		for _, exit := range exits {
+			if !hasEdge(graph, node.ID, exit) {
+				issues = append(issues, ValidationIssue{
+					Severity: SeverityWarning,
+					NodeID:   node.ID,
+					Message:  fmt.Sprintf("node %q: flow %q may return on exit %q, which has no outgoing edge", node.Data.Label, pf.flow.Name, exit),
+				})
+			}
+		}
+
+		if cycle := findCycle(target, []int64{flowID}); cycle != nil {
+			names := make([]string, len(cycle))
+			for i, id := range cycle {
+				names[i] = flowName(id)
+			}
+			msg := "sub-flows form a cycle: " + strings.Join(names, " → ")
+			if !reported[msg] {
+				reported[msg] = true
+				errorf("%s", msg)
+			}
+		}
+	}
+	return issues
+}
+
+// hasEdge reports whether the node has an outgoing edge on the handle.
+func hasEdge(graph *FlowGraph, nodeID, handle string) bool {
+	for _, e := range graph.Edges {
+		if e.Source == nodeID && e.SourceHandle == handle {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPayloadTemplates calls check for every string in a webhook payload.
+func checkPayloadTemplates(v any, check func(name, text string)) {
+	switch val := v.(type) {
+	case string:
+		check("payload", val)
+	case map[string]any:
+		for _, item := range val {
+			checkPayloadTemplates(item, check)
+		}
+	case []any:
+		for _, item := range val {
+			checkPayloadTemplates(item, check)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package codecs implements the audio codecs the media path can transcode
+// between. Every codec encodes and decodes 16-bit linear audio, so any
+// two registered codecs can be connected through a Transcoder, resampling
+// between their sample rates as needed.
+//
+// G.711 (PCMU and PCMA) and G.722 are built in. Opus is registered when
+// the binary is built with the "opus" build tag and cgo, linking libopus;
+// the release binaries are. Without it Opus is not in the registry, so it
+// is never offered for transcoding in SDP and Opus calls are only relayed
+// between legs that both negotiated it.
+package codecs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Encoder encodes linear audio to RTP payloads.
+type Encoder interface {
+	// Encode encodes one frame of linear audio and appends the payload
+	// to dst.
+	Encode(dst []byte, pcm []int16) ([]byte, error)
+}
+
+// Decoder decodes RTP payloads to linear audio.
+type Decoder interface {
+	// Decode decodes one payload and appends the linear audio to dst.
+	Decode(dst []int16, payload []byte) ([]int16, error)
+}
+
+// Codec describes an audio codec and creates encoders and decoders for it.
+// Encoders and decoders may keep state between frames, so each stream
+// needs its own.
+type Codec struct {
+	// Name is the SDP encoding name, e.g. "PCMU" or "opus".
+	Name string
+
+	// PayloadType is the static RTP payload type, or the conventional
+	// dynamic payload type when the codec has no static assignment.
+	PayloadType int
+
+	// ClockRate is the RTP timestamp clock rate advertised in SDP.
+	ClockRate int
+
+	// Channels is the channel count advertised in SDP, or 0 to omit it.
+	Channels int
+
+	// Fmtp holds the format parameters to offer, if any.
+	Fmtp string
+
+	// SampleRate is the rate of the linear audio the codec encodes and
+	// decodes. It differs from ClockRate for G.722.
+	SampleRate int
+
+	// NewEncoder and NewDecoder create per-stream encoders and decoders.
+	NewEncoder func() (Encoder, error)
+	NewDecoder func() (Decoder, error)
+}
+
+// Dynamic reports whether the codec uses a dynamic RTP payload type.
+func (c *Codec) Dynamic() bool {
+	return c.PayloadType >= 96
+}
+
+// NewEncoderAt returns an encoder taking linear audio at the given sample
+// rate, resampling it to the codec's rate first if they differ.
+func (c *Codec) NewEncoderAt(rate int) (Encoder, error) {
+	enc, err := c.NewEncoder()
+	if err != nil {
+		return nil, fmt.Errorf("creating %s encoder: %w", c.Name, err)
+	}
+	if rate == c.SampleRate {
+		return enc, nil
+	}
+	res, err := NewResampler(rate, c.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+	return &resamplingEncoder{enc: enc, res: res}, nil
+}
+
+// NewDecoderAt returns a decoder producing linear audio at the given
+// sample rate, resampling the codec's output if the rates differ.
+func (c *Codec) NewDecoderAt(rate int) (Decoder, error) {
+	dec, err := c.NewDecoder()
+	if err != nil {
+		return nil, fmt.Errorf("creating %s decoder: %w", c.Name, err)
+	}
+	if rate == c.SampleRate {
+		return dec, nil
+	}
+	res, err := NewResampler(c.SampleRate, rate)
+	if err != nil {
+		return nil, err
+	}
+	return &resamplingDecoder{dec: dec, res: res}, nil
+}
+
+// resamplingEncoder resamples linear audio before encoding it.
+type resamplingEncoder struct {
+	enc Encoder
+	res *Resampler
+	pcm []int16
+}
+
+func (e *resamplingEncoder) Encode(dst []byte, pcm []int16) ([]byte, error) {
+	e.pcm = e.res.Resample(e.pcm[:0], pcm)
+	return e.enc.Encode(dst, e.pcm)
+}
+
+// resamplingDecoder resamples decoded linear audio.
+type resamplingDecoder struct {
+	dec Decoder
+	res *Resampler
+	pcm []int16
+}
+
+func (d *resamplingDecoder) Decode(dst []int16, payload []byte) ([]int16, error) {
+	var err error
+	d.pcm, err = d.dec.Decode(d.pcm[:0], payload)
+	if err != nil {
+		return dst, err
+	}
+	return d.res.Resample(dst, d.pcm), nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Codec)
+)
+
+// Register makes a codec available by name, replacing any codec already
+// registered under the same name.
+func Register(c *Codec) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(c.Name)] = c
+}
+
+// Lookup returns the codec registered under the given SDP encoding name,
+// compared case-insensitively, or nil if there is none.
+func Lookup(name string) *Codec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[strings.ToLower(name)]
+}
+
+// LookupPayloadType returns the codec with the given static RTP payload
+// type, or nil if there is none.
+func LookupPayloadType(pt int) *Codec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, c := range registry {
+		if !c.Dynamic() && c.PayloadType == pt {
+			return c
+		}
+	}
+	return nil
+}
+
+// All returns the registered codecs ordered by name.
+func All() []*Codec {
+	registryMu.RLock()
+	all := make([]*Codec, 0, len(registry))
+	for _, c := range registry {
+		all = append(all, c)
+	}
+	registryMu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
@@ -0,0 +1,98 @@
+package codecs
+
+import (
+	"math"
+	"testing"
+)
+
+// tone returns n samples of a sine wave at the given rate and amplitude.
+func tone(rate int, freq float64, amplitude float64, n int) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return out
+}
+
+// alignedSNR finds the delay of got against ref, up to maxLag samples, at
+// which they match best and returns the signal-to-noise ratio in dB there.
+// The first skip samples of ref are ignored to let codec state settle.
+func alignedSNR(ref, got []int16, skip, maxLag int) (lag int, snr float64) {
+	snr = math.Inf(-1)
+	for l := 0; l <= maxLag; l++ {
+		var sig, noise float64
+		for i := skip; i < len(ref) && i+l < len(got); i++ {
+			s := float64(ref[i])
+			d := float64(got[i+l]) - s
+			sig += s * s
+			noise += d * d
+		}
+		if noise == 0 {
+			return l, math.Inf(1)
+		}
+		if v := 10 * math.Log10(sig/noise); v > snr {
+			lag, snr = l, v
+		}
+	}
+	return lag, snr
+}
+
+// rms returns the root mean square level of samples.
+func rms(samples []int16) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"PCMU", "pcma", "g722"} {
+		if Lookup(name) == nil {
+			t.Errorf("Lookup(%q) = nil, want built-in codec", name)
+		}
+	}
+	if Lookup("G729") != nil {
+		t.Error("Lookup(G729) should be nil")
+	}
+
+	if c := LookupPayloadType(9); c != G722 {
+		t.Errorf("LookupPayloadType(9) = %v, want G722", c)
+	}
+	if c := LookupPayloadType(111); c != nil {
+		t.Errorf("LookupPayloadType(111) = %v, want nil for dynamic payload type", c.Name)
+	}
+
+	all := All()
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Name > all[i].Name {
+			t.Errorf("All not sorted: %q before %q", all[i-1].Name, all[i].Name)
+		}
+	}
+}
+
+func TestNewDecoderAtResamples(t *testing.T) {
+	dec, err := PCMU.NewDecoderAt(16000)
+	if err != nil {
+		t.Fatalf("NewDecoderAt: %v", err)
+	}
+	pcm, err := dec.Decode(nil, make([]byte, 160))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(pcm) != 320 {
+		t.Errorf("decoded %d samples, want 320 at 16 kHz", len(pcm))
+	}
+
+	enc, err := G722.NewEncoderAt(8000)
+	if err != nil {
+		t.Fatalf("NewEncoderAt: %v", err)
+	}
+	payload, err := enc.Encode(nil, make([]int16, 160))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if len(payload) != 160 {
+		t.Errorf("encoded %d bytes, want 160 for 20 ms of G.722", len(payload))
+	}
+}
@@ -0,0 +1,145 @@
+package codecs
+
+import "math/bits"
+
+// G.711 at 8 kHz, one byte per sample (ITU-T G.711).
+var (
+	PCMU = &Codec{
+		Name:        "PCMU",
+		PayloadType: 0,
+		ClockRate:   8000,
+		SampleRate:  8000,
+		NewEncoder:  func() (Encoder, error) { return g711Encoder{&linearToUlaw}, nil },
+		NewDecoder:  func() (Decoder, error) { return g711Decoder{&ulawToLinear}, nil },
+	}
+	PCMA = &Codec{
+		Name:        "PCMA",
+		PayloadType: 8,
+		ClockRate:   8000,
+		SampleRate:  8000,
+		NewEncoder:  func() (Encoder, error) { return g711Encoder{&linearToAlaw}, nil },
+		NewDecoder:  func() (Decoder, error) { return g711Decoder{&alawToLinear}, nil },
+	}
+)
+
+var (
+	ulawToLinear [256]int16
+	alawToLinear [256]int16
+	linearToUlaw [65536]byte
+	linearToAlaw [65536]byte
+)
+
+func init() {
+	for i := range 256 {
+		ulawToLinear[i] = DecodeUlaw(byte(i))
+		alawToLinear[i] = DecodeAlaw(byte(i))
+	}
+	for i := -32768; i <= 32767; i++ {
+		linearToUlaw[uint16(i)] = EncodeUlaw(int16(i))
+		linearToAlaw[uint16(i)] = EncodeAlaw(int16(i))
+	}
+
+	Register(PCMU)
+	Register(PCMA)
+}
+
+// g711Encoder encodes samples through a 64K-entry lookup table.
+type g711Encoder struct {
+	table *[65536]byte
+}
+
+func (e g711Encoder) Encode(dst []byte, pcm []int16) ([]byte, error) {
+	for _, s := range pcm {
+		dst = append(dst, e.table[uint16(s)])
+	}
+	return dst, nil
+}
+
+// g711Decoder decodes samples through a 256-entry lookup table.
+type g711Decoder struct {
+	table *[256]int16
+}
+
+func (d g711Decoder) Decode(dst []int16, payload []byte) ([]int16, error) {
+	for _, b := range payload {
+		dst = append(dst, d.table[b])
+	}
+	return dst, nil
+}
+
+// EncodeUlaw converts a 16-bit linear sample to a u-law byte.
+func EncodeUlaw(s int16) byte {
+	const (
+		bias = 0x84
+		clip = 32635
+	)
+	v := int(s)
+	sign := byte(0)
+	if v < 0 {
+		v = -v
+		sign = 0x80
+	}
+	if v > clip {
+		v = clip
+	}
+	v += bias
+
+	exponent := bits.Len(uint(v>>7)) - 1
+	mantissa := (v >> (exponent + 3)) & 0x0F
+	return ^(sign | byte(exponent<<4) | byte(mantissa))
+}
+
+// DecodeUlaw converts a u-law byte to a 16-bit linear sample.
+func DecodeUlaw(u byte) int16 {
+	u = ^u
+	t := (int(u&0x0F)<<3 + 0x84) << ((u & 0x70) >> 4)
+	if u&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+	return int16(t - 0x84)
+}
+
+// EncodeAlaw converts a 16-bit linear sample to an a-law byte.
+func EncodeAlaw(s int16) byte {
+	v := int(s) >> 3
+	mask := byte(0xD5)
+	if v < 0 {
+		mask = 0x55
+		v = -v - 1
+	}
+
+	// Segment boundaries of the 13-bit magnitude.
+	segment := bits.Len(uint(v>>4)) - 1
+	if segment < 0 {
+		segment = 0
+	}
+	if segment >= 8 {
+		return 0x7F ^ mask
+	}
+
+	aval := byte(segment << 4)
+	if segment < 2 {
+		aval |= byte(v>>1) & 0x0F
+	} else {
+		aval |= byte(v>>segment) & 0x0F
+	}
+	return aval ^ mask
+}
+
+// DecodeAlaw converts an a-law byte to a 16-bit linear sample.
+func DecodeAlaw(a byte) int16 {
+	a ^= 0x55
+	t := int(a&0x0F) << 4
+	switch segment := (a & 0x70) >> 4; segment {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (segment - 1)
+	}
+	if a&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
@@ -0,0 +1,58 @@
+package codecs
+
+import "testing"
+
+func TestG711KnownValues(t *testing.T) {
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"ulaw silence", int(EncodeUlaw(0)), 0xFF},
+		{"ulaw decode silence", int(DecodeUlaw(0xFF)), 0},
+		{"ulaw positive peak", int(DecodeUlaw(0x80)), 32124},
+		{"ulaw negative peak", int(DecodeUlaw(0x00)), -32124},
+		{"alaw silence", int(EncodeAlaw(0)), 0xD5},
+		{"alaw decode silence", int(DecodeAlaw(0xD5)), 8},
+		{"alaw positive peak", int(DecodeAlaw(0xAA)), 32256},
+		{"alaw negative peak", int(DecodeAlaw(0x2A)), -32256},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestG711RoundTrip(t *testing.T) {
+	for _, c := range []*Codec{PCMU, PCMA} {
+		t.Run(c.Name, func(t *testing.T) {
+			// Every code decodes to a value that encodes back to it,
+			// apart from the duplicate zero codes.
+			for i := range 256 {
+				b := byte(i)
+				var back byte
+				if c == PCMU {
+					back = EncodeUlaw(DecodeUlaw(b))
+				} else {
+					back = EncodeAlaw(DecodeAlaw(b))
+				}
+				if back != b && !(c == PCMU && b == 0x7F) {
+					t.Errorf("code 0x%02X round-trips to 0x%02X", b, back)
+				}
+			}
+
+			enc, _ := c.NewEncoder()
+			dec, _ := c.NewDecoder()
+			in := tone(8000, 400, 16000, 800)
+			payload, _ := enc.Encode(nil, in)
+			if len(payload) != len(in) {
+				t.Fatalf("encoded %d bytes, want %d", len(payload), len(in))
+			}
+			out, _ := dec.Decode(nil, payload)
+			if _, snr := alignedSNR(in, out, 0, 0); snr < 30 {
+				t.Errorf("snr = %.1f dB, want at least 30", snr)
+			}
+		})
+	}
+}
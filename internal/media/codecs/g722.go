@@ -0,0 +1,333 @@
+package codecs
+
+// G722 is ITU-T G.722 at 64 kbit/s: 16 kHz wideband audio split into two
+// sub-bands coded with ADPCM, one byte per pair of samples. For historical
+// reasons its RTP clock rate is 8000 Hz (RFC 3551 §4.5.2).
+var G722 = &Codec{
+	Name:        "G722",
+	PayloadType: 9,
+	ClockRate:   8000,
+	SampleRate:  16000,
+	NewEncoder:  func() (Encoder, error) { return newG722Encoder(), nil },
+	NewDecoder:  func() (Decoder, error) { return newG722Decoder(), nil },
+}
+
+func init() {
+	Register(G722)
+}
+
+// Quantizer and adaptation tables from ITU-T G.722.
+var (
+	g722Q6 = [32]int{
+		0, 35, 72, 110, 150, 190, 233, 276,
+		323, 370, 422, 473, 530, 587, 650, 714,
+		786, 858, 940, 1023, 1121, 1219, 1339, 1458,
+		1612, 1765, 1980, 2195, 2557, 2919, 0, 0,
+	}
+	g722ILN = [32]int{
+		0, 63, 62, 31, 30, 29, 28, 27,
+		26, 25, 24, 23, 22, 21, 20, 19,
+		18, 17, 16, 15, 14, 13, 12, 11,
+		10, 9, 8, 7, 6, 5, 4, 0,
+	}
+	g722ILP = [32]int{
+		0, 61, 60, 59, 58, 57, 56, 55,
+		54, 53, 52, 51, 50, 49, 48, 47,
+		46, 45, 44, 43, 42, 41, 40, 39,
+		38, 37, 36, 35, 34, 33, 32, 0,
+	}
+	g722WL   = [8]int{-60, -30, 58, 172, 334, 538, 1198, 3042}
+	g722RL42 = [16]int{0, 7, 6, 5, 4, 3, 2, 1, 7, 6, 5, 4, 3, 2, 1, 0}
+	g722ILB  = [32]int{
+		2048, 2093, 2139, 2186, 2233, 2282, 2332,
+		2383, 2435, 2489, 2543, 2599, 2656, 2714,
+		2774, 2834, 2896, 2960, 3025, 3091, 3158,
+		3228, 3298, 3371, 3444, 3520, 3597, 3676,
+		3756, 3838, 3922, 4008,
+	}
+	g722QM4 = [16]int{
+		0, -20456, -12896, -8968,
+		-6288, -4240, -2584, -1200,
+		20456, 12896, 8968, 6288,
+		4240, 2584, 1200, 0,
+	}
+	g722QM6 = [64]int{
+		-136, -136, -136, -136, -24808, -21904, -19008, -16704,
+		-14984, -13512, -12280, -11192, -10232, -9360, -8576, -7856,
+		-7192, -6576, -6000, -5456, -4944, -4464, -4008, -3576,
+		-3168, -2776, -2400, -2032, -1688, -1360, -1040, -728,
+		24808, 21904, 19008, 16704, 14984, 13512, 12280, 11192,
+		10232, 9360, 8576, 7856, 7192, 6576, 6000, 5456,
+		4944, 4464, 4008, 3576, 3168, 2776, 2400, 2032,
+		1688, 1360, 1040, 728, 432, 136, -432, -136,
+	}
+	g722QM2 = [4]int{-7408, -1616, 7408, 1616}
+	g722QMF = [12]int{3, -11, 12, 32, -210, 951, 3876, -805, 362, -156, 53, -11}
+	g722IHN = [3]int{0, 1, 0}
+	g722IHP = [3]int{0, 3, 2}
+	g722WH  = [3]int{0, -214, 798}
+	g722RH2 = [4]int{2, 1, 2, 1}
+)
+
+// g722Band is the ADPCM predictor state of one sub-band.
+type g722Band struct {
+	s, sp, sz int
+	r         [3]int
+	a, ap     [3]int
+	p         [3]int
+	d         [7]int
+	b, bp     [7]int
+	sg        [7]int
+	nb        int
+	det       int
+}
+
+// saturate clamps a value to the 16-bit range.
+func saturate(v int) int {
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return v
+}
+
+// scale computes a quantizer scale factor from a log scale factor.
+func scale(nb, shift int) int {
+	wd1 := (nb >> 6) & 31
+	wd2 := shift - (nb >> 11)
+	var wd3 int
+	if wd2 < 0 {
+		wd3 = g722ILB[wd1] << -wd2
+	} else {
+		wd3 = g722ILB[wd1] >> wd2
+	}
+	return wd3 << 2
+}
+
+// update adapts the band's pole and zero predictors to the quantized
+// difference d and computes the next signal estimate (G.722 block 4).
+func (b *g722Band) update(d int) {
+	// RECONS and PARREC.
+	b.d[0] = d
+	b.r[0] = saturate(b.s + d)
+	b.p[0] = saturate(b.sz + d)
+
+	// UPPOL2.
+	for i := range 3 {
+		b.sg[i] = b.p[i] >> 15
+	}
+	wd1 := saturate(b.a[1] << 2)
+	wd2 := wd1
+	if b.sg[0] == b.sg[1] {
+		wd2 = -wd1
+	}
+	if wd2 > 32767 {
+		wd2 = 32767
+	}
+	wd3 := wd2 >> 7
+	if b.sg[0] == b.sg[2] {
+		wd3 += 128
+	} else {
+		wd3 -= 128
+	}
+	wd3 += (b.a[2] * 32512) >> 15
+	wd3 = min(max(wd3, -12288), 12288)
+	b.ap[2] = wd3
+
+	// UPPOL1.
+	b.sg[0] = b.p[0] >> 15
+	b.sg[1] = b.p[1] >> 15
+	wd1 = -192
+	if b.sg[0] == b.sg[1] {
+		wd1 = 192
+	}
+	wd2 = (b.a[1] * 32640) >> 15
+	b.ap[1] = saturate(wd1 + wd2)
+	wd3 = saturate(15360 - b.ap[2])
+	b.ap[1] = min(max(b.ap[1], -wd3), wd3)
+
+	// UPZERO.
+	wd1 = 128
+	if d == 0 {
+		wd1 = 0
+	}
+	b.sg[0] = d >> 15
+	for i := 1; i < 7; i++ {
+		b.sg[i] = b.d[i] >> 15
+		wd2 = -wd1
+		if b.sg[i] == b.sg[0] {
+			wd2 = wd1
+		}
+		wd3 = (b.b[i] * 32640) >> 15
+		b.bp[i] = saturate(wd2 + wd3)
+	}
+
+	// DELAYA.
+	for i := 6; i > 0; i-- {
+		b.d[i] = b.d[i-1]
+		b.b[i] = b.bp[i]
+	}
+	for i := 2; i > 0; i-- {
+		b.r[i] = b.r[i-1]
+		b.p[i] = b.p[i-1]
+		b.a[i] = b.ap[i]
+	}
+
+	// FILTEP.
+	wd1 = saturate(b.r[1] + b.r[1])
+	wd1 = (b.a[1] * wd1) >> 15
+	wd2 = saturate(b.r[2] + b.r[2])
+	wd2 = (b.a[2] * wd2) >> 15
+	b.sp = saturate(wd1 + wd2)
+
+	// FILTEZ.
+	b.sz = 0
+	for i := 6; i > 0; i-- {
+		wd1 = saturate(b.d[i] + b.d[i])
+		b.sz += (b.b[i] * wd1) >> 15
+	}
+	b.sz = saturate(b.sz)
+
+	// PREDIC.
+	b.s = saturate(b.sp + b.sz)
+}
+
+// adaptLow updates the lower sub-band's scale factor for a 4-bit code
+// and returns its quantized difference (blocks 2L, 3L).
+func (b *g722Band) adaptLow(code4 int) int {
+	dlow := (b.det * g722QM4[code4]) >> 15
+
+	nb := (b.nb*127)>>7 + g722WL[g722RL42[code4]]
+	b.nb = min(max(nb, 0), 18432)
+	b.det = scale(b.nb, 8)
+	return dlow
+}
+
+// adaptHigh updates the upper sub-band's scale factor for a 2-bit code
+// and returns its quantized difference (blocks 2H, 3H).
+func (b *g722Band) adaptHigh(code2 int) int {
+	dhigh := (b.det * g722QM2[code2]) >> 15
+
+	nb := (b.nb*127)>>7 + g722WH[g722RH2[code2]]
+	b.nb = min(max(nb, 0), 22528)
+	b.det = scale(b.nb, 10)
+	return dhigh
+}
+
+// g722Encoder is the state of one G.722 encoding stream.
+type g722Encoder struct {
+	band [2]g722Band
+	x    [24]int // transmit QMF history
+}
+
+func newG722Encoder() *g722Encoder {
+	e := &g722Encoder{}
+	e.band[0].det = 32
+	e.band[1].det = 8
+	return e
+}
+
+// Encode encodes pairs of 16 kHz samples to one byte each. A trailing odd
+// sample is ignored.
+func (e *g722Encoder) Encode(dst []byte, pcm []int16) ([]byte, error) {
+	for j := 0; j+1 < len(pcm); j += 2 {
+		// Transmit QMF: split the pair into low and high sub-bands.
+		copy(e.x[:22], e.x[2:])
+		e.x[22] = int(pcm[j])
+		e.x[23] = int(pcm[j+1])
+		sumEven, sumOdd := 0, 0
+		for i := range 12 {
+			sumOdd += e.x[2*i] * g722QMF[i]
+			sumEven += e.x[2*i+1] * g722QMF[11-i]
+		}
+		xlow := (sumEven + sumOdd) >> 14
+		xhigh := (sumEven - sumOdd) >> 14
+
+		// Lower sub-band: 6-bit ADPCM (block 1L).
+		low := &e.band[0]
+		el := saturate(xlow - low.s)
+		wd := el
+		if el < 0 {
+			wd = -(el + 1)
+		}
+		i := 1
+		for ; i < 30; i++ {
+			if wd < (g722Q6[i]*low.det)>>12 {
+				break
+			}
+		}
+		ilow := g722ILP[i]
+		if el < 0 {
+			ilow = g722ILN[i]
+		}
+		low.update(low.adaptLow(ilow >> 2))
+
+		// Upper sub-band: 2-bit ADPCM (block 1H).
+		high := &e.band[1]
+		eh := saturate(xhigh - high.s)
+		wd = eh
+		if eh < 0 {
+			wd = -(eh + 1)
+		}
+		mih := 1
+		if wd >= (564*high.det)>>12 {
+			mih = 2
+		}
+		ihigh := g722IHP[mih]
+		if eh < 0 {
+			ihigh = g722IHN[mih]
+		}
+		high.update(high.adaptHigh(ihigh))
+
+		dst = append(dst, byte(ihigh<<6|ilow))
+	}
+	return dst, nil
+}
+
+// g722Decoder is the state of one G.722 decoding stream.
+type g722Decoder struct {
+	band [2]g722Band
+	x    [24]int // receive QMF history
+}
+
+func newG722Decoder() *g722Decoder {
+	d := &g722Decoder{}
+	d.band[0].det = 32
+	d.band[1].det = 8
+	return d
+}
+
+// Decode decodes each byte to a pair of 16 kHz samples.
+func (d *g722Decoder) Decode(dst []int16, payload []byte) ([]int16, error) {
+	for _, code := range payload {
+		ilow := int(code & 0x3F)
+		ihigh := int(code>>6) & 0x03
+
+		// Lower sub-band: reconstruct from the full 6-bit code, adapt
+		// on its 4 most significant bits (blocks 5L, 6L).
+		low := &d.band[0]
+		rlow := low.s + (low.det*g722QM6[ilow])>>15
+		rlow = min(max(rlow, -16384), 16383)
+		low.update(low.adaptLow(ilow >> 2))
+
+		// Upper sub-band (blocks 5H, 6H).
+		high := &d.band[1]
+		dhigh := high.adaptHigh(ihigh)
+		rhigh := min(max(dhigh+high.s, -16384), 16383)
+		high.update(dhigh)
+
+		// Receive QMF: recombine the sub-bands into two samples.
+		copy(d.x[:22], d.x[2:])
+		d.x[22] = rlow + rhigh
+		d.x[23] = rlow - rhigh
+		xout1, xout2 := 0, 0
+		for i := range 12 {
+			xout2 += d.x[2*i] * g722QMF[i]
+			xout1 += d.x[2*i+1] * g722QMF[11-i]
+		}
+		dst = append(dst, int16(saturate(xout1>>11)), int16(saturate(xout2>>11)))
+	}
+	return dst, nil
+}
@@ -0,0 +1,62 @@
+package codecs
+
+import "testing"
+
+func TestG722RoundTrip(t *testing.T) {
+	enc, _ := G722.NewEncoder()
+	dec, _ := G722.NewDecoder()
+
+	tests := []struct {
+		name string
+		freq float64
+	}{
+		{"narrowband tone", 440},
+		{"wideband tone", 5500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tone(16000, tt.freq, 10000, 3200)
+
+			var payload []byte
+			var out []int16
+			// Code in 20 ms frames as a call would.
+			for i := 0; i < len(in); i += 320 {
+				frame, err := enc.Encode(nil, in[i:i+320])
+				if err != nil {
+					t.Fatalf("Encode: %v", err)
+				}
+				if len(frame) != 160 {
+					t.Fatalf("frame is %d bytes, want 160", len(frame))
+				}
+				payload = append(payload, frame...)
+				out, err = dec.Decode(out, frame)
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+			}
+			if len(out) != len(in) {
+				t.Fatalf("decoded %d samples, want %d", len(out), len(in))
+			}
+
+			// The two QMF filters delay the signal by 22 samples.
+			lag, snr := alignedSNR(in, out, 800, 40)
+			if lag != 22 {
+				t.Errorf("delay = %d samples, want 22", lag)
+			}
+			if snr < 15 {
+				t.Errorf("snr = %.1f dB, want at least 15", snr)
+			}
+		})
+	}
+}
+
+func TestG722Silence(t *testing.T) {
+	enc, _ := G722.NewEncoder()
+	dec, _ := G722.NewDecoder()
+
+	payload, _ := enc.Encode(nil, make([]int16, 1600))
+	out, _ := dec.Decode(nil, payload)
+	if level := rms(out[100:]); level > 10 {
+		t.Errorf("silence decodes at rms %.1f, want near zero", level)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build opus && cgo
+
+package codecs
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// Opus is the Opus codec (RFC 6716), carried in RTP as described by RFC
+// 7587: always advertised as 48 kHz stereo, though mono is sent. It is
+// only available in builds with the "opus" tag, which link libopus.
+var Opus = &Codec{
+	Name:        "opus",
+	PayloadType: 111,
+	ClockRate:   48000,
+	Channels:    2,
+	Fmtp:        "minptime=10;useinbandfec=1",
+	SampleRate:  opusSampleRate,
+	NewEncoder:  newOpusEncoder,
+	NewDecoder:  newOpusDecoder,
+}
+
+func init() {
+	Register(Opus)
+}
+
+const (
+	// opusSampleRate is the rate Opus audio is encoded and decoded at.
+	opusSampleRate = 48000
+
+	// opusMaxPacket is the largest Opus packet produced (RFC 6716 §3.4).
+	opusMaxPacket = 1275
+
+	// opusMaxFrame is the number of samples in the longest Opus packet,
+	// 120 ms at 48 kHz.
+	opusMaxFrame = 5760
+)
+
+// opusEncoder wraps a libopus encoder for mono voice.
+type opusEncoder struct {
+	st  *C.OpusEncoder
+	out [opusMaxPacket]byte
+}
+
+func newOpusEncoder() (Encoder, error) {
+	var code C.int
+	st := C.opus_encoder_create(C.opus_int32(opusSampleRate), 1, C.OPUS_APPLICATION_VOIP, &code)
+	if code != C.OPUS_OK {
+		return nil, opusError("creating encoder", code)
+	}
+	e := &opusEncoder{st: st}
+	runtime.AddCleanup(e, func(st *C.OpusEncoder) { C.opus_encoder_destroy(st) }, st)
+	return e, nil
+}
+
+// Encode encodes one frame, which must be 2.5, 5, 10, 20, 40 or 60 ms.
+func (e *opusEncoder) Encode(dst []byte, pcm []int16) ([]byte, error) {
+	if len(pcm) == 0 {
+		return dst, errors.New("opus: empty frame")
+	}
+	n := C.opus_encode(e.st, (*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(len(pcm)),
+		(*C.uchar)(unsafe.Pointer(&e.out[0])), C.opus_int32(len(e.out)))
+	runtime.KeepAlive(e)
+	if n < 0 {
+		return dst, opusError("encoding", n)
+	}
+	return append(dst, e.out[:n]...), nil
+}
+
+// opusDecoder wraps a libopus decoder producing mono audio.
+type opusDecoder struct {
+	st  *C.OpusDecoder
+	pcm [opusMaxFrame]int16
+}
+
+func newOpusDecoder() (Decoder, error) {
+	var code C.int
+	st := C.opus_decoder_create(C.opus_int32(opusSampleRate), 1, &code)
+	if code != C.OPUS_OK {
+		return nil, opusError("creating decoder", code)
+	}
+	d := &opusDecoder{st: st}
+	runtime.AddCleanup(d, func(st *C.OpusDecoder) { C.opus_decoder_destroy(st) }, st)
+	return d, nil
+}
+
+func (d *opusDecoder) Decode(dst []int16, payload []byte) ([]int16, error) {
+	if len(payload) == 0 {
+		return dst, errors.New("opus: empty payload")
+	}
+	n := C.opus_decode(d.st, (*C.uchar)(unsafe.Pointer(&payload[0])), C.opus_int32(len(payload)),
+		(*C.opus_int16)(unsafe.Pointer(&d.pcm[0])), C.int(len(d.pcm)), 0)
+	runtime.KeepAlive(d)
+	if n < 0 {
+		return dst, opusError("decoding", n)
+	}
+	return append(dst, d.pcm[:n]...), nil
+}
+
+// opusError describes a libopus error code.
+func opusError(op string, code C.int) error {
+	return fmt.Errorf("opus: %s: %s", op, C.GoString(C.opus_strerror(code)))
+}
@@ -0,0 +1,13 @@
+//go:build !(opus && cgo)
+
+package codecs
+
+import "testing"
+
+func TestOpusNotRegistered(t *testing.T) {
+	// Without libopus Opus must not be registered, or calls would be
+	// offered Opus the relay cannot transcode.
+	if c := Lookup("opus"); c != nil {
+		t.Errorf("Lookup(opus) = %v, want nil without the opus build tag", c.Name)
+	}
+}
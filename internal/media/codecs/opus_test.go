@@ -0,0 +1,63 @@
+//go:build opus && cgo
+
+package codecs
+
+import "testing"
+
+func TestOpusRegistered(t *testing.T) {
+	if c := Lookup("OPUS"); c != Opus {
+		t.Fatalf("Lookup(OPUS) = %v, want Opus", c)
+	}
+	if !Opus.Dynamic() {
+		t.Error("Opus should use a dynamic payload type")
+	}
+}
+
+func TestOpusRoundTrip(t *testing.T) {
+	enc, err := Opus.NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	dec, err := Opus.NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	in := tone(opusSampleRate, 440, 10000, opusSampleRate)
+	var out []int16
+	// Code in 20 ms frames as a call would.
+	for i := 0; i < len(in); i += 960 {
+		frame, err := enc.Encode(nil, in[i:i+960])
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if len(frame) == 0 || len(frame) > opusMaxPacket {
+			t.Fatalf("frame is %d bytes", len(frame))
+		}
+		out, err = dec.Decode(out, frame)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+	}
+	if len(out) != len(in) {
+		t.Fatalf("decoded %d samples, want %d", len(out), len(in))
+	}
+
+	// Opus is perceptual, so compare levels rather than waveforms once
+	// the encoder has settled.
+	want, got := rms(in[4800:]), rms(out[4800:])
+	if got < want*0.7 || got > want*1.3 {
+		t.Errorf("decoded rms %.0f, want about %.0f", got, want)
+	}
+}
+
+func TestOpusEmpty(t *testing.T) {
+	enc, _ := Opus.NewEncoder()
+	dec, _ := Opus.NewDecoder()
+	if _, err := enc.Encode(nil, nil); err == nil {
+		t.Error("expected error encoding an empty frame")
+	}
+	if _, err := dec.Decode(nil, nil); err == nil {
+		t.Error("expected error decoding an empty payload")
+	}
+}
@@ -0,0 +1,143 @@
+package codecs
+
+import (
+	"fmt"
+	"math"
+)
+
+// resamplerZeroCrossings is the number of zero crossings of the low-pass
+// filter on each side of its centre, which sets its steepness.
+const resamplerZeroCrossings = 8
+
+// Resampler converts a stream of linear audio between two sample rates
+// where one is a whole multiple of the other, such as 8, 16 and 48 kHz.
+// Samples are passed through a windowed-sinc low-pass filter that removes
+// images when upsampling and aliases when downsampling. The filter keeps
+// history across calls, so a stream can be resampled frame by frame
+// without discontinuities at frame boundaries.
+type Resampler struct {
+	up, down int
+
+	// taps is the low-pass filter, designed at the higher of the two
+	// rates and scaled for the conversion's gain.
+	taps []float64
+
+	// hist holds the most recent input samples, newest last, twice over
+	// so a window of them can always be read as one contiguous slice.
+	hist []float64
+	pos  int
+
+	// phase counts input samples towards the next output when
+	// downsampling.
+	phase int
+}
+
+// NewResampler creates a resampler from one sample rate to another. It
+// returns an error unless one rate is a whole multiple of the other.
+func NewResampler(from, to int) (*Resampler, error) {
+	if from <= 0 || to <= 0 {
+		return nil, fmt.Errorf("invalid resampling rates %d to %d Hz", from, to)
+	}
+	up, down := 1, 1
+	switch {
+	case to >= from && to%from == 0:
+		up = to / from
+	case from > to && from%to == 0:
+		down = from / to
+	default:
+		return nil, fmt.Errorf("unsupported resampling from %d to %d Hz", from, to)
+	}
+
+	r := &Resampler{up: up, down: down}
+	factor := max(up, down)
+	if factor == 1 {
+		return r, nil
+	}
+
+	// Windowed sinc with its cutoff at the lower rate's Nyquist frequency.
+	n := 2*resamplerZeroCrossings*factor + 1
+	centre := float64(n-1) / 2
+	r.taps = make([]float64, n)
+	sum := 0.0
+	for i := range r.taps {
+		x := (float64(i) - centre) / float64(factor)
+		sinc := 1.0
+		if x != 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		// Blackman window.
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1)) + 0.08*math.Cos(4*math.Pi*float64(i)/float64(n-1))
+		r.taps[i] = sinc * w
+		sum += r.taps[i]
+	}
+	// Unity gain at DC; upsampling spreads each input sample over up
+	// outputs, so its gain is up.
+	for i := range r.taps {
+		r.taps[i] *= float64(up) / sum
+	}
+
+	size := n
+	if up > 1 {
+		size = (n + up - 1) / up
+	}
+	r.hist = make([]float64, 2*size)
+	return r, nil
+}
+
+// Resample converts src and appends the output to dst. When downsampling,
+// an output sample is produced for every whole group of input samples, so
+// frames whose length is a multiple of the ratio convert exactly.
+func (r *Resampler) Resample(dst, src []int16) []int16 {
+	switch {
+	case r.up > 1:
+		for _, s := range src {
+			window := r.push(float64(s))
+			for phase := range r.up {
+				acc := 0.0
+				for j, k := 0, phase; k < len(r.taps); j, k = j+1, k+r.up {
+					acc += r.taps[k] * window[len(window)-1-j]
+				}
+				dst = append(dst, saturate16(acc))
+			}
+		}
+	case r.down > 1:
+		for _, s := range src {
+			window := r.push(float64(s))
+			r.phase++
+			if r.phase < r.down {
+				continue
+			}
+			r.phase = 0
+			acc := 0.0
+			for k, t := range r.taps {
+				acc += t * window[len(window)-1-k]
+			}
+			dst = append(dst, saturate16(acc))
+		}
+	default:
+		dst = append(dst, src...)
+	}
+	return dst
+}
+
+// push adds a sample to the history and returns the window of recent
+// samples, newest last.
+func (r *Resampler) push(s float64) []float64 {
+	size := len(r.hist) / 2
+	r.pos = (r.pos + 1) % size
+	r.hist[r.pos] = s
+	r.hist[r.pos+size] = s
+	return r.hist[r.pos+1 : r.pos+size+1]
+}
+
+// saturate16 rounds a sample and clamps it to the 16-bit range.
+func saturate16(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
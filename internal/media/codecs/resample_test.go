@@ -0,0 +1,70 @@
+package codecs
+
+import "testing"
+
+func TestNewResamplerRates(t *testing.T) {
+	for _, rates := range [][2]int{{8000, 16000}, {16000, 8000}, {8000, 48000}, {48000, 8000}, {16000, 48000}, {48000, 16000}, {8000, 8000}} {
+		if _, err := NewResampler(rates[0], rates[1]); err != nil {
+			t.Errorf("NewResampler(%d, %d): %v", rates[0], rates[1], err)
+		}
+	}
+	for _, rates := range [][2]int{{8000, 11025}, {44100, 48000}, {0, 8000}} {
+		if _, err := NewResampler(rates[0], rates[1]); err == nil {
+			t.Errorf("NewResampler(%d, %d): expected error", rates[0], rates[1])
+		}
+	}
+}
+
+func TestResamplerFrameLengths(t *testing.T) {
+	tests := []struct {
+		from, to int
+		in, want int
+	}{
+		{8000, 48000, 160, 960},
+		{48000, 8000, 960, 160},
+		{16000, 8000, 320, 160},
+		{8000, 16000, 160, 320},
+		{8000, 8000, 160, 160},
+	}
+	for _, tt := range tests {
+		r, _ := NewResampler(tt.from, tt.to)
+		for range 3 {
+			if got := len(r.Resample(nil, make([]int16, tt.in))); got != tt.want {
+				t.Errorf("%d to %d Hz: %d samples in, %d out, want %d", tt.from, tt.to, tt.in, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestResamplerRoundTrip(t *testing.T) {
+	up, _ := NewResampler(8000, 48000)
+	down, _ := NewResampler(48000, 8000)
+
+	in := tone(8000, 700, 12000, 1600)
+	var out []int16
+	// Frame by frame, as on a call, so history must carry across frames.
+	for i := 0; i < len(in); i += 160 {
+		wide := up.Resample(nil, in[i:i+160])
+		out = down.Resample(out, wide)
+	}
+	if _, snr := alignedSNR(in, out, 200, 40); snr < 30 {
+		t.Errorf("snr = %.1f dB, want at least 30", snr)
+	}
+}
+
+func TestResamplerRejectsAliases(t *testing.T) {
+	r, _ := NewResampler(48000, 8000)
+
+	// A 1 kHz tone passes; a 6 kHz tone would alias to 2 kHz at 8 kHz and
+	// must be filtered out.
+	pass := r.Resample(nil, tone(48000, 1000, 10000, 9600))
+	r, _ = NewResampler(48000, 8000)
+	stop := r.Resample(nil, tone(48000, 6000, 10000, 9600))
+
+	if level := rms(pass[100:]); level < 6500 || level > 7600 {
+		t.Errorf("1 kHz tone rms = %.0f, want about %.0f", level, 10000/1.414)
+	}
+	if level := rms(stop[100:]); level > 100 {
+		t.Errorf("6 kHz tone rms = %.0f after downsampling, want it filtered out", level)
+	}
+}
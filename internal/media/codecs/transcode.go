@@ -0,0 +1,51 @@
+package codecs
+
+import "fmt"
+
+// Transcoder converts the payloads of one RTP stream from one codec to
+// another by decoding them to linear audio, resampling it to the target
+// codec's rate and encoding it again. It keeps codec state between
+// payloads, so each stream needs its own Transcoder.
+type Transcoder struct {
+	from, to *Codec
+	dec      Decoder
+	enc      Encoder
+	pcm      []int16
+}
+
+// NewTranscoder creates a transcoder from one codec to another.
+func NewTranscoder(from, to *Codec) (*Transcoder, error) {
+	dec, err := from.NewDecoderAt(to.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := to.NewEncoder()
+	if err != nil {
+		return nil, fmt.Errorf("creating %s encoder: %w", to.Name, err)
+	}
+	return &Transcoder{from: from, to: to, dec: dec, enc: enc}, nil
+}
+
+// From returns the codec payloads are converted from.
+func (t *Transcoder) From() *Codec {
+	return t.from
+}
+
+// To returns the codec payloads are converted to.
+func (t *Transcoder) To() *Codec {
+	return t.to
+}
+
+// Transcode converts one payload and appends the result to dst.
+func (t *Transcoder) Transcode(dst, payload []byte) ([]byte, error) {
+	var err error
+	t.pcm, err = t.dec.Decode(t.pcm[:0], payload)
+	if err != nil {
+		return dst, fmt.Errorf("decoding %s: %w", t.from.Name, err)
+	}
+	dst, err = t.enc.Encode(dst, t.pcm)
+	if err != nil {
+		return dst, fmt.Errorf("encoding %s: %w", t.to.Name, err)
+	}
+	return dst, nil
+}
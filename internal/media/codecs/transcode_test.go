@@ -0,0 +1,61 @@
+package codecs
+
+import "testing"
+
+func TestTranscoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  *Codec
+		frameIn   int
+		frameOut  int
+		minSNR    float64
+		maxLag    int
+		inputRate int
+	}{
+		{"PCMU to PCMA", PCMU, PCMA, 160, 160, 25, 0, 8000},
+		{"PCMA to G722", PCMA, G722, 160, 160, 12, 60, 8000},
+		{"G722 to PCMU", G722, PCMU, 160, 160, 12, 60, 8000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := NewTranscoder(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("NewTranscoder: %v", err)
+			}
+			if tc.From() != tt.from || tc.To() != tt.to {
+				t.Errorf("From/To = %s/%s", tc.From().Name, tc.To().Name)
+			}
+
+			// Encode a tone in the source codec, transcode it frame by
+			// frame, then decode the result back to 8 kHz to compare.
+			ref := tone(8000, 500, 10000, 1600)
+			enc, _ := tt.from.NewEncoderAt(8000)
+			dec, _ := tt.to.NewDecoderAt(8000)
+
+			var out []int16
+			for i := 0; i < len(ref); i += 160 {
+				payload, err := enc.Encode(nil, ref[i:i+160])
+				if err != nil {
+					t.Fatalf("Encode: %v", err)
+				}
+				if len(payload) != tt.frameIn {
+					t.Fatalf("source frame is %d bytes, want %d", len(payload), tt.frameIn)
+				}
+				converted, err := tc.Transcode(nil, payload)
+				if err != nil {
+					t.Fatalf("Transcode: %v", err)
+				}
+				if len(converted) != tt.frameOut {
+					t.Fatalf("transcoded frame is %d bytes, want %d", len(converted), tt.frameOut)
+				}
+				out, err = dec.Decode(out, converted)
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+			}
+			if _, snr := alignedSNR(ref, out, 400, tt.maxLag); snr < tt.minSNR {
+				t.Errorf("snr = %.1f dB, want at least %.0f", snr, tt.minSNR)
+			}
+		})
+	}
+}
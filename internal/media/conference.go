@@ -56,8 +56,15 @@ type ConferenceManager struct {
 
 	mu    sync.Mutex
 	rooms map[int64]*ConferenceRoom
+
+	// onChange, if set, is called when a participant joins or leaves.
+	onChange ParticipantListener
 }
 
+// ParticipantListener is called with a conference participant when it
+// joins (joined is true) or leaves the conference named bridgeName.
+type ParticipantListener func(bridgeName string, p ConferenceParticipant, joined bool)
+
 // NewConferenceManager creates a conference manager backed by the given proxy
 // for RTP port allocation. dataDir is the application data directory used for
 // storing conference recordings under dataDir/recordings/.
@@ -70,6 +77,12 @@ func NewConferenceManager(proxy *Proxy, dataDir string, logger *slog.Logger) *Co
 	}
 }
 
+// OnParticipantChange registers a listener for participants joining and
+// leaving conferences. Must be called before any conference is joined.
+func (cm *ConferenceManager) OnParticipantChange(fn ParticipantListener) {
+	cm.onChange = fn
+}
+
 // JoinResult holds the result of joining a conference.
 type JoinResult struct {
 	// Room is the conference room that was joined.
@@ -106,7 +119,7 @@ type JoinOpts struct {
 // the allocated RTP socket pair for SDP rewriting.
 //
 // The caller must call Leave when the participant exits the conference.
-func (cm *ConferenceManager) Join(ctx context.Context, bridgeID int64, bridgeName string, maxMembers int, announceJoins bool, record bool, participantID string, remote *net.UDPAddr, payloadType int, opts *JoinOpts) (*JoinResult, error) {
+func (cm *ConferenceManager) Join(ctx context.Context, bridgeID int64, bridgeName string, maxMembers int, announceJoins bool, record bool, participantID string, remote *net.UDPAddr, codec LegCodec, opts *JoinOpts) (*JoinResult, error) {
 	cm.mu.Lock()
 
 	room, exists := cm.rooms[bridgeID]
@@ -163,7 +176,7 @@ func (cm *ConferenceManager) Join(ctx context.Context, bridgeID int64, bridgeNam
 	cm.mu.Unlock()
 
 	// Add participant to the mixer (mixer has its own locking).
-	socket, err := room.Mixer.AddParticipant(participantID, remote, payloadType)
+	socket, err := room.Mixer.AddParticipant(participantID, remote, codec)
 	if err != nil {
 		return nil, fmt.Errorf("adding participant to conference %q: %w", bridgeName, err)
 	}
@@ -175,16 +188,17 @@ func (cm *ConferenceManager) Join(ctx context.Context, bridgeID int64, bridgeNam
 		callerNum = opts.CallerIDNum
 	}
 
-	cm.mu.Lock()
-	room.participants[participantID] = &ConferenceParticipant{
+	participant := &ConferenceParticipant{
 		ID:           participantID,
 		BridgeID:     bridgeID,
 		CallerIDName: callerName,
 		CallerIDNum:  callerNum,
 		JoinedAt:     time.Now(),
-		PayloadType:  payloadType,
+		PayloadType:  codec.PayloadType,
 		Port:         socket.Ports.RTP,
 	}
+	cm.mu.Lock()
+	room.participants[participantID] = participant
 	cm.mu.Unlock()
 
 	cm.logger.Info("participant joined conference",
@@ -196,6 +210,9 @@ func (cm *ConferenceManager) Join(ctx context.Context, bridgeID int64, bridgeNam
 		"rtp_port", socket.Ports.RTP,
 		"participants", room.Mixer.ParticipantCount(),
 	)
+	if cm.onChange != nil {
+		cm.onChange(bridgeName, *participant, true)
+	}
 
 	// Play join tone to all participants if announce_joins is enabled.
 	if room.AnnounceJoins {
@@ -226,6 +243,7 @@ func (cm *ConferenceManager) Leave(bridgeID int64, participantID string) error {
 
 	// Remove participant from the room's metadata registry.
 	cm.mu.Lock()
+	participant := room.participants[participantID]
 	delete(room.participants, participantID)
 	cm.mu.Unlock()
 
@@ -237,6 +255,9 @@ func (cm *ConferenceManager) Leave(bridgeID int64, participantID string) error {
 		"participant_id", participantID,
 		"remaining", remaining,
 	)
+	if cm.onChange != nil && participant != nil {
+		cm.onChange(room.BridgeName, *participant, false)
+	}
 
 	// Play leave tone to remaining participants if announce_joins is enabled
 	// and the room is not empty.
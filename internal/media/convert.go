@@ -0,0 +1,176 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WAV format codes for linear PCM input accepted by ConvertWAV.
+const (
+	wavFormatPCM        = 1      // linear PCM
+	wavFormatExtensible = 0xFFFE // WAVE_FORMAT_EXTENSIBLE, treated as PCM
+)
+
+// promptSampleRate is the sample rate of G.711 prompts.
+const promptSampleRate = 8000
+
+// ConvertWAV converts a WAV file to 8 kHz mono G.711 in the law given by
+// payloadType (PayloadPCMU or PayloadPCMA), the format used for prompts.
+// The input may be 8 or 16-bit linear PCM or G.711 at any sample rate and
+// channel count; channels are mixed down and the audio is resampled.
+//
+// WAV files written to a pipe often carry a placeholder data size, so the
+// data chunk is read up to the end of the input when its size overruns it.
+func ConvertWAV(data []byte, payloadType int) ([]byte, error) {
+	if payloadType != PayloadPCMU && payloadType != PayloadPCMA {
+		return nil, fmt.Errorf("unsupported payload type %d for wav conversion", payloadType)
+	}
+
+	r := bytes.NewReader(data)
+	hdr, err := parseWAVHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wav: %w", err)
+	}
+	if hdr.NumChannels == 0 || hdr.SampleRate == 0 {
+		return nil, fmt.Errorf("invalid wav: %d channels at %d Hz", hdr.NumChannels, hdr.SampleRate)
+	}
+
+	audio := data[len(data)-r.Len():]
+	if int64(hdr.DataSize) < int64(len(audio)) {
+		audio = audio[:hdr.DataSize]
+	}
+
+	samples, err := decodeWAVSamples(hdr, audio)
+	if err != nil {
+		return nil, err
+	}
+	samples = resampleLinear(samples, int(hdr.SampleRate), promptSampleRate)
+
+	encode := &linearToUlaw
+	wavFormat := uint16(wavFormatPCMU)
+	if payloadType == PayloadPCMA {
+		encode = &linearToAlaw
+		wavFormat = wavFormatPCMA
+	}
+
+	var out bytes.Buffer
+	out.Grow(wavHeaderSize + len(samples))
+	writeG711WAVHeader(&out, wavFormat, uint32(len(samples)))
+	for _, s := range samples {
+		out.WriteByte(encode[uint16(s)])
+	}
+	return out.Bytes(), nil
+}
+
+// decodeWAVSamples decodes interleaved WAV audio to mono 16-bit samples.
+func decodeWAVSamples(hdr *wavHeader, audio []byte) ([]int16, error) {
+	var decode func(b []byte) int16
+	switch {
+	case hdr.AudioFormat == wavFormatPCMU && hdr.BitsPerSample == 8:
+		decode = func(b []byte) int16 { return ulawToLinear[b[0]] }
+	case hdr.AudioFormat == wavFormatPCMA && hdr.BitsPerSample == 8:
+		decode = func(b []byte) int16 { return alawToLinear[b[0]] }
+	case (hdr.AudioFormat == wavFormatPCM || hdr.AudioFormat == wavFormatExtensible) && hdr.BitsPerSample == 8:
+		// 8-bit PCM is unsigned.
+		decode = func(b []byte) int16 { return int16(int(b[0])-128) << 8 }
+	case (hdr.AudioFormat == wavFormatPCM || hdr.AudioFormat == wavFormatExtensible) && hdr.BitsPerSample == 16:
+		decode = func(b []byte) int16 { return int16(binary.LittleEndian.Uint16(b)) }
+	default:
+		return nil, fmt.Errorf("unsupported wav format %d with %d bits per sample", hdr.AudioFormat, hdr.BitsPerSample)
+	}
+
+	sampleSize := int(hdr.BitsPerSample / 8)
+	frameSize := sampleSize * int(hdr.NumChannels)
+	frames := len(audio) / frameSize
+	if frames == 0 {
+		return nil, errors.New("wav file has no audio data")
+	}
+
+	samples := make([]int16, frames)
+	channels := int(hdr.NumChannels)
+	for i := range samples {
+		frame := audio[i*frameSize : (i+1)*frameSize]
+		sum := 0
+		for c := 0; c < channels; c++ {
+			sum += int(decode(frame[c*sampleSize:]))
+		}
+		samples[i] = int16(sum / channels)
+	}
+	return samples, nil
+}
+
+// resampleLinear converts samples between sample rates. When downsampling,
+// each output sample averages the input samples it covers, which keeps
+// most of the aliasing out of the narrower band; when upsampling, samples
+// are interpolated linearly.
+func resampleLinear(samples []int16, from, to int) []int16 {
+	if from == to || len(samples) == 0 {
+		return samples
+	}
+
+	n := int(int64(len(samples)) * int64(to) / int64(from))
+	if n == 0 {
+		n = 1
+	}
+	out := make([]int16, n)
+	step := float64(from) / float64(to)
+
+	if from > to {
+		for i := range out {
+			start := int(float64(i) * step)
+			end := int(float64(i+1) * step)
+			if end > len(samples) {
+				end = len(samples)
+			}
+			if end <= start {
+				end = start + 1
+			}
+			sum := 0
+			for _, s := range samples[start:end] {
+				sum += int(s)
+			}
+			out[i] = int16(sum / (end - start))
+		}
+		return out
+	}
+
+	last := len(samples) - 1
+	for i := range out {
+		pos := float64(i) * step
+		j := int(pos)
+		if j >= last {
+			out[i] = samples[last]
+			continue
+		}
+		frac := pos - float64(j)
+		out[i] = int16(float64(samples[j])*(1-frac) + float64(samples[j+1])*frac)
+	}
+	return out
+}
+
+// writeG711WAVHeader writes a 44-byte WAV header for 8 kHz mono G.711
+// audio in the given WAV format.
+func writeG711WAVHeader(w io.Writer, format uint16, dataSize uint32) {
+	var hdr [wavHeaderSize]byte
+
+	copy(hdr[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:8], wavHeaderSize-8+dataSize)
+	copy(hdr[8:12], "WAVE")
+
+	copy(hdr[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(hdr[16:20], 16)
+	binary.LittleEndian.PutUint16(hdr[20:22], format)
+	binary.LittleEndian.PutUint16(hdr[22:24], 1)
+	binary.LittleEndian.PutUint32(hdr[24:28], promptSampleRate)
+	binary.LittleEndian.PutUint32(hdr[28:32], promptSampleRate)
+	binary.LittleEndian.PutUint16(hdr[32:34], 1)
+	binary.LittleEndian.PutUint16(hdr[34:36], 8)
+
+	copy(hdr[36:40], "data")
+	binary.LittleEndian.PutUint32(hdr[40:44], dataSize)
+
+	w.Write(hdr[:])
+}
@@ -0,0 +1,108 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// buildPCMWAV builds a 16-bit linear PCM WAV holding a 400 Hz tone on
+// every channel. dataSize overrides the data chunk size when non-zero.
+func buildPCMWAV(sampleRate uint32, channels uint16, frames int, dataSize uint32) []byte {
+	var audio bytes.Buffer
+	for i := 0; i < frames; i++ {
+		s := int16(8000 * math.Sin(2*math.Pi*400*float64(i)/float64(sampleRate)))
+		for c := uint16(0); c < channels; c++ {
+			binary.Write(&audio, binary.LittleEndian, s)
+		}
+	}
+	if dataSize == 0 {
+		dataSize = uint32(audio.Len())
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+audio.Len()))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(wavFormatPCM))
+	binary.Write(&buf, binary.LittleEndian, channels)
+	binary.Write(&buf, binary.LittleEndian, sampleRate)
+	binary.Write(&buf, binary.LittleEndian, sampleRate*uint32(channels)*2)
+	binary.Write(&buf, binary.LittleEndian, channels*2)
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, dataSize)
+	buf.Write(audio.Bytes())
+	return buf.Bytes()
+}
+
+func TestConvertWAV(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          []byte
+		payloadType int
+		wantFormat  uint16
+		wantSamples int
+	}{
+		{"22050 Hz mono to PCMU", buildPCMWAV(22050, 1, 22050, 0), PayloadPCMU, wavFormatPCMU, 8000},
+		{"16 kHz stereo to PCMA", buildPCMWAV(16000, 2, 8000, 0), PayloadPCMA, wavFormatPCMA, 4000},
+		{"6 kHz upsampled", buildPCMWAV(6000, 1, 6000, 0), PayloadPCMU, wavFormatPCMU, 8000},
+		{"streamed data size", buildPCMWAV(8000, 1, 800, 0xFFFFFFFF), PayloadPCMU, wavFormatPCMU, 800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ConvertWAV(tt.in, tt.payloadType)
+			if err != nil {
+				t.Fatalf("ConvertWAV: %v", err)
+			}
+			if err := ValidateWAVData(out); err != nil {
+				t.Fatalf("converted wav invalid: %v", err)
+			}
+			hdr, err := parseWAVHeader(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("parsing converted wav: %v", err)
+			}
+			if hdr.AudioFormat != tt.wantFormat {
+				t.Errorf("format = %d, want %d", hdr.AudioFormat, tt.wantFormat)
+			}
+			if int(hdr.DataSize) != tt.wantSamples || len(out) != wavHeaderSize+tt.wantSamples {
+				t.Errorf("data size = %d (%d bytes), want %d samples", hdr.DataSize, len(out), tt.wantSamples)
+			}
+		})
+	}
+}
+
+func TestConvertWAVPreservesLevel(t *testing.T) {
+	out, err := ConvertWAV(buildPCMWAV(16000, 1, 16000, 0), PayloadPCMU)
+	if err != nil {
+		t.Fatalf("ConvertWAV: %v", err)
+	}
+	peak := 0
+	for _, b := range out[wavHeaderSize:] {
+		s := int(ulawToLinear[b])
+		if s < 0 {
+			s = -s
+		}
+		peak = max(peak, s)
+	}
+	// A 400 Hz tone survives averaging pairs of samples nearly intact.
+	want := int(ulawToLinear[linearToUlaw[8000]])
+	if peak < want*9/10 || peak > want*11/10 {
+		t.Errorf("peak = %d, want about %d", peak, want)
+	}
+}
+
+func TestConvertWAVErrors(t *testing.T) {
+	if _, err := ConvertWAV([]byte("not a wav"), PayloadPCMU); err == nil {
+		t.Error("expected error for invalid data")
+	}
+	if _, err := ConvertWAV(buildPCMWAV(8000, 1, 100, 0), PayloadTelephoneEvent); err == nil {
+		t.Error("expected error for non-G.711 payload type")
+	}
+	if _, err := ConvertWAV(buildPCMWAV(8000, 1, 0, 0), PayloadPCMU); err == nil {
+		t.Error("expected error for empty audio")
+	}
+}
@@ -216,6 +216,17 @@ type DTMFCollector struct {
 	// string ("0"-"9", "*", "#", "A"-"D"). The channel is closed when
 	// the collector stops.
 	Digits chan string
+
+	// Audio, if set, is called with the payload and payload type of every
+	// RTP packet that is not a telephone-event, e.g. to record the caller
+	// while listening for digits. The payload is only valid for the
+	// duration of the call. Must be set before Run.
+	Audio func(payload []byte, payloadType int)
+
+	// SRTP, if set, authenticates and decrypts packets from an endpoint
+	// that uses SRTP. Packets that fail are dropped. Must be set before
+	// Run.
+	SRTP *SRTPContext
 }
 
 // collectorReadTimeout is the read deadline for the collector's UDP socket.
@@ -273,9 +284,18 @@ func (c *DTMFCollector) Run(ctx context.Context) {
 		}
 
 		pkt := buf[:n]
+		if c.SRTP != nil {
+			if pkt, err = c.SRTP.Unprotect(pkt[:0], pkt); err != nil {
+				continue
+			}
+			n = len(pkt)
+		}
 
 		pt := rtpPayloadType(pkt)
 		if pt != PayloadTelephoneEvent {
+			if c.Audio != nil && pt >= 0 && n > minRTPHeader {
+				c.Audio(pkt[minRTPHeader:], pt)
+			}
 			continue
 		}
 
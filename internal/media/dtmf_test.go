@@ -767,6 +767,74 @@ func TestDTMFCollector_IgnoresNonDTMFPackets(t *testing.T) {
 	}
 }
 
+func TestDTMFCollector_Audio(t *testing.T) {
+	logger := slog.Default()
+
+	collectorConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	if err != nil {
+		t.Fatalf("listen collector: %v", err)
+	}
+	defer collectorConn.Close()
+	collectorAddr := collectorConn.LocalAddr().(*net.UDPAddr)
+
+	sender, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	if err != nil {
+		t.Fatalf("listen sender: %v", err)
+	}
+	defer sender.Close()
+
+	type audioPacket struct {
+		payload     []byte
+		payloadType int
+	}
+	audio := make(chan audioPacket, 8)
+
+	collector := NewDTMFCollector(collectorConn, logger)
+	collector.Audio = func(payload []byte, payloadType int) {
+		audio <- audioPacket{payload: append([]byte(nil), payload...), payloadType: payloadType}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go collector.Run(ctx)
+
+	pkt := makeTestRTPPacket(PayloadPCMU, []byte{0x7F, 0x7E, 0x7D})
+	if _, err := sender.WriteToUDP(pkt, collectorAddr); err != nil {
+		t.Fatalf("send PCMU packet: %v", err)
+	}
+	dtmfPkt := makeDTMFRTPPacket(5, true, 10, 480, 5000)
+	if _, err := sender.WriteToUDP(dtmfPkt, collectorAddr); err != nil {
+		t.Fatalf("send DTMF packet: %v", err)
+	}
+
+	select {
+	case got := <-audio:
+		if got.payloadType != PayloadPCMU {
+			t.Errorf("audio payload type = %d, want %d", got.payloadType, PayloadPCMU)
+		}
+		if string(got.payload) != string([]byte{0x7F, 0x7E, 0x7D}) {
+			t.Errorf("audio payload = %x, want 7f7e7d", got.payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for audio")
+	}
+
+	select {
+	case digit := <-collector.Digits:
+		if digit != "5" {
+			t.Errorf("got digit %q, want %q", digit, "5")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for digit")
+	}
+
+	select {
+	case got := <-audio:
+		t.Errorf("telephone-event packet passed to audio callback: %+v", got)
+	default:
+	}
+}
+
 func TestDTMFCollector_ContextCancellation(t *testing.T) {
 	logger := slog.Default()
 
@@ -20,6 +20,10 @@ type MediaSession struct {
 
 	mu    sync.Mutex
 	relay *Relay
+
+	// srtp holds the caller and callee legs' SRTP keys, set with SetSRTP
+	// and applied when the relay starts.
+	srtp [2]*LegSRTP
 }
 
 // CreateMediaSession allocates a new media session with two port pairs
@@ -50,6 +54,19 @@ func (ms *MediaSession) Session() *Session {
 // Returns an error if the session is not in the New state or if a relay is
 // already running.
 func (ms *MediaSession) StartRelay(callerRemote, calleeRemote *net.UDPAddr, allowedPayloadTypes []int) error {
+	return ms.startRelay(callerRemote, calleeRemote, nil, allowedPayloadTypes)
+}
+
+// StartTranscodingRelay is like StartRelay, but also tells the relay which
+// audio codec each leg negotiated, so audio is transcoded when the legs'
+// codecs differ. Both legs' payload types are forwarded in addition to
+// allowedPayloadTypes.
+func (ms *MediaSession) StartTranscodingRelay(callerRemote, calleeRemote *net.UDPAddr, caller, callee LegCodec, allowedPayloadTypes []int) error {
+	return ms.startRelay(callerRemote, calleeRemote, &[2]LegCodec{caller, callee}, allowedPayloadTypes)
+}
+
+// startRelay starts the relay, setting the legs' codecs if given.
+func (ms *MediaSession) startRelay(callerRemote, calleeRemote *net.UDPAddr, legCodecs *[2]LegCodec, allowedPayloadTypes []int) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
@@ -62,7 +79,16 @@ func (ms *MediaSession) StartRelay(callerRemote, calleeRemote *net.UDPAddr, allo
 		return fmt.Errorf("cannot start relay: session %q in state %s, expected new", ms.session.ID, state)
 	}
 
-	ms.relay = NewRelay(ms.session, callerRemote, calleeRemote, allowedPayloadTypes, ms.logger)
+	relay := NewRelay(ms.session, callerRemote, calleeRemote, allowedPayloadTypes, ms.logger)
+	if err := relay.SetSRTP(ms.srtp[0], ms.srtp[1]); err != nil {
+		return fmt.Errorf("setting relay srtp: %w", err)
+	}
+	if legCodecs != nil {
+		if err := relay.SetCodecs(legCodecs[0], legCodecs[1]); err != nil {
+			return fmt.Errorf("setting relay codecs: %w", err)
+		}
+	}
+	ms.relay = relay
 	ms.relay.Start()
 
 	ms.logger.Info("media session relay started",
@@ -74,6 +100,61 @@ func (ms *MediaSession) StartRelay(callerRemote, calleeRemote *net.UDPAddr, allo
 	return nil
 }
 
+// SetSRTP sets the SRTP keys of the caller and callee legs, or nil for a
+// plain RTP leg. The relay terminates SRTP on each leg with keys. Must be
+// called before the relay is started.
+func (ms *MediaSession) SetSRTP(caller, callee *LegSRTP) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.relay != nil {
+		return fmt.Errorf("cannot set srtp: relay already started for session %q", ms.session.ID)
+	}
+	ms.srtp = [2]*LegSRTP{caller, callee}
+	return nil
+}
+
+// SetLegSRTP changes the SRTP keys of one leg, or with nil switches it to
+// plain RTP, e.g. when a re-INVITE rekeys the leg or a transfer puts a
+// new party on it. A running relay applies the keys immediately.
+func (ms *MediaSession) SetLegSRTP(callerSide bool, leg *LegSRTP) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	i := 0
+	if !callerSide {
+		i = 1
+	}
+	ms.srtp[i] = leg
+	if ms.relay == nil {
+		return nil
+	}
+	return ms.relay.SetLegSRTP(callerSide, leg)
+}
+
+// SRTP returns the SRTP keys of the caller or callee leg, or nil if the
+// leg uses plain RTP.
+func (ms *MediaSession) SRTP(callerSide bool) *LegSRTP {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if callerSide {
+		return ms.srtp[0]
+	}
+	return ms.srtp[1]
+}
+
+// OutboundSRTP returns the context that encrypts media sent to the caller
+// or callee leg, or nil if the leg uses plain RTP or no relay is running.
+func (ms *MediaSession) OutboundSRTP(callerSide bool) *SRTPContext {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return nil
+	}
+	return relay.OutboundSRTP(callerSide)
+}
+
 // SetRecorder attaches a call recorder to the relay. Both directions of
 // RTP audio will be fed to the recorder. Must be called after StartRelay.
 // Returns an error if no relay is running.
@@ -145,6 +226,139 @@ func (ms *MediaSession) CalleeAddr() *net.UDPAddr {
 	return relay.CalleeAddr()
 }
 
+// SetCallerRemote redirects the caller leg's outgoing RTP to a new remote
+// endpoint without interrupting the relay. Returns an error if no relay is
+// running.
+func (ms *MediaSession) SetCallerRemote(addr *net.UDPAddr) error {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return fmt.Errorf("cannot set caller remote: no relay running for session %q", ms.session.ID)
+	}
+	relay.SetCallerRemote(addr)
+	ms.logger.Info("caller leg remote updated", "caller_remote", addr.String())
+	return nil
+}
+
+// SetCalleeRemote redirects the callee leg's outgoing RTP to a new remote
+// endpoint without interrupting the relay. Returns an error if no relay is
+// running.
+func (ms *MediaSession) SetCalleeRemote(addr *net.UDPAddr) error {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return fmt.Errorf("cannot set callee remote: no relay running for session %q", ms.session.ID)
+	}
+	relay.SetCalleeRemote(addr)
+	ms.logger.Info("callee leg remote updated", "callee_remote", addr.String())
+	return nil
+}
+
+// SetCallerHeld starts or stops suppressing relayed media towards the caller
+// leg while it is on hold. Returns an error if no relay is running.
+func (ms *MediaSession) SetCallerHeld(held bool) error {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return fmt.Errorf("cannot hold caller: no relay running for session %q", ms.session.ID)
+	}
+	relay.SetCallerHeld(held)
+	ms.logger.Info("caller leg hold updated", "held", held)
+	return nil
+}
+
+// SetCalleeHeld starts or stops suppressing relayed media towards the callee
+// leg while it is on hold. Returns an error if no relay is running.
+func (ms *MediaSession) SetCalleeHeld(held bool) error {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return fmt.Errorf("cannot hold callee: no relay running for session %q", ms.session.ID)
+	}
+	relay.SetCalleeHeld(held)
+	ms.logger.Info("callee leg hold updated", "held", held)
+	return nil
+}
+
+// PayloadTypes returns the RTP payload types forwarded by the relay, or nil
+// if no relay is running.
+func (ms *MediaSession) PayloadTypes() []int {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return nil
+	}
+	return relay.PayloadTypes()
+}
+
+// Codecs returns the audio codec each leg negotiated, and false if no relay
+// is running or it was started without codecs.
+func (ms *MediaSession) Codecs() (caller, callee LegCodec, ok bool) {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return LegCodec{}, LegCodec{}, false
+	}
+	return relay.Codecs()
+}
+
+// SetLegCodec changes the audio codec one leg negotiated, e.g. after a
+// re-INVITE; see Relay.SetLegCodec. Returns an error if no relay is
+// running.
+func (ms *MediaSession) SetLegCodec(callerSide bool, codec LegCodec) error {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return fmt.Errorf("cannot set codec: no relay running for session %q", ms.session.ID)
+	}
+	if err := relay.SetLegCodec(callerSide, codec); err != nil {
+		return err
+	}
+	ms.logger.Info("media session leg codec changed",
+		"caller_side", callerSide,
+		"codec", codec.String(),
+		"transcoding", relay.Transcoding(),
+	)
+	return nil
+}
+
+// AttachMixer moves both legs of the call into m as the participants
+// callerID and calleeID; see Relay.AttachMixer. Returns an error if no
+// relay is running.
+func (ms *MediaSession) AttachMixer(m *Mixer, callerID, calleeID string, caller, callee LegCodec) error {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return fmt.Errorf("cannot attach mixer: no relay running for session %q", ms.session.ID)
+	}
+	if err := relay.AttachMixer(m, callerID, calleeID, caller, callee); err != nil {
+		return err
+	}
+	ms.logger.Info("media session legs attached to mixer")
+	return nil
+}
+
+// DetachMixer takes the legs out of the mixer set with AttachMixer and
+// resumes relaying between them.
+func (ms *MediaSession) DetachMixer() {
+	ms.mu.Lock()
+	relay := ms.relay
+	ms.mu.Unlock()
+	if relay == nil {
+		return
+	}
+	relay.DetachMixer()
+	ms.logger.Info("media session legs detached from mixer")
+}
+
 // CallerRTPPort returns the local RTP port allocated for the caller leg.
 func (ms *MediaSession) CallerRTPPort() int {
 	return ms.session.CallerLeg.Ports.RTP
@@ -13,142 +13,28 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
-)
-
-// G.711 u-law (PCMU) decoding table: maps each u-law byte to a 16-bit linear PCM sample.
-var ulawToLinear [256]int16
-
-// G.711 a-law (PCMA) decoding table: maps each a-law byte to a 16-bit linear PCM sample.
-var alawToLinear [256]int16
 
-// G.711 u-law encoding table: maps 14-bit magnitude to a u-law byte.
-// Precomputed for the full 16-bit signed range (we use magnitude + sign).
-var linearToUlaw [65536]uint8
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
+)
 
-// G.711 a-law encoding table: maps 16-bit signed sample to an a-law byte.
-var linearToAlaw [65536]uint8
+// G.711 lookup tables shared by the player, recorders and WAV conversion,
+// built from the codecs package. Samples are 16-bit linear PCM.
+var (
+	ulawToLinear [256]int16
+	alawToLinear [256]int16
+	linearToUlaw [65536]uint8
+	linearToAlaw [65536]uint8
+)
 
 func init() {
-	// Build u-law decode table.
 	for i := 0; i < 256; i++ {
-		ulawToLinear[i] = decodeUlaw(uint8(i))
-	}
-	// Build a-law decode table.
-	for i := 0; i < 256; i++ {
-		alawToLinear[i] = decodeAlaw(uint8(i))
-	}
-	// Build u-law encode table.
-	for i := -32768; i <= 32767; i++ {
-		linearToUlaw[uint16(int16(i))] = encodeUlaw(int16(i))
+		ulawToLinear[i] = codecs.DecodeUlaw(uint8(i))
+		alawToLinear[i] = codecs.DecodeAlaw(uint8(i))
 	}
-	// Build a-law encode table.
 	for i := -32768; i <= 32767; i++ {
-		linearToAlaw[uint16(int16(i))] = encodeAlaw(int16(i))
-	}
-}
-
-// decodeUlaw converts a u-law byte to a 16-bit linear PCM sample.
-func decodeUlaw(u uint8) int16 {
-	// Complement to obtain the original code.
-	u = ^u
-	sign := int16(1)
-	if u&0x80 != 0 {
-		sign = -1
-		u &= 0x7F
-	}
-	exponent := int((u >> 4) & 0x07)
-	mantissa := int(u & 0x0F)
-	// Reconstruct the magnitude.
-	sample := int16((mantissa<<(uint(exponent)+1) | (1 << uint(exponent)) | (1 << uint(exponent)) - 1 + (1 << uint(exponent))) - 33 + (1 << uint(exponent)))
-	// Simplified standard formula:
-	sample = int16(((2*mantissa + 33) << uint(exponent)) - 33)
-	return sign * sample
-}
-
-// decodeAlaw converts an a-law byte to a 16-bit linear PCM sample.
-func decodeAlaw(a uint8) int16 {
-	a ^= 0x55
-	sign := int16(1)
-	if a&0x80 != 0 {
-		a &= 0x7F
-	} else {
-		sign = -1
-	}
-	exponent := int((a >> 4) & 0x07)
-	mantissa := int(a & 0x0F)
-	var sample int16
-	if exponent == 0 {
-		sample = int16(mantissa<<4 | 0x08)
-	} else {
-		sample = int16((mantissa<<4 | 0x108) << uint(exponent-1))
-	}
-	return sign * sample
-}
-
-// encodeUlaw converts a 16-bit linear PCM sample to a u-law byte.
-func encodeUlaw(sample int16) uint8 {
-	// Bias and clamp.
-	const bias = 0x84
-	const clip = 32635
-
-	sign := uint8(0)
-	if sample < 0 {
-		sign = 0x80
-		sample = -sample
-	}
-	if sample > clip {
-		sample = clip
-	}
-	sample += bias
-
-	exponent := 7
-	mask := int16(0x4000)
-	for exponent > 0 {
-		if sample&mask != 0 {
-			break
-		}
-		exponent--
-		mask >>= 1
-	}
-
-	mantissa := (sample >> (uint(exponent) + 3)) & 0x0F
-	uval := ^(sign | uint8(exponent<<4) | uint8(mantissa))
-	return uval
-}
-
-// encodeAlaw converts a 16-bit linear PCM sample to an a-law byte.
-func encodeAlaw(sample int16) uint8 {
-	sign := uint8(0x55)
-	if sample < 0 {
-		sample = -sample
-		sign = 0xD5
-	}
-
-	if sample > 4095 {
-		sample = 4095
-	}
-
-	var exponent int
-	var mantissa int
-	if sample < 256 {
-		exponent = 0
-		mantissa = int(sample) >> 4
-	} else {
-		exp := 1
-		expMask := int16(512)
-		for exp < 7 {
-			if sample < expMask<<1 {
-				break
-			}
-			exp++
-			expMask <<= 1
-		}
-		exponent = exp
-		mantissa = (int(sample) >> uint(exponent+3)) & 0x0F
+		linearToUlaw[uint16(int16(i))] = codecs.EncodeUlaw(int16(i))
+		linearToAlaw[uint16(int16(i))] = codecs.EncodeAlaw(int16(i))
 	}
-
-	aval := uint8(exponent<<4 | mantissa)
-	return aval ^ sign
 }
 
 // MixerParticipant represents a single participant in a conference mix.
@@ -161,14 +47,35 @@ type MixerParticipant struct {
 	muted atomic.Bool
 
 	// Socket is the RTP socket pair allocated for this participant's leg.
+	// nil for a participant attached with AttachParticipant.
 	Socket *SocketPair
 
 	// Remote is the learned remote RTP address for this participant.
 	remote *atomicAddr
 
-	// payloadType is the negotiated audio codec (PayloadPCMU or PayloadPCMA).
+	// inbox and send carry the media of a participant attached with
+	// AttachParticipant in place of a socket: packets handed to Feed
+	// are queued on inbox, and its mix is passed to send.
+	inbox chan []byte
+	send  func(pkt []byte)
+
+	// deaf is the set of participant IDs this participant does not hear,
+	// replaced as a whole by SetHears so the mix loop can read it
+	// without locking.
+	deaf atomic.Pointer[map[string]bool]
+
+	// payloadType is the RTP payload type of the negotiated audio codec.
 	payloadType int
 
+	// decoder and encoder convert the participant's codec to and from
+	// the mixer's 8 kHz linear audio.
+	decoder codecs.Decoder
+	encoder codecs.Encoder
+
+	// tsStep is the RTP timestamp increment of one packet in the
+	// participant's codec clock.
+	tsStep uint32
+
 	// ssrc is the RTP SSRC for outbound packets to this participant.
 	ssrc uint32
 
@@ -185,6 +92,9 @@ type MixerParticipant struct {
 	// hasAudio indicates whether lastAudio contains valid data for the
 	// current mix cycle.
 	hasAudio bool
+
+	// pcm is scratch space for decoding, used by the mix goroutine.
+	pcm []int16
 }
 
 // SetMuted sets the mute state for this participant.
@@ -197,18 +107,33 @@ func (p *MixerParticipant) IsMuted() bool {
 	return p.muted.Load()
 }
 
+// hears reports whether this participant hears the participant with the
+// given ID.
+func (p *MixerParticipant) hears(id string) bool {
+	deaf := p.deaf.Load()
+	return deaf == nil || !(*deaf)[id]
+}
+
+// mixSampleRate is the sample rate conference audio is mixed at.
+const mixSampleRate = 8000
+
 // Mixer implements N-way audio mixing for conference bridges.
 //
 // Architecture: The mixer allocates one RTP socket pair per participant.
 // A single mix goroutine runs at the ptime interval (20ms). On each cycle:
 //  1. Read one RTP packet from each participant's socket (non-blocking).
-//  2. Decode G.711 audio to linear PCM (16-bit signed).
+//  2. Decode the audio to 8 kHz linear PCM (16-bit signed).
 //  3. For each participant, sum all OTHER participants' decoded audio (N-1 mix).
-//  4. Encode the mixed PCM back to the participant's G.711 codec.
+//  4. Encode the mixed PCM back to the participant's codec.
 //  5. Send the mixed RTP packet to the participant.
 //
+// Participants may use any codec in the codecs registry (G.711, G.722 and,
+// in builds with libopus, Opus); wideband audio is mixed at 8 kHz.
+//
 // This "decode, mix, encode" approach ensures each participant hears all
 // other participants mixed together, but not their own audio (avoiding echo).
+// SetHears narrows who hears whom, e.g. for a supervisor listening in on a
+// call without being heard.
 type Mixer struct {
 	proxy  *Proxy
 	logger *slog.Logger
@@ -247,14 +172,22 @@ func (m *Mixer) SetRecorder(rec *ConferenceRecorder) {
 
 // AddParticipant allocates an RTP socket pair for a new participant and
 // registers them in the mixer. The remote address is the participant's
-// far-end RTP address from SDP negotiation. payloadType must be PayloadPCMU
-// or PayloadPCMA.
+// far-end RTP address from SDP negotiation, and codec the audio codec it
+// negotiated.
 //
 // Returns the allocated SocketPair (for SDP rewriting) and an error if
 // allocation fails.
-func (m *Mixer) AddParticipant(id string, remote *net.UDPAddr, payloadType int) (*SocketPair, error) {
-	if payloadType != PayloadPCMU && payloadType != PayloadPCMA {
-		return nil, fmt.Errorf("unsupported conference codec: payload type %d, only PCMU (0) and PCMA (8) supported", payloadType)
+func (m *Mixer) AddParticipant(id string, remote *net.UDPAddr, codec LegCodec) (*SocketPair, error) {
+	if codec.Codec == nil {
+		return nil, fmt.Errorf("unsupported conference codec: payload type %d", codec.PayloadType)
+	}
+	decoder, err := codec.Codec.NewDecoderAt(mixSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported conference codec %s: %w", codec.Codec.Name, err)
+	}
+	encoder, err := codec.Codec.NewEncoderAt(mixSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported conference codec %s: %w", codec.Codec.Name, err)
 	}
 
 	m.mu.Lock()
@@ -273,7 +206,10 @@ func (m *Mixer) AddParticipant(id string, remote *net.UDPAddr, payloadType int)
 		ID:          id,
 		Socket:      pair,
 		remote:      newAtomicAddr(remote),
-		payloadType: payloadType,
+		payloadType: codec.PayloadType,
+		decoder:     decoder,
+		encoder:     encoder,
+		tsStep:      uint32(samplesPerPacket * codec.Codec.ClockRate / mixSampleRate),
 		ssrc:        rand.Uint32(),
 		seq:         uint16(rand.UintN(65536)),
 		ts:          rand.Uint32(),
@@ -285,13 +221,113 @@ func (m *Mixer) AddParticipant(id string, remote *net.UDPAddr, payloadType int)
 		"participant_id", id,
 		"rtp_port", pair.Ports.RTP,
 		"remote", remote.String(),
-		"payload_type", payloadType,
+		"codec", codec.String(),
 		"total_participants", len(m.participants),
 	)
 
 	return pair, nil
 }
 
+// fedQueueLen is how many packets handed to Feed are queued for an
+// attached participant before the oldest are dropped.
+const fedQueueLen = 4
+
+// AttachParticipant registers a participant whose media is carried by
+// another component, e.g. one leg of a call's relay, rather than a socket
+// of the mixer's own. Its RTP packets in the given codec are handed to the
+// mixer with Feed, and the RTP packets of its mix are passed to send from
+// the mix goroutine.
+func (m *Mixer) AttachParticipant(id string, codec LegCodec, send func(pkt []byte)) error {
+	if codec.Codec == nil {
+		return fmt.Errorf("unsupported mixer codec: payload type %d", codec.PayloadType)
+	}
+	decoder, err := codec.Codec.NewDecoderAt(mixSampleRate)
+	if err != nil {
+		return fmt.Errorf("unsupported mixer codec %s: %w", codec.Codec.Name, err)
+	}
+	encoder, err := codec.Codec.NewEncoderAt(mixSampleRate)
+	if err != nil {
+		return fmt.Errorf("unsupported mixer codec %s: %w", codec.Codec.Name, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.participants[id]; exists {
+		return fmt.Errorf("participant %q already in mixer", id)
+	}
+	m.participants[id] = &MixerParticipant{
+		ID:          id,
+		inbox:       make(chan []byte, fedQueueLen),
+		send:        send,
+		payloadType: codec.PayloadType,
+		decoder:     decoder,
+		encoder:     encoder,
+		tsStep:      uint32(samplesPerPacket * codec.Codec.ClockRate / mixSampleRate),
+		ssrc:        rand.Uint32(),
+		seq:         uint16(rand.UintN(65536)),
+		ts:          rand.Uint32(),
+	}
+
+	m.logger.Info("participant attached to mixer",
+		"participant_id", id,
+		"codec", codec.String(),
+		"total_participants", len(m.participants),
+	)
+	return nil
+}
+
+// Feed queues an RTP packet received from a participant attached with
+// AttachParticipant. The packet is copied. When the mixer falls behind,
+// the oldest queued packet is dropped.
+func (m *Mixer) Feed(id string, pkt []byte) {
+	m.mu.RLock()
+	p := m.participants[id]
+	m.mu.RUnlock()
+	if p == nil || p.inbox == nil {
+		return
+	}
+
+	cp := append([]byte(nil), pkt...)
+	for {
+		select {
+		case p.inbox <- cp:
+			return
+		default:
+		}
+		select {
+		case <-p.inbox:
+		default:
+		}
+	}
+}
+
+// SetHears sets whether one participant hears another. By default every
+// participant hears all the others. Returns an error if the listener is
+// not in the mixer.
+func (m *Mixer) SetHears(listener, speaker string, hears bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.participants[listener]
+	if !ok {
+		return fmt.Errorf("participant %q not in mixer", listener)
+	}
+	deaf := make(map[string]bool)
+	if old := p.deaf.Load(); old != nil {
+		for id := range *old {
+			deaf[id] = true
+		}
+	}
+	if hears {
+		delete(deaf, speaker)
+	} else {
+		deaf[speaker] = true
+	}
+	p.deaf.Store(&deaf)
+	return nil
+}
+
 // RemoveParticipant removes a participant from the mixer and releases their
 // RTP port pair. Returns an error if the participant is not found.
 func (m *Mixer) RemoveParticipant(id string) error {
@@ -305,7 +341,9 @@ func (m *Mixer) RemoveParticipant(id string) error {
 	count := len(m.participants)
 	m.mu.Unlock()
 
-	m.proxy.Release(p.Socket)
+	if p.Socket != nil {
+		m.proxy.Release(p.Socket)
+	}
 
 	m.logger.Info("participant removed from conference",
 		"participant_id", id,
@@ -371,7 +409,9 @@ func (m *Mixer) Release() {
 	m.mu.Unlock()
 
 	for _, p := range participants {
-		m.proxy.Release(p.Socket)
+		if p.Socket != nil {
+			m.proxy.Release(p.Socket)
+		}
 	}
 
 	m.logger.Info("conference mixer released",
@@ -389,7 +429,7 @@ func (m *Mixer) mixLoop(ctx context.Context) {
 	defer ticker.Stop()
 
 	buf := make([]byte, maxRTPPacket)
-	outPkt := make([]byte, rtpHeaderSize+samplesPerPacket)
+	outPkt := make([]byte, rtpHeaderSize, maxRTPPacket)
 
 	for {
 		select {
@@ -427,23 +467,10 @@ func (m *Mixer) mixCycle(readBuf, outPkt []byte) {
 	for _, p := range parts {
 		p.hasAudio = false
 
-		// Non-blocking read with short deadline.
-		p.Socket.RTPConn.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
-		n, srcAddr, err := p.Socket.RTPConn.ReadFromUDP(readBuf)
-		if err != nil {
-			if !errors.Is(err, os.ErrDeadlineExceeded) {
-				m.logger.Debug("conference read error",
-					"participant_id", p.ID,
-					"error", err,
-				)
-			}
-			continue
-		}
-
-		pkt := readBuf[:n]
+		pkt, srcAddr := m.readPacket(p, readBuf)
 
 		// Validate minimum RTP size.
-		if n < minRTPHeader+1 {
+		if len(pkt) < minRTPHeader+1 {
 			continue
 		}
 
@@ -454,13 +481,24 @@ func (m *Mixer) mixCycle(readBuf, outPkt []byte) {
 		}
 
 		// Symmetric RTP: learn actual remote address from first packet.
-		p.remote.update(srcAddr)
+		if srcAddr != nil {
+			p.remote.update(srcAddr)
+		}
 
-		// Decode G.711 payload to linear PCM.
-		payload := pkt[minRTPHeader:]
-		samples := len(payload)
-		if samples > samplesPerPacket {
-			samples = samplesPerPacket
+		// Decode the payload to linear PCM. Muted participants are still
+		// decoded so stateful codecs follow the stream.
+		payload := rtpPayload(pkt)
+		if len(payload) == 0 {
+			continue
+		}
+		var err error
+		p.pcm, err = p.decoder.Decode(p.pcm[:0], payload)
+		if err != nil {
+			m.logger.Debug("conference decode error",
+				"participant_id", p.ID,
+				"error", err,
+			)
+			continue
 		}
 
 		if p.IsMuted() {
@@ -468,16 +506,7 @@ func (m *Mixer) mixCycle(readBuf, outPkt []byte) {
 			continue
 		}
 
-		switch pt {
-		case PayloadPCMU:
-			for i := 0; i < samples; i++ {
-				p.lastAudio[i] = ulawToLinear[payload[i]]
-			}
-		case PayloadPCMA:
-			for i := 0; i < samples; i++ {
-				p.lastAudio[i] = alawToLinear[payload[i]]
-			}
-		}
+		samples := copy(p.lastAudio[:], p.pcm)
 		// Zero-fill remaining samples if packet was short.
 		for i := samples; i < samplesPerPacket; i++ {
 			p.lastAudio[i] = 0
@@ -515,6 +544,7 @@ func (m *Mixer) mixCycle(readBuf, outPkt []byte) {
 	// Phase 2: For each participant, compute the N-1 mix (sum of all others)
 	// and send the mixed packet.
 	var mixBuf [samplesPerPacket]int32
+	var frame [samplesPerPacket]int16
 
 	for _, dest := range parts {
 		// Sum all other participants' audio.
@@ -527,7 +557,7 @@ func (m *Mixer) mixCycle(readBuf, outPkt []byte) {
 			if src.ID == dest.ID {
 				continue
 			}
-			if !src.hasAudio {
+			if !src.hasAudio || !dest.hears(src.ID) {
 				continue
 			}
 			hasInput = true
@@ -548,41 +578,39 @@ func (m *Mixer) mixCycle(readBuf, outPkt []byte) {
 			// No audio from anyone else; send silence or skip.
 			// Advance sequence/timestamp to maintain timing.
 			dest.seq++
-			dest.ts += timestampIncrement
+			dest.ts += dest.tsStep
 			continue
 		}
 
 		// Clamp to 16-bit range and encode to the destination's codec.
-		switch dest.payloadType {
-		case PayloadPCMU:
-			for i := 0; i < samplesPerPacket; i++ {
-				s := mixBuf[i]
-				if s > 32767 {
-					s = 32767
-				} else if s < -32768 {
-					s = -32768
-				}
-				outPkt[rtpHeaderSize+i] = linearToUlaw[uint16(int16(s))]
-			}
-		case PayloadPCMA:
-			for i := 0; i < samplesPerPacket; i++ {
-				s := mixBuf[i]
-				if s > 32767 {
-					s = 32767
-				} else if s < -32768 {
-					s = -32768
-				}
-				outPkt[rtpHeaderSize+i] = linearToAlaw[uint16(int16(s))]
+		for i := 0; i < samplesPerPacket; i++ {
+			s := mixBuf[i]
+			if s > 32767 {
+				s = 32767
+			} else if s < -32768 {
+				s = -32768
 			}
+			frame[i] = int16(s)
+		}
+		pkt, err := dest.encoder.Encode(outPkt[:rtpHeaderSize], frame[:])
+		if err != nil {
+			m.logger.Debug("conference encode error",
+				"participant_id", dest.ID,
+				"error", err,
+			)
+			dest.seq++
+			dest.ts += dest.tsStep
+			continue
 		}
 
 		// Build RTP header.
-		buildRTPHeader(outPkt[:rtpHeaderSize], dest.payloadType, false, dest.seq, dest.ts, dest.ssrc)
+		buildRTPHeader(pkt[:rtpHeaderSize], dest.payloadType, false, dest.seq, dest.ts, dest.ssrc)
 
 		// Send to participant.
-		remote := dest.remote.load()
-		if remote != nil {
-			if _, err := dest.Socket.RTPConn.WriteToUDP(outPkt[:rtpHeaderSize+samplesPerPacket], remote); err != nil {
+		if dest.send != nil {
+			dest.send(pkt)
+		} else if remote := dest.remote.load(); remote != nil {
+			if _, err := dest.Socket.RTPConn.WriteToUDP(pkt, remote); err != nil {
 				m.logger.Debug("conference write error",
 					"participant_id", dest.ID,
 					"error", err,
@@ -591,17 +619,46 @@ func (m *Mixer) mixCycle(readBuf, outPkt []byte) {
 		}
 
 		dest.seq++
-		dest.ts += timestampIncrement
+		dest.ts += dest.tsStep
 	}
 }
 
+// readPacket returns the next RTP packet from a participant, read from its
+// socket into buf or taken from its Feed queue, and the address a socket
+// packet came from. It returns nil if the participant has sent nothing
+// this cycle.
+func (m *Mixer) readPacket(p *MixerParticipant, buf []byte) ([]byte, *net.UDPAddr) {
+	if p.Socket == nil {
+		select {
+		case pkt := <-p.inbox:
+			return pkt, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	// Non-blocking read with short deadline.
+	p.Socket.RTPConn.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+	n, srcAddr, err := p.Socket.RTPConn.ReadFromUDP(buf)
+	if err != nil {
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			m.logger.Debug("conference read error",
+				"participant_id", p.ID,
+				"error", err,
+			)
+		}
+		return nil, nil
+	}
+	return buf[:n], srcAddr
+}
+
 // PortForParticipant returns the local RTP port allocated for the given
 // participant. Returns 0 if the participant is not found.
 func (m *Mixer) PortForParticipant(id string) int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	p, ok := m.participants[id]
-	if !ok {
+	if !ok || p.Socket == nil {
 		return 0
 	}
 	return p.Socket.Ports.RTP
@@ -0,0 +1,88 @@
+package media
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
+)
+
+// toneFrame returns a PCMU RTP packet of a constant sample value.
+func toneFrame(t *testing.T, value int16) []byte {
+	t.Helper()
+	enc, _ := codecs.PCMU.NewEncoder()
+	frame := make([]int16, samplesPerPacket)
+	for i := range frame {
+		frame[i] = value
+	}
+	payload, err := enc.Encode(nil, frame)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return makeTestRTPPacket(PayloadPCMU, payload)
+}
+
+// frameLevel decodes a PCMU RTP packet and returns its first sample.
+func frameLevel(t *testing.T, pkt []byte) int16 {
+	t.Helper()
+	dec, _ := codecs.PCMU.NewDecoder()
+	pcm, err := dec.Decode(nil, rtpPayload(pkt))
+	if err != nil || len(pcm) == 0 {
+		t.Fatalf("Decode: %v", err)
+	}
+	return pcm[0]
+}
+
+func TestMixerAttachedParticipantsAndHears(t *testing.T) {
+	m := NewMixer(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	pcmu := LegCodec{PayloadType: PayloadPCMU, Codec: codecs.PCMU}
+
+	got := make(map[string][]byte)
+	for _, id := range []string{"caller", "callee", "supervisor"} {
+		err := m.AttachParticipant(id, pcmu, func(pkt []byte) {
+			got[id] = append([]byte(nil), pkt...)
+		})
+		if err != nil {
+			t.Fatalf("AttachParticipant(%s): %v", id, err)
+		}
+	}
+	if err := m.AttachParticipant("caller", pcmu, func([]byte) {}); err == nil {
+		t.Error("expected error attaching a duplicate participant")
+	}
+
+	// Whisper: the supervisor hears both parties, the callee hears the
+	// supervisor, the caller does not.
+	if err := m.SetHears("caller", "supervisor", false); err != nil {
+		t.Fatalf("SetHears: %v", err)
+	}
+	if err := m.SetHears("bogus", "caller", false); err == nil {
+		t.Error("expected error for unknown listener")
+	}
+
+	levels := map[string]int16{"caller": 1000, "callee": 2000, "supervisor": 4000}
+	for id, level := range levels {
+		m.Feed(id, toneFrame(t, level))
+	}
+	m.mixCycle(make([]byte, maxRTPPacket), make([]byte, rtpHeaderSize, maxRTPPacket))
+
+	want := map[string]int16{"caller": 2000, "callee": 5000, "supervisor": 3000}
+	for id, level := range want {
+		pkt, ok := got[id]
+		if !ok {
+			t.Errorf("%s was sent no mix", id)
+			continue
+		}
+		// Allow for G.711 quantisation.
+		if diff := int(frameLevel(t, pkt)) - int(level); diff < -200 || diff > 200 {
+			t.Errorf("%s heard %d, want about %d", id, frameLevel(t, pkt), level)
+		}
+	}
+
+	if err := m.RemoveParticipant("supervisor"); err != nil {
+		t.Fatalf("RemoveParticipant: %v", err)
+	}
+	if n := m.ParticipantCount(); n != 2 {
+		t.Errorf("participant count = %d, want 2", n)
+	}
+}
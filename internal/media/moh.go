@@ -0,0 +1,297 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mohListenerBuffer is how many frames a hold music listener may fall
+// behind the stream before frames are dropped for it.
+const mohListenerBuffer = 10
+
+// ErrMOHStreamEnded is returned by Player.PlayStream when the hold music
+// stream stops because none of its files can be played.
+var ErrMOHStreamEnded = errors.New("hold music stream ended")
+
+// MOHFrame is 20ms of G.711 hold music. Samples is shared by every
+// listener of the stream and must not be modified.
+type MOHFrame struct {
+	PayloadType int
+	Samples     []byte
+}
+
+// MOHStream plays a playlist of G.711 audio files in real time and fans
+// each 20ms frame out to all of its listeners, so any number of held
+// calls share one file reader. Files are WAV, or raw a-law (.alaw, .al)
+// or u-law (.ulaw, .ul) audio. The stream runs only while it has
+// listeners; a listener that falls behind loses frames rather than
+// delaying the others.
+type MOHStream struct {
+	files   []string
+	shuffle bool
+	logger  *slog.Logger
+
+	mu        sync.Mutex
+	listeners map[*MOHListener]struct{}
+
+	// stop cancels the goroutine reading the playlist, or is nil when
+	// the stream is not running.
+	stop context.CancelFunc
+}
+
+// NewMOHStream creates a hold music stream playing files in order, or in
+// a new random order on every pass if shuffle is set.
+func NewMOHStream(files []string, shuffle bool, logger *slog.Logger) *MOHStream {
+	return &MOHStream{
+		files:     files,
+		shuffle:   shuffle,
+		logger:    logger.With("subsystem", "moh"),
+		listeners: make(map[*MOHListener]struct{}),
+	}
+}
+
+// MOHListener receives the frames of a hold music stream.
+type MOHListener struct {
+	stream *MOHStream
+	frames chan MOHFrame
+}
+
+// Listen adds a listener to the stream, starting it if it is idle. The
+// listener must be closed when no longer needed.
+func (s *MOHStream) Listen() *MOHListener {
+	l := &MOHListener{
+		stream: s,
+		frames: make(chan MOHFrame, mohListenerBuffer),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners[l] = struct{}{}
+	if s.stop == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stop = cancel
+		go s.run(ctx)
+	}
+	return l
+}
+
+// Listeners returns the number of listeners on the stream.
+func (s *MOHStream) Listeners() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.listeners)
+}
+
+// Frames returns the channel the listener's frames arrive on. It is
+// closed when the listener is closed or the stream ends.
+func (l *MOHListener) Frames() <-chan MOHFrame {
+	return l.frames
+}
+
+// Close removes the listener from its stream, stopping the stream if it
+// was the last one. Closing a listener twice is a no-op.
+func (l *MOHListener) Close() {
+	s := l.stream
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.listeners[l]; !ok {
+		return
+	}
+	delete(s.listeners, l)
+	close(l.frames)
+	if len(s.listeners) == 0 && s.stop != nil {
+		s.stop()
+		s.stop = nil
+	}
+}
+
+// run plays the playlist over and over until ctx is cancelled. If a whole
+// pass produces no audio the stream ends and its listeners are closed.
+func (s *MOHStream) run(ctx context.Context) {
+	clock := &mohClock{start: time.Now()}
+	for {
+		played := false
+		for _, path := range s.order() {
+			n, err := s.playFile(ctx, path, clock)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				s.logger.Warn("skipping unplayable hold music file",
+					"file", path,
+					"error", err,
+				)
+				continue
+			}
+			if n > 0 {
+				played = true
+			}
+		}
+		if !played {
+			s.logger.Error("hold music playlist has nothing to play",
+				"files", len(s.files),
+			)
+			s.end(ctx)
+			return
+		}
+	}
+}
+
+// order returns the files in the order of the next pass.
+func (s *MOHStream) order() []string {
+	files := append([]string(nil), s.files...)
+	if s.shuffle {
+		rand.Shuffle(len(files), func(i, j int) {
+			files[i], files[j] = files[j], files[i]
+		})
+	}
+	return files
+}
+
+// end closes all listeners of a stream that cannot play anything. A later
+// Listen starts it again.
+func (s *MOHStream) end(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	for l := range s.listeners {
+		delete(s.listeners, l)
+		close(l.frames)
+	}
+	s.stop()
+	s.stop = nil
+}
+
+// mohClock paces a stream's frames at 20ms intervals of wall-clock time
+// across files.
+type mohClock struct {
+	start time.Time
+	sent  int
+}
+
+// wait sleeps until the next frame is due, or returns false if ctx is
+// cancelled first. A stream that fell far behind (e.g. after the host
+// was suspended) restarts its clock instead of sending a burst.
+func (c *mohClock) wait(ctx context.Context) bool {
+	c.sent++
+	sleep := time.Until(c.start.Add(time.Duration(c.sent) * packetDuration))
+	if sleep < -5*packetDuration {
+		c.start = time.Now().Add(-time.Duration(c.sent) * packetDuration)
+		return ctx.Err() == nil
+	}
+	if sleep <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(sleep)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// playFile sends the audio of one file to the listeners, paced by clock.
+// Returns the number of frames sent.
+func (s *MOHStream) playFile(ctx context.Context, path string, clock *mohClock) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening audio file: %w", err)
+	}
+	defer f.Close()
+
+	pt, size, err := g711Audio(f, path)
+	if err != nil {
+		return 0, err
+	}
+
+	r := io.LimitReader(f, int64(size))
+	frames := 0
+	for {
+		samples := make([]byte, samplesPerPacket)
+		n, err := io.ReadFull(r, samples)
+		if n == 0 {
+			if err != nil && !errors.Is(err, io.EOF) {
+				return frames, fmt.Errorf("reading audio data: %w", err)
+			}
+			return frames, nil
+		}
+		if n < samplesPerPacket {
+			silence := byte(0xFF)
+			if pt == PayloadPCMA {
+				silence = 0xD5
+			}
+			for i := n; i < samplesPerPacket; i++ {
+				samples[i] = silence
+			}
+		}
+
+		if !clock.wait(ctx) {
+			return frames, ctx.Err()
+		}
+		s.fanOut(ctx, MOHFrame{PayloadType: pt, Samples: samples})
+		frames++
+	}
+}
+
+// fanOut hands a frame to every listener that has room for it.
+func (s *MOHStream) fanOut(ctx context.Context, frame MOHFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	for l := range s.listeners {
+		select {
+		case l.frames <- frame:
+		default:
+		}
+	}
+}
+
+// g711Audio positions r at the start of the audio of a hold music file
+// and returns its payload type and size in bytes. Files named .alaw, .al,
+// .ulaw or .ul are raw G.711; anything else must be a G.711 WAV file.
+func g711Audio(r io.ReadSeeker, path string) (int, uint32, error) {
+	var pt int
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".alaw", ".al":
+		pt = PayloadPCMA
+	case ".ulaw", ".ul":
+		pt = PayloadPCMU
+	default:
+		hdr, err := parseWAVHeader(r)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing wav header: %w", err)
+		}
+		if pt, err = payloadTypeForWAV(hdr.AudioFormat); err != nil {
+			return 0, 0, err
+		}
+		if hdr.NumChannels != 1 || hdr.SampleRate != 8000 || hdr.BitsPerSample != 8 {
+			return 0, 0, fmt.Errorf("wav file must be 8000 Hz 8-bit mono, got %d Hz %d-bit %d channels",
+				hdr.SampleRate, hdr.BitsPerSample, hdr.NumChannels)
+		}
+		return pt, hdr.DataSize, nil
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, fmt.Errorf("sizing raw audio: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("rewinding raw audio: %w", err)
+	}
+	return pt, uint32(size), nil
+}
@@ -0,0 +1,71 @@
+package media
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMOHStream_SharedFrames(t *testing.T) {
+	// 3 packets of u-law WAV followed by 1 packet of raw a-law.
+	wav := createTestWAV(t, wavFormatPCMU, 8000, 1, 8, 480)
+	raw := filepath.Join(t.TempDir(), "tone.alaw")
+	if err := os.WriteFile(raw, bytes.Repeat([]byte{0x55}, samplesPerPacket), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := NewMOHStream([]string{wav, raw}, false, logger)
+
+	a := s.Listen()
+	b := s.Listen()
+	if got := s.Listeners(); got != 2 {
+		t.Fatalf("Listeners() = %d, want 2", got)
+	}
+
+	want := []int{PayloadPCMU, PayloadPCMU, PayloadPCMU, PayloadPCMA}
+	for i, pt := range want {
+		for _, l := range []*MOHListener{a, b} {
+			select {
+			case f := <-l.Frames():
+				if f.PayloadType != pt || len(f.Samples) != samplesPerPacket {
+					t.Fatalf("frame %d = pt %d, %d samples, want pt %d", i, f.PayloadType, len(f.Samples), pt)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("frame %d not received", i)
+			}
+		}
+	}
+
+	a.Close()
+	a.Close()
+	b.Close()
+	if got := s.Listeners(); got != 0 {
+		t.Errorf("Listeners() after close = %d, want 0", got)
+	}
+	if _, ok := <-a.Frames(); ok {
+		t.Error("closed listener still receiving frames")
+	}
+}
+
+func TestMOHStream_NothingToPlay(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := NewMOHStream([]string{filepath.Join(t.TempDir(), "missing.wav")}, true, logger)
+
+	l := s.Listen()
+	select {
+	case _, ok := <-l.Frames():
+		if ok {
+			t.Fatal("received a frame from an unplayable playlist")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream with nothing to play did not end")
+	}
+	if got := s.Listeners(); got != 0 {
+		t.Errorf("Listeners() after end = %d, want 0", got)
+	}
+	l.Close()
+}
@@ -12,6 +12,8 @@ import (
 	"net"
 	"os"
 	"time"
+
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
 )
 
 // WAV format codes for G.711 codecs.
@@ -247,6 +249,25 @@ type Player struct {
 	ssrc uint32
 	seq  uint16
 	ts   uint32
+
+	// tsStep is the RTP timestamp increment of one packet in the clock
+	// rate of the codec sent.
+	tsStep uint32
+
+	// outPT is the G.711 payload type to send, or -1 to send audio in the
+	// file's own encoding. Audio in the other G.711 law is converted.
+	outPT int
+
+	// codec, when set with SetCodec, is the non-G.711 codec audio is
+	// re-encoded in, through transcoders from the G.711 law of the
+	// audio keyed by its payload type. encBuf holds the encoded packet.
+	codec       LegCodec
+	transcoders map[int]*codecs.Transcoder
+	encBuf      []byte
+
+	// srtp encrypts packets for an endpoint that uses SRTP, or is nil.
+	srtp    *SRTPContext
+	srtpBuf []byte
 }
 
 // NewPlayer creates an audio player that sends RTP packets from the
@@ -259,6 +280,85 @@ func NewPlayer(conn *net.UDPConn, remote *net.UDPAddr, logger *slog.Logger) *Pla
 		ssrc:   rand.Uint32(),
 		seq:    uint16(rand.UintN(65536)),
 		ts:     rand.Uint32(),
+		tsStep: timestampIncrement,
+		outPT:  -1,
+	}
+}
+
+// SetPayloadType makes the player send audio as the given G.711 payload
+// type (PayloadPCMU or PayloadPCMA) regardless of the file's encoding, for
+// playback to an endpoint that negotiated the other law.
+func (p *Player) SetPayloadType(pt int) error {
+	if pt != PayloadPCMU && pt != PayloadPCMA {
+		return fmt.Errorf("unsupported payload type %d for playback", pt)
+	}
+	p.outPT = pt
+	return nil
+}
+
+// SetCodec makes the player send audio encoded with codec, for playback
+// to an endpoint that negotiated a codec other than G.711, such as G.722
+// or Opus. The G.711 audio played is transcoded through the codec
+// registry. A G.711 codec is sent as with SetPayloadType.
+func (p *Player) SetCodec(codec LegCodec) error {
+	if codec.Codec == nil {
+		return fmt.Errorf("unsupported codec %s for playback", codec)
+	}
+	if codec.isG711() {
+		return p.SetPayloadType(codec.Codec.PayloadType)
+	}
+	p.outPT = -1
+	p.codec = codec
+	p.transcoders = make(map[int]*codecs.Transcoder)
+	p.tsStep = uint32(samplesPerPacket * codec.Codec.ClockRate / 8000)
+	return nil
+}
+
+// encode re-encodes one packet of G.711 samples of payload type pt in the
+// codec set with SetCodec. It returns the packet with room for the RTP
+// header, which the caller fills in.
+func (p *Player) encode(samples []byte, pt int) ([]byte, error) {
+	t := p.transcoders[pt]
+	if t == nil {
+		from := codecs.LookupPayloadType(pt)
+		if from == nil {
+			return nil, fmt.Errorf("unsupported payload type %d for playback", pt)
+		}
+		var err error
+		if t, err = codecs.NewTranscoder(from, p.codec.Codec); err != nil {
+			return nil, err
+		}
+		p.transcoders[pt] = t
+	}
+
+	if cap(p.encBuf) < rtpHeaderSize {
+		p.encBuf = make([]byte, rtpHeaderSize, maxRTPPacket)
+	}
+	out, err := t.Transcode(p.encBuf[:rtpHeaderSize], samples)
+	if err != nil {
+		return nil, err
+	}
+	p.encBuf = out
+	return out, nil
+}
+
+// SetSRTP makes the player encrypt its packets with s, for playback to an
+// endpoint that uses SRTP. nil sends plain RTP.
+func (p *Player) SetSRTP(s *SRTPContext) {
+	p.srtp = s
+}
+
+// transcodeG711 converts G.711 samples in place from one law to the other.
+func transcodeG711(samples []byte, from, to int) {
+	switch {
+	case from == PayloadPCMU && to == PayloadPCMA:
+		for i, b := range samples {
+			samples[i] = linearToAlaw[uint16(ulawToLinear[b])]
+		}
+	case from == PayloadPCMA && to == PayloadPCMU:
+		for i, b := range samples {
+			samples[i] = linearToUlaw[uint16(alawToLinear[b])]
+		}
 	}
 }
 
@@ -323,6 +423,11 @@ func (p *Player) PlayData(ctx context.Context, r io.Reader, payloadType int, dat
 // streamAudio reads audio samples from r and sends them as RTP packets
 // with 20ms pacing. Each packet carries 160 bytes (160 samples at 8kHz).
 func (p *Player) streamAudio(ctx context.Context, r io.Reader, pt int, dataSize uint32) (*PlayResult, error) {
+	sendPT := pt
+	if p.outPT >= 0 {
+		sendPT = p.outPT
+	}
+
 	pkt := make([]byte, rtpHeaderSize+samplesPerPacket)
 	sent := 0
 	remaining := dataSize
@@ -362,11 +467,15 @@ func (p *Player) streamAudio(ctx context.Context, r io.Reader, pt int, dataSize
 			break
 		}
 
+		if sendPT != pt {
+			transcodeG711(pkt[rtpHeaderSize:rtpHeaderSize+n], pt, sendPT)
+		}
+
 		// If we read fewer than 160 bytes (end of file), pad with silence.
 		// G.711 u-law silence = 0xFF, G.711 a-law silence = 0xD5.
 		if n < samplesPerPacket {
 			silence := byte(0xFF) // u-law silence
-			if pt == PayloadPCMA {
+			if sendPT == PayloadPCMA {
 				silence = 0xD5 // a-law silence
 			}
 			for i := rtpHeaderSize + n; i < rtpHeaderSize+samplesPerPacket; i++ {
@@ -374,18 +483,23 @@ func (p *Player) streamAudio(ctx context.Context, r io.Reader, pt int, dataSize
 			}
 		}
 
+		out, outPT := pkt, sendPT
+		if p.codec.Codec != nil {
+			if out, err = p.encode(pkt[rtpHeaderSize:], sendPT); err != nil {
+				return nil, fmt.Errorf("encoding audio: %w", err)
+			}
+			outPT = p.codec.PayloadType
+		}
+
 		// Build RTP header.
-		buildRTPHeader(pkt[:rtpHeaderSize], pt, marker, p.seq, p.ts, p.ssrc)
+		buildRTPHeader(out[:rtpHeaderSize], outPT, marker, p.seq, p.ts, p.ssrc)
 		marker = false // Only first packet is marked.
 
-		// Send the packet.
-		if _, err := p.conn.WriteToUDP(pkt, p.remote); err != nil {
-			return nil, fmt.Errorf("sending rtp packet: %w", err)
+		if err := p.send(out); err != nil {
+			return nil, err
 		}
 
 		sent++
-		p.seq++
-		p.ts += timestampIncrement
 		remaining -= uint32(n)
 
 		// Pace packets at 20ms intervals. Use wall-clock timing to avoid
@@ -408,3 +522,73 @@ func (p *Player) streamAudio(ctx context.Context, r io.Reader, pt int, dataSize
 		Duration:    duration,
 	}, nil
 }
+
+// PlayStream sends the frames of a shared hold music stream as RTP until
+// ctx is cancelled, returning ctx's error, or the listener's frames stop,
+// returning ErrMOHStreamEnded if the stream ended. Frames are paced by
+// the stream; the player only renumbers them into its own RTP stream.
+// The listener is not closed.
+func (p *Player) PlayStream(ctx context.Context, l *MOHListener) (*PlayResult, error) {
+	pkt := make([]byte, rtpHeaderSize+samplesPerPacket)
+	sent := 0
+	start := time.Now()
+	result := func() *PlayResult {
+		return &PlayResult{PacketsSent: sent, Duration: time.Since(start)}
+	}
+
+	for {
+		var frame MOHFrame
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return result(), ctx.Err()
+		case frame, ok = <-l.Frames():
+		}
+		if !ok {
+			return result(), ErrMOHStreamEnded
+		}
+
+		sendPT := frame.PayloadType
+		if p.outPT >= 0 {
+			sendPT = p.outPT
+		}
+		copy(pkt[rtpHeaderSize:], frame.Samples)
+		if sendPT != frame.PayloadType {
+			transcodeG711(pkt[rtpHeaderSize:], frame.PayloadType, sendPT)
+		}
+		out := pkt
+		if p.codec.Codec != nil {
+			var err error
+			if out, err = p.encode(pkt[rtpHeaderSize:], sendPT); err != nil {
+				return result(), fmt.Errorf("encoding hold music: %w", err)
+			}
+			sendPT = p.codec.PayloadType
+		}
+		buildRTPHeader(out[:rtpHeaderSize], sendPT, sent == 0, p.seq, p.ts, p.ssrc)
+
+		if err := p.send(out); err != nil {
+			return result(), err
+		}
+		sent++
+	}
+}
+
+// send transmits one RTP packet, encrypted if the endpoint uses SRTP, and
+// advances the sequence number and timestamp.
+func (p *Player) send(pkt []byte) error {
+	out := pkt
+	if p.srtp != nil {
+		var err error
+		out, err = p.srtp.Protect(p.srtpBuf[:0], pkt)
+		if err != nil {
+			return fmt.Errorf("protecting rtp packet: %w", err)
+		}
+		p.srtpBuf = out
+	}
+	if _, err := p.conn.WriteToUDP(out, p.remote); err != nil {
+		return fmt.Errorf("sending rtp packet: %w", err)
+	}
+	p.seq++
+	p.ts += p.tsStep
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
 )
 
 // createTestWAV creates a minimal G.711 WAV file with the specified format
@@ -370,6 +372,111 @@ func TestPlayer_PlayData(t *testing.T) {
 	}
 }
 
+func TestPlayer_SetCodec(t *testing.T) {
+	// G.711 audio played to a G.722 endpoint is re-encoded as G.722.
+	data := bytes.Repeat([]byte{0xFF}, 2*samplesPerPacket)
+
+	listenAddr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	listener, err := net.ListenUDP("udp", listenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	remoteAddr := listener.LocalAddr().(*net.UDPAddr)
+
+	sendAddr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	sender, err := net.ListenUDP("udp", sendAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	player := NewPlayer(sender, remoteAddr, logger)
+	if err := player.SetCodec(LegCodec{PayloadType: PayloadPCMU}); err == nil {
+		t.Error("expected error for an unknown codec")
+	}
+	if err := player.SetCodec(LegCodec{PayloadType: PayloadG722, Codec: codecs.G722}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := player.PlayData(context.Background(), bytes.NewReader(data), PayloadPCMU, uint32(len(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	var ts [2]uint32
+	buf := make([]byte, 1500)
+	for i := range ts {
+		listener.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := listener.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pt := int(buf[1] & 0x7F); pt != PayloadG722 {
+			t.Errorf("payload type = %d, want %d", pt, PayloadG722)
+		}
+		// 20ms of G.722 is 320 samples at 16 kHz, four bits each.
+		if n != rtpHeaderSize+160 {
+			t.Errorf("packet size = %d, want %d", n, rtpHeaderSize+160)
+		}
+		ts[i] = binary.BigEndian.Uint32(buf[4:8])
+	}
+	if step := ts[1] - ts[0]; step != 160 {
+		t.Errorf("timestamp step = %d, want 160 (G.722 clock rate 8000)", step)
+	}
+}
+
+func TestPlayer_SetPayloadType(t *testing.T) {
+	// u-law silence must arrive as a-law silence.
+	data := bytes.Repeat([]byte{0xFF}, 100)
+
+	listenAddr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	listener, err := net.ListenUDP("udp", listenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	remoteAddr := listener.LocalAddr().(*net.UDPAddr)
+
+	sendAddr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	sender, err := net.ListenUDP("udp", sendAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	player := NewPlayer(sender, remoteAddr, logger)
+	if err := player.SetPayloadType(PayloadPCMA); err != nil {
+		t.Fatal(err)
+	}
+	if err := player.SetPayloadType(PayloadOpus); err == nil {
+		t.Error("expected error for non-G.711 payload type")
+	}
+
+	if _, err := player.PlayData(context.Background(), bytes.NewReader(data), PayloadPCMU, uint32(len(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1500)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != rtpHeaderSize+samplesPerPacket {
+		t.Fatalf("packet size = %d, want %d", n, rtpHeaderSize+samplesPerPacket)
+	}
+	if pt := int(buf[1] & 0x7F); pt != PayloadPCMA {
+		t.Errorf("payload type = %d, want %d", pt, PayloadPCMA)
+	}
+	for i, b := range buf[rtpHeaderSize:n] {
+		if v := alawToLinear[b]; v < -8 || v > 8 {
+			t.Fatalf("sample %d = %#x (linear %d), want a-law silence", i, b, v)
+		}
+	}
+}
+
 // buildTestWAVData creates minimal WAV file data in memory for testing.
 func buildTestWAVData(format uint16, sampleRate uint32, channels uint16, bitsPerSample uint16, numSamples int) []byte {
 	data := make([]byte, numSamples)
@@ -18,17 +18,24 @@ const (
 	// recorderFlushSize is the number of decoded samples to buffer before
 	// flushing to disk. 8000 samples = 1 second at 8kHz.
 	recorderFlushSize = 8000
+
+	// recorderSampleRate is the sample rate recordings are stored at.
+	recorderSampleRate = 8000
 )
 
-// rtpPacket is a copy of an RTP packet queued for recording.
+// rtpPacket is a copy of an RTP packet queued for recording. Audio in
+// codecs other than G.711 is queued already decoded, in samples.
 type rtpPacket struct {
 	payload     []byte
 	payloadType int
+	samples     []int16
 }
 
 // Recorder captures an RTP stream to a WAV file. It runs a dedicated
 // goroutine that reads packets from a buffered channel, decodes G.711
 // audio to linear PCM, then re-encodes to G.711 u-law for WAV storage.
+// Audio in other codecs, such as G.722 or Opus, is decoded by the relay
+// and fed as linear PCM with FeedLinear.
 //
 // Usage:
 //
@@ -112,6 +119,24 @@ func (r *Recorder) Feed(payload []byte, payloadType int) {
 	}
 }
 
+// FeedLinear queues 8 kHz linear audio for recording, for codecs other
+// than G.711 which the relay decodes itself. Like Feed, it copies the
+// samples and drops them if the write goroutine is behind.
+func (r *Recorder) FeedLinear(samples []int16) {
+	if len(samples) == 0 {
+		return
+	}
+
+	buf := make([]int16, len(samples))
+	copy(buf, samples)
+
+	select {
+	case r.packets <- rtpPacket{samples: buf}:
+	default:
+		// Channel full — drop packet rather than blocking the relay.
+	}
+}
+
 // Stop finalizes the recording: drains remaining packets, rewrites the WAV
 // header with the actual data size, and closes the file. Returns the file
 // path and duration in seconds. Must be called exactly once.
@@ -179,6 +204,10 @@ func (r *Recorder) writeLoop() {
 	}
 
 	for pkt := range r.packets {
+		for _, s := range pkt.samples {
+			writeBuf = append(writeBuf, linearToUlaw[uint16(s)])
+		}
+
 		// Decode each G.711 byte to PCM, then re-encode to u-law.
 		// If the source is already PCMU, this is a passthrough (decode+encode = identity).
 		// If the source is PCMA, this transcodes a-law → PCM → u-law.
@@ -2,7 +2,9 @@ package media
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"os"
 	"sync"
@@ -73,8 +75,9 @@ type Relay struct {
 	session *Session
 	logger  *slog.Logger
 
-	// allowedPT is the set of payload types to relay.
-	allowedPT map[int]struct{}
+	// allowedPT is the set of payload types to relay. The set is
+	// replaced, never modified, when a leg's codec changes.
+	allowedPT atomic.Pointer[map[int]struct{}]
 
 	// callerRemote is the learned remote RTP address for the caller leg.
 	// Initialized from SDP and updated on first packet (symmetric RTP).
@@ -83,13 +86,63 @@ type Relay struct {
 	// Initialized from SDP and updated on first packet (symmetric RTP).
 	calleeRemote *atomicAddr
 
+	// callerRelearn and calleeRelearn are set when a leg's remote endpoint
+	// is replaced (e.g. after a call transfer) so the forwarding goroutine
+	// learns the new endpoint's address from its first packet.
+	callerRelearn atomic.Bool
+	calleeRelearn atomic.Bool
+
+	// callerRetired and calleeRetired hold the address of a replaced
+	// endpoint. Packets it still sends are dropped so they are neither
+	// relayed nor mistaken for the new endpoint.
+	callerRetired atomic.Pointer[net.UDPAddr]
+	calleeRetired atomic.Pointer[net.UDPAddr]
+
+	// callerHeld and calleeHeld are set while a leg is on hold. Media
+	// towards a held leg is not relayed so hold music can be played to it
+	// on the leg's socket instead.
+	callerHeld atomic.Bool
+	calleeHeld atomic.Bool
+
 	// recorder captures both directions of RTP audio to a WAV file.
 	// Set via SetRecorder before Start, or nil to disable recording.
 	recorder *Recorder
 
+	// callerCodec and calleeCodec are the codecs each leg negotiated,
+	// set via SetCodecs and changed via SetLegCodec. toCallee and
+	// toCaller convert media between them; both are nil when the codecs
+	// are not known, in which case packets are forwarded untouched.
+	// codecMu serialises changes.
+	callerCodec, calleeCodec LegCodec
+	toCallee, toCaller       atomic.Pointer[streamConverter]
+	codecMu                  sync.Mutex
+
+	// SRTP contexts of legs that use SRTP, set via SetLegSRTP: the *In
+	// contexts decrypt media from a leg and the *Out contexts encrypt
+	// media sent to it. nil for plain RTP legs. srtpMu serialises
+	// changes and guards the keys they were derived from.
+	callerIn, callerOut atomic.Pointer[SRTPContext]
+	calleeIn, calleeOut atomic.Pointer[SRTPContext]
+	srtpMu              sync.Mutex
+	srtpKeys            [2]*LegSRTP
+
+	// mix, while set, is the mixer the legs' audio is handed to instead
+	// of being relayed between them. See AttachMixer.
+	mix atomic.Pointer[relayMix]
+
 	wg sync.WaitGroup
 }
 
+// relayMix is a mixer a relay's legs are attached to.
+type relayMix struct {
+	mixer *Mixer
+
+	// ids are the mixer participant IDs of the caller and callee legs,
+	// and pts the audio payload types their devices send.
+	ids [2]string
+	pts [2]int
+}
+
 // NewRelay creates a relay for the given session with the specified allowed
 // payload types. callerRemote and calleeRemote are the far-end RTP addresses
 // learned from SDP negotiation. These addresses serve as initial targets and
@@ -99,13 +152,14 @@ func NewRelay(session *Session, callerRemote, calleeRemote *net.UDPAddr, allowed
 	for _, p := range allowedPayloadTypes {
 		pt[p] = struct{}{}
 	}
-	return &Relay{
+	r := &Relay{
 		session:      session,
 		logger:       logger.With("subsystem", "rtp-relay", "session_id", session.ID),
-		allowedPT:    pt,
 		callerRemote: newAtomicAddr(callerRemote),
 		calleeRemote: newAtomicAddr(calleeRemote),
 	}
+	r.allowedPT.Store(&pt)
+	return r
 }
 
 // SetRecorder attaches a call recorder to this relay. Both directions of
@@ -114,6 +168,202 @@ func (r *Relay) SetRecorder(rec *Recorder) {
 	r.recorder = rec
 }
 
+// SetCodecs tells the relay which audio codec each leg negotiated. When the
+// codecs differ, audio is transcoded between them; when only the payload
+// type numbers differ, packets are renumbered. Both payload types are
+// added to the allowed set. Must be called before Start.
+func (r *Relay) SetCodecs(caller, callee LegCodec) error {
+	r.codecMu.Lock()
+	defer r.codecMu.Unlock()
+	return r.setCodecsLocked(caller, callee)
+}
+
+// SetLegCodec changes the codec one leg negotiated while the relay runs,
+// e.g. when a re-INVITE moves the leg to a codec the other leg does not
+// have. Audio is transcoded between the legs' codecs from the next packet
+// on. The relay must have been given codecs with SetCodecs, and the legs
+// must not be in a mixer, which has the old codec.
+func (r *Relay) SetLegCodec(callerSide bool, codec LegCodec) error {
+	r.codecMu.Lock()
+	defer r.codecMu.Unlock()
+
+	if r.toCallee.Load() == nil {
+		return fmt.Errorf("relay has no codecs to transcode between")
+	}
+	if r.mix.Load() != nil {
+		return fmt.Errorf("relay legs are attached to a mixer")
+	}
+	caller, callee := r.callerCodec, codec
+	if callerSide {
+		caller, callee = codec, r.calleeCodec
+	}
+	return r.setCodecsLocked(caller, callee)
+}
+
+// setCodecsLocked sets the legs' codecs and the converters between them.
+// Must be called with r.codecMu held.
+func (r *Relay) setCodecsLocked(caller, callee LegCodec) error {
+	toCallee, err := newStreamConverter(caller, callee)
+	if err != nil {
+		return err
+	}
+	toCaller, err := newStreamConverter(callee, caller)
+	if err != nil {
+		return err
+	}
+
+	pts := maps.Clone(*r.allowedPT.Load())
+	pts[caller.PayloadType] = struct{}{}
+	pts[callee.PayloadType] = struct{}{}
+	r.allowedPT.Store(&pts)
+
+	r.callerCodec, r.calleeCodec = caller, callee
+	r.toCallee.Store(toCallee)
+	r.toCaller.Store(toCaller)
+	return nil
+}
+
+// Codecs returns the codecs set with SetCodecs, and false if none were.
+func (r *Relay) Codecs() (caller, callee LegCodec, ok bool) {
+	r.codecMu.Lock()
+	defer r.codecMu.Unlock()
+	return r.callerCodec, r.calleeCodec, r.toCallee.Load() != nil
+}
+
+// Transcoding reports whether the relay converts audio between codecs.
+func (r *Relay) Transcoding() bool {
+	conv := r.toCallee.Load()
+	return conv != nil && conv.transcoder != nil
+}
+
+// SetSRTP makes the relay terminate SRTP on the legs given keys: media
+// from such a leg is authenticated and decrypted, and media sent to it is
+// encrypted, so an SRTP leg can be bridged to a plain RTP one. nil leaves
+// a leg as plain RTP.
+func (r *Relay) SetSRTP(caller, callee *LegSRTP) error {
+	if err := r.SetLegSRTP(true, caller); err != nil {
+		return fmt.Errorf("caller leg: %w", err)
+	}
+	if err := r.SetLegSRTP(false, callee); err != nil {
+		return fmt.Errorf("callee leg: %w", err)
+	}
+	return nil
+}
+
+// SetLegSRTP changes the SRTP keys of one leg, or with nil switches it to
+// plain RTP. It may be called while the relay runs, e.g. when a re-INVITE
+// rekeys the leg. A key that is unchanged keeps its context, so the
+// rollover counter and replay state carry over.
+func (r *Relay) SetLegSRTP(callerSide bool, leg *LegSRTP) error {
+	r.srtpMu.Lock()
+	defer r.srtpMu.Unlock()
+
+	i, in, out := 0, &r.callerIn, &r.callerOut
+	if !callerSide {
+		i, in, out = 1, &r.calleeIn, &r.calleeOut
+	}
+	if leg == nil {
+		in.Store(nil)
+		out.Store(nil)
+		r.srtpKeys[i] = nil
+		return nil
+	}
+
+	cur := r.srtpKeys[i]
+	inCtx, outCtx := in.Load(), out.Load()
+	var err error
+	if cur == nil || !cur.Remote.sameKey(leg.Remote) {
+		if inCtx, err = NewSRTPContext(leg.Remote); err != nil {
+			return err
+		}
+	}
+	if cur == nil || !cur.Local.sameKey(leg.Local) {
+		if outCtx, err = NewSRTPContext(leg.Local); err != nil {
+			return err
+		}
+	}
+	in.Store(inCtx)
+	out.Store(outCtx)
+	r.srtpKeys[i] = leg
+	return nil
+}
+
+// OutboundSRTP returns the context that encrypts media sent to one leg,
+// or nil if the leg uses plain RTP. Other senders on the leg's socket,
+// such as hold music, must protect their packets with it.
+func (r *Relay) OutboundSRTP(callerSide bool) *SRTPContext {
+	if callerSide {
+		return r.callerOut.Load()
+	}
+	return r.calleeOut.Load()
+}
+
+// AttachMixer moves both legs into m as the participants callerID and
+// calleeID, so further parties can be mixed into the call. The relay keeps
+// the legs' sockets: audio read from each leg is fed to the mixer and the
+// leg's mix is sent back to it, with SRTP and hold applied as usual. Other
+// payloads, such as DTMF, are still relayed between the legs. caller and
+// callee are the codecs the legs' devices send.
+func (r *Relay) AttachMixer(m *Mixer, callerID, calleeID string, caller, callee LegCodec) error {
+	if r.mix.Load() != nil {
+		return fmt.Errorf("relay already attached to a mixer")
+	}
+	if err := m.AttachParticipant(callerID, caller, r.mixerSender(true)); err != nil {
+		return fmt.Errorf("caller leg: %w", err)
+	}
+	if err := m.AttachParticipant(calleeID, callee, r.mixerSender(false)); err != nil {
+		m.RemoveParticipant(callerID)
+		return fmt.Errorf("callee leg: %w", err)
+	}
+	r.mix.Store(&relayMix{
+		mixer: m,
+		ids:   [2]string{callerID, calleeID},
+		pts:   [2]int{caller.PayloadType, callee.PayloadType},
+	})
+	return nil
+}
+
+// DetachMixer takes the legs out of the mixer they were attached to with
+// AttachMixer and resumes relaying audio between them.
+func (r *Relay) DetachMixer() {
+	mix := r.mix.Swap(nil)
+	if mix == nil {
+		return
+	}
+	mix.mixer.RemoveParticipant(mix.ids[0])
+	mix.mixer.RemoveParticipant(mix.ids[1])
+}
+
+// mixerSender returns the function a mixer sends one leg's mix with.
+func (r *Relay) mixerSender(callerSide bool) func(pkt []byte) {
+	direction, conn, remote, held, protect := "caller→callee", r.session.CalleeLeg.RTPConn, r.calleeRemote, &r.calleeHeld, &r.calleeOut
+	if callerSide {
+		direction, conn, remote, held, protect = "callee→caller", r.session.CallerLeg.RTPConn, r.callerRemote, &r.callerHeld, &r.callerOut
+	}
+	srtpBuf := make([]byte, 0, maxRTPPacket)
+	return func(pkt []byte) {
+		if held.Load() {
+			return
+		}
+		if s := protect.Load(); s != nil {
+			var err error
+			if pkt, err = s.Protect(srtpBuf[:0], pkt); err != nil {
+				r.session.RecordDrop()
+				return
+			}
+		}
+		if _, err := conn.WriteToUDP(pkt, remote.load()); err != nil {
+			r.logger.Debug("rtp write error",
+				"direction", direction,
+				"error", err,
+			)
+			return
+		}
+		r.session.TouchActivity()
+		r.session.RecordPacket(direction, len(pkt))
+	}
+}
+
 // Start begins bidirectional RTP relay between the two legs.
 // Caller→Callee: reads from CallerLeg.RTPConn, writes to CalleeLeg.RTPConn → calleeRemote.
 // Callee→Caller: reads from CalleeLeg.RTPConn, writes to CallerLeg.RTPConn → callerRemote.
@@ -124,14 +374,17 @@ func (r *Relay) Start() {
 	r.session.SetState(SessionStateActive)
 
 	r.wg.Add(2)
-	go r.forward("caller→callee", r.session.CallerLeg.RTPConn, r.session.CalleeLeg.RTPConn, r.calleeRemote, r.callerRemote)
-	go r.forward("callee→caller", r.session.CalleeLeg.RTPConn, r.session.CallerLeg.RTPConn, r.callerRemote, r.calleeRemote)
+	go r.forward("caller→callee", r.session.CallerLeg.RTPConn, r.session.CalleeLeg.RTPConn, r.calleeRemote, r.callerRemote, &r.callerRelearn, &r.callerRetired, &r.calleeHeld, &r.toCallee, &r.callerIn, &r.calleeOut)
+	go r.forward("callee→caller", r.session.CalleeLeg.RTPConn, r.session.CallerLeg.RTPConn, r.callerRemote, r.calleeRemote, &r.calleeRelearn, &r.calleeRetired, &r.callerHeld, &r.toCaller, &r.calleeIn, &r.callerOut)
 
 	r.logger.Info("rtp relay started",
 		"caller_local_port", r.session.CallerLeg.Ports.RTP,
 		"callee_local_port", r.session.CalleeLeg.Ports.RTP,
 		"caller_remote", r.callerRemote.load().String(),
 		"callee_remote", r.calleeRemote.load().String(),
+		"transcoding", r.Transcoding(),
+		"caller_srtp", r.callerIn.Load() != nil,
+		"callee_srtp", r.calleeIn.Load() != nil,
 	)
 }
 
@@ -162,6 +415,46 @@ func (r *Relay) CalleeAddr() *net.UDPAddr {
 	return r.calleeRemote.load()
 }
 
+// SetCallerRemote points the caller leg at a new remote endpoint, e.g. when
+// the caller side of the call has been transferred to another device. The
+// address is re-learned from the new endpoint's first RTP packet.
+func (r *Relay) SetCallerRemote(addr *net.UDPAddr) {
+	r.callerRetired.Store(r.callerRemote.load())
+	r.callerRemote.v.Store(addr)
+	r.callerRelearn.Store(true)
+}
+
+// SetCalleeRemote points the callee leg at a new remote endpoint, e.g. when
+// the callee side of the call has been transferred to another device. The
+// address is re-learned from the new endpoint's first RTP packet.
+func (r *Relay) SetCalleeRemote(addr *net.UDPAddr) {
+	r.calleeRetired.Store(r.calleeRemote.load())
+	r.calleeRemote.v.Store(addr)
+	r.calleeRelearn.Store(true)
+}
+
+// SetCallerHeld starts or stops suppressing media towards the caller leg,
+// e.g. while the callee has put the call on hold.
+func (r *Relay) SetCallerHeld(held bool) {
+	r.callerHeld.Store(held)
+}
+
+// SetCalleeHeld starts or stops suppressing media towards the callee leg,
+// e.g. while the caller has put the call on hold.
+func (r *Relay) SetCalleeHeld(held bool) {
+	r.calleeHeld.Store(held)
+}
+
+// PayloadTypes returns the RTP payload types the relay forwards.
+func (r *Relay) PayloadTypes() []int {
+	allowed := *r.allowedPT.Load()
+	pts := make([]int, 0, len(allowed))
+	for pt := range allowed {
+		pts = append(pts, pt)
+	}
+	return pts
+}
+
 // readTimeout is the read deadline for UDP sockets in the relay loop.
 // This allows goroutines to periodically check the stopped flag.
 const readTimeout = 100 * time.Millisecond
@@ -173,11 +466,22 @@ const readTimeout = 100 * time.Millisecond
 // of the opposite leg). learnRemote is updated with the actual source address of
 // the first valid RTP packet received on this leg. This allows the opposite
 // direction's forward goroutine to send replies back to the real (post-NAT) address.
-func (r *Relay) forward(direction string, src, dst *net.UDPConn, writeRemote, learnRemote *atomicAddr) {
+// When relearn is set, the next valid packet's source is learned again, and
+// packets from the retired address are dropped. While held is set, packets
+// are read (and recorded) but not written to the destination leg. While conv
+// holds a converter, packets are converted to the destination leg's codec.
+// While unprotect or protect holds a context, SRTP is removed from packets
+// read or applied to packets written.
+func (r *Relay) forward(direction string, src, dst *net.UDPConn, writeRemote, learnRemote *atomicAddr, relearn *atomic.Bool, retired *atomic.Pointer[net.UDPAddr], held *atomic.Bool, conv *atomic.Pointer[streamConverter], unprotect, protect *atomic.Pointer[SRTPContext]) {
 	defer r.wg.Done()
 
 	buf := make([]byte, maxRTPPacket)
+	srtpBuf := make([]byte, 0, maxRTPPacket)
 	learned := false
+	leg := 1
+	if src == r.session.CallerLeg.RTPConn {
+		leg = 0
+	}
 	for {
 		if r.session.IsStopped() {
 			return
@@ -209,16 +513,37 @@ func (r *Relay) forward(direction string, src, dst *net.UDPConn, writeRemote, le
 			continue
 		}
 
-		if _, ok := r.allowedPT[pt]; !ok {
+		if _, ok := (*r.allowedPT.Load())[pt]; !ok {
 			// Payload type not in allowed set; drop.
 			r.session.RecordDrop()
 			continue
 		}
 
+		if old := retired.Load(); old != nil && old.IP.Equal(srcAddr.IP) && old.Port == srcAddr.Port {
+			// Media from an endpoint that has been transferred away.
+			r.session.RecordDrop()
+			continue
+		}
+
+		// Authenticate SRTP before learning addresses from it, so forged
+		// packets cannot redirect the stream.
+		if s := unprotect.Load(); s != nil {
+			pkt, err = s.Unprotect(pkt[:0], pkt)
+			if err != nil {
+				r.session.RecordDrop()
+				r.logger.Debug("srtp unprotect failed",
+					"direction", direction,
+					"error", err,
+				)
+				continue
+			}
+			n = len(pkt)
+		}
+
 		// Symmetric RTP: learn the actual remote address from the first
 		// valid RTP packet. This handles NAT where the real source differs
 		// from the SDP-signaled address.
-		if !learned {
+		if !learned || relearn.CompareAndSwap(true, false) {
 			if learnRemote.update(srcAddr) {
 				r.logger.Info("symmetric rtp: learned remote address",
 					"direction", direction,
@@ -231,8 +556,58 @@ func (r *Relay) forward(direction string, src, dst *net.UDPConn, writeRemote, le
 		// Feed RTP payload to recorder if active. The RTP payload starts
 		// after the fixed 12-byte header (plus CSRC and extension if present,
 		// but G.711 typically has none). We use the simple 12-byte offset.
+		// With known codecs, the converter finds the payload and decodes
+		// codecs the recorder cannot store directly.
+		sc := conv.Load()
 		if r.recorder != nil && n > minRTPHeader {
-			r.recorder.Feed(pkt[minRTPHeader:n], pt)
+			if sc != nil {
+				if err := sc.record(r.recorder, pkt, pt); err != nil {
+					r.logger.Debug("rtp recording decode error",
+						"direction", direction,
+						"error", err,
+					)
+				}
+			} else {
+				r.recorder.Feed(pkt[minRTPHeader:n], pt)
+			}
+		}
+
+		// While the legs are in a mixer, their audio goes to it instead.
+		if mix := r.mix.Load(); mix != nil && pt == mix.pts[leg] {
+			mix.mixer.Feed(mix.ids[leg], pkt)
+			r.session.TouchActivity()
+			continue
+		}
+
+		// Convert even while held so codec state follows the stream.
+		if sc != nil {
+			pkt, err = sc.convert(pkt, pt)
+			if err != nil {
+				r.session.RecordDrop()
+				r.logger.Debug("rtp transcode error",
+					"direction", direction,
+					"error", err,
+				)
+				continue
+			}
+		}
+
+		if held.Load() {
+			// The destination leg is on hold and hearing hold music.
+			r.session.TouchActivity()
+			continue
+		}
+
+		if s := protect.Load(); s != nil {
+			pkt, err = s.Protect(srtpBuf[:0], pkt)
+			if err != nil {
+				r.session.RecordDrop()
+				r.logger.Debug("srtp protect failed",
+					"direction", direction,
+					"error", err,
+				)
+				continue
+			}
 		}
 
 		_, err = dst.WriteToUDP(pkt, writeRemote.load())
@@ -248,7 +623,7 @@ func (r *Relay) forward(direction string, src, dst *net.UDPConn, writeRemote, le
 		}
 
 		r.session.TouchActivity()
-		r.session.RecordPacket(direction, n)
+		r.session.RecordPacket(direction, len(pkt))
 	}
 }
 
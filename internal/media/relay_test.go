@@ -6,6 +6,8 @@ import (
 	"net"
 	"testing"
 	"time"
+
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
 )
 
 // makeTestRTPPacket creates a minimal RTP packet with the given payload type and payload.
@@ -717,3 +719,224 @@ func TestSymmetricRTP_SameAddr(t *testing.T) {
 		t.Errorf("callee addr port = %d, want %d (should be unchanged)", got.Port, calleePhoneAddr.Port)
 	}
 }
+
+func TestRelaySetCalleeRemote(t *testing.T) {
+	// After a transfer the callee leg is pointed at a new device. Caller
+	// media must reach the new device and its address must be re-learned.
+	logger := slog.Default()
+
+	callerPair, callerLocalAddr := allocateTestPair(t)
+	defer callerPair.Close()
+	calleePair, calleeLocalAddr := allocateTestPair(t)
+	defer calleePair.Close()
+
+	listen := func() *net.UDPConn {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		return conn
+	}
+	callerPhone := listen()
+	defer callerPhone.Close()
+	oldCallee := listen()
+	defer oldCallee.Close()
+	newCallee := listen()
+	defer newCallee.Close()
+
+	session := &Session{
+		ID:        "test-session-retarget",
+		CallID:    "test-call-retarget",
+		CallerLeg: callerPair,
+		CalleeLeg: calleePair,
+		CreatedAt: time.Now(),
+		state:     SessionStateNew,
+	}
+
+	relay := StartPCMURelay(session, callerPhone.LocalAddr().(*net.UDPAddr), oldCallee.LocalAddr().(*net.UDPAddr), logger)
+	defer relay.Stop()
+
+	// The original callee sends first so its address is learned.
+	buf := make([]byte, maxRTPPacket)
+	if _, err := oldCallee.WriteToUDP(makeTestRTPPacket(PayloadPCMU, []byte{0x01}), calleeLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	callerPhone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := callerPhone.ReadFromUDP(buf); err != nil {
+		t.Fatalf("caller phone read: %v", err)
+	}
+
+	// Bogus port to verify re-learning picks up the real source.
+	newAddr := newCallee.LocalAddr().(*net.UDPAddr)
+	relay.SetCalleeRemote(&net.UDPAddr{IP: newAddr.IP, Port: 59997})
+	if _, err := newCallee.WriteToUDP(makeTestRTPPacket(PayloadPCMU, []byte{0x02}), calleeLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if got := relay.CalleeAddr(); got.Port != newAddr.Port {
+		t.Fatalf("callee addr = %s, want %s", got, newAddr)
+	}
+
+	callerPhone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := callerPhone.ReadFromUDP(buf); err != nil {
+		t.Fatalf("caller phone read: %v", err)
+	}
+
+	// Late media from the replaced callee is dropped.
+	if _, err := oldCallee.WriteToUDP(makeTestRTPPacket(PayloadPCMU, []byte{0x03}), calleeLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	callerPhone.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	if _, _, err := callerPhone.ReadFromUDP(buf); err == nil {
+		t.Error("expected media from the replaced callee to be dropped")
+	}
+
+	pkt := makeTestRTPPacket(PayloadPCMU, []byte{0xCA, 0xFE})
+	if _, err := callerPhone.WriteToUDP(pkt, callerLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	newCallee.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := newCallee.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("new callee read: %v", err)
+	}
+	if !bytes.Equal(buf[:n], pkt) {
+		t.Errorf("received packet differs from sent packet")
+	}
+}
+
+func TestRelaySetCalleeHeld(t *testing.T) {
+	// While the callee is held, caller media is not relayed to it; callee
+	// media still reaches the caller. Unholding resumes forwarding.
+	logger := slog.Default()
+
+	callerPair, callerLocalAddr := allocateTestPair(t)
+	defer callerPair.Close()
+	calleePair, calleeLocalAddr := allocateTestPair(t)
+	defer calleePair.Close()
+
+	callerPhone, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer callerPhone.Close()
+	calleePhone, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer calleePhone.Close()
+
+	session := &Session{
+		ID:        "test-session-hold",
+		CallID:    "test-call-hold",
+		CallerLeg: callerPair,
+		CalleeLeg: calleePair,
+		CreatedAt: time.Now(),
+		state:     SessionStateNew,
+	}
+
+	relay := StartPCMURelay(session, callerPhone.LocalAddr().(*net.UDPAddr), calleePhone.LocalAddr().(*net.UDPAddr), logger)
+	defer relay.Stop()
+
+	buf := make([]byte, maxRTPPacket)
+	relay.SetCalleeHeld(true)
+
+	if _, err := callerPhone.WriteToUDP(makeTestRTPPacket(PayloadPCMU, []byte{0x01}), callerLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	calleePhone.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	if _, _, err := calleePhone.ReadFromUDP(buf); err == nil {
+		t.Error("expected caller media to be suppressed while callee is held")
+	}
+
+	if _, err := calleePhone.WriteToUDP(makeTestRTPPacket(PayloadPCMU, []byte{0x02}), calleeLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	callerPhone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := callerPhone.ReadFromUDP(buf); err != nil {
+		t.Fatalf("caller phone read: %v", err)
+	}
+
+	relay.SetCalleeHeld(false)
+	if _, err := callerPhone.WriteToUDP(makeTestRTPPacket(PayloadPCMU, []byte{0x03}), callerLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	calleePhone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := calleePhone.ReadFromUDP(buf); err != nil {
+		t.Fatalf("expected caller media after unhold: %v", err)
+	}
+
+	if stats := session.Stats(); stats.PacketsDropped != 0 {
+		t.Errorf("held media counted as dropped: %d", stats.PacketsDropped)
+	}
+}
+
+func TestRelaySetLegCodec(t *testing.T) {
+	// A leg that moves to another codec mid-call is transcoded to the
+	// codec the other leg still uses.
+	logger := slog.Default()
+
+	callerPair, callerLocalAddr := allocateTestPair(t)
+	defer callerPair.Close()
+	calleePair, _ := allocateTestPair(t)
+	defer calleePair.Close()
+
+	callerPhone, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer callerPhone.Close()
+	calleePhone, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer calleePhone.Close()
+
+	session := &Session{
+		ID:        "test-session-codec",
+		CallID:    "test-call-codec",
+		CallerLeg: callerPair,
+		CalleeLeg: calleePair,
+		CreatedAt: time.Now(),
+		state:     SessionStateNew,
+	}
+
+	relay := NewRelay(session, callerPhone.LocalAddr().(*net.UDPAddr), calleePhone.LocalAddr().(*net.UDPAddr), []int{PayloadPCMU}, logger)
+	if err := relay.SetLegCodec(true, LegCodec{PayloadType: PayloadPCMA, Codec: codecs.PCMA}); err == nil {
+		t.Error("expected a relay without codecs to refuse a codec change")
+	}
+	pcmu := LegCodec{PayloadType: PayloadPCMU, Codec: codecs.PCMU}
+	if err := relay.SetCodecs(pcmu, pcmu); err != nil {
+		t.Fatalf("set codecs: %v", err)
+	}
+	relay.Start()
+	defer relay.Stop()
+
+	if err := relay.SetLegCodec(true, LegCodec{PayloadType: PayloadPCMA, Codec: codecs.PCMA}); err != nil {
+		t.Fatalf("set leg codec: %v", err)
+	}
+	if caller, callee, _ := relay.Codecs(); caller.Codec != codecs.PCMA || callee.Codec != codecs.PCMU {
+		t.Errorf("codecs = %s, %s, want PCMA/8, PCMU/0", caller, callee)
+	}
+
+	payload := bytes.Repeat([]byte{codecs.EncodeAlaw(1000)}, samplesPerPacket)
+	if _, err := callerPhone.WriteToUDP(makeTestRTPPacket(PayloadPCMA, payload), callerLocalAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	buf := make([]byte, maxRTPPacket)
+	calleePhone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := calleePhone.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("callee phone read: %v", err)
+	}
+	if pt := rtpPayloadType(buf[:n]); pt != PayloadPCMU {
+		t.Fatalf("payload type = %d, want %d", pt, PayloadPCMU)
+	}
+	want := codecs.EncodeUlaw(codecs.DecodeAlaw(payload[0]))
+	if got := buf[minRTPHeader]; got != want {
+		t.Errorf("first sample = %#x, want %#x", got, want)
+	}
+}
@@ -68,15 +68,37 @@ func (c Codec) String() string {
 
 // MediaDescription holds a parsed SDP m= section with its attributes.
 type MediaDescription struct {
-	Type       string      // "audio", "video", etc.
-	Port       int         // transport port
-	NumPorts   int         // number of ports (0 means 1)
-	Proto      string      // e.g. "RTP/AVP", "RTP/SAVP"
-	Formats    []int       // payload type numbers
-	Connection *Connection // media-level c= line (overrides session-level)
-	Codecs     []Codec     // parsed from a=rtpmap lines
-	Attributes []string    // raw a= lines for this media section
-	Direction  string      // "sendrecv", "sendonly", "recvonly", "inactive"
+	Type       string            // "audio", "video", etc.
+	Port       int               // transport port
+	NumPorts   int               // number of ports (0 means 1)
+	Proto      string            // e.g. "RTP/AVP", "RTP/SAVP"
+	Formats    []int             // payload type numbers
+	Connection *Connection       // media-level c= line (overrides session-level)
+	Codecs     []Codec           // parsed from a=rtpmap lines
+	Attributes []string          // raw a= lines for this media section
+	Direction  string            // "sendrecv", "sendonly", "recvonly", "inactive"
+	Crypto     []CryptoAttribute // usable SDES keys from a=crypto lines
+}
+
+// IsSecure reports whether the media uses a secure RTP profile such as
+// RTP/SAVP, which requires SRTP.
+func (m *MediaDescription) IsSecure() bool {
+	return strings.Contains(m.Proto, "SAVP")
+}
+
+// SetCrypto replaces the media description's a=crypto attributes.
+func (m *MediaDescription) SetCrypto(attrs []CryptoAttribute) {
+	kept := m.Attributes[:0:0]
+	for _, attr := range m.Attributes {
+		if !strings.HasPrefix(attr, "crypto:") {
+			kept = append(kept, attr)
+		}
+	}
+	for _, c := range attrs {
+		kept = append(kept, "crypto:"+c.String())
+	}
+	m.Attributes = kept
+	m.Crypto = attrs
 }
 
 // CodecByPayloadType returns the codec with the given payload type, or nil.
@@ -105,6 +127,96 @@ func (m *MediaDescription) HasCodec(name string) bool {
 	return m.CodecByName(name) != nil
 }
 
+// SetDirection replaces the media direction attribute (sendrecv, sendonly,
+// recvonly or inactive).
+func (m *MediaDescription) SetDirection(dir string) {
+	attrs := m.Attributes[:0:0]
+	for _, attr := range m.Attributes {
+		if !isDirectionAttribute(attr) {
+			attrs = append(attrs, attr)
+		}
+	}
+	m.Attributes = append(attrs, dir)
+	m.Direction = dir
+}
+
+// RetainFormats removes every payload type not in pts from the media
+// description, along with its rtpmap and fmtp attributes.
+func (m *MediaDescription) RetainFormats(pts []int) {
+	keep := make(map[int]bool, len(pts))
+	for _, pt := range pts {
+		keep[pt] = true
+	}
+
+	formats := m.Formats[:0:0]
+	for _, pt := range m.Formats {
+		if keep[pt] {
+			formats = append(formats, pt)
+		}
+	}
+	m.Formats = formats
+
+	codecs := m.Codecs[:0:0]
+	for _, c := range m.Codecs {
+		if keep[c.PayloadType] {
+			codecs = append(codecs, c)
+		}
+	}
+	m.Codecs = codecs
+
+	attrs := m.Attributes[:0:0]
+	for _, attr := range m.Attributes {
+		var value string
+		switch {
+		case strings.HasPrefix(attr, "rtpmap:"):
+			value = attr[7:]
+		case strings.HasPrefix(attr, "fmtp:"):
+			value = attr[5:]
+		default:
+			attrs = append(attrs, attr)
+			continue
+		}
+		ptStr, _, _ := strings.Cut(value, " ")
+		if pt, err := strconv.Atoi(ptStr); err != nil || keep[pt] {
+			attrs = append(attrs, attr)
+		}
+	}
+	m.Attributes = attrs
+}
+
+// AddCodec appends a codec to the media description's formats, with its
+// rtpmap attribute and, if it has format parameters, its fmtp attribute.
+func (m *MediaDescription) AddCodec(c Codec) {
+	// Clip before appending: rewritten copies share these slices with
+	// the description they were copied from.
+	m.Formats = append(m.Formats[:len(m.Formats):len(m.Formats)], c.PayloadType)
+	m.Codecs = append(m.Codecs[:len(m.Codecs):len(m.Codecs)], c)
+	m.Attributes = append(m.Attributes[:len(m.Attributes):len(m.Attributes)], "rtpmap:"+c.String())
+	if c.Fmtp != "" {
+		m.Attributes = append(m.Attributes, "fmtp:"+strconv.Itoa(c.PayloadType)+" "+c.Fmtp)
+	}
+}
+
+// isDirectionAttribute reports whether an a= value is a direction attribute.
+func isDirectionAttribute(attr string) bool {
+	return attr == "sendrecv" || attr == "sendonly" || attr == "recvonly" || attr == "inactive"
+}
+
+// AnswerDirection returns the direction an answerer uses in response to an
+// offered direction (RFC 3264 §6.1).
+func AnswerDirection(offer string) string {
+	switch offer {
+	case "sendonly":
+		return "recvonly"
+	case "recvonly":
+		return "sendonly"
+	case "inactive":
+		return "inactive"
+	default:
+		return "sendrecv"
+	}
+}
+
 // SessionDescription holds a fully parsed SDP session.
 type SessionDescription struct {
 	Version     int
@@ -127,6 +239,20 @@ func (s *SessionDescription) AudioMedia() *MediaDescription {
 	return nil
 }
 
+// IsHold reports whether the session puts the far end on hold: the audio
+// stream is sendonly or inactive, or uses the RFC 2543 0.0.0.0 connection
+// address.
+func (s *SessionDescription) IsHold() bool {
+	audio := s.AudioMedia()
+	if audio == nil {
+		return false
+	}
+	if audio.Direction == "sendonly" || audio.Direction == "inactive" {
+		return true
+	}
+	return s.ConnectionAddress(audio) == "0.0.0.0"
+}
+
 // ConnectionAddress returns the effective connection address for a media
 // description, preferring the media-level c= line over the session-level one.
 func (s *SessionDescription) ConnectionAddress(m *MediaDescription) string {
@@ -373,7 +499,12 @@ func parseMediaAttribute(md *MediaDescription, attr string) {
 			md.Codecs = append(md.Codecs, Codec{PayloadType: pt, Fmtp: params})
 		}
 
-	case attr == "sendrecv" || attr == "sendonly" || attr == "recvonly" || attr == "inactive":
+	case strings.HasPrefix(attr, "crypto:"):
+		if c, err := parseCrypto(attr[7:]); err == nil {
+			md.Crypto = append(md.Crypto, c)
+		}
+
+	case isDirectionAttribute(attr):
 		md.Direction = attr
 	}
 }
@@ -282,6 +282,110 @@ a=rtpmap:0 PCMU/8000
 	}
 }
 
+func TestSessionDescription_IsHold(t *testing.T) {
+	tests := []struct {
+		name string
+		conn string
+		dir  string
+		want bool
+	}{
+		{"sendrecv", "10.0.0.1", "sendrecv", false},
+		{"recvonly", "10.0.0.1", "recvonly", false},
+		{"sendonly", "10.0.0.1", "sendonly", true},
+		{"inactive", "10.0.0.1", "inactive", true},
+		{"rfc 2543 zero address", "0.0.0.0", "sendrecv", true},
+	}
+
+	for _, tt := range tests {
+		sdp := "v=0\no=- 1 1 IN IP4 10.0.0.1\ns=-\nc=IN IP4 " + tt.conn +
+			"\nt=0 0\nm=audio 5004 RTP/AVP 0\na=" + tt.dir + "\n"
+		sd, err := ParseSDP([]byte(sdp))
+		if err != nil {
+			t.Fatalf("%s: ParseSDP failed: %v", tt.name, err)
+		}
+		if got := sd.IsHold(); got != tt.want {
+			t.Errorf("%s: IsHold() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnswerDirection(t *testing.T) {
+	tests := map[string]string{
+		"sendrecv": "sendrecv",
+		"sendonly": "recvonly",
+		"recvonly": "sendonly",
+		"inactive": "inactive",
+	}
+	for offer, want := range tests {
+		if got := AnswerDirection(offer); got != want {
+			t.Errorf("AnswerDirection(%q) = %q, want %q", offer, got, want)
+		}
+	}
+}
+
+func TestMediaDescription_SetDirectionAndRetainFormats(t *testing.T) {
+	sd, err := ParseSDP([]byte(testSDPOffer))
+	if err != nil {
+		t.Fatalf("ParseSDP failed: %v", err)
+	}
+
+	audio := sd.AudioMedia()
+	audio.SetDirection("recvonly")
+	audio.RetainFormats([]int{8, 101})
+
+	reparsed, err := ParseSDP(sd.Marshal())
+	if err != nil {
+		t.Fatalf("ParseSDP of marshaled sdp failed: %v", err)
+	}
+	got := reparsed.AudioMedia()
+
+	if got.Direction != "recvonly" {
+		t.Errorf("direction = %q, want %q", got.Direction, "recvonly")
+	}
+	if len(got.Formats) != 2 || got.Formats[0] != 8 || got.Formats[1] != 101 {
+		t.Errorf("formats = %v, want [8 101]", got.Formats)
+	}
+	if got.HasCodec("PCMU") || got.HasCodec("opus") {
+		t.Errorf("removed codecs still present: %+v", got.Codecs)
+	}
+	if c := got.CodecByPayloadType(101); c == nil || c.Fmtp != "0-16" {
+		t.Errorf("telephone-event fmtp not retained: %+v", c)
+	}
+	for _, attr := range got.Attributes {
+		if attr == "sendrecv" {
+			t.Error("old direction attribute not removed")
+		}
+	}
+}
+
+func TestMediaDescription_AddCodec(t *testing.T) {
+	sd, err := ParseSDP([]byte(testSDPOffer))
+	if err != nil {
+		t.Fatalf("ParseSDP failed: %v", err)
+	}
+
+	rewritten := RewriteSDP(sd, "10.0.0.99", 20000)
+	rewritten.AudioMedia().AddCodec(Codec{PayloadType: 9, Name: "G722", ClockRate: 8000})
+	rewritten.AudioMedia().AddCodec(Codec{PayloadType: 96, Name: "opus", ClockRate: 48000, Channels: 2, Fmtp: "useinbandfec=1"})
+
+	reparsed, err := ParseSDP(rewritten.Marshal())
+	if err != nil {
+		t.Fatalf("ParseSDP of marshaled sdp failed: %v", err)
+	}
+	got := reparsed.AudioMedia()
+	if n := len(got.Formats); n < 2 || got.Formats[n-2] != 9 || got.Formats[n-1] != 96 {
+		t.Errorf("formats = %v, want 9 and 96 appended", got.Formats)
+	}
+	if c := got.CodecByPayloadType(96); c == nil || c.Name != "opus" || c.Channels != 2 || c.Fmtp != "useinbandfec=1" {
+		t.Errorf("added opus codec = %+v", c)
+	}
+
+	// The description the rewrite was copied from is untouched.
+	if sd.AudioMedia().HasCodec("G722") || len(sd.AudioMedia().Formats) != len(got.Formats)-2 {
+		t.Errorf("original media modified: %v", sd.AudioMedia().Formats)
+	}
+}
+
 func TestParseSDP_Empty(t *testing.T) {
 	_, err := ParseSDP([]byte(""))
 	if err == nil {
@@ -685,3 +789,44 @@ a=rtpmap:0 PCMU/8000
 		t.Errorf("address = %q, want %q", sd.Connection.Address, "2001:db8::1")
 	}
 }
+
+func TestParseSDP_Crypto(t *testing.T) {
+	sdp := `v=0
+o=- 1 1 IN IP4 10.0.0.1
+s=-
+c=IN IP4 10.0.0.1
+t=0 0
+m=audio 5004 RTP/SAVP 0
+a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz|2^20
+a=crypto:2 AEAD_AES_256_GCM inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz
+a=crypto:3 AES_CM_128_HMAC_SHA1_32 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz
+`
+	sd, err := ParseSDP([]byte(sdp))
+	if err != nil {
+		t.Fatalf("ParseSDP failed: %v", err)
+	}
+	audio := sd.AudioMedia()
+	if !audio.IsSecure() {
+		t.Error("RTP/SAVP media not reported as secure")
+	}
+	// The unsupported suite is skipped.
+	if len(audio.Crypto) != 2 || audio.Crypto[0].Tag != 1 || audio.Crypto[1].Suite != SuiteAESCM128HMACSHA1_32 {
+		t.Fatalf("crypto = %+v", audio.Crypto)
+	}
+
+	key, _ := NewCryptoAttribute(7, SuiteAESCM128HMACSHA1_80)
+	audio.SetCrypto([]CryptoAttribute{key})
+	reparsed, err := ParseSDP(sd.Marshal())
+	if err != nil {
+		t.Fatalf("ParseSDP after SetCrypto: %v", err)
+	}
+	got := reparsed.AudioMedia().Crypto
+	if len(got) != 1 || got[0].Tag != 7 || string(got[0].Key) != string(key.Key) {
+		t.Errorf("crypto after SetCrypto = %+v", got)
+	}
+
+	audio.SetCrypto(nil)
+	if strings.Contains(string(sd.Marshal()), "a=crypto") {
+		t.Error("SetCrypto(nil) left crypto attributes")
+	}
+}
@@ -196,6 +196,12 @@ func (m *SessionManager) SetSessionTimeout(d time.Duration) {
 	m.sessionTimeout = d
 }
 
+// Proxy returns the proxy sessions allocate their ports from, for mixers
+// that need sockets of their own.
+func (m *SessionManager) Proxy() *Proxy {
+	return m.proxy
+}
+
 // Allocate creates a new RTP session for a call by allocating two port pairs:
 // one for the caller leg and one for the callee leg. The session is registered
 // and returned in the New state.
@@ -0,0 +1,341 @@
+package media
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SDES crypto suites (RFC 4568) supported for SRTP.
+const (
+	SuiteAESCM128HMACSHA1_80 = "AES_CM_128_HMAC_SHA1_80"
+	SuiteAESCM128HMACSHA1_32 = "AES_CM_128_HMAC_SHA1_32"
+)
+
+// srtpAuthTagLen maps each supported crypto suite to its authentication
+// tag length in bytes.
+var srtpAuthTagLen = map[string]int{
+	SuiteAESCM128HMACSHA1_80: 10,
+	SuiteAESCM128HMACSHA1_32: 4,
+}
+
+const (
+	srtpMasterKeyLen  = 16
+	srtpMasterSaltLen = 14
+	srtpAuthKeyLen    = 20
+
+	// srtpReplayWindow is the number of packets behind the highest index
+	// received that may still arrive out of order (RFC 3711 §3.3.2).
+	srtpReplayWindow = 64
+)
+
+// Errors returned by SRTPContext.Unprotect.
+var (
+	ErrSRTPAuth   = errors.New("srtp authentication failed")
+	ErrSRTPReplay = errors.New("srtp packet replayed")
+)
+
+// CryptoAttribute is an SDES a=crypto attribute (RFC 4568): the crypto
+// suite and master key with which the sender of the SDP encrypts its
+// media.
+type CryptoAttribute struct {
+	Tag   int
+	Suite string
+	// Key is the master key followed by the master salt.
+	Key []byte
+}
+
+// NewCryptoAttribute returns a crypto attribute for suite with a random
+// master key and salt.
+func NewCryptoAttribute(tag int, suite string) (CryptoAttribute, error) {
+	if _, ok := srtpAuthTagLen[suite]; !ok {
+		return CryptoAttribute{}, fmt.Errorf("unsupported crypto suite %q", suite)
+	}
+	key := make([]byte, srtpMasterKeyLen+srtpMasterSaltLen)
+	if _, err := rand.Read(key); err != nil {
+		return CryptoAttribute{}, fmt.Errorf("generating srtp master key: %w", err)
+	}
+	return CryptoAttribute{Tag: tag, Suite: suite, Key: key}, nil
+}
+
+// String returns the a=crypto attribute value.
+func (c CryptoAttribute) String() string {
+	return strconv.Itoa(c.Tag) + " " + c.Suite + " inline:" + base64.StdEncoding.EncodeToString(c.Key)
+}
+
+// sameKey reports whether c and o name the same suite and master key.
+func (c CryptoAttribute) sameKey(o CryptoAttribute) bool {
+	return c.Suite == o.Suite && bytes.Equal(c.Key, o.Key)
+}
+
+// parseCrypto parses an a=crypto attribute value:
+// <tag> <crypto-suite> inline:<key||salt>[|<lifetime>][|<MKI>:<length>] [<session-params>]
+// Attributes this package cannot use, with unknown suites, several keys,
+// MKIs or session parameters, are rejected.
+func parseCrypto(value string) (CryptoAttribute, error) {
+	parts := strings.Fields(value)
+	if len(parts) < 3 {
+		return CryptoAttribute{}, fmt.Errorf("expected 3 fields, got %d", len(parts))
+	}
+	if len(parts) > 3 {
+		return CryptoAttribute{}, fmt.Errorf("session parameters not supported")
+	}
+
+	tag, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return CryptoAttribute{}, fmt.Errorf("invalid tag: %w", err)
+	}
+	if _, ok := srtpAuthTagLen[parts[1]]; !ok {
+		return CryptoAttribute{}, fmt.Errorf("unsupported crypto suite %q", parts[1])
+	}
+
+	keyParams, ok := strings.CutPrefix(parts[2], "inline:")
+	if !ok || strings.Contains(keyParams, ";") {
+		return CryptoAttribute{}, fmt.Errorf("expected a single inline key")
+	}
+	fields := strings.Split(keyParams, "|")
+	for _, f := range fields[1:] {
+		if strings.Contains(f, ":") {
+			return CryptoAttribute{}, fmt.Errorf("mki not supported")
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(fields[0])
+	if err != nil {
+		return CryptoAttribute{}, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(key) != srtpMasterKeyLen+srtpMasterSaltLen {
+		return CryptoAttribute{}, fmt.Errorf("key is %d bytes, want %d", len(key), srtpMasterKeyLen+srtpMasterSaltLen)
+	}
+
+	return CryptoAttribute{Tag: tag, Suite: parts[1], Key: key}, nil
+}
+
+// LegSRTP holds the SDES keys of one leg of a relay.
+type LegSRTP struct {
+	// Remote is the key the far end encrypts with, from its SDP.
+	Remote CryptoAttribute
+	// Local is the key the relay encrypts with, from the SDP the PBX
+	// sent to the far end.
+	Local CryptoAttribute
+}
+
+// SRTPContext protects or unprotects RTP packets (RFC 3711) under one
+// master key, tracking the rollover counter of each SSRC. It is safe for
+// concurrent use.
+type SRTPContext struct {
+	tagLen int
+
+	mu      sync.Mutex
+	block   cipher.Block
+	salt    [srtpMasterSaltLen]byte
+	mac     hash.Hash
+	streams map[uint32]*srtpStream
+}
+
+// srtpStream is the per-SSRC state of an SRTP context.
+type srtpStream struct {
+	roc     uint32
+	lastSeq uint16
+	// replay has bit i set if the packet i behind the highest index has
+	// been received.
+	replay uint64
+}
+
+// NewSRTPContext derives the session keys for c's master key.
+func NewSRTPContext(c CryptoAttribute) (*SRTPContext, error) {
+	tagLen, ok := srtpAuthTagLen[c.Suite]
+	if !ok {
+		return nil, fmt.Errorf("unsupported crypto suite %q", c.Suite)
+	}
+	if len(c.Key) != srtpMasterKeyLen+srtpMasterSaltLen {
+		return nil, fmt.Errorf("srtp key is %d bytes, want %d", len(c.Key), srtpMasterKeyLen+srtpMasterSaltLen)
+	}
+
+	encKey, authKey, salt, err := srtpDeriveKeys(c.Key[:srtpMasterKeyLen], c.Key[srtpMasterKeyLen:])
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating srtp cipher: %w", err)
+	}
+
+	s := &SRTPContext{
+		tagLen:  tagLen,
+		block:   block,
+		mac:     hmac.New(sha1.New, authKey),
+		streams: make(map[uint32]*srtpStream),
+	}
+	copy(s.salt[:], salt)
+	return s, nil
+}
+
+// srtpDeriveKeys derives the SRTP session encryption key, authentication
+// key and salt from a master key and salt, with a key derivation rate of
+// zero (RFC 3711 §4.3).
+func srtpDeriveKeys(masterKey, masterSalt []byte) (encKey, authKey, salt []byte, err error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("creating srtp key derivation cipher: %w", err)
+	}
+	derive := func(label byte, n int) []byte {
+		var iv [aes.BlockSize]byte
+		copy(iv[:], masterSalt)
+		iv[7] ^= label
+		out := make([]byte, n)
+		cipher.NewCTR(block, iv[:]).XORKeyStream(out, out)
+		return out
+	}
+	return derive(0x00, srtpMasterKeyLen), derive(0x01, srtpAuthKeyLen), derive(0x02, srtpMasterSaltLen), nil
+}
+
+// Protect encrypts and authenticates the RTP packet pkt, appending the
+// SRTP packet to dst. dst may be pkt[:0] if it has room for the tag.
+func (s *SRTPContext) Protect(dst, pkt []byte) ([]byte, error) {
+	off := rtpPayloadOffset(pkt)
+	if off < 0 {
+		return nil, fmt.Errorf("malformed rtp packet")
+	}
+	ssrc := binary.BigEndian.Uint32(pkt[8:12])
+	seq := binary.BigEndian.Uint16(pkt[2:4])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.streams[ssrc]
+	if st == nil {
+		st = &srtpStream{lastSeq: seq}
+		s.streams[ssrc] = st
+	}
+	roc := st.estimateROC(seq)
+	st.advance(roc, seq)
+
+	out := append(dst, pkt...)
+	body := out[len(dst):]
+	s.crypt(body[off:], ssrc, roc, seq)
+	return append(out, s.authTag(body, roc)...), nil
+}
+
+// Unprotect authenticates and decrypts the SRTP packet pkt, appending the
+// RTP packet to dst. dst may be pkt[:0]. It returns ErrSRTPAuth for packets
+// that fail authentication and ErrSRTPReplay for ones already received.
+func (s *SRTPContext) Unprotect(dst, pkt []byte) ([]byte, error) {
+	if len(pkt) < minRTPHeader+s.tagLen {
+		return nil, fmt.Errorf("srtp packet too short")
+	}
+	body, tag := pkt[:len(pkt)-s.tagLen], pkt[len(pkt)-s.tagLen:]
+	off := rtpPayloadOffset(body)
+	if off < 0 {
+		return nil, fmt.Errorf("malformed srtp packet")
+	}
+	ssrc := binary.BigEndian.Uint32(pkt[8:12])
+	seq := binary.BigEndian.Uint16(pkt[2:4])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.streams[ssrc]
+	fresh := st == nil
+	if fresh {
+		st = &srtpStream{lastSeq: seq}
+	}
+	roc := st.estimateROC(seq)
+	if !fresh && st.replayed(roc, seq) {
+		return nil, ErrSRTPReplay
+	}
+	if subtle.ConstantTimeCompare(s.authTag(body, roc), tag) != 1 {
+		return nil, ErrSRTPAuth
+	}
+	if fresh {
+		s.streams[ssrc] = st
+	}
+	st.advance(roc, seq)
+
+	out := append(dst, body...)
+	s.crypt(out[len(dst)+off:], ssrc, roc, seq)
+	return out, nil
+}
+
+// crypt applies the AES counter mode keystream for a packet to payload in
+// place (RFC 3711 §4.1.1).
+func (s *SRTPContext) crypt(payload []byte, ssrc, roc uint32, seq uint16) {
+	var iv [aes.BlockSize]byte
+	copy(iv[:], s.salt[:])
+	var x [aes.BlockSize]byte
+	binary.BigEndian.PutUint32(x[4:8], ssrc)
+	binary.BigEndian.PutUint32(x[8:12], roc)
+	binary.BigEndian.PutUint16(x[12:14], seq)
+	subtle.XORBytes(iv[:], iv[:], x[:])
+	cipher.NewCTR(s.block, iv[:]).XORKeyStream(payload, payload)
+}
+
+// authTag returns the truncated HMAC-SHA1 tag of an SRTP packet body and
+// its rollover counter (RFC 3711 §4.2).
+func (s *SRTPContext) authTag(body []byte, roc uint32) []byte {
+	var rocBytes [4]byte
+	binary.BigEndian.PutUint32(rocBytes[:], roc)
+	s.mac.Reset()
+	s.mac.Write(body)
+	s.mac.Write(rocBytes[:])
+	return s.mac.Sum(nil)[:s.tagLen]
+}
+
+// estimateROC guesses the rollover counter of a packet with sequence
+// number seq from the highest sequence number seen (RFC 3711 §3.3.1).
+func (st *srtpStream) estimateROC(seq uint16) uint32 {
+	switch {
+	case st.lastSeq < 1<<15:
+		if int(seq)-int(st.lastSeq) > 1<<15 && st.roc > 0 {
+			return st.roc - 1
+		}
+	case int(st.lastSeq)-(1<<15) > int(seq):
+		return st.roc + 1
+	}
+	return st.roc
+}
+
+// srtpIndex returns a packet's 48-bit SRTP index.
+func srtpIndex(roc uint32, seq uint16) uint64 {
+	return uint64(roc)<<16 | uint64(seq)
+}
+
+// replayed reports whether the packet with the given index was received
+// already or is too old to tell.
+func (st *srtpStream) replayed(roc uint32, seq uint16) bool {
+	highest := srtpIndex(st.roc, st.lastSeq)
+	index := srtpIndex(roc, seq)
+	if index > highest {
+		return false
+	}
+	behind := highest - index
+	return behind >= srtpReplayWindow || st.replay&(1<<behind) != 0
+}
+
+// advance records a packet that was sent or authenticated.
+func (st *srtpStream) advance(roc uint32, seq uint16) {
+	highest := srtpIndex(st.roc, st.lastSeq)
+	index := srtpIndex(roc, seq)
+	switch {
+	case index > highest:
+		if shift := index - highest; shift < srtpReplayWindow {
+			st.replay = st.replay<<shift | 1
+		} else {
+			st.replay = 1
+		}
+		st.roc, st.lastSeq = roc, seq
+	case highest-index < srtpReplayWindow:
+		st.replay |= 1 << (highest - index)
+	}
+}
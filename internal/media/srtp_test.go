@@ -0,0 +1,237 @@
+package media
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", s, err)
+	}
+	return b
+}
+
+// TestSRTPKeyDerivation checks the key derivation test vectors of
+// RFC 3711 appendix B.3.
+func TestSRTPKeyDerivation(t *testing.T) {
+	encKey, authKey, salt, err := srtpDeriveKeys(
+		mustHex(t, "E1F97A0D3E018BE0D64FA32C06DE4139"),
+		mustHex(t, "0EC675AD498AFEEBB6960B3AABE6"),
+	)
+	if err != nil {
+		t.Fatalf("srtpDeriveKeys: %v", err)
+	}
+	if want := mustHex(t, "C61E7A93744F39EE10734AFE3FF7A087"); !bytes.Equal(encKey, want) {
+		t.Errorf("cipher key = %X, want %X", encKey, want)
+	}
+	if want := mustHex(t, "30CBBC08863D8C85D49DB34A9AE1"); !bytes.Equal(salt, want) {
+		t.Errorf("cipher salt = %X, want %X", salt, want)
+	}
+	if want := mustHex(t, "CEBE321F6FF7716B6FD4AB49AF256A156D38BAA4"); !bytes.Equal(authKey, want) {
+		t.Errorf("auth key = %X, want %X", authKey, want)
+	}
+}
+
+// TestSRTPKeystream checks the AES counter mode test vector of RFC 3711
+// appendix B.2.
+func TestSRTPKeystream(t *testing.T) {
+	block, err := aes.NewCipher(mustHex(t, "2B7E151628AED2A6ABF7158809CF4F3C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SRTPContext{block: block}
+	copy(s.salt[:], mustHex(t, "F0F1F2F3F4F5F6F7F8F9FAFBFCFD"))
+
+	keystream := make([]byte, 48)
+	s.crypt(keystream, 0, 0, 0)
+	want := mustHex(t, "E03EAD0935C95E80E166B16DD92B4EB4"+
+		"D23513162B02D0F72A43A2FE4A5F97AB"+
+		"41E95B3BB0A2E8DD477901E4FCA894C0")
+	if !bytes.Equal(keystream, want) {
+		t.Errorf("keystream = %X, want %X", keystream, want)
+	}
+}
+
+func TestCryptoAttribute(t *testing.T) {
+	c, err := NewCryptoAttribute(1, SuiteAESCM128HMACSHA1_80)
+	if err != nil {
+		t.Fatalf("NewCryptoAttribute: %v", err)
+	}
+	parsed, err := parseCrypto(c.String())
+	if err != nil {
+		t.Fatalf("parseCrypto(%q): %v", c.String(), err)
+	}
+	if parsed.Tag != 1 || parsed.Suite != c.Suite || !bytes.Equal(parsed.Key, c.Key) {
+		t.Errorf("round trip = %+v, want %+v", parsed, c)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"lifetime", "1 AES_CM_128_HMAC_SHA1_32 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz|2^20", true},
+		{"mki", "1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz|2^20|1:4", false},
+		{"unknown suite", "1 AEAD_AES_256_GCM inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz", false},
+		{"session params", "1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz UNENCRYPTED_SRTP", false},
+		{"short key", "1 AES_CM_128_HMAC_SHA1_80 inline:AAAA", false},
+	}
+	for _, tt := range tests {
+		if _, err := parseCrypto(tt.value); (err == nil) != tt.ok {
+			t.Errorf("%s: parseCrypto error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestSRTPRoundTrip(t *testing.T) {
+	for _, suite := range []string{SuiteAESCM128HMACSHA1_80, SuiteAESCM128HMACSHA1_32} {
+		t.Run(suite, func(t *testing.T) {
+			key, _ := NewCryptoAttribute(1, suite)
+			sender, err := NewSRTPContext(key)
+			if err != nil {
+				t.Fatalf("NewSRTPContext: %v", err)
+			}
+			receiver, _ := NewSRTPContext(key)
+
+			// Cross the sequence number wrap so the rollover counter moves.
+			seq := uint16(65533)
+			for range 6 {
+				pkt := makeTestRTPPacket(PayloadPCMU, bytes.Repeat([]byte{0x55}, 160))
+				binary.BigEndian.PutUint16(pkt[2:4], seq)
+				seq++
+
+				protected, err := sender.Protect(nil, pkt)
+				if err != nil {
+					t.Fatalf("Protect: %v", err)
+				}
+				if len(protected) != len(pkt)+srtpAuthTagLen[suite] {
+					t.Fatalf("protected packet is %d bytes, want %d", len(protected), len(pkt)+srtpAuthTagLen[suite])
+				}
+				if bytes.Equal(protected[minRTPHeader:len(pkt)], pkt[minRTPHeader:]) {
+					t.Fatal("payload not encrypted")
+				}
+
+				got, err := receiver.Unprotect(protected[:0], protected)
+				if err != nil {
+					t.Fatalf("Unprotect seq %d: %v", seq-1, err)
+				}
+				if !bytes.Equal(got, pkt) {
+					t.Fatalf("seq %d: unprotected packet differs", seq-1)
+				}
+			}
+			if st := receiver.streams[1]; st.roc != 1 {
+				t.Errorf("roc = %d, want 1", st.roc)
+			}
+		})
+	}
+}
+
+func TestSRTPUnprotectRejects(t *testing.T) {
+	key, _ := NewCryptoAttribute(1, SuiteAESCM128HMACSHA1_80)
+	sender, _ := NewSRTPContext(key)
+	receiver, _ := NewSRTPContext(key)
+
+	protected, _ := sender.Protect(nil, makeTestRTPPacket(PayloadPCMU, []byte{1, 2, 3, 4}))
+
+	tampered := bytes.Clone(protected)
+	tampered[minRTPHeader] ^= 0x01
+	if _, err := receiver.Unprotect(nil, tampered); !errors.Is(err, ErrSRTPAuth) {
+		t.Errorf("tampered packet: err = %v, want ErrSRTPAuth", err)
+	}
+
+	if _, err := receiver.Unprotect(nil, protected); err != nil {
+		t.Fatalf("Unprotect: %v", err)
+	}
+	if _, err := receiver.Unprotect(nil, protected); !errors.Is(err, ErrSRTPReplay) {
+		t.Errorf("replayed packet: err = %v, want ErrSRTPReplay", err)
+	}
+
+	other, _ := NewCryptoAttribute(1, SuiteAESCM128HMACSHA1_80)
+	wrongKey, _ := NewSRTPContext(other)
+	if _, err := wrongKey.Unprotect(nil, protected); !errors.Is(err, ErrSRTPAuth) {
+		t.Errorf("wrong key: err = %v, want ErrSRTPAuth", err)
+	}
+}
+
+func TestRelaySRTP(t *testing.T) {
+	callerPair, callerLocalAddr := allocateTestPair(t)
+	defer callerPair.Close()
+	calleePair, calleeLocalAddr := allocateTestPair(t)
+	defer calleePair.Close()
+
+	callerPhone, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	defer callerPhone.Close()
+	calleePhone, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	defer calleePhone.Close()
+
+	session := &Session{
+		ID:        "test-session-srtp",
+		CallID:    "test-call-srtp",
+		CallerLeg: callerPair,
+		CalleeLeg: calleePair,
+		CreatedAt: time.Now(),
+		state:     SessionStateNew,
+	}
+
+	// The caller uses SRTP, the callee plain RTP.
+	phoneKey, _ := NewCryptoAttribute(1, SuiteAESCM128HMACSHA1_80)
+	relayKey, _ := NewCryptoAttribute(1, SuiteAESCM128HMACSHA1_80)
+	relay := NewRelay(session, callerPhone.LocalAddr().(*net.UDPAddr), calleePhone.LocalAddr().(*net.UDPAddr), []int{PayloadPCMU}, slog.Default())
+	if err := relay.SetSRTP(&LegSRTP{Remote: phoneKey, Local: relayKey}, nil); err != nil {
+		t.Fatalf("SetSRTP: %v", err)
+	}
+	relay.Start()
+	defer relay.Stop()
+
+	phoneOut, _ := NewSRTPContext(phoneKey)
+	phoneIn, _ := NewSRTPContext(relayKey)
+	buf := make([]byte, maxRTPPacket)
+
+	// Caller to callee: decrypted.
+	plain := makeTestRTPPacket(PayloadPCMU, []byte{1, 2, 3, 4})
+	protected, _ := phoneOut.Protect(nil, plain)
+	callerPhone.WriteToUDP(protected, callerLocalAddr)
+
+	calleePhone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := calleePhone.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("callee phone read: %v", err)
+	}
+	if !bytes.Equal(buf[:n], plain) {
+		t.Errorf("callee received %x, want %x", buf[:n], plain)
+	}
+
+	// Callee to caller: encrypted with the relay's key.
+	plain = makeTestRTPPacket(PayloadPCMU, []byte{5, 6, 7, 8})
+	calleePhone.WriteToUDP(plain, calleeLocalAddr)
+
+	callerPhone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err = callerPhone.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("caller phone read: %v", err)
+	}
+	got, err := phoneIn.Unprotect(nil, buf[:n])
+	if err != nil {
+		t.Fatalf("Unprotect: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("caller received %x, want %x", got, plain)
+	}
+
+	// Unauthenticated packets from the caller are dropped.
+	callerPhone.WriteToUDP(makeTestRTPPacket(PayloadPCMU, bytes.Repeat([]byte{9}, 20)), callerLocalAddr)
+	calleePhone.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, _, err := calleePhone.ReadFromUDP(buf); err == nil {
+		t.Error("plain rtp from an srtp leg was relayed")
+	}
+}
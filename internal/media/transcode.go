@@ -0,0 +1,196 @@
+package media
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
+)
+
+// PayloadG722 is the static RTP payload type of G.722.
+const PayloadG722 = 9
+
+// LegCodec is the audio codec negotiated on one leg of a call and the RTP
+// payload type that leg uses for it.
+type LegCodec struct {
+	PayloadType int
+	Codec       *codecs.Codec
+}
+
+// String returns the codec name and payload type, e.g. "PCMU/0".
+func (c LegCodec) String() string {
+	if c.Codec == nil {
+		return fmt.Sprintf("unknown/%d", c.PayloadType)
+	}
+	return fmt.Sprintf("%s/%d", c.Codec.Name, c.PayloadType)
+}
+
+// isG711 reports whether the recorders can store the leg's audio directly.
+func (c LegCodec) isG711() bool {
+	return c.Codec == codecs.PCMU || c.Codec == codecs.PCMA
+}
+
+// rtpPayloadOffset returns the offset of the payload in an RTP packet,
+// after the CSRC list and any header extension, or -1 if the packet is
+// malformed.
+func rtpPayloadOffset(pkt []byte) int {
+	if len(pkt) < minRTPHeader {
+		return -1
+	}
+	off := minRTPHeader + 4*int(pkt[0]&0x0F)
+	if pkt[0]&0x10 != 0 {
+		if len(pkt) < off+4 {
+			return -1
+		}
+		off += 4 + 4*int(binary.BigEndian.Uint16(pkt[off+2:off+4]))
+	}
+	if off > len(pkt) {
+		return -1
+	}
+	return off
+}
+
+// rtpPayload returns the payload of an RTP packet with any padding
+// removed, or nil if the packet is malformed.
+func rtpPayload(pkt []byte) []byte {
+	off := rtpPayloadOffset(pkt)
+	if off < 0 {
+		return nil
+	}
+	payload := pkt[off:]
+	if pkt[0]&0x20 != 0 && len(payload) > 0 {
+		pad := int(payload[len(payload)-1])
+		if pad > len(payload) {
+			return nil
+		}
+		payload = payload[:len(payload)-pad]
+	}
+	return payload
+}
+
+// streamConverter adapts the RTP stream of one relay direction from the
+// codec of the leg it arrives on to the codec of the leg it is sent to.
+// Audio is transcoded when the codecs differ; payload types are renumbered
+// and timestamps rescaled when only those differ. It is used by a single
+// forwarding goroutine and is not safe for concurrent use.
+type streamConverter struct {
+	src, dst LegCodec
+
+	// transcoder converts audio payloads, or is nil when both legs use
+	// the same codec.
+	transcoder *codecs.Transcoder
+
+	// recordDecoder decodes source audio to 8 kHz for a recorder when it
+	// is not G.711. Created on first use.
+	recordDecoder codecs.Decoder
+	pcm           []int16
+
+	// Timestamp mapping between the legs' RTP clocks.
+	tsStarted bool
+	tsIn      uint32
+	tsOut     uint32
+
+	out []byte
+}
+
+// newStreamConverter creates a converter from src's codec to dst's.
+func newStreamConverter(src, dst LegCodec) (*streamConverter, error) {
+	if src.Codec == nil || dst.Codec == nil {
+		return nil, fmt.Errorf("unknown codec: %s to %s", src, dst)
+	}
+	c := &streamConverter{src: src, dst: dst}
+	if src.Codec != dst.Codec {
+		t, err := codecs.NewTranscoder(src.Codec, dst.Codec)
+		if err != nil {
+			return nil, fmt.Errorf("transcoding %s to %s: %w", src.Codec.Name, dst.Codec.Name, err)
+		}
+		c.transcoder = t
+	}
+	return c, nil
+}
+
+// convert returns the packet to send for pkt, which has payload type pt.
+// The result may alias pkt or the converter's own buffer. It returns nil
+// if the packet cannot be converted.
+func (c *streamConverter) convert(pkt []byte, pt int) ([]byte, error) {
+	rescale := c.src.Codec.ClockRate != c.dst.Codec.ClockRate
+	if pt != c.src.PayloadType {
+		// Other payloads (telephone-event) only need their timestamps
+		// moved to the destination clock.
+		if rescale {
+			binary.BigEndian.PutUint32(pkt[4:8], c.timestamp(binary.BigEndian.Uint32(pkt[4:8])))
+		}
+		return pkt, nil
+	}
+
+	if c.transcoder == nil {
+		pkt[1] = pkt[1]&0x80 | byte(c.dst.PayloadType)
+		if rescale {
+			binary.BigEndian.PutUint32(pkt[4:8], c.timestamp(binary.BigEndian.Uint32(pkt[4:8])))
+		}
+		return pkt, nil
+	}
+
+	payload := rtpPayload(pkt)
+	if payload == nil {
+		return nil, fmt.Errorf("malformed rtp packet")
+	}
+
+	// Rebuild a plain 12-byte header: no padding, extension or CSRCs.
+	out := append(c.out[:0], pkt[:minRTPHeader]...)
+	out[0] = 0x80
+	out[1] = pkt[1]&0x80 | byte(c.dst.PayloadType)
+	binary.BigEndian.PutUint32(out[4:8], c.timestamp(binary.BigEndian.Uint32(pkt[4:8])))
+
+	out, err := c.transcoder.Transcode(out, payload)
+	if err != nil {
+		return nil, err
+	}
+	c.out = out
+	return out, nil
+}
+
+// timestamp maps a source RTP timestamp to the destination leg's clock.
+// Differences between successive timestamps are rescaled, so the mapping
+// survives timestamp wraparound.
+func (c *streamConverter) timestamp(ts uint32) uint32 {
+	if !c.tsStarted {
+		c.tsStarted = true
+		c.tsIn, c.tsOut = ts, ts
+		return ts
+	}
+	delta := int64(int32(ts - c.tsIn))
+	c.tsIn = ts
+	c.tsOut += uint32(delta * int64(c.dst.Codec.ClockRate) / int64(c.src.Codec.ClockRate))
+	return c.tsOut
+}
+
+// record feeds the audio in pkt, which has payload type pt, to rec.
+func (c *streamConverter) record(rec *Recorder, pkt []byte, pt int) error {
+	if pt != c.src.PayloadType {
+		return nil
+	}
+	payload := rtpPayload(pkt)
+	if len(payload) == 0 {
+		return nil
+	}
+	if c.src.isG711() {
+		rec.Feed(payload, c.src.Codec.PayloadType)
+		return nil
+	}
+
+	if c.recordDecoder == nil {
+		dec, err := c.src.Codec.NewDecoderAt(recorderSampleRate)
+		if err != nil {
+			return err
+		}
+		c.recordDecoder = dec
+	}
+	var err error
+	c.pcm, err = c.recordDecoder.Decode(c.pcm[:0], payload)
+	if err != nil {
+		return err
+	}
+	rec.FeedLinear(c.pcm)
+	return nil
+}
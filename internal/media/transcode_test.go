@@ -0,0 +1,118 @@
+package media
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
+)
+
+func TestRtpPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		pkt  []byte
+		want []byte
+	}{
+		{"plain", makeTestRTPPacket(0, []byte{1, 2, 3}), []byte{1, 2, 3}},
+		{"csrc", append([]byte{0x81, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 9, 9, 9, 9}, 4, 5), []byte{4, 5}},
+		{"extension", append([]byte{0x90, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0xBE, 0xDE, 0, 1, 7, 7, 7, 7}, 6), []byte{6}},
+		{"padding", append([]byte{0xA0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1}, 8, 0, 2), []byte{8}},
+		{"truncated", []byte{0x80, 0}, nil},
+		{"bad extension", []byte{0x90, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0xBE}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rtpPayload(tt.pkt)
+			if string(got) != string(tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("rtpPayload = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamConverter_Renumber(t *testing.T) {
+	// Same codec under different dynamic payload types on each leg.
+	c, err := newStreamConverter(LegCodec{PayloadType: 111, Codec: codecs.PCMU}, LegCodec{PayloadType: 0, Codec: codecs.PCMU})
+	if err != nil {
+		t.Fatalf("newStreamConverter: %v", err)
+	}
+
+	pkt := makeTestRTPPacket(111, []byte{0xFF, 0xFF})
+	pkt[1] |= 0x80
+	out, err := c.convert(pkt, 111)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if got := rtpPayloadType(out); got != 0 {
+		t.Errorf("payload type = %d, want 0", got)
+	}
+	if out[1]&0x80 == 0 {
+		t.Error("marker bit lost")
+	}
+}
+
+func TestStreamConverter_Transcode(t *testing.T) {
+	c, err := newStreamConverter(LegCodec{PayloadType: PayloadPCMU, Codec: codecs.PCMU}, LegCodec{PayloadType: PayloadG722, Codec: codecs.G722})
+	if err != nil {
+		t.Fatalf("newStreamConverter: %v", err)
+	}
+
+	enc, _ := codecs.PCMU.NewEncoder()
+	frame := make([]int16, samplesPerPacket)
+	payload, _ := enc.Encode(nil, frame)
+
+	for i := range 3 {
+		pkt := makeTestRTPPacket(PayloadPCMU, payload)
+		binary.BigEndian.PutUint32(pkt[4:8], uint32(1000+i*timestampIncrement))
+
+		out, err := c.convert(pkt, PayloadPCMU)
+		if err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		if got := rtpPayloadType(out); got != PayloadG722 {
+			t.Errorf("payload type = %d, want %d", got, PayloadG722)
+		}
+		// G.722 shares the 8 kHz RTP clock and packs 20 ms into 160 bytes.
+		if got := len(out) - rtpHeaderSize; got != 160 {
+			t.Errorf("payload is %d bytes, want 160", got)
+		}
+		if got, want := binary.BigEndian.Uint32(out[4:8]), uint32(1000+i*timestampIncrement); got != want {
+			t.Errorf("timestamp = %d, want %d", got, want)
+		}
+	}
+
+	// DTMF events pass through untouched.
+	dtmf := makeTestRTPPacket(PayloadTelephoneEvent, []byte{1, 0x80, 0, 160})
+	out, err := c.convert(dtmf, PayloadTelephoneEvent)
+	if err != nil {
+		t.Fatalf("convert dtmf: %v", err)
+	}
+	if rtpPayloadType(out) != PayloadTelephoneEvent || len(out) != len(dtmf) {
+		t.Errorf("telephone-event packet altered")
+	}
+}
+
+func TestStreamConverter_Timestamp(t *testing.T) {
+	// 8 kHz to 48 kHz: each 20 ms step of 160 becomes 960, across the
+	// 32-bit wrap of the source clock.
+	c := &streamConverter{
+		src: LegCodec{PayloadType: PayloadPCMU, Codec: codecs.PCMU},
+		dst: LegCodec{PayloadType: PayloadOpus, Codec: &codecs.Codec{Name: "opus", ClockRate: 48000}},
+	}
+	start := uint32(0xFFFFFFFF - 200)
+	if got := c.timestamp(start); got != start {
+		t.Fatalf("first timestamp = %d, want %d", got, start)
+	}
+	for i := 1; i <= 3; i++ {
+		got := c.timestamp(start + uint32(i*160))
+		if want := start + uint32(i*960); got != want {
+			t.Errorf("timestamp %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestNewStreamConverter_UnknownCodec(t *testing.T) {
+	if _, err := newStreamConverter(LegCodec{PayloadType: 18}, LegCodec{PayloadType: 0, Codec: codecs.PCMU}); err == nil {
+		t.Error("expected error for unknown codec")
+	}
+}
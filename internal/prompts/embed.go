@@ -23,4 +23,37 @@ var SystemPrompts = []string{
 	"ivr_timeout.wav",
 	"transfer_accept.wav",
 	"followme_confirm.wav",
+	"queue_hold_music.wav",
+	"queue_position.wav",
+	"digit_0.wav",
+	"digit_1.wav",
+	"digit_2.wav",
+	"digit_3.wav",
+	"digit_4.wav",
+	"digit_5.wav",
+	"digit_6.wav",
+	"digit_7.wav",
+	"digit_8.wav",
+	"digit_9.wav",
+	"vm_enter_mailbox.wav",
+	"vm_enter_pin.wav",
+	"vm_invalid_pin.wav",
+	"vm_new_messages.wav",
+	"vm_old_messages.wav",
+	"vm_main_menu.wav",
+	"vm_message_menu.wav",
+	"vm_message_deleted.wav",
+	"vm_message_saved.wav",
+	"vm_no_more_messages.wav",
+	"vm_record_greeting.wav",
+	"vm_greeting_saved.wav",
+	"vm_record_name.wav",
+	"vm_name_saved.wav",
+	"vm_goodbye.wav",
+	"caller_blocked.wav",
+	"call_parked.wav",
+	"dir_enter_first_name.wav",
+	"dir_enter_last_name.wav",
+	"dir_candidate_menu.wav",
+	"dir_no_match.wav",
 }
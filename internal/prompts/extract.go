@@ -29,6 +29,13 @@ func GreetingPath(dataDir string, boxID int64) string {
 	return filepath.Join(dataDir, "greetings", fmt.Sprintf("box_%d.wav", boxID))
 }
 
+// NamePath returns the standard file path for the recorded name of a
+// voicemail box's owner, announced by the dial-by-name directory. Names are
+// stored as $DATA_DIR/greetings/box_{id}_name.wav.
+func NamePath(dataDir string, boxID int64) string {
+	return filepath.Join(dataDir, "greetings", fmt.Sprintf("box_%d_name.wav", boxID))
+}
+
 // ExtractToDataDir copies the embedded system prompts to the data directory
 // so they can be served by the media player and referenced by flow nodes.
 // Files that already exist on disk are skipped, preserving any manual edits.
@@ -26,6 +26,39 @@ var defaultPrompts = []prompt{
 	{"ivr_timeout.wav", 1500},
 	{"transfer_accept.wav", 2000},
 	{"followme_confirm.wav", 2000},
+	{"queue_hold_music.wav", 10000},
+	{"queue_position.wav", 2000},
+	{"digit_0.wav", 500},
+	{"digit_1.wav", 500},
+	{"digit_2.wav", 500},
+	{"digit_3.wav", 500},
+	{"digit_4.wav", 500},
+	{"digit_5.wav", 500},
+	{"digit_6.wav", 500},
+	{"digit_7.wav", 500},
+	{"digit_8.wav", 500},
+	{"digit_9.wav", 500},
+	{"vm_enter_mailbox.wav", 2000},
+	{"vm_enter_pin.wav", 2000},
+	{"vm_invalid_pin.wav", 1500},
+	{"vm_new_messages.wav", 1000},
+	{"vm_old_messages.wav", 1000},
+	{"vm_main_menu.wav", 4000},
+	{"vm_message_menu.wav", 5000},
+	{"vm_message_deleted.wav", 1000},
+	{"vm_message_saved.wav", 1000},
+	{"vm_no_more_messages.wav", 1500},
+	{"vm_record_greeting.wav", 2500},
+	{"vm_greeting_saved.wav", 1500},
+	{"vm_record_name.wav", 2500},
+	{"vm_name_saved.wav", 1500},
+	{"vm_goodbye.wav", 1000},
+	{"caller_blocked.wav", 1500},
+	{"call_parked.wav", 1500},
+	{"dir_enter_first_name.wav", 3000},
+	{"dir_enter_last_name.wav", 3000},
+	{"dir_candidate_menu.wav", 3000},
+	{"dir_no_match.wav", 1500},
 }
 
 func main() {
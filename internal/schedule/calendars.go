@@ -0,0 +1,315 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// Calendar sources.
+const (
+	SourceUpload = "upload"
+	SourceURL    = "url"
+)
+
+// MaxCalendarSize is the largest iCalendar file accepted, uploaded or
+// fetched.
+const MaxCalendarSize = 5 << 20
+
+// OverrideSet is an imported calendar of a time switch with its parsed
+// events. While any event is in progress the time switch follows Label.
+type OverrideSet struct {
+	CalendarID int64
+	Name       string
+	Label      string
+	Events     []Event
+}
+
+// Covering returns the first event of the set in progress at t.
+func (s *OverrideSet) Covering(t time.Time) (*Event, bool) {
+	for i := range s.Events {
+		if _, ok := s.Events[i].Covering(t); ok {
+			return &s.Events[i], true
+		}
+	}
+	return nil, false
+}
+
+// Calendars stores the iCalendar files imported into time switches in the
+// data directory and keeps their parsed events in memory, reparsing a
+// file only when it changes.
+type Calendars struct {
+	repo   database.TimeSwitchCalendarRepository
+	dir    string
+	client *http.Client
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	cache map[int64]cachedCalendar
+}
+
+// cachedCalendar is a parsed calendar file and the modification time of
+// the file it was parsed from.
+type cachedCalendar struct {
+	modTime time.Time
+	events  []Event
+}
+
+// Dir returns the directory imported calendar files are stored in.
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "calendars")
+}
+
+// NewCalendars creates a calendar store for files under dataDir.
+func NewCalendars(repo database.TimeSwitchCalendarRepository, dataDir string, logger *slog.Logger) *Calendars {
+	return &Calendars{
+		repo:   repo,
+		dir:    Dir(dataDir),
+		client: &http.Client{Timeout: 30 * time.Second},
+		logger: logger.With("subsystem", "calendars"),
+		cache:  make(map[int64]cachedCalendar),
+	}
+}
+
+// path returns the file a calendar is stored in.
+func (c *Calendars) path(id int64) string {
+	return filepath.Join(c.dir, strconv.FormatInt(id, 10)+".ics")
+}
+
+// OverrideSets returns the imported calendars of a time switch with their
+// events. A URL calendar that has not been fetched yet has no events.
+func (c *Calendars) OverrideSets(ctx context.Context, timeSwitchID int64) ([]OverrideSet, error) {
+	cals, err := c.repo.ListByTimeSwitch(ctx, timeSwitchID)
+	if err != nil {
+		return nil, fmt.Errorf("listing time switch calendars: %w", err)
+	}
+
+	sets := make([]OverrideSet, 0, len(cals))
+	for _, cal := range cals {
+		events, err := c.events(cal.ID)
+		if err != nil {
+			return nil, fmt.Errorf("loading calendar %q: %w", cal.Name, err)
+		}
+		sets = append(sets, OverrideSet{
+			CalendarID: cal.ID,
+			Name:       cal.Name,
+			Label:      cal.Label,
+			Events:     events,
+		})
+	}
+	return sets, nil
+}
+
+// events returns the parsed events of a calendar file, from the cache if
+// the file has not changed since it was last parsed.
+func (c *Calendars) events(id int64) ([]Event, error) {
+	path := c.path(id)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking calendar file: %w", err)
+	}
+
+	c.mu.Lock()
+	cached, ok := c.cache[id]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.events, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading calendar file: %w", err)
+	}
+	cal, err := ParseICS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cache[id] = cachedCalendar{modTime: info.ModTime(), events: cal.Events}
+	c.mu.Unlock()
+	return cal.Events, nil
+}
+
+// Save validates an iCalendar file and stores it as the file of a
+// calendar, replacing any previous one. Returns the parsed calendar.
+func (c *Calendars) Save(id int64, data []byte) (*Calendar, error) {
+	cal, err := ParseICS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(c.dir, 0750); err != nil {
+		return nil, fmt.Errorf("creating calendar directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating calendar file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("writing calendar file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("writing calendar file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(id)); err != nil {
+		return nil, fmt.Errorf("storing calendar file: %w", err)
+	}
+
+	c.mu.Lock()
+	delete(c.cache, id)
+	c.mu.Unlock()
+	return cal, nil
+}
+
+// Remove deletes the stored file of a calendar.
+func (c *Calendars) Remove(id int64) error {
+	c.mu.Lock()
+	delete(c.cache, id)
+	c.mu.Unlock()
+
+	if err := os.Remove(c.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing calendar file: %w", err)
+	}
+	return nil
+}
+
+// Refresh fetches a URL calendar and stores it, recording the outcome on
+// the calendar. On failure the previously fetched file is kept.
+func (c *Calendars) Refresh(ctx context.Context, cal *models.TimeSwitchCalendar) error {
+	parsed, err := c.fetch(ctx, cal)
+	if err != nil {
+		if rerr := c.repo.UpdateRefresh(ctx, cal.ID, 0, err.Error()); rerr != nil {
+			c.logger.Error("failed to record calendar refresh", "calendar_id", cal.ID, "error", rerr)
+		}
+		return err
+	}
+
+	if err := c.repo.UpdateRefresh(ctx, cal.ID, len(parsed.Events), ""); err != nil {
+		return err
+	}
+	c.logger.Info("calendar refreshed",
+		"calendar_id", cal.ID,
+		"name", cal.Name,
+		"events", len(parsed.Events),
+		"skipped", parsed.Skipped,
+	)
+	return nil
+}
+
+// fetch downloads and stores a URL calendar.
+func (c *Calendars) fetch(ctx context.Context, cal *models.TimeSwitchCalendar) (*Calendar, error) {
+	u, err := FetchURL(cal.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", "FlowPBX-Calendar/1.0")
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching calendar: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxCalendarSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading calendar: %w", err)
+	}
+	if len(data) > MaxCalendarSize {
+		return nil, fmt.Errorf("calendar is larger than %d bytes", MaxCalendarSize)
+	}
+	return c.Save(cal.ID, data)
+}
+
+// RefreshDue fetches every URL calendar whose refresh interval has passed
+// since it was last fetched, or that has never been fetched.
+func (c *Calendars) RefreshDue(ctx context.Context) {
+	cals, err := c.repo.ListBySource(ctx, SourceURL)
+	if err != nil {
+		c.logger.Error("failed to list url calendars", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range cals {
+		cal := &cals[i]
+		if cal.RefreshedAt != nil && now.Sub(*cal.RefreshedAt) < time.Duration(cal.RefreshMinutes)*time.Minute {
+			continue
+		}
+		if err := c.Refresh(ctx, cal); err != nil {
+			c.logger.Warn("calendar refresh failed, keeping cached copy",
+				"calendar_id", cal.ID,
+				"name", cal.Name,
+				"error", err,
+			)
+		}
+	}
+}
+
+// FetchURL returns the HTTP URL to fetch a calendar URL from, accepting
+// webcal:// subscription links as HTTPS.
+func FetchURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid calendar url: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("calendar url must be http, https or webcal")
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("calendar url has no host")
+	}
+	return u.String(), nil
+}
+
+// StartRefreshTicker runs a background goroutine that fetches URL
+// calendars as their refresh intervals pass, checking every interval. The
+// goroutine stops when the provided context is cancelled.
+func StartRefreshTicker(ctx context.Context, calendars *Calendars, interval time.Duration) {
+	go func() {
+		calendars.RefreshDue(ctx)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				calendars.RefreshDue(ctx)
+			}
+		}
+	}()
+}
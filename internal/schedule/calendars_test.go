@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+func TestCalendarsRefresh(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	ts := &models.TimeSwitch{Name: "hours", Timezone: "Australia/Sydney", Rules: "[]", Overrides: "[]"}
+	if err := database.NewTimeSwitchRepository(db).Create(ctx, ts); err != nil {
+		t.Fatalf("creating time switch: %v", err)
+	}
+
+	var gone atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gone.Load() {
+			http.Error(w, "gone", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(testICS))
+	}))
+	defer srv.Close()
+
+	repo := database.NewTimeSwitchCalendarRepository(db)
+	cal := &models.TimeSwitchCalendar{
+		TimeSwitchID:   ts.ID,
+		Name:           "holidays",
+		Label:          "Closed",
+		Source:         SourceURL,
+		URL:            srv.URL,
+		RefreshMinutes: 60,
+	}
+	if err := repo.Create(ctx, cal); err != nil {
+		t.Fatalf("creating calendar: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	calendars := NewCalendars(repo, dir, logger)
+
+	// Not fetched yet: the calendar has no events.
+	sets, err := calendars.OverrideSets(ctx, ts.ID)
+	if err != nil || len(sets) != 1 || len(sets[0].Events) != 0 {
+		t.Fatalf("OverrideSets() before fetch = %+v, %v; want one empty set", sets, err)
+	}
+
+	calendars.RefreshDue(ctx)
+	sets, err = calendars.OverrideSets(ctx, ts.ID)
+	if err != nil || len(sets) != 1 || len(sets[0].Events) != 4 {
+		t.Fatalf("OverrideSets() after fetch = %d sets, %v; want 4 events", len(sets), err)
+	}
+	syd, _ := time.LoadLocation("Australia/Sydney")
+	ev, ok := sets[0].Covering(time.Date(2025, 12, 25, 10, 0, 0, 0, syd))
+	if !ok || ev.Summary != "Christmas Day" {
+		t.Errorf("Covering(christmas) = %+v, %v", ev, ok)
+	}
+
+	// A failed fetch keeps the cached copy and records the error.
+	gone.Store(true)
+	got, _ := repo.GetByID(ctx, cal.ID)
+	if err := calendars.Refresh(ctx, got); err == nil {
+		t.Fatal("Refresh() of a 404 succeeded, want error")
+	}
+	got, _ = repo.GetByID(ctx, cal.ID)
+	if got.LastError == "" || got.EventCount != 4 {
+		t.Errorf("after failed refresh: last_error = %q, event_count = %d; want error and 4", got.LastError, got.EventCount)
+	}
+	sets, err = calendars.OverrideSets(ctx, ts.ID)
+	if err != nil || len(sets[0].Events) != 4 {
+		t.Errorf("OverrideSets() after failed fetch = %+v, %v; want cached events", sets, err)
+	}
+
+	if err := calendars.Remove(cal.ID); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	sets, _ = calendars.OverrideSets(ctx, ts.ID)
+	if len(sets[0].Events) != 0 {
+		t.Errorf("OverrideSets() after Remove() has %d events, want 0", len(sets[0].Events))
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"https://example.com/cal.ics", "https://example.com/cal.ics", true},
+		{"webcal://example.com/cal.ics", "https://example.com/cal.ics", true},
+		{"ftp://example.com/cal.ics", "", false},
+		{"/local/path.ics", "", false},
+	}
+	for _, tt := range tests {
+		got, err := FetchURL(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("FetchURL(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package schedule
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a VEVENT of an iCalendar file. End is exclusive. A floating
+// event (an all-day event, or one with a local time and no known time
+// zone) has its times stored as wall-clock values in UTC and happens at
+// that wall-clock time wherever it is evaluated.
+type Event struct {
+	UID      string
+	Summary  string
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+	Floating bool
+	RRule    *RRule
+	ExDates  []time.Time
+}
+
+// Calendar is a parsed iCalendar file.
+type Calendar struct {
+	Name   string // X-WR-CALNAME, if set
+	Events []Event
+
+	// Skipped counts the events left out because they were cancelled or
+	// could not be parsed (e.g. an unsupported recurrence rule).
+	Skipped int
+}
+
+// Covering returns the start of the occurrence of the event that is in
+// progress at t. Floating times are resolved in t's location.
+func (e *Event) Covering(t time.Time) (time.Time, bool) {
+	start := e.resolve(e.Start, t.Location())
+	d := e.End.Sub(e.Start)
+
+	if e.RRule == nil {
+		if !t.Before(start) && t.Before(start.Add(d)) {
+			return start, true
+		}
+		return time.Time{}, false
+	}
+
+	occ, ok := e.RRule.Covering(start, d, t)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, ex := range e.ExDates {
+		if occ.Equal(e.resolve(ex, t.Location())) {
+			return time.Time{}, false
+		}
+	}
+	return occ, true
+}
+
+// resolve returns the instant of an event time, interpreting a floating
+// time in loc.
+func (e *Event) resolve(t time.Time, loc *time.Location) time.Time {
+	if !e.Floating {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
+
+// icalProperty is a content line of an iCalendar file.
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// changedInstance identifies an instance of a recurring event that was
+// moved or changed by a separate VEVENT with a RECURRENCE-ID.
+type changedInstance struct {
+	uid          string
+	recurrenceID time.Time
+}
+
+// ParseICS parses the VEVENTs of an iCalendar file. Events that cannot be
+// used are counted in Skipped rather than failing the whole calendar; an
+// error is returned only if data is not an iCalendar file.
+func ParseICS(data []byte) (*Calendar, error) {
+	lines := unfoldLines(data)
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimSpace(lines[0]), "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("not an iCalendar file: missing BEGIN:VCALENDAR")
+	}
+
+	cal := &Calendar{}
+	var (
+		props   []icalProperty
+		inEvent bool
+		nested  int
+		changed []changedInstance
+	)
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		p, err := parseContentLine(line)
+		if err != nil {
+			if inEvent {
+				// Let the event fail on the missing property instead.
+				continue
+			}
+			return nil, err
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && !inEvent:
+			inEvent = true
+			props = props[:0]
+		case p.name == "BEGIN" && inEvent:
+			// Skip components nested in an event, such as VALARM.
+			nested++
+		case p.name == "END" && inEvent && nested > 0:
+			nested--
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT") && inEvent:
+			inEvent = false
+			ev, recurrenceID, ok := buildEvent(props)
+			switch {
+			case !ok:
+				cal.Skipped++
+			case recurrenceID != nil:
+				// A changed instance of a recurring event: it stands on
+				// its own and replaces the instance it was moved from.
+				changed = append(changed, changedInstance{uid: ev.UID, recurrenceID: *recurrenceID})
+				cal.Events = append(cal.Events, *ev)
+			default:
+				cal.Events = append(cal.Events, *ev)
+			}
+		case inEvent && nested == 0:
+			props = append(props, p)
+		case p.name == "X-WR-CALNAME" && !inEvent:
+			cal.Name = unescapeText(p.value)
+		}
+	}
+
+	// Exclude the original instances of changed recurrences from their
+	// recurring events.
+	for _, c := range changed {
+		for i := range cal.Events {
+			ev := &cal.Events[i]
+			if ev.UID == c.uid && ev.RRule != nil {
+				ev.ExDates = append(ev.ExDates, c.recurrenceID)
+			}
+		}
+	}
+	return cal, nil
+}
+
+// buildEvent builds an event from its properties, returning false if it
+// is cancelled or invalid. The RECURRENCE-ID of a changed instance of a
+// recurring event is returned as well.
+func buildEvent(props []icalProperty) (*Event, *time.Time, bool) {
+	ev := &Event{}
+	var (
+		hasStart     bool
+		hasEnd       bool
+		duration     time.Duration
+		hasDuration  bool
+		recurrenceID *time.Time
+	)
+	for _, p := range props {
+		var err error
+		switch p.name {
+		case "UID":
+			ev.UID = p.value
+		case "SUMMARY":
+			ev.Summary = unescapeText(p.value)
+		case "STATUS":
+			if strings.EqualFold(p.value, "CANCELLED") {
+				return nil, nil, false
+			}
+		case "DTSTART":
+			ev.Start, ev.AllDay, ev.Floating, err = parseDateTime(p)
+			hasStart = err == nil
+		case "DTEND":
+			ev.End, _, _, err = parseDateTime(p)
+			hasEnd = err == nil
+		case "DURATION":
+			duration, err = parseDuration(p.value)
+			hasDuration = err == nil
+		case "RRULE":
+			ev.RRule, err = ParseRRule(p.value)
+		case "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				var t time.Time
+				t, _, _, err = parseDateTime(icalProperty{name: p.name, params: p.params, value: v})
+				if err != nil {
+					break
+				}
+				ev.ExDates = append(ev.ExDates, t)
+			}
+		case "RECURRENCE-ID":
+			var t time.Time
+			t, _, _, err = parseDateTime(p)
+			recurrenceID = &t
+		}
+		if err != nil {
+			return nil, nil, false
+		}
+	}
+	if !hasStart {
+		return nil, nil, false
+	}
+
+	switch {
+	case hasEnd:
+		if ev.Floating {
+			// DTEND may be zoned while DTSTART is not; compare wall clocks.
+			ev.End = time.Date(ev.End.Year(), ev.End.Month(), ev.End.Day(),
+				ev.End.Hour(), ev.End.Minute(), ev.End.Second(), 0, time.UTC)
+		}
+	case hasDuration:
+		ev.End = ev.Start.Add(duration)
+	case ev.AllDay:
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	default:
+		ev.End = ev.Start
+	}
+	if ev.End.Before(ev.Start) {
+		return nil, nil, false
+	}
+	return ev, recurrenceID, true
+}
+
+// parseDateTime parses a DATE or DATE-TIME property value. It reports
+// whether the value is a date and whether it is floating; floating values
+// are returned as wall-clock times in UTC. A TZID that is not a known IANA
+// time zone (e.g. a Windows zone name) is treated as floating.
+func parseDateTime(p icalProperty) (time.Time, bool, bool, error) {
+	v := strings.TrimSpace(p.value)
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(v) == 8 {
+		t, err := time.Parse("20060102", v)
+		return t, true, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		return t, false, false, err
+	}
+	if tzid := strings.Trim(p.params["TZID"], `"`); tzid != "" {
+		if loc, err := time.LoadLocation(tzid); err == nil {
+			t, err := time.ParseInLocation("20060102T150405", v, loc)
+			return t, false, false, err
+		}
+	}
+	t, err := time.Parse("20060102T150405", v)
+	return t, false, true, err
+}
+
+// parseDuration parses an iCalendar duration such as "P1D", "PT1H30M" or
+// "P2W".
+func parseDuration(s string) (time.Duration, error) {
+	orig := s
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "+"), "P")
+	if len(s) == len(orig) || s == "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+
+	var d time.Duration
+	inTime := false
+	num := ""
+	parts := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		num = ""
+		parts++
+		switch {
+		case c == 'W' && !inTime:
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case c == 'D' && !inTime:
+			d += time.Duration(n) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+	}
+	if num != "" || parts == 0 {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	return d, nil
+}
+
+// unfoldLines splits an iCalendar file into content lines, joining lines
+// folded onto continuation lines that begin with a space or tab.
+func unfoldLines(data []byte) []string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseContentLine splits a content line into its name, parameters and
+// value. Parameter values may be quoted and contain ':' or ';'.
+func parseContentLine(line string) (icalProperty, error) {
+	p := icalProperty{params: map[string]string{}}
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, fmt.Errorf("invalid iCalendar line %q", line)
+	}
+	p.name = strings.ToUpper(line[:i])
+	rest := line[i:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return p, fmt.Errorf("invalid parameter in iCalendar line %q", line)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("unterminated quoted parameter in iCalendar line %q", line)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return p, fmt.Errorf("invalid iCalendar line %q", line)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+		p.params[name] = value
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return p, fmt.Errorf("invalid iCalendar line %q", line)
+	}
+	p.value = rest[1:]
+	return p, nil
+}
+
+// unescapeText reverses the escaping of an iCalendar TEXT value.
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
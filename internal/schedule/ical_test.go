@@ -0,0 +1,165 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"X-WR-CALNAME:NSW Public Holidays\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:christmas\r\n" +
+	"DTSTART;VALUE=DATE:20251225\r\n" +
+	"DTEND;VALUE=DATE:20251226\r\n" +
+	"SUMMARY:Christmas Day\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:shutdown\r\n" +
+	"DTSTART;TZID=Australia/Sydney:20251229T120000\r\n" +
+	"DURATION:PT4H\r\n" +
+	"SUMMARY:Office closure\\, afternoon\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:training\r\n" +
+	"DTSTART:20250106T220000Z\r\n" +
+	"DTEND:20250106T230000Z\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO\r\n" +
+	"EXDATE:20250113T220000Z\r\n" +
+	"SUMMARY:Staff training\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:training\r\n" +
+	"RECURRENCE-ID:20250120T220000Z\r\n" +
+	"DTSTART:20250121T220000Z\r\n" +
+	"DTEND:20250121T230000Z\r\n" +
+	"SUMMARY:Staff training (moved)\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled\r\n" +
+	"DTSTART;VALUE=DATE:20251231\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:hourly\r\n" +
+	"DTSTART:20250101T000000Z\r\n" +
+	"RRULE:FREQ=HOURLY\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	cal, err := ParseICS([]byte(testICS))
+	if err != nil {
+		t.Fatalf("ParseICS() error: %v", err)
+	}
+	if cal.Name != "NSW Public Holidays" {
+		t.Errorf("Name = %q, want %q", cal.Name, "NSW Public Holidays")
+	}
+	if len(cal.Events) != 4 {
+		t.Fatalf("got %d events, want 4", len(cal.Events))
+	}
+	if cal.Skipped != 2 {
+		t.Errorf("Skipped = %d, want 2 (cancelled and unsupported rule)", cal.Skipped)
+	}
+
+	xmas := cal.Events[0]
+	if !xmas.AllDay || !xmas.Floating || xmas.Summary != "Christmas Day" {
+		t.Errorf("christmas = %+v, want floating all-day event", xmas)
+	}
+	if got := cal.Events[1].Summary; got != "Office closure, afternoon" {
+		t.Errorf("escaped summary = %q", got)
+	}
+	if got := cal.Events[1].End.Sub(cal.Events[1].Start); got != 4*time.Hour {
+		t.Errorf("DURATION event length = %v, want 4h", got)
+	}
+	if got := len(cal.Events[2].ExDates); got != 2 {
+		t.Errorf("recurring event has %d exdates, want 2 (EXDATE and moved instance)", got)
+	}
+}
+
+func TestParseICSFoldedLines(t *testing.T) {
+	data := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20250101\nSUMMARY:New Year\n 's Day\nEND:VEVENT\nEND:VCALENDAR\n"
+	cal, err := ParseICS([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseICS() error: %v", err)
+	}
+	if len(cal.Events) != 1 || cal.Events[0].Summary != "New Year's Day" {
+		t.Errorf("events = %+v, want one unfolded summary", cal.Events)
+	}
+}
+
+func TestParseICSNotCalendar(t *testing.T) {
+	if _, err := ParseICS([]byte("<html></html>")); err == nil {
+		t.Error("ParseICS() of html succeeded, want error")
+	}
+}
+
+func TestEventCovering(t *testing.T) {
+	cal, err := ParseICS([]byte(testICS))
+	if err != nil {
+		t.Fatalf("ParseICS() error: %v", err)
+	}
+	syd, _ := time.LoadLocation("Australia/Sydney")
+	perth, _ := time.LoadLocation("Australia/Perth")
+
+	byUID := func(uid, summary string) *Event {
+		for i := range cal.Events {
+			if cal.Events[i].UID == uid && strings.HasPrefix(cal.Events[i].Summary, summary) {
+				return &cal.Events[i]
+			}
+		}
+		t.Fatalf("event %s not found", uid)
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		event *Event
+		at    time.Time
+		want  bool
+	}{
+		{"all day in sydney", byUID("christmas", ""), time.Date(2025, 12, 25, 23, 30, 0, 0, syd), true},
+		{"all day is floating", byUID("christmas", ""), time.Date(2025, 12, 25, 0, 30, 0, 0, perth), true},
+		{"day after", byUID("christmas", ""), time.Date(2025, 12, 26, 0, 0, 0, 0, syd), false},
+		{"zoned event", byUID("shutdown", ""), time.Date(2025, 12, 29, 13, 0, 0, 0, syd), true},
+		{"zoned event elsewhere", byUID("shutdown", ""), time.Date(2025, 12, 29, 13, 0, 0, 0, perth), false},
+		{"recurring", byUID("training", "Staff training"), time.Date(2025, 1, 27, 22, 30, 0, 0, time.UTC), true},
+		{"excluded", byUID("training", "Staff training"), time.Date(2025, 1, 13, 22, 30, 0, 0, time.UTC), false},
+		{"moved from", byUID("training", "Staff training"), time.Date(2025, 1, 20, 22, 30, 0, 0, time.UTC), false},
+		{"moved to", byUID("training", "Staff training (moved)"), time.Date(2025, 1, 21, 22, 30, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := tt.event.Covering(tt.at); got != tt.want {
+				t.Errorf("Covering() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"P1D", 24 * time.Hour, true},
+		{"PT1H30M", 90 * time.Minute, true},
+		{"P1W", 7 * 24 * time.Hour, true},
+		{"P1DT2H", 26 * time.Hour, true},
+		{"PT", 0, false},
+		{"1D", 0, false},
+		{"P1H", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
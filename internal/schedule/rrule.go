@@ -0,0 +1,485 @@
+// Package schedule evaluates calendar schedules for time switches:
+// iCalendar (RFC 5545) recurrence rules, .ics calendar files, and the
+// imported calendars a time switch uses as override sets.
+package schedule
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a recurrence rule.
+type Frequency int
+
+// Supported recurrence frequencies. Sub-daily frequencies (HOURLY,
+// MINUTELY, SECONDLY) are not supported; a time switch rule gives the
+// time of day itself.
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+// maxPeriods bounds how many periods of a rule are expanded looking for an
+// occurrence, so a rule that never produces one (e.g. BYMONTHDAY=31 with
+// BYMONTH=2) cannot loop forever.
+const maxPeriods = 100000
+
+// WeekdayNum is a BYDAY entry: a weekday, optionally with an ordinal such
+// as 1 (first) or -1 (last) within the month or year.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int // 0 means every such weekday
+}
+
+// RRule is a parsed iCalendar recurrence rule. Occurrences start at the
+// time of day of the DTSTART they are evaluated against.
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int // 0 means unbounded
+	ByMonth    []int
+	ByMonthDay []int
+	ByDay      []WeekdayNum
+	BySetPos   []int
+	WeekStart  time.Weekday
+
+	// until is the UNTIL bound, the zero time if there is none. A
+	// floating or date-only UNTIL is resolved in the DTSTART's location.
+	until         time.Time
+	untilFloating bool
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRRule parses a recurrence rule such as
+// "FREQ=YEARLY;BYMONTH=1;BYDAY=-1MO". A leading "RRULE:" is accepted.
+func ParseRRule(s string) (*RRule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+
+	r := &RRule{Interval: 1, WeekStart: time.Monday}
+	hasFreq := false
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			hasFreq = true
+			switch strings.ToUpper(value) {
+			case "DAILY":
+				r.Freq = Daily
+			case "WEEKLY":
+				r.Freq = Weekly
+			case "MONTHLY":
+				r.Freq = Monthly
+			case "YEARLY":
+				r.Freq = Yearly
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		case "UNTIL":
+			r.until, r.untilFloating, err = parseUntil(value)
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, 1, 31, true)
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(value, 1, 366, true)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "WKST":
+			wd, ok := weekdayCodes[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("unknown weekday")
+			}
+			r.WeekStart = wd
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", strings.ToUpper(name), value, err)
+		}
+	}
+
+	if !hasFreq {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && !r.until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot both be set")
+	}
+	if r.Freq != Monthly && r.Freq != Yearly {
+		for _, d := range r.ByDay {
+			if d.N != 0 {
+				return nil, fmt.Errorf("BYDAY ordinals are only allowed with MONTHLY or YEARLY")
+			}
+		}
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("BYMONTHDAY is not allowed with WEEKLY")
+	}
+	return r, nil
+}
+
+// parseUntil parses an UNTIL value: a date, a UTC date-time or a floating
+// date-time. A date bounds the rule at the end of that day.
+func parseUntil(s string) (time.Time, bool, error) {
+	if len(s) == 8 {
+		t, err := time.Parse("20060102", s)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	if strings.HasSuffix(s, "Z") {
+		t, err := time.Parse("20060102T150405Z", s)
+		return t, false, err
+	}
+	t, err := time.Parse("20060102T150405", s)
+	return t, true, err
+}
+
+// parseIntList parses a comma-separated list of integers within
+// [lo, hi], or within [-hi, -lo] as well if negative is set.
+func parseIntList(s string, lo, hi int, negative bool) ([]int, error) {
+	var out []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		abs := n
+		if negative && n < 0 {
+			abs = -n
+		}
+		if abs < lo || abs > hi {
+			return nil, fmt.Errorf("%d out of range", n)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// parseByDay parses a BYDAY list such as "MO,WE,FR" or "1MO,-1FR".
+func parseByDay(s string) ([]WeekdayNum, error) {
+	var out []WeekdayNum
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToUpper(f)
+		if len(f) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", f)
+		}
+		wd, ok := weekdayCodes[f[len(f)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", f)
+		}
+		wn := WeekdayNum{Weekday: wd}
+		if prefix := f[:len(f)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid weekday ordinal %q", f)
+			}
+			wn.N = n
+		}
+		out = append(out, wn)
+	}
+	return out, nil
+}
+
+// Covering returns the start of the occurrence of the rule, with the
+// first occurrence at or after dtstart, that covers t: the occurrence
+// starting at or before t and lasting longer than t minus its start.
+func (r *RRule) Covering(dtstart time.Time, d time.Duration, t time.Time) (time.Time, bool) {
+	if t.Before(dtstart) {
+		return time.Time{}, false
+	}
+	until := r.untilIn(dtstart.Location())
+	from := t.Add(-d)
+
+	// Without COUNT every occurrence before the one that can cover t is
+	// irrelevant, so expansion can start close to t instead of dtstart.
+	period := 0
+	if r.Count == 0 {
+		period = r.periodsBefore(dtstart, from)
+	}
+
+	last := dateOf(t.In(dtstart.Location()))
+	count := 0
+	for i := 0; i < maxPeriods && !r.periodStart(dtstart, period).After(last); i++ {
+		for _, occ := range r.expand(dtstart, period) {
+			if occ.Before(dtstart) {
+				continue
+			}
+			if !until.IsZero() && occ.After(until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if occ.After(t) {
+				return time.Time{}, false
+			}
+			if occ.After(from) || (d == 0 && occ.Equal(t)) {
+				return occ, true
+			}
+		}
+		period += r.Interval
+	}
+	return time.Time{}, false
+}
+
+// untilIn returns the rule's UNTIL bound, resolving a floating one in loc.
+func (r *RRule) untilIn(loc *time.Location) time.Time {
+	if r.until.IsZero() || !r.untilFloating {
+		return r.until
+	}
+	u := r.until
+	return time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), 0, loc)
+}
+
+// periodsBefore returns the index of the last period, a multiple of the
+// interval, that begins at least one whole period before t. Occurrences of
+// earlier periods all end before the ones in it begin.
+func (r *RRule) periodsBefore(dtstart, t time.Time) int {
+	if !t.After(dtstart) {
+		return 0
+	}
+	t = t.In(dtstart.Location())
+	var n int
+	switch r.Freq {
+	case Daily:
+		n = daysBetween(dtstart, t)
+	case Weekly:
+		n = daysBetween(weekStart(dtstart, r.WeekStart), t) / 7
+	case Monthly:
+		n = (t.Year()-dtstart.Year())*12 + int(t.Month()-dtstart.Month())
+	case Yearly:
+		n = t.Year() - dtstart.Year()
+	}
+	n = n/r.Interval*r.Interval - r.Interval
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// periodStart returns the date the period, the one period intervals
+// after the period containing dtstart, begins on.
+func (r *RRule) periodStart(dtstart time.Time, period int) time.Time {
+	switch r.Freq {
+	case Weekly:
+		return weekStart(dtstart, r.WeekStart).AddDate(0, 0, 7*period)
+	case Monthly:
+		return time.Date(dtstart.Year(), dtstart.Month()+time.Month(period), 1, 0, 0, 0, 0, time.UTC)
+	case Yearly:
+		return time.Date(dtstart.Year()+period, 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return dateOf(dtstart).AddDate(0, 0, period)
+	}
+}
+
+// expand returns the occurrences of a period, the one period intervals
+// after the period containing dtstart, in order.
+func (r *RRule) expand(dtstart time.Time, period int) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := dateOf(dtstart).AddDate(0, 0, period)
+		if r.monthMatches(day) && r.monthDayMatches(day) && r.weekdayMatches(day) {
+			days = append(days, day)
+		}
+	case Weekly:
+		start := weekStart(dateOf(dtstart), r.WeekStart).AddDate(0, 0, 7*period)
+		for i := 0; i < 7; i++ {
+			day := start.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if r.monthMatches(day) && r.weekdayMatches(day) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		first := time.Date(dtstart.Year(), dtstart.Month()+time.Month(period), 1, 0, 0, 0, 0, time.UTC)
+		if r.monthMatches(first) {
+			days = r.monthDays(first.Year(), first.Month(), dtstart.Day())
+		}
+	case Yearly:
+		year := dtstart.Year() + period
+		switch {
+		case len(r.ByMonth) > 0:
+			for m := time.January; m <= time.December; m++ {
+				if r.monthMatches(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)) {
+					days = append(days, r.monthDays(year, m, dtstart.Day())...)
+				}
+			}
+		case len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				days = append(days, r.monthDays(year, m, dtstart.Day())...)
+			}
+		case len(r.ByDay) > 0:
+			days = r.weekdaysIn(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC))
+		default:
+			day := time.Date(year, dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, time.UTC)
+			if day.Day() == dtstart.Day() {
+				days = append(days, day)
+			}
+		}
+	}
+
+	days = r.applySetPos(days)
+	out := make([]time.Time, len(days))
+	for i, day := range days {
+		out[i] = time.Date(day.Year(), day.Month(), day.Day(),
+			dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+	}
+	return out
+}
+
+// monthDays returns the days of a month selected by BYMONTHDAY and BYDAY,
+// or the day of month dom if neither is set.
+func (r *RRule) monthDays(year int, month time.Month, dom int) []time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	next := first.AddDate(0, 1, 0)
+	last := next.AddDate(0, 0, -1).Day()
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		var days []time.Time
+		for d := 1; d <= last; d++ {
+			day := time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+			if r.monthDayMatches(day) && r.weekdayMatches(day) {
+				days = append(days, day)
+			}
+		}
+		return days
+	case len(r.ByDay) > 0:
+		return r.weekdaysIn(first, next)
+	default:
+		if dom > last {
+			return nil
+		}
+		return []time.Time{time.Date(year, month, dom, 0, 0, 0, 0, time.UTC)}
+	}
+}
+
+// weekdaysIn returns the days in [from, to) selected by BYDAY, in order.
+// An ordinal picks the nth (or nth from last) such weekday in the range.
+func (r *RRule) weekdaysIn(from, to time.Time) []time.Time {
+	var days []time.Time
+	for _, wd := range r.ByDay {
+		var matching []time.Time
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == wd.Weekday {
+				matching = append(matching, day)
+			}
+		}
+		switch {
+		case wd.N == 0:
+			days = append(days, matching...)
+		case wd.N > 0 && wd.N <= len(matching):
+			days = append(days, matching[wd.N-1])
+		case wd.N < 0 && -wd.N <= len(matching):
+			days = append(days, matching[len(matching)+wd.N])
+		}
+	}
+	slices.SortFunc(days, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(days, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+// applySetPos keeps the BYSETPOS positions of a period's sorted days.
+func (r *RRule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var out []time.Time
+	for i, day := range days {
+		for _, pos := range r.BySetPos {
+			if pos == i+1 || pos == i-len(days) {
+				out = append(out, day)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (r *RRule) monthMatches(day time.Time) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, int(day.Month()))
+}
+
+func (r *RRule) monthDayMatches(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range r.ByMonthDay {
+		if d == day.Day() || (d < 0 && last+d+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdayMatches reports whether day is one of the BYDAY weekdays,
+// ignoring ordinals, which are applied by weekdaysIn.
+func (r *RRule) weekdayMatches(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// dateOf returns the calendar date of t as midnight UTC, so that day
+// arithmetic is not affected by daylight saving transitions.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// weekStart returns the date of the first day of the week containing t.
+func weekStart(t time.Time, wkst time.Weekday) time.Time {
+	d := dateOf(t)
+	back := (int(d.Weekday()) - int(wkst) + 7) % 7
+	return d.AddDate(0, 0, -back)
+}
+
+// daysBetween returns the number of calendar days from a to b.
+func daysBetween(a, b time.Time) int {
+	return int(dateOf(b).Sub(dateOf(a)).Hours() / 24)
+}
@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{"FREQ=DAILY", false},
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR", false},
+		{"FREQ=MONTHLY;BYDAY=-1FR", false},
+		{"FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=25", false},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", false},
+		{"FREQ=WEEKLY;UNTIL=20251231T235959Z", false},
+		{"", true},
+		{"BYDAY=MO", true},
+		{"FREQ=HOURLY", true},
+		{"FREQ=WEEKLY;BYDAY=1MO", true},
+		{"FREQ=DAILY;COUNT=3;UNTIL=20251231", true},
+		{"FREQ=DAILY;INTERVAL=0", true},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", true},
+		{"FREQ=DAILY;BYHOUR=9", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := ParseRRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRRuleCovering(t *testing.T) {
+	syd, _ := time.LoadLocation("Australia/Sydney")
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		dur     time.Duration
+		at      time.Time
+		want    bool
+	}{
+		{
+			name:    "daily within occurrence",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 1, 1, 9, 0, 0, 0, syd),
+			dur:     8 * time.Hour,
+			at:      time.Date(2025, 6, 10, 12, 0, 0, 0, syd),
+			want:    true,
+		},
+		{
+			name:    "daily after occurrence ends",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 1, 1, 9, 0, 0, 0, syd),
+			dur:     8 * time.Hour,
+			at:      time.Date(2025, 6, 10, 17, 0, 0, 0, syd),
+			want:    false,
+		},
+		{
+			name:    "before dtstart",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 1, 1, 9, 0, 0, 0, syd),
+			dur:     8 * time.Hour,
+			at:      time.Date(2024, 12, 31, 10, 0, 0, 0, syd),
+			want:    false,
+		},
+		{
+			name:    "fortnightly on week",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR",
+			dtstart: time.Date(2025, 3, 7, 13, 0, 0, 0, syd), // Friday
+			dur:     4 * time.Hour,
+			at:      time.Date(2025, 3, 21, 14, 0, 0, 0, syd),
+			want:    true,
+		},
+		{
+			name:    "fortnightly off week",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR",
+			dtstart: time.Date(2025, 3, 7, 13, 0, 0, 0, syd),
+			dur:     4 * time.Hour,
+			at:      time.Date(2025, 3, 14, 14, 0, 0, 0, syd),
+			want:    false,
+		},
+		{
+			name:    "last friday of month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: time.Date(2025, 1, 1, 0, 0, 0, 0, syd),
+			dur:     24 * time.Hour,
+			at:      time.Date(2025, 5, 30, 10, 0, 0, 0, syd),
+			want:    true,
+		},
+		{
+			name:    "not last friday of month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: time.Date(2025, 1, 1, 0, 0, 0, 0, syd),
+			dur:     24 * time.Hour,
+			at:      time.Date(2025, 5, 23, 10, 0, 0, 0, syd),
+			want:    false,
+		},
+		{
+			name:    "second monday of june",
+			rule:    "FREQ=YEARLY;BYMONTH=6;BYDAY=2MO",
+			dtstart: time.Date(2020, 6, 8, 0, 0, 0, 0, syd),
+			dur:     24 * time.Hour,
+			at:      time.Date(2026, 6, 8, 15, 0, 0, 0, syd),
+			want:    true,
+		},
+		{
+			name:    "last weekday of month via setpos",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			dtstart: time.Date(2025, 1, 1, 16, 0, 0, 0, syd),
+			dur:     time.Hour,
+			at:      time.Date(2025, 8, 29, 16, 30, 0, 0, syd), // Friday 29 Aug
+			want:    true,
+		},
+		{
+			name:    "yearly on fixed date",
+			rule:    "FREQ=YEARLY",
+			dtstart: time.Date(2020, 12, 25, 0, 0, 0, 0, syd),
+			dur:     24 * time.Hour,
+			at:      time.Date(2030, 12, 25, 23, 59, 0, 0, syd),
+			want:    true,
+		},
+		{
+			name:    "count exhausted",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2025, 1, 1, 9, 0, 0, 0, syd),
+			dur:     time.Hour,
+			at:      time.Date(2025, 1, 4, 9, 30, 0, 0, syd),
+			want:    false,
+		},
+		{
+			name:    "last counted occurrence",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2025, 1, 1, 9, 0, 0, 0, syd),
+			dur:     time.Hour,
+			at:      time.Date(2025, 1, 3, 9, 30, 0, 0, syd),
+			want:    true,
+		},
+		{
+			name:    "past until",
+			rule:    "FREQ=DAILY;UNTIL=20250110",
+			dtstart: time.Date(2025, 1, 1, 9, 0, 0, 0, syd),
+			dur:     time.Hour,
+			at:      time.Date(2025, 1, 11, 9, 30, 0, 0, syd),
+			want:    false,
+		},
+		{
+			name:    "overnight occurrence into next day",
+			rule:    "FREQ=WEEKLY;BYDAY=FR",
+			dtstart: time.Date(2025, 3, 7, 22, 0, 0, 0, syd),
+			dur:     10 * time.Hour,
+			at:      time.Date(2025, 3, 15, 3, 0, 0, 0, syd), // Saturday 03:00
+			want:    true,
+		},
+		{
+			name:    "month without the day is skipped",
+			rule:    "FREQ=MONTHLY",
+			dtstart: time.Date(2025, 1, 31, 9, 0, 0, 0, syd),
+			dur:     time.Hour,
+			at:      time.Date(2025, 3, 31, 9, 30, 0, 0, syd),
+			want:    true,
+		},
+		{
+			name:    "local time kept across daylight saving change",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 3, 1, 9, 0, 0, 0, syd), // AEDT
+			dur:     time.Hour,
+			at:      time.Date(2025, 4, 10, 9, 15, 0, 0, syd), // AEST
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) error: %v", tt.rule, err)
+			}
+			_, got := r.Covering(tt.dtstart, tt.dur, tt.at)
+			if got != tt.want {
+				t.Errorf("Covering() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
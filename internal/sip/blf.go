@@ -0,0 +1,326 @@
+package sip
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// Dialog states reported in dialog-info documents (RFC 4235 section 3.7.1).
+const (
+	dialogStateEarly     = "early"
+	dialogStateConfirmed = "confirmed"
+)
+
+// extensionCall is one call an extension is on, as reported to busy lamp
+// field and presence subscribers.
+type extensionCall struct {
+	// id identifies the call for the subscriber: the Call-ID of the
+	// extension's leg.
+	id string
+
+	// state is dialogStateEarly while ringing, dialogStateConfirmed once
+	// answered.
+	state string
+
+	// initiator is true if the extension placed the call.
+	initiator bool
+
+	// remoteNum and remoteName identify the other party.
+	remoteNum  string
+	remoteName string
+}
+
+// extensionCalls returns the answered calls the extension is on, or for a
+// park orbit the call parked in it.
+func (dm *DialogManager) extensionCalls(extension string) []extensionCall {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+
+	var calls []extensionCall
+	for _, d := range dm.dialogs {
+		for _, callerSide := range []bool{true, false} {
+			// A joined dialog only has its remaining party left.
+			if d.Peer != nil && callerSide != d.peerCallerRemains {
+				continue
+			}
+			leg := d.Callee
+			if callerSide {
+				leg = d.Caller
+			}
+			if leg.Extension == nil || leg.Extension.Extension != extension {
+				continue
+			}
+
+			call := extensionCall{
+				id:        leg.CallID,
+				state:     dialogStateConfirmed,
+				initiator: callerSide,
+			}
+			if d.Peer != nil {
+				call.remoteNum, call.remoteName = d.Peer.partyID(d.Peer.peerCallerRemains)
+			} else {
+				call.remoteNum, call.remoteName = d.partyID(!callerSide)
+			}
+			calls = append(calls, call)
+		}
+
+		// A park orbit is busy while a call waits in it.
+		if p := d.parked.Load(); p != nil && p.orbit == extension {
+			call := extensionCall{
+				id:    d.legSignalling(p.callerSide).callID,
+				state: dialogStateConfirmed,
+			}
+			call.remoteNum, call.remoteName = d.partyID(p.callerSide)
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// partyID returns the number and display name of one side of the dialog.
+// Must be called with the dialog manager's lock held.
+func (d *Dialog) partyID(callerSide bool) (num, name string) {
+	if callerSide {
+		if d.Caller.Extension != nil {
+			return d.Caller.Extension.Extension, d.Caller.Extension.Name
+		}
+		return d.CallerIDNum, d.CallerIDName
+	}
+	if d.Callee.Extension != nil {
+		return d.Callee.Extension.Extension, d.Callee.Extension.Name
+	}
+	return d.CalledNum, ""
+}
+
+// ringingCalls returns the unanswered calls the extension is placing or
+// being rung by. Calls that already have a dialog in answered are
+// skipped.
+func ringingCalls(extension string, pending []*PendingCall, answered []extensionCall) []extensionCall {
+	var calls []extensionCall
+	for _, pc := range pending {
+		if !containsString(pc.Extensions, extension) || pc.CallerReq == nil {
+			continue
+		}
+		if containsCall(answered, pc.CallID) {
+			continue
+		}
+
+		call := extensionCall{
+			id:    pc.CallID,
+			state: dialogStateEarly,
+		}
+		from := pc.CallerReq.From()
+		if from != nil && from.Address.User == extension {
+			call.initiator = true
+			call.remoteNum = pc.CallerReq.Recipient.User
+		} else if from != nil {
+			call.remoteNum, call.remoteName = from.Address.User, from.DisplayName
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+func containsCall(calls []extensionCall, id string) bool {
+	for _, c := range calls {
+		if c.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// callsFor returns all calls an extension is on, answered and ringing.
+func (m *SubscriptionManager) callsFor(extension string) []extensionCall {
+	calls := m.dialogMgr.extensionCalls(extension)
+	return append(calls, ringingCalls(extension, m.pendingMgr.PendingCalls(), calls)...)
+}
+
+// dialogInfo is an RFC 4235 dialog information document.
+type dialogInfo struct {
+	XMLName xml.Name           `xml:"urn:ietf:params:xml:ns:dialog-info dialog-info"`
+	Version int                `xml:"version,attr"`
+	State   string             `xml:"state,attr"`
+	Entity  string             `xml:"entity,attr"`
+	Dialogs []dialogInfoDialog `xml:"dialog"`
+}
+
+type dialogInfoDialog struct {
+	ID        string           `xml:"id,attr"`
+	CallID    string           `xml:"call-id,attr"`
+	Direction string           `xml:"direction,attr"`
+	State     string           `xml:"state"`
+	Remote    *dialogInfoParty `xml:"remote,omitempty"`
+}
+
+type dialogInfoParty struct {
+	Identity dialogInfoIdentity `xml:"identity"`
+}
+
+type dialogInfoIdentity struct {
+	Display string `xml:"display,attr,omitempty"`
+	URI     string `xml:",chardata"`
+}
+
+// dialogInfoBody renders the extension's calls as a full dialog-info
+// document. An idle extension has no dialog elements.
+func (m *SubscriptionManager) dialogInfoBody(extension string, version int) []byte {
+	return buildDialogInfo(extension, m.proxyIP, version, m.callsFor(extension))
+}
+
+// buildDialogInfo renders calls as a full RFC 4235 dialog-info document
+// for the extension.
+func buildDialogInfo(extension, host string, version int, calls []extensionCall) []byte {
+	doc := dialogInfo{
+		Version: version,
+		State:   "full",
+		Entity:  fmt.Sprintf("sip:%s@%s", extension, host),
+	}
+	for _, c := range calls {
+		dlg := dialogInfoDialog{
+			ID:        c.id,
+			CallID:    c.id,
+			Direction: "recipient",
+			State:     c.state,
+		}
+		if c.initiator {
+			dlg.Direction = "initiator"
+		}
+		if c.remoteNum != "" {
+			dlg.Remote = &dialogInfoParty{Identity: dialogInfoIdentity{
+				Display: c.remoteName,
+				URI:     fmt.Sprintf("sip:%s@%s", c.remoteNum, host),
+			}}
+		}
+		doc.Dialogs = append(doc.Dialogs, dlg)
+	}
+
+	out, _ := xml.MarshalIndent(doc, "", "  ")
+	return append([]byte(xml.Header), out...)
+}
+
+// pidfPresence is an RFC 3863 presence document with a single tuple.
+type pidfPresence struct {
+	XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:pidf presence"`
+	Entity  string    `xml:"entity,attr"`
+	Tuple   pidfTuple `xml:"tuple"`
+}
+
+type pidfTuple struct {
+	ID     string `xml:"id,attr"`
+	Status struct {
+		Basic string `xml:"basic"`
+	} `xml:"status"`
+	Note string `xml:"note,omitempty"`
+}
+
+// presenceBody renders the extension's presence: open while it has an
+// active registration, with a note describing its call state.
+func (m *SubscriptionManager) presenceBody(ctx context.Context, extension string) []byte {
+	online, dnd := false, false
+	ext, err := m.extensions.GetByExtension(ctx, extension)
+	if err != nil {
+		m.logger.Error("failed to look up extension for presence",
+			"extension", extension,
+			"error", err,
+		)
+	}
+	if ext != nil {
+		dnd = ext.DND
+		regs, err := m.registrations.GetByExtensionID(ctx, ext.ID)
+		if err != nil {
+			m.logger.Error("failed to look up registrations for presence",
+				"extension", extension,
+				"error", err,
+			)
+		}
+		now := time.Now()
+		for _, reg := range regs {
+			if reg.Expires.After(now) {
+				online = true
+				break
+			}
+		}
+	}
+	return buildPresence(extension, m.proxyIP, online, dnd, m.callsFor(extension))
+}
+
+// buildPresence renders a PIDF document for the extension.
+func buildPresence(extension, host string, online, dnd bool, calls []extensionCall) []byte {
+	doc := pidfPresence{
+		Entity: fmt.Sprintf("sip:%s@%s", extension, host),
+	}
+	doc.Tuple.ID = "ext-" + extension
+	doc.Tuple.Status.Basic = "closed"
+	doc.Tuple.Note = "Offline"
+
+	if online {
+		doc.Tuple.Status.Basic = "open"
+		doc.Tuple.Note = "Available"
+		for _, c := range calls {
+			if c.state == dialogStateConfirmed {
+				doc.Tuple.Note = "On the phone"
+				break
+			}
+			if !c.initiator {
+				doc.Tuple.Note = "Ringing"
+			}
+		}
+		if dnd && doc.Tuple.Note == "Available" {
+			doc.Tuple.Note = "Do not disturb"
+		}
+	}
+
+	out, _ := xml.MarshalIndent(doc, "", "  ")
+	return append([]byte(xml.Header), out...)
+}
+
+// messageSummary renders the voicemail counts of the boxes that notify
+// the extension as an RFC 3842 message-summary body.
+func (m *SubscriptionManager) messageSummary(ctx context.Context, extension string) ([]byte, error) {
+	ext, err := m.extensions.GetByExtension(ctx, extension)
+	if err != nil {
+		return nil, fmt.Errorf("looking up extension: %w", err)
+	}
+	if ext == nil {
+		return []byte(messageSummaryBody(extension, m.proxyIP, 0, 0)), nil
+	}
+
+	boxes, err := m.voicemailBoxes.ListByNotifyExtensionID(ctx, ext.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing voicemail boxes: %w", err)
+	}
+
+	var newMessages, oldMessages int
+	for _, box := range boxes {
+		msgs, err := m.voicemailMessages.ListByMailbox(ctx, box.ID)
+		if err != nil {
+			return nil, fmt.Errorf("listing voicemail messages for box %d: %w", box.ID, err)
+		}
+		for _, msg := range msgs {
+			if msg.Read {
+				oldMessages++
+			} else {
+				newMessages++
+			}
+		}
+	}
+	return []byte(messageSummaryBody(extension, m.proxyIP, newMessages, oldMessages)), nil
+}
+
+// messageSummaryBody builds an RFC 3842 message-summary body.
+func messageSummaryBody(extension, host string, newMessages, oldMessages int) string {
+	waiting := "no"
+	if newMessages > 0 {
+		waiting = "yes"
+	}
+	return fmt.Sprintf("Messages-Waiting: %s\r\nMessage-Account: sip:%s@%s\r\nVoice-Message: %d/%d (%d new, %d old)\r\n",
+		waiting,
+		extension,
+		host,
+		newMessages, oldMessages,
+		newMessages, oldMessages,
+	)
+}
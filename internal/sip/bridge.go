@@ -19,11 +19,23 @@ type MediaBridge struct {
 	logger   *slog.Logger
 	codecPT  int
 	callerSD *media.SessionDescription
+
+	// callerSRTP holds the caller leg's SRTP keys, nil for plain RTP.
+	// calleeMode and calleeKeys are the callee leg's SRTP mode and the
+	// keys offered to it. calleeSRTP is set instead when the callee
+	// sent an offer of its own (see acceptCalleeOffer).
+	callerSRTP    *media.LegSRTP
+	calleeMode    string
+	calleeKeys    []media.CryptoAttribute
+	calleeSRTP    *media.LegSRTP
+	calleeOfferer bool
 }
 
 // AllocateMediaBridge performs phase 1 of media bridging: parses the caller's
 // SDP, allocates an RTP session with two port pairs, and rewrites the caller's
 // SDP so the callee's RTP is directed to the proxy's callee-leg socket.
+// SRTP is terminated on each leg according to policy; an offer the
+// caller's mode does not accept fails with errSRTPNotAcceptable.
 //
 // Returns the MediaBridge (for phase 2) and the rewritten SDP body that
 // should be sent in the forked INVITE to the callee.
@@ -32,6 +44,7 @@ func AllocateMediaBridge(
 	callerSDPBody []byte,
 	callID string,
 	proxyIP string,
+	policy SRTPPolicy,
 	logger *slog.Logger,
 ) (*MediaBridge, []byte, error) {
 	// Parse caller's SDP.
@@ -45,19 +58,29 @@ func AllocateMediaBridge(
 		return nil, nil, fmt.Errorf("caller sdp has no audio media")
 	}
 
+	callerSRTP, err := answerSRTP(callerAudio, policy.Caller)
+	if err != nil {
+		return nil, nil, fmt.Errorf("caller leg: %w", err)
+	}
+
 	// Allocate an RTP session with two port pairs (caller + callee legs).
 	ms, err := media.CreateMediaSession(sessionMgr, callID, callID, logger)
 	if err != nil {
 		return nil, nil, fmt.Errorf("allocating media session: %w", err)
 	}
 
-	// Rewrite caller's SDP: replace IP/port with proxy's callee-leg address.
-	// This SDP will be sent in the forked INVITE so the callee sends its
-	// RTP to the proxy's callee-leg socket.
-	rewrittenForCallee, err := media.RewriteSDPBytes(callerSDPBody, proxyIP, ms.CalleeRTPPort())
+	mb := &MediaBridge{
+		session:    ms,
+		proxyIP:    proxyIP,
+		callID:     callID,
+		logger:     logger,
+		callerSD:   callerSD,
+		callerSRTP: callerSRTP,
+	}
+	rewrittenForCallee, err := mb.CalleeOffer(policy.Callee)
 	if err != nil {
 		ms.Release()
-		return nil, nil, fmt.Errorf("rewriting sdp for callee: %w", err)
+		return nil, nil, err
 	}
 
 	logger.Info("media bridge allocated",
@@ -65,15 +88,52 @@ func AllocateMediaBridge(
 		"proxy_ip", proxyIP,
 		"caller_leg_port", ms.CallerRTPPort(),
 		"callee_leg_port", ms.CalleeRTPPort(),
+		"caller_srtp", callerSRTP != nil,
 	)
 
-	return &MediaBridge{
-		session:  ms,
-		proxyIP:  proxyIP,
-		callID:   callID,
-		logger:   logger,
-		callerSD: callerSD,
-	}, rewrittenForCallee, nil
+	return mb, rewrittenForCallee, nil
+}
+
+// CalleeOffer returns the SDP offer for the callee under SRTP mode, for
+// a callee whose mode differs from the one the bridge was allocated with,
+// e.g. each trunk tried in turn. The most recent offer is the one
+// CompleteMediaBridge expects an answer to.
+func (mb *MediaBridge) CalleeOffer(mode string) ([]byte, error) {
+	// Rewrite caller's SDP: replace IP/port with proxy's callee-leg address.
+	// This SDP will be sent in the forked INVITE so the callee sends its
+	// RTP to the proxy's callee-leg socket. Codecs the caller lacks are
+	// offered too, so a callee without a common codec can still answer
+	// and be transcoded. The caller's keys are replaced by the proxy's.
+	forCallee := media.RewriteSDP(mb.callerSD, mb.proxyIP, mb.session.CalleeRTPPort())
+	offerTranscodableCodecs(forCallee)
+	keys, err := offerSRTP(forCallee.AudioMedia(), mode)
+	if err != nil {
+		return nil, fmt.Errorf("callee leg: %w", err)
+	}
+	mb.calleeMode, mb.calleeKeys = mode, keys
+	return forCallee.Marshal(), nil
+}
+
+// acceptCalleeOffer sets the callee leg's SRTP keys from an offer the
+// callee sent rather than an answer to the bridge's offer, as a device
+// picking up the call does. The bridge's answer to the callee must carry
+// the returned keys (see setAnswerSRTP).
+func (mb *MediaBridge) acceptCalleeOffer(offer *media.MediaDescription, mode string) (*media.LegSRTP, error) {
+	leg, err := answerSRTP(offer, mode)
+	if err != nil {
+		return nil, fmt.Errorf("callee leg: %w", err)
+	}
+	mb.calleeSRTP, mb.calleeOfferer = leg, true
+	return leg, nil
+}
+
+// callerEarlyAnswer returns an SDP answer directing the caller's RTP to
+// the proxy's caller-leg socket before the callee answers, for early
+// media played by the PBX.
+func (mb *MediaBridge) callerEarlyAnswer() []byte {
+	answer := media.RewriteSDP(mb.callerSD, mb.proxyIP, mb.session.CallerRTPPort())
+	setAnswerSRTP(answer.AudioMedia(), mb.callerSD.AudioMedia(), mb.callerSRTP)
+	return answer.Marshal()
 }
 
 // CompleteMediaBridge performs phase 2 of media bridging: parses the callee's
@@ -91,28 +151,45 @@ func (mb *MediaBridge) CompleteMediaBridge(calleeSDPBody []byte) ([]byte, error)
 		return nil, fmt.Errorf("parsing callee sdp: %w", err)
 	}
 
-	// Negotiate a common audio codec.
-	codecPT, codecName, err := negotiateAudioCodec(mb.callerSD, calleeSDP)
+	// Negotiate each leg's audio codec, transcoding if they differ.
+	callerCodec, calleeCodec, err := negotiateBridgeCodecs(mb.callerSD, calleeSDP)
 	if err != nil {
 		mb.Release()
 		return nil, fmt.Errorf("codec negotiation failed: %w", err)
 	}
-	mb.codecPT = codecPT
+	mb.codecPT = calleeCodec.PayloadType
+	transcoding := callerCodec.Codec != calleeCodec.Codec
+
+	calleeSRTP := mb.calleeSRTP
+	if !mb.calleeOfferer {
+		calleeSRTP, err = acceptSRTPAnswer(calleeSDP.AudioMedia(), mb.calleeKeys, mb.calleeMode)
+		if err != nil {
+			mb.Release()
+			return nil, fmt.Errorf("callee leg: %w", err)
+		}
+	}
 
 	mb.logger.Info("codec negotiated",
 		"call_id", mb.callID,
-		"codec", codecName,
-		"payload_type", codecPT,
+		"caller_codec", callerCodec.String(),
+		"callee_codec", calleeCodec.String(),
+		"transcoding", transcoding,
 	)
 
 	// Rewrite callee's SDP: replace IP/port with proxy's caller-leg address.
 	// This SDP will be sent in the 200 OK to the caller so it sends RTP
-	// to the proxy's caller-leg socket.
-	rewrittenForCaller, err := media.RewriteSDPBytes(calleeSDPBody, mb.proxyIP, mb.session.CallerRTPPort())
-	if err != nil {
-		mb.Release()
-		return nil, fmt.Errorf("rewriting sdp for caller: %w", err)
+	// to the proxy's caller-leg socket. When transcoding, the answer
+	// names the caller's codec instead of the callee's, and it always
+	// carries the proxy's key for the caller leg, not the callee's.
+	forCaller := media.RewriteSDP(calleeSDP, mb.proxyIP, mb.session.CallerRTPPort())
+	if transcoding {
+		if err := answerWithCodec(forCaller, sdpCodec(mb.callerSD.AudioMedia(), callerCodec)); err != nil {
+			mb.Release()
+			return nil, fmt.Errorf("rewriting sdp for caller: %w", err)
+		}
 	}
+	setAnswerSRTP(forCaller.AudioMedia(), mb.callerSD.AudioMedia(), mb.callerSRTP)
+	rewrittenForCaller := forCaller.Marshal()
 
 	// Extract far-end RTP addresses from the original (pre-rewrite) SDPs.
 	callerRemote, err := extractRTPAddr(mb.callerSD)
@@ -128,10 +205,21 @@ func (mb *MediaBridge) CompleteMediaBridge(calleeSDPBody []byte) ([]byte, error)
 	}
 
 	// Allowed payload types: the negotiated audio codec + DTMF.
-	allowedPTs := []int{codecPT, media.PayloadTelephoneEvent}
+	allowedPTs := []int{calleeCodec.PayloadType, media.PayloadTelephoneEvent}
 
-	// Start the bidirectional RTP relay.
-	if err := mb.session.StartRelay(callerRemote, calleeRemote, allowedPTs); err != nil {
+	if err := mb.session.SetSRTP(mb.callerSRTP, calleeSRTP); err != nil {
+		mb.Release()
+		return nil, fmt.Errorf("setting srtp keys: %w", err)
+	}
+
+	// Start the bidirectional RTP relay. Codecs the media layer knows are
+	// passed on, so it can transcode or renumber payload types.
+	if callerCodec.Codec != nil && calleeCodec.Codec != nil {
+		err = mb.session.StartTranscodingRelay(callerRemote, calleeRemote, callerCodec, calleeCodec, allowedPTs)
+	} else {
+		err = mb.session.StartRelay(callerRemote, calleeRemote, allowedPTs)
+	}
+	if err != nil {
 		mb.Release()
 		return nil, fmt.Errorf("starting rtp relay: %w", err)
 	}
@@ -140,7 +228,9 @@ func (mb *MediaBridge) CompleteMediaBridge(calleeSDPBody []byte) ([]byte, error)
 		"call_id", mb.callID,
 		"caller_remote", callerRemote.String(),
 		"callee_remote", calleeRemote.String(),
-		"codec", codecName,
+		"transcoding", transcoding,
+		"caller_srtp", mb.callerSRTP != nil,
+		"callee_srtp", calleeSRTP != nil,
 	)
 
 	return rewrittenForCaller, nil
@@ -212,6 +302,8 @@ func staticPTName(pt int) string {
 		return "PCMU"
 	case media.PayloadPCMA:
 		return "PCMA"
+	case media.PayloadG722:
+		return "G722"
 	default:
 		return ""
 	}
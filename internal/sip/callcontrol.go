@@ -0,0 +1,125 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// callControlRingTimeout bounds how long a destination rings for a call
+// control request from the API. It is kept under the HTTP server's write
+// timeout so the request can report the outcome.
+const callControlRingTimeout = 20 * time.Second
+
+var (
+	// ErrCallNotFound is returned by call control when no active call has
+	// the given Call-ID.
+	ErrCallNotFound = errors.New("call not found")
+
+	// ErrCallUncontrollable is returned when a call's state does not allow
+	// the requested action, e.g. a call joined by an attended transfer or
+	// one with a transfer already in progress.
+	ErrCallUncontrollable = errors.New("call cannot be controlled in its current state")
+)
+
+// HangupCall hangs up a call from the PBX side, sending BYE to each leg of
+// an answered call. Calls joined by an attended transfer are hung up
+// together. A call still ringing is cancelled as if its caller had hung
+// up.
+func (s *Server) HangupCall(callID string) error {
+	d := s.dialogMgr.GetDialog(callID)
+	if d == nil {
+		if s.pendingMgr.Cancel(callID, s.logger) {
+			s.logger.Info("ringing call cancelled",
+				"call_id", callID,
+			)
+			s.finalizeCancelledCDR(callID)
+			return nil
+		}
+		return ErrCallNotFound
+	}
+
+	s.logger.Info("call hangup requested",
+		"call_id", callID,
+	)
+
+	if d.Peer != nil {
+		byeReq := d.leg(d.peerCallerRemains).newRequest(sip.BYE)
+		if err := s.forker.Client().WriteRequest(byeReq); err != nil {
+			s.logger.Error("failed to send bye to joined call",
+				"call_id", callID,
+				"error", err,
+			)
+		}
+		s.hangupJoinedCall(d, "api_hangup")
+		return nil
+	}
+
+	// Stop a flow still talking to the caller, e.g. a voicemail menu.
+	if s.flowActions != nil {
+		s.flowActions.abortEarlyMedia(d.CallID)
+	}
+	s.sendBYEToCaller(d)
+	if d.hasCallee() {
+		s.sendBYEToCallee(d)
+	}
+	s.endDialog(d, "api_hangup")
+	return nil
+}
+
+// TransferCall blindly transfers an answered call to an extension, number
+// or SIP URI. The destination replaces the callee leg, or the caller leg
+// when replaceCaller is set, and the replaced party is hung up once the
+// destination answers. If it does not, the call carries on unchanged.
+func (s *Server) TransferCall(ctx context.Context, callID, destination string, replaceCaller bool) error {
+	d := s.dialogMgr.GetDialog(callID)
+	if d == nil {
+		return ErrCallNotFound
+	}
+	if d.Peer != nil || d.Direction == CallTypeSupervise || (replaceCaller && !d.hasCallee()) {
+		return ErrCallUncontrollable
+	}
+	// Only the vacant side of a parked call can be filled by a transfer.
+	if d.parked.Load() != nil && !d.vacant(replaceCaller) {
+		return ErrCallUncontrollable
+	}
+
+	target, err := s.flowActions.resolveTransferTarget(ctx, destination, nil)
+	if err != nil {
+		return fmt.Errorf("resolving transfer destination: %w", err)
+	}
+
+	transferCtx, cancel := context.WithTimeout(ctx, callControlRingTimeout)
+	defer cancel()
+	return s.flowActions.transferDialog(transferCtx, d, replaceCaller, target)
+}
+
+// SuperviseCall joins a supervisor's extension to an answered call in the
+// given mode, ringing the supervisor's phones. A supervisor already on the
+// call has its mode changed instead.
+func (s *Server) SuperviseCall(ctx context.Context, callID, extension string, mode SuperviseMode) error {
+	return s.flowActions.superviseCall(ctx, callID, extension, mode)
+}
+
+// transferDialog replaces one side of an answered call with target and,
+// once it has answered, hangs up the party it replaced. A call the PBX
+// answered itself has no callee leg to hang up.
+func (a *FlowSIPActions) transferDialog(ctx context.Context, d *Dialog, replaceCaller bool, target *transferTarget) error {
+	if !d.transferring.CompareAndSwap(false, true) {
+		return fmt.Errorf("%w: call %s already has a transfer in progress", ErrCallUncontrollable, d.CallID)
+	}
+	defer d.transferring.Store(false)
+
+	hadLeg := (replaceCaller || d.hasCallee()) && !d.vacant(replaceCaller)
+	oldLeg := d.leg(replaceCaller)
+	if err := a.transferLeg(ctx, d, replaceCaller, target); err != nil {
+		return err
+	}
+	if hadLeg {
+		a.sendLegBYE(oldLeg, d.CallID)
+	}
+	return nil
+}
@@ -3,10 +3,15 @@ package sip
 import (
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/emiago/sipgo/sip"
 )
 
+// pickedUpTTL is how long a call answered by call pickup is remembered, so
+// the goroutine that was forking it can tell it apart from a cancelled call.
+const pickedUpTTL = time.Minute
+
 // PendingCall represents a call that is ringing but not yet answered.
 // It holds the cancel function to abort all fork legs and the caller's
 // server transaction so we can send 487 Request Terminated.
@@ -15,9 +20,12 @@ type PendingCall struct {
 	CallID string
 
 	// CallerTx is the original INVITE server transaction from the caller.
+	// It is nil for a call the PBX placed itself, such as an originated
+	// call ringing the extension it was placed for.
 	CallerTx sip.ServerTransaction
 
-	// CallerReq is the original INVITE request from the caller.
+	// CallerReq is the original INVITE request from the caller, or one the
+	// PBX built to describe a call it placed itself.
 	CallerReq *sip.Request
 
 	// CancelFork cancels the fork context, causing all outbound INVITE
@@ -27,22 +35,67 @@ type PendingCall struct {
 	// Bridge holds the allocated media bridge (may be nil). Released
 	// if the call is cancelled before answer.
 	Bridge *MediaBridge
+
+	// Extensions lists the local extensions involved in the call: the
+	// calling extension and those being rung. Used for busy lamp fields
+	// and call pickup.
+	Extensions []string
+
+	// ringingSince is when the call was added.
+	ringingSince time.Time
+}
+
+// ringing reports whether the call is ringing the given extension, as
+// opposed to being placed by it.
+func (pc *PendingCall) ringing(extension string) bool {
+	if pc.CallerReq != nil {
+		if from := pc.CallerReq.From(); from != nil && from.Address.User == extension {
+			return false
+		}
+	}
+	for _, ext := range pc.Extensions {
+		if ext == extension {
+			return true
+		}
+	}
+	return false
 }
 
 // PendingCallManager tracks calls that are in the ringing/forking state
 // (between INVITE receipt and answer or failure). This allows the CANCEL
 // handler to find and abort pending calls.
 type PendingCallManager struct {
-	mu      sync.RWMutex
-	pending map[string]*PendingCall // keyed by Call-ID
-	logger  *slog.Logger
+	mu       sync.RWMutex
+	pending  map[string]*PendingCall // keyed by Call-ID
+	pickedUp map[string]time.Time    // Call-ID -> pickup time
+	logger   *slog.Logger
+
+	// lastCaller maps each extension to the number of the last caller
+	// that rang it, for the block-last-caller feature code.
+	lastCaller map[string]string
+
+	// onChange, if set, is called with the extensions of a call that
+	// starts or stops ringing.
+	onChange ExtensionStateListener
+
+	// onRinging, if set, is called with a call that starts or stops
+	// ringing.
+	onRinging RingingListener
 }
 
+// RingingListener is called with a pending call when it starts ringing
+// (ringing is true) and when it stops, whether because it was answered,
+// picked up, cancelled, or failed. It is called with the manager's lock
+// held and must not call back into the manager.
+type RingingListener func(pc *PendingCall, ringing bool)
+
 // NewPendingCallManager creates a new pending call tracker.
 func NewPendingCallManager(logger *slog.Logger) *PendingCallManager {
 	return &PendingCallManager{
-		pending: make(map[string]*PendingCall),
-		logger:  logger.With("subsystem", "pending-calls"),
+		pending:    make(map[string]*PendingCall),
+		pickedUp:   make(map[string]time.Time),
+		logger:     logger.With("subsystem", "pending-calls"),
+		lastCaller: make(map[string]string),
 	}
 }
 
@@ -51,10 +104,45 @@ func (pm *PendingCallManager) Add(pc *PendingCall) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
+	if pc.ringingSince.IsZero() {
+		pc.ringingSince = time.Now()
+	}
 	pm.pending[pc.CallID] = pc
 	pm.logger.Debug("pending call added",
 		"call_id", pc.CallID,
 	)
+	if pc.CallerReq != nil {
+		if from := pc.CallerReq.From(); from != nil {
+			for _, ext := range pc.Extensions {
+				if pc.ringing(ext) {
+					pm.lastCaller[ext] = from.Address.User
+				}
+			}
+		}
+	}
+	pm.notifyChange(pc)
+	if pm.onRinging != nil {
+		pm.onRinging(pc, true)
+	}
+}
+
+// OnExtensionStateChange registers a listener for extensions whose calls
+// start or stop ringing. Must be called before calls are handled.
+func (pm *PendingCallManager) OnExtensionStateChange(fn ExtensionStateListener) {
+	pm.onChange = fn
+}
+
+// OnRingingChange registers a listener for calls that start or stop
+// ringing. Must be called before calls are handled.
+func (pm *PendingCallManager) OnRingingChange(fn RingingListener) {
+	pm.onRinging = fn
+}
+
+// notifyChange reports a pending call's extensions to the listener.
+func (pm *PendingCallManager) notifyChange(pc *PendingCall) {
+	if pm.onChange != nil && len(pc.Extensions) > 0 {
+		pm.onChange(pc.Extensions)
+	}
 }
 
 // Remove removes a pending call. Called when the call is answered or all
@@ -71,9 +159,21 @@ func (pm *PendingCallManager) Remove(callID string) *PendingCall {
 	pm.logger.Debug("pending call removed",
 		"call_id", callID,
 	)
+	pm.notifyChange(pc)
+	if pm.onRinging != nil {
+		pm.onRinging(pc, false)
+	}
 	return pc
 }
 
+// LastCaller returns the number of the last caller that rang extension,
+// or "" if none has since the PBX started.
+func (pm *PendingCallManager) LastCaller(extension string) string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.lastCaller[extension]
+}
+
 // Get retrieves a pending call by Call-ID without removing it.
 func (pm *PendingCallManager) Get(callID string) *PendingCall {
 	pm.mu.RLock()
@@ -81,6 +181,69 @@ func (pm *PendingCallManager) Get(callID string) *PendingCall {
 	return pm.pending[callID]
 }
 
+// RingingCall returns the longest-ringing call that is ringing any of the
+// given extensions, or nil if there is none. The call stays pending; use
+// Pickup to take it over.
+func (pm *PendingCallManager) RingingCall(extensions []string) *PendingCall {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	var oldest *PendingCall
+	for _, pc := range pm.pending {
+		if oldest != nil && !pc.ringingSince.Before(oldest.ringingSince) {
+			continue
+		}
+		for _, ext := range extensions {
+			if pc.ringing(ext) {
+				oldest = pc
+				break
+			}
+		}
+	}
+	return oldest
+}
+
+// Pickup removes a pending call so that a device other than those being
+// rung can answer it. The caller must cancel the fork and answer the
+// call; the goroutine that was forking it sees PickedUp return true.
+// Returns nil if the call is no longer pending.
+func (pm *PendingCallManager) Pickup(callID string) *PendingCall {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pc, ok := pm.pending[callID]
+	if !ok {
+		return nil
+	}
+	delete(pm.pending, callID)
+
+	now := time.Now()
+	for id, at := range pm.pickedUp {
+		if now.Sub(at) > pickedUpTTL {
+			delete(pm.pickedUp, id)
+		}
+	}
+	pm.pickedUp[callID] = now
+
+	pm.logger.Debug("pending call picked up",
+		"call_id", callID,
+	)
+	pm.notifyChange(pc)
+	if pm.onRinging != nil {
+		pm.onRinging(pc, false)
+	}
+	return pc
+}
+
+// PickedUp reports whether a call that is no longer pending was taken
+// over by Pickup rather than cancelled.
+func (pm *PendingCallManager) PickedUp(callID string) bool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	_, ok := pm.pickedUp[callID]
+	return ok
+}
+
 // PendingCalls returns a snapshot of all currently pending (ringing) calls.
 // The returned slice is a copy safe for iteration without holding the lock.
 func (pm *PendingCallManager) PendingCalls() []*PendingCall {
@@ -122,6 +285,10 @@ func (pm *PendingCallManager) Cancel(callID string, logger *slog.Logger) bool {
 		)
 	}
 
+	if pc.CallerTx == nil {
+		return true
+	}
+
 	// Send 487 Request Terminated to the caller's original INVITE transaction.
 	terminatedRes := sip.NewResponseFromRequest(pc.CallerReq, 487, "Request Terminated", nil)
 	if err := pc.CallerTx.Respond(terminatedRes); err != nil {
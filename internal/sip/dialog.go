@@ -3,6 +3,7 @@ package sip
 import (
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/emiago/sipgo/sip"
@@ -38,6 +39,11 @@ type CallLeg struct {
 
 	// RemoteTarget is the SIP URI to send in-dialog requests (BYE) to.
 	RemoteTarget *sip.Uri
+
+	// CallID is the SIP Call-ID of this leg. Legs forked to extensions and
+	// legs created by a transfer use their own Call-ID; trunk legs share
+	// the caller's.
+	CallID string
 }
 
 // Dialog represents an active call session between two parties.
@@ -90,6 +96,57 @@ type Dialog struct {
 	// dialog parameters (To tag, Contact) needed for BYE.
 	CalleeRes *sip.Response
 
+	// CallerOutReq and CallerOutRes are set when the caller leg has been
+	// replaced by a leg the PBX originated (the target of a transfer made
+	// by the original caller). In-dialog requests to the caller are then
+	// built from this INVITE/200 OK pair instead of CallerReq.
+	CallerOutReq *sip.Request
+	CallerOutRes *sip.Response
+
+	// CalleeInReq is set when the callee leg is a device that called the
+	// PBX rather than one the PBX rang: an extension that picked up the
+	// call. The PBX answered this INVITE, so in-dialog requests to the
+	// callee are built from it the way they are for the original caller.
+	CalleeInReq *sip.Request
+
+	// TransferredTo is the destination the call was last transferred to,
+	// recorded in the CDR.
+	TransferredTo string
+
+	// Peer is the call this dialog was joined to by an attended transfer.
+	// The transferor's legs on both calls are gone; the remaining party
+	// here is bridged to the remaining party on Peer, and a hangup on one
+	// is relayed to the other.
+	Peer *Dialog
+
+	// peerCallerRemains reports whether the remaining party of a joined
+	// dialog is its caller leg. Only meaningful when Peer is set.
+	peerCallerRemains bool
+
+	// callerSeq and calleeSeq number the in-dialog requests (BYE, NOTIFY)
+	// the PBX sends on each leg. They are replaced when a leg is.
+	callerSeq *atomic.Uint32
+	calleeSeq *atomic.Uint32
+
+	// transferring is set while a REFER on this dialog is in progress.
+	transferring atomic.Bool
+
+	// parked is set while one party of the call waits in a park orbit
+	// and the other side of the dialog is vacant. Stored by the dialog
+	// manager when the call is parked, retrieved or returned.
+	parked atomic.Pointer[parkedCall]
+
+	// mediaMu guards callerMedia and calleeMedia, the per-leg media state
+	// updated by re-INVITEs (hold, resume, address changes).
+	mediaMu     sync.Mutex
+	callerMedia legMedia
+	calleeMedia legMedia
+
+	// supervision is the supervisor session this dialog takes part in,
+	// as the supervised call or as the supervisor's own call. Guarded by
+	// mediaMu.
+	supervision *supervision
+
 	// StartTime is when the INVITE was received.
 	StartTime time.Time
 
@@ -146,22 +203,258 @@ func (d *Dialog) Disposition() string {
 	}
 }
 
+// isCallerLeg reports whether a Call-ID and remote party tag (the From tag
+// of a request, or the from-tag of a Replaces header) identify the caller
+// leg. When both legs share a Call-ID (trunk legs), the tag decides.
+func (d *Dialog) isCallerLeg(callID, remoteTag string) bool {
+	if d.Caller.CallID != "" && d.Caller.CallID != d.Callee.CallID {
+		return callID == d.Caller.CallID
+	}
+	return remoteTag == d.callerRemoteTag() || remoteTag == ""
+}
+
+// hasLeg reports whether one of the dialog's legs has the given Call-ID
+// and remote tag.
+func (d *Dialog) hasLeg(callID, remoteTag string) bool {
+	for _, side := range []bool{true, false} {
+		l := d.legSignalling(side)
+		if l.callID == callID && l.remoteTag == remoteTag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCallee reports whether the dialog has a callee leg. A call the PBX
+// answered itself (e.g. voicemail) has only the caller leg.
+func (d *Dialog) hasCallee() bool {
+	return d.CalleeReq != nil || d.CalleeInReq != nil
+}
+
+// callerInvite returns the INVITE that set up the caller side: the
+// caller's own, or the one the PBX sent when it placed the caller leg
+// itself, as for a supervisor or an originated call.
+func (d *Dialog) callerInvite() *sip.Request {
+	if d.CallerReq != nil {
+		return d.CallerReq
+	}
+	return d.CallerOutReq
+}
+
+// IsCallerRequest reports whether an in-dialog request was sent by the
+// caller leg rather than the callee leg.
+func (d *Dialog) IsCallerRequest(req *sip.Request) bool {
+	callID, fromTag := requestDialogID(req)
+	return d.isCallerLeg(callID, fromTag)
+}
+
+// callerRemoteTag returns the caller device's dialog tag.
+func (d *Dialog) callerRemoteTag() string {
+	if d.CallerOutReq != nil {
+		return d.Caller.ToTag
+	}
+	return d.Caller.FromTag
+}
+
+// leg returns the signalling state of one side of the dialog.
+func (d *Dialog) leg(callerSide bool) dialogLeg {
+	l := d.legSignalling(callerSide)
+	d.mediaMu.Lock()
+	l.sdp = d.legMedia(callerSide).sdp
+	d.mediaMu.Unlock()
+	return l
+}
+
+// legSignalling returns the dialog identifiers and INVITE state of one side.
+func (d *Dialog) legSignalling(callerSide bool) dialogLeg {
+	if callerSide {
+		if d.callerSeq == nil {
+			d.callerSeq = new(atomic.Uint32)
+		}
+		if d.CallerOutReq != nil {
+			return dialogLeg{
+				callID:    d.Caller.CallID,
+				remoteTag: d.Caller.ToTag,
+				req:       d.CallerOutReq,
+				res:       d.CallerOutRes,
+				target:    d.Caller.RemoteTarget,
+				seq:       d.callerSeq,
+			}
+		}
+		return dialogLeg{
+			callID:    d.Caller.CallID,
+			remoteTag: d.Caller.FromTag,
+			uas:       true,
+			req:       d.CallerReq,
+			localTag:  d.Caller.ToTag,
+			seq:       d.callerSeq,
+		}
+	}
+
+	if d.calleeSeq == nil {
+		d.calleeSeq = new(atomic.Uint32)
+	}
+	if d.CalleeInReq != nil {
+		return dialogLeg{
+			callID:    d.Callee.CallID,
+			remoteTag: d.Callee.FromTag,
+			uas:       true,
+			req:       d.CalleeInReq,
+			localTag:  d.Callee.ToTag,
+			seq:       d.calleeSeq,
+		}
+	}
+	return dialogLeg{
+		callID:    d.Callee.CallID,
+		remoteTag: d.Callee.ToTag,
+		req:       d.CalleeReq,
+		res:       d.CalleeRes,
+		target:    d.Callee.RemoteTarget,
+		seq:       d.calleeSeq,
+	}
+}
+
+// dialogLeg holds what the PBX needs to send in-dialog requests on one leg
+// of a call. A snapshot stays usable after the leg has been replaced, e.g.
+// to send the final NOTIFY and BYE to a transferor.
+type dialogLeg struct {
+	callID    string
+	remoteTag string
+
+	// uas is true when the PBX answered this leg's INVITE (the original
+	// caller). Requests then swap From/To relative to req.
+	uas bool
+
+	// req is the INVITE received (uas) or sent (uac) on this leg.
+	req *sip.Request
+
+	// res is the 2xx the PBX received (uac only).
+	res *sip.Response
+
+	// target is the remote target from the 2xx Contact (uac only).
+	target *sip.Uri
+
+	// localTag is the To tag the PBX answered with (uas only).
+	localTag string
+
+	// sdp is the leg's latest session description from a re-INVITE, if
+	// it has sent one since the call was set up.
+	sdp []byte
+
+	seq *atomic.Uint32
+}
+
+// newRequest builds an in-dialog request on the leg with the next CSeq.
+func (l dialogLeg) newRequest(method sip.RequestMethod) *sip.Request {
+	if l.uas {
+		return buildReverseDialogRequest(method, l.req, l.localTag, l.seq.Add(1))
+	}
+	base := uint32(1)
+	if cseq := l.req.CSeq(); cseq != nil {
+		base = cseq.SeqNo
+	}
+	return buildInDialogRequest(method, l.req, l.res, l.target, base+l.seq.Add(1))
+}
+
+// remoteSDP returns the session description the leg's device sent: its
+// offer for a leg the PBX answered, or its answer otherwise.
+func (l dialogLeg) remoteSDP() []byte {
+	if l.sdp != nil {
+		return l.sdp
+	}
+	if l.uas {
+		return l.req.Body()
+	}
+	if l.res == nil {
+		return nil
+	}
+	return l.res.Body()
+}
+
+// requestDialogID returns the Call-ID and From tag of a request.
+func requestDialogID(req *sip.Request) (callID, fromTag string) {
+	if cid := req.CallID(); cid != nil {
+		callID = cid.Value()
+	}
+	if from := req.From(); from != nil {
+		fromTag, _ = from.Params.Get("tag")
+	}
+	return callID, fromTag
+}
+
+// retiredLegTTL is how long a leg removed from a dialog by a transfer is
+// remembered, so a BYE it sends afterwards is answered 200 OK rather than
+// tearing down the call or getting 481.
+const retiredLegTTL = time.Minute
+
 // DialogManager tracks all active call dialogs in memory.
 // It provides thread-safe access for concurrent SIP request processing.
 type DialogManager struct {
 	mu      sync.RWMutex
-	dialogs map[string]*Dialog // keyed by Call-ID
+	dialogs map[string]*Dialog   // keyed by Call-ID
+	legs    map[string]string    // leg Call-ID -> dialog Call-ID, for legs with their own Call-ID
+	retired map[string]time.Time // legKey -> retirement time
 	logger  *slog.Logger
+
+	// onChange, if set, is called with the extensions of a call that is
+	// answered, ends, or changes parties.
+	onChange ExtensionStateListener
+
+	// onCallState, if set, is called with a dialog that is created or
+	// terminated.
+	onCallState CallStateListener
+}
+
+// CallStateListener is called with a dialog when it is created on answer
+// or terminated; d.State tells which. It is called with the dialog
+// manager's lock held and must not call back into the manager.
+type CallStateListener func(d *Dialog)
+
+// OnCallStateChange registers a listener for calls that are answered or
+// end. Must be called before calls are handled.
+func (dm *DialogManager) OnCallStateChange(fn CallStateListener) {
+	dm.onCallState = fn
+}
+
+// OnExtensionStateChange registers a listener for extensions whose calls
+// are answered, end, or change parties. Must be called before calls are
+// handled.
+func (dm *DialogManager) OnExtensionStateChange(fn ExtensionStateListener) {
+	dm.onChange = fn
+}
+
+// notifyChange reports the extensions on the given dialogs to the listener.
+func (dm *DialogManager) notifyChange(dialogs ...*Dialog) {
+	if dm.onChange == nil {
+		return
+	}
+	var exts []string
+	for _, d := range dialogs {
+		exts = append(exts, extensionNumbers(d.Caller.Extension, d.Callee.Extension)...)
+		if p := d.parked.Load(); p != nil {
+			exts = append(exts, p.orbit)
+		}
+	}
+	if len(exts) > 0 {
+		dm.onChange(exts)
+	}
 }
 
 // NewDialogManager creates a new in-memory dialog tracker.
 func NewDialogManager(logger *slog.Logger) *DialogManager {
 	return &DialogManager{
 		dialogs: make(map[string]*Dialog),
+		legs:    make(map[string]string),
+		retired: make(map[string]time.Time),
 		logger:  logger.With("subsystem", "dialog"),
 	}
 }
 
+// legKey identifies a dialog leg by Call-ID and the remote party's tag.
+func legKey(callID, remoteTag string) string {
+	return callID + ";" + remoteTag
+}
+
 // CreateDialog registers a new call dialog when an INVITE is answered.
 // The dialog is stored with state CallStateAnswered.
 func (dm *DialogManager) CreateDialog(d *Dialog) {
@@ -172,13 +465,37 @@ func (dm *DialogManager) CreateDialog(d *Dialog) {
 	d.AnswerTime = &now
 	d.State = CallStateAnswered
 
+	if d.Caller.CallID == "" {
+		d.Caller.CallID = d.CallID
+	}
+	if d.Callee.CallID == "" {
+		d.Callee.CallID = d.CallID
+		if d.CalleeReq != nil {
+			if cid := d.CalleeReq.CallID(); cid != nil {
+				d.Callee.CallID = cid.Value()
+			}
+		}
+	}
+	d.callerSeq = new(atomic.Uint32)
+	d.calleeSeq = new(atomic.Uint32)
+
 	dm.dialogs[d.CallID] = d
+	if d.Caller.CallID != d.CallID {
+		dm.legs[d.Caller.CallID] = d.CallID
+	}
+	if d.Callee.CallID != d.CallID {
+		dm.legs[d.Callee.CallID] = d.CallID
+	}
 	dm.logger.Info("dialog created",
 		"call_id", d.CallID,
 		"direction", d.Direction,
 		"caller", d.CallerIDNum,
 		"callee", d.CalledNum,
 	)
+	dm.notifyChange(d)
+	if dm.onCallState != nil {
+		dm.onCallState(d)
+	}
 }
 
 // GetDialog retrieves an active dialog by Call-ID.
@@ -189,6 +506,120 @@ func (dm *DialogManager) GetDialog(callID string) *Dialog {
 	return dm.dialogs[callID]
 }
 
+// FindDialog retrieves the active dialog that an in-dialog request with
+// the given Call-ID and From tag belongs to, by the Call-ID of either leg.
+// A local flow call has the same Call-ID at both ends, as a leg of the
+// call it was dialled for and as the flow's own dialog; the From tag tells
+// them apart. Returns nil if no dialog has a leg with the given Call-ID.
+func (dm *DialogManager) FindDialog(callID, fromTag string) *Dialog {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	d := dm.dialogs[callID]
+	if primary, ok := dm.legs[callID]; ok {
+		if p := dm.dialogs[primary]; p != nil && (d == nil || p.hasLeg(callID, fromTag)) {
+			return p
+		}
+	}
+	return d
+}
+
+// IsRetiredLeg reports whether a Call-ID and remote tag belong to a leg
+// that was removed from its dialog by a transfer.
+func (dm *DialogManager) IsRetiredLeg(callID, remoteTag string) bool {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	_, ok := dm.retired[legKey(callID, remoteTag)]
+	return ok
+}
+
+// retireLeg remembers a leg that has left its dialog and forgets legs
+// retired more than retiredLegTTL ago. Must be called with dm.mu held.
+func (dm *DialogManager) retireLeg(d *Dialog, leg dialogLeg) {
+	now := time.Now()
+	for key, at := range dm.retired {
+		if now.Sub(at) > retiredLegTTL {
+			delete(dm.retired, key)
+		}
+	}
+	dm.retired[legKey(leg.callID, leg.remoteTag)] = now
+	if leg.callID != d.CallID {
+		delete(dm.legs, leg.callID)
+	}
+}
+
+// ReplaceLeg swaps one side of an active dialog for a leg the PBX
+// originated, e.g. the answering target of a blind transfer. req and res
+// are the INVITE sent to the new device and its 200 OK. The previous leg is
+// retired. Returns false if the dialog is no longer active.
+func (dm *DialogManager) ReplaceLeg(d *Dialog, callerSide bool, leg CallLeg, req *sip.Request, res *sip.Response) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if dm.dialogs[d.CallID] != d {
+		return false
+	}
+
+	dm.retireLeg(d, d.leg(callerSide))
+	d.resetLegMedia(callerSide)
+	d.endSupervision()
+	dm.notifyChange(d)
+	d.parked.Store(nil)
+
+	if callerSide {
+		d.Caller = leg
+		d.CallerOutReq = req
+		d.CallerOutRes = res
+		d.callerSeq = new(atomic.Uint32)
+	} else {
+		d.Callee = leg
+		d.CalleeTx = nil
+		d.CalleeInReq = nil
+		d.CalleeReq = req
+		d.CalleeRes = res
+		d.calleeSeq = new(atomic.Uint32)
+	}
+	if leg.CallID != d.CallID {
+		dm.legs[leg.CallID] = d.CallID
+	}
+
+	dm.logger.Info("dialog leg replaced",
+		"call_id", d.CallID,
+		"caller_side", callerSide,
+		"leg_call_id", leg.CallID,
+	)
+	dm.notifyChange(d)
+	return true
+}
+
+// JoinDialogs links two active dialogs after an attended transfer. The
+// transferor's leg on each (the side opposite the remaining party) is
+// retired. Returns false if either dialog is no longer active.
+func (dm *DialogManager) JoinDialogs(a *Dialog, aCallerRemains bool, b *Dialog, bCallerRemains bool) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if dm.dialogs[a.CallID] != a || dm.dialogs[b.CallID] != b {
+		return false
+	}
+
+	dm.retireLeg(a, a.leg(!aCallerRemains))
+	dm.retireLeg(b, b.leg(!bCallerRemains))
+	a.stopHoldMusic()
+	b.stopHoldMusic()
+	a.endSupervision()
+	b.endSupervision()
+
+	a.Peer, a.peerCallerRemains = b, aCallerRemains
+	b.Peer, b.peerCallerRemains = a, bCallerRemains
+	dm.notifyChange(a, b)
+
+	dm.logger.Info("dialogs joined",
+		"call_id", a.CallID,
+		"peer_call_id", b.CallID,
+	)
+	return true
+}
+
 // TerminateDialog marks a dialog as terminated and removes it from the
 // active map. Returns the terminated dialog for CDR generation, or nil
 // if no dialog was found.
@@ -205,8 +636,12 @@ func (dm *DialogManager) TerminateDialog(callID string, hangupCause string) *Dia
 	d.EndTime = &now
 	d.State = CallStateTerminated
 	d.HangupCause = hangupCause
+	d.stopHoldMusic()
+	d.endSupervision()
 
 	delete(dm.dialogs, callID)
+	delete(dm.legs, d.Caller.CallID)
+	delete(dm.legs, d.Callee.CallID)
 	dm.logger.Info("dialog terminated",
 		"call_id", d.CallID,
 		"direction", d.Direction,
@@ -214,6 +649,11 @@ func (dm *DialogManager) TerminateDialog(callID string, hangupCause string) *Dia
 		"duration_ms", d.Duration().Milliseconds(),
 		"billable_ms", d.BillableDuration().Milliseconds(),
 	)
+	dm.notifyChange(d)
+	d.parked.Store(nil)
+	if dm.onCallState != nil {
+		dm.onCallState(d)
+	}
 
 	return d
 }
@@ -0,0 +1,518 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// earlyMediaKeepAlive is how often an early media session is marked active
+// so the session reaper does not release it while the caller is on hold.
+const earlyMediaKeepAlive = 15 * time.Second
+
+// earlyMedia is a caller-facing RTP stream established with a 183 Session
+// Progress before the call is answered. It is used to play hold music and
+// announcements (e.g. while waiting in a queue). The underlying media bridge
+// is handed over to the answering leg so the caller keeps the RTP address
+// it was given in the 183. If the PBX answers the call itself (AnswerCall),
+// the stream stays in use after the 200 OK.
+type earlyMedia struct {
+	callID    string
+	req       *sip.Request
+	tx        sip.ServerTransaction
+	bridge    *MediaBridge
+	callerSDP []byte
+	calleeSDP []byte
+	toTag     string
+	player    *media.Player
+
+	// ctx is cancelled when the stream is detached (answered) or the
+	// caller hangs up. Playback in progress stops immediately.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// playMu serialises playback on the caller leg socket.
+	playMu sync.Mutex
+
+	// recorder, if set, receives the caller's audio. recMu keeps the
+	// collector from feeding it after it is stopped.
+	recMu    sync.Mutex
+	recorder *media.Recorder
+
+	// collectorDone is closed once the RFC 2833 collector reading the
+	// caller leg socket has stopped.
+	collectorDone chan struct{}
+
+	// answered, connected and hungUp are guarded by FlowSIPActions.earlyMu.
+	// answered means the stream was handed over to an answering leg;
+	// connected means the PBX answered the call itself.
+	answered  bool
+	connected bool
+	hungUp    bool
+}
+
+// StartEarlyMedia sends 183 Session Progress with an SDP answer pointing at
+// the proxy's caller-leg socket and registers the call as pending so that a
+// CANCEL from the caller stops playback. Subsequent calls are no-ops.
+func (a *FlowSIPActions) StartEarlyMedia(ctx context.Context, callCtx *flow.CallContext) error {
+	_, err := a.startEarlyMedia(callCtx)
+	return err
+}
+
+// PlayPrompt plays an audio file to the caller once over early media.
+func (a *FlowSIPActions) PlayPrompt(ctx context.Context, callCtx *flow.CallContext, filePath string) error {
+	em, err := a.startEarlyMedia(callCtx)
+	if err != nil {
+		return err
+	}
+	return a.playEarly(ctx, em, filePath)
+}
+
+// PlayHoldMusic plays a music on hold class, or loops an audio file if
+// classID is nil or the class has no prompts, to the caller over early
+// media until ctx is cancelled, the call is answered by another leg, or
+// the caller hangs up. The caller listens to the class's shared stream.
+func (a *FlowSIPActions) PlayHoldMusic(ctx context.Context, callCtx *flow.CallContext, classID *int64, filePath string) error {
+	em, err := a.startEarlyMedia(callCtx)
+	if err != nil {
+		return err
+	}
+
+	em.playMu.Lock()
+	defer em.playMu.Unlock()
+
+	if err := a.earlyMediaErr(em); err != nil || em.ctx.Err() != nil {
+		return err
+	}
+
+	playCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(em.ctx, cancel)
+	defer stop()
+	defer cancel()
+
+	l := a.moh.Listen(playCtx, classID, filePath)
+	defer l.Close()
+
+	_, err = em.player.PlayStream(playCtx, l)
+	if hangupErr := a.earlyMediaErr(em); hangupErr != nil {
+		return hangupErr
+	}
+	if err != nil && playCtx.Err() == nil {
+		return fmt.Errorf("playing hold music: %w", err)
+	}
+	return nil
+}
+
+// AnswerCall answers the call with 200 OK on behalf of the PBX, e.g. for
+// voicemail. The 200 OK carries the SDP and To tag of the early media
+// stream, which is started first if necessary, so prompts keep playing on
+// the same RTP stream. A dialog without a callee leg tracks the call until
+// HangupCall, a BYE from the caller, or the end of the flow. Subsequent
+// calls are no-ops.
+func (a *FlowSIPActions) AnswerCall(ctx context.Context, callCtx *flow.CallContext) error {
+	callID := callCtx.CallID
+
+	em, err := a.startEarlyMedia(callCtx)
+	if err != nil {
+		return err
+	}
+
+	a.earlyMu.Lock()
+	if em.hungUp {
+		a.earlyMu.Unlock()
+		return flow.ErrCallerHungUp
+	}
+	if em.answered || em.connected {
+		a.earlyMu.Unlock()
+		return nil
+	}
+	em.connected = true
+	a.earlyMu.Unlock()
+
+	res := sip.NewResponseFromRequest(em.req, 200, "OK", em.callerSDP)
+	res.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+	if em.toTag != "" {
+		res.To().Params.Add("tag", em.toTag)
+	}
+	if err := em.tx.Respond(res); err != nil {
+		a.earlyMu.Lock()
+		em.connected = false
+		a.earlyMu.Unlock()
+		return fmt.Errorf("sending 200 ok: %w", err)
+	}
+
+	if pc := a.pendingMgr.Get(callID); pc != nil && pc.Bridge == em.bridge {
+		a.pendingMgr.Remove(callID)
+	}
+
+	direction := CallTypeInternal
+	if callCtx.TrunkID != 0 {
+		direction = CallTypeInbound
+	}
+	dialog := &Dialog{
+		CallID:       callID,
+		Direction:    direction,
+		TrunkID:      callCtx.TrunkID,
+		CallerIDName: callCtx.CallerIDName,
+		CallerIDNum:  callCtx.CallerIDNum,
+		CalledNum:    callCtx.Callee,
+		StartTime:    callCtx.StartTime,
+		CallerTx:     em.tx,
+		CallerReq:    em.req,
+		Media:        em.bridge.Session(),
+		Caller:       CallLeg{ToTag: em.toTag},
+	}
+	if from := em.req.From(); from != nil {
+		dialog.Caller.FromTag, _ = from.Params.Get("tag")
+	}
+	a.dialogMgr.CreateDialog(dialog)
+	a.updateCDROnAnswer(callID)
+
+	a.logger.Info("call answered by pbx",
+		"call_id", callID,
+		"active_calls", a.dialogMgr.ActiveCallCount(),
+	)
+	return nil
+}
+
+// activeStream returns the call's caller-facing RTP stream while it is on
+// early media or answered by the PBX, or nil if there is none.
+func (a *FlowSIPActions) activeStream(callID string) *earlyMedia {
+	a.earlyMu.Lock()
+	defer a.earlyMu.Unlock()
+
+	em := a.early[callID]
+	if em == nil || em.answered || em.ctx.Err() != nil {
+		return nil
+	}
+	return em
+}
+
+// pbxAnswered reports whether AnswerCall answered the call.
+func (a *FlowSIPActions) pbxAnswered(callID string) bool {
+	a.earlyMu.Lock()
+	defer a.earlyMu.Unlock()
+
+	em := a.early[callID]
+	return em != nil && em.connected
+}
+
+// startEarlyMedia returns the call's early media stream, creating it and
+// sending 183 Session Progress on first use.
+func (a *FlowSIPActions) startEarlyMedia(callCtx *flow.CallContext) (*earlyMedia, error) {
+	callID := callCtx.CallID
+
+	a.earlyMu.Lock()
+	defer a.earlyMu.Unlock()
+
+	if em, ok := a.early[callID]; ok {
+		return em, nil
+	}
+
+	req := callCtx.Request
+	tx := callCtx.Transaction
+	if req == nil || tx == nil {
+		return nil, fmt.Errorf("call context has no sip request or transaction")
+	}
+	if len(req.Body()) == 0 || a.sessionMgr == nil {
+		return nil, fmt.Errorf("call has no sdp offer for early media")
+	}
+
+	bridge, calleeSDP, err := AllocateMediaBridge(a.sessionMgr, req.Body(), callID, a.proxyIP, SRTPPolicy{}, a.logger)
+	if err != nil {
+		return nil, fmt.Errorf("allocating media bridge: %w", err)
+	}
+
+	// Answer the caller's offer with the proxy's caller-leg address.
+	callerSDP := bridge.callerEarlyAnswer()
+
+	// Prompts and digits on an SRTP caller leg are encrypted with the
+	// bridge's keys, which the relay takes over once a callee answers.
+	srtpIn, srtpOut, err := legSRTPContexts(bridge.callerSRTP)
+	if err != nil {
+		bridge.Release()
+		return nil, fmt.Errorf("creating early media srtp context: %w", err)
+	}
+	// A relay started without the callee's answer, e.g. to a transfer
+	// target, must still terminate the caller's SRTP.
+	if err := bridge.Session().SetSRTP(bridge.callerSRTP, nil); err != nil {
+		bridge.Release()
+		return nil, fmt.Errorf("setting early media srtp keys: %w", err)
+	}
+
+	remote, err := extractRTPAddr(bridge.callerSD)
+	if err != nil {
+		bridge.Release()
+		return nil, fmt.Errorf("extracting caller rtp address: %w", err)
+	}
+
+	res := sip.NewResponseFromRequest(req, 183, "Session Progress", callerSDP)
+	res.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+	if err := tx.Respond(res); err != nil {
+		bridge.Release()
+		return nil, fmt.Errorf("sending 183 session progress: %w", err)
+	}
+
+	toTag := ""
+	if to := res.To(); to != nil {
+		toTag, _ = to.Params.Get("tag")
+	}
+
+	emCtx, cancel := context.WithCancel(context.Background())
+	conn := bridge.Session().Session().CallerLeg.RTPConn
+	em := &earlyMedia{
+		callID:        callID,
+		req:           req,
+		tx:            tx,
+		bridge:        bridge,
+		callerSDP:     callerSDP,
+		calleeSDP:     calleeSDP,
+		toTag:         toTag,
+		player:        media.NewPlayer(conn, remote, a.logger),
+		ctx:           emCtx,
+		cancel:        cancel,
+		collectorDone: make(chan struct{}),
+	}
+	em.player.SetSRTP(srtpOut)
+	a.early[callID] = em
+	a.pendingMgr.Add(a.earlyPendingCall(em))
+
+	// Nothing else reads the caller leg socket until a relay takes it
+	// over, so listen for RFC 2833 digits (and audio to record) here.
+	collector := media.NewDTMFCollector(conn, a.logger)
+	collector.Audio = em.feedRecorder
+	collector.SRTP = srtpIn
+	go collector.Run(emCtx)
+	go func() {
+		defer close(em.collectorDone)
+		for digit := range collector.Digits {
+			if a.dtmfMgr != nil {
+				a.dtmfMgr.Inject(callID, digit)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(earlyMediaKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-emCtx.Done():
+				return
+			case <-ticker.C:
+				bridge.Session().Session().TouchActivity()
+			}
+		}
+	}()
+
+	a.logger.Info("early media started",
+		"call_id", callID,
+		"caller_remote", remote.String(),
+		"proxy_port", bridge.Session().CallerRTPPort(),
+	)
+
+	return em, nil
+}
+
+// setRecorder starts or, with nil, stops feeding the caller's audio to rec.
+func (em *earlyMedia) setRecorder(rec *media.Recorder) {
+	em.recMu.Lock()
+	em.recorder = rec
+	em.recMu.Unlock()
+}
+
+// feedRecorder passes a caller audio payload to the recorder, if any.
+func (em *earlyMedia) feedRecorder(payload []byte, payloadType int) {
+	em.recMu.Lock()
+	if em.recorder != nil {
+		em.recorder.Feed(payload, payloadType)
+	}
+	em.recMu.Unlock()
+}
+
+// playEarly plays a single file over the early media stream. It returns nil
+// when playback completes, ctx is cancelled, or the stream is detached, and
+// flow.ErrCallerHungUp if the caller abandoned the call.
+func (a *FlowSIPActions) playEarly(ctx context.Context, em *earlyMedia, filePath string) error {
+	em.playMu.Lock()
+	defer em.playMu.Unlock()
+
+	if err := a.earlyMediaErr(em); err != nil || em.ctx.Err() != nil {
+		return err
+	}
+
+	playCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(em.ctx, cancel)
+	defer stop()
+	defer cancel()
+
+	result, err := em.player.PlayFile(playCtx, filePath)
+	if hangupErr := a.earlyMediaErr(em); hangupErr != nil {
+		return hangupErr
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil
+		}
+		return fmt.Errorf("playing %s: %w", filePath, err)
+	}
+	if result.PacketsSent == 0 {
+		return fmt.Errorf("playing %s: file contains no audio", filePath)
+	}
+	return nil
+}
+
+// earlyMediaErr returns flow.ErrCallerHungUp if the caller abandoned the
+// call while on early media.
+func (a *FlowSIPActions) earlyMediaErr(em *earlyMedia) error {
+	a.earlyMu.Lock()
+	defer a.earlyMu.Unlock()
+	if em.hungUp {
+		return flow.ErrCallerHungUp
+	}
+	return nil
+}
+
+// earlyPendingCall builds the pending call entry registered while the caller
+// is listening to early media, so a CANCEL stops playback and sends 487.
+func (a *FlowSIPActions) earlyPendingCall(em *earlyMedia) *PendingCall {
+	return &PendingCall{
+		CallID:     em.callID,
+		CallerTx:   em.tx,
+		CallerReq:  em.req,
+		CancelFork: func() { a.abortEarlyMedia(em.callID) },
+		Bridge:     em.bridge,
+	}
+}
+
+// allocateFlowBridge returns the media bridge and callee-facing SDP for a
+// flow ring attempt. If the call is on early media, its bridge is reused so
+// the caller keeps the RTP address it was given in the 183.
+func (a *FlowSIPActions) allocateFlowBridge(req *sip.Request, callID string) (*MediaBridge, []byte, error) {
+	a.earlyMu.Lock()
+	em := a.early[callID]
+	a.earlyMu.Unlock()
+
+	if em != nil && em.ctx.Err() == nil {
+		return em.bridge, em.calleeSDP, nil
+	}
+	return AllocateMediaBridge(a.sessionMgr, req.Body(), callID, a.proxyIP, SRTPPolicy{}, a.logger)
+}
+
+// provisionalRelayTx returns the caller transaction the forker should relay
+// 180/183 responses on, or nil if the caller is on early media and must keep
+// hearing it instead of switching to ringback.
+func (a *FlowSIPActions) provisionalRelayTx(callID string, tx sip.ServerTransaction) sip.ServerTransaction {
+	a.earlyMu.Lock()
+	em := a.early[callID]
+	a.earlyMu.Unlock()
+
+	if em != nil && em.ctx.Err() == nil {
+		return nil
+	}
+	return tx
+}
+
+// releaseFlowBridge releases a media bridge after an unanswered ring attempt.
+// Early media bridges are kept, and the early pending call is re-registered
+// because the ring attempt replaced and then removed it.
+func (a *FlowSIPActions) releaseFlowBridge(callID string, bridge *MediaBridge) {
+	if bridge == nil {
+		return
+	}
+
+	a.earlyMu.Lock()
+	em := a.early[callID]
+	a.earlyMu.Unlock()
+
+	if em != nil && em.bridge == bridge {
+		if em.ctx.Err() == nil && a.pendingMgr.Get(callID) == nil {
+			a.pendingMgr.Add(a.earlyPendingCall(em))
+		}
+		return
+	}
+	bridge.Release()
+}
+
+// detachEarlyMedia stops early media playback when a ring attempt has been
+// answered and hands the bridge over to the answering leg. It returns the
+// To tag used in the 183 so the 200 OK stays in the same dialog, or "" if
+// the call was not on early media.
+func (a *FlowSIPActions) detachEarlyMedia(callID string) string {
+	a.earlyMu.Lock()
+	em := a.early[callID]
+	if em != nil {
+		em.answered = true
+	}
+	a.earlyMu.Unlock()
+
+	if em == nil {
+		return ""
+	}
+
+	em.cancel()
+
+	// Wait for any in-flight playback and the digit collector to stop
+	// before the relay takes over the caller leg socket.
+	em.playMu.Lock()
+	em.playMu.Unlock()
+	<-em.collectorDone
+
+	a.logger.Debug("early media detached",
+		"call_id", callID,
+	)
+
+	return em.toTag
+}
+
+// abortEarlyMedia marks the caller as hung up and stops playback. Called
+// when the caller cancels while on hold or during a ring attempt.
+func (a *FlowSIPActions) abortEarlyMedia(callID string) {
+	a.earlyMu.Lock()
+	em := a.early[callID]
+	if em != nil && !em.answered {
+		em.hungUp = true
+	}
+	a.earlyMu.Unlock()
+
+	if em != nil {
+		em.cancel()
+	}
+}
+
+// releaseEarlyMedia tears down the call's early media stream once the flow
+// has finished. The bridge is released unless it was handed over to an
+// answered leg. A call the PBX answered itself is hung up, as nothing is
+// left to talk to the caller.
+func (a *FlowSIPActions) releaseEarlyMedia(callID string) {
+	a.earlyMu.Lock()
+	em := a.early[callID]
+	delete(a.early, callID)
+	a.earlyMu.Unlock()
+
+	if em == nil {
+		return
+	}
+
+	em.cancel()
+
+	if em.connected && !em.answered {
+		if d := a.dialogMgr.GetDialog(callID); d != nil && !d.hasCallee() {
+			a.hangupDialog(d, "normal_clearing")
+		}
+		return
+	}
+
+	if !em.answered {
+		if pc := a.pendingMgr.Get(callID); pc != nil && pc.Bridge == em.bridge {
+			a.pendingMgr.Remove(callID)
+		}
+		em.bridge.Release()
+	}
+}
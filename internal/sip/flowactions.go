@@ -2,10 +2,12 @@ package sip
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/emiago/sipgo"
@@ -15,6 +17,7 @@ import (
 	"github.com/flowpbx/flowpbx/internal/flow"
 	"github.com/flowpbx/flowpbx/internal/media"
 	"github.com/flowpbx/flowpbx/internal/push"
+	"github.com/flowpbx/flowpbx/internal/tts"
 )
 
 // defaultPushWaitTimeout is the time to wait for a mobile app to re-register
@@ -29,6 +32,7 @@ type FlowSIPActions struct {
 	pushTokens     database.PushTokenRepository
 	forker         *Forker
 	outboundRouter *OutboundRouter
+	router         *CallRouter
 	dialogMgr      *DialogManager
 	pendingMgr     *PendingCallManager
 	sessionMgr     *media.SessionManager
@@ -37,9 +41,36 @@ type FlowSIPActions struct {
 	cdrs           database.CDRRepository
 	pushClient     *push.Client
 	regNotifier    *RegistrationNotifier
+	subscriptions  *SubscriptionManager
+	speech         *tts.Cache
+	moh            *MOHManager
 	proxyIP        string
+	sipPort        int
 	dataDir        string
 	logger         *slog.Logger
+
+	// early tracks calls currently streaming early media (hold music or
+	// announcements before answer), keyed by Call-ID.
+	earlyMu sync.Mutex
+	early   map[string]*earlyMedia
+
+	// local holds the local flow calls being dialled, keyed by the
+	// Request-URI user their INVITE is addressed to.
+	localMu sync.Mutex
+	local   map[string]*localFlowCall
+
+	// onVoicemail, if set, is called with each new voicemail message.
+	onVoicemail VoicemailListener
+}
+
+// VoicemailListener is called with a voicemail message that has just been
+// stored in box.
+type VoicemailListener func(box *models.VoicemailBox, msg *models.VoicemailMessage)
+
+// OnVoicemail registers a listener for new voicemail messages. Must be
+// called before calls are handled.
+func (a *FlowSIPActions) OnVoicemail(fn VoicemailListener) {
+	a.onVoicemail = fn
 }
 
 // NewFlowSIPActions creates a new SIP actions adapter for the flow engine.
@@ -57,7 +88,11 @@ func NewFlowSIPActions(
 	cdrs database.CDRRepository,
 	pushClient *push.Client,
 	regNotifier *RegistrationNotifier,
+	subscriptions *SubscriptionManager,
+	speech *tts.Cache,
+	moh *MOHManager,
 	proxyIP string,
+	sipPort int,
 	dataDir string,
 	logger *slog.Logger,
 ) *FlowSIPActions {
@@ -67,6 +102,7 @@ func NewFlowSIPActions(
 		pushTokens:     pushTokens,
 		forker:         forker,
 		outboundRouter: outboundRouter,
+		router:         NewCallRouter(extensions, registrations, dialogMgr, logger),
 		dialogMgr:      dialogMgr,
 		pendingMgr:     pendingMgr,
 		sessionMgr:     sessionMgr,
@@ -75,9 +111,15 @@ func NewFlowSIPActions(
 		cdrs:           cdrs,
 		pushClient:     pushClient,
 		regNotifier:    regNotifier,
+		subscriptions:  subscriptions,
+		speech:         speech,
+		moh:            moh,
 		proxyIP:        proxyIP,
+		sipPort:        sipPort,
 		dataDir:        dataDir,
 		logger:         logger.With("subsystem", "flow_sip_actions"),
+		early:          make(map[string]*earlyMedia),
+		local:          make(map[string]*localFlowCall),
 	}
 }
 
@@ -185,7 +227,7 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 	var calleeSDP []byte
 	if len(req.Body()) > 0 && a.sessionMgr != nil {
 		var err error
-		bridge, calleeSDP, err = AllocateMediaBridge(a.sessionMgr, req.Body(), callID, a.proxyIP, a.logger)
+		bridge, calleeSDP, err = a.allocateFlowBridge(req, callID)
 		if err != nil {
 			a.logger.Error("failed to allocate media bridge for flow ring",
 				"call_id", callID,
@@ -206,10 +248,11 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 		CallerReq:  req,
 		CancelFork: cancelFork,
 		Bridge:     bridge,
+		Extensions: extensionNumbers(ext),
 	})
 
 	// Fork INVITE to all registered contacts.
-	result := a.forker.Fork(forkCtx, req, tx, active, nil, callID, calleeSDP)
+	result := a.forker.Fork(forkCtx, req, a.provisionalRelayTx(callID, tx), active, nil, callID, calleeSDP)
 
 	// Remove from pending calls.
 	pc := a.pendingMgr.Remove(callID)
@@ -218,26 +261,26 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 	// If the pending call was already cancelled by the CANCEL handler,
 	// the fork result doesn't matter.
 	if pc == nil {
+		if a.pendingMgr.PickedUp(callID) {
+			return a.pickedUpRingResult(callID, result), nil
+		}
 		a.logger.Info("flow ring completed but call was already cancelled",
 			"call_id", callID,
 		)
 		if result.Answered && result.AnsweringTx != nil {
 			result.AnsweringTx.Terminate()
 		}
-		return &flow.RingResult{}, fmt.Errorf("call cancelled during ringing")
+		a.abortEarlyMedia(callID)
+		return &flow.RingResult{}, fmt.Errorf("call cancelled during ringing: %w", flow.ErrCallerHungUp)
 	}
 
 	if result.Error != nil {
-		if bridge != nil {
-			bridge.Release()
-		}
+		a.releaseFlowBridge(callID, bridge)
 		return nil, fmt.Errorf("forking to extension %s: %w", ext.Extension, result.Error)
 	}
 
 	if result.AllBusy {
-		if bridge != nil {
-			bridge.Release()
-		}
+		a.releaseFlowBridge(callID, bridge)
 		return &flow.RingResult{AllBusy: true}, nil
 	}
 
@@ -274,9 +317,7 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 				// Re-fetch active registrations — the app should now have a fresh one.
 				regs, err = a.registrations.GetByExtensionID(ctx, ext.ID)
 				if err != nil {
-					if bridge != nil {
-						bridge.Release()
-					}
+					a.releaseFlowBridge(callID, bridge)
 					return nil, fmt.Errorf("looking up registrations after push wait: %w", err)
 				}
 
@@ -299,35 +340,36 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 						CallerReq:  req,
 						CancelFork: retryCancel,
 						Bridge:     bridge,
+						Extensions: extensionNumbers(ext),
 					})
 
-					result = a.forker.Fork(retryCtx, req, tx, active, nil, callID, calleeSDP)
+					result = a.forker.Fork(retryCtx, req, a.provisionalRelayTx(callID, tx), active, nil, callID, calleeSDP)
 
 					pc = a.pendingMgr.Remove(callID)
 					retryCancel()
 
 					// Check retry result.
 					if pc == nil {
+						if a.pendingMgr.PickedUp(callID) {
+							return a.pickedUpRingResult(callID, result), nil
+						}
 						a.logger.Info("retry fork completed but call was already cancelled",
 							"call_id", callID,
 						)
 						if result.Answered && result.AnsweringTx != nil {
 							result.AnsweringTx.Terminate()
 						}
-						return &flow.RingResult{}, fmt.Errorf("call cancelled during retry")
+						a.abortEarlyMedia(callID)
+						return &flow.RingResult{}, fmt.Errorf("call cancelled during retry: %w", flow.ErrCallerHungUp)
 					}
 
 					if result.Error != nil {
-						if bridge != nil {
-							bridge.Release()
-						}
+						a.releaseFlowBridge(callID, bridge)
 						return nil, fmt.Errorf("retry fork failed: %w", result.Error)
 					}
 
 					if result.AllBusy {
-						if bridge != nil {
-							bridge.Release()
-						}
+						a.releaseFlowBridge(callID, bridge)
 						return &flow.RingResult{AllBusy: true}, nil
 					}
 
@@ -349,9 +391,7 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 	}
 
 	if !result.Answered {
-		if bridge != nil {
-			bridge.Release()
-		}
+		a.releaseFlowBridge(callID, bridge)
 		return &flow.RingResult{Answered: false}, nil
 	}
 
@@ -362,6 +402,10 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 		"contact", result.AnsweringContact.ContactURI,
 	)
 
+	// Stop any early media (e.g. queue hold music) so the relay can take
+	// over the caller leg socket.
+	earlyTag := a.detachEarlyMedia(callID)
+
 	// Send ACK to the answering callee device.
 	ackReq := buildACKFor2xx(result.AnsweringLeg.req, result.AnswerResponse)
 	if err := a.forker.Client().WriteRequest(ackReq); err != nil {
@@ -398,6 +442,10 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 	if len(okBody) > 0 {
 		okResponse.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
 	}
+	if earlyTag != "" {
+		// Keep the To tag from the 183 so the caller sees a single dialog.
+		okResponse.To().Params.Add("tag", earlyTag)
+	}
 
 	if err := tx.Respond(okResponse); err != nil {
 		a.logger.Error("failed to relay 200 ok to caller via flow",
@@ -440,6 +488,11 @@ func (a *FlowSIPActions) RingExtension(ctx context.Context, callCtx *flow.CallCo
 			dialog.Caller.FromTag = tag
 		}
 	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
 	if to := result.AnswerResponse.To(); to != nil {
 		if tag, ok := to.Params.Get("tag"); ok {
 			dialog.Callee.ToTag = tag
@@ -557,7 +610,7 @@ func (a *FlowSIPActions) RingGroup(ctx context.Context, callCtx *flow.CallContex
 	var calleeSDP []byte
 	if len(req.Body()) > 0 && a.sessionMgr != nil {
 		var err error
-		bridge, calleeSDP, err = AllocateMediaBridge(a.sessionMgr, req.Body(), callID, a.proxyIP, a.logger)
+		bridge, calleeSDP, err = a.allocateFlowBridge(req, callID)
 		if err != nil {
 			a.logger.Error("failed to allocate media bridge for ring group",
 				"call_id", callID,
@@ -578,43 +631,42 @@ func (a *FlowSIPActions) RingGroup(ctx context.Context, callCtx *flow.CallContex
 		CallerReq:  req,
 		CancelFork: cancelFork,
 		Bridge:     bridge,
+		Extensions: extensionNumbers(extensions...),
 	})
 
 	// Fork INVITE to all registered contacts across all member extensions.
-	result := a.forker.Fork(forkCtx, req, tx, allContacts, nil, callID, calleeSDP)
+	result := a.forker.Fork(forkCtx, req, a.provisionalRelayTx(callID, tx), allContacts, nil, callID, calleeSDP)
 
 	// Remove from pending calls.
 	pc := a.pendingMgr.Remove(callID)
 	cancelFork()
 
 	if pc == nil {
+		if a.pendingMgr.PickedUp(callID) {
+			return a.pickedUpRingResult(callID, result), nil
+		}
 		a.logger.Info("ring group completed but call was already cancelled",
 			"call_id", callID,
 		)
 		if result.Answered && result.AnsweringTx != nil {
 			result.AnsweringTx.Terminate()
 		}
-		return &flow.RingResult{}, fmt.Errorf("call cancelled during ringing")
+		a.abortEarlyMedia(callID)
+		return &flow.RingResult{}, fmt.Errorf("call cancelled during ringing: %w", flow.ErrCallerHungUp)
 	}
 
 	if result.Error != nil {
-		if bridge != nil {
-			bridge.Release()
-		}
+		a.releaseFlowBridge(callID, bridge)
 		return nil, fmt.Errorf("forking to ring group: %w", result.Error)
 	}
 
 	if result.AllBusy {
-		if bridge != nil {
-			bridge.Release()
-		}
+		a.releaseFlowBridge(callID, bridge)
 		return &flow.RingResult{AllBusy: true}, nil
 	}
 
 	if !result.Answered {
-		if bridge != nil {
-			bridge.Release()
-		}
+		a.releaseFlowBridge(callID, bridge)
 		return &flow.RingResult{Answered: false}, nil
 	}
 
@@ -624,6 +676,10 @@ func (a *FlowSIPActions) RingGroup(ctx context.Context, callCtx *flow.CallContex
 		"contact", result.AnsweringContact.ContactURI,
 	)
 
+	// Stop any early media (e.g. queue hold music) so the relay can take
+	// over the caller leg socket.
+	earlyTag := a.detachEarlyMedia(callID)
+
 	// Send ACK to the answering callee device.
 	ackReq := buildACKFor2xx(result.AnsweringLeg.req, result.AnswerResponse)
 	if err := a.forker.Client().WriteRequest(ackReq); err != nil {
@@ -660,6 +716,10 @@ func (a *FlowSIPActions) RingGroup(ctx context.Context, callCtx *flow.CallContex
 	if len(okBody) > 0 {
 		okResponse.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
 	}
+	if earlyTag != "" {
+		// Keep the To tag from the 183 so the caller sees a single dialog.
+		okResponse.To().Params.Add("tag", earlyTag)
+	}
 
 	if err := tx.Respond(okResponse); err != nil {
 		a.logger.Error("failed to relay 200 ok to caller via ring group",
@@ -718,6 +778,11 @@ func (a *FlowSIPActions) RingGroup(ctx context.Context, callCtx *flow.CallContex
 			dialog.Caller.FromTag = tag
 		}
 	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
 	if to := result.AnswerResponse.To(); to != nil {
 		if tag, ok := to.Params.Get("tag"); ok {
 			dialog.Callee.ToTag = tag
@@ -751,10 +816,12 @@ func (a *FlowSIPActions) RingGroup(ctx context.Context, callCtx *flow.CallContex
 // It acquires a per-call DTMF buffer from the CallDTMFManager, configures a
 // DigitBuffer with the requested timing, and blocks until collection completes.
 // DTMF digits arrive from both SIP INFO (injected by handleInfo) and RFC 2833
-// (injected by the DTMFCollector bridge).
+// (injected by the DTMFCollector on the caller's RTP stream).
 //
-// TODO(sprint-14): Full media implementation — play audio file via RTP while
-// collecting. Current implementation collects DTMF without playing audio.
+// A prompt that names an audio file is played to the caller over early media
+// (or the answered call) first; pressing a digit stops playback and counts
+// as the first digit collected. TTS prompts are rendered to speech first
+// when a TTS engine is configured.
 func (a *FlowSIPActions) PlayAndCollect(ctx context.Context, callCtx *flow.CallContext, prompt string, isTTS bool, timeout int, digitTimeout int, maxDigits int) (*flow.CollectResult, error) {
 	callID := callCtx.CallID
 	a.logger.Info("play and collect starting",
@@ -778,15 +845,40 @@ func (a *FlowSIPActions) PlayAndCollect(ctx context.Context, callCtx *flow.CallC
 	digitCh := a.dtmfMgr.Acquire(callID)
 	defer a.dtmfMgr.Release(callID)
 
+	collectCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	source := digitCh
+	em, file := a.promptStream(collectCtx, callCtx, prompt, isTTS)
+	if em != nil {
+		first, err := a.playUntilDigit(collectCtx, em, file, digitCh)
+		if err != nil {
+			return nil, err
+		}
+		if first != "" {
+			source = prependDigit(collectCtx, first, digitCh)
+		}
+
+		// Stop waiting for digits if the caller hangs up.
+		stop := context.AfterFunc(em.ctx, cancel)
+		defer stop()
+	}
+
 	// Configure the digit buffer with the requested timing parameters.
-	buf := media.NewDigitBuffer(digitCh, a.logger)
+	buf := media.NewDigitBuffer(source, a.logger)
 	buf.SetFirstDigitTimeout(time.Duration(timeout) * time.Second)
 	buf.SetInterDigitTimeout(time.Duration(digitTimeout) * time.Second)
 	buf.SetMaxDigits(maxDigits)
 	buf.SetTerminator("#")
 
 	// Block until collection completes (max digits, terminator, timeout, or cancel).
-	result := buf.Collect(ctx)
+	result := buf.Collect(collectCtx)
+
+	if em != nil {
+		if err := a.earlyMediaErr(em); err != nil {
+			return nil, err
+		}
+	}
 
 	a.logger.Info("play and collect completed",
 		"call_id", callID,
@@ -801,6 +893,115 @@ func (a *FlowSIPActions) PlayAndCollect(ctx context.Context, callCtx *flow.CallC
 	}, nil
 }
 
+// promptStream returns the stream to play a prompt on, starting early media
+// if necessary, and the audio file to play. TTS prompts are rendered to a
+// file first. The stream is nil if the prompt has no audio or cannot be
+// played to the caller.
+func (a *FlowSIPActions) promptStream(ctx context.Context, callCtx *flow.CallContext, prompt string, isTTS bool) (*earlyMedia, string) {
+	if prompt == "" {
+		return nil, ""
+	}
+	file := prompt
+	if isTTS {
+		var err error
+		if file, err = a.speak(ctx, prompt); err != nil {
+			level := slog.LevelWarn
+			if errors.Is(err, errNoTTS) {
+				level = slog.LevelDebug
+			}
+			a.logger.Log(ctx, level, "cannot speak prompt, collecting digits only",
+				"call_id", callCtx.CallID,
+				"error", err,
+			)
+			return nil, ""
+		}
+	}
+	if _, err := os.Stat(file); err != nil {
+		a.logger.Debug("prompt file not available, collecting digits only",
+			"call_id", callCtx.CallID,
+			"prompt", file,
+		)
+		return nil, ""
+	}
+	if _, err := a.startEarlyMedia(callCtx); err != nil {
+		a.logger.Warn("cannot play prompt, collecting digits only",
+			"call_id", callCtx.CallID,
+			"prompt", file,
+			"error", err,
+		)
+		return nil, ""
+	}
+	return a.activeStream(callCtx.CallID), file
+}
+
+// errNoTTS is returned when a TTS prompt is played without a TTS engine.
+var errNoTTS = errors.New("no tts engine configured")
+
+// speak renders text to a speech file with the configured TTS engine.
+func (a *FlowSIPActions) speak(ctx context.Context, text string) (string, error) {
+	if a.speech == nil {
+		return "", errNoTTS
+	}
+	return a.speech.Render(ctx, text)
+}
+
+// playUntilDigit plays a file to the caller until it ends or a digit
+// arrives on digits, which stops playback. It returns the digit, or "" if
+// playback ran to completion. Playback errors other than the caller
+// hanging up are logged and ignored so digits can still be collected.
+func (a *FlowSIPActions) playUntilDigit(ctx context.Context, em *earlyMedia, filePath string, digits <-chan string) (string, error) {
+	playCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.playEarly(playCtx, em, filePath)
+	}()
+
+	var digit string
+	var err error
+	select {
+	case err = <-done:
+	case digit = <-digits:
+		cancel()
+		err = <-done
+	}
+
+	if errors.Is(err, flow.ErrCallerHungUp) {
+		return "", err
+	}
+	if err != nil {
+		a.logger.Warn("prompt playback failed",
+			"call_id", em.callID,
+			"prompt", filePath,
+			"error", err,
+		)
+	}
+	return digit, nil
+}
+
+// prependDigit returns a channel that yields first and then the digits
+// received on src until ctx is cancelled.
+func prependDigit(ctx context.Context, first string, src <-chan string) <-chan string {
+	out := make(chan string, cap(src)+1)
+	out <- first
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case digit := <-src:
+				select {
+				case out <- digit:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
 // updateCDROnAnswer updates the CDR with the answer time.
 func (a *FlowSIPActions) updateCDROnAnswer(callID string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -829,11 +1030,9 @@ func (a *FlowSIPActions) updateCDROnAnswer(callID string) {
 	}
 }
 
-// RecordMessage plays a greeting prompt and records the caller's message.
-//
-// TODO(sprint-14): Full media implementation — play greeting via RTP, then
-// record incoming RTP to WAV file. Current implementation creates an empty
-// recording file and returns immediately.
+// RecordMessage answers the call, plays a greeting prompt and records the
+// caller's audio to filePath. Recording stops when the caller presses '#',
+// hangs up, or maxDuration seconds pass; the message is kept in every case.
 func (a *FlowSIPActions) RecordMessage(ctx context.Context, callCtx *flow.CallContext, greeting string, maxDuration int, filePath string) (*flow.RecordResult, error) {
 	callID := callCtx.CallID
 	a.logger.Info("record message starting",
@@ -843,29 +1042,95 @@ func (a *FlowSIPActions) RecordMessage(ctx context.Context, callCtx *flow.CallCo
 		"file_path", filePath,
 	)
 
-	// Stub: create an empty file as a placeholder for the recording.
-	// The full RTP recording implementation will be added in the media sprint.
-	f, err := os.Create(filePath)
+	if err := a.AnswerCall(ctx, callCtx); err != nil {
+		return nil, fmt.Errorf("answering call for recording: %w", err)
+	}
+	em := a.activeStream(callID)
+	if em == nil {
+		return nil, fmt.Errorf("call %s has no media stream to record", callID)
+	}
+
+	// Digits pressed during the greeting are ignored; '#' only ends the
+	// recording once it has started.
+	if greeting != "" {
+		if err := a.playEarly(ctx, em, greeting); err != nil {
+			if errors.Is(err, flow.ErrCallerHungUp) {
+				return nil, err
+			}
+			a.logger.Warn("greeting playback failed",
+				"call_id", callID,
+				"greeting", greeting,
+				"error", err,
+			)
+		}
+	}
+
+	digitCh := a.dtmfMgr.Acquire(callID)
+	defer a.dtmfMgr.Release(callID)
+
+	rec, err := media.NewRecorder(filePath, a.logger)
 	if err != nil {
-		return nil, fmt.Errorf("creating recording file: %w", err)
+		return nil, fmt.Errorf("starting recorder: %w", err)
+	}
+	em.setRecorder(rec)
+
+	recordCtx, cancel := context.WithTimeout(ctx, time.Duration(maxDuration)*time.Second)
+	defer cancel()
+	stop := context.AfterFunc(em.ctx, cancel)
+	defer stop()
+
+wait:
+	for {
+		select {
+		case <-recordCtx.Done():
+			break wait
+		case digit := <-digitCh:
+			if digit == "#" {
+				break wait
+			}
+		}
 	}
-	f.Close()
 
-	a.logger.Info("record message completed (stub)",
+	em.setRecorder(nil)
+	path, duration := rec.Stop()
+
+	a.logger.Info("record message completed",
 		"call_id", callID,
-		"file_path", filePath,
+		"file_path", path,
+		"duration_secs", duration,
 	)
 
 	return &flow.RecordResult{
-		FilePath:     filePath,
-		DurationSecs: 0,
+		FilePath:     path,
+		DurationSecs: duration,
 	}, nil
 }
 
+// pickedUpRingResult reports a ring that ended because another extension
+// picked the call up. The pickup has answered the caller and owns the media
+// bridge; a device that answered the fork at the same time is dropped.
+func (a *FlowSIPActions) pickedUpRingResult(callID string, result *ForkResult) *flow.RingResult {
+	a.logger.Info("flow ring ended by call pickup",
+		"call_id", callID,
+	)
+	if result.Answered && result.AnsweringTx != nil {
+		result.AnsweringTx.Terminate()
+	}
+	return &flow.RingResult{Answered: true}
+}
+
+// VoicemailReceived reports a new voicemail message to the listener.
+func (a *FlowSIPActions) VoicemailReceived(_ context.Context, box *models.VoicemailBox, msg *models.VoicemailMessage) {
+	if a.onVoicemail != nil {
+		a.onVoicemail(box, msg)
+	}
+}
+
 // SendMWI sends a SIP NOTIFY to all registered devices for the specified
 // extension to update the Message Waiting Indicator (voicemail lamp). The
 // NOTIFY carries an Event: message-summary header and an RFC 3842 body
-// with the voice-message counts.
+// with the voice-message counts. Devices that subscribed to the
+// extension's message-summary are notified on their subscription too.
 func (a *FlowSIPActions) SendMWI(ctx context.Context, ext *models.Extension, newMessages int, oldMessages int) error {
 	a.logger.Info("sending MWI notification",
 		"extension", ext.Extension,
@@ -873,6 +1138,13 @@ func (a *FlowSIPActions) SendMWI(ctx context.Context, ext *models.Extension, new
 		"old_messages", oldMessages,
 	)
 
+	// Build the RFC 3842 message-summary body.
+	body := messageSummaryBody(ext.Extension, a.proxyIP, newMessages, oldMessages)
+
+	if a.subscriptions != nil {
+		a.subscriptions.NotifyMessageSummary(ext.Extension, body)
+	}
+
 	// Look up active registrations for the extension.
 	regs, err := a.registrations.GetByExtensionID(ctx, ext.ID)
 	if err != nil {
@@ -895,19 +1167,6 @@ func (a *FlowSIPActions) SendMWI(ctx context.Context, ext *models.Extension, new
 		return nil
 	}
 
-	// Build the RFC 3842 message-summary body.
-	waiting := "no"
-	if newMessages > 0 {
-		waiting = "yes"
-	}
-	body := fmt.Sprintf("Messages-Waiting: %s\r\nMessage-Account: sip:%s@%s\r\nVoice-Message: %d/%d (%d new, %d old)\r\n",
-		waiting,
-		ext.Extension,
-		a.proxyIP,
-		newMessages, oldMessages,
-		newMessages, oldMessages,
-	)
-
 	// Send NOTIFY to each active registration.
 	var lastErr error
 	for i := range active {
@@ -994,12 +1253,9 @@ func (a *FlowSIPActions) sendMWINotify(reg *models.Registration, ext *models.Ext
 }
 
 // HangupCall terminates the call with the given SIP cause code and reason.
-// For answered calls (active dialog), it sends BYE. For unanswered calls,
-// it sends an error response on the server transaction.
-//
-// TODO(sprint-14): Full implementation — look up active dialog, send BYE to
-// both legs, release media. Current implementation sends the response code
-// on the original server transaction.
+// For answered calls (active dialog), it sends BYE to both legs, releases
+// media and finalizes the CDR. For unanswered calls, it sends an error
+// response on the server transaction.
 func (a *FlowSIPActions) HangupCall(ctx context.Context, callCtx *flow.CallContext, cause int, reason string) error {
 	callID := callCtx.CallID
 	a.logger.Info("hanging up call",
@@ -1012,14 +1268,20 @@ func (a *FlowSIPActions) HangupCall(ctx context.Context, callCtx *flow.CallConte
 	if a.dialogMgr != nil {
 		dialog := a.dialogMgr.GetDialog(callID)
 		if dialog != nil {
-			a.dialogMgr.TerminateDialog(callID, reason)
-			a.logger.Info("terminated active dialog for hangup",
-				"call_id", callID,
-			)
+			a.hangupDialog(dialog, reason)
 			return nil
 		}
 	}
 
+	// The caller already hung up, or the call was answered and has ended.
+	a.earlyMu.Lock()
+	em := a.early[callID]
+	ended := em != nil && (em.hungUp || em.connected || em.answered)
+	a.earlyMu.Unlock()
+	if ended {
+		return nil
+	}
+
 	// No active dialog — respond on the server transaction if possible.
 	if callCtx.Request != nil && callCtx.Transaction != nil {
 		res := sip.NewResponseFromRequest(callCtx.Request, cause, reason, nil)
@@ -1031,22 +1293,89 @@ func (a *FlowSIPActions) HangupCall(ctx context.Context, callCtx *flow.CallConte
 	return nil
 }
 
-// BlindTransfer performs a blind (unattended) transfer by sending a SIP REFER
-// to the caller's user agent, instructing it to send a new INVITE to the
-// specified destination.
-//
-// TODO(sprint-14): Full REFER implementation — send SIP REFER with Refer-To
-// header, handle NOTIFY subscription for transfer status. Current
-// implementation logs the transfer and terminates the existing dialog.
+// hangupDialog ends an answered call from the PBX side: both legs are sent
+// BYE, recording and media are stopped, and the dialog and CDR finalized.
+func (a *FlowSIPActions) hangupDialog(d *Dialog, hangupCause string) {
+	var legs []dialogLeg
+	if !d.vacant(true) {
+		legs = append(legs, d.leg(true))
+	}
+	if d.hasCallee() && !d.vacant(false) {
+		legs = append(legs, d.leg(false))
+	}
+	for _, leg := range legs {
+		if err := a.forker.Client().WriteRequest(leg.newRequest(sip.BYE)); err != nil {
+			a.logger.Error("failed to send bye on hangup",
+				"call_id", d.CallID,
+				"error", err,
+			)
+		}
+	}
+
+	if d.Recorder != nil {
+		d.Recorder.Stop()
+	}
+	if d.Media != nil {
+		d.Media.Release()
+	}
+
+	terminated := a.dialogMgr.TerminateDialog(d.CallID, hangupCause)
+	if terminated == nil {
+		return
+	}
+	finalizeDialogCDR(a.cdrs, terminated, a.logger)
+
+	a.logger.Info("call hung up by pbx",
+		"call_id", d.CallID,
+		"hangup_cause", hangupCause,
+	)
+}
+
+// BlindTransfer performs a blind (unattended) transfer of the call to an
+// extension, number, or SIP URI. The destination is routed with the same
+// machinery as a REFER transfer. If the call is already bridged, the
+// destination replaces the callee leg and the previous callee is hung up;
+// otherwise the destination is rung directly and bridged on answer.
 func (a *FlowSIPActions) BlindTransfer(ctx context.Context, callCtx *flow.CallContext, destination string) error {
 	callID := callCtx.CallID
-	a.logger.Info("blind transfer (stub)",
+	a.logger.Info("blind transfer",
 		"call_id", callID,
 		"destination", destination,
 	)
 
-	// Stub: log the transfer. The full SIP REFER implementation will be
-	// added in the SIP signaling sprint.
+	target, err := a.resolveTransferTarget(ctx, destination, nil)
+	if err != nil {
+		return fmt.Errorf("resolving transfer destination: %w", err)
+	}
+
+	if d := a.dialogMgr.GetDialog(callID); d != nil {
+		transferCtx, cancel := context.WithTimeout(ctx, transferRingTimeout)
+		defer cancel()
+		return a.transferDialog(transferCtx, d, false, target)
+	}
+
+	var result *flow.RingResult
+	if target.extension != nil {
+		ringTimeout := target.extension.RingTimeout
+		if ringTimeout <= 0 {
+			ringTimeout = int(transferRingTimeout / time.Second)
+		}
+		result, err = a.RingExtension(ctx, callCtx, target.extension, ringTimeout)
+	} else {
+		result, err = a.ringExternalNumber(ctx, callCtx, target.trunks, target.destination,
+			int(transferRingTimeout/time.Second), callCtx.CallerIDName, callCtx.CallerIDNum, false)
+	}
+	if err != nil {
+		return err
+	}
+	if !result.Answered {
+		return fmt.Errorf("transfer destination %s did not answer", destination)
+	}
+
+	a.recordTransfer(callID, target.destination)
+	if d := a.dialogMgr.GetDialog(callID); d != nil {
+		d.TransferredTo = target.destination
+	}
 	return nil
 }
 
@@ -1059,6 +1388,9 @@ const conferencePINMaxAttempts = 3
 // PINs without waiting for the inter-digit timeout.
 const conferencePINMaxDigits = 10
 
+// conferencePINPrompt is spoken to ask for a conference PIN.
+const conferencePINPrompt = "Please enter the conference PIN, followed by the hash key."
+
 // conferencePINFirstDigitTimeout is the time to wait for the first PIN
 // digit before treating it as a timeout (seconds).
 const conferencePINFirstDigitTimeout = 10
@@ -1092,10 +1424,9 @@ func (a *FlowSIPActions) verifyConferencePIN(ctx context.Context, callCtx *flow.
 		callCtx.ClearDTMF()
 
 		// Collect PIN digits using the standard PlayAndCollect mechanism.
-		// The prompt is a placeholder — audio playback is not yet
-		// implemented (TODO sprint-14), but DTMF collection via SIP INFO
-		// and RFC 2833 works.
-		result, err := a.PlayAndCollect(ctx, callCtx, "conf-pin-entry", false,
+		// The request is spoken when a TTS engine is configured; without
+		// one, digits are collected silently.
+		result, err := a.PlayAndCollect(ctx, callCtx, conferencePINPrompt, true,
 			conferencePINFirstDigitTimeout, conferencePINInterDigitTimeout, conferencePINMaxDigits)
 		if err != nil {
 			return fmt.Errorf("collecting conference pin: %w", err)
@@ -1212,17 +1543,10 @@ func (a *FlowSIPActions) JoinConference(ctx context.Context, callCtx *flow.CallC
 		Port: callerAudio.Port,
 	}
 
-	// Negotiate a G.711 codec (PCMU or PCMA) from the caller's SDP.
-	// The mixer only supports G.711 codecs.
-	payloadType := -1
-	for _, pt := range callerAudio.Formats {
-		if pt == media.PayloadPCMU || pt == media.PayloadPCMA {
-			payloadType = pt
-			break
-		}
-	}
-	if payloadType < 0 {
-		return fmt.Errorf("no supported G.711 codec in caller sdp (need PCMU or PCMA)")
+	// Pick the caller's most preferred codec the mixer can decode.
+	codec, ok := firstLegCodec(callerAudio)
+	if !ok {
+		return fmt.Errorf("no supported codec in caller sdp")
 	}
 
 	// Add participant to the conference room via ConferenceManager.
@@ -1230,17 +1554,22 @@ func (a *FlowSIPActions) JoinConference(ctx context.Context, callCtx *flow.CallC
 		CallerIDName: callCtx.CallerIDName,
 		CallerIDNum:  callCtx.CallerIDNum,
 	}
-	joinResult, err := a.conferenceMgr.Join(ctx, bridge.ID, bridge.Name, bridge.MaxMembers, bridge.AnnounceJoins, bridge.Record, callID, callerRemote, payloadType, joinOpts)
+	joinResult, err := a.conferenceMgr.Join(ctx, bridge.ID, bridge.Name, bridge.MaxMembers, bridge.AnnounceJoins, bridge.Record, callID, callerRemote, codec, joinOpts)
 	if err != nil {
 		return fmt.Errorf("joining conference room: %w", err)
 	}
 
-	// Rewrite the caller's SDP so RTP flows to the mixer's allocated port.
-	rewrittenSDP, err := media.RewriteSDPBytes(sdpBody, a.proxyIP, joinResult.Port)
-	if err != nil {
-		a.conferenceMgr.Leave(bridge.ID, callID)
-		return fmt.Errorf("rewriting sdp for conference: %w", err)
+	// Rewrite the caller's SDP so RTP flows to the mixer's allocated port,
+	// answering with the chosen codec and DTMF only.
+	answer := media.RewriteSDP(callerSD, a.proxyIP, joinResult.Port)
+	if audio := answer.AudioMedia(); audio != nil {
+		keep := []int{codec.PayloadType}
+		if pt, ok := codecPayloadType(audio, "telephone-event"); ok {
+			keep = append(keep, pt)
+		}
+		audio.RetainFormats(keep)
 	}
+	rewrittenSDP := answer.Marshal()
 
 	// Apply mute-on-join if configured.
 	if bridge.MuteOnJoin {
@@ -1261,7 +1590,7 @@ func (a *FlowSIPActions) JoinConference(ctx context.Context, callCtx *flow.CallC
 		"conference", bridge.Name,
 		"conference_id", bridge.ID,
 		"mixer_port", joinResult.Port,
-		"payload_type", payloadType,
+		"codec", codec.String(),
 		"muted", bridge.MuteOnJoin,
 	)
 
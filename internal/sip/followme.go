@@ -51,16 +51,6 @@ func (a *FlowSIPActions) RingFollowMe(ctx context.Context, callCtx *flow.CallCon
 		"confirm", confirm,
 	)
 
-	// Select candidate trunks for outbound dialling.
-	trunks, err := a.outboundRouter.SelectTrunks(ctx)
-	if err != nil {
-		a.logger.Warn("follow-me no trunks available",
-			"call_id", callID,
-			"error", err,
-		)
-		return &flow.RingResult{Answered: false}, nil
-	}
-
 	// Try each follow-me number sequentially.
 	for i, fmNum := range numbers {
 		if ctx.Err() != nil {
@@ -91,8 +81,18 @@ func (a *FlowSIPActions) RingFollowMe(ctx context.Context, callCtx *flow.CallCon
 			ringTimeout = 30
 		}
 
-		// Try to ring this external number via available trunks.
-		result, err := a.ringExternalNumber(ctx, callCtx, trunks, fmNum.Number, ringTimeout, callerIDName, callerIDNum, confirm)
+		// Route the number through the dial plan and ring it via the
+		// route's trunks.
+		plan, err := a.outboundRouter.Route(ctx, fmNum.Number, nil)
+		if err != nil {
+			a.logger.Warn("follow-me external number not routable",
+				"call_id", callID,
+				"number", fmNum.Number,
+				"error", err,
+			)
+			continue
+		}
+		result, err := a.ringExternalNumber(ctx, callCtx, plan.Trunks, plan.Number, ringTimeout, callerIDName, callerIDNum, confirm)
 		if err != nil {
 			a.logger.Warn("follow-me external number failed",
 				"call_id", callID,
@@ -145,7 +145,7 @@ func (a *FlowSIPActions) ringExternalNumber(
 	var trunkSDP []byte
 	if len(req.Body()) > 0 && a.sessionMgr != nil {
 		var err error
-		bridge, trunkSDP, err = AllocateMediaBridge(a.sessionMgr, req.Body(), callID, a.proxyIP, a.logger)
+		bridge, _, err = AllocateMediaBridge(a.sessionMgr, req.Body(), callID, a.proxyIP, SRTPPolicy{}, a.logger)
 		if err != nil {
 			return nil, fmt.Errorf("allocating media bridge: %w", err)
 		}
@@ -177,6 +177,14 @@ func (a *FlowSIPActions) ringExternalNumber(
 			}
 		}
 
+		if bridge != nil {
+			var err error
+			if trunkSDP, err = bridge.CalleeOffer(trunk.SRTPMode); err != nil {
+				outResult = &outboundResult{err: err}
+				continue
+			}
+		}
+
 		outResult = a.sendFollowMeInvite(ringCtx, req, tx, trunk, number, callID, trunkSDP, callerIDName, callerIDNum)
 
 		if ringCtx.Err() != nil {
@@ -327,6 +335,11 @@ func (a *FlowSIPActions) ringExternalNumber(
 			dialog.Caller.FromTag = tag
 		}
 	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
 	if to := outResult.res.To(); to != nil {
 		if tag, ok := to.Params.Get("tag"); ok {
 			dialog.Callee.ToTag = tag
@@ -418,17 +431,40 @@ func (a *FlowSIPActions) confirmExternalLeg(
 
 	conn := session.Session().CalleeLeg.RTPConn
 
+	// The relay is not running yet, so decrypt and encrypt an SRTP leg
+	// here with the keys its answer selected.
+	calleeSRTP, err := acceptSRTPAnswer(calleeAudio, bridge.calleeKeys, bridge.calleeMode)
+	if err != nil {
+		a.logger.Warn("follow-me confirm: callee media not acceptable",
+			"call_id", callID,
+			"number", number,
+			"error", err,
+		)
+		return false
+	}
+	srtpIn, srtpOut, err := legSRTPContexts(calleeSRTP)
+	if err != nil {
+		a.logger.Warn("follow-me confirm: failed to create srtp context",
+			"call_id", callID,
+			"number", number,
+			"error", err,
+		)
+		return false
+	}
+
 	// Create a context with confirmation timeout.
 	confirmCtx, confirmCancel := context.WithTimeout(ctx, confirmTimeout)
 	defer confirmCancel()
 
 	// Start DTMF collector on the callee-leg socket concurrently.
 	collector := media.NewDTMFCollector(conn, a.logger)
+	collector.SRTP = srtpIn
 	go collector.Run(confirmCtx)
 
 	// Play the confirmation prompt to the external leg.
 	promptPath := filepath.Join(a.dataDir, "prompts", "system", "followme_confirm.wav")
 	player := media.NewPlayer(conn, calleeRemote, a.logger)
+	player.SetSRTP(srtpOut)
 	if _, err := player.PlayFile(confirmCtx, promptPath); err != nil {
 		a.logger.Warn("follow-me confirm: failed to play prompt",
 			"call_id", callID,
@@ -513,7 +549,8 @@ func (a *FlowSIPActions) sendFollowMeBYE(inviteReq *sip.Request, inviteRes *sip.
 
 // sendFollowMeInvite builds and sends an INVITE to a trunk for a follow-me
 // external number. This is similar to sendOutboundInvite but adapted for the
-// follow-me context (FlowSIPActions rather than InviteHandler).
+// follow-me context (FlowSIPActions rather than InviteHandler). callerTx may
+// be nil when there is no caller to relay ringing to (e.g. a transfer).
 func (a *FlowSIPActions) sendFollowMeInvite(
 	ctx context.Context,
 	callerReq *sip.Request,
@@ -608,7 +645,7 @@ func (a *FlowSIPActions) sendFollowMeInvite(
 
 		case res.StatusCode == 180 || res.StatusCode == 183:
 			// Relay first provisional response to the caller.
-			if !ringingRelayed {
+			if !ringingRelayed && callerTx != nil {
 				ringingRelayed = true
 				var provBody []byte
 				if res.StatusCode == 183 && len(res.Body()) > 0 {
@@ -733,7 +770,7 @@ func (a *FlowSIPActions) handleFollowMeTrunkAuth(
 			continue
 
 		case res.StatusCode == 180 || res.StatusCode == 183:
-			if !ringingRelayed {
+			if !ringingRelayed && callerTx != nil {
 				ringingRelayed = true
 				var provBody []byte
 				if res.StatusCode == 183 && len(res.Body()) > 0 {
@@ -813,15 +850,27 @@ func (a *FlowSIPActions) RingFollowMeSimultaneous(ctx context.Context, callCtx *
 		"confirm", confirm,
 	)
 
-	// Select candidate trunks for outbound dialling.
-	trunks, err := a.outboundRouter.SelectTrunks(ctx)
-	if err != nil {
-		a.logger.Warn("follow-me no trunks available",
-			"call_id", callID,
-			"error", err,
-		)
+	// Route each number through the dial plan, skipping any that cannot
+	// be routed.
+	plans := make(map[string]*OutboundPlan, len(numbers))
+	var routable []models.FollowMeNumber
+	for _, n := range numbers {
+		plan, err := a.outboundRouter.Route(ctx, n.Number, nil)
+		if err != nil {
+			a.logger.Warn("follow-me external number not routable",
+				"call_id", callID,
+				"number", n.Number,
+				"error", err,
+			)
+			continue
+		}
+		plans[n.Number] = plan
+		routable = append(routable, n)
+	}
+	if len(routable) == 0 {
 		return &flow.RingResult{Answered: false}, nil
 	}
+	numbers = routable
 
 	// Determine the maximum ring timeout across all numbers.
 	maxTimeout := 30
@@ -850,7 +899,7 @@ func (a *FlowSIPActions) RingFollowMeSimultaneous(ctx context.Context, callCtx *
 		wg.Add(1)
 		go func(num models.FollowMeNumber) {
 			defer wg.Done()
-			a.ringFollowMeSimultaneousLeg(ringCtx, callCtx, trunks, num, callerIDName, callerIDNum, resultCh)
+			a.ringFollowMeSimultaneousLeg(ringCtx, callCtx, plans[num.Number], num, callerIDName, callerIDNum, resultCh)
 		}(fmNum)
 	}
 
@@ -1028,6 +1077,11 @@ func (a *FlowSIPActions) RingFollowMeSimultaneous(ctx context.Context, callCtx *
 			dialog.Caller.FromTag = tag
 		}
 	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
 	if to := outResult.res.To(); to != nil {
 		if tag, ok := to.Params.Get("tag"); ok {
 			dialog.Callee.ToTag = tag
@@ -1054,11 +1108,12 @@ func (a *FlowSIPActions) RingFollowMeSimultaneous(ctx context.Context, callCtx *
 
 // ringFollowMeSimultaneousLeg attempts to ring a single external number for
 // a simultaneous follow-me ring. Each leg allocates its own media bridge and
-// tries trunks in priority order. The result is sent to resultCh.
+// tries the trunks of its routed plan in order. The result is sent to
+// resultCh.
 func (a *FlowSIPActions) ringFollowMeSimultaneousLeg(
 	ctx context.Context,
 	callCtx *flow.CallContext,
-	trunks []models.Trunk,
+	plan *OutboundPlan,
 	fmNum models.FollowMeNumber,
 	callerIDName string,
 	callerIDNum string,
@@ -1087,22 +1142,22 @@ func (a *FlowSIPActions) ringFollowMeSimultaneousLeg(
 	var trunkSDP []byte
 	if len(req.Body()) > 0 && a.sessionMgr != nil {
 		var err error
-		bridge, trunkSDP, err = AllocateMediaBridge(a.sessionMgr, req.Body(), callID+"_fm_"+fmNum.Number, a.proxyIP, a.logger)
+		bridge, _, err = AllocateMediaBridge(a.sessionMgr, req.Body(), callID+"_fm_"+fmNum.Number, a.proxyIP, SRTPPolicy{}, a.logger)
 		if err != nil {
 			resultCh <- followMeLegResult{number: fmNum.Number, err: fmt.Errorf("allocating media bridge: %w", err)}
 			return
 		}
 	}
 
-	// Try each trunk in priority order.
+	// Try each of the route's trunks in order.
 	var outResult *outboundResult
 	var selectedTrunk *models.Trunk
-	for i := range trunks {
+	for i := range plan.Trunks {
 		if legCtx.Err() != nil {
 			break
 		}
 
-		trunk := &trunks[i]
+		trunk := &plan.Trunks[i]
 
 		// Enforce max_channels.
 		if trunk.MaxChannels > 0 {
@@ -1112,7 +1167,15 @@ func (a *FlowSIPActions) ringFollowMeSimultaneousLeg(
 			}
 		}
 
-		outResult = a.sendFollowMeInvite(legCtx, req, callCtx.Transaction, trunk, fmNum.Number, callID, trunkSDP, callerIDName, callerIDNum)
+		if bridge != nil {
+			var err error
+			if trunkSDP, err = bridge.CalleeOffer(trunk.SRTPMode); err != nil {
+				outResult = &outboundResult{err: err}
+				continue
+			}
+		}
+
+		outResult = a.sendFollowMeInvite(legCtx, req, callCtx.Transaction, trunk, plan.Number, callID, trunkSDP, callerIDName, callerIDNum)
 
 		if legCtx.Err() != nil {
 			break
@@ -101,6 +101,9 @@ func (f *Forker) Client() *sipgo.Client {
 // inbound server transaction (callerTx). The first 200 OK from any fork wins;
 // all other forks are immediately cancelled via CANCEL.
 //
+// callerTx may be nil to suppress relaying provisional responses, e.g. when
+// the caller is already hearing early media played by the proxy.
+//
 // If sdpBody is non-nil, it overrides the SDP body from the incoming INVITE
 // for all forked legs. This is used by the media proxy to rewrite SDP so that
 // the callee's RTP is directed to the proxy rather than the caller directly.
@@ -218,7 +221,7 @@ func (f *Forker) Fork(
 			// Only the first provisional is relayed to avoid confusing the caller UA
 			// with multiple provisional SDP offers.
 			receivedProvisional = true
-			if !ringingRelayed {
+			if !ringingRelayed && callerTx != nil {
 				ringingRelayed = true
 
 				// Include the SDP body for 183 early media; 180 typically has no body.
@@ -0,0 +1,436 @@
+package sip
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// defaultHoldMusicFile is the music played to a held party without a
+// music on hold class, relative to the data directory.
+const defaultHoldMusicFile = "prompts/system/queue_hold_music.wav"
+
+// legMedia is the media state of one side of a dialog that changes after
+// the call is set up. Guarded by Dialog.mediaMu.
+type legMedia struct {
+	// sdp is the latest session description the leg's device offered in
+	// a re-INVITE.
+	sdp []byte
+
+	// answers counts the SDP answers sent to the leg in response to
+	// re-INVITEs; it advances the o= session version of each answer.
+	answers int
+
+	// moh is the hold music being played to this leg, if it is held.
+	moh *holdMusic
+}
+
+// holdMusic is music on hold being streamed to a held party.
+type holdMusic struct {
+	cancel context.CancelFunc
+}
+
+// legMedia returns the media state of one side. Must be called with
+// d.mediaMu held.
+func (d *Dialog) legMedia(callerSide bool) *legMedia {
+	if callerSide {
+		return &d.callerMedia
+	}
+	return &d.calleeMedia
+}
+
+// resetLegMedia clears a side's media state when its leg is replaced. Any
+// hold ends with it, since either the holding or the held party is gone.
+func (d *Dialog) resetLegMedia(callerSide bool) {
+	d.stopHoldMusic()
+	d.mediaMu.Lock()
+	*d.legMedia(callerSide) = legMedia{}
+	d.mediaMu.Unlock()
+}
+
+// stopHoldMusic stops hold music to both sides of the dialog.
+func (d *Dialog) stopHoldMusic() {
+	d.stopHoldMusicTo(true)
+	d.stopHoldMusicTo(false)
+}
+
+// stopHoldMusicTo stops hold music to one side and resumes relaying media
+// to it. Returns false if that side was not held.
+func (d *Dialog) stopHoldMusicTo(callerSide bool) bool {
+	d.mediaMu.Lock()
+	lm := d.legMedia(callerSide)
+	moh := lm.moh
+	lm.moh = nil
+	d.mediaMu.Unlock()
+
+	if moh == nil {
+		return false
+	}
+	moh.cancel()
+	if d.Media != nil {
+		setLegHeld(d.Media, callerSide, false)
+	}
+	return true
+}
+
+// setLegHeld starts or stops suppressing relayed media to one side of a
+// media session.
+func setLegHeld(ms *media.MediaSession, callerSide bool, held bool) error {
+	if callerSide {
+		return ms.SetCallerHeld(held)
+	}
+	return ms.SetCalleeHeld(held)
+}
+
+// handleReInvite processes an INVITE within an established dialog: hold
+// (a=sendonly, a=inactive or c=0.0.0.0), resume, and RTP address changes.
+// The re-INVITE is answered by the PBX itself rather than relayed: the
+// offering leg's relay endpoint is updated, and while the other party is
+// held the relay stops forwarding to it and plays hold music instead. The
+// answer keeps the leg's negotiated codec if the offer still has it;
+// otherwise the leg moves to an offered codec and the relay transcodes
+// between it and the other party's.
+func (h *InviteHandler) handleReInvite(req *sip.Request, tx sip.ServerTransaction, callID string) {
+	_, fromTag := requestDialogID(req)
+
+	d := h.dialogMgr.FindDialog(callID, fromTag)
+	if d == nil || h.dialogMgr.IsRetiredLeg(callID, fromTag) {
+		h.logger.Warn("re-invite for unknown dialog",
+			"call_id", callID,
+		)
+		h.respondError(req, tx, 481, "Call/Transaction Does Not Exist")
+		return
+	}
+	if d.Media == nil {
+		h.respondError(req, tx, 488, "Not Acceptable Here")
+		return
+	}
+
+	fromCaller := d.IsCallerRequest(req)
+
+	// The party the offerer is talking to. After an attended transfer that
+	// is the remaining party on the joined call.
+	held, heldCaller := d, !fromCaller
+	if d.Peer != nil {
+		held, heldCaller = d.Peer, d.Peer.peerCallerRemains
+	}
+
+	port := d.Media.CalleeRTPPort()
+	if fromCaller {
+		port = d.Media.CallerRTPPort()
+	}
+
+	heldSD, err := media.ParseSDP(held.leg(heldCaller).remoteSDP())
+	if err != nil {
+		h.logger.Error("failed to parse held party sdp",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondError(req, tx, 500, "Internal Server Error")
+		return
+	}
+
+	// An offerless re-INVITE asks for a fresh offer: send the current
+	// session unchanged and ignore the answer in the ACK.
+	if len(req.Body()) == 0 {
+		reoffer := media.RewriteSDP(heldSD, h.proxyIP, port)
+		if audio := reoffer.AudioMedia(); audio != nil {
+			// Offer the leg's own key, not the held party's.
+			offerLegSRTP(audio, d.Media.SRTP(fromCaller))
+		}
+		h.respondReInvite(req, tx, d, fromCaller, reoffer)
+		return
+	}
+
+	offer, err := media.ParseSDP(req.Body())
+	if err != nil || offer.AudioMedia() == nil {
+		h.logger.Warn("invalid re-invite sdp",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondError(req, tx, 488, "Not Acceptable Here")
+		return
+	}
+	offerAudio := offer.AudioMedia()
+
+	// The offer may rekey an SRTP leg but not switch its encryption on
+	// or off.
+	legSRTP, err := rekeySRTP(offerAudio, d.Media.SRTP(fromCaller))
+	if err != nil {
+		h.logger.Warn("re-invite srtp not acceptable",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondError(req, tx, 488, "Not Acceptable Here")
+		return
+	}
+
+	// The other party is not renegotiated: it keeps sending its codec.
+	answer := media.RewriteSDP(heldSD, h.proxyIP, port)
+	if codec := negotiatedCodec(d, fromCaller); codec != "" {
+		offerPT, ok := codecPayloadType(offerAudio, codec)
+		if !ok {
+			// The offer drops the leg's codec: move the leg to one it
+			// offers and transcode to the other party's.
+			lc, ok := reInviteCodec(offerAudio, negotiatedCodec(d, !fromCaller))
+			if !ok {
+				h.logger.Warn("re-invite offers no codec the relay can transcode",
+					"call_id", callID,
+					"codec", codec,
+				)
+				h.respondError(req, tx, 488, "Not Acceptable Here")
+				return
+			}
+			if err := d.Media.SetLegCodec(fromCaller, lc); err != nil {
+				h.logger.Warn("re-invite codec change not possible",
+					"call_id", callID,
+					"codec", codec,
+					"new_codec", lc.String(),
+					"error", err,
+				)
+				h.respondError(req, tx, 488, "Not Acceptable Here")
+				return
+			}
+			answerWithCodec(answer, sdpCodec(offerAudio, lc))
+		} else if audio := answer.AudioMedia(); audio != nil {
+			if pt, ok := codecPayloadType(audio, codec); ok {
+				keep := []int{pt}
+				if pt, ok := codecPayloadType(audio, "telephone-event"); ok {
+					keep = append(keep, pt)
+				}
+				audio.RetainFormats(keep)
+			} else if lc, ok := legCodec(offerAudio, offerPT); ok {
+				// The relay transcodes, so the other party's SDP does
+				// not have the offerer's codec: answer with the offer's.
+				answerWithCodec(answer, sdpCodec(offerAudio, lc))
+			}
+		}
+	}
+	if audio := answer.AudioMedia(); audio != nil {
+		audio.SetDirection(media.AnswerDirection(offerAudio.Direction))
+		setAnswerSRTP(audio, offerAudio, legSRTP)
+	}
+	if legSRTP != nil {
+		if err := d.Media.SetLegSRTP(fromCaller, legSRTP); err != nil {
+			h.logger.Error("failed to rekey srtp",
+				"call_id", callID,
+				"error", err,
+			)
+			h.respondError(req, tx, 500, "Internal Server Error")
+			return
+		}
+	}
+
+	// Follow the offerer to a new RTP address. The RFC 2543 hold address
+	// 0.0.0.0 is not a real endpoint and is ignored.
+	if addr, err := extractRTPAddr(offer); err == nil && !addr.IP.IsUnspecified() {
+		prev := d.leg(fromCaller).remoteSDP()
+		if prevSD, err := media.ParseSDP(prev); err == nil {
+			if prevAddr, err := extractRTPAddr(prevSD); err != nil || !prevAddr.IP.Equal(addr.IP) || prevAddr.Port != addr.Port {
+				if err := setLegRemote(d.Media, fromCaller, addr); err != nil {
+					h.logger.Error("failed to update relay endpoint",
+						"call_id", callID,
+						"error", err,
+					)
+				}
+			}
+		}
+	}
+
+	d.mediaMu.Lock()
+	d.legMedia(fromCaller).sdp = req.Body()
+	d.mediaMu.Unlock()
+
+	switch {
+	case d.vacant(!fromCaller):
+		// A parked party has no one to hold; it hears park music until
+		// the call is retrieved.
+	case offer.IsHold():
+		holder := d.Callee.Extension
+		if fromCaller {
+			holder = d.Caller.Extension
+		}
+		h.startHoldMusic(held, heldCaller, holder)
+	case held.stopHoldMusicTo(heldCaller):
+		h.logger.Info("call resumed",
+			"call_id", callID,
+			"caller_resumed", fromCaller,
+		)
+	}
+
+	h.respondReInvite(req, tx, d, fromCaller, answer)
+}
+
+// respondReInvite sends the 200 OK to a re-INVITE with the given SDP,
+// advancing its session version past the previous answer on the leg.
+func (h *InviteHandler) respondReInvite(req *sip.Request, tx sip.ServerTransaction, d *Dialog, fromCaller bool, answer *media.SessionDescription) {
+	d.mediaMu.Lock()
+	lm := d.legMedia(fromCaller)
+	lm.answers++
+	answers := lm.answers
+	d.mediaMu.Unlock()
+
+	if v, err := strconv.ParseUint(answer.Origin.SessionVersion, 10, 64); err == nil {
+		answer.Origin.SessionVersion = strconv.FormatUint(v+uint64(answers), 10)
+	}
+
+	res := sip.NewResponseFromRequest(req, 200, "OK", answer.Marshal())
+	res.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+	if err := tx.Respond(res); err != nil {
+		h.logger.Error("failed to respond to re-invite",
+			"call_id", d.CallID,
+			"error", err,
+		)
+	}
+}
+
+// startHoldMusic stops relaying media to one side of d and plays it hold
+// music until stopHoldMusicTo is called or the call ends. holder is the
+// extension that put it on hold, if any.
+func (h *InviteHandler) startHoldMusic(d *Dialog, callerSide bool, holder *models.Extension) {
+	playHoldMusic(d, callerSide, h.flowActions.moh, holder, h.logger)
+}
+
+// playHoldMusic stops relaying media to one side of d and plays it the
+// music on hold chosen for holder through a media.Player on the leg's
+// relay socket. Music to a side whose codec is not G.711 is transcoded to
+// it; a side whose codec the media layer does not know is held in silence.
+func playHoldMusic(d *Dialog, callerSide bool, moh *MOHManager, holder *models.Extension, logger *slog.Logger) {
+	d.mediaMu.Lock()
+	lm := d.legMedia(callerSide)
+	if lm.moh != nil {
+		d.mediaMu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	lm.moh = &holdMusic{cancel: cancel}
+	d.mediaMu.Unlock()
+
+	if err := setLegHeld(d.Media, callerSide, true); err != nil {
+		logger.Error("failed to hold media",
+			"call_id", d.CallID,
+			"error", err,
+		)
+		d.stopHoldMusicTo(callerSide)
+		return
+	}
+
+	logger.Info("call on hold",
+		"call_id", d.CallID,
+		"caller_held", callerSide,
+	)
+
+	sess := d.Media.Session()
+	conn, remote := sess.CalleeLeg.RTPConn, d.Media.CalleeAddr()
+	if callerSide {
+		conn, remote = sess.CallerLeg.RTPConn, d.Media.CallerAddr()
+	}
+	if remote == nil {
+		return
+	}
+
+	player := media.NewPlayer(conn, remote, logger)
+	player.SetSRTP(d.Media.OutboundSRTP(callerSide))
+	switch strings.ToUpper(negotiatedCodec(d, callerSide)) {
+	case "PCMU":
+		player.SetPayloadType(media.PayloadPCMU)
+	case "PCMA":
+		player.SetPayloadType(media.PayloadPCMA)
+	default:
+		caller, callee, ok := d.Media.Codecs()
+		codec := callee
+		if callerSide {
+			codec = caller
+		}
+		if !ok || player.SetCodec(codec) != nil {
+			logger.Warn("hold music cannot be encoded in the leg's codec, holding in silence",
+				"call_id", d.CallID,
+				"codec", codec.String(),
+			)
+			return
+		}
+	}
+
+	go func() {
+		class := moh.dialogClass(ctx, d, holder)
+		l := moh.Listen(ctx, class, filepath.Join(moh.dataDir, defaultHoldMusicFile))
+		defer l.Close()
+
+		_, err := player.PlayStream(ctx, l)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("hold music failed, holding in silence",
+			"call_id", d.CallID,
+			"error", err,
+		)
+	}()
+}
+
+// negotiatedCodec returns the name of the audio codec one side of the call
+// sends and receives, or "" if it cannot be determined. Without transcoding
+// both sides share the codec, and the relay's payload types are those of
+// the callee's SDP answer.
+func negotiatedCodec(d *Dialog, callerSide bool) string {
+	if d.Media == nil {
+		return ""
+	}
+	if caller, callee, ok := d.Media.Codecs(); ok {
+		if callerSide {
+			return caller.Codec.Name
+		}
+		return callee.Codec.Name
+	}
+	sd, err := media.ParseSDP(d.leg(false).remoteSDP())
+	if err != nil || sd.AudioMedia() == nil {
+		return ""
+	}
+	audio := sd.AudioMedia()
+	for _, pt := range d.Media.PayloadTypes() {
+		if pt == media.PayloadTelephoneEvent {
+			continue
+		}
+		if c := audio.CodecByPayloadType(pt); c != nil && c.Name != "" {
+			return c.Name
+		}
+		if name := staticPTName(pt); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// reInviteCodec picks the codec for a leg whose re-INVITE offer drops its
+// negotiated codec: other, the codec of the other party, if offered, so
+// the relay need not transcode; otherwise the first offered codec the
+// media layer can transcode.
+func reInviteCodec(offer *media.MediaDescription, other string) (media.LegCodec, bool) {
+	if other != "" {
+		if pt, ok := codecPayloadType(offer, other); ok {
+			if lc, ok := legCodec(offer, pt); ok {
+				return lc, true
+			}
+		}
+	}
+	return firstLegCodec(offer)
+}
+
+// codecPayloadType returns the payload type a media description uses for
+// the named codec, including static payload types listed without rtpmap.
+func codecPayloadType(m *media.MediaDescription, name string) (int, bool) {
+	if c := m.CodecByName(name); c != nil {
+		return c.PayloadType, true
+	}
+	for _, pt := range m.Formats {
+		if strings.EqualFold(staticPTName(pt), name) {
+			return pt, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,100 @@
+package sip
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+func TestCodecPayloadType(t *testing.T) {
+	sd, err := media.ParseSDP([]byte("v=0\r\n" +
+		"o=- 1 1 IN IP4 10.0.0.1\r\n" +
+		"s=-\r\n" +
+		"c=IN IP4 10.0.0.1\r\n" +
+		"t=0 0\r\n" +
+		"m=audio 5004 RTP/AVP 8 0 96\r\n" +
+		"a=rtpmap:96 telephone-event/8000\r\n"))
+	if err != nil {
+		t.Fatalf("ParseSDP: %v", err)
+	}
+	audio := sd.AudioMedia()
+
+	tests := []struct {
+		name   string
+		wantPT int
+		wantOK bool
+	}{
+		{"PCMA", 8, true},
+		{"pcmu", 0, true},
+		{"telephone-event", 96, true},
+		{"opus", 0, false},
+	}
+	for _, tt := range tests {
+		pt, ok := codecPayloadType(audio, tt.name)
+		if ok != tt.wantOK || (ok && pt != tt.wantPT) {
+			t.Errorf("codecPayloadType(%q) = %d, %v; want %d, %v", tt.name, pt, ok, tt.wantPT, tt.wantOK)
+		}
+	}
+}
+
+func TestReInviteCodec(t *testing.T) {
+	audio := testAudioSDP(t, "m=audio 5004 RTP/AVP 18 9 8 101",
+		"rtpmap:101 telephone-event/8000",
+	).AudioMedia()
+
+	tests := []struct {
+		other  string
+		wantPT int
+	}{
+		{"PCMA", 8}, // the other party's codec needs no transcoding
+		{"PCMU", 9}, // otherwise the first codec the relay can transcode
+		{"", 9},
+	}
+	for _, tt := range tests {
+		lc, ok := reInviteCodec(audio, tt.other)
+		if !ok || lc.PayloadType != tt.wantPT {
+			t.Errorf("reInviteCodec(%q) = %s, %v; want payload type %d", tt.other, lc, ok, tt.wantPT)
+		}
+	}
+
+	unknown := testAudioSDP(t, "m=audio 5004 RTP/AVP 18 101",
+		"rtpmap:101 telephone-event/8000",
+	).AudioMedia()
+	if lc, ok := reInviteCodec(unknown, "PCMU"); ok {
+		t.Errorf("reInviteCodec chose %s from an offer without a known codec", lc)
+	}
+}
+
+func TestDialogLegMediaOverride(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+	d := newTestDialog(dm)
+
+	reinvite := []byte("v=0\r\nm=audio 6000 RTP/AVP 0\r\n")
+	d.mediaMu.Lock()
+	d.legMedia(false).sdp = reinvite
+	d.legMedia(false).moh = &holdMusic{cancel: func() {}}
+	d.mediaMu.Unlock()
+
+	if got := string(d.leg(false).remoteSDP()); got != string(reinvite) {
+		t.Errorf("callee remote sdp = %q, want re-INVITE offer", got)
+	}
+	if d.leg(true).sdp != nil {
+		t.Error("caller leg should not see the callee's re-INVITE sdp")
+	}
+
+	// Replacing the callee leg ends the hold and forgets its SDP.
+	newReq := newTestDialogRequest("target-call", "101", "pbx-tag-2", "103")
+	leg := CallLeg{CallID: "target-call", FromTag: "pbx-tag-2", ToTag: "target-tag"}
+	if !dm.ReplaceLeg(d, false, leg, newReq, newTestAnswer(newReq, "target-tag")) {
+		t.Fatal("expected ReplaceLeg to succeed")
+	}
+	if d.calleeMedia.moh != nil || d.calleeMedia.sdp != nil {
+		t.Errorf("callee media state not reset: %+v", d.calleeMedia)
+	}
+	if d.stopHoldMusicTo(false) {
+		t.Error("expected no hold music after leg replacement")
+	}
+}
@@ -25,6 +25,32 @@ const (
 	CallTypeInbound CallType = "inbound"
 	// CallTypeOutbound is a call from a local extension to an external number via trunk.
 	CallTypeOutbound CallType = "outbound"
+	// CallTypePickup is a local extension dialling a pickup feature code to
+	// answer a call ringing elsewhere.
+	CallTypePickup CallType = "pickup"
+	// CallTypeVoicemail is a local extension dialling a voicemail feature
+	// code to listen to messages.
+	CallTypeVoicemail CallType = "voicemail"
+	// CallTypeSupervise is a supervisor dialling a supervise feature code
+	// to monitor, whisper to or barge into another extension's call.
+	CallTypeSupervise CallType = "supervise"
+	// CallTypeBlockCaller is a local extension dialling the block feature
+	// code to add the last caller that rang it to the blocklist.
+	CallTypeBlockCaller CallType = "block_caller"
+	// CallTypePark is a local extension dialling a park code or the park
+	// feature code to park the call it has on hold.
+	CallTypePark CallType = "park"
+	// CallTypeParkRetrieve is a local extension dialling a park orbit to
+	// take the call parked there.
+	CallTypeParkRetrieve CallType = "park_retrieve"
+	// CallTypeOriginate is a call the PBX places on an extension's behalf
+	// from the API (click-to-call): the extension is rung first, then the
+	// destination.
+	CallTypeOriginate CallType = "originate"
+	// CallTypeFlow is a call the PBX places to one of its own call flows
+	// for a call it has already answered, such as an originated call to a
+	// flow entry point or a parked call that timed out. See dialLocalFlow.
+	CallTypeFlow CallType = "flow"
 )
 
 // InviteContext holds the classified information about an incoming INVITE.
@@ -43,6 +69,29 @@ type InviteContext struct {
 	// InboundNumber is set when an inbound call matches a DID.
 	InboundNumber *models.InboundNumber
 
+	// PickupExtension is the extension whose ringing call a directed
+	// pickup (**<ext>) answers. Empty for group pickup (*8).
+	PickupExtension string
+
+	// VoicemailOwn is set when a voicemail feature code (*97) asks for the
+	// caller's own mailbox rather than prompting for one (*98).
+	VoicemailOwn bool
+
+	// SuperviseMode and SuperviseExtension are set for a supervise feature
+	// code: how the supervisor joins the call of which extension.
+	SuperviseMode      SuperviseMode
+	SuperviseExtension string
+
+	// ParkLot is the park lot of a park code or orbit, and ParkOrbit the
+	// orbit dialled to retrieve a parked call.
+	ParkLot   *models.ParkLot
+	ParkOrbit string
+
+	// FlowID and FlowNode are the call flow and node a local flow call
+	// enters.
+	FlowID   int64
+	FlowNode string
+
 	// RequestURI is the user part of the Request-URI (the dialed number/extension).
 	RequestURI string
 
@@ -63,7 +112,9 @@ type InviteHandler struct {
 	registrations  database.RegistrationRepository
 	pushTokens     database.PushTokenRepository
 	inboundNumbers database.InboundNumberRepository
+	callerFilters  database.CallerFilterRepository
 	trunks         database.TrunkRepository
+	ringGroups     database.RingGroupRepository
 	trunkRegistrar *TrunkRegistrar
 	auth           *Authenticator
 	router         *CallRouter
@@ -76,6 +127,7 @@ type InviteHandler struct {
 	systemConfig   database.SystemConfigRepository
 	flowEngine     *flow.Engine
 	flowActions    *FlowSIPActions
+	parkMgr        *ParkManager
 	pushClient     *push.Client
 	regNotifier    *RegistrationNotifier
 	proxyIP        string
@@ -89,7 +141,9 @@ func NewInviteHandler(
 	registrations database.RegistrationRepository,
 	pushTokens database.PushTokenRepository,
 	inboundNumbers database.InboundNumberRepository,
+	callerFilters database.CallerFilterRepository,
 	trunks database.TrunkRepository,
+	ringGroups database.RingGroupRepository,
 	trunkRegistrar *TrunkRegistrar,
 	auth *Authenticator,
 	outboundRouter *OutboundRouter,
@@ -101,6 +155,7 @@ func NewInviteHandler(
 	sysConfig database.SystemConfigRepository,
 	flowEngine *flow.Engine,
 	flowActions *FlowSIPActions,
+	parkMgr *ParkManager,
 	pushClient *push.Client,
 	regNotifier *RegistrationNotifier,
 	proxyIP string,
@@ -113,7 +168,9 @@ func NewInviteHandler(
 		registrations:  registrations,
 		pushTokens:     pushTokens,
 		inboundNumbers: inboundNumbers,
+		callerFilters:  callerFilters,
 		trunks:         trunks,
+		ringGroups:     ringGroups,
 		trunkRegistrar: trunkRegistrar,
 		auth:           auth,
 		router:         router,
@@ -126,6 +183,7 @@ func NewInviteHandler(
 		systemConfig:   sysConfig,
 		flowEngine:     flowEngine,
 		flowActions:    flowActions,
+		parkMgr:        parkMgr,
 		pushClient:     pushClient,
 		regNotifier:    regNotifier,
 		proxyIP:        proxyIP,
@@ -158,6 +216,13 @@ func (h *InviteHandler) HandleInvite(req *sip.Request, tx sip.ServerTransaction)
 		return
 	}
 
+	// A To tag means the INVITE is within an established dialog: hold,
+	// resume, or a media change.
+	if tag, ok := req.To().Params.Get("tag"); ok && tag != "" {
+		h.handleReInvite(req, tx, callID)
+		return
+	}
+
 	// Classify the call type.
 	ic, err := h.classifyCall(req, tx)
 	if err != nil {
@@ -182,17 +247,55 @@ func (h *InviteHandler) HandleInvite(req *sip.Request, tx sip.ServerTransaction)
 		"trunk_id", ic.TrunkID,
 	)
 
+	// Refuse up front a call this build cannot play media to, rather
+	// than failing once it is answered.
+	if opusOnlyOffer(req.Body()) {
+		h.logger.Warn("refusing opus-only offer, opus is not compiled in",
+			"call_id", callID,
+		)
+		res := sip.NewResponseFromRequest(req, 488, "Not Acceptable Here", nil)
+		res.AppendHeader(sip.NewHeader("Warning", `305 flowpbx "Opus is not supported by this build"`))
+		if err := tx.Respond(res); err != nil {
+			h.logger.Error("failed to send error response",
+				"call_id", callID,
+				"error", err,
+			)
+		}
+		return
+	}
+
+	// A pickup or park retrieval answers a call that already has a CDR;
+	// it gets none of its own.
+	switch ic.CallType {
+	case CallTypePickup:
+		h.handlePickup(req, tx, ic, callID)
+		return
+	case CallTypeParkRetrieve:
+		h.handleParkRetrieve(req, tx, ic, callID)
+		return
+	}
+
 	// Create CDR at call start with initial fields.
 	h.createInitialCDR(ic, callID)
 
 	// Dispatch to call routing based on call type.
 	switch ic.CallType {
+	case CallTypeVoicemail:
+		h.handleVoicemailCall(req, tx, ic, callID)
+	case CallTypeSupervise:
+		h.handleSupervise(req, tx, ic, callID)
+	case CallTypeBlockCaller:
+		h.handleBlockLastCaller(req, tx, ic, callID)
+	case CallTypePark:
+		h.handlePark(req, tx, ic, callID)
 	case CallTypeInternal:
 		h.handleInternalCall(req, tx, ic, callID)
 	case CallTypeInbound:
 		h.handleInboundCall(req, tx, ic, callID)
 	case CallTypeOutbound:
 		h.handleOutboundCall(req, tx, ic, callID)
+	case CallTypeFlow:
+		h.handleFlowCall(req, tx, ic, callID)
 	default:
 		h.logger.Error("unknown call type",
 			"call_id", callID,
@@ -274,13 +377,15 @@ func (h *InviteHandler) handleInternalCall(req *sip.Request, tx sip.ServerTransa
 	var calleeSDP []byte
 	if len(req.Body()) > 0 && h.sessionMgr != nil {
 		var err error
-		bridge, calleeSDP, err = AllocateMediaBridge(h.sessionMgr, req.Body(), callID, h.proxyIP, h.logger)
+		policy := SRTPPolicy{Caller: ic.CallerExtension.SRTPMode, Callee: route.TargetExtension.SRTPMode}
+		bridge, calleeSDP, err = AllocateMediaBridge(h.sessionMgr, req.Body(), callID, h.proxyIP, policy, h.logger)
 		if err != nil {
 			h.logger.Error("failed to allocate media bridge",
 				"call_id", callID,
 				"error", err,
 			)
-			h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
+			code, reason := mediaErrorStatus(err)
+			h.respondErrorWithCDR(req, tx, code, reason, callID)
 			return
 		}
 	}
@@ -308,6 +413,7 @@ func (h *InviteHandler) handleInternalCall(req *sip.Request, tx sip.ServerTransa
 		CallerReq:  req,
 		CancelFork: cancelFork,
 		Bridge:     bridge,
+		Extensions: extensionNumbers(ic.CallerExtension, route.TargetExtension),
 	})
 
 	// Fork INVITE to all registered contacts (multi-device ringing).
@@ -475,6 +581,11 @@ func (h *InviteHandler) handleInternalCall(req *sip.Request, tx sip.ServerTransa
 			dialog.Caller.FromTag = tag
 		}
 	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
 	if to := result.AnswerResponse.To(); to != nil {
 		if tag, ok := to.Params.Get("tag"); ok {
 			dialog.Callee.ToTag = tag
@@ -493,7 +604,7 @@ func (h *InviteHandler) handleInternalCall(req *sip.Request, tx sip.ServerTransa
 	}
 
 	h.dialogMgr.CreateDialog(dialog)
-	h.updateCDROnAnswer(callID, 0)
+	h.updateCDROnAnswer(callID, 0, nil)
 
 	h.logger.Info("call dialog established",
 		"call_id", callID,
@@ -548,6 +659,12 @@ func (h *InviteHandler) handleInboundCall(req *sip.Request, tx sip.ServerTransac
 		}
 	}
 
+	// Screen the caller against the blocklist and allowlist before the
+	// call reaches a flow or extension.
+	if h.screenInboundCall(req, tx, ic, callID) {
+		return
+	}
+
 	// If the inbound number matched a DID but we have no target extension,
 	// that means the DID is mapped to a flow. Spawn the flow engine.
 	if ic.TargetExtension == nil && ic.InboundNumber != nil {
@@ -568,29 +685,8 @@ func (h *InviteHandler) handleInboundCall(req *sip.Request, tx sip.ServerTransac
 			"entry_node", ic.InboundNumber.FlowEntryNode,
 		)
 
-		callCtx := flow.NewCallContext(
-			callID,
-			ic.CallerIDName,
-			ic.CallerIDNum,
-			ic.RequestURI,
-			ic.InboundNumber,
-			ic.TrunkID,
-			req,
-			tx,
-		)
-
-		// Execute the flow synchronously so the SIP server transaction
-		// stays alive for the duration of the call.
-		if err := h.flowEngine.ExecuteFlow(callCtx, *ic.InboundNumber.FlowID, ic.InboundNumber.FlowEntryNode); err != nil {
-			h.logger.Error("flow execution failed",
-				"call_id", callID,
-				"flow_id", *ic.InboundNumber.FlowID,
-				"error", err,
-			)
-			h.finalizeCDRFailed(callID, 500)
-		} else {
-			h.finalizeCDRFailed(callID, 200)
-		}
+		callCtx := h.newInboundCallContext(req, tx, ic, callID)
+		h.executeInboundFlow(callCtx, *ic.InboundNumber.FlowID, ic.InboundNumber.FlowEntryNode)
 		return
 	}
 
@@ -673,13 +769,18 @@ func (h *InviteHandler) handleInboundCall(req *sip.Request, tx sip.ServerTransac
 	var calleeSDP []byte
 	if len(req.Body()) > 0 && h.sessionMgr != nil {
 		var err error
-		bridge, calleeSDP, err = AllocateMediaBridge(h.sessionMgr, req.Body(), callID, h.proxyIP, h.logger)
+		policy := SRTPPolicy{Callee: route.TargetExtension.SRTPMode}
+		if inboundTrunk != nil {
+			policy.Caller = inboundTrunk.SRTPMode
+		}
+		bridge, calleeSDP, err = AllocateMediaBridge(h.sessionMgr, req.Body(), callID, h.proxyIP, policy, h.logger)
 		if err != nil {
 			h.logger.Error("failed to allocate media bridge",
 				"call_id", callID,
 				"error", err,
 			)
-			h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
+			code, reason := mediaErrorStatus(err)
+			h.respondErrorWithCDR(req, tx, code, reason, callID)
 			return
 		}
 	}
@@ -704,6 +805,7 @@ func (h *InviteHandler) handleInboundCall(req *sip.Request, tx sip.ServerTransac
 		CallerReq:  req,
 		CancelFork: cancelFork,
 		Bridge:     bridge,
+		Extensions: extensionNumbers(route.TargetExtension),
 	})
 
 	// Fork INVITE to all registered contacts.
@@ -851,6 +953,11 @@ func (h *InviteHandler) handleInboundCall(req *sip.Request, tx sip.ServerTransac
 			dialog.Caller.FromTag = tag
 		}
 	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
 	if to := result.AnswerResponse.To(); to != nil {
 		if tag, ok := to.Params.Get("tag"); ok {
 			dialog.Callee.ToTag = tag
@@ -869,7 +976,7 @@ func (h *InviteHandler) handleInboundCall(req *sip.Request, tx sip.ServerTransac
 	}
 
 	h.dialogMgr.CreateDialog(dialog)
-	h.updateCDROnAnswer(callID, ic.TrunkID)
+	h.updateCDROnAnswer(callID, ic.TrunkID, nil)
 
 	h.logger.Info("inbound call dialog established",
 		"call_id", callID,
@@ -882,12 +989,57 @@ func (h *InviteHandler) handleInboundCall(req *sip.Request, tx sip.ServerTransac
 	)
 }
 
-// classifyCall determines whether the INVITE is internal, inbound, or outbound.
+// newInboundCallContext creates the flow call context for an inbound call.
+func (h *InviteHandler) newInboundCallContext(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string) *flow.CallContext {
+	return flow.NewCallContext(
+		callID,
+		ic.CallerIDName,
+		ic.CallerIDNum,
+		ic.RequestURI,
+		ic.InboundNumber,
+		ic.TrunkID,
+		req,
+		tx,
+	)
+}
+
+// executeInboundFlow runs an inbound call through a call flow from
+// entryNode and finalizes its CDR once the flow completes.
+func (h *InviteHandler) executeInboundFlow(callCtx *flow.CallContext, flowID int64, entryNode string) {
+	callID := callCtx.CallID
+
+	// Execute the flow synchronously so the SIP server transaction
+	// stays alive for the duration of the call.
+	if err := h.flowEngine.ExecuteFlow(callCtx, flowID, entryNode); err != nil {
+		h.logger.Error("flow execution failed",
+			"call_id", callID,
+			"flow_id", flowID,
+			"error", err,
+		)
+		h.finalizeCDRFailed(callID, 500)
+	} else {
+		h.finalizeCDRFailed(callID, 200)
+	}
+
+	// Tear down any early media (e.g. queue hold music) that was not
+	// handed over to an answered leg.
+	if h.flowActions != nil {
+		h.flowActions.releaseEarlyMedia(callID)
+	}
+}
+
+// classifyCall determines whether the INVITE is internal, inbound, outbound,
+// a call pickup, voicemail retrieval, call supervision or call parking.
 // Returns nil InviteContext (without error) if classifyCall already sent a SIP
 // response (auth challenge, rejection, etc.).
 func (h *InviteHandler) classifyCall(req *sip.Request, tx sip.ServerTransaction) (*InviteContext, error) {
 	ctx := context.Background()
 
+	// Step 0: An INVITE the PBX sent itself to run a call flow.
+	if ic := h.classifyLocalFlowCall(req); ic != nil {
+		return ic, nil
+	}
+
 	sourceIP := sourceHost(req)
 	requestUser := req.Recipient.User
 
@@ -939,7 +1091,52 @@ func (h *InviteHandler) classifyCall(req *sip.Request, tx sip.ServerTransaction)
 		sourcePort:      srcPort,
 	}
 
-	// Step 3: Check if the target matches a local extension.
+	// Step 3: Check for a call pickup feature code.
+	if target, ok := parsePickupCode(requestUser); ok {
+		ic.CallType = CallTypePickup
+		ic.PickupExtension = target
+		return ic, nil
+	}
+
+	// Step 4: Check for a voicemail retrieval feature code.
+	if own, ok := parseVoicemailCode(requestUser); ok {
+		ic.CallType = CallTypeVoicemail
+		ic.VoicemailOwn = own
+		return ic, nil
+	}
+
+	// Step 5: Check for a supervise feature code.
+	if mode, target, ok := parseSuperviseCode(requestUser); ok {
+		ic.CallType = CallTypeSupervise
+		ic.SuperviseMode = mode
+		ic.SuperviseExtension = target
+		return ic, nil
+	}
+
+	// Step 6: Check for the block-last-caller feature code.
+	if requestUser == blockLastCallerCode {
+		ic.CallType = CallTypeBlockCaller
+		return ic, nil
+	}
+
+	// Step 7: Check for a park code or park orbit.
+	if h.parkMgr != nil {
+		lot, orbit, err := h.parkMgr.Lookup(ctx, requestUser)
+		if err != nil {
+			return nil, err
+		}
+		if lot != nil {
+			ic.CallType = CallTypePark
+			if orbit != "" {
+				ic.CallType = CallTypeParkRetrieve
+			}
+			ic.ParkLot = lot
+			ic.ParkOrbit = orbit
+			return ic, nil
+		}
+	}
+
+	// Step 8: Check if the target matches a local extension.
 	targetExt, err := h.extensions.GetByExtension(ctx, requestUser)
 	if err != nil {
 		return nil, err
@@ -952,7 +1149,7 @@ func (h *InviteHandler) classifyCall(req *sip.Request, tx sip.ServerTransaction)
 		return ic, nil
 	}
 
-	// Step 4: Target is not a local extension — outbound call.
+	// Step 9: Target is not a local extension — outbound call.
 	ic.CallType = CallTypeOutbound
 	return ic, nil
 }
@@ -1080,8 +1277,9 @@ func buildACKFor2xx(inviteReq *sip.Request, inviteResp *sip.Response) *sip.Reque
 	return ack
 }
 
-// updateCDROnAnswer updates the CDR with the answer time when a call is answered.
-func (h *InviteHandler) updateCDROnAnswer(callID string, trunkID int64) {
+// updateCDROnAnswer updates the CDR with the answer time when a call is
+// answered, along with the trunk and its rate per minute when known.
+func (h *InviteHandler) updateCDROnAnswer(callID string, trunkID int64, rate *float64) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -1107,6 +1305,9 @@ func (h *InviteHandler) updateCDROnAnswer(callID string, trunkID int64) {
 	if trunkID > 0 && cdr.TrunkID == nil {
 		cdr.TrunkID = &trunkID
 	}
+	if rate != nil {
+		cdr.Rate = rate
+	}
 
 	if err := h.cdrs.Update(ctx, cdr); err != nil {
 		h.logger.Error("failed to update cdr on answer",
@@ -1263,12 +1464,18 @@ func MapSIPToDisposition(statusCode int) (disposition string, hangupCause string
 // (e.g. rejected, not found, busy). Uses the SIP response code to determine
 // the disposition and hangup cause.
 func (h *InviteHandler) finalizeCDRFailed(callID string, sipCode int) {
+	finalizeFailedCDR(h.cdrs, callID, sipCode, h.logger)
+}
+
+// finalizeFailedCDR updates the CDR of a call that failed before being
+// answered with the disposition for sipCode.
+func finalizeFailedCDR(cdrs database.CDRRepository, callID string, sipCode int, logger *slog.Logger) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cdr, err := h.cdrs.GetByCallID(ctx, callID)
+	cdr, err := cdrs.GetByCallID(ctx, callID)
 	if err != nil {
-		h.logger.Error("failed to fetch cdr for failure update",
+		logger.Error("failed to fetch cdr for failure update",
 			"call_id", callID,
 			"error", err,
 		)
@@ -1289,8 +1496,8 @@ func (h *InviteHandler) finalizeCDRFailed(callID string, sipCode int) {
 	cdr.Disposition = disposition
 	cdr.HangupCause = hangupCause
 
-	if err := h.cdrs.Update(ctx, cdr); err != nil {
-		h.logger.Error("failed to finalize cdr on failure",
+	if err := cdrs.Update(ctx, cdr); err != nil {
+		logger.Error("failed to finalize cdr on failure",
 			"call_id", callID,
 			"error", err,
 		)
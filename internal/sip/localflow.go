@@ -0,0 +1,147 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/google/uuid"
+)
+
+// localFlowAnswerTimeout bounds how long a call flow entered for a call
+// the PBX has already answered may take to answer it, e.g. while the call
+// waits in a queue.
+const localFlowAnswerTimeout = 30 * time.Minute
+
+// localFlowCall is a call flow being dialled for a call the PBX has
+// already answered, such as an originated call to a flow entry point or
+// a parked call sent to its lot's timeout flow.
+// Flows run on an inbound INVITE transaction, so the PBX enters the flow
+// by sending an INVITE to itself, addressed to a one-time token. The flow
+// answers, plays to and hangs up that INVITE as it would an inbound call,
+// and the answered leg is bridged to the call like any other destination.
+type localFlowCall struct {
+	flowID      int64
+	flowNode    string
+	destination string
+
+	callerIDName string
+	callerIDNum  string
+}
+
+// flowTarget returns the transfer target that enters flow flowID at node
+// nodeID.
+func flowTarget(flowID int64, nodeID string) *transferTarget {
+	return &transferTarget{
+		destination: fmt.Sprintf("flow:%d/%s", flowID, nodeID),
+		flowID:      flowID,
+		flowNode:    nodeID,
+	}
+}
+
+// dialLocalFlow enters the call flow of target with an INVITE the PBX
+// sends to itself carrying the given SDP offer, and waits for the flow to
+// answer. See dialTransferTarget.
+func (a *FlowSIPActions) dialLocalFlow(ctx context.Context, origin *sip.Request, callID string, target *transferTarget, sdp []byte, cidName, cidNum string) (*transferAnswer, error) {
+	token := a.addLocalFlowCall(&localFlowCall{
+		flowID:       target.flowID,
+		flowNode:     target.flowNode,
+		destination:  target.destination,
+		callerIDName: cidName,
+		callerIDNum:  cidNum,
+	})
+	defer a.takeLocalFlowCall(token)
+
+	contact := models.Registration{
+		ContactURI: fmt.Sprintf("sip:%s@127.0.0.1:%d", token, a.sipPort),
+		Transport:  "udp",
+	}
+	caller := &models.Extension{Name: cidName, Extension: cidNum}
+	result := a.forker.Fork(ctx, origin, nil, []models.Registration{contact}, caller, callID, sdp)
+	if result.Error != nil {
+		return nil, fmt.Errorf("entering %s: %w", target.destination, result.Error)
+	}
+	if !result.Answered {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &transferStatusError{code: 480, reason: "Temporarily Unavailable"}
+	}
+
+	ackReq := buildACKFor2xx(result.AnsweringLeg.req, result.AnswerResponse)
+	if err := a.forker.Client().WriteRequest(ackReq); err != nil {
+		result.AnsweringTx.Terminate()
+		return nil, fmt.Errorf("sending ack to local flow call: %w", err)
+	}
+	return &transferAnswer{
+		req: result.AnsweringLeg.req,
+		res: result.AnswerResponse,
+	}, nil
+}
+
+// addLocalFlowCall registers a local flow call about to be dialled and
+// returns the token its INVITE is addressed to.
+func (a *FlowSIPActions) addLocalFlowCall(lc *localFlowCall) string {
+	token := "flow-" + uuid.New().String()
+
+	a.localMu.Lock()
+	a.local[token] = lc
+	a.localMu.Unlock()
+	return token
+}
+
+// takeLocalFlowCall removes and returns the local flow call dialled with
+// token, or nil if there is none. Each token is accepted once.
+func (a *FlowSIPActions) takeLocalFlowCall(token string) *localFlowCall {
+	a.localMu.Lock()
+	defer a.localMu.Unlock()
+
+	lc := a.local[token]
+	delete(a.local, token)
+	return lc
+}
+
+// classifyLocalFlowCall returns the context of an INVITE the PBX sent to
+// itself to enter a call flow, or nil if req is not one. Only INVITEs from
+// a loopback address carrying a token the PBX is dialling qualify.
+func (h *InviteHandler) classifyLocalFlowCall(req *sip.Request) *InviteContext {
+	if h.flowActions == nil {
+		return nil
+	}
+	if ip := net.ParseIP(sourceHost(req)); ip == nil || !ip.IsLoopback() {
+		return nil
+	}
+	lc := h.flowActions.takeLocalFlowCall(req.Recipient.User)
+	if lc == nil {
+		return nil
+	}
+	return &InviteContext{
+		CallType:     CallTypeFlow,
+		RequestURI:   lc.destination,
+		CallerIDName: lc.callerIDName,
+		CallerIDNum:  lc.callerIDNum,
+		FlowID:       lc.flowID,
+		FlowNode:     lc.flowNode,
+	}
+}
+
+// handleFlowCall runs a local flow call through its call flow from the
+// node it was dialled for.
+func (h *InviteHandler) handleFlowCall(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string) {
+	if h.flowEngine == nil {
+		h.respondErrorWithCDR(req, tx, 501, "Not Implemented", callID)
+		return
+	}
+
+	h.logger.Info("local call entering flow engine",
+		"call_id", callID,
+		"flow_id", ic.FlowID,
+		"entry_node", ic.FlowNode,
+	)
+
+	callCtx := h.newInboundCallContext(req, tx, ic, callID)
+	h.executeInboundFlow(callCtx, ic.FlowID, ic.FlowNode)
+}
@@ -0,0 +1,81 @@
+package sip
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+func TestClassifyLocalFlowCall(t *testing.T) {
+	a := &FlowSIPActions{local: make(map[string]*localFlowCall)}
+	h := &InviteHandler{flowActions: a}
+	target := flowTarget(3, "ivr-1")
+
+	invite := func(user, source string) *sip.Request {
+		req := sip.NewRequest(sip.INVITE, sip.Uri{User: user, Host: "127.0.0.1"})
+		req.SetSource(source)
+		return req
+	}
+
+	token := a.addLocalFlowCall(&localFlowCall{
+		flowID:       target.flowID,
+		flowNode:     target.flowNode,
+		destination:  target.destination,
+		callerIDName: "Alice",
+		callerIDNum:  "101",
+	})
+	if ic := h.classifyLocalFlowCall(invite(token, "192.0.2.20:5060")); ic != nil {
+		t.Fatal("accepted a local flow call from a remote address")
+	}
+
+	ic := h.classifyLocalFlowCall(invite(token, "127.0.0.1:5060"))
+	if ic == nil {
+		t.Fatal("expected the local flow call to be classified")
+	}
+	if ic.CallType != CallTypeFlow || ic.FlowID != 3 || ic.FlowNode != "ivr-1" {
+		t.Errorf("classified as %s into flow %d node %q, want flow call into flow 3 node ivr-1", ic.CallType, ic.FlowID, ic.FlowNode)
+	}
+	if ic.CallerIDName != "Alice" || ic.CallerIDNum != "101" || ic.RequestURI != "flow:3/ivr-1" {
+		t.Errorf("caller %q <%s> to %q, want Alice <101> to flow:3/ivr-1", ic.CallerIDName, ic.CallerIDNum, ic.RequestURI)
+	}
+
+	if h.classifyLocalFlowCall(invite(token, "127.0.0.1:5060")) != nil {
+		t.Error("accepted a local flow call token twice")
+	}
+	if h.classifyLocalFlowCall(invite("flow-unknown", "127.0.0.1:5060")) != nil {
+		t.Error("accepted an unknown local flow call token")
+	}
+}
+
+func TestFindDialogLocalFlowCall(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+
+	// The originated call dialled the flow on leg "flow-leg"; the flow
+	// answered that INVITE itself and tracks it as its own dialog.
+	call := &Dialog{
+		CallID: "orig-call",
+		Caller: CallLeg{CallID: "phone-leg", FromTag: "pbx-tag", ToTag: "phone-tag"},
+		Callee: CallLeg{CallID: "flow-leg", FromTag: "dialer-tag", ToTag: "flow-tag"},
+	}
+	call.CalleeReq = newTestDialogRequest("flow-leg", "101", "dialer-tag", "flow")
+	flowCall := &Dialog{
+		CallID:    "flow-leg",
+		CallerReq: newTestDialogRequest("flow-leg", "101", "dialer-tag", "flow"),
+		Caller:    CallLeg{FromTag: "dialer-tag", ToTag: "flow-tag"},
+	}
+	dm.CreateDialog(call)
+	dm.CreateDialog(flowCall)
+
+	if got := dm.FindDialog("flow-leg", "dialer-tag"); got != flowCall {
+		t.Error("expected a request from the dialling side to reach the flow's dialog")
+	}
+	if got := dm.FindDialog("flow-leg", "flow-tag"); got != call {
+		t.Error("expected a request from the flow to reach the call it was dialled for")
+	}
+	if got := dm.FindDialog("phone-leg", "phone-tag"); got != call {
+		t.Error("expected the call to be found by its caller leg")
+	}
+}
@@ -0,0 +1,177 @@
+package sip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+	"github.com/flowpbx/flowpbx/internal/prompts"
+)
+
+// mohModeShuffle plays a music on hold class's prompts in random order;
+// any other mode plays them in the order listed.
+const mohModeShuffle = "shuffle"
+
+// MOHManager owns the shared hold music streams: one per music on hold
+// class, built from its playlist of audio prompts, and one per built-in
+// hold music file. Every held, parked or queued call listening to the same
+// music is fed from the same stream.
+type MOHManager struct {
+	classes        database.MOHClassRepository
+	audioPrompts   database.AudioPromptRepository
+	inboundNumbers database.InboundNumberRepository
+	dataDir        string
+	logger         *slog.Logger
+
+	mu           sync.Mutex
+	classStreams map[int64]*mohClassStream
+	fileStreams  map[string]*media.MOHStream
+}
+
+// mohClassStream is the stream of a music on hold class and the version
+// of the class it was built from. A class edited since gets a new stream;
+// calls listening to the old one keep it until they stop.
+type mohClassStream struct {
+	stream  *media.MOHStream
+	version time.Time
+}
+
+// NewMOHManager creates a new music on hold manager.
+func NewMOHManager(
+	classes database.MOHClassRepository,
+	audioPrompts database.AudioPromptRepository,
+	inboundNumbers database.InboundNumberRepository,
+	dataDir string,
+	logger *slog.Logger,
+) *MOHManager {
+	return &MOHManager{
+		classes:        classes,
+		audioPrompts:   audioPrompts,
+		inboundNumbers: inboundNumbers,
+		dataDir:        dataDir,
+		logger:         logger.With("subsystem", "moh"),
+		classStreams:   make(map[int64]*mohClassStream),
+		fileStreams:    make(map[string]*media.MOHStream),
+	}
+}
+
+// Listen returns a listener on the music on hold class's stream, or on
+// the stream of fallback, an audio file, if classID is nil or the class
+// no longer exists or has no prompts. The listener must be closed.
+func (m *MOHManager) Listen(ctx context.Context, classID *int64, fallback string) *media.MOHListener {
+	if classID != nil {
+		stream, err := m.classStream(ctx, *classID)
+		if err != nil {
+			m.logger.Warn("music on hold class unavailable, using default",
+				"moh_class_id", *classID,
+				"error", err,
+			)
+		}
+		if stream != nil {
+			return stream.Listen()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stream, ok := m.fileStreams[fallback]
+	if !ok {
+		stream = media.NewMOHStream([]string{fallback}, false, m.logger)
+		m.fileStreams[fallback] = stream
+	}
+	return stream.Listen()
+}
+
+// classStream returns the stream of a music on hold class, building it if
+// the class is new or has changed. Returns nil if the class does not exist
+// or has no prompts.
+func (m *MOHManager) classStream(ctx context.Context, id int64) (*media.MOHStream, error) {
+	class, err := m.classes.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("looking up moh class: %w", err)
+	}
+
+	m.mu.Lock()
+	cs, ok := m.classStreams[id]
+	if class == nil {
+		delete(m.classStreams, id)
+	}
+	m.mu.Unlock()
+	if class == nil {
+		return nil, nil
+	}
+	if ok && cs.version.Equal(class.UpdatedAt) {
+		return cs.stream, nil
+	}
+
+	files, err := m.playlist(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cs, ok := m.classStreams[id]; ok && cs.version.Equal(class.UpdatedAt) {
+		return cs.stream, nil
+	}
+	stream := media.NewMOHStream(files, class.Mode == mohModeShuffle, m.logger.With("moh_class", class.Name))
+	m.classStreams[id] = &mohClassStream{stream: stream, version: class.UpdatedAt}
+	return stream, nil
+}
+
+// playlist returns the files of a class's audio prompts. Prompts deleted
+// since the class was saved are left out.
+func (m *MOHManager) playlist(ctx context.Context, class *models.MOHClass) ([]string, error) {
+	var ids []int64
+	if err := json.Unmarshal([]byte(class.PromptIDs), &ids); err != nil {
+		return nil, fmt.Errorf("parsing moh class prompt ids: %w", err)
+	}
+
+	files := make([]string, 0, len(ids))
+	for _, id := range ids {
+		p, err := m.audioPrompts.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("looking up audio prompt %d: %w", id, err)
+		}
+		if p == nil {
+			continue
+		}
+		files = append(files, filepath.Join(prompts.CustomDir(m.dataDir), p.FilePath))
+	}
+	return files, nil
+}
+
+// dialogClass returns the music on hold class a party of d hears when
+// holder puts it on hold or parks it: the holder's, or else that of the
+// inbound number the call came in on. nil means the default hold music.
+func (m *MOHManager) dialogClass(ctx context.Context, d *Dialog, holder *models.Extension) *int64 {
+	if holder != nil && holder.MOHClassID != nil {
+		return holder.MOHClassID
+	}
+	if d.Direction != CallTypeInbound || d.CallerReq == nil {
+		return nil
+	}
+
+	num, err := m.inboundNumbers.GetByNumber(ctx, d.CallerReq.Recipient.User)
+	if err != nil {
+		m.logger.Warn("failed to look up inbound number for hold music",
+			"call_id", d.CallID,
+			"error", err,
+		)
+		return nil
+	}
+	if num == nil {
+		return nil
+	}
+	return num.MOHClassID
+}
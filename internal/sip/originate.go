@@ -0,0 +1,333 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+	"github.com/google/uuid"
+)
+
+// originateRingTimeout is how long the destination of an originated call
+// rings once the extension it was placed for has answered, and how long
+// that extension rings if it has no ring timeout of its own.
+const originateRingTimeout = 30 * time.Second
+
+// originateSDP is the media the PBX offers an extension's phones when it
+// rings them for an originated call, before the destination's media is
+// known. Its address is replaced with the proxy's, and the codecs the
+// relay can transcode are added to it.
+const originateSDP = "v=0\r\n" +
+	"o=flowpbx 0 0 IN IP4 0.0.0.0\r\n" +
+	"s=flowpbx\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 0 RTP/AVP 0 8 101\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:8 PCMA/8000\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"a=fmtp:101 0-16\r\n" +
+	"a=sendrecv\r\n"
+
+// OriginateCall places a call on behalf of an extension (click-to-call).
+// The extension's phones are rung first; once one answers, the destination
+// (an extension, number or SIP URI) is dialled and bridged to it. Both are
+// routed before anything rings, so a call that cannot be placed fails
+// straight away. The call then proceeds in the background; the returned
+// Call-ID identifies it in the active-call list and its CDR.
+func (s *Server) OriginateCall(ctx context.Context, extension, destination string) (string, error) {
+	ext, caller, err := s.flowActions.originateCaller(ctx, extension)
+	if err != nil {
+		return "", err
+	}
+	target, err := s.flowActions.resolveTransferTarget(ctx, destination, ext)
+	if err != nil {
+		return "", fmt.Errorf("routing to destination: %w", err)
+	}
+	return s.flowActions.originate(ext, caller, target)
+}
+
+// OriginateFlowCall places a call on behalf of an extension to a call
+// flow entry point. Once the extension answers, the call enters flow
+// flowID at node entryNode as a local flow call. See OriginateCall.
+func (s *Server) OriginateFlowCall(ctx context.Context, extension string, flowID int64, entryNode string) (string, error) {
+	ext, caller, err := s.flowActions.originateCaller(ctx, extension)
+	if err != nil {
+		return "", err
+	}
+	return s.flowActions.originate(ext, caller, flowTarget(flowID, entryNode))
+}
+
+// originateCaller looks up the extension an originated call is placed for
+// and routes the call to its phones.
+func (a *FlowSIPActions) originateCaller(ctx context.Context, extension string) (*models.Extension, *transferTarget, error) {
+	if a.sessionMgr == nil {
+		return nil, nil, fmt.Errorf("media proxy not available")
+	}
+
+	ext, err := a.extensions.GetByExtension(ctx, extension)
+	if err != nil {
+		return nil, nil, fmt.Errorf("looking up extension %s: %w", extension, err)
+	}
+	if ext == nil {
+		return nil, nil, ErrExtensionNotFound
+	}
+
+	caller, err := a.resolveTransferTarget(ctx, ext.Extension, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("routing to extension %s: %w", ext.Extension, err)
+	}
+	return ext, caller, nil
+}
+
+// originate starts ringing the phones of extension ext, routed as caller,
+// for a call to target. See Server.OriginateCall.
+func (a *FlowSIPActions) originate(ext *models.Extension, caller, target *transferTarget) (string, error) {
+	callID := uuid.New().String()
+	ms, err := media.CreateMediaSession(a.sessionMgr, callID, callID, a.logger)
+	if err != nil {
+		return "", err
+	}
+	offer, keys, err := a.originateOffer(ms.CallerRTPPort(), ext.SRTPMode)
+	if err != nil {
+		ms.Release()
+		return "", err
+	}
+
+	ringTimeout := time.Duration(ext.RingTimeout) * time.Second
+	if ringTimeout <= 0 {
+		ringTimeout = originateRingTimeout
+	}
+	ringCtx, cancel := context.WithTimeout(context.Background(), ringTimeout)
+
+	o := &origination{
+		callID: callID,
+		req:    originateRequest(callID, ext, target.destination, a.proxyIP),
+		ext:    ext,
+		caller: caller,
+		target: target,
+		media:  ms,
+		offer:  offer,
+		keys:   keys,
+		start:  time.Now(),
+	}
+
+	a.createOriginateCDR(o)
+	a.pendingMgr.Add(&PendingCall{
+		CallID:     callID,
+		CallerReq:  o.req,
+		CancelFork: cancel,
+	})
+
+	a.logger.Info("originating call",
+		"call_id", callID,
+		"extension", ext.Extension,
+		"destination", target.destination,
+		"contacts", len(caller.contacts),
+	)
+
+	go func() {
+		defer cancel()
+		a.placeOriginatedCall(ringCtx, o)
+	}()
+	return callID, nil
+}
+
+// origination is an originated call being placed.
+type origination struct {
+	callID string
+
+	// req describes the call for the pending-call list: from the
+	// extension, to the destination. It is never sent.
+	req *sip.Request
+
+	ext    *models.Extension
+	caller *transferTarget
+	target *transferTarget
+
+	// media is the call's media session. Its caller side faces the
+	// extension and is offered offer, with SRTP keys keys.
+	media *media.MediaSession
+	offer []byte
+	keys  []media.CryptoAttribute
+
+	start time.Time
+}
+
+// placeOriginatedCall rings the extension an originated call was placed
+// for and, once it answers, dials the destination. ctx bounds the ringing
+// and is cancelled if the call is hung up before the extension answers.
+func (a *FlowSIPActions) placeOriginatedCall(ctx context.Context, o *origination) {
+	cidName, cidNum := "", o.target.destination
+	if ext := o.target.extension; ext != nil {
+		cidName = ext.Name
+	}
+
+	answer, err := a.dialTransferTarget(ctx, o.req, o.callID, o.caller, o.offer, cidName, cidNum)
+
+	if a.pendingMgr.Remove(o.callID) == nil {
+		// Hung up while ringing; the CDR has been finalized already.
+		if err == nil {
+			a.sendLegBYE(answer.leg(), o.callID)
+		}
+		o.media.Release()
+		a.logger.Info("originated call cancelled while ringing",
+			"call_id", o.callID,
+		)
+		return
+	}
+	if err != nil {
+		o.media.Release()
+		code, _ := transferFailureStatus(err)
+		finalizeFailedCDR(a.cdrs, o.callID, code, a.logger)
+		a.logger.Info("originated call not answered by extension",
+			"call_id", o.callID,
+			"extension", o.ext.Extension,
+			"error", err,
+		)
+		return
+	}
+
+	leg := answer.leg()
+	if err := a.acceptOriginateAnswer(o, answer.res.Body()); err != nil {
+		a.sendLegBYE(leg, o.callID)
+		o.media.Release()
+		finalizeFailedCDR(a.cdrs, o.callID, 488, a.logger)
+		a.logger.Error("originated call answer not usable",
+			"call_id", o.callID,
+			"extension", o.ext.Extension,
+			"error", err,
+		)
+		return
+	}
+
+	d := &Dialog{
+		CallID:       o.callID,
+		Direction:    CallTypeOriginate,
+		CallerIDName: o.ext.Name,
+		CallerIDNum:  o.ext.Extension,
+		CalledNum:    o.target.destination,
+		StartTime:    o.start,
+		CallerOutReq: answer.req,
+		CallerOutRes: answer.res,
+		Media:        o.media,
+		Caller:       answer.callLeg(o.ext, leg.target),
+	}
+	a.dialogMgr.CreateDialog(d)
+	a.updateCDROnAnswer(o.callID)
+
+	a.logger.Info("originated call answered by extension, dialling destination",
+		"call_id", o.callID,
+		"extension", o.ext.Extension,
+		"destination", o.target.destination,
+	)
+
+	connectTimeout := originateRingTimeout
+	if o.target.flowID != 0 {
+		connectTimeout = localFlowAnswerTimeout
+	}
+	connectCtx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := a.connectLeg(connectCtx, d, false, o.target); err != nil {
+		if errors.Is(err, errTransferCallEnded) || !a.dialogMgr.HasDialog(o.callID) {
+			// The extension hung up while the destination was ringing.
+			return
+		}
+		code, _ := transferFailureStatus(err)
+		_, cause := MapSIPToDisposition(code)
+		a.logger.Info("originated call destination not reached",
+			"call_id", o.callID,
+			"destination", o.target.destination,
+			"error", err,
+		)
+		a.hangupDialog(d, cause)
+		return
+	}
+
+	a.logger.Info("originated call bridged",
+		"call_id", o.callID,
+		"extension", o.ext.Extension,
+		"destination", o.target.destination,
+	)
+}
+
+// originateOffer builds the SDP offered to the extension's phones, pointed
+// at the proxy's port, with SRTP keys under the extension's mode.
+func (a *FlowSIPActions) originateOffer(port int, srtpMode string) ([]byte, []media.CryptoAttribute, error) {
+	sd, err := media.ParseSDP([]byte(originateSDP))
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing originate sdp: %w", err)
+	}
+	offer := media.RewriteSDP(sd, a.proxyIP, port)
+	offer.Origin.SessionID = strconv.FormatInt(time.Now().UnixNano(), 10)
+	offerTranscodableCodecs(offer)
+	keys, err := offerSRTP(offer.AudioMedia(), srtpMode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return offer.Marshal(), keys, nil
+}
+
+// acceptOriginateAnswer applies the SRTP keys of the extension's SDP
+// answer to the caller side of the call's media session. The relay itself
+// starts once the destination answers.
+func (a *FlowSIPActions) acceptOriginateAnswer(o *origination, body []byte) error {
+	sd, err := media.ParseSDP(body)
+	if err != nil {
+		return fmt.Errorf("parsing sdp answer: %w", err)
+	}
+	audio := sd.AudioMedia()
+	if audio == nil {
+		return fmt.Errorf("sdp answer has no audio media")
+	}
+	legSRTP, err := acceptSRTPAnswer(audio, o.keys, o.ext.SRTPMode)
+	if err != nil {
+		return err
+	}
+	return o.media.SetLegSRTP(true, legSRTP)
+}
+
+// originateRequest builds the request that describes an originated call in
+// the pending-call list and event stream while the extension rings: from
+// the extension, to the destination.
+func originateRequest(callID string, ext *models.Extension, destination, host string) *sip.Request {
+	req := sip.NewRequest(sip.INVITE, sip.Uri{Scheme: "sip", User: destination, Host: host})
+	from := &sip.FromHeader{
+		DisplayName: ext.Name,
+		Address:     sip.Uri{Scheme: "sip", User: ext.Extension, Host: host},
+	}
+	from.Params.Add("tag", sip.GenerateTagN(16))
+	req.AppendHeader(from)
+	req.AppendHeader(&sip.ToHeader{
+		Address: sip.Uri{Scheme: "sip", User: destination, Host: host},
+	})
+	req.AppendHeader(sip.NewHeader("Call-ID", callID))
+	return req
+}
+
+// createOriginateCDR records an originated call as it starts ringing.
+func (a *FlowSIPActions) createOriginateCDR(o *origination) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cdr := &models.CDR{
+		CallID:       o.callID,
+		StartTime:    o.start,
+		CallerIDName: o.ext.Name,
+		CallerIDNum:  o.ext.Extension,
+		Callee:       o.target.destination,
+		Direction:    string(CallTypeOriginate),
+		Disposition:  "in_progress",
+	}
+	if err := a.cdrs.Create(ctx, cdr); err != nil {
+		a.logger.Error("failed to create originate cdr",
+			"call_id", o.callID,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,110 @@
+package sip
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+func TestOriginateRequest(t *testing.T) {
+	ext := &models.Extension{Extension: "101", Name: "Alice"}
+	req := originateRequest("orig-call", ext, "0400000000", "192.0.2.10")
+
+	if got := req.CallID().Value(); got != "orig-call" {
+		t.Errorf("Call-ID = %q, want orig-call", got)
+	}
+	from := req.From()
+	if from.Address.User != "101" || from.DisplayName != "Alice" {
+		t.Errorf("From = %q <%s>, want Alice <101>", from.DisplayName, from.Address.User)
+	}
+	if tag, _ := from.Params.Get("tag"); tag == "" {
+		t.Error("expected a From tag")
+	}
+	if got := req.To().Address.User; got != "0400000000" {
+		t.Errorf("To user = %q, want 0400000000", got)
+	}
+}
+
+func TestOriginateOffer(t *testing.T) {
+	a := &FlowSIPActions{proxyIP: "192.0.2.10"}
+
+	body, keys, err := a.originateOffer(40000, srtpModeOff)
+	if err != nil {
+		t.Fatalf("originateOffer: %v", err)
+	}
+	if keys != nil {
+		t.Errorf("expected no srtp keys with srtp off, got %d", len(keys))
+	}
+	sd, err := media.ParseSDP(body)
+	if err != nil {
+		t.Fatalf("parsing offer: %v", err)
+	}
+	audio := sd.AudioMedia()
+	if audio == nil {
+		t.Fatal("offer has no audio media")
+	}
+	if audio.Port != 40000 || sd.ConnectionAddress(audio) != "192.0.2.10" {
+		t.Errorf("offer media at %s:%d, want 192.0.2.10:40000", sd.ConnectionAddress(audio), audio.Port)
+	}
+	if len(audio.Formats) == 0 || audio.Formats[0] != 0 {
+		t.Errorf("formats = %v, want PCMU first", audio.Formats)
+	}
+	if !audio.HasCodec("telephone-event") {
+		t.Error("expected telephone-event in the offer")
+	}
+
+	body, keys, err = a.originateOffer(40000, srtpModeRequired)
+	if err != nil {
+		t.Fatalf("originateOffer with srtp required: %v", err)
+	}
+	sd, err = media.ParseSDP(body)
+	if err != nil {
+		t.Fatalf("parsing srtp offer: %v", err)
+	}
+	if len(keys) == 0 || !sd.AudioMedia().IsSecure() {
+		t.Error("expected a secure offer with srtp keys when srtp is required")
+	}
+}
+
+func TestDialogManagerFindsCallerLegCallID(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+	d := &Dialog{
+		CallID:    "orig-call",
+		Direction: CallTypeOriginate,
+		Caller:    CallLeg{CallID: "phone-leg", FromTag: "pbx-tag", ToTag: "phone-tag"},
+	}
+	dm.CreateDialog(d)
+
+	if got := dm.FindDialog("phone-leg", ""); got != d {
+		t.Error("expected dialog to be found by its caller leg's call-id")
+	}
+	if got := dm.GetDialog("orig-call"); got != d {
+		t.Error("expected dialog to be found by its own call-id")
+	}
+}
+
+func TestPendingCallCancelWithoutCallerTx(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	pm := NewPendingCallManager(logger)
+
+	cancelled := false
+	pm.Add(&PendingCall{
+		CallID:     "orig-call",
+		CallerReq:  originateRequest("orig-call", &models.Extension{Extension: "101"}, "102", "192.0.2.10"),
+		CancelFork: func() { cancelled = true },
+	})
+
+	if !pm.Cancel("orig-call", logger) {
+		t.Fatal("expected a call placed by the pbx to be cancelled")
+	}
+	if !cancelled {
+		t.Error("expected the ringing to be cancelled")
+	}
+	if pm.Get("orig-call") != nil {
+		t.Error("cancelled call still pending")
+	}
+}
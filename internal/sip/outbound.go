@@ -2,8 +2,11 @@ package sip
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,14 +14,17 @@ import (
 	"github.com/emiago/sipgo/sip"
 	"github.com/flowpbx/flowpbx/internal/database"
 	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/dialplan"
 	"github.com/flowpbx/flowpbx/internal/media"
 	"github.com/icholy/digest"
 )
 
-// OutboundRouter selects a trunk for outbound calls and builds the INVITE
-// to send to the trunk provider.
+// OutboundRouter routes dialled numbers through the outbound dial plan to
+// trunks and builds the INVITE to send to the trunk provider.
 type OutboundRouter struct {
 	trunks         database.TrunkRepository
+	routes         database.OutboundRouteRepository
+	rates          database.TrunkRateRepository
 	trunkRegistrar *TrunkRegistrar
 	encryptor      *database.Encryptor
 	logger         *slog.Logger
@@ -27,20 +33,209 @@ type OutboundRouter struct {
 // NewOutboundRouter creates a new outbound call router.
 func NewOutboundRouter(
 	trunks database.TrunkRepository,
+	routes database.OutboundRouteRepository,
+	rates database.TrunkRateRepository,
 	trunkRegistrar *TrunkRegistrar,
 	encryptor *database.Encryptor,
 	logger *slog.Logger,
 ) *OutboundRouter {
 	return &OutboundRouter{
 		trunks:         trunks,
+		routes:         routes,
+		rates:          rates,
 		trunkRegistrar: trunkRegistrar,
 		encryptor:      encryptor,
 		logger:         logger.With("subsystem", "outbound-router"),
 	}
 }
 
-// ErrNoTrunksAvailable is returned when no enabled trunks exist.
-var ErrNoTrunksAvailable = fmt.Errorf("no trunks available for outbound routing")
+var (
+	// ErrNoTrunksAvailable is returned when no enabled trunks exist.
+	ErrNoTrunksAvailable = fmt.Errorf("no trunks available for outbound routing")
+
+	// ErrNoOutboundRoute is returned when outbound routes are configured
+	// but none matches the dialled number.
+	ErrNoOutboundRoute = errors.New("no outbound route matches the dialled number")
+
+	// ErrCallNotPermitted is returned when the calling extension's class
+	// of service does not permit the number it dialled.
+	ErrCallNotPermitted = errors.New("call not permitted by class of service")
+)
+
+// OutboundPlan is a dialled number routed through the dial plan.
+type OutboundPlan struct {
+	// Route is the outbound route the number matched, or nil when no
+	// routes are configured.
+	Route *models.OutboundRoute
+
+	// Number is the number to send the trunks, after the route's prefix
+	// rules. Each trunk's own prefix rules are applied on top.
+	Number string
+
+	// Trunks are the usable trunks to try, in order: cheapest first for
+	// trunks whose rate deck covers Number, then the rest in route order.
+	Trunks []models.Trunk
+
+	// Rates maps the ID of each trunk whose rate deck covers Number to
+	// its rate per minute.
+	Rates map[int64]float64
+}
+
+// Route routes a dialled number through the outbound dial plan. Enabled
+// routes are matched in priority order and the first with a pattern
+// matching number is used. caller, when set, is the extension placing the
+// call; its class of service must permit the route's class before any
+// trunk is returned. With no routes configured, every enabled trunk is
+// tried by priority and only internal-only extensions are refused. Either
+// way the trunks are then ranked by their rate for the number.
+func (r *OutboundRouter) Route(ctx context.Context, number string, caller *models.Extension) (*OutboundPlan, error) {
+	routes, err := r.routes.ListEnabled(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing outbound routes: %w", err)
+	}
+
+	if len(routes) == 0 {
+		if caller != nil && caller.ClassOfService == dialplan.ClassInternal {
+			return nil, fmt.Errorf("%w: extension %s is internal only", ErrCallNotPermitted, caller.Extension)
+		}
+		trunks, err := r.SelectTrunks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return r.rankByCost(ctx, &OutboundPlan{Number: number, Trunks: trunks}), nil
+	}
+
+	route := r.matchRoute(routes, number)
+	if route == nil {
+		return nil, ErrNoOutboundRoute
+	}
+	if caller != nil && !dialplan.Permits(caller.ClassOfService, route.Class) {
+		return nil, fmt.Errorf("%w: extension %s may not call %s numbers",
+			ErrCallNotPermitted, caller.Extension, route.Class)
+	}
+
+	trunks, err := r.routeTrunks(ctx, route)
+	if err != nil {
+		return nil, err
+	}
+	return r.rankByCost(ctx, &OutboundPlan{
+		Route:  route,
+		Number: applyPrefixRules(number, route.PrefixStrip, route.PrefixAdd),
+		Trunks: trunks,
+	}), nil
+}
+
+// rankByCost looks up the rate of each of plan's trunks for its number and
+// orders the trunks cheapest first. Trunks without a matching rate keep
+// their order after the rated ones. A failed lookup leaves that trunk
+// unrated rather than failing the call.
+func (r *OutboundRouter) rankByCost(ctx context.Context, plan *OutboundPlan) *OutboundPlan {
+	if r.rates == nil {
+		return plan
+	}
+
+	for _, trunk := range plan.Trunks {
+		rate, err := r.rates.LongestPrefix(ctx, trunk.ID, plan.Number)
+		if err != nil {
+			r.logger.Warn("failed to look up trunk rate",
+				"trunk", trunk.Name,
+				"trunk_id", trunk.ID,
+				"error", err,
+			)
+			continue
+		}
+		if rate == nil {
+			continue
+		}
+		if plan.Rates == nil {
+			plan.Rates = make(map[int64]float64)
+		}
+		plan.Rates[trunk.ID] = rate.Rate
+	}
+
+	sort.SliceStable(plan.Trunks, func(i, j int) bool {
+		ri, iok := plan.Rates[plan.Trunks[i].ID]
+		rj, jok := plan.Rates[plan.Trunks[j].ID]
+		if iok != jok {
+			return iok
+		}
+		return iok && ri < rj
+	})
+	return plan
+}
+
+// rate returns the rate per minute of trunkID for the plan's number, or
+// nil if the trunk has no rate for it.
+func (p *OutboundPlan) rate(trunkID int64) *float64 {
+	rate, ok := p.Rates[trunkID]
+	if !ok {
+		return nil
+	}
+	return &rate
+}
+
+// matchRoute returns the first of routes with a pattern matching number,
+// or nil if none does. Patterns that do not compile are skipped.
+func (r *OutboundRouter) matchRoute(routes []models.OutboundRoute, number string) *models.OutboundRoute {
+	for i := range routes {
+		route := &routes[i]
+		var patterns []string
+		if err := json.Unmarshal([]byte(route.Patterns), &patterns); err != nil {
+			r.logger.Warn("skipping outbound route with invalid patterns",
+				"route", route.Name,
+				"route_id", route.ID,
+				"error", err,
+			)
+			continue
+		}
+		for _, raw := range patterns {
+			p, err := dialplan.Compile(raw)
+			if err != nil {
+				r.logger.Warn("skipping invalid outbound route pattern",
+					"route", route.Name,
+					"route_id", route.ID,
+					"error", err,
+				)
+				continue
+			}
+			if p.Match(number) {
+				return route
+			}
+		}
+	}
+	return nil
+}
+
+// routeTrunks returns the usable trunks of route in the order it lists
+// them. Trunks that are disabled or no longer exist are skipped.
+func (r *OutboundRouter) routeTrunks(ctx context.Context, route *models.OutboundRoute) ([]models.Trunk, error) {
+	var ids []int64
+	if err := json.Unmarshal([]byte(route.TrunkIDs), &ids); err != nil {
+		return nil, fmt.Errorf("parsing trunk ids of outbound route %s: %w", route.Name, err)
+	}
+
+	enabled, err := r.trunks.ListEnabled(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing enabled trunks: %w", err)
+	}
+	byID := make(map[int64]models.Trunk, len(enabled))
+	for _, trunk := range enabled {
+		byID[trunk.ID] = trunk
+	}
+
+	var trunks []models.Trunk
+	for _, id := range ids {
+		if trunk, ok := byID[id]; ok {
+			trunks = append(trunks, trunk)
+		}
+	}
+
+	candidates := r.usableTrunks(trunks)
+	if len(candidates) == 0 {
+		return nil, ErrNoTrunksAvailable
+	}
+	return candidates, nil
+}
 
 // SelectTrunks returns enabled trunks ordered by priority, skipping any whose
 // runtime status is failed or disabled. Each trunk's password is decrypted
@@ -56,7 +251,17 @@ func (r *OutboundRouter) SelectTrunks(ctx context.Context) ([]models.Trunk, erro
 		return nil, ErrNoTrunksAvailable
 	}
 
-	// Filter out trunks whose runtime status indicates they are not usable.
+	candidates := r.usableTrunks(trunks)
+	if len(candidates) == 0 {
+		return nil, ErrNoTrunksAvailable
+	}
+
+	return candidates, nil
+}
+
+// usableTrunks filters out trunks whose runtime status is failed or
+// disabled and decrypts the passwords of the rest, keeping their order.
+func (r *OutboundRouter) usableTrunks(trunks []models.Trunk) []models.Trunk {
 	var candidates []models.Trunk
 	for _, trunk := range trunks {
 		if r.trunkRegistrar != nil {
@@ -87,12 +292,7 @@ func (r *OutboundRouter) SelectTrunks(ctx context.Context) ([]models.Trunk, erro
 
 		candidates = append(candidates, trunk)
 	}
-
-	if len(candidates) == 0 {
-		return nil, ErrNoTrunksAvailable
-	}
-
-	return candidates, nil
+	return candidates
 }
 
 // handleOutboundCall routes a call from a local extension to an external number
@@ -112,36 +312,65 @@ func (h *InviteHandler) handleOutboundCall(req *sip.Request, tx sip.ServerTransa
 		return
 	}
 
-	// Select candidate trunks ordered by priority, filtered by health.
-	trunks, err := h.outboundRouter.SelectTrunks(ctx)
+	// Route the dialled number through the dial plan. The caller's class
+	// of service is checked before any trunk is tried.
+	plan, err := h.outboundRouter.Route(ctx, ic.RequestURI, ic.CallerExtension)
 	if err != nil {
-		if err == ErrNoTrunksAvailable {
+		switch {
+		case errors.Is(err, ErrCallNotPermitted):
+			h.logger.Info("outbound call rejected: not permitted",
+				"call_id", callID,
+				"dialed", ic.RequestURI,
+				"error", err,
+			)
+			h.respondErrorWithCDR(req, tx, 403, "Forbidden", callID)
+		case errors.Is(err, ErrNoOutboundRoute):
+			h.logger.Info("outbound call failed: no matching route",
+				"call_id", callID,
+				"dialed", ic.RequestURI,
+			)
+			h.respondErrorWithCDR(req, tx, 404, "Not Found", callID)
+		case errors.Is(err, ErrNoTrunksAvailable):
 			h.logger.Warn("outbound call failed: no trunks available",
 				"call_id", callID,
 				"dialed", ic.RequestURI,
 			)
 			h.respondErrorWithCDR(req, tx, 503, "Service Unavailable", callID)
-			return
+		default:
+			h.logger.Error("outbound call failed: trunk selection error",
+				"call_id", callID,
+				"error", err,
+			)
+			h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
 		}
-		h.logger.Error("outbound call failed: trunk selection error",
+		return
+	}
+	trunks := plan.Trunks
+	if plan.Route != nil {
+		h.logger.Info("outbound call matched route",
 			"call_id", callID,
-			"error", err,
+			"route", plan.Route.Name,
+			"route_id", plan.Route.ID,
+			"class", plan.Route.Class,
+			"dialed", ic.RequestURI,
+			"number", plan.Number,
 		)
-		h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
-		return
 	}
 
 	// Phase 1: Allocate media bridge to proxy RTP between caller and trunk.
 	var bridge *MediaBridge
 	var trunkSDP []byte
 	if len(req.Body()) > 0 && h.sessionMgr != nil {
-		bridge, trunkSDP, err = AllocateMediaBridge(h.sessionMgr, req.Body(), callID, h.proxyIP, h.logger)
+		// The trunk leg's offer is rebuilt for each trunk's SRTP mode below.
+		policy := SRTPPolicy{Caller: ic.CallerExtension.SRTPMode}
+		bridge, _, err = AllocateMediaBridge(h.sessionMgr, req.Body(), callID, h.proxyIP, policy, h.logger)
 		if err != nil {
 			h.logger.Error("failed to allocate media bridge for outbound call",
 				"call_id", callID,
 				"error", err,
 			)
-			h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
+			code, reason := mediaErrorStatus(err)
+			h.respondErrorWithCDR(req, tx, code, reason, callID)
 			return
 		}
 	}
@@ -154,6 +383,7 @@ func (h *InviteHandler) handleOutboundCall(req *sip.Request, tx sip.ServerTransa
 		CallerReq:  req,
 		CancelFork: cancelOutbound,
 		Bridge:     bridge,
+		Extensions: extensionNumbers(ic.CallerExtension),
 	})
 
 	// Try each trunk in priority order until one succeeds or a callee-level
@@ -187,7 +417,14 @@ func (h *InviteHandler) handleOutboundCall(req *sip.Request, tx sip.ServerTransa
 			"candidates", len(trunks),
 		)
 
-		result = h.sendOutboundInvite(outboundCtx, req, tx, ic, trunk, callID, trunkSDP)
+		if bridge != nil {
+			if trunkSDP, err = bridge.CalleeOffer(trunk.SRTPMode); err != nil {
+				result = &outboundResult{err: err}
+				continue
+			}
+		}
+
+		result = h.sendOutboundInvite(outboundCtx, req, tx, ic, trunk, plan.Number, callID, trunkSDP)
 
 		// Check if context was cancelled (e.g. CANCEL from caller).
 		if outboundCtx.Err() != nil {
@@ -388,6 +625,11 @@ func (h *InviteHandler) handleOutboundCall(req *sip.Request, tx sip.ServerTransa
 			dialog.Caller.FromTag = tag
 		}
 	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
 	if to := result.res.To(); to != nil {
 		if tag, ok := to.Params.Get("tag"); ok {
 			dialog.Callee.ToTag = tag
@@ -407,7 +649,7 @@ func (h *InviteHandler) handleOutboundCall(req *sip.Request, tx sip.ServerTransa
 	}
 
 	h.dialogMgr.CreateDialog(dialog)
-	h.updateCDROnAnswer(callID, selectedTrunk.ID)
+	h.updateCDROnAnswer(callID, selectedTrunk.ID, plan.rate(selectedTrunk.ID))
 
 	h.logger.Info("outbound call dialog established",
 		"call_id", callID,
@@ -498,25 +740,27 @@ func applyPrefixRules(number string, strip int, add string) string {
 }
 
 // sendOutboundInvite builds and sends an INVITE to the trunk for an outbound call.
-// It handles digest authentication challenges (401/407) and relays provisional
-// responses back to the caller.
+// number is the dialled number as routed by the dial plan. It handles digest
+// authentication challenges (401/407) and relays provisional responses back
+// to the caller.
 func (h *InviteHandler) sendOutboundInvite(
 	ctx context.Context,
 	callerReq *sip.Request,
 	callerTx sip.ServerTransaction,
 	ic *InviteContext,
 	trunk *models.Trunk,
+	number string,
 	callID string,
 	sdpBody []byte,
 ) *outboundResult {
 	// Apply trunk prefix manipulation rules to the dialed number.
-	dialedNumber := applyPrefixRules(ic.RequestURI, trunk.PrefixStrip, trunk.PrefixAdd)
+	dialedNumber := applyPrefixRules(number, trunk.PrefixStrip, trunk.PrefixAdd)
 
-	if dialedNumber != ic.RequestURI {
+	if dialedNumber != number {
 		h.logger.Debug("applied prefix rules to dialed number",
 			"call_id", callID,
 			"trunk", trunk.Name,
-			"original", ic.RequestURI,
+			"original", number,
 			"transformed", dialedNumber,
 			"prefix_strip", trunk.PrefixStrip,
 			"prefix_add", trunk.PrefixAdd,
@@ -1,9 +1,17 @@
 package sip
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/flowpbx/flowpbx/internal/database"
 	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/dialplan"
 )
 
 func TestApplyPrefixRules(t *testing.T) {
@@ -190,3 +198,148 @@ func TestBuildOutboundCallerID(t *testing.T) {
 		})
 	}
 }
+
+// staticTrunks serves a fixed list of enabled trunks.
+type staticTrunks struct {
+	database.TrunkRepository
+	trunks []models.Trunk
+}
+
+func (s staticTrunks) ListEnabled(context.Context) ([]models.Trunk, error) {
+	return s.trunks, nil
+}
+
+// staticRoutes serves a fixed list of enabled outbound routes.
+type staticRoutes struct {
+	database.OutboundRouteRepository
+	routes []models.OutboundRoute
+}
+
+func (s staticRoutes) ListEnabled(context.Context) ([]models.OutboundRoute, error) {
+	return s.routes, nil
+}
+
+func TestOutboundRouterRoute(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	trunks := staticTrunks{trunks: []models.Trunk{
+		{ID: 1, Name: "primary", Priority: 1},
+		{ID: 2, Name: "backup", Priority: 2},
+	}}
+	routes := staticRoutes{routes: []models.OutboundRoute{
+		{ID: 1, Name: "emergency", Patterns: `["000","112"]`, TrunkIDs: `[1]`, Class: dialplan.ClassEmergency},
+		{ID: 2, Name: "premium", Patterns: `["_190."]`, TrunkIDs: `[1]`, Class: dialplan.ClassPremium},
+		{ID: 3, Name: "international", Patterns: `["_0011."]`, TrunkIDs: `[2,1]`, Class: dialplan.ClassInternational, PrefixStrip: 4, PrefixAdd: "+"},
+		{ID: 4, Name: "national", Patterns: `["_0[2-478]XXXXXXXX"]`, TrunkIDs: `[1,3,2]`, Class: dialplan.ClassNational},
+	}}
+	r := NewOutboundRouter(trunks, routes, nil, nil, nil, logger)
+
+	national := &models.Extension{Extension: "101", ClassOfService: dialplan.ClassNational}
+	internalOnly := &models.Extension{Extension: "102", ClassOfService: dialplan.ClassInternal}
+
+	plan, err := r.Route(context.Background(), "0298765432", national)
+	if err != nil {
+		t.Fatalf("Route(national): %v", err)
+	}
+	if plan.Route == nil || plan.Route.ID != 4 || plan.Number != "0298765432" {
+		t.Errorf("Route(national) = %+v, want the national route, number unchanged", plan)
+	}
+	if len(plan.Trunks) != 2 || plan.Trunks[0].ID != 1 || plan.Trunks[1].ID != 2 {
+		t.Errorf("trunks = %+v, want 1 then 2 with the missing trunk skipped", plan.Trunks)
+	}
+
+	plan, err = r.Route(context.Background(), "00114420794600", nil)
+	if err != nil {
+		t.Fatalf("Route(international): %v", err)
+	}
+	if plan.Number != "+4420794600" || plan.Trunks[0].ID != 2 {
+		t.Errorf("Route(international) = %q via trunk %d, want +4420794600 via trunk 2", plan.Number, plan.Trunks[0].ID)
+	}
+
+	if _, err := r.Route(context.Background(), "00114420794600", national); !errors.Is(err, ErrCallNotPermitted) {
+		t.Errorf("national extension dialling international: err = %v, want ErrCallNotPermitted", err)
+	}
+	if _, err := r.Route(context.Background(), "0298765432", internalOnly); !errors.Is(err, ErrCallNotPermitted) {
+		t.Errorf("internal extension dialling national: err = %v, want ErrCallNotPermitted", err)
+	}
+	if _, err := r.Route(context.Background(), "000", internalOnly); err != nil {
+		t.Errorf("internal extension dialling emergency: %v", err)
+	}
+	if _, err := r.Route(context.Background(), "12345", national); !errors.Is(err, ErrNoOutboundRoute) {
+		t.Errorf("unmatched number: err = %v, want ErrNoOutboundRoute", err)
+	}
+}
+
+func TestOutboundRouterRouteWithoutRoutes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	trunks := staticTrunks{trunks: []models.Trunk{{ID: 1, Name: "primary"}}}
+	r := NewOutboundRouter(trunks, staticRoutes{}, nil, nil, nil, logger)
+
+	plan, err := r.Route(context.Background(), "0298765432", &models.Extension{ClassOfService: dialplan.ClassLocal})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if plan.Route != nil || plan.Number != "0298765432" || len(plan.Trunks) != 1 {
+		t.Errorf("Route = %+v, want every trunk and the number unchanged", plan)
+	}
+
+	if _, err := r.Route(context.Background(), "0298765432", &models.Extension{ClassOfService: dialplan.ClassInternal}); !errors.Is(err, ErrCallNotPermitted) {
+		t.Errorf("internal extension: err = %v, want ErrCallNotPermitted", err)
+	}
+}
+
+// staticRates serves fixed rate decks keyed by trunk ID.
+type staticRates struct {
+	database.TrunkRateRepository
+	decks map[int64][]models.TrunkRate
+}
+
+func (s staticRates) LongestPrefix(_ context.Context, trunkID int64, number string) (*models.TrunkRate, error) {
+	var best *models.TrunkRate
+	for i, rate := range s.decks[trunkID] {
+		if strings.HasPrefix(number, rate.Prefix) && (best == nil || len(rate.Prefix) > len(best.Prefix)) {
+			best = &s.decks[trunkID][i]
+		}
+	}
+	return best, nil
+}
+
+func TestOutboundRouterRouteCheapestFirst(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	trunks := staticTrunks{trunks: []models.Trunk{
+		{ID: 1, Name: "unrated", Priority: 1},
+		{ID: 2, Name: "expensive", Priority: 2},
+		{ID: 3, Name: "cheap", Priority: 3},
+	}}
+	rates := staticRates{decks: map[int64][]models.TrunkRate{
+		2: {{Prefix: "04", Rate: 0.02}, {Prefix: "0", Rate: 0.10}},
+		3: {{Prefix: "0", Rate: 0.05}},
+	}}
+	r := NewOutboundRouter(trunks, staticRoutes{}, rates, nil, nil, logger)
+
+	plan, err := r.Route(context.Background(), "0298765432", nil)
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	var order []int64
+	for _, trunk := range plan.Trunks {
+		order = append(order, trunk.ID)
+	}
+	if !slices.Equal(order, []int64{3, 2, 1}) {
+		t.Errorf("trunk order = %v, want [3 2 1]", order)
+	}
+	if got := plan.rate(2); got == nil || *got != 0.10 {
+		t.Errorf("rate of trunk 2 = %v, want 0.10", got)
+	}
+	if plan.rate(1) != nil {
+		t.Error("expected no rate for the unrated trunk")
+	}
+
+	// The longest matching prefix decides: mobiles are cheapest on trunk 2.
+	plan, err = r.Route(context.Background(), "0412345678", nil)
+	if err != nil {
+		t.Fatalf("Route(mobile): %v", err)
+	}
+	if plan.Trunks[0].ID != 2 {
+		t.Errorf("first trunk for a mobile = %d, want 2", plan.Trunks[0].ID)
+	}
+}
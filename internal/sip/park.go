@@ -0,0 +1,670 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+const (
+	// parkFeatureCode parks the other party of the dialling extension's
+	// current call in the first enabled park lot.
+	parkFeatureCode = "*70"
+
+	// callParkedPrompt is played to the parker before the orbit number.
+	callParkedPrompt = "prompts/system/call_parked.wav"
+
+	// defaultParkTimeout is how long, in seconds, a call stays parked
+	// when its lot has no timeout set.
+	defaultParkTimeout = 120
+
+	hangupCauseParkTimeout = "park_timeout"
+)
+
+// parkTimeoutFlow is the park lot timeout action that sends timed out
+// calls to a flow node instead of back to the parker ("return").
+const parkTimeoutFlow = "flow"
+
+// errNoParkOrbit is returned when a lot has no free orbit, or the orbit
+// asked for is taken.
+var errNoParkOrbit = errors.New("no free park orbit")
+
+// parkedCall is a call waiting in a park orbit. The parked party stays on
+// its side of the dialog; the other side is vacant until the call is
+// retrieved or returned.
+type parkedCall struct {
+	lot    models.ParkLot
+	orbit  string
+	dialog *Dialog
+
+	// callerSide reports whether the parked party is the dialog's caller.
+	callerSide bool
+
+	// parkedBy is the extension that parked the call.
+	parkedBy *models.Extension
+	parkedAt time.Time
+
+	// timer fires when the call has been parked for the lot's timeout.
+	// Guarded by ParkManager.mu.
+	timer *time.Timer
+}
+
+// vacant reports whether one side of the dialog has been left empty by
+// parking the call.
+func (d *Dialog) vacant(callerSide bool) bool {
+	p := d.parked.Load()
+	return p != nil && p.callerSide != callerSide
+}
+
+// ParkOrbit returns the orbit the call is parked in and the extension
+// that parked it, or empty strings if the call is not parked.
+func (d *Dialog) ParkOrbit() (orbit, parkedBy string) {
+	p := d.parked.Load()
+	if p == nil {
+		return "", ""
+	}
+	return p.orbit, p.parkedBy.Extension
+}
+
+// matchParkNumber resolves a dialled number against the enabled park
+// lots. The park code of a lot (or the park feature code, for the first
+// lot) returns the lot with an empty orbit; an orbit number returns the
+// lot and the orbit.
+func matchParkNumber(lots []models.ParkLot, number string) (*models.ParkLot, string, bool) {
+	if number == parkFeatureCode && len(lots) > 0 {
+		return &lots[0], "", true
+	}
+	n, err := strconv.Atoi(number)
+	for i := range lots {
+		lot := &lots[i]
+		if lot.ParkCode == number {
+			return lot, "", true
+		}
+		if err == nil && strconv.Itoa(n) == number && n >= lot.OrbitStart && n <= lot.OrbitEnd {
+			return lot, number, true
+		}
+	}
+	return nil, "", false
+}
+
+// ParkManager tracks the calls waiting in park orbits. Calls are parked
+// with a REFER to a park code or orbit, or by dialling a park code or
+// *70 while another call is on hold; dialling an orbit retrieves the call
+// parked there. Orbits live in memory only.
+type ParkManager struct {
+	lots      database.ParkLotRepository
+	callFlows database.CallFlowRepository
+	actions   *FlowSIPActions
+	dialogMgr *DialogManager
+	logger    *slog.Logger
+
+	mu     sync.Mutex
+	orbits map[string]*parkedCall
+}
+
+// NewParkManager creates a park manager. actions places the calls that
+// return a timed out parked call.
+func NewParkManager(
+	lots database.ParkLotRepository,
+	callFlows database.CallFlowRepository,
+	actions *FlowSIPActions,
+	dialogMgr *DialogManager,
+	logger *slog.Logger,
+) *ParkManager {
+	return &ParkManager{
+		lots:      lots,
+		callFlows: callFlows,
+		actions:   actions,
+		dialogMgr: dialogMgr,
+		logger:    logger.With("subsystem", "park"),
+		orbits:    make(map[string]*parkedCall),
+	}
+}
+
+// Lookup resolves a dialled number to an enabled park lot and, for an
+// orbit number, the orbit. It returns a nil lot if the number belongs to
+// no lot.
+func (pm *ParkManager) Lookup(ctx context.Context, number string) (*models.ParkLot, string, error) {
+	lots, err := pm.lots.ListEnabled(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("listing park lots: %w", err)
+	}
+	lot, orbit, _ := matchParkNumber(lots, number)
+	return lot, orbit, nil
+}
+
+// occupiedLocked reports whether a call is still waiting in the orbit.
+// Entries for calls that have since ended, been retrieved or returned are
+// dropped. Must be called with pm.mu held.
+func (pm *ParkManager) occupiedLocked(orbit string) bool {
+	p, ok := pm.orbits[orbit]
+	if !ok {
+		return false
+	}
+	if p.dialog.parked.Load() == p {
+		return true
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	delete(pm.orbits, orbit)
+	return false
+}
+
+// freeOrbitLocked returns the lowest free orbit of lot, or "" if all are
+// taken. Must be called with pm.mu held.
+func (pm *ParkManager) freeOrbitLocked(lot *models.ParkLot) string {
+	for n := lot.OrbitStart; n <= lot.OrbitEnd; n++ {
+		if orbit := strconv.Itoa(n); !pm.occupiedLocked(orbit) {
+			return orbit
+		}
+	}
+	return ""
+}
+
+// park leaves the party on parkedSide of d waiting in an orbit of lot,
+// the given one or the first free one if orbit is empty, and plays it
+// hold music. The parker's leg is retired but not sent a BYE. Returns
+// the orbit.
+func (pm *ParkManager) park(d *Dialog, parkedSide bool, parker *models.Extension, lot *models.ParkLot, orbit string) (string, error) {
+	if d.Media == nil {
+		return "", errTransferNoMedia
+	}
+
+	pm.mu.Lock()
+	if orbit == "" {
+		orbit = pm.freeOrbitLocked(lot)
+	} else if pm.occupiedLocked(orbit) {
+		orbit = ""
+	}
+	if orbit == "" {
+		pm.mu.Unlock()
+		return "", errNoParkOrbit
+	}
+
+	p := &parkedCall{
+		lot:        *lot,
+		orbit:      orbit,
+		dialog:     d,
+		callerSide: parkedSide,
+		parkedBy:   parker,
+		parkedAt:   time.Now(),
+	}
+	if !pm.dialogMgr.ParkLeg(d, p) {
+		pm.mu.Unlock()
+		return "", errTransferCallEnded
+	}
+	pm.orbits[orbit] = p
+	pm.armLocked(p)
+	pm.mu.Unlock()
+
+	// Nothing is relayed to the vacant side until someone takes it.
+	if err := setLegHeld(d.Media, !parkedSide, true); err != nil {
+		pm.logger.Error("failed to hold vacant side of parked call",
+			"call_id", d.CallID,
+			"error", err,
+		)
+	}
+	playHoldMusic(d, parkedSide, pm.actions.moh, parker, pm.logger)
+
+	pm.logger.Info("call parked",
+		"call_id", d.CallID,
+		"lot", lot.Name,
+		"orbit", orbit,
+		"parked_by", parker.Extension,
+	)
+	return orbit, nil
+}
+
+// armLocked starts the timer that returns a parked call when its lot's
+// timeout passes. Must be called with pm.mu held.
+func (pm *ParkManager) armLocked(p *parkedCall) {
+	timeout := p.lot.Timeout
+	if timeout <= 0 {
+		timeout = defaultParkTimeout
+	}
+	p.timer = time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+		pm.timedOut(p)
+	})
+}
+
+// parkedIn returns the call waiting in an orbit, or nil.
+func (pm *ParkManager) parkedIn(orbit string) *parkedCall {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if !pm.occupiedLocked(orbit) {
+		return nil
+	}
+	return pm.orbits[orbit]
+}
+
+// claim takes a parked call out of its orbit so that only one of a
+// retrieval and the timeout acts on it. Returns false if the call has
+// already left the orbit.
+func (pm *ParkManager) claim(p *parkedCall) bool {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if pm.orbits[p.orbit] != p || !pm.occupiedLocked(p.orbit) {
+		return false
+	}
+	p.timer.Stop()
+	delete(pm.orbits, p.orbit)
+	return true
+}
+
+// unclaim puts a claimed call back in its orbit after a failed retrieval,
+// with its timeout restarted.
+func (pm *ParkManager) unclaim(p *parkedCall) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if p.dialog.parked.Load() != p || pm.occupiedLocked(p.orbit) {
+		return
+	}
+	pm.orbits[p.orbit] = p
+	pm.armLocked(p)
+}
+
+// timedOut handles a call left in its orbit for the lot's timeout. It is
+// rung back to the extension that parked it or, for a lot with the flow
+// timeout action, sent into the lot's flow at its timeout node. A call
+// that cannot be placed anywhere is hung up.
+func (pm *ParkManager) timedOut(p *parkedCall) {
+	if !pm.claim(p) {
+		return
+	}
+	d := p.dialog
+
+	pm.logger.Info("parked call timed out",
+		"call_id", d.CallID,
+		"orbit", p.orbit,
+		"action", p.lot.TimeoutAction,
+	)
+
+	target, timeout := pm.timeoutFlowTarget(p), localFlowAnswerTimeout
+	if target == nil {
+		timeout = transferRingTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var err error
+	if target == nil {
+		target, err = pm.actions.resolveTransferTarget(ctx, p.parkedBy.Extension, nil)
+	}
+	if err == nil {
+		err = pm.actions.transferLeg(ctx, d, !p.callerSide, target)
+	}
+	if err == nil {
+		if err := setLegHeld(d.Media, !p.callerSide, false); err != nil {
+			pm.logger.Error("failed to resume media to returned call",
+				"call_id", d.CallID,
+				"error", err,
+			)
+		}
+		return
+	}
+	if errors.Is(err, errTransferCallEnded) {
+		return
+	}
+
+	pm.logger.Warn("timed out parked call could not be placed",
+		"call_id", d.CallID,
+		"orbit", p.orbit,
+		"error", err,
+	)
+	pm.actions.hangupDialog(d, hangupCauseParkTimeout)
+}
+
+// timeoutFlowTarget returns the flow entry a timed out parked call is
+// sent to for a lot with the flow timeout action, or nil if the call goes
+// back to the parker. The API checks the node when the lot is saved and
+// when its flow is published, rolled back or deleted; if the flow still
+// cannot be entered, the call is returned to the parker.
+func (pm *ParkManager) timeoutFlowTarget(p *parkedCall) *transferTarget {
+	if p.lot.TimeoutAction != parkTimeoutFlow || p.lot.TimeoutFlowID == nil {
+		return nil
+	}
+	flowID, node := *p.lot.TimeoutFlowID, p.lot.TimeoutFlowNode
+
+	ctx, cancel := context.WithTimeout(context.Background(), transferRingTimeout)
+	defer cancel()
+	if err := flow.CheckEntry(ctx, pm.callFlows, flowID, node); err != nil {
+		pm.logger.Warn("park timeout flow unusable, returning call to parker",
+			"call_id", p.dialog.CallID,
+			"flow_id", flowID,
+			"node", node,
+			"error", err,
+		)
+		return nil
+	}
+	return flowTarget(flowID, node)
+}
+
+// ParkLeg retires the leg on the opposite side of the parked party of p
+// and leaves that side vacant, with no extension, until the call is
+// retrieved or returned. Returns false if the dialog is no longer active.
+func (dm *DialogManager) ParkLeg(d *Dialog, p *parkedCall) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if dm.dialogs[d.CallID] != d {
+		return false
+	}
+
+	vacant := !p.callerSide
+	dm.retireLeg(d, d.leg(vacant))
+	d.resetLegMedia(vacant)
+	d.endSupervision()
+	dm.notifyChange(d)
+
+	if vacant {
+		d.Caller.Extension = nil
+		d.Caller.Registration = nil
+	} else {
+		d.Callee.Extension = nil
+		d.Callee.Registration = nil
+	}
+	d.parked.Store(p)
+
+	dm.logger.Info("dialog parked",
+		"call_id", d.CallID,
+		"orbit", p.orbit,
+		"caller_parked", p.callerSide,
+	)
+	dm.notifyChange(d)
+	return true
+}
+
+// AttachLeg fills the vacant side of a parked dialog with a device that
+// called the PBX to retrieve it. req is the device's INVITE and tx its
+// transaction, answered by the PBX. Returns false if the dialog is no
+// longer active.
+func (dm *DialogManager) AttachLeg(d *Dialog, callerSide bool, leg CallLeg, req *sip.Request, tx sip.ServerTransaction) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if dm.dialogs[d.CallID] != d {
+		return false
+	}
+
+	dm.notifyChange(d)
+	d.parked.Store(nil)
+
+	if callerSide {
+		d.Caller = leg
+		d.CallerTx = tx
+		d.CallerReq = req
+		d.CallerOutReq = nil
+		d.CallerOutRes = nil
+		d.callerSeq = new(atomic.Uint32)
+	} else {
+		d.Callee = leg
+		d.CalleeTx = nil
+		d.CalleeReq = nil
+		d.CalleeRes = nil
+		d.CalleeInReq = req
+		d.calleeSeq = new(atomic.Uint32)
+	}
+	if leg.CallID != d.CallID {
+		dm.legs[leg.CallID] = d.CallID
+	}
+
+	dm.logger.Info("dialog leg attached",
+		"call_id", d.CallID,
+		"caller_side", callerSide,
+		"leg_call_id", leg.CallID,
+	)
+	dm.notifyChange(d)
+	return true
+}
+
+// handlePark parks the other party of the dialling extension's current
+// call (normally one it has just put on hold) in the lot of the park code
+// or *70 it dialled. The PBX answers, announces the orbit, hangs up, and
+// sends BYE to the extension's leg of the parked call.
+func (h *InviteHandler) handlePark(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string) {
+	if h.parkMgr == nil || h.flowActions == nil {
+		h.respondErrorWithCDR(req, tx, 501, "Not Implemented", callID)
+		return
+	}
+
+	ext := ic.CallerExtension
+	d, parkerIsCaller := h.dialogMgr.ExtensionCall(ext.Extension)
+	if d == nil {
+		h.logger.Info("park: extension has no call to park",
+			"call_id", callID,
+			"extension", ext.Extension,
+		)
+		h.respondErrorWithCDR(req, tx, 404, "Not Found", callID)
+		return
+	}
+	if !d.transferring.CompareAndSwap(false, true) {
+		h.respondErrorWithCDR(req, tx, 491, "Request Pending", callID)
+		return
+	}
+
+	parkerLeg := d.leg(parkerIsCaller)
+	orbit, err := h.parkMgr.park(d, !parkerIsCaller, ext, ic.ParkLot, "")
+	d.transferring.Store(false)
+	if err != nil {
+		code, reason := transferFailureStatus(err)
+		h.logger.Warn("park failed",
+			"call_id", callID,
+			"parked_call_id", d.CallID,
+			"lot", ic.ParkLot.Name,
+			"error", err,
+		)
+		h.respondErrorWithCDR(req, tx, code, reason, callID)
+		return
+	}
+	h.flowActions.sendLegBYE(parkerLeg, d.CallID)
+
+	ctx := context.Background()
+	callCtx := flow.NewCallContext(callID, ic.CallerIDName, ic.CallerIDNum, ic.RequestURI, nil, 0, req, tx)
+	if err := h.flowActions.AnswerCall(ctx, callCtx); err != nil {
+		h.logger.Warn("park: failed to answer",
+			"call_id", callID,
+			"error", err,
+		)
+		h.flowActions.releaseEarlyMedia(callID)
+		h.finalizeCDRFailed(callID, 487)
+		return
+	}
+
+	prompts := []string{filepath.Join(h.dataDir, callParkedPrompt)}
+	for _, digit := range orbit {
+		prompts = append(prompts, filepath.Join(h.dataDir, "prompts", "system", "digit_"+string(digit)+".wav"))
+	}
+	for _, prompt := range prompts {
+		if err := h.flowActions.PlayPrompt(ctx, callCtx, prompt); err != nil {
+			h.logger.Warn("park: failed to announce orbit",
+				"call_id", callID,
+				"orbit", orbit,
+				"error", err,
+			)
+			break
+		}
+	}
+	if err := h.flowActions.HangupCall(ctx, callCtx, 200, "normal_clearing"); err != nil {
+		h.logger.Error("park: failed to hang up",
+			"call_id", callID,
+			"error", err,
+		)
+	}
+	h.flowActions.releaseEarlyMedia(callID)
+}
+
+// handleParkRetrieve connects an extension that dialled an orbit to the
+// call parked there. The retriever's device takes the vacant side of the
+// parked dialog, whose CDR carries on; the retrieval gets no CDR of its
+// own.
+func (h *InviteHandler) handleParkRetrieve(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, retrieverCallID string) {
+	if h.parkMgr == nil {
+		h.respondError(req, tx, 501, "Not Implemented")
+		return
+	}
+
+	p := h.parkMgr.parkedIn(ic.ParkOrbit)
+	if p == nil {
+		h.logger.Info("park retrieve found no parked call",
+			"call_id", retrieverCallID,
+			"orbit", ic.ParkOrbit,
+		)
+		h.respondError(req, tx, 404, "Not Found")
+		return
+	}
+	d := p.dialog
+	vacant := !p.callerSide
+
+	// Check the media before taking the call out of its orbit, so an
+	// incompatible device leaves it parked.
+	answer, legSRTP, remote, err := h.parkRetrieveAnswer(d, vacant, req.Body(), ic.CallerExtension.SRTPMode)
+	if err != nil {
+		h.logger.Warn("park retrieve rejected: incompatible media",
+			"call_id", d.CallID,
+			"retriever_call_id", retrieverCallID,
+			"error", err,
+		)
+		h.respondError(req, tx, 488, "Not Acceptable Here")
+		return
+	}
+
+	if !h.parkMgr.claim(p) {
+		h.respondError(req, tx, 404, "Not Found")
+		return
+	}
+
+	if err := d.Media.SetLegSRTP(vacant, legSRTP); err == nil {
+		err = setLegRemote(d.Media, vacant, remote)
+	}
+	if err != nil {
+		h.logger.Error("failed to connect media to parked call",
+			"call_id", d.CallID,
+			"error", err,
+		)
+		h.parkMgr.unclaim(p)
+		h.respondError(req, tx, 500, "Internal Server Error")
+		return
+	}
+
+	res := sip.NewResponseFromRequest(req, 200, "OK", answer)
+	res.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+	if err := tx.Respond(res); err != nil {
+		h.logger.Error("failed to send 200 ok to park retriever",
+			"call_id", d.CallID,
+			"retriever_call_id", retrieverCallID,
+			"error", err,
+		)
+		h.parkMgr.unclaim(p)
+		return
+	}
+
+	leg := CallLeg{
+		Extension: ic.CallerExtension,
+		CallID:    retrieverCallID,
+	}
+	if from := req.From(); from != nil {
+		leg.FromTag, _ = from.Params.Get("tag")
+	}
+	if to := res.To(); to != nil {
+		leg.ToTag, _ = to.Params.Get("tag")
+	}
+	if contact := req.Contact(); contact != nil {
+		leg.ContactURI = contact.Address.String()
+	}
+
+	if !h.dialogMgr.AttachLeg(d, vacant, leg, req, tx) {
+		// The parked party hung up while the retriever was answered.
+		h.flowActions.sendLegBYE(dialogLeg{
+			callID:    retrieverCallID,
+			remoteTag: leg.FromTag,
+			uas:       true,
+			req:       req,
+			localTag:  leg.ToTag,
+			seq:       new(atomic.Uint32),
+		}, d.CallID)
+		return
+	}
+	if err := setLegHeld(d.Media, vacant, false); err != nil {
+		h.logger.Error("failed to resume media to retrieved call",
+			"call_id", d.CallID,
+			"error", err,
+		)
+	}
+	d.stopHoldMusicTo(p.callerSide)
+
+	h.logger.Info("parked call retrieved",
+		"call_id", d.CallID,
+		"orbit", p.orbit,
+		"retrieved_by", ic.CallerExtension.Extension,
+		"parked_secs", int(time.Since(p.parkedAt).Seconds()),
+	)
+}
+
+// parkRetrieveAnswer builds the SDP answer to a retriever's offer: the
+// parked party's description pointed at the proxy socket of the vacant
+// side, in the codec that side of the relay already uses. It returns the
+// answer, the retriever leg's SRTP keys and its RTP address.
+func (h *InviteHandler) parkRetrieveAnswer(d *Dialog, vacant bool, offerSDP []byte, srtpMode string) ([]byte, *media.LegSRTP, *net.UDPAddr, error) {
+	offer, err := media.ParseSDP(offerSDP)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing retriever sdp: %w", err)
+	}
+	offerAudio := offer.AudioMedia()
+	if offerAudio == nil {
+		return nil, nil, nil, fmt.Errorf("retriever sdp has no audio media")
+	}
+	remote, err := extractRTPAddr(offer)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("extracting retriever rtp address: %w", err)
+	}
+
+	parkedSD, err := media.ParseSDP(d.leg(!vacant).remoteSDP())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing parked party sdp: %w", err)
+	}
+	port := d.Media.CalleeRTPPort()
+	if vacant {
+		port = d.Media.CallerRTPPort()
+	}
+	answer := media.RewriteSDP(parkedSD, h.proxyIP, port)
+
+	if codec := negotiatedCodec(d, vacant); codec != "" {
+		offerPT, ok := codecPayloadType(offerAudio, codec)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("retriever does not offer %s", codec)
+		}
+		if audio := answer.AudioMedia(); audio != nil {
+			if _, ok := codecPayloadType(audio, codec); ok {
+				narrowAnswer(answer, offer, codec)
+			} else if lc, ok := legCodec(offerAudio, offerPT); ok {
+				// The relay transcodes, so the parked party's SDP does
+				// not have the codec of the vacant side.
+				answerWithCodec(answer, sdpCodec(offerAudio, lc))
+			}
+		}
+	}
+
+	legSRTP, err := answerSRTP(offerAudio, srtpMode)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if audio := answer.AudioMedia(); audio != nil {
+		audio.SetDirection(media.AnswerDirection(offerAudio.Direction))
+		setAnswerSRTP(audio, offerAudio, legSRTP)
+	}
+	return answer.Marshal(), legSRTP, remote, nil
+}
@@ -0,0 +1,67 @@
+package sip
+
+import (
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+func TestMatchParkNumber(t *testing.T) {
+	lots := []models.ParkLot{
+		{ID: 1, ParkCode: "700", OrbitStart: 701, OrbitEnd: 720},
+		{ID: 2, ParkCode: "800", OrbitStart: 801, OrbitEnd: 805},
+	}
+
+	tests := []struct {
+		number    string
+		wantLot   int64
+		wantOrbit string
+	}{
+		{"*70", 1, ""},
+		{"700", 1, ""},
+		{"800", 2, ""},
+		{"701", 1, "701"},
+		{"720", 1, "720"},
+		{"803", 2, "803"},
+		{"721", 0, ""},
+		{"0701", 0, ""},
+		{"*71", 0, ""},
+	}
+	for _, tt := range tests {
+		lot, orbit, ok := matchParkNumber(lots, tt.number)
+		var got int64
+		if ok {
+			got = lot.ID
+		}
+		if got != tt.wantLot || orbit != tt.wantOrbit {
+			t.Errorf("matchParkNumber(%q) = lot %d orbit %q, want lot %d orbit %q", tt.number, got, orbit, tt.wantLot, tt.wantOrbit)
+		}
+	}
+
+	if _, _, ok := matchParkNumber(nil, "*70"); ok {
+		t.Error("matchParkNumber(*70) with no lots matched")
+	}
+}
+
+func TestFreeOrbit(t *testing.T) {
+	pm := &ParkManager{orbits: make(map[string]*parkedCall)}
+	lot := &models.ParkLot{OrbitStart: 701, OrbitEnd: 702}
+
+	d := &Dialog{}
+	p := &parkedCall{dialog: d, orbit: "701"}
+	d.parked.Store(p)
+	pm.orbits["701"] = p
+
+	if got := pm.freeOrbitLocked(lot); got != "702" {
+		t.Errorf("freeOrbitLocked with 701 taken = %q, want 702", got)
+	}
+
+	// A call that left park frees its orbit.
+	d.parked.Store(nil)
+	if got := pm.freeOrbitLocked(lot); got != "701" {
+		t.Errorf("freeOrbitLocked after unpark = %q, want 701", got)
+	}
+	if _, ok := pm.orbits["701"]; ok {
+		t.Error("stale orbit 701 not removed")
+	}
+}
@@ -0,0 +1,431 @@
+package sip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// Call pickup feature codes.
+const (
+	// groupPickupCode answers the longest-ringing call in the caller's
+	// pickup scope: its ring groups and pickup group.
+	groupPickupCode = "*8"
+
+	// directedPickupPrefix followed by an extension number answers the
+	// call ringing that extension.
+	directedPickupPrefix = "**"
+)
+
+// parsePickupCode reports whether a dialled number is a pickup feature
+// code. For directed pickup it returns the target extension; for group
+// pickup the target is empty.
+func parsePickupCode(user string) (string, bool) {
+	if user == groupPickupCode {
+		return "", true
+	}
+	if target, ok := strings.CutPrefix(user, directedPickupPrefix); ok && target != "" {
+		return target, true
+	}
+	return "", false
+}
+
+// handlePickup answers a call ringing another extension on behalf of the
+// extension that dialled a pickup code. The other forked legs are
+// cancelled, the picking-up device is bridged to the caller, and the
+// original call's dialog and CDR carry on with the picker as callee.
+func (h *InviteHandler) handlePickup(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, pickerCallID string) {
+	ctx := context.Background()
+
+	scope := []string{ic.PickupExtension}
+	if ic.PickupExtension == "" {
+		var err error
+		scope, err = h.pickupScope(ctx, ic.CallerExtension)
+		if err != nil {
+			h.logger.Error("failed to resolve pickup scope",
+				"call_id", pickerCallID,
+				"extension", ic.CallerExtension.Extension,
+				"error", err,
+			)
+			h.respondError(req, tx, 500, "Internal Server Error")
+			return
+		}
+	}
+
+	pc := h.pendingMgr.RingingCall(scope)
+	if pc == nil {
+		h.logger.Info("pickup found no ringing call",
+			"call_id", pickerCallID,
+			"extension", ic.CallerExtension.Extension,
+			"target", ic.PickupExtension,
+		)
+		h.respondError(req, tx, 404, "Not Found")
+		return
+	}
+	callID := pc.CallID
+	callerSDP := pc.CallerReq.Body()
+
+	// Check the media before taking the call away from the devices
+	// ringing, so an incompatible picker leaves the call ringing.
+	if len(callerSDP) > 0 {
+		if _, err := pickupCodec(callerSDP, req.Body()); err != nil {
+			h.logger.Warn("pickup rejected: incompatible media",
+				"call_id", callID,
+				"picker_call_id", pickerCallID,
+				"error", err,
+			)
+			h.respondError(req, tx, 488, "Not Acceptable Here")
+			return
+		}
+	}
+
+	// Claim the call. If it was answered or cancelled in the meantime
+	// there is nothing left to pick up.
+	if pc = h.pendingMgr.Pickup(callID); pc == nil {
+		h.respondError(req, tx, 404, "Not Found")
+		return
+	}
+
+	// Stop any early media before the relay takes over the caller leg,
+	// then cancel the forked legs still ringing.
+	var earlyTag string
+	if h.flowActions != nil {
+		earlyTag = h.flowActions.detachEarlyMedia(callID)
+	}
+	pc.CancelFork()
+
+	h.logger.Info("call picked up",
+		"call_id", callID,
+		"picker_call_id", pickerCallID,
+		"extension", ic.CallerExtension.Extension,
+		"target", ic.PickupExtension,
+	)
+
+	callerBody, pickerBody, mediaSession, err := h.bridgePickup(pc, req.Body(), ic.CallerExtension.SRTPMode)
+	if err != nil {
+		h.logger.Error("failed to bridge picked up call",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondError(pc.CallerReq, pc.CallerTx, 500, "Internal Server Error")
+		h.finalizeCDRFailed(callID, 500)
+		code, reason := mediaErrorStatus(err)
+		h.respondError(req, tx, code, reason)
+		return
+	}
+
+	okResponse := sip.NewResponseFromRequest(pc.CallerReq, 200, "OK", callerBody)
+	if len(callerBody) > 0 {
+		okResponse.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+	}
+	if earlyTag != "" {
+		// Keep the To tag from the 183 so the caller sees a single dialog.
+		okResponse.To().Params.Add("tag", earlyTag)
+	}
+	if err := pc.CallerTx.Respond(okResponse); err != nil {
+		h.logger.Error("failed to send 200 ok to picked up caller",
+			"call_id", callID,
+			"error", err,
+		)
+		if mediaSession != nil {
+			mediaSession.Release()
+		}
+		h.respondError(req, tx, 500, "Internal Server Error")
+		return
+	}
+
+	pickerResponse := sip.NewResponseFromRequest(req, 200, "OK", pickerBody)
+	if len(pickerBody) > 0 {
+		pickerResponse.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+	}
+	if err := tx.Respond(pickerResponse); err != nil {
+		h.logger.Error("failed to send 200 ok to pickup device",
+			"call_id", callID,
+			"picker_call_id", pickerCallID,
+			"error", err,
+		)
+	}
+
+	dialog := h.pickupDialog(ctx, pc, okResponse, req, pickerResponse, ic.CallerExtension)
+	dialog.Media = mediaSession
+	if shouldRecord(h.globalRecordingPolicy(), dialog.Caller.Extension, ic.CallerExtension, nil) && mediaSession != nil {
+		dialog.Recorder = h.startCallRecording(callID, mediaSession)
+	}
+
+	h.dialogMgr.CreateDialog(dialog)
+	h.updateCDROnAnswer(callID, 0, nil)
+
+	h.logger.Info("picked up call dialog established",
+		"call_id", callID,
+		"caller", dialog.CallerIDNum,
+		"picked_up_by", ic.CallerExtension.Extension,
+		"active_calls", h.dialogMgr.ActiveCallCount(),
+		"media_bridged", mediaSession != nil,
+		"recording", dialog.Recorder != nil,
+	)
+}
+
+// bridgePickup connects the picking-up device's media to the caller. It
+// completes the call's media bridge with the picker's offer, allocating
+// one if the call was ringing without, and returns the SDP answers for
+// the caller and the picker. Without a bridge each side is handed the
+// other's description. SRTP on the picker's leg follows pickerSRTPMode.
+func (h *InviteHandler) bridgePickup(pc *PendingCall, pickerSDP []byte, pickerSRTPMode string) (callerBody, pickerBody []byte, ms *media.MediaSession, err error) {
+	callerSDP := pc.CallerReq.Body()
+	bridge := pc.Bridge
+	if bridge == nil && len(callerSDP) > 0 && h.sessionMgr != nil {
+		bridge, _, err = AllocateMediaBridge(h.sessionMgr, callerSDP, pc.CallID, h.proxyIP, SRTPPolicy{}, h.logger)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if bridge == nil {
+		return pickerSDP, callerSDP, nil, nil
+	}
+
+	codec, err := pickupCodec(callerSDP, pickerSDP)
+	if err != nil {
+		bridge.Release()
+		return nil, nil, nil, err
+	}
+
+	// Both devices sent offers, so the picker's keys come from its offer
+	// rather than an answer to the bridge's.
+	pickerSD, err := media.ParseSDP(pickerSDP)
+	if err != nil {
+		bridge.Release()
+		return nil, nil, nil, fmt.Errorf("parsing picker sdp: %w", err)
+	}
+	if pickerSD.AudioMedia() == nil {
+		bridge.Release()
+		return nil, nil, nil, fmt.Errorf("picker sdp has no audio media")
+	}
+	pickerSRTP, err := bridge.acceptCalleeOffer(pickerSD.AudioMedia(), pickerSRTPMode)
+	if err != nil {
+		bridge.Release()
+		return nil, nil, nil, err
+	}
+
+	rewritten, err := bridge.CompleteMediaBridge(pickerSDP)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ms = bridge.Session()
+
+	// Answer each device with the other's description narrowed to the
+	// negotiated codec.
+	callerAnswer, err := media.ParseSDP(rewritten)
+	if err != nil {
+		ms.Release()
+		return nil, nil, nil, fmt.Errorf("parsing sdp for caller: %w", err)
+	}
+	callerSD, err := media.ParseSDP(callerSDP)
+	if err != nil {
+		ms.Release()
+		return nil, nil, nil, fmt.Errorf("parsing caller sdp: %w", err)
+	}
+	pickerAnswer := media.RewriteSDP(callerSD, h.proxyIP, ms.CalleeRTPPort())
+	if audio := pickerAnswer.AudioMedia(); audio != nil {
+		setAnswerSRTP(audio, pickerSD.AudioMedia(), pickerSRTP)
+	}
+
+	narrowAnswer(callerAnswer, callerSD, codec)
+	narrowAnswer(pickerAnswer, pickerSD, codec)
+	return callerAnswer.Marshal(), pickerAnswer.Marshal(), ms, nil
+}
+
+// pickupCodec returns the audio codec the caller's and picker's offers
+// have in common.
+func pickupCodec(callerSDP, pickerSDP []byte) (string, error) {
+	if len(pickerSDP) == 0 {
+		return "", fmt.Errorf("picker sent no sdp offer")
+	}
+	callerSD, err := media.ParseSDP(callerSDP)
+	if err != nil {
+		return "", fmt.Errorf("parsing caller sdp: %w", err)
+	}
+	pickerSD, err := media.ParseSDP(pickerSDP)
+	if err != nil {
+		return "", fmt.Errorf("parsing picker sdp: %w", err)
+	}
+	_, codec, err := negotiateAudioCodec(callerSD, pickerSD)
+	if err != nil {
+		return "", err
+	}
+	return codec, nil
+}
+
+// narrowAnswer limits an answer's audio to the negotiated codec and DTMF
+// and sets its direction to match the offer.
+func narrowAnswer(answer, offer *media.SessionDescription, codec string) {
+	audio := answer.AudioMedia()
+	if audio == nil {
+		return
+	}
+	var keep []int
+	if pt, ok := codecPayloadType(audio, codec); ok {
+		keep = append(keep, pt)
+	}
+	if pt, ok := codecPayloadType(audio, "telephone-event"); ok {
+		keep = append(keep, pt)
+	}
+	audio.RetainFormats(keep)
+	if offerAudio := offer.AudioMedia(); offerAudio != nil {
+		audio.SetDirection(media.AnswerDirection(offerAudio.Direction))
+	}
+}
+
+// pickupDialog builds the dialog for a picked up call: the original
+// caller's leg, answered by the PBX with okResponse, and the picker's leg,
+// answered with pickerResponse.
+func (h *InviteHandler) pickupDialog(
+	ctx context.Context,
+	pc *PendingCall,
+	okResponse *sip.Response,
+	pickerReq *sip.Request,
+	pickerResponse *sip.Response,
+	picker *models.Extension,
+) *Dialog {
+	callerReq := pc.CallerReq
+	dialog := &Dialog{
+		CallID:      pc.CallID,
+		Direction:   CallTypeInbound,
+		CalledNum:   callerReq.Recipient.User,
+		StartTime:   time.Now(),
+		CallerTx:    pc.CallerTx,
+		CallerReq:   callerReq,
+		CalleeInReq: pickerReq,
+		Callee: CallLeg{
+			Extension: picker,
+		},
+	}
+
+	if from := callerReq.From(); from != nil {
+		dialog.CallerIDNum = from.Address.User
+		dialog.CallerIDName = from.DisplayName
+		if tag, ok := from.Params.Get("tag"); ok {
+			dialog.Caller.FromTag = tag
+		}
+	}
+	if to := okResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Caller.ToTag = tag
+		}
+	}
+
+	if cid := pickerReq.CallID(); cid != nil {
+		dialog.Callee.CallID = cid.Value()
+	}
+	if from := pickerReq.From(); from != nil {
+		if tag, ok := from.Params.Get("tag"); ok {
+			dialog.Callee.FromTag = tag
+		}
+	}
+	if to := pickerResponse.To(); to != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			dialog.Callee.ToTag = tag
+		}
+	}
+	if contact := pickerReq.Contact(); contact != nil {
+		dialog.Callee.ContactURI = contact.Address.String()
+	}
+
+	// The call's CDR records how it arrived.
+	cdr, err := h.cdrs.GetByCallID(ctx, pc.CallID)
+	if err != nil {
+		h.logger.Error("failed to fetch cdr for picked up call",
+			"call_id", pc.CallID,
+			"error", err,
+		)
+	}
+	if cdr != nil {
+		dialog.Direction = CallType(cdr.Direction)
+		dialog.StartTime = cdr.StartTime
+		dialog.CallerIDName = cdr.CallerIDName
+		dialog.CallerIDNum = cdr.CallerIDNum
+		dialog.CalledNum = cdr.Callee
+		if cdr.TrunkID != nil {
+			dialog.TrunkID = *cdr.TrunkID
+		}
+	}
+
+	if dialog.Direction == CallTypeInternal {
+		ext, err := h.extensions.GetByExtension(ctx, dialog.CallerIDNum)
+		if err != nil {
+			h.logger.Error("failed to look up calling extension for pickup",
+				"call_id", pc.CallID,
+				"error", err,
+			)
+		}
+		dialog.Caller.Extension = ext
+	}
+
+	return dialog
+}
+
+// pickupScope returns the extensions whose ringing calls an extension can
+// answer with group pickup: the members of every ring group it belongs
+// to and the extensions sharing its pickup group, itself included.
+func (h *InviteHandler) pickupScope(ctx context.Context, ext *models.Extension) ([]string, error) {
+	exts, err := h.extensions.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing extensions: %w", err)
+	}
+	numbers := make(map[int64]string, len(exts))
+	for _, e := range exts {
+		numbers[e.ID] = e.Extension
+	}
+
+	scope := []string{ext.Extension}
+	add := func(number string) {
+		if number != "" && !containsString(scope, number) {
+			scope = append(scope, number)
+		}
+	}
+
+	if ext.PickupGroup != "" {
+		for _, e := range exts {
+			if e.PickupGroup == ext.PickupGroup {
+				add(e.Extension)
+			}
+		}
+	}
+
+	if h.ringGroups != nil {
+		groups, err := h.ringGroups.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing ring groups: %w", err)
+		}
+		for _, rg := range groups {
+			var memberIDs []int64
+			if err := json.Unmarshal([]byte(rg.Members), &memberIDs); err != nil {
+				h.logger.Warn("invalid ring group members",
+					"ring_group_id", rg.ID,
+					"error", err,
+				)
+				continue
+			}
+			member := false
+			for _, id := range memberIDs {
+				if id == ext.ID {
+					member = true
+					break
+				}
+			}
+			if !member {
+				continue
+			}
+			for _, id := range memberIDs {
+				add(numbers[id])
+			}
+		}
+	}
+
+	return scope, nil
+}
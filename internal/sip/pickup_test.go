@@ -0,0 +1,111 @@
+package sip
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+func TestParsePickupCode(t *testing.T) {
+	tests := []struct {
+		user       string
+		wantTarget string
+		wantOK     bool
+	}{
+		{"*8", "", true},
+		{"**102", "102", true},
+		{"**", "", false},
+		{"*80", "", false},
+		{"102", "", false},
+	}
+	for _, tt := range tests {
+		target, ok := parsePickupCode(tt.user)
+		if target != tt.wantTarget || ok != tt.wantOK {
+			t.Errorf("parsePickupCode(%q) = %q, %v; want %q, %v", tt.user, target, ok, tt.wantTarget, tt.wantOK)
+		}
+	}
+}
+
+func TestPendingCallPickup(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	pm := NewPendingCallManager(logger)
+
+	now := time.Now()
+	pm.Add(&PendingCall{
+		CallID:       "newer",
+		CallerReq:    newTestDialogRequest("newer", "101", "a", "102"),
+		Extensions:   []string{"101", "102"},
+		ringingSince: now,
+	})
+	pm.Add(&PendingCall{
+		CallID:       "older",
+		CallerReq:    newTestDialogRequest("older", "0400000000", "b", "102"),
+		Extensions:   []string{"102"},
+		ringingSince: now.Add(-time.Second),
+	})
+
+	// The calling extension is not being rung by its own call.
+	if pc := pm.RingingCall([]string{"101"}); pc != nil {
+		t.Errorf("RingingCall(101) = %s, want nil", pc.CallID)
+	}
+	pc := pm.RingingCall([]string{"103", "102"})
+	if pc == nil || pc.CallID != "older" {
+		t.Fatalf("RingingCall(102) = %+v, want older", pc)
+	}
+
+	if pm.Pickup("older") == nil {
+		t.Fatal("Pickup(older) = nil")
+	}
+	if pm.Pickup("older") != nil {
+		t.Error("second Pickup(older) returned a call")
+	}
+	if !pm.PickedUp("older") || pm.PickedUp("newer") {
+		t.Error("PickedUp does not match picked up calls")
+	}
+	if pm.Get("older") != nil {
+		t.Error("picked up call still pending")
+	}
+	if pc := pm.RingingCall([]string{"102"}); pc == nil || pc.CallID != "newer" {
+		t.Errorf("RingingCall(102) after pickup = %+v, want newer", pc)
+	}
+}
+
+func TestPickedUpCalleeLegRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+
+	callerReq := newTestDialogRequest("caller-call", "101", "caller-tag", "102")
+	pickerReq := newTestDialogRequest("picker-call", "103", "picker-tag", "*8")
+	d := &Dialog{
+		CallID:      "caller-call",
+		Caller:      CallLeg{FromTag: "caller-tag", ToTag: "pbx-local"},
+		Callee:      CallLeg{CallID: "picker-call", FromTag: "picker-tag", ToTag: "pbx-picker"},
+		CallerReq:   callerReq,
+		CalleeInReq: pickerReq,
+	}
+	dm.CreateDialog(d)
+
+	if got := dm.FindDialog("picker-call", ""); got != d {
+		t.Fatal("dialog not found by picker's Call-ID")
+	}
+	if d.IsCallerRequest(newTestDialogRequest("picker-call", "103", "picker-tag", "*8")) {
+		t.Error("picker's request treated as caller's")
+	}
+
+	bye := d.leg(false).newRequest(sip.BYE)
+	if cid := bye.CallID(); cid == nil || cid.Value() != "picker-call" {
+		t.Errorf("BYE Call-ID = %v, want picker-call", bye.CallID())
+	}
+	if tag, _ := bye.From().Params.Get("tag"); tag != "pbx-picker" {
+		t.Errorf("BYE From tag = %q, want pbx-picker", tag)
+	}
+	if tag, _ := bye.To().Params.Get("tag"); tag != "picker-tag" {
+		t.Errorf("BYE To tag = %q, want picker-tag", tag)
+	}
+	if bye.Recipient.User != "103" {
+		t.Errorf("BYE Request-URI user = %q, want 103", bye.Recipient.User)
+	}
+}
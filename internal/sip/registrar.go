@@ -29,8 +29,16 @@ type Registrar struct {
 	auth          *Authenticator
 	regNotifier   *RegistrationNotifier
 	logger        *slog.Logger
+
+	// onChange, if set, is called when an extension gains its first
+	// contact or loses its last one.
+	onChange RegistrationListener
 }
 
+// RegistrationListener is called with an extension that has gained its
+// first registered contact (registered is true) or lost its last one.
+type RegistrationListener func(ext *models.Extension, registered bool)
+
 // NewRegistrar creates a new REGISTER handler.
 func NewRegistrar(
 	extensions database.ExtensionRepository,
@@ -50,6 +58,12 @@ func NewRegistrar(
 	}
 }
 
+// OnRegistrationChange registers a listener for extensions that come
+// online or go offline. Must be called before requests are handled.
+func (r *Registrar) OnRegistrationChange(fn RegistrationListener) {
+	r.onChange = fn
+}
+
 // HandleRegister processes incoming REGISTER requests.
 func (r *Registrar) HandleRegister(req *sip.Request, tx sip.ServerTransaction) {
 	r.logger.Debug("register request received",
@@ -103,6 +117,7 @@ func (r *Registrar) HandleRegister(req *sip.Request, tx sip.ServerTransaction) {
 		r.respondError(req, tx, 500, "Internal Server Error")
 		return
 	}
+	wasRegistered := count > 0
 
 	contactURI := contact.Address.String()
 
@@ -209,6 +224,9 @@ func (r *Registrar) HandleRegister(req *sip.Request, tx sip.ServerTransaction) {
 	if r.regNotifier != nil {
 		r.regNotifier.Notify(ext.ID)
 	}
+	if r.onChange != nil && !wasRegistered {
+		r.onChange(ext, true)
+	}
 
 	// Send 200 OK with the registered Contact and Expires.
 	res := sip.NewResponseFromRequest(req, 200, "OK", nil)
@@ -226,6 +244,12 @@ func (r *Registrar) HandleRegister(req *sip.Request, tx sip.ServerTransaction) {
 func (r *Registrar) handleUnregister(req *sip.Request, tx sip.ServerTransaction, ext *models.Extension, contact *sip.ContactHeader) {
 	ctx := context.Background()
 
+	// Only an extension that had contacts can go offline.
+	var before int64
+	if r.onChange != nil {
+		before, _ = r.registrations.CountByExtensionID(ctx, ext.ID)
+	}
+
 	if contact.Address.Wildcard {
 		// Contact: * — remove all registrations for this extension.
 		regs, err := r.registrations.GetByExtensionID(ctx, ext.ID)
@@ -267,6 +291,12 @@ func (r *Registrar) handleUnregister(req *sip.Request, tx sip.ServerTransaction,
 		)
 	}
 
+	if before > 0 {
+		if count, err := r.registrations.CountByExtensionID(ctx, ext.ID); err == nil && count == 0 {
+			r.onChange(ext, false)
+		}
+	}
+
 	res := sip.NewResponseFromRequest(req, 200, "OK", nil)
 	if err := tx.Respond(res); err != nil {
 		r.logger.Error("failed to send unregister response", "error", err)
@@ -288,6 +318,10 @@ func (r *Registrar) RunExpiryCleanup(ctx context.Context) {
 			r.logger.Info("registration expiry cleanup stopped")
 			return
 		case <-ticker.C:
+			var before map[int64]bool
+			if r.onChange != nil {
+				before, _ = r.registrations.RegisteredExtensionIDs(ctx)
+			}
 			deleted, err := r.registrations.DeleteExpired(ctx)
 			if err != nil {
 				r.logger.Error("failed to clean expired registrations", "error", err)
@@ -295,6 +329,7 @@ func (r *Registrar) RunExpiryCleanup(ctx context.Context) {
 			}
 			if deleted > 0 {
 				r.logger.Info("expired registrations cleaned", "count", deleted)
+				r.notifyExpired(ctx, before)
 			}
 
 			// Also clean expired nonces from the authenticator.
@@ -303,6 +338,29 @@ func (r *Registrar) RunExpiryCleanup(ctx context.Context) {
 	}
 }
 
+// notifyExpired reports the extensions in before that no longer have any
+// registered contact to the listener.
+func (r *Registrar) notifyExpired(ctx context.Context, before map[int64]bool) {
+	if len(before) == 0 {
+		return
+	}
+	after, err := r.registrations.RegisteredExtensionIDs(ctx)
+	if err != nil {
+		r.logger.Error("failed to list registered extensions", "error", err)
+		return
+	}
+	for id := range before {
+		if after[id] {
+			continue
+		}
+		ext, err := r.extensions.GetByID(ctx, id)
+		if err != nil || ext == nil {
+			continue
+		}
+		r.onChange(ext, false)
+	}
+}
+
 // parseExpiry extracts the registration expiry from the request.
 // Checks Contact params first, then Expires header, then uses default.
 func (r *Registrar) parseExpiry(req *sip.Request) int {
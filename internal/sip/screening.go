@@ -0,0 +1,440 @@
+package sip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// Caller filter lists, match types and actions.
+const (
+	filterListBlock = "block"
+	filterListAllow = "allow"
+
+	filterMatchExact     = "exact"
+	filterMatchPrefix    = "prefix"
+	filterMatchRegex     = "regex"
+	filterMatchAnonymous = "anonymous"
+
+	filterActionReject    = "reject"
+	filterActionVoicemail = "voicemail"
+	filterActionFlow      = "flow"
+)
+
+// Hangup causes recorded on the CDR of a rejected caller.
+const (
+	hangupCauseCallerBlocked     = "caller_blocked"
+	hangupCauseAnonymousRejected = "anonymous_rejected"
+)
+
+// blockLastCallerCode adds the last caller that rang the dialling
+// extension to the global blocklist.
+const blockLastCallerCode = "*60"
+
+// callerBlockedPrompt confirms a block-last-caller request, relative to
+// the data directory.
+const callerBlockedPrompt = "prompts/system/caller_blocked.wav"
+
+// anonymousCallerIDs are the From users carriers send for callers that
+// withhold their number.
+var anonymousCallerIDs = map[string]bool{
+	"":            true,
+	"anonymous":   true,
+	"private":     true,
+	"restricted":  true,
+	"unavailable": true,
+	"unknown":     true,
+	"withheld":    true,
+}
+
+// isAnonymousCaller reports whether the caller of req withheld their
+// number, either with a placeholder From user or the RFC 3323 anonymous
+// From host.
+func isAnonymousCaller(req *sip.Request) bool {
+	from := req.From()
+	if from == nil {
+		return true
+	}
+	if strings.EqualFold(from.Address.Host, "anonymous.invalid") {
+		return true
+	}
+	return anonymousCallerIDs[strings.ToLower(from.Address.User)]
+}
+
+// matchCallerFilter returns the entry of filters that decides how a caller
+// is treated on a call to the inbound number with ID inboundNumberID (zero
+// when the call matched no inbound number), or nil if none applies.
+//
+// Entries for the inbound number take precedence over global ones. Within
+// a scope the most specific match wins: exact and anonymous entries, then
+// the longest prefix, then regular expressions. An allow entry wins a tie
+// with a block entry, so allowing a number exempts it from broader blocks.
+func matchCallerFilter(filters []models.CallerFilter, callerNum string, anonymous bool, inboundNumberID int64) *models.CallerFilter {
+	var best *models.CallerFilter
+	bestRank := -1
+	for i := range filters {
+		f := &filters[i]
+		if !f.Enabled {
+			continue
+		}
+		scoped := f.InboundNumberID != nil
+		if scoped && *f.InboundNumberID != inboundNumberID {
+			continue
+		}
+
+		specificity, ok := filterSpecificity(f, callerNum, anonymous)
+		if !ok {
+			continue
+		}
+		rank := specificity * 2
+		if scoped {
+			rank += 10000
+		}
+		if f.List == filterListAllow {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = f, rank
+		}
+	}
+	return best
+}
+
+// filterSpecificity reports whether f matches the caller and, if so, how
+// specific the match is. Entries other than anonymous ones never match a
+// caller that withheld their number.
+func filterSpecificity(f *models.CallerFilter, callerNum string, anonymous bool) (int, bool) {
+	if f.MatchType == filterMatchAnonymous {
+		return 1000, anonymous
+	}
+	if anonymous {
+		return 0, false
+	}
+
+	switch f.MatchType {
+	case filterMatchExact:
+		return 1000, callerNum == f.Pattern
+	case filterMatchPrefix:
+		return 1 + len(f.Pattern), f.Pattern != "" && strings.HasPrefix(callerNum, f.Pattern)
+	case filterMatchRegex:
+		re := filterRegexp(f.Pattern)
+		if re == nil {
+			return 0, false
+		}
+		return 0, re.MatchString(callerNum)
+	}
+	return 0, false
+}
+
+// filterRegexps holds the compiled form of each regex filter pattern seen,
+// keyed by pattern, so a pattern is compiled once rather than on every
+// inbound call. Patterns that fail to compile are stored as nil.
+var filterRegexps sync.Map
+
+// filterRegexp returns the compiled form of a regex filter pattern, or nil
+// if it does not compile. The API rejects such patterns when a filter is
+// saved, so nil only covers rows written some other way.
+func filterRegexp(pattern string) *regexp.Regexp {
+	if v, ok := filterRegexps.Load(pattern); ok {
+		return v.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = nil
+	}
+	v, _ := filterRegexps.LoadOrStore(pattern, re)
+	return v.(*regexp.Regexp)
+}
+
+// screenInboundCall applies the caller filters to an inbound call. It
+// returns true if a block entry matched and the call was handled here:
+// rejected, sent to voicemail or routed to a flow node. Screening errors
+// let the call through rather than dropping it.
+func (h *InviteHandler) screenInboundCall(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string) bool {
+	if h.callerFilters == nil {
+		return false
+	}
+
+	ctx := context.Background()
+	filters, err := h.callerFilters.ListEnabled(ctx)
+	if err != nil {
+		h.logger.Error("failed to load caller filters",
+			"call_id", callID,
+			"error", err,
+		)
+		return false
+	}
+	if len(filters) == 0 {
+		return false
+	}
+
+	var inboundNumberID int64
+	if ic.InboundNumber != nil {
+		inboundNumberID = ic.InboundNumber.ID
+	}
+	anonymous := isAnonymousCaller(req)
+
+	f := matchCallerFilter(filters, ic.CallerIDNum, anonymous, inboundNumberID)
+	if f == nil {
+		return false
+	}
+	if f.List == filterListAllow {
+		h.logger.Debug("inbound caller allowed by caller filter",
+			"call_id", callID,
+			"caller", ic.CallerIDNum,
+			"filter_id", f.ID,
+		)
+		return false
+	}
+
+	h.logger.Info("inbound caller matched blocklist",
+		"call_id", callID,
+		"caller", ic.CallerIDNum,
+		"anonymous", anonymous,
+		"filter_id", f.ID,
+		"match_type", f.MatchType,
+		"action", f.Action,
+	)
+
+	switch f.Action {
+	case filterActionVoicemail:
+		if f.VoicemailBoxID != nil && h.flowEngine != nil && h.flowActions != nil {
+			h.screenToVoicemail(req, tx, ic, callID, f)
+			return true
+		}
+	case filterActionFlow:
+		if f.FlowID != nil && f.FlowNode != "" && h.flowEngine != nil {
+			callCtx := h.newInboundCallContext(req, tx, ic, callID)
+			callCtx.RecordNode(filterPathEntry(f))
+			h.executeInboundFlow(callCtx, *f.FlowID, f.FlowNode)
+			return true
+		}
+	}
+
+	// Reject, and the fallback for a voicemail or flow action whose
+	// target is gone.
+	h.respondErrorWithCDR(req, tx, 603, "Decline", callID)
+	cause := hangupCauseCallerBlocked
+	if f.MatchType == filterMatchAnonymous {
+		cause = hangupCauseAnonymousRejected
+	}
+	h.recordCallerFilterMatch(callID, f, cause)
+	return true
+}
+
+// screenToVoicemail sends a blocked caller to the voicemail box of f and
+// hangs up once the message is recorded.
+func (h *InviteHandler) screenToVoicemail(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string, f *models.CallerFilter) {
+	callCtx := h.newInboundCallContext(req, tx, ic, callID)
+	callCtx.RecordNode(filterPathEntry(f))
+
+	node := flow.Node{
+		ID:   "caller_filter_voicemail",
+		Type: "voicemail",
+		Data: flow.NodeData{
+			Label:      "Voicemail",
+			EntityID:   f.VoicemailBoxID,
+			EntityType: "voicemail_box",
+		},
+	}
+	_, err := h.flowEngine.ExecuteNode(callCtx, node)
+	h.recordCallerFilterPath(callID, callCtx.GetFlowPath())
+
+	answered := h.flowActions.pbxAnswered(callID)
+	switch {
+	case err != nil && !answered:
+		h.logger.Error("screened voicemail failed",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
+	case err != nil:
+		h.logger.Error("screened voicemail failed",
+			"call_id", callID,
+			"error", err,
+		)
+		fallthrough
+	case answered:
+		if err := h.flowActions.HangupCall(context.Background(), callCtx, 200, hangupCauseCallerBlocked); err != nil {
+			h.logger.Error("failed to hang up screened call",
+				"call_id", callID,
+				"error", err,
+			)
+		}
+	default:
+		// The caller hung up before the call was answered.
+		h.finalizeCDRFailed(callID, 487)
+	}
+
+	h.flowActions.releaseEarlyMedia(callID)
+}
+
+// filterPathEntry is the CDR flow path entry recording a caller filter
+// match.
+func filterPathEntry(f *models.CallerFilter) string {
+	return fmt.Sprintf("caller_filter_%d", f.ID)
+}
+
+// recordCallerFilterMatch records a rejected caller's filter match on the
+// call's CDR: the hangup cause, and the filter as its flow path.
+func (h *InviteHandler) recordCallerFilterMatch(callID string, f *models.CallerFilter, hangupCause string) {
+	h.updateScreenedCDR(callID, func(cdr *models.CDR) {
+		cdr.HangupCause = hangupCause
+		if path, err := json.Marshal([]string{filterPathEntry(f)}); err == nil {
+			cdr.FlowPath = string(path)
+		}
+	})
+}
+
+// recordCallerFilterPath stores the flow path of a screened call that ran
+// a single node outside of a flow.
+func (h *InviteHandler) recordCallerFilterPath(callID string, path []string) {
+	h.updateScreenedCDR(callID, func(cdr *models.CDR) {
+		if data, err := json.Marshal(path); err == nil {
+			cdr.FlowPath = string(data)
+		}
+	})
+}
+
+// updateScreenedCDR applies update to the CDR of a screened call.
+func (h *InviteHandler) updateScreenedCDR(callID string, update func(cdr *models.CDR)) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cdr, err := h.cdrs.GetByCallID(ctx, callID)
+	if err != nil {
+		h.logger.Error("failed to fetch cdr for caller filter update",
+			"call_id", callID,
+			"error", err,
+		)
+		return
+	}
+	if cdr == nil {
+		return
+	}
+
+	update(cdr)
+	if err := h.cdrs.Update(ctx, cdr); err != nil {
+		h.logger.Error("failed to record caller filter match on cdr",
+			"call_id", callID,
+			"error", err,
+		)
+	}
+}
+
+// handleBlockLastCaller adds the last caller that rang the calling
+// extension to the global blocklist, then plays a confirmation and hangs
+// up. Anonymous callers and local extensions cannot be blocked this way.
+func (h *InviteHandler) handleBlockLastCaller(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string) {
+	if h.callerFilters == nil || h.flowActions == nil {
+		h.respondErrorWithCDR(req, tx, 501, "Not Implemented", callID)
+		return
+	}
+
+	ctx := context.Background()
+	ext := ic.CallerExtension.Extension
+	caller := h.pendingMgr.LastCaller(ext)
+	if anonymousCallerIDs[strings.ToLower(caller)] {
+		h.logger.Info("block last caller: no caller to block",
+			"call_id", callID,
+			"extension", ext,
+		)
+		h.respondErrorWithCDR(req, tx, 404, "Not Found", callID)
+		return
+	}
+
+	local, err := h.extensions.GetByExtension(ctx, caller)
+	if err != nil {
+		h.logger.Error("block last caller: failed to look up caller",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
+		return
+	}
+	if local != nil {
+		h.logger.Info("block last caller: last caller is a local extension",
+			"call_id", callID,
+			"extension", ext,
+			"caller", caller,
+		)
+		h.respondErrorWithCDR(req, tx, 403, "Forbidden", callID)
+		return
+	}
+
+	if err := h.blockCaller(ctx, caller, ext); err != nil {
+		h.logger.Error("block last caller: failed to add blocklist entry",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
+		return
+	}
+
+	h.logger.Info("last caller blocked",
+		"call_id", callID,
+		"extension", ext,
+		"caller", caller,
+	)
+
+	callCtx := flow.NewCallContext(callID, ic.CallerIDName, ic.CallerIDNum, ic.RequestURI, nil, 0, req, tx)
+	if err := h.flowActions.AnswerCall(ctx, callCtx); err != nil {
+		h.logger.Warn("block last caller: failed to answer",
+			"call_id", callID,
+			"error", err,
+		)
+		h.flowActions.releaseEarlyMedia(callID)
+		h.finalizeCDRFailed(callID, 487)
+		return
+	}
+	if err := h.flowActions.PlayPrompt(ctx, callCtx, filepath.Join(h.dataDir, callerBlockedPrompt)); err != nil {
+		h.logger.Warn("block last caller: failed to play confirmation",
+			"call_id", callID,
+			"error", err,
+		)
+	}
+	if err := h.flowActions.HangupCall(ctx, callCtx, 200, "normal_clearing"); err != nil {
+		h.logger.Error("block last caller: failed to hang up",
+			"call_id", callID,
+			"error", err,
+		)
+	}
+	h.flowActions.releaseEarlyMedia(callID)
+}
+
+// blockCaller adds number to the global blocklist with the reject action,
+// unless a global exact block entry for it already exists.
+func (h *InviteHandler) blockCaller(ctx context.Context, number, byExtension string) error {
+	filters, err := h.callerFilters.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing caller filters: %w", err)
+	}
+	for _, f := range filters {
+		if f.InboundNumberID == nil && f.List == filterListBlock &&
+			f.MatchType == filterMatchExact && f.Pattern == number {
+			if f.Enabled {
+				return nil
+			}
+			f.Enabled = true
+			return h.callerFilters.Update(ctx, &f)
+		}
+	}
+
+	return h.callerFilters.Create(ctx, &models.CallerFilter{
+		List:        filterListBlock,
+		MatchType:   filterMatchExact,
+		Pattern:     number,
+		Action:      filterActionReject,
+		Description: fmt.Sprintf("Blocked from extension %s with %s", byExtension, blockLastCallerCode),
+		Enabled:     true,
+	})
+}
@@ -0,0 +1,114 @@
+package sip
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+func TestIsAnonymousCaller(t *testing.T) {
+	tests := []struct {
+		user string
+		host string
+		want bool
+	}{
+		{"0412345678", "10.0.0.2", false},
+		{"anonymous", "10.0.0.2", true},
+		{"Restricted", "10.0.0.2", true},
+		{"", "10.0.0.2", true},
+		{"0412345678", "anonymous.invalid", true},
+	}
+	for _, tt := range tests {
+		req := sip.NewRequest(sip.INVITE, sip.Uri{User: "100", Host: "10.0.0.1"})
+		req.AppendHeader(&sip.FromHeader{Address: sip.Uri{User: tt.user, Host: tt.host}, Params: sip.NewParams()})
+		if got := isAnonymousCaller(req); got != tt.want {
+			t.Errorf("isAnonymousCaller(%s@%s) = %v, want %v", tt.user, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCallerFilter(t *testing.T) {
+	did := int64(7)
+	filters := []models.CallerFilter{
+		{ID: 1, List: filterListBlock, MatchType: filterMatchPrefix, Pattern: "0390", Enabled: true},
+		{ID: 2, List: filterListAllow, MatchType: filterMatchExact, Pattern: "0390001111", Enabled: true},
+		{ID: 3, List: filterListBlock, MatchType: filterMatchRegex, Pattern: `^1900\d+$`, Enabled: true},
+		{ID: 4, List: filterListBlock, MatchType: filterMatchAnonymous, Enabled: true},
+		{ID: 5, InboundNumberID: &did, List: filterListAllow, MatchType: filterMatchPrefix, Pattern: "039", Enabled: true},
+		{ID: 6, List: filterListBlock, MatchType: filterMatchExact, Pattern: "0411111111", Enabled: false},
+		{ID: 7, List: filterListBlock, MatchType: filterMatchPrefix, Pattern: "03900", Enabled: true},
+	}
+
+	tests := []struct {
+		name      string
+		caller    string
+		anonymous bool
+		did       int64
+		wantID    int64
+	}{
+		{"longest prefix", "0390012222", false, 0, 7},
+		{"shorter prefix", "0390912222", false, 0, 1},
+		{"exact allow beats prefix block", "0390001111", false, 0, 2},
+		{"regex", "1900123456", false, 0, 3},
+		{"anonymous", "", true, 0, 4},
+		{"number entries skip anonymous", "0390012222", true, 0, 4},
+		{"did entry beats global", "0390012222", false, did, 5},
+		{"did entry for other did", "0390012222", false, 8, 7},
+		{"disabled", "0411111111", false, 0, 0},
+		{"no match", "0299999999", false, 0, 0},
+	}
+	for _, tt := range tests {
+		f := matchCallerFilter(filters, tt.caller, tt.anonymous, tt.did)
+		var got int64
+		if f != nil {
+			got = f.ID
+		}
+		if got != tt.wantID {
+			t.Errorf("%s: matchCallerFilter(%q) = filter %d, want %d", tt.name, tt.caller, got, tt.wantID)
+		}
+	}
+}
+
+func TestFilterRegexpCached(t *testing.T) {
+	re := filterRegexp(`^1900\d+$`)
+	if re == nil || !re.MatchString("1900123456") {
+		t.Fatalf("filterRegexp did not compile a valid pattern")
+	}
+	if again := filterRegexp(`^1900\d+$`); again != re {
+		t.Errorf("filterRegexp compiled the same pattern twice")
+	}
+	if filterRegexp(`^(1900`) != nil {
+		t.Errorf("filterRegexp returned a regexp for an invalid pattern")
+	}
+}
+
+func TestPendingCallLastCaller(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	pm := NewPendingCallManager(logger)
+
+	pm.Add(&PendingCall{
+		CallID:     "first",
+		CallerReq:  newTestDialogRequest("first", "0400000000", "a", "102"),
+		Extensions: []string{"102", "103"},
+	})
+	pm.Add(&PendingCall{
+		CallID:     "second",
+		CallerReq:  newTestDialogRequest("second", "0411111111", "b", "102"),
+		Extensions: []string{"102"},
+	})
+	pm.Remove("first")
+	pm.Remove("second")
+
+	if got := pm.LastCaller("102"); got != "0411111111" {
+		t.Errorf("LastCaller(102) = %q, want 0411111111", got)
+	}
+	if got := pm.LastCaller("103"); got != "0400000000" {
+		t.Errorf("LastCaller(103) = %q, want 0400000000", got)
+	}
+	if got := pm.LastCaller("104"); got != "" {
+		t.Errorf("LastCaller(104) = %q, want empty", got)
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"github.com/flowpbx/flowpbx/internal/flow/nodes"
 	"github.com/flowpbx/flowpbx/internal/media"
 	"github.com/flowpbx/flowpbx/internal/push"
+	"github.com/flowpbx/flowpbx/internal/schedule"
+	"github.com/flowpbx/flowpbx/internal/tts"
 )
 
 // Server wraps the sipgo SIP stack with FlowPBX-specific handlers.
@@ -27,10 +29,14 @@ type Server struct {
 	registrar      *Registrar
 	trunkRegistrar *TrunkRegistrar
 	inviteHandler  *InviteHandler
+	flowActions    *FlowSIPActions
+	calendars      *schedule.Calendars
+	parkMgr        *ParkManager
 	forker         *Forker
 	auth           *Authenticator
 	dialogMgr      *DialogManager
 	pendingMgr     *PendingCallManager
+	subscriptions  *SubscriptionManager
 	sessionMgr     *media.SessionManager
 	dtmfMgr        *media.CallDTMFManager
 	conferenceMgr  *media.ConferenceManager
@@ -56,6 +62,26 @@ func NewServer(cfg *config.Config, db *database.DB, enc *database.Encryptor, sys
 	sip.SIPDebugTracer(tracer)
 	logger.Info("sip message tracing configured", "verbosity", verbosity.String())
 
+	// Create the text-to-speech cache for TTS prompts, if an engine is configured.
+	var speech *tts.Cache
+	synth, err := tts.NewSynthesizer(tts.Config{
+		Engine:  cfg.TTSEngine,
+		Command: cfg.TTSCommand,
+		URL:     cfg.TTSURL,
+		Voice:   cfg.TTSVoice,
+		APIKey:  cfg.TTSAPIKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating tts engine: %w", err)
+	}
+	if synth != nil {
+		speech = tts.NewCache(synth, cfg.DataDir, media.PayloadPCMU, logger)
+		logger.Info("tts engine configured",
+			"engine", cfg.TTSEngine,
+			"voice", cfg.TTSVoice,
+		)
+	}
+
 	ua, err := sipgo.NewUA(
 		sipgo.WithUserAgent("FlowPBX"),
 		sipgo.WithUserAgentHostname(cfg.SIPHost()),
@@ -112,7 +138,7 @@ func NewServer(cfg *config.Config, db *database.DB, enc *database.Encryptor, sys
 	dtmfMgr := media.NewCallDTMFManager(logger)
 	cdrs := database.NewCDRRepository(db)
 	callFlows := database.NewCallFlowRepository(db)
-	outboundRouter := NewOutboundRouter(trunks, trunkRegistrar, enc, logger)
+	outboundRouter := NewOutboundRouter(trunks, database.NewOutboundRouteRepository(db), database.NewTrunkRateRepository(db), trunkRegistrar, enc, logger)
 
 	// Create conference manager for active conference room lifecycle.
 	conferenceMgr := media.NewConferenceManager(rtpProxy, cfg.DataDir, logger)
@@ -129,16 +155,23 @@ func NewServer(cfg *config.Config, db *database.DB, enc *database.Encryptor, sys
 	// Create the flow engine for inbound call routing via visual flow graphs.
 	voicemailMessages := database.NewVoicemailMessageRepository(db)
 	ringGroups := database.NewRingGroupRepository(db)
+	queues := database.NewQueueRepository(db)
 	voicemailBoxes := database.NewVoicemailBoxRepository(db)
 	ivrMenus := database.NewIVRMenuRepository(db)
 	timeSwitches := database.NewTimeSwitchRepository(db)
 	conferenceBridges := database.NewConferenceBridgeRepository(db)
-	entityResolver := flow.NewEntityResolver(extensions, ringGroups, voicemailBoxes, ivrMenus, timeSwitches, conferenceBridges, inboundNumbers)
+	entityResolver := flow.NewEntityResolver(extensions, ringGroups, queues, voicemailBoxes, ivrMenus, timeSwitches, conferenceBridges, inboundNumbers)
 	flowEngine := flow.NewEngine(callFlows, cdrs, entityResolver, logger)
-	flowSIPActions := NewFlowSIPActions(extensions, registrations, pushTokens, forker, outboundRouter, dialogMgr, pendingMgr, sessionMgr, dtmfMgr, conferenceMgr, cdrs, pushClient, regNotifier, proxyIP, cfg.DataDir, logger)
-	nodes.RegisterAll(flowEngine, flowSIPActions, extensions, voicemailMessages, sysConfig, enc, emailSend, cfg.DataDir, logger)
+	parkLots := database.NewParkLotRepository(db)
+	subscriptions := NewSubscriptionManager(extensions, registrations, voicemailBoxes, voicemailMessages, parkLots, auth, forker, dialogMgr, pendingMgr, proxyIP, logger)
+	mohMgr := NewMOHManager(database.NewMOHClassRepository(db), database.NewAudioPromptRepository(db), inboundNumbers, cfg.DataDir, logger)
+	flowSIPActions := NewFlowSIPActions(extensions, registrations, pushTokens, forker, outboundRouter, dialogMgr, pendingMgr, sessionMgr, dtmfMgr, conferenceMgr, cdrs, pushClient, regNotifier, subscriptions, speech, mohMgr, proxyIP, cfg.SIPPort, cfg.DataDir, logger)
+	calendars := schedule.NewCalendars(database.NewTimeSwitchCalendarRepository(db), cfg.DataDir, slog.Default())
+	nodes.RegisterAll(flowEngine, flowSIPActions, extensions, voicemailBoxes, voicemailMessages, sysConfig, enc, emailSend, speech, calendars, cfg.DataDir, logger)
+
+	parkMgr := NewParkManager(parkLots, callFlows, flowSIPActions, dialogMgr, logger)
 
-	inviteHandler := NewInviteHandler(extensions, registrations, pushTokens, inboundNumbers, trunks, trunkRegistrar, auth, outboundRouter, forker, dialogMgr, pendingMgr, sessionMgr, cdrs, sysConfig, flowEngine, flowSIPActions, pushClient, regNotifier, proxyIP, cfg.DataDir, logger)
+	inviteHandler := NewInviteHandler(extensions, registrations, pushTokens, inboundNumbers, database.NewCallerFilterRepository(db), trunks, ringGroups, trunkRegistrar, auth, outboundRouter, forker, dialogMgr, pendingMgr, sessionMgr, cdrs, sysConfig, flowEngine, flowSIPActions, parkMgr, pushClient, regNotifier, proxyIP, cfg.DataDir, logger)
 
 	s := &Server{
 		cfg:            cfg,
@@ -147,10 +180,14 @@ func NewServer(cfg *config.Config, db *database.DB, enc *database.Encryptor, sys
 		registrar:      registrar,
 		trunkRegistrar: trunkRegistrar,
 		inviteHandler:  inviteHandler,
+		flowActions:    flowSIPActions,
+		calendars:      calendars,
+		parkMgr:        parkMgr,
 		forker:         forker,
 		auth:           auth,
 		dialogMgr:      dialogMgr,
 		pendingMgr:     pendingMgr,
+		subscriptions:  subscriptions,
 		sessionMgr:     sessionMgr,
 		dtmfMgr:        dtmfMgr,
 		conferenceMgr:  conferenceMgr,
@@ -172,6 +209,8 @@ func (s *Server) registerHandlers() {
 	s.srv.OnCancel(s.handleCANCEL)
 	s.srv.OnOptions(s.handleOptions)
 	s.srv.OnInfo(s.handleInfo)
+	s.srv.OnRefer(s.handleREFER)
+	s.srv.OnSubscribe(s.subscriptions.HandleSubscribe)
 }
 
 // Start begins listening on configured transports. It blocks until the
@@ -239,6 +278,13 @@ func (s *Server) Start(ctx context.Context) error {
 		s.registrar.RunExpiryCleanup(ctx)
 	}()
 
+	// Start subscription expiry cleanup.
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.subscriptions.RunExpiryCleanup(ctx)
+	}()
+
 	// Start the RTP session reaper for orphaned media sessions.
 	s.sessionMgr.StartReaper()
 
@@ -269,6 +315,24 @@ func (s *Server) Stop() {
 	s.logger.Info("sip server stopped")
 }
 
+// Registrar returns the REGISTER handler for observing extension
+// registrations.
+func (s *Server) Registrar() *Registrar {
+	return s.registrar
+}
+
+// FlowActions returns the SIP actions used by call flows, for observing
+// new voicemail messages.
+func (s *Server) FlowActions() *FlowSIPActions {
+	return s.flowActions
+}
+
+// Calendars returns the store of calendars imported into time switches,
+// shared with the time switch flow node.
+func (s *Server) Calendars() *schedule.Calendars {
+	return s.calendars
+}
+
 // TrunkRegistrar returns the trunk registration manager for querying status
 // and managing trunk registrations.
 func (s *Server) TrunkRegistrar() *TrunkRegistrar {
@@ -280,10 +344,7 @@ func (s *Server) TrunkRegistrar() *TrunkRegistrar {
 // with an ACK to confirm the dialog. ACK requests are not transactional —
 // they have no response.
 func (s *Server) handleACK(req *sip.Request, tx sip.ServerTransaction) {
-	callID := ""
-	if cid := req.CallID(); cid != nil {
-		callID = cid.Value()
-	}
+	callID, fromTag := requestDialogID(req)
 
 	s.logger.Debug("sip ack received",
 		"call_id", callID,
@@ -292,7 +353,7 @@ func (s *Server) handleACK(req *sip.Request, tx sip.ServerTransaction) {
 	)
 
 	// Verify the ACK matches an active dialog.
-	if d := s.dialogMgr.GetDialog(callID); d != nil {
+	if d := s.dialogMgr.FindDialog(callID, fromTag); d != nil {
 		s.logger.Debug("ack matched active dialog",
 			"call_id", callID,
 			"caller", d.CallerIDNum,
@@ -320,8 +381,29 @@ func (s *Server) handleBYE(req *sip.Request, tx sip.ServerTransaction) {
 		"source", req.Source(),
 	)
 
-	// Look up the active dialog for this call.
-	d := s.dialogMgr.GetDialog(callID)
+	fromTag := ""
+	if from := req.From(); from != nil {
+		if tag, ok := from.Params.Get("tag"); ok {
+			fromTag = tag
+		}
+	}
+
+	// A leg that left the call through a transfer is already gone from
+	// its dialog; acknowledge its BYE without touching the call.
+	if s.dialogMgr.IsRetiredLeg(callID, fromTag) {
+		s.logger.Debug("bye from transferred leg",
+			"call_id", callID,
+		)
+		res := sip.NewResponseFromRequest(req, 200, "OK", nil)
+		if err := tx.Respond(res); err != nil {
+			s.logger.Error("failed to respond to bye", "error", err)
+		}
+		return
+	}
+
+	// Look up the active dialog for this call. Legs forked to extensions
+	// have their own Call-ID, so match on either leg.
+	d := s.dialogMgr.FindDialog(callID, fromTag)
 	if d == nil {
 		s.logger.Warn("bye for unknown dialog",
 			"call_id", callID,
@@ -339,22 +421,34 @@ func (s *Server) handleBYE(req *sip.Request, tx sip.ServerTransaction) {
 		s.logger.Error("failed to respond to bye", "error", err)
 	}
 
-	// Determine which leg sent the BYE and send BYE to the other leg.
-	fromTag := ""
-	if from := req.From(); from != nil {
-		if tag, ok := from.Params.Get("tag"); ok {
-			fromTag = tag
-		}
+	// Stop a flow that is still talking to the caller itself, e.g. a
+	// voicemail menu on a call the PBX answered.
+	if s.flowActions != nil {
+		s.flowActions.abortEarlyMedia(d.CallID)
 	}
 
+	// Determine which leg sent the BYE and send BYE to the other leg.
 	hangupCause := "normal_clearing"
-	callerHangup := fromTag == d.Caller.FromTag || fromTag == ""
+	callerHangup := d.isCallerLeg(callID, fromTag)
+
+	// Calls joined by an attended transfer hang up together.
+	if d.Peer != nil {
+		if callerHangup {
+			hangupCause = "caller_bye"
+		} else {
+			hangupCause = "callee_bye"
+		}
+		s.hangupJoinedCall(d, hangupCause)
+		return
+	}
 
 	if callerHangup {
 		s.logger.Debug("bye from caller, sending bye to callee",
 			"call_id", callID,
 		)
-		s.sendBYEToCallee(d)
+		if d.hasCallee() {
+			s.sendBYEToCallee(d)
+		}
 		hangupCause = "caller_bye"
 	} else {
 		s.logger.Debug("bye from callee, sending bye to caller",
@@ -364,12 +458,18 @@ func (s *Server) handleBYE(req *sip.Request, tx sip.ServerTransaction) {
 		hangupCause = "callee_bye"
 	}
 
+	s.endDialog(d, hangupCause)
+}
+
+// endDialog stops a call's recording and media once its legs have been
+// sent or have sent BYE, then terminates the dialog and finalizes its CDR.
+func (s *Server) endDialog(d *Dialog, hangupCause string) {
 	// Stop call recording if active. Must be done before releasing media
 	// so the recorder can drain remaining packets from the relay.
 	if d.Recorder != nil {
 		filePath, duration := d.Recorder.Stop()
-		s.logger.Info("call recording stopped on bye",
-			"call_id", callID,
+		s.logger.Info("call recording stopped",
+			"call_id", d.CallID,
 			"file", filePath,
 			"duration_secs", duration,
 		)
@@ -378,13 +478,13 @@ func (s *Server) handleBYE(req *sip.Request, tx sip.ServerTransaction) {
 	// Release media resources.
 	if d.Media != nil {
 		d.Media.Release()
-		s.logger.Debug("media session released on bye",
-			"call_id", callID,
+		s.logger.Debug("media session released",
+			"call_id", d.CallID,
 		)
 	}
 
 	// Terminate the dialog.
-	terminated := s.dialogMgr.TerminateDialog(callID, hangupCause)
+	terminated := s.dialogMgr.TerminateDialog(d.CallID, hangupCause)
 	if terminated == nil {
 		return
 	}
@@ -393,22 +493,55 @@ func (s *Server) handleBYE(req *sip.Request, tx sip.ServerTransaction) {
 	s.finalizeCDR(terminated)
 }
 
+// hangupJoinedCall tears down both calls of an attended transfer when the
+// remaining party on d hangs up: the remaining party on the peer call gets
+// a BYE, and both media sessions and CDRs are finalised.
+func (s *Server) hangupJoinedCall(d *Dialog, hangupCause string) {
+	peer := d.Peer
+
+	byeReq := peer.leg(peer.peerCallerRemains).newRequest(sip.BYE)
+	if err := s.forker.Client().WriteRequest(byeReq); err != nil {
+		s.logger.Error("failed to send bye to joined call",
+			"call_id", peer.CallID,
+			"error", err,
+		)
+	}
+
+	for _, dlg := range []*Dialog{d, peer} {
+		if dlg.Recorder != nil {
+			dlg.Recorder.Stop()
+		}
+		if dlg.Media != nil {
+			dlg.Media.Release()
+		}
+		if terminated := s.dialogMgr.TerminateDialog(dlg.CallID, hangupCause); terminated != nil {
+			s.finalizeCDR(terminated)
+		}
+	}
+
+	s.logger.Info("joined calls terminated",
+		"call_id", d.CallID,
+		"peer_call_id", peer.CallID,
+		"hangup_cause", hangupCause,
+	)
+}
+
 // sendBYEToCallee sends a BYE request to the callee (answering device).
 // The BYE is constructed as an in-dialog request using the dialog parameters
 // from the original INVITE and 200 OK exchange.
 func (s *Server) sendBYEToCallee(d *Dialog) {
-	if d.CalleeReq == nil {
+	// The vacant side of a parked call has no device to hang up.
+	if d.vacant(false) {
+		return
+	}
+	if d.CalleeReq == nil && d.CalleeInReq == nil {
 		s.logger.Warn("cannot send bye to callee: no callee request stored",
 			"call_id", d.CallID,
 		)
 		return
 	}
 
-	byeReq := s.buildInDialogBYE(
-		d.CalleeReq,
-		d.CalleeRes,
-		d.Callee.RemoteTarget,
-	)
+	byeReq := d.leg(false).newRequest(sip.BYE)
 
 	if err := s.forker.Client().WriteRequest(byeReq); err != nil {
 		s.logger.Error("failed to send bye to callee",
@@ -424,18 +557,22 @@ func (s *Server) sendBYEToCallee(d *Dialog) {
 
 // sendBYEToCaller sends a BYE request to the caller (originating device).
 // The BYE is constructed as an in-dialog request using the dialog parameters
-// from the original INVITE.
+// from the original INVITE and the PBX's answer.
 func (s *Server) sendBYEToCaller(d *Dialog) {
-	if d.CallerReq == nil {
+	if d.vacant(true) {
+		return
+	}
+	if d.CallerReq == nil && d.CallerOutReq == nil {
 		s.logger.Warn("cannot send bye to caller: no caller request stored",
 			"call_id", d.CallID,
 		)
 		return
 	}
 
-	// For the caller leg, we build a BYE as a UAS sending to the UAC.
-	// The roles are reversed: the From/To are swapped relative to the original INVITE.
-	byeReq := s.buildReverseDialogBYE(d.CallerReq)
+	// For the original caller leg, we build a BYE as a UAS sending to the
+	// UAC: the From/To are swapped relative to the original INVITE. A caller
+	// leg replaced by a transfer is a PBX-originated leg like the callee's.
+	byeReq := d.leg(true).newRequest(sip.BYE)
 
 	if err := s.forker.Client().WriteRequest(byeReq); err != nil {
 		s.logger.Error("failed to send bye to caller",
@@ -449,13 +586,16 @@ func (s *Server) sendBYEToCaller(d *Dialog) {
 	}
 }
 
-// buildInDialogBYE creates a BYE request within an established dialog on the
-// outbound (callee) leg. The Request-URI is the Contact from the callee's 200 OK
-// (remoteTarget), and dialog headers match the original INVITE/response exchange.
-func (s *Server) buildInDialogBYE(
+// buildInDialogRequest creates a request within an established dialog on a
+// leg the PBX originated (e.g. the callee leg). The Request-URI is the Contact
+// from the 2xx (remoteTarget), and dialog headers match the original
+// INVITE/response exchange.
+func buildInDialogRequest(
+	method sip.RequestMethod,
 	inviteReq *sip.Request,
 	inviteResp *sip.Response,
 	remoteTarget *sip.Uri,
+	seq uint32,
 ) *sip.Request {
 	// Request-URI: Contact from the callee's 200 OK, or original INVITE recipient.
 	recipient := &inviteReq.Recipient
@@ -463,105 +603,115 @@ func (s *Server) buildInDialogBYE(
 		recipient = remoteTarget
 	}
 
-	bye := sip.NewRequest(sip.BYE, *recipient.Clone())
-	bye.SipVersion = inviteReq.SipVersion
+	req := sip.NewRequest(method, *recipient.Clone())
+	req.SipVersion = inviteReq.SipVersion
 
 	// From: same as the original INVITE (our side of the dialog).
 	if h := inviteReq.From(); h != nil {
-		bye.AppendHeader(sip.HeaderClone(h))
+		req.AppendHeader(sip.HeaderClone(h))
 	}
 
 	// To: from the response (includes remote tag).
 	if inviteResp != nil {
 		if h := inviteResp.To(); h != nil {
-			bye.AppendHeader(sip.HeaderClone(h))
+			req.AppendHeader(sip.HeaderClone(h))
 		}
 	} else if h := inviteReq.To(); h != nil {
-		bye.AppendHeader(sip.HeaderClone(h))
+		req.AppendHeader(sip.HeaderClone(h))
 	}
 
 	// Call-ID: same as the dialog.
 	if h := inviteReq.CallID(); h != nil {
-		bye.AppendHeader(sip.HeaderClone(h))
+		req.AppendHeader(sip.HeaderClone(h))
 	}
 
-	// CSeq: new sequence number, method BYE.
+	// CSeq: next sequence number on this leg.
 	cseq := &sip.CSeqHeader{
-		SeqNo:      2,
-		MethodName: sip.BYE,
+		SeqNo:      seq,
+		MethodName: method,
 	}
-	bye.AppendHeader(cseq)
+	req.AppendHeader(cseq)
 
 	maxFwd := sip.MaxForwardsHeader(70)
-	bye.AppendHeader(&maxFwd)
+	req.AppendHeader(&maxFwd)
 
-	bye.SetTransport(inviteReq.Transport())
-	bye.SetSource(inviteReq.Source())
+	req.SetTransport(inviteReq.Transport())
+	req.SetSource(inviteReq.Source())
 
-	return bye
+	return req
 }
 
-// buildReverseDialogBYE creates a BYE request to the caller (originating side).
-// Since the PBX is the UAS for the caller's INVITE, the From/To headers are
-// swapped: our To becomes From, and the caller's From becomes To.
-func (s *Server) buildReverseDialogBYE(callerReq *sip.Request) *sip.Request {
+// buildReverseDialogRequest creates an in-dialog request to the caller
+// (originating side). Since the PBX is the UAS for the caller's INVITE, the
+// From/To headers are swapped: our To becomes From, and the caller's From
+// becomes To. localTag is the To tag the PBX answered with.
+func buildReverseDialogRequest(method sip.RequestMethod, callerReq *sip.Request, localTag string, seq uint32) *sip.Request {
 	// Request-URI: the Contact from the caller's INVITE (where to send BYE).
 	recipient := &callerReq.Recipient
 	if contact := callerReq.Contact(); contact != nil {
 		recipient = &contact.Address
 	}
 
-	bye := sip.NewRequest(sip.BYE, *recipient.Clone())
-	bye.SipVersion = callerReq.SipVersion
+	req := sip.NewRequest(method, *recipient.Clone())
+	req.SipVersion = callerReq.SipVersion
 
-	// From/To swapped: we are now the initiator of BYE.
+	// From/To swapped: we are now the initiator of the request.
 	// From = original To (PBX side), To = original From (caller side).
 	if h := callerReq.To(); h != nil {
 		fromHeader := h.AsFrom()
-		bye.AppendHeader(&fromHeader)
+		if _, ok := fromHeader.Params.Get("tag"); !ok && localTag != "" {
+			fromHeader.Params.Add("tag", localTag)
+		}
+		req.AppendHeader(&fromHeader)
 	}
 	if h := callerReq.From(); h != nil {
 		toHeader := h.AsTo()
-		bye.AppendHeader(&toHeader)
+		req.AppendHeader(&toHeader)
 	}
 
 	// Call-ID: same as the dialog.
 	if h := callerReq.CallID(); h != nil {
-		bye.AppendHeader(sip.HeaderClone(h))
+		req.AppendHeader(sip.HeaderClone(h))
 	}
 
-	// CSeq: new sequence number for this direction.
+	// CSeq: next sequence number for this direction.
 	cseq := &sip.CSeqHeader{
-		SeqNo:      1,
-		MethodName: sip.BYE,
+		SeqNo:      seq,
+		MethodName: method,
 	}
-	bye.AppendHeader(cseq)
+	req.AppendHeader(cseq)
 
 	maxFwd := sip.MaxForwardsHeader(70)
-	bye.AppendHeader(&maxFwd)
+	req.AppendHeader(&maxFwd)
 
-	bye.SetTransport(callerReq.Transport())
-	bye.SetSource(callerReq.Source())
+	req.SetTransport(callerReq.Transport())
+	req.SetSource(callerReq.Source())
 
-	return bye
+	return req
 }
 
 // finalizeCDR updates the CDR that was created at call start with hangup
 // information from the terminated dialog.
 func (s *Server) finalizeCDR(d *Dialog) {
+	finalizeDialogCDR(s.cdrs, d, s.logger)
+}
+
+// finalizeDialogCDR updates the CDR of a terminated dialog with its hangup
+// information.
+func finalizeDialogCDR(cdrs database.CDRRepository, d *Dialog, logger *slog.Logger) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cdr, err := s.cdrs.GetByCallID(ctx, d.CallID)
+	cdr, err := cdrs.GetByCallID(ctx, d.CallID)
 	if err != nil {
-		s.logger.Error("failed to fetch cdr for finalization",
+		logger.Error("failed to fetch cdr for finalization",
 			"call_id", d.CallID,
 			"error", err,
 		)
 		return
 	}
 	if cdr == nil {
-		s.logger.Warn("no cdr found to finalize",
+		logger.Warn("no cdr found to finalize",
 			"call_id", d.CallID,
 		)
 		return
@@ -576,21 +726,28 @@ func (s *Server) finalizeCDR(d *Dialog) {
 	cdr.BillableDur = &billableSec
 	cdr.Disposition = d.Disposition()
 	cdr.HangupCause = d.HangupCause
+	if cdr.Rate != nil {
+		cost := database.CallCost(*cdr.Rate, billableSec)
+		cdr.Cost = &cost
+	}
 
 	// Store recording file path if call was recorded.
 	if d.Recorder != nil {
 		cdr.RecordingFile = d.Recorder.FilePath()
 	}
+	if d.TransferredTo != "" {
+		cdr.TransferredTo = d.TransferredTo
+	}
 
-	if err := s.cdrs.Update(ctx, cdr); err != nil {
-		s.logger.Error("failed to finalize cdr",
+	if err := cdrs.Update(ctx, cdr); err != nil {
+		logger.Error("failed to finalize cdr",
 			"call_id", d.CallID,
 			"error", err,
 		)
 		return
 	}
 
-	s.logger.Info("cdr finalized",
+	logger.Info("cdr finalized",
 		"call_id", d.CallID,
 		"cdr_id", cdr.ID,
 		"direction", cdr.Direction,
@@ -640,7 +797,12 @@ func (s *Server) handleCANCEL(req *sip.Request, tx sip.ServerTransaction) {
 		s.logger.Info("cancel for answered call, treating as bye",
 			"call_id", callID,
 		)
-		s.sendBYEToCallee(d)
+		if s.flowActions != nil {
+			s.flowActions.abortEarlyMedia(callID)
+		}
+		if d.hasCallee() {
+			s.sendBYEToCallee(d)
+		}
 		if d.Recorder != nil {
 			d.Recorder.Stop()
 		}
@@ -746,7 +908,8 @@ func (s *Server) handleOptions(req *sip.Request, tx sip.ServerTransaction) {
 
 	res := sip.NewResponseFromRequest(req, 200, "OK", nil)
 	res.AppendHeader(sip.NewHeader("Accept", "application/sdp"))
-	res.AppendHeader(sip.NewHeader("Allow", "INVITE, ACK, CANCEL, BYE, REGISTER, OPTIONS, INFO"))
+	res.AppendHeader(sip.NewHeader("Allow-Events", allowEvents))
+	res.AppendHeader(sip.NewHeader("Allow", "INVITE, ACK, CANCEL, BYE, REGISTER, OPTIONS, INFO, REFER, SUBSCRIBE, NOTIFY"))
 
 	if err := tx.Respond(res); err != nil {
 		s.logger.Error("failed to respond to options", "error", err)
@@ -0,0 +1,174 @@
+package sip
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// SRTP modes of an extension or trunk. An empty mode is treated as
+// srtpModeOptional.
+const (
+	// srtpModeOff sends plain RTP and refuses RTP/SAVP offers.
+	srtpModeOff = "off"
+	// srtpModeOptional uses SRTP when the far end offers or accepts
+	// a=crypto, and plain RTP otherwise.
+	srtpModeOptional = "optional"
+	// srtpModeRequired refuses calls whose media would not be encrypted.
+	srtpModeRequired = "required"
+)
+
+// errSRTPNotAcceptable is returned when an offer or answer does not meet a
+// leg's SRTP mode. The call should be rejected with 488 Not Acceptable Here.
+var errSRTPNotAcceptable = errors.New("media encryption does not meet srtp policy")
+
+// SRTPPolicy holds the SRTP modes of the caller and callee legs of a call.
+// The zero value makes SRTP optional on both legs.
+type SRTPPolicy struct {
+	Caller string
+	Callee string
+}
+
+// answerSRTP decides whether a leg whose audio offer is offer uses SRTP
+// under mode. It returns the leg's keys, with a new local key in the
+// offer's first supported crypto suite, or nil for plain RTP.
+func answerSRTP(offer *media.MediaDescription, mode string) (*media.LegSRTP, error) {
+	if mode == srtpModeOff {
+		if offer.IsSecure() {
+			return nil, fmt.Errorf("%w: %s offered but srtp is off", errSRTPNotAcceptable, offer.Proto)
+		}
+		return nil, nil
+	}
+	if len(offer.Crypto) == 0 {
+		if offer.IsSecure() {
+			return nil, fmt.Errorf("%w: %s offered without a supported crypto suite", errSRTPNotAcceptable, offer.Proto)
+		}
+		if mode == srtpModeRequired {
+			return nil, fmt.Errorf("%w: srtp required but not offered", errSRTPNotAcceptable)
+		}
+		return nil, nil
+	}
+
+	remote := offer.Crypto[0]
+	local, err := media.NewCryptoAttribute(remote.Tag, remote.Suite)
+	if err != nil {
+		return nil, err
+	}
+	return &media.LegSRTP{Remote: remote, Local: local}, nil
+}
+
+// setAnswerSRTP makes an audio answer match the transport profile of the
+// offer it answers, carrying the leg's local key, or no key for plain RTP.
+func setAnswerSRTP(answer, offer *media.MediaDescription, leg *media.LegSRTP) {
+	answer.Proto = offer.Proto
+	if leg == nil {
+		answer.SetCrypto(nil)
+		return
+	}
+	answer.SetCrypto([]media.CryptoAttribute{leg.Local})
+}
+
+// offerSRTP sets the crypto attributes and transport profile of an audio
+// offer for mode and returns the keys offered, nil for plain RTP. Both
+// supported suites are offered, the stronger first. Only a required mode
+// offers RTP/SAVP, which devices without SRTP reject.
+func offerSRTP(offer *media.MediaDescription, mode string) ([]media.CryptoAttribute, error) {
+	offer.Proto = "RTP/AVP"
+	if mode == srtpModeOff {
+		offer.SetCrypto(nil)
+		return nil, nil
+	}
+	if mode == srtpModeRequired {
+		offer.Proto = "RTP/SAVP"
+	}
+
+	var keys []media.CryptoAttribute
+	for i, suite := range []string{media.SuiteAESCM128HMACSHA1_80, media.SuiteAESCM128HMACSHA1_32} {
+		key, err := media.NewCryptoAttribute(i+1, suite)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	offer.SetCrypto(keys)
+	return keys, nil
+}
+
+// offerLegSRTP sets the crypto attributes and transport profile of an
+// audio offer to an established leg with keys leg, nil for plain RTP.
+func offerLegSRTP(offer *media.MediaDescription, leg *media.LegSRTP) {
+	if leg == nil {
+		offer.Proto = "RTP/AVP"
+		offer.SetCrypto(nil)
+		return
+	}
+	offer.Proto = "RTP/SAVP"
+	offer.SetCrypto([]media.CryptoAttribute{leg.Local})
+}
+
+// acceptSRTPAnswer returns the keys of a leg whose audio answer is answer
+// to an offer of keys offered under mode, or nil for plain RTP. The answer
+// selects an offered key by tag and suite.
+func acceptSRTPAnswer(answer *media.MediaDescription, offered []media.CryptoAttribute, mode string) (*media.LegSRTP, error) {
+	for _, c := range answer.Crypto {
+		for _, o := range offered {
+			if c.Tag == o.Tag && c.Suite == o.Suite {
+				return &media.LegSRTP{Remote: c, Local: o}, nil
+			}
+		}
+	}
+	if answer.IsSecure() {
+		return nil, fmt.Errorf("%w: %s answer without an offered crypto suite", errSRTPNotAcceptable, answer.Proto)
+	}
+	if mode == srtpModeRequired {
+		return nil, fmt.Errorf("%w: srtp required but not accepted", errSRTPNotAcceptable)
+	}
+	return nil, nil
+}
+
+// rekeySRTP applies a re-INVITE offer's crypto to a leg that uses SRTP,
+// keeping the leg's local key, and returns the leg's new keys. A leg on
+// plain RTP stays on it, so nil is returned for it unless the offer
+// insists on RTP/SAVP.
+func rekeySRTP(offer *media.MediaDescription, leg *media.LegSRTP) (*media.LegSRTP, error) {
+	if leg == nil {
+		if offer.IsSecure() {
+			return nil, fmt.Errorf("%w: cannot switch a plain rtp call to srtp", errSRTPNotAcceptable)
+		}
+		return nil, nil
+	}
+	for _, c := range offer.Crypto {
+		if c.Suite == leg.Local.Suite {
+			local := leg.Local
+			local.Tag = c.Tag
+			return &media.LegSRTP{Remote: c, Local: local}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: re-invite drops the call's crypto suite", errSRTPNotAcceptable)
+}
+
+// legSRTPContexts returns contexts that decrypt media from and encrypt
+// media to a leg with keys leg, for the PBX's own players and collectors
+// on the leg's socket. Both are nil for plain RTP.
+func legSRTPContexts(leg *media.LegSRTP) (in, out *media.SRTPContext, err error) {
+	if leg == nil {
+		return nil, nil, nil
+	}
+	if in, err = media.NewSRTPContext(leg.Remote); err != nil {
+		return nil, nil, err
+	}
+	if out, err = media.NewSRTPContext(leg.Local); err != nil {
+		return nil, nil, err
+	}
+	return in, out, nil
+}
+
+// mediaErrorStatus returns the SIP status for a failure to set up media:
+// 488 if the SRTP policy rejected the offer, otherwise 500.
+func mediaErrorStatus(err error) (int, string) {
+	if errors.Is(err, errSRTPNotAcceptable) {
+		return 488, "Not Acceptable Here"
+	}
+	return 500, "Internal Server Error"
+}
@@ -0,0 +1,114 @@
+package sip
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+const testCrypto80 = "crypto:1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz"
+
+func TestAnswerSRTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		mline   string
+		attrs   []string
+		mode    string
+		wantKey bool
+		wantErr bool
+	}{
+		{"optional with crypto", "m=audio 5004 RTP/AVP 0", []string{testCrypto80}, "", true, false},
+		{"optional without crypto", "m=audio 5004 RTP/AVP 0", nil, srtpModeOptional, false, false},
+		{"off strips avp crypto", "m=audio 5004 RTP/AVP 0", []string{testCrypto80}, srtpModeOff, false, false},
+		{"off refuses savp", "m=audio 5004 RTP/SAVP 0", []string{testCrypto80}, srtpModeOff, false, true},
+		{"required without crypto", "m=audio 5004 RTP/AVP 0", nil, srtpModeRequired, false, true},
+		{"savp with unsupported suite", "m=audio 5004 RTP/SAVP 0", []string{"crypto:1 AEAD_AES_256_GCM inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz"}, srtpModeOptional, false, true},
+		{"required with crypto", "m=audio 5004 RTP/SAVP 0", []string{testCrypto80}, srtpModeRequired, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offer := testAudioSDP(t, tt.mline, tt.attrs...).AudioMedia()
+			leg, err := answerSRTP(offer, tt.mode)
+			if tt.wantErr {
+				if !errors.Is(err, errSRTPNotAcceptable) {
+					t.Fatalf("err = %v, want errSRTPNotAcceptable", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("answerSRTP: %v", err)
+			}
+			if (leg != nil) != tt.wantKey {
+				t.Fatalf("keys = %v, want keys %v", leg, tt.wantKey)
+			}
+			if leg != nil && (leg.Local.Tag != 1 || leg.Local.Suite != media.SuiteAESCM128HMACSHA1_80 || string(leg.Local.Key) == string(leg.Remote.Key)) {
+				t.Errorf("local key %+v does not answer remote %+v", leg.Local, leg.Remote)
+			}
+		})
+	}
+}
+
+func TestOfferAndAcceptSRTP(t *testing.T) {
+	offer := testAudioSDP(t, "m=audio 5004 RTP/SAVP 0", testCrypto80).AudioMedia()
+	callerKey := offer.Crypto[0].Key
+	keys, err := offerSRTP(offer, srtpModeOptional)
+	if err != nil {
+		t.Fatalf("offerSRTP: %v", err)
+	}
+	if offer.Proto != "RTP/AVP" || len(offer.Crypto) != 2 || len(keys) != 2 {
+		t.Fatalf("optional offer = %s with %d keys, want RTP/AVP with 2", offer.Proto, len(offer.Crypto))
+	}
+	if string(offer.Crypto[0].Key) == string(callerKey) {
+		t.Error("offer carries the caller's key")
+	}
+
+	// The callee picks the second suite.
+	picked, _ := media.NewCryptoAttribute(2, media.SuiteAESCM128HMACSHA1_32)
+	answer := testAudioSDP(t, "m=audio 6000 RTP/AVP 0", "crypto:"+picked.String()).AudioMedia()
+	leg, err := acceptSRTPAnswer(answer, keys, srtpModeOptional)
+	if err != nil {
+		t.Fatalf("acceptSRTPAnswer: %v", err)
+	}
+	if leg == nil || string(leg.Remote.Key) != string(picked.Key) || string(leg.Local.Key) != string(keys[1].Key) {
+		t.Errorf("leg keys = %+v, want the callee's key and the second offered key", leg)
+	}
+
+	// A plain answer is accepted unless SRTP is required.
+	plain := testAudioSDP(t, "m=audio 6000 RTP/AVP 0").AudioMedia()
+	if leg, err := acceptSRTPAnswer(plain, keys, srtpModeOptional); leg != nil || err != nil {
+		t.Errorf("plain answer = %v, %v; want plain rtp", leg, err)
+	}
+	if _, err := acceptSRTPAnswer(plain, keys, srtpModeRequired); !errors.Is(err, errSRTPNotAcceptable) {
+		t.Errorf("plain answer to required offer: err = %v", err)
+	}
+
+	// Off offers plain RTP.
+	offer = testAudioSDP(t, "m=audio 5004 RTP/SAVP 0", testCrypto80).AudioMedia()
+	if keys, _ := offerSRTP(offer, srtpModeOff); keys != nil || offer.Proto != "RTP/AVP" || len(offer.Crypto) != 0 {
+		t.Errorf("off offer = %s with %d keys", offer.Proto, len(offer.Crypto))
+	}
+}
+
+func TestRekeySRTP(t *testing.T) {
+	remote, _ := media.NewCryptoAttribute(1, media.SuiteAESCM128HMACSHA1_80)
+	local, _ := media.NewCryptoAttribute(1, media.SuiteAESCM128HMACSHA1_80)
+	leg := &media.LegSRTP{Remote: remote, Local: local}
+
+	rekeyed, _ := media.NewCryptoAttribute(3, media.SuiteAESCM128HMACSHA1_80)
+	offer := testAudioSDP(t, "m=audio 5004 RTP/SAVP 0", "crypto:"+rekeyed.String()).AudioMedia()
+	got, err := rekeySRTP(offer, leg)
+	if err != nil {
+		t.Fatalf("rekeySRTP: %v", err)
+	}
+	if string(got.Remote.Key) != string(rekeyed.Key) || string(got.Local.Key) != string(local.Key) || got.Local.Tag != 3 {
+		t.Errorf("rekeyed leg = %+v, want the new remote key and the old local key under tag 3", got)
+	}
+
+	if _, err := rekeySRTP(testAudioSDP(t, "m=audio 5004 RTP/AVP 0").AudioMedia(), leg); !errors.Is(err, errSRTPNotAcceptable) {
+		t.Errorf("dropping srtp: err = %v", err)
+	}
+	if _, err := rekeySRTP(offer, nil); !errors.Is(err, errSRTPNotAcceptable) {
+		t.Errorf("switching to srtp: err = %v", err)
+	}
+}
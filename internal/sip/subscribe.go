@@ -0,0 +1,573 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+// Event packages accepted in SUBSCRIBE requests.
+const (
+	eventDialog         = "dialog"          // RFC 4235, busy lamp fields
+	eventPresence       = "presence"        // RFC 3856
+	eventMessageSummary = "message-summary" // RFC 3842, voicemail lamp
+)
+
+// allowEvents is the Allow-Events header value listing the supported
+// event packages.
+const allowEvents = "dialog, presence, message-summary"
+
+const (
+	subscribeDefaultExpiry = 3600 // 1 hour default subscription expiry
+	subscribeMinExpiry     = 60   // 1 minute minimum
+	subscribeMaxExpiry     = 7200 // 2 hours maximum
+	subscribeNotifyTimeout = 5 * time.Second
+)
+
+// ExtensionStateListener is called with the extension numbers whose call
+// state may have changed. It is invoked with the reporting manager's lock
+// held, so it must not block or call back into that manager.
+type ExtensionStateListener func(extensions []string)
+
+// extensionNumbers returns the numbers of the given extensions, skipping
+// nil entries.
+func extensionNumbers(exts ...*models.Extension) []string {
+	var nums []string
+	for _, ext := range exts {
+		if ext != nil {
+			nums = append(nums, ext.Extension)
+		}
+	}
+	return nums
+}
+
+// subscription is an accepted SUBSCRIBE dialog. The PBX is the notifier:
+// it answered the SUBSCRIBE and sends NOTIFYs on the dialog it created.
+type subscription struct {
+	// key identifies the subscription by Call-ID and subscriber tag.
+	key string
+
+	// event is the subscribed event package.
+	event string
+
+	// extension is the extension whose state is watched.
+	extension string
+
+	// subscriber is the extension that subscribed.
+	subscriber string
+
+	// leg builds the NOTIFY requests on the subscription dialog.
+	leg dialogLeg
+
+	// source is the address the SUBSCRIBE came from. NOTIFYs are sent
+	// there so they reach subscribers behind NAT.
+	source string
+
+	// expires is when the subscription ends unless refreshed. Guarded by
+	// SubscriptionManager.mu.
+	expires time.Time
+
+	// mu serialises NOTIFYs so each carries state at least as new as the
+	// one before it, and guards version.
+	mu      sync.Mutex
+	version int
+}
+
+// SubscriptionManager accepts SUBSCRIBE requests for the dialog, presence
+// and message-summary event packages and sends NOTIFYs to subscribers when
+// the watched extension's calls or voicemail change. Subscriptions live in
+// memory only; phones re-subscribe after a restart.
+type SubscriptionManager struct {
+	extensions        database.ExtensionRepository
+	registrations     database.RegistrationRepository
+	voicemailBoxes    database.VoicemailBoxRepository
+	voicemailMessages database.VoicemailMessageRepository
+	parkLots          database.ParkLotRepository
+	auth              *Authenticator
+	forker            *Forker
+	dialogMgr         *DialogManager
+	pendingMgr        *PendingCallManager
+	proxyIP           string
+	logger            *slog.Logger
+
+	mu   sync.Mutex
+	subs map[string]*subscription // keyed by legKey(Call-ID, subscriber tag)
+}
+
+// NewSubscriptionManager creates a SUBSCRIBE handler and registers it for
+// call state changes on the dialog and pending call managers.
+func NewSubscriptionManager(
+	extensions database.ExtensionRepository,
+	registrations database.RegistrationRepository,
+	voicemailBoxes database.VoicemailBoxRepository,
+	voicemailMessages database.VoicemailMessageRepository,
+	parkLots database.ParkLotRepository,
+	auth *Authenticator,
+	forker *Forker,
+	dialogMgr *DialogManager,
+	pendingMgr *PendingCallManager,
+	proxyIP string,
+	logger *slog.Logger,
+) *SubscriptionManager {
+	m := &SubscriptionManager{
+		extensions:        extensions,
+		registrations:     registrations,
+		voicemailBoxes:    voicemailBoxes,
+		voicemailMessages: voicemailMessages,
+		parkLots:          parkLots,
+		auth:              auth,
+		forker:            forker,
+		dialogMgr:         dialogMgr,
+		pendingMgr:        pendingMgr,
+		proxyIP:           proxyIP,
+		logger:            logger.With("subsystem", "subscriptions"),
+		subs:              make(map[string]*subscription),
+	}
+	dialogMgr.OnExtensionStateChange(m.ExtensionsChanged)
+	pendingMgr.OnExtensionStateChange(m.ExtensionsChanged)
+	return m
+}
+
+// HandleSubscribe processes incoming SUBSCRIBE requests. A SUBSCRIBE
+// without a To tag creates a subscription to the extension in the
+// Request-URI; one with a To tag refreshes or, with Expires: 0, ends an
+// existing subscription. Every accepted SUBSCRIBE is followed by a NOTIFY
+// with the current state.
+func (m *SubscriptionManager) HandleSubscribe(req *sip.Request, tx sip.ServerTransaction) {
+	callID, fromTag := requestDialogID(req)
+
+	event := parseEventPackage(req)
+	switch event {
+	case eventDialog, eventPresence, eventMessageSummary:
+	default:
+		m.logger.Debug("subscribe for unsupported event",
+			"call_id", callID,
+			"event", event,
+		)
+		res := sip.NewResponseFromRequest(req, 489, "Bad Event", nil)
+		res.AppendHeader(sip.NewHeader("Allow-Events", allowEvents))
+		if err := tx.Respond(res); err != nil {
+			m.logger.Error("failed to send subscribe response", "error", err)
+		}
+		return
+	}
+
+	expiry := parseSubscribeExpiry(req)
+
+	if to := req.To(); to != nil {
+		if _, ok := to.Params.Get("tag"); ok {
+			m.refresh(req, tx, legKey(callID, fromTag), event, expiry)
+			return
+		}
+	}
+
+	ext := m.auth.Authenticate(req, tx)
+	if ext == nil {
+		return
+	}
+
+	target := req.Recipient.User
+	if target == "" {
+		target = ext.Extension
+	}
+
+	// Voicemail counts are private to the mailbox owner.
+	if event == eventMessageSummary && target != ext.Extension {
+		m.logger.Warn("message-summary subscribe for another extension",
+			"call_id", callID,
+			"subscriber", ext.Extension,
+			"target", target,
+		)
+		m.respondError(req, tx, 403, "Forbidden")
+		return
+	}
+
+	if target != ext.Extension {
+		watched, err := m.extensions.GetByExtension(context.Background(), target)
+		if err != nil {
+			m.logger.Error("failed to look up subscribed extension",
+				"call_id", callID,
+				"target", target,
+				"error", err,
+			)
+			m.respondError(req, tx, 500, "Internal Server Error")
+			return
+		}
+		if watched == nil && !m.isParkOrbit(event, target) {
+			m.respondError(req, tx, 404, "Not Found")
+			return
+		}
+	}
+
+	if expiry > 0 && expiry < subscribeMinExpiry {
+		expiry = subscribeMinExpiry
+	}
+	if expiry > subscribeMaxExpiry {
+		expiry = subscribeMaxExpiry
+	}
+
+	sub := &subscription{
+		key:        legKey(callID, fromTag),
+		event:      event,
+		extension:  target,
+		subscriber: ext.Extension,
+		leg: dialogLeg{
+			callID:    callID,
+			remoteTag: fromTag,
+			uas:       true,
+			req:       req,
+			localTag:  sip.GenerateTagN(16),
+			seq:       new(atomic.Uint32),
+		},
+		source:  req.Source(),
+		expires: time.Now().Add(time.Duration(expiry) * time.Second),
+	}
+
+	res := sip.NewResponseFromRequest(req, 200, "OK", nil)
+	res.To().Params.Add("tag", sub.leg.localTag)
+	res.AppendHeader(sip.NewHeader("Expires", strconv.Itoa(expiry)))
+	if err := tx.Respond(res); err != nil {
+		m.logger.Error("failed to send subscribe response",
+			"call_id", callID,
+			"error", err,
+		)
+		return
+	}
+
+	// Expires: 0 on an initial SUBSCRIBE is a one-off fetch of the
+	// current state.
+	if expiry == 0 {
+		go m.notify(sub, "terminated;reason=timeout")
+		return
+	}
+
+	m.mu.Lock()
+	m.subs[sub.key] = sub
+	m.mu.Unlock()
+
+	m.logger.Info("subscription created",
+		"call_id", callID,
+		"event", event,
+		"subscriber", ext.Extension,
+		"extension", target,
+		"expires", expiry,
+	)
+
+	go m.notify(sub, "")
+}
+
+// isParkOrbit reports whether a dialog event subscription targets a park
+// orbit, whose lamp shows whether a call is parked in it.
+func (m *SubscriptionManager) isParkOrbit(event, target string) bool {
+	if event != eventDialog || m.parkLots == nil {
+		return false
+	}
+	lots, err := m.parkLots.ListEnabled(context.Background())
+	if err != nil {
+		m.logger.Error("failed to list park lots",
+			"target", target,
+			"error", err,
+		)
+		return false
+	}
+	_, orbit, _ := matchParkNumber(lots, target)
+	return orbit != ""
+}
+
+// refresh handles a SUBSCRIBE within an existing subscription dialog.
+func (m *SubscriptionManager) refresh(req *sip.Request, tx sip.ServerTransaction, key, event string, expiry int) {
+	if expiry > 0 && expiry < subscribeMinExpiry {
+		expiry = subscribeMinExpiry
+	}
+	if expiry > subscribeMaxExpiry {
+		expiry = subscribeMaxExpiry
+	}
+
+	m.mu.Lock()
+	sub, ok := m.subs[key]
+	if ok && sub.event != event {
+		ok = false
+	}
+	if ok {
+		sub.expires = time.Now().Add(time.Duration(expiry) * time.Second)
+		if expiry == 0 {
+			delete(m.subs, key)
+		}
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		m.respondError(req, tx, 481, "Subscription Does Not Exist")
+		return
+	}
+
+	res := sip.NewResponseFromRequest(req, 200, "OK", nil)
+	res.AppendHeader(sip.NewHeader("Expires", strconv.Itoa(expiry)))
+	if err := tx.Respond(res); err != nil {
+		m.logger.Error("failed to send subscribe response",
+			"call_id", sub.leg.callID,
+			"error", err,
+		)
+		return
+	}
+
+	if expiry == 0 {
+		m.logger.Info("subscription ended",
+			"call_id", sub.leg.callID,
+			"event", sub.event,
+			"extension", sub.extension,
+		)
+		go m.notify(sub, "terminated;reason=timeout")
+		return
+	}
+	go m.notify(sub, "")
+}
+
+// ExtensionsChanged sends dialog and presence NOTIFYs to the subscribers
+// watching any of the given extensions. It is registered as the
+// ExtensionStateListener of the dialog and pending call managers; the
+// NOTIFYs are sent asynchronously so it never blocks their callers.
+func (m *SubscriptionManager) ExtensionsChanged(extensions []string) {
+	for _, sub := range m.subscriptionsFor(extensions, eventDialog, eventPresence) {
+		go m.notify(sub, "")
+	}
+}
+
+// NotifyMessageSummary sends a message-summary NOTIFY with the given RFC
+// 3842 body to the subscribers of an extension's voicemail lamp.
+func (m *SubscriptionManager) NotifyMessageSummary(extension, body string) {
+	for _, sub := range m.subscriptionsFor([]string{extension}, eventMessageSummary) {
+		go m.notifyBody(sub, "", []byte(body))
+	}
+}
+
+// subscriptionsFor returns the subscriptions to any of the given event
+// packages watching any of the given extensions.
+func (m *SubscriptionManager) subscriptionsFor(extensions []string, events ...string) []*subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var subs []*subscription
+	for _, sub := range m.subs {
+		if !containsString(events, sub.event) || !containsString(extensions, sub.extension) {
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// RunExpiryCleanup periodically ends subscriptions that were not
+// refreshed in time, sending each subscriber a final NOTIFY.
+func (m *SubscriptionManager) RunExpiryCleanup(ctx context.Context) {
+	ticker := time.NewTicker(expiryCleanupPeriod)
+	defer ticker.Stop()
+
+	m.logger.Info("subscription expiry cleanup started",
+		"interval", expiryCleanupPeriod.String(),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.logger.Info("subscription expiry cleanup stopped")
+			return
+		case <-ticker.C:
+			expired := m.removeExpired(time.Now())
+			for _, sub := range expired {
+				go m.notify(sub, "terminated;reason=timeout")
+			}
+			if len(expired) > 0 {
+				m.logger.Info("expired subscriptions cleaned", "count", len(expired))
+			}
+		}
+	}
+}
+
+// removeExpired removes and returns the subscriptions that expired
+// before now.
+func (m *SubscriptionManager) removeExpired(now time.Time) []*subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []*subscription
+	for key, sub := range m.subs {
+		if sub.expires.Before(now) {
+			expired = append(expired, sub)
+			delete(m.subs, key)
+		}
+	}
+	return expired
+}
+
+// SubscriptionCount returns the number of active subscriptions.
+func (m *SubscriptionManager) SubscriptionCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.subs)
+}
+
+// notify sends a NOTIFY with the watched extension's current state.
+// terminated is the Subscription-State for a final NOTIFY, or "" while
+// the subscription is active.
+func (m *SubscriptionManager) notify(sub *subscription, terminated string) {
+	m.notifyBody(sub, terminated, nil)
+}
+
+// notifyBody sends a NOTIFY on a subscription. If body is nil the state
+// is looked up and rendered for the subscription's event package.
+func (m *SubscriptionManager) notifyBody(sub *subscription, terminated string, body []byte) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	// A NOTIFY racing with the end of the subscription is dropped; the
+	// final NOTIFY carries the state instead.
+	m.mu.Lock()
+	active := m.subs[sub.key] == sub
+	expires := sub.expires
+	m.mu.Unlock()
+	if !active && terminated == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeNotifyTimeout)
+	defer cancel()
+
+	var contentType string
+	switch sub.event {
+	case eventDialog:
+		sub.version++
+		contentType = "application/dialog-info+xml"
+		body = m.dialogInfoBody(sub.extension, sub.version)
+	case eventPresence:
+		contentType = "application/pidf+xml"
+		body = m.presenceBody(ctx, sub.extension)
+	case eventMessageSummary:
+		contentType = "application/simple-message-summary"
+		if body == nil {
+			var err error
+			body, err = m.messageSummary(ctx, sub.extension)
+			if err != nil {
+				m.logger.Error("failed to build message summary",
+					"call_id", sub.leg.callID,
+					"extension", sub.extension,
+					"error", err,
+				)
+				return
+			}
+		}
+	}
+
+	state := terminated
+	if state == "" {
+		remaining := int(time.Until(expires).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		state = fmt.Sprintf("active;expires=%d", remaining)
+	}
+
+	req := sub.leg.newRequest(sip.NOTIFY)
+	req.SetDestination(sub.source)
+	req.AppendHeader(sip.NewHeader("Event", sub.event))
+	req.AppendHeader(sip.NewHeader("Subscription-State", state))
+	req.AppendHeader(sip.NewHeader("Content-Type", contentType))
+	req.SetBody(body)
+
+	tx, err := m.forker.Client().TransactionRequest(ctx, req, sipgo.ClientRequestBuild)
+	if err != nil {
+		m.logger.Error("failed to send subscription notify",
+			"call_id", sub.leg.callID,
+			"event", sub.event,
+			"error", err,
+		)
+		return
+	}
+	defer tx.Terminate()
+
+	select {
+	case res := <-tx.Responses():
+		// 481 means the subscriber has forgotten the subscription.
+		if res.StatusCode == 481 {
+			m.remove(sub)
+		} else if res.StatusCode >= 300 {
+			m.logger.Warn("subscription notify rejected",
+				"call_id", sub.leg.callID,
+				"event", sub.event,
+				"status", res.StatusCode,
+			)
+		}
+	case <-tx.Done():
+	case <-ctx.Done():
+		m.logger.Warn("subscription notify timed out",
+			"call_id", sub.leg.callID,
+			"event", sub.event,
+		)
+	}
+}
+
+// remove drops a subscription the subscriber no longer knows about.
+func (m *SubscriptionManager) remove(sub *subscription) {
+	m.mu.Lock()
+	if m.subs[sub.key] == sub {
+		delete(m.subs, sub.key)
+	}
+	m.mu.Unlock()
+
+	m.logger.Info("subscription removed by subscriber",
+		"call_id", sub.leg.callID,
+		"event", sub.event,
+		"extension", sub.extension,
+	)
+}
+
+func (m *SubscriptionManager) respondError(req *sip.Request, tx sip.ServerTransaction, code int, reason string) {
+	res := sip.NewResponseFromRequest(req, code, reason, nil)
+	if err := tx.Respond(res); err != nil {
+		m.logger.Error("failed to send error response",
+			"code", code,
+			"error", err,
+		)
+	}
+}
+
+// parseEventPackage returns the event package named in the Event header,
+// without parameters.
+func parseEventPackage(req *sip.Request) string {
+	h := req.GetHeader("Event")
+	if h == nil {
+		return ""
+	}
+	pkg, _, _ := strings.Cut(h.Value(), ";")
+	return strings.ToLower(strings.TrimSpace(pkg))
+}
+
+// parseSubscribeExpiry returns the Expires header value of a SUBSCRIBE,
+// or the default subscription duration if it is missing or invalid.
+func parseSubscribeExpiry(req *sip.Request) int {
+	if h := req.GetHeader("Expires"); h != nil {
+		if exp, err := strconv.Atoi(strings.TrimSpace(h.Value())); err == nil && exp >= 0 {
+			return exp
+		}
+	}
+	return subscribeDefaultExpiry
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package sip
+
+import (
+	"encoding/xml"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+func TestParseEventPackage(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"dialog", "dialog"},
+		{"Presence", "presence"},
+		{"message-summary;id=1", "message-summary"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		req := sip.NewRequest(sip.SUBSCRIBE, sip.Uri{User: "101", Host: "10.0.0.1"})
+		if tt.value != "" {
+			req.AppendHeader(sip.NewHeader("Event", tt.value))
+		}
+		if got := parseEventPackage(req); got != tt.want {
+			t.Errorf("parseEventPackage(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseSubscribeExpiry(t *testing.T) {
+	req := sip.NewRequest(sip.SUBSCRIBE, sip.Uri{User: "101", Host: "10.0.0.1"})
+	if got := parseSubscribeExpiry(req); got != subscribeDefaultExpiry {
+		t.Errorf("missing Expires = %d, want %d", got, subscribeDefaultExpiry)
+	}
+	req.AppendHeader(sip.NewHeader("Expires", "0"))
+	if got := parseSubscribeExpiry(req); got != 0 {
+		t.Errorf("Expires: 0 = %d, want 0", got)
+	}
+}
+
+func TestDialogManagerNotifiesExtensionChanges(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+
+	var changes [][]string
+	dm.OnExtensionStateChange(func(exts []string) {
+		changes = append(changes, exts)
+	})
+
+	callerReq := newTestDialogRequest("caller-call", "101", "caller-tag", "102")
+	calleeReq := newTestDialogRequest("callee-call", "101", "pbx-tag", "102")
+	d := &Dialog{
+		CallID:      "caller-call",
+		CallerIDNum: "101",
+		CalledNum:   "102",
+		Caller:      CallLeg{FromTag: "caller-tag", ToTag: "pbx-local", Extension: &models.Extension{Extension: "101", Name: "Alice"}},
+		Callee:      CallLeg{FromTag: "pbx-tag", ToTag: "callee-tag", Extension: &models.Extension{Extension: "102", Name: "Bob"}},
+		CallerReq:   callerReq,
+		CalleeReq:   calleeReq,
+		CalleeRes:   newTestAnswer(calleeReq, "callee-tag"),
+	}
+	dm.CreateDialog(d)
+
+	if len(changes) != 1 || strings.Join(changes[0], ",") != "101,102" {
+		t.Fatalf("changes after create = %v", changes)
+	}
+
+	calls := dm.extensionCalls("102")
+	if len(calls) != 1 {
+		t.Fatalf("extensionCalls(102) = %+v", calls)
+	}
+	if c := calls[0]; c.id != "callee-call" || c.state != dialogStateConfirmed || c.initiator || c.remoteNum != "101" || c.remoteName != "Alice" {
+		t.Errorf("callee call = %+v", c)
+	}
+	if calls := dm.extensionCalls("101"); len(calls) != 1 || !calls[0].initiator || calls[0].remoteNum != "102" {
+		t.Errorf("extensionCalls(101) = %+v", calls)
+	}
+
+	dm.TerminateDialog(d.CallID, "test")
+	if len(changes) != 2 {
+		t.Fatalf("changes after terminate = %v", changes)
+	}
+	if calls := dm.extensionCalls("102"); len(calls) != 0 {
+		t.Errorf("extensionCalls after terminate = %+v", calls)
+	}
+}
+
+func TestRingingCalls(t *testing.T) {
+	req := newTestDialogRequest("ring-call", "101", "caller-tag", "102")
+	req.From().DisplayName = "Alice"
+	pending := []*PendingCall{
+		{CallID: "ring-call", CallerReq: req, Extensions: []string{"101", "102"}},
+		{CallID: "other-call", CallerReq: req, Extensions: []string{"103"}},
+	}
+
+	calls := ringingCalls("102", pending, nil)
+	if len(calls) != 1 {
+		t.Fatalf("ringingCalls(102) = %+v", calls)
+	}
+	if c := calls[0]; c.state != dialogStateEarly || c.initiator || c.remoteNum != "101" || c.remoteName != "Alice" {
+		t.Errorf("rung call = %+v", c)
+	}
+
+	calls = ringingCalls("101", pending, nil)
+	if len(calls) != 1 || !calls[0].initiator || calls[0].remoteNum != "102" {
+		t.Errorf("ringingCalls(101) = %+v", calls)
+	}
+
+	// A call already answered is reported by its dialog only.
+	answered := []extensionCall{{id: "ring-call", state: dialogStateConfirmed}}
+	if calls := ringingCalls("102", pending, answered); len(calls) != 0 {
+		t.Errorf("ringingCalls with answered dialog = %+v", calls)
+	}
+}
+
+func TestBuildDialogInfo(t *testing.T) {
+	body := buildDialogInfo("102", "pbx.example.com", 3, []extensionCall{
+		{id: "abc", state: dialogStateEarly, remoteNum: "101", remoteName: "Alice"},
+	})
+
+	var doc dialogInfo
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, body)
+	}
+	if doc.Version != 3 || doc.State != "full" || doc.Entity != "sip:102@pbx.example.com" {
+		t.Errorf("document attributes = %+v", doc)
+	}
+	if len(doc.Dialogs) != 1 {
+		t.Fatalf("dialogs = %+v", doc.Dialogs)
+	}
+	dlg := doc.Dialogs[0]
+	if dlg.ID != "abc" || dlg.Direction != "recipient" || dlg.State != "early" {
+		t.Errorf("dialog = %+v", dlg)
+	}
+	if dlg.Remote == nil || dlg.Remote.Identity.URI != "sip:101@pbx.example.com" || dlg.Remote.Identity.Display != "Alice" {
+		t.Errorf("remote = %+v", dlg.Remote)
+	}
+
+	idle := buildDialogInfo("102", "pbx.example.com", 4, nil)
+	if strings.Contains(string(idle), "<dialog ") {
+		t.Errorf("idle document has dialogs:\n%s", idle)
+	}
+}
+
+func TestBuildPresence(t *testing.T) {
+	tests := []struct {
+		name      string
+		online    bool
+		dnd       bool
+		calls     []extensionCall
+		wantBasic string
+		wantNote  string
+	}{
+		{"offline", false, false, nil, "closed", "Offline"},
+		{"idle", true, false, nil, "open", "Available"},
+		{"dnd", true, true, nil, "open", "Do not disturb"},
+		{"ringing", true, false, []extensionCall{{state: dialogStateEarly}}, "open", "Ringing"},
+		{"dialing", true, false, []extensionCall{{state: dialogStateEarly, initiator: true}}, "open", "Available"},
+		{"on call", true, true, []extensionCall{{state: dialogStateEarly}, {state: dialogStateConfirmed}}, "open", "On the phone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc pidfPresence
+			if err := xml.Unmarshal(buildPresence("101", "pbx.example.com", tt.online, tt.dnd, tt.calls), &doc); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if doc.Tuple.Status.Basic != tt.wantBasic || doc.Tuple.Note != tt.wantNote {
+				t.Errorf("basic, note = %q, %q; want %q, %q", doc.Tuple.Status.Basic, doc.Tuple.Note, tt.wantBasic, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestMessageSummaryBody(t *testing.T) {
+	want := "Messages-Waiting: yes\r\nMessage-Account: sip:101@10.0.0.1\r\nVoice-Message: 2/5 (2 new, 5 old)\r\n"
+	if got := messageSummaryBody("101", "10.0.0.1", 2, 5); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if got := messageSummaryBody("101", "10.0.0.1", 0, 5); !strings.HasPrefix(got, "Messages-Waiting: no\r\n") {
+		t.Errorf("body without new messages = %q", got)
+	}
+}
+
+func TestSubscriptionExpiryAndLookup(t *testing.T) {
+	m := &SubscriptionManager{subs: make(map[string]*subscription)}
+	now := time.Now()
+	m.subs["a"] = &subscription{key: "a", event: eventDialog, extension: "101", expires: now.Add(time.Minute)}
+	m.subs["b"] = &subscription{key: "b", event: eventPresence, extension: "101", expires: now.Add(-time.Second)}
+	m.subs["c"] = &subscription{key: "c", event: eventMessageSummary, extension: "101", expires: now.Add(time.Minute)}
+
+	if subs := m.subscriptionsFor([]string{"101"}, eventDialog, eventPresence); len(subs) != 2 {
+		t.Errorf("subscriptionsFor dialog/presence = %d, want 2", len(subs))
+	}
+	if subs := m.subscriptionsFor([]string{"102"}, eventDialog); len(subs) != 0 {
+		t.Errorf("subscriptionsFor other extension = %d, want 0", len(subs))
+	}
+
+	expired := m.removeExpired(now)
+	if len(expired) != 1 || expired[0].key != "b" {
+		t.Fatalf("expired = %+v", expired)
+	}
+	if m.SubscriptionCount() != 2 {
+		t.Errorf("SubscriptionCount = %d, want 2", m.SubscriptionCount())
+	}
+}
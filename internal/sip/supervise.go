@@ -0,0 +1,603 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// Supervisor feature codes, each followed by the extension whose call the
+// supervisor joins.
+const (
+	monitorPrefix = "*31"
+	whisperPrefix = "*32"
+	bargePrefix   = "*33"
+)
+
+// SuperviseMode is how a supervisor takes part in another extension's call.
+type SuperviseMode string
+
+const (
+	// SuperviseMonitor lets the supervisor listen without being heard.
+	SuperviseMonitor SuperviseMode = "monitor"
+	// SuperviseWhisper lets the supervisor speak to the agent only.
+	SuperviseWhisper SuperviseMode = "whisper"
+	// SuperviseBarge joins the supervisor to the call as a three-way call.
+	SuperviseBarge SuperviseMode = "barge"
+)
+
+// Mixer participant IDs of a supervised call.
+const (
+	superviseCallerID     = "caller"
+	superviseCalleeID     = "callee"
+	superviseSupervisorID = "supervisor"
+)
+
+var (
+	// ErrCallSupervised is returned when a call already has a supervisor.
+	ErrCallSupervised = errors.New("call already has a supervisor")
+
+	// ErrNotSupervisor is returned when an extension that may not
+	// supervise calls tries to.
+	ErrNotSupervisor = errors.New("extension is not a supervisor")
+)
+
+// parseSuperviseCode reports whether a dialled number is a supervisor
+// feature code, returning the mode and the extension whose call to join.
+func parseSuperviseCode(user string) (SuperviseMode, string, bool) {
+	codes := []struct {
+		prefix string
+		mode   SuperviseMode
+	}{
+		{monitorPrefix, SuperviseMonitor},
+		{whisperPrefix, SuperviseWhisper},
+		{bargePrefix, SuperviseBarge},
+	}
+	for _, c := range codes {
+		if target, ok := strings.CutPrefix(user, c.prefix); ok && target != "" {
+			return c.mode, target, true
+		}
+	}
+	return "", "", false
+}
+
+// supervision is a supervisor taking part in a call. The call's legs stay
+// on its relay, which hands their audio to a mixer shared with the
+// supervisor's leg; the mode sets who hears the supervisor. It is linked
+// from both the supervised call's dialog and the supervisor's own.
+type supervision struct {
+	call        *Dialog
+	agentCaller bool   // the agent is the supervised call's caller
+	extension   string // the supervisor's extension number
+	logger      *slog.Logger
+
+	// hangup hangs up the supervisor's call when the supervised call ends.
+	hangup func(d *Dialog)
+
+	mu         sync.Mutex
+	mixer      *media.Mixer
+	mode       SuperviseMode
+	supervisor *Dialog // the supervisor's own call, once answered
+	stopped    bool
+}
+
+// applyMode sets who hears the supervisor: nobody when monitoring, the
+// agent when whispering, both parties when barged in. Must be called with
+// sv.mu held.
+func (sv *supervision) applyMode(mode SuperviseMode) error {
+	agent, other := superviseCalleeID, superviseCallerID
+	if sv.agentCaller {
+		agent, other = other, agent
+	}
+	if err := sv.mixer.SetHears(agent, superviseSupervisorID, mode != SuperviseMonitor); err != nil {
+		return err
+	}
+	if err := sv.mixer.SetHears(other, superviseSupervisorID, mode == SuperviseBarge); err != nil {
+		return err
+	}
+	sv.mode = mode
+	return nil
+}
+
+// setMode switches a running supervision to another mode.
+func (sv *supervision) setMode(mode SuperviseMode) error {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.stopped {
+		return ErrCallNotFound
+	}
+	if err := sv.applyMode(mode); err != nil {
+		return err
+	}
+	sv.logger.Info("call supervision mode changed",
+		"call_id", sv.call.CallID,
+		"supervisor", sv.extension,
+		"mode", mode,
+	)
+	return nil
+}
+
+// attach links the supervisor's own call to the supervision. It returns
+// false if the supervision has already ended, in which case the
+// supervisor's call should not go ahead.
+func (sv *supervision) attach(supervisor *Dialog) bool {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.stopped {
+		return false
+	}
+	sv.supervisor = supervisor
+	supervisor.mediaMu.Lock()
+	supervisor.supervision = sv
+	supervisor.mediaMu.Unlock()
+	return true
+}
+
+// stop takes the supervisor out of the call, resuming the relay between
+// the call's legs, and returns the supervisor's call if it was answered.
+// Later calls return nil.
+func (sv *supervision) stop() *Dialog {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.stopped {
+		return nil
+	}
+	sv.stopped = true
+
+	if sv.call.Media != nil {
+		sv.call.Media.DetachMixer()
+	}
+	if sv.mixer != nil {
+		sv.mixer.Release()
+	}
+	sv.call.clearSupervision(sv)
+	if sv.supervisor != nil {
+		sv.supervisor.clearSupervision(sv)
+	}
+	return sv.supervisor
+}
+
+// clearSupervision unlinks sv from d if it is still linked.
+func (d *Dialog) clearSupervision(sv *supervision) {
+	d.mediaMu.Lock()
+	if d.supervision == sv {
+		d.supervision = nil
+	}
+	d.mediaMu.Unlock()
+}
+
+// endSupervision ends the supervision d takes part in, whether d is the
+// supervised call or the supervisor's own. When the supervised call ends
+// or changes parties, the supervisor is hung up.
+func (d *Dialog) endSupervision() {
+	d.mediaMu.Lock()
+	sv := d.supervision
+	d.mediaMu.Unlock()
+	if sv == nil {
+		return
+	}
+	if supervisor := sv.stop(); supervisor != nil && supervisor != d {
+		go sv.hangup(supervisor)
+	}
+}
+
+// ExtensionCall returns the answered call an extension is on and whether
+// the extension is its caller, or nil if it is on none. Calls joined by
+// an attended transfer, parked calls and supervisors' own calls are
+// skipped.
+func (dm *DialogManager) ExtensionCall(extension string) (*Dialog, bool) {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	for _, d := range dm.dialogs {
+		if d.Peer != nil || d.Direction == CallTypeSupervise || !d.hasCallee() || d.parked.Load() != nil {
+			continue
+		}
+		if ext := d.Callee.Extension; ext != nil && ext.Extension == extension {
+			return d, false
+		}
+		if ext := d.Caller.Extension; ext != nil && ext.Extension == extension {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// supervisedLegCodec returns the audio codec one side of a call's device
+// sends, which the mixer decodes and answers in.
+func supervisedLegCodec(d *Dialog, callerSide bool) (media.LegCodec, bool) {
+	if caller, callee, ok := d.Media.Codecs(); ok {
+		if callerSide {
+			return caller, true
+		}
+		return callee, true
+	}
+	name := negotiatedCodec(d, callerSide)
+	sd, err := media.ParseSDP(d.leg(callerSide).remoteSDP())
+	if name == "" || err != nil || sd.AudioMedia() == nil {
+		return media.LegCodec{}, false
+	}
+	pt, ok := codecPayloadType(sd.AudioMedia(), name)
+	if !ok {
+		return media.LegCodec{}, false
+	}
+	return legCodec(sd.AudioMedia(), pt)
+}
+
+// startSupervision moves the legs of d into a mixer together with a
+// supervisor's leg that sends codec from remote; a nil remote is learned
+// from the supervisor's first packet. agentCaller tells which of d's legs
+// is the agent a whisper reaches. It returns the supervision and the
+// mixer port the supervisor's device must send to.
+func (a *FlowSIPActions) startSupervision(d *Dialog, agentCaller bool, mode SuperviseMode, extension string, remote *net.UDPAddr, codec media.LegCodec) (*supervision, int, error) {
+	if d.Media == nil || d.Peer != nil || !d.hasCallee() || d.Direction == CallTypeSupervise || d.parked.Load() != nil {
+		return nil, 0, ErrCallUncontrollable
+	}
+	callerCodec, callerOK := supervisedLegCodec(d, true)
+	calleeCodec, calleeOK := supervisedLegCodec(d, false)
+	if !callerOK || !calleeOK {
+		return nil, 0, fmt.Errorf("%w: call codec cannot be mixed", ErrCallUncontrollable)
+	}
+
+	sv := &supervision{
+		call:        d,
+		agentCaller: agentCaller,
+		extension:   extension,
+		logger:      a.logger,
+		hangup: func(supervisor *Dialog) {
+			a.hangupDialog(supervisor, "supervised_call_ended")
+		},
+	}
+
+	// Claim the call first so a second supervisor is turned away.
+	d.mediaMu.Lock()
+	if d.supervision != nil {
+		d.mediaMu.Unlock()
+		return nil, 0, ErrCallSupervised
+	}
+	d.supervision = sv
+	d.mediaMu.Unlock()
+
+	// Hold sv.mu until the mixer runs, so a hangup meanwhile waits for
+	// the setup to finish before undoing it.
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	mixer := media.NewMixer(a.sessionMgr.Proxy(), a.logger)
+	pair, err := mixer.AddParticipant(superviseSupervisorID, remote, codec)
+	if err != nil {
+		d.clearSupervision(sv)
+		return nil, 0, fmt.Errorf("adding supervisor to mixer: %w", err)
+	}
+	if err := d.Media.AttachMixer(mixer, superviseCallerID, superviseCalleeID, callerCodec, calleeCodec); err != nil {
+		mixer.Release()
+		d.clearSupervision(sv)
+		return nil, 0, fmt.Errorf("attaching call to mixer: %w", err)
+	}
+	sv.mixer = mixer
+	if err := sv.applyMode(mode); err != nil {
+		d.Media.DetachMixer()
+		mixer.Release()
+		sv.mixer = nil
+		d.clearSupervision(sv)
+		return nil, 0, fmt.Errorf("setting supervise mode: %w", err)
+	}
+	mixer.Start(context.Background())
+
+	a.logger.Info("call supervision started",
+		"call_id", d.CallID,
+		"supervisor", extension,
+		"mode", mode,
+	)
+	return sv, pair.Ports.RTP, nil
+}
+
+// handleSupervise joins a supervisor who dialled a supervise feature code
+// to the answered call of the extension in the code. The supervisor's
+// phone is answered with a plain RTP stream from the call's mixer.
+func (h *InviteHandler) handleSupervise(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string) {
+	if h.flowActions == nil || h.sessionMgr == nil {
+		h.respondErrorWithCDR(req, tx, 501, "Not Implemented", callID)
+		return
+	}
+
+	ext := ic.CallerExtension
+	if !ext.Supervisor {
+		h.logger.Warn("supervise rejected: extension is not a supervisor",
+			"call_id", callID,
+			"extension", ext.Extension,
+		)
+		h.respondErrorWithCDR(req, tx, 403, "Forbidden", callID)
+		return
+	}
+
+	d, agentCaller := h.dialogMgr.ExtensionCall(ic.SuperviseExtension)
+	if d == nil {
+		h.logger.Info("supervise found no answered call",
+			"call_id", callID,
+			"extension", ext.Extension,
+			"target", ic.SuperviseExtension,
+		)
+		h.respondErrorWithCDR(req, tx, 404, "Not Found", callID)
+		return
+	}
+
+	offer, remote, codec, err := supervisorOffer(req.Body())
+	if err != nil {
+		h.logger.Warn("supervise rejected: unusable media offer",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondErrorWithCDR(req, tx, 488, "Not Acceptable Here", callID)
+		return
+	}
+
+	sv, port, err := h.flowActions.startSupervision(d, agentCaller, ic.SuperviseMode, ext.Extension, remote, codec)
+	if err != nil {
+		h.logger.Warn("failed to start call supervision",
+			"call_id", callID,
+			"supervised_call_id", d.CallID,
+			"error", err,
+		)
+		code, reason := 500, "Internal Server Error"
+		switch {
+		case errors.Is(err, ErrCallSupervised):
+			code, reason = 486, "Busy Here"
+		case errors.Is(err, ErrCallUncontrollable):
+			code, reason = 488, "Not Acceptable Here"
+		}
+		h.respondErrorWithCDR(req, tx, code, reason, callID)
+		return
+	}
+
+	answer := media.RewriteSDP(offer, h.proxyIP, port)
+	narrowAnswer(answer, offer, codec.Codec.Name)
+	if audio := answer.AudioMedia(); audio != nil {
+		setAnswerSRTP(audio, offer.AudioMedia(), nil)
+	}
+	res := sip.NewResponseFromRequest(req, 200, "OK", answer.Marshal())
+	res.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+
+	dialog := &Dialog{
+		CallID:       callID,
+		Direction:    CallTypeSupervise,
+		CallerIDName: ic.CallerIDName,
+		CallerIDNum:  ic.CallerIDNum,
+		CalledNum:    ic.RequestURI,
+		StartTime:    time.Now(),
+		CallerTx:     tx,
+		CallerReq:    req,
+		Caller:       CallLeg{Extension: ext},
+	}
+	if from := req.From(); from != nil {
+		dialog.Caller.FromTag, _ = from.Params.Get("tag")
+	}
+	if to := res.To(); to != nil {
+		dialog.Caller.ToTag, _ = to.Params.Get("tag")
+	}
+
+	// The supervised call may have ended while the mixer was set up.
+	if !sv.attach(dialog) {
+		h.respondErrorWithCDR(req, tx, 404, "Not Found", callID)
+		return
+	}
+	if err := tx.Respond(res); err != nil {
+		h.logger.Error("failed to send 200 ok to supervisor",
+			"call_id", callID,
+			"error", err,
+		)
+		sv.stop()
+		h.finalizeCDRFailed(callID, 500)
+		return
+	}
+
+	h.dialogMgr.CreateDialog(dialog)
+	h.updateCDROnAnswer(callID, 0, nil)
+
+	h.logger.Info("supervisor joined call",
+		"call_id", callID,
+		"supervised_call_id", d.CallID,
+		"supervisor", ext.Extension,
+		"mode", ic.SuperviseMode,
+	)
+}
+
+// supervisorOffer checks the SDP offer of a supervisor's phone, returning
+// it parsed together with the phone's RTP address and the first codec in
+// it the mixer supports. Supervisor legs are plain RTP.
+func supervisorOffer(body []byte) (*media.SessionDescription, *net.UDPAddr, media.LegCodec, error) {
+	if len(body) == 0 {
+		return nil, nil, media.LegCodec{}, fmt.Errorf("no sdp offer")
+	}
+	sd, err := media.ParseSDP(body)
+	if err != nil {
+		return nil, nil, media.LegCodec{}, fmt.Errorf("parsing sdp offer: %w", err)
+	}
+	audio := sd.AudioMedia()
+	if audio == nil {
+		return nil, nil, media.LegCodec{}, fmt.Errorf("sdp offer has no audio media")
+	}
+	if _, err := answerSRTP(audio, srtpModeOff); err != nil {
+		return nil, nil, media.LegCodec{}, err
+	}
+	remote, err := extractRTPAddr(sd)
+	if err != nil {
+		return nil, nil, media.LegCodec{}, err
+	}
+	codec, ok := firstLegCodec(audio)
+	if !ok {
+		return nil, nil, media.LegCodec{}, fmt.Errorf("sdp offer has no supported codec")
+	}
+	return sd, remote, codec, nil
+}
+
+// superviseCall rings a supervisor's extension and, once it answers, joins
+// it to the call in the given mode. If the supervisor is already on the
+// call, only the mode is changed.
+func (a *FlowSIPActions) superviseCall(ctx context.Context, callID, extension string, mode SuperviseMode) error {
+	d := a.dialogMgr.GetDialog(callID)
+	if d == nil {
+		return ErrCallNotFound
+	}
+
+	d.mediaMu.Lock()
+	current := d.supervision
+	d.mediaMu.Unlock()
+	if current != nil {
+		if current.call != d || current.extension != extension {
+			return ErrCallSupervised
+		}
+		return current.setMode(mode)
+	}
+
+	ext, err := a.extensions.GetByExtension(ctx, extension)
+	if err != nil {
+		return fmt.Errorf("looking up extension %s: %w", extension, err)
+	}
+	if ext == nil {
+		return ErrExtensionNotFound
+	}
+	if !ext.Supervisor {
+		return ErrNotSupervisor
+	}
+	if d.Media == nil || d.CallerReq == nil || !d.hasCallee() {
+		return ErrCallUncontrollable
+	}
+
+	// The agent is the call's extension, preferring the callee: on an
+	// internal call, the extension that was called.
+	agentCaller := d.Callee.Extension == nil
+	codec, ok := supervisedLegCodec(d, agentCaller)
+	if !ok {
+		return fmt.Errorf("%w: call codec cannot be mixed", ErrCallUncontrollable)
+	}
+
+	target, err := a.resolveTransferTarget(ctx, ext.Extension, nil)
+	if err != nil {
+		return fmt.Errorf("routing to supervisor: %w", err)
+	}
+
+	start := time.Now()
+	sv, port, err := a.startSupervision(d, agentCaller, mode, ext.Extension, nil, codec)
+	if err != nil {
+		return err
+	}
+	offer, err := a.supervisorOfferSDP(d, agentCaller, codec, port)
+	if err != nil {
+		sv.stop()
+		return err
+	}
+
+	ringCtx, cancel := context.WithTimeout(ctx, callControlRingTimeout)
+	defer cancel()
+	answer, err := a.dialTransferTarget(ringCtx, d.callerInvite(), d.CallID, target, offer, d.CallerIDName, d.CallerIDNum)
+	if err != nil {
+		sv.stop()
+		return fmt.Errorf("ringing supervisor: %w", err)
+	}
+
+	leg := answer.leg()
+	answerSD, err := media.ParseSDP(answer.res.Body())
+	if err == nil && answerSD.AudioMedia() == nil {
+		err = fmt.Errorf("sdp answer has no audio media")
+	}
+	if err == nil {
+		_, err = acceptSRTPAnswer(answerSD.AudioMedia(), nil, srtpModeOff)
+	}
+	if err != nil {
+		a.sendLegBYE(leg, d.CallID)
+		sv.stop()
+		return fmt.Errorf("reading supervisor sdp: %w", err)
+	}
+
+	dialog := a.supervisorDialog(d, ext, answer, leg, start)
+	if !sv.attach(dialog) {
+		a.sendLegBYE(leg, dialog.CallID)
+		return ErrCallNotFound
+	}
+	a.dialogMgr.CreateDialog(dialog)
+	a.createSupervisorCDR(dialog)
+
+	a.logger.Info("supervisor joined call",
+		"call_id", dialog.CallID,
+		"supervised_call_id", d.CallID,
+		"supervisor", ext.Extension,
+		"mode", mode,
+	)
+	return nil
+}
+
+// supervisorOfferSDP builds the SDP offered to a supervisor's phone: the
+// agent's media description pointed at the supervisor's mixer port,
+// narrowed to the codec the mixer sends, without SRTP.
+func (a *FlowSIPActions) supervisorOfferSDP(d *Dialog, agentCaller bool, codec media.LegCodec, port int) ([]byte, error) {
+	sd, err := media.ParseSDP(d.leg(agentCaller).remoteSDP())
+	if err != nil {
+		return nil, fmt.Errorf("parsing agent sdp: %w", err)
+	}
+	offer := media.RewriteSDP(sd, a.proxyIP, port)
+	audio := offer.AudioMedia()
+	if audio == nil {
+		return nil, fmt.Errorf("agent sdp has no audio media")
+	}
+	audio.RetainFormats([]int{codec.PayloadType})
+	audio.SetDirection("sendrecv")
+	if _, err := offerSRTP(audio, srtpModeOff); err != nil {
+		return nil, err
+	}
+	return offer.Marshal(), nil
+}
+
+// supervisorDialog builds the dialog of a supervisor's phone the PBX rang
+// to join d. The answered leg is its caller side, as on a caller leg
+// replaced by a transfer; it has no callee and no media session of its
+// own, its audio going through the supervision's mixer.
+func (a *FlowSIPActions) supervisorDialog(d *Dialog, ext *models.Extension, answer *transferAnswer, leg dialogLeg, start time.Time) *Dialog {
+	dialog := &Dialog{
+		Direction:    CallTypeSupervise,
+		CallerIDName: ext.Name,
+		CallerIDNum:  ext.Extension,
+		CalledNum:    d.CalledNum,
+		StartTime:    start,
+		CallerOutReq: answer.req,
+		CallerOutRes: answer.res,
+		Caller:       answer.callLeg(ext, leg.target),
+	}
+	if ext := d.Callee.Extension; ext != nil {
+		dialog.CalledNum = ext.Extension
+	}
+	dialog.CallID = dialog.Caller.CallID
+	return dialog
+}
+
+// createSupervisorCDR records a supervisor call the PBX rang from the API,
+// already answered. It is finalized like any other call when it ends.
+func (a *FlowSIPActions) createSupervisorCDR(d *Dialog) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cdr := &models.CDR{
+		CallID:       d.CallID,
+		StartTime:    d.StartTime,
+		AnswerTime:   d.AnswerTime,
+		CallerIDName: d.CallerIDName,
+		CallerIDNum:  d.CallerIDNum,
+		Callee:       d.CalledNum,
+		Direction:    string(CallTypeSupervise),
+		Disposition:  "in_progress",
+	}
+	if err := a.cdrs.Create(ctx, cdr); err != nil {
+		a.logger.Error("failed to create supervisor cdr",
+			"call_id", d.CallID,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,91 @@
+package sip
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/database/models"
+)
+
+func TestParseSuperviseCode(t *testing.T) {
+	tests := []struct {
+		user       string
+		wantMode   SuperviseMode
+		wantTarget string
+		wantOK     bool
+	}{
+		{"*31102", SuperviseMonitor, "102", true},
+		{"*32102", SuperviseWhisper, "102", true},
+		{"*33102", SuperviseBarge, "102", true},
+		{"*31", "", "", false},
+		{"*34102", "", "", false},
+		{"102", "", "", false},
+	}
+	for _, tt := range tests {
+		mode, target, ok := parseSuperviseCode(tt.user)
+		if mode != tt.wantMode || target != tt.wantTarget || ok != tt.wantOK {
+			t.Errorf("parseSuperviseCode(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.user, mode, target, ok, tt.wantMode, tt.wantTarget, tt.wantOK)
+		}
+	}
+}
+
+func TestDialogManagerExtensionCall(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+	d := newTestDialog(dm)
+	d.Caller.Extension = &models.Extension{Extension: "101"}
+	d.Callee.Extension = &models.Extension{Extension: "102"}
+
+	if got, caller := dm.ExtensionCall("102"); got != d || caller {
+		t.Errorf("ExtensionCall(102) = %v, %v; want the dialog as callee", got, caller)
+	}
+	if got, caller := dm.ExtensionCall("101"); got != d || !caller {
+		t.Errorf("ExtensionCall(101) = %v, %v; want the dialog as caller", got, caller)
+	}
+	if got, _ := dm.ExtensionCall("103"); got != nil {
+		t.Error("expected no call for an idle extension")
+	}
+}
+
+func TestSupervisionEndsWithCall(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+	call := newTestDialog(dm)
+	supervisor := &Dialog{
+		CallID:    "supervisor-call",
+		Direction: CallTypeSupervise,
+		CallerReq: newTestDialogRequest("supervisor-call", "200", "sup-tag", "*31102"),
+	}
+	dm.CreateDialog(supervisor)
+
+	hungUp := make(chan *Dialog, 1)
+	sv := &supervision{
+		call:   call,
+		logger: logger,
+		hangup: func(d *Dialog) { hungUp <- d },
+	}
+	call.supervision = sv
+	if !sv.attach(supervisor) {
+		t.Fatal("expected attach to succeed on a running supervision")
+	}
+
+	dm.TerminateDialog(call.CallID, "caller_bye")
+
+	select {
+	case d := <-hungUp:
+		if d != supervisor {
+			t.Errorf("hung up %s, want the supervisor's call", d.CallID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("supervisor was not hung up when the call ended")
+	}
+	if call.supervision != nil || supervisor.supervision != nil {
+		t.Error("expected the supervision to be unlinked from both dialogs")
+	}
+	if sv.attach(supervisor) {
+		t.Error("expected attach to fail after the supervision ended")
+	}
+}
@@ -0,0 +1,176 @@
+package sip
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
+)
+
+// legCodec resolves payload type pt of a media description to a codec the
+// media layer can decode, by rtpmap name or, for static payload types
+// listed without rtpmap, by number. ok is false if the codec is unknown.
+func legCodec(m *media.MediaDescription, pt int) (media.LegCodec, bool) {
+	if c := m.CodecByPayloadType(pt); c != nil {
+		codec := codecs.Lookup(c.Name)
+		if codec == nil || codec.ClockRate != c.ClockRate {
+			return media.LegCodec{PayloadType: pt}, false
+		}
+		return media.LegCodec{PayloadType: pt, Codec: codec}, true
+	}
+	if codec := codecs.LookupPayloadType(pt); codec != nil {
+		return media.LegCodec{PayloadType: pt, Codec: codec}, true
+	}
+	return media.LegCodec{PayloadType: pt}, false
+}
+
+// opusOnlyOffer reports whether an SDP offer has Opus but no other codec
+// the media layer can decode, in a build without Opus (see codecs). Such a
+// call could only be relayed to a party that also answers Opus; prompts,
+// hold music and transcoding to any other party would fail mid-call.
+func opusOnlyOffer(body []byte) bool {
+	if len(body) == 0 || codecs.Lookup("opus") != nil {
+		return false
+	}
+	sd, err := media.ParseSDP(body)
+	if err != nil || sd.AudioMedia() == nil {
+		return false
+	}
+	audio := sd.AudioMedia()
+	if !audio.HasCodec("opus") {
+		return false
+	}
+	_, ok := firstLegCodec(audio)
+	return !ok
+}
+
+// firstLegCodec returns the first format of m the media layer can decode.
+func firstLegCodec(m *media.MediaDescription) (media.LegCodec, bool) {
+	for _, pt := range m.Formats {
+		if lc, ok := legCodec(m, pt); ok {
+			return lc, true
+		}
+	}
+	return media.LegCodec{}, false
+}
+
+// negotiateBridgeCodecs picks the audio codec for each leg of a bridged
+// call. A codec common to both legs is preferred, so audio is relayed
+// untouched; otherwise each leg keeps its own codec and the relay
+// transcodes between them. Codecs are nil for a common codec the media
+// layer does not know, which can still be relayed but not transcoded.
+func negotiateBridgeCodecs(callerSDP, calleeSDP *media.SessionDescription) (caller, callee media.LegCodec, err error) {
+	pt, name, err := negotiateAudioCodec(callerSDP, calleeSDP)
+	if err == nil {
+		callee, _ = legCodec(calleeSDP.AudioMedia(), pt)
+		callerPT, ok := codecPayloadType(callerSDP.AudioMedia(), name)
+		if !ok {
+			callerPT = pt
+		}
+		caller, _ = legCodec(callerSDP.AudioMedia(), callerPT)
+		return caller, callee, nil
+	}
+
+	var ok bool
+	if callee, ok = firstLegCodec(calleeSDP.AudioMedia()); !ok {
+		return media.LegCodec{}, media.LegCodec{}, err
+	}
+	if caller, ok = firstLegCodec(callerSDP.AudioMedia()); !ok {
+		return media.LegCodec{}, media.LegCodec{}, err
+	}
+	return caller, callee, nil
+}
+
+// offerTranscodableCodecs appends every registered codec the offer lacks
+// to its audio media, so the answerer can pick a codec the offerer does
+// not support and the relay can transcode. Offers with no registered
+// codec are left alone, since their audio could not be transcoded. Opus
+// is only registered, and so only added, in builds with libopus.
+func offerTranscodableCodecs(sd *media.SessionDescription) {
+	audio := sd.AudioMedia()
+	if audio == nil {
+		return
+	}
+
+	offered := make(map[*codecs.Codec]bool)
+	used := make(map[int]bool)
+	for _, pt := range audio.Formats {
+		used[pt] = true
+		if lc, ok := legCodec(audio, pt); ok {
+			offered[lc.Codec] = true
+		}
+	}
+	if len(offered) == 0 {
+		return
+	}
+
+	for _, c := range codecs.All() {
+		if offered[c] {
+			continue
+		}
+		pt, ok := c.PayloadType, !used[c.PayloadType]
+		if !ok && c.Dynamic() {
+			pt, ok = freeDynamicPayloadType(used)
+		}
+		if !ok {
+			continue
+		}
+		used[pt] = true
+		audio.AddCodec(media.Codec{
+			PayloadType: pt,
+			Name:        c.Name,
+			ClockRate:   c.ClockRate,
+			Channels:    c.Channels,
+			Fmtp:        c.Fmtp,
+		})
+	}
+}
+
+// freeDynamicPayloadType returns the lowest dynamic payload type not in used.
+func freeDynamicPayloadType(used map[int]bool) (int, bool) {
+	for pt := 96; pt <= 127; pt++ {
+		if !used[pt] {
+			return pt, true
+		}
+	}
+	return 0, false
+}
+
+// sdpCodec returns the rtpmap entry a media description has for a leg's
+// codec, synthesising one for static payload types listed without rtpmap.
+func sdpCodec(m *media.MediaDescription, lc media.LegCodec) media.Codec {
+	if c := m.CodecByPayloadType(lc.PayloadType); c != nil {
+		return *c
+	}
+	return media.Codec{
+		PayloadType: lc.PayloadType,
+		Name:        lc.Codec.Name,
+		ClockRate:   lc.Codec.ClockRate,
+		Channels:    lc.Codec.Channels,
+		Fmtp:        lc.Codec.Fmtp,
+	}
+}
+
+// answerWithCodec narrows the audio media of an answer to codec c, which
+// the offerer supports but the answer's author may not, keeping only
+// telephone-event alongside it. Used when the relay transcodes, so each
+// party sees an answer in its own codec.
+func answerWithCodec(answer *media.SessionDescription, c media.Codec) error {
+	audio := answer.AudioMedia()
+	if audio == nil {
+		return fmt.Errorf("answer has no audio media")
+	}
+
+	var keep []int
+	if te := audio.CodecByName("telephone-event"); te != nil && te.PayloadType != c.PayloadType {
+		keep = append(keep, te.PayloadType)
+	}
+	audio.RetainFormats(keep)
+	audio.AddCodec(c)
+
+	// Put the codec ahead of telephone-event.
+	audio.Formats = slices.DeleteFunc(slices.Clone(audio.Formats), func(pt int) bool { return pt == c.PayloadType })
+	audio.Formats = slices.Insert(audio.Formats, 0, c.PayloadType)
+	return nil
+}
@@ -0,0 +1,164 @@
+package sip
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+	"github.com/flowpbx/flowpbx/internal/media/codecs"
+)
+
+// testAudioSDP parses an SDP with one audio line and the given attributes.
+func testAudioSDP(t *testing.T, mline string, attrs ...string) *media.SessionDescription {
+	t.Helper()
+	body := "v=0\r\n" +
+		"o=- 1 1 IN IP4 10.0.0.1\r\n" +
+		"s=-\r\n" +
+		"c=IN IP4 10.0.0.1\r\n" +
+		"t=0 0\r\n" +
+		mline + "\r\n"
+	for _, a := range attrs {
+		body += "a=" + a + "\r\n"
+	}
+	sd, err := media.ParseSDP([]byte(body))
+	if err != nil {
+		t.Fatalf("ParseSDP: %v", err)
+	}
+	return sd
+}
+
+func TestLegCodec(t *testing.T) {
+	audio := testAudioSDP(t, "m=audio 5004 RTP/AVP 0 9 96 101 18",
+		"rtpmap:96 G722/16000",
+		"rtpmap:101 telephone-event/8000",
+	).AudioMedia()
+
+	tests := []struct {
+		pt     int
+		want   *codecs.Codec
+		wantOK bool
+	}{
+		{0, codecs.PCMU, true},
+		{9, codecs.G722, true},
+		{96, nil, false}, // wrong clock rate for G.722
+		{101, nil, false},
+		{18, nil, false},
+	}
+	for _, tt := range tests {
+		lc, ok := legCodec(audio, tt.pt)
+		if ok != tt.wantOK || lc.Codec != tt.want || lc.PayloadType != tt.pt {
+			t.Errorf("legCodec(%d) = %s, %v; want ok %v", tt.pt, lc, ok, tt.wantOK)
+		}
+	}
+}
+
+func TestNegotiateBridgeCodecs(t *testing.T) {
+	tests := []struct {
+		name       string
+		caller     string
+		callee     string
+		wantCaller string
+		wantCallee string
+		wantErr    bool
+	}{
+		{"common codec", "m=audio 5004 RTP/AVP 8 0", "m=audio 6000 RTP/AVP 0", "PCMU/0", "PCMU/0", false},
+		{"transcode", "m=audio 5004 RTP/AVP 9 101", "m=audio 6000 RTP/AVP 8 101", "G722/9", "PCMA/8", false},
+		{"unknown common codec", "m=audio 5004 RTP/AVP 18", "m=audio 6000 RTP/AVP 18", "unknown/18", "unknown/18", false},
+		{"nothing to transcode", "m=audio 5004 RTP/AVP 3", "m=audio 6000 RTP/AVP 0", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller, callee, err := negotiateBridgeCodecs(
+				testAudioSDP(t, tt.caller, "rtpmap:18 G729/8000", "rtpmap:101 telephone-event/8000"),
+				testAudioSDP(t, tt.callee, "rtpmap:18 G729/8000", "rtpmap:101 telephone-event/8000"),
+			)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s and %s", caller, callee)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("negotiateBridgeCodecs: %v", err)
+			}
+			if caller.String() != tt.wantCaller || callee.String() != tt.wantCallee {
+				t.Errorf("codecs = %s, %s; want %s, %s", caller, callee, tt.wantCaller, tt.wantCallee)
+			}
+		})
+	}
+}
+
+func TestOfferTranscodableCodecs(t *testing.T) {
+	sd := testAudioSDP(t, "m=audio 5004 RTP/AVP 9 101", "rtpmap:101 telephone-event/8000")
+	offerTranscodableCodecs(sd)
+	audio := sd.AudioMedia()
+
+	if !slices.Equal(audio.Formats[:2], []int{9, 101}) {
+		t.Errorf("formats = %v, want the caller's own codecs first", audio.Formats)
+	}
+	for _, name := range []string{"PCMU", "PCMA"} {
+		if _, ok := codecPayloadType(audio, name); !ok {
+			t.Errorf("offer lacks %s: %v", name, audio.Formats)
+		}
+	}
+	if opus := codecs.Lookup("opus"); opus != nil {
+		if c := audio.CodecByName("opus"); c == nil || c.PayloadType < 96 || c.PayloadType == 101 {
+			t.Errorf("opus offered as %v", c)
+		}
+	}
+
+	// An offer with nothing the media layer can decode is left alone.
+	sd = testAudioSDP(t, "m=audio 5004 RTP/AVP 18")
+	offerTranscodableCodecs(sd)
+	if got := sd.AudioMedia().Formats; !slices.Equal(got, []int{18}) {
+		t.Errorf("formats = %v, want [18]", got)
+	}
+}
+
+func TestAnswerWithCodec(t *testing.T) {
+	answer := testAudioSDP(t, "m=audio 6000 RTP/AVP 8 101",
+		"rtpmap:8 PCMA/8000",
+		"rtpmap:101 telephone-event/8000",
+		"fmtp:101 0-16",
+	)
+	if err := answerWithCodec(answer, media.Codec{PayloadType: 9, Name: "G722", ClockRate: 8000}); err != nil {
+		t.Fatalf("answerWithCodec: %v", err)
+	}
+
+	reparsed, err := media.ParseSDP(answer.Marshal())
+	if err != nil {
+		t.Fatalf("ParseSDP: %v", err)
+	}
+	audio := reparsed.AudioMedia()
+	if !slices.Equal(audio.Formats, []int{9, 101}) {
+		t.Errorf("formats = %v, want [9 101]", audio.Formats)
+	}
+	if c := audio.CodecByPayloadType(9); c == nil || c.Name != "G722" {
+		t.Errorf("rtpmap for 9 = %v, want G722", c)
+	}
+	if audio.CodecByName("PCMA") != nil {
+		t.Error("answer still lists PCMA")
+	}
+}
+
+func TestOpusOnlyOffer(t *testing.T) {
+	opusOnly := testAudioSDP(t, "m=audio 5004 RTP/AVP 111 101",
+		"rtpmap:111 opus/48000/2",
+		"rtpmap:101 telephone-event/8000",
+	).Marshal()
+	withPCMU := testAudioSDP(t, "m=audio 5004 RTP/AVP 111 0",
+		"rtpmap:111 opus/48000/2",
+	).Marshal()
+
+	// Builds with Opus can take any Opus offer.
+	wantRefused := codecs.Lookup("opus") == nil
+	if got := opusOnlyOffer(opusOnly); got != wantRefused {
+		t.Errorf("opusOnlyOffer(opus only) = %v, want %v", got, wantRefused)
+	}
+	if opusOnlyOffer(withPCMU) {
+		t.Error("refused an offer with PCMU alongside Opus")
+	}
+	if opusOnlyOffer(nil) {
+		t.Error("refused an offerless INVITE")
+	}
+}
@@ -0,0 +1,819 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+	"github.com/google/uuid"
+)
+
+// transferRingTimeout is how long a transfer target rings before the
+// transfer is reported as failed and the original call is kept.
+const transferRingTimeout = 30 * time.Second
+
+// transferNotifyTimeout bounds the wait for the transferor's response to
+// a NOTIFY carrying transfer progress.
+const transferNotifyTimeout = 5 * time.Second
+
+// referTarget is the parsed Refer-To header of a REFER request.
+type referTarget struct {
+	// user is the user part of the Refer-To URI: an extension or number.
+	user string
+
+	// replaces is set for attended transfers, identifying the transferor's
+	// consultation call that the target should replace.
+	replaces *replacesParams
+}
+
+// replacesParams identifies a dialog as seen by the transferor (RFC 3891).
+type replacesParams struct {
+	callID  string
+	toTag   string
+	fromTag string
+}
+
+// parseReferTo parses a Refer-To header value such as
+// "<sip:103@pbx;user=phone?Replaces=abc%40host%3Bto-tag%3D1%3Bfrom-tag%3D2>".
+func parseReferTo(value string) (*referTarget, error) {
+	v := strings.TrimSpace(value)
+	if i := strings.IndexByte(v, '<'); i >= 0 {
+		j := strings.IndexByte(v[i:], '>')
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated refer-to uri %q", value)
+		}
+		v = v[i+1 : i+j]
+	}
+
+	uriPart, headers, _ := strings.Cut(v, "?")
+
+	var uri sip.Uri
+	if err := sip.ParseUri(uriPart, &uri); err != nil {
+		return nil, fmt.Errorf("parsing refer-to uri %q: %w", uriPart, err)
+	}
+	if uri.User == "" {
+		return nil, fmt.Errorf("refer-to uri %q has no user part", uriPart)
+	}
+
+	target := &referTarget{user: uri.User}
+	for _, hdr := range strings.Split(headers, "&") {
+		name, val, ok := strings.Cut(hdr, "=")
+		if !ok || !strings.EqualFold(name, "Replaces") {
+			continue
+		}
+		unescaped, err := url.PathUnescape(val)
+		if err != nil {
+			return nil, fmt.Errorf("unescaping replaces %q: %w", val, err)
+		}
+		replaces, err := parseReplaces(unescaped)
+		if err != nil {
+			return nil, err
+		}
+		target.replaces = replaces
+	}
+
+	return target, nil
+}
+
+// parseReplaces parses a Replaces value: "call-id;to-tag=x;from-tag=y".
+func parseReplaces(value string) (*replacesParams, error) {
+	parts := strings.Split(value, ";")
+	r := &replacesParams{callID: strings.TrimSpace(parts[0])}
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch strings.ToLower(k) {
+		case "to-tag":
+			r.toTag = v
+		case "from-tag":
+			r.fromTag = v
+		}
+	}
+	if r.callID == "" || r.toTag == "" || r.fromTag == "" {
+		return nil, fmt.Errorf("incomplete replaces %q", value)
+	}
+	return r, nil
+}
+
+// replacesLeg returns the side of d whose leg the Replaces parameters
+// identify, as seen by the PBX: the leg's Call-ID, the remote party's tag
+// as from-tag and the PBX's own tag as to-tag (RFC 3891 section 3). ok is
+// false if neither leg matches exactly.
+func replacesLeg(d *Dialog, r *replacesParams) (callerSide, ok bool) {
+	for _, side := range []bool{true, false} {
+		l := d.legSignalling(side)
+		legCallID := l.callID
+		if legCallID == "" {
+			legCallID = d.CallID
+		}
+		localTag := l.localTag
+		if !l.uas && l.req != nil {
+			localTag, _ = l.req.From().Params.Get("tag")
+		}
+		if legCallID == r.callID && l.remoteTag == r.fromTag && localTag == r.toTag {
+			return side, true
+		}
+	}
+	return false, false
+}
+
+// referSipfrag builds the message/sipfrag body of a transfer NOTIFY.
+func referSipfrag(code int, reason string) []byte {
+	return []byte(fmt.Sprintf("SIP/2.0 %d %s\r\n", code, reason))
+}
+
+// transferStatusError is a final SIP response from a transfer target.
+type transferStatusError struct {
+	code   int
+	reason string
+}
+
+func (e *transferStatusError) Error() string {
+	return fmt.Sprintf("transfer target responded %d %s", e.code, e.reason)
+}
+
+// errTransferNoMedia is returned when a call has no media session to
+// re-point, e.g. a call set up without SDP.
+var errTransferNoMedia = errors.New("call has no media session")
+
+// errTransferCallEnded is returned when the call hung up while the
+// transfer target was being connected.
+var errTransferCallEnded = errors.New("call ended during transfer")
+
+// transferFailureStatus maps a transfer error to the SIP status reported
+// to the transferor in the final NOTIFY.
+func transferFailureStatus(err error) (int, string) {
+	var statusErr *transferStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.code, statusErr.reason
+	case errors.Is(err, ErrExtensionNotFound):
+		return 404, "Not Found"
+	case errors.Is(err, ErrDND), errors.Is(err, ErrAllBusy):
+		return 486, "Busy Here"
+	case errors.Is(err, ErrNoRegistrations):
+		return 480, "Temporarily Unavailable"
+	case errors.Is(err, ErrNoTrunksAvailable):
+		return 503, "Service Unavailable"
+	case errors.Is(err, ErrCallNotPermitted):
+		return 403, "Forbidden"
+	case errors.Is(err, ErrNoOutboundRoute):
+		return 404, "Not Found"
+	case errors.Is(err, context.DeadlineExceeded):
+		return 408, "Request Timeout"
+	case errors.Is(err, errTransferNoMedia):
+		return 488, "Not Acceptable Here"
+	case errors.Is(err, errTransferCallEnded):
+		return 487, "Request Terminated"
+	case errors.Is(err, errNoParkOrbit):
+		return 486, "Busy Here"
+	default:
+		return 500, "Server Internal Error"
+	}
+}
+
+// handleREFER processes an in-dialog REFER from an extension's phone
+// (RFC 3515). A blind transfer re-routes the transferee to the Refer-To
+// target; an attended transfer (Refer-To with Replaces) joins the
+// transferee to the party on the transferor's consultation call. A blind
+// transfer to a park code or orbit parks the transferee instead. Progress
+// is reported to the transferor with NOTIFY sipfrag bodies.
+func (s *Server) handleREFER(req *sip.Request, tx sip.ServerTransaction) {
+	callID, fromTag := requestDialogID(req)
+
+	s.logger.Info("sip refer received",
+		"call_id", callID,
+		"from", req.From().Address.User,
+		"source", req.Source(),
+	)
+
+	respond := func(code int, reason string) {
+		res := sip.NewResponseFromRequest(req, code, reason, nil)
+		if err := tx.Respond(res); err != nil {
+			s.logger.Error("failed to respond to refer", "error", err)
+		}
+	}
+
+	d := s.dialogMgr.FindDialog(callID, fromTag)
+	if d == nil || s.dialogMgr.IsRetiredLeg(callID, fromTag) {
+		s.logger.Warn("refer for unknown dialog",
+			"call_id", callID,
+		)
+		respond(481, "Call/Transaction Does Not Exist")
+		return
+	}
+
+	h := req.GetHeader("Refer-To")
+	if h == nil {
+		respond(400, "Missing Refer-To")
+		return
+	}
+	target, err := parseReferTo(h.Value())
+	if err != nil {
+		s.logger.Warn("invalid refer-to header",
+			"call_id", callID,
+			"refer_to", h.Value(),
+			"error", err,
+		)
+		respond(400, "Bad Refer-To")
+		return
+	}
+
+	// Only a local extension may transfer its call.
+	fromCaller := d.isCallerLeg(callID, fromTag)
+	transferor := d.Callee.Extension
+	if fromCaller {
+		transferor = d.Caller.Extension
+	}
+	if transferor == nil {
+		s.logger.Warn("refer from non-extension leg rejected",
+			"call_id", callID,
+		)
+		respond(403, "Forbidden")
+		return
+	}
+	if d.Peer != nil || d.Media == nil || d.parked.Load() != nil {
+		respond(488, "Not Acceptable Here")
+		return
+	}
+
+	// A blind transfer to a park code or orbit parks the transferee.
+	var parkLot *models.ParkLot
+	var parkOrbit string
+	if target.replaces == nil && s.parkMgr != nil {
+		parkLot, parkOrbit, err = s.parkMgr.Lookup(context.Background(), target.user)
+		if err != nil {
+			s.logger.Error("failed to look up park lot",
+				"call_id", callID,
+				"target", target.user,
+				"error", err,
+			)
+			respond(500, "Server Internal Error")
+			return
+		}
+	}
+
+	// For an attended transfer, find the consultation call and which of
+	// its legs belongs to the target (the one that is not the transferor).
+	// Call-IDs are visible to BLF subscribers, so the Replaces tags must
+	// name the transferor's own leg of the consultation call.
+	var consult *Dialog
+	consultCallerRemains := false
+	if r := target.replaces; r != nil {
+		consult = s.dialogMgr.FindDialog(r.callID, r.fromTag)
+		var transferorSide, ok bool
+		if consult != nil && consult != d {
+			transferorSide, ok = replacesLeg(consult, r)
+		}
+		if !ok || consult.Peer != nil || consult.Media == nil {
+			s.logger.Warn("attended transfer references unknown call",
+				"call_id", callID,
+				"replaces_call_id", r.callID,
+			)
+			respond(481, "Call/Transaction Does Not Exist")
+			return
+		}
+		owner := consult.Callee.Extension
+		if transferorSide {
+			owner = consult.Caller.Extension
+		}
+		if owner == nil || owner.ID != transferor.ID {
+			s.logger.Warn("attended transfer of another extension's call rejected",
+				"call_id", callID,
+				"replaces_call_id", r.callID,
+				"transferor", transferor.Extension,
+			)
+			respond(403, "Forbidden")
+			return
+		}
+		consultCallerRemains = !transferorSide
+	}
+
+	if !d.transferring.CompareAndSwap(false, true) {
+		respond(491, "Request Pending")
+		return
+	}
+
+	respond(202, "Accepted")
+
+	s.logger.Info("call transfer accepted",
+		"call_id", callID,
+		"transferor", transferor.Extension,
+		"target", target.user,
+		"attended", consult != nil,
+		"park", parkLot != nil,
+	)
+
+	// Snapshot the transferor's leg: NOTIFY and the final BYE are sent on
+	// it after it has been removed from the dialog.
+	transferorLeg := d.leg(fromCaller)
+
+	go func() {
+		defer d.transferring.Store(false)
+
+		s.flowActions.sendReferNotify(transferorLeg, 100, "Trying")
+
+		var err error
+		switch {
+		case consult != nil:
+			err = s.flowActions.attendedTransfer(d, !fromCaller, consult, consultCallerRemains, target.user)
+		case parkLot != nil:
+			_, err = s.parkMgr.park(d, !fromCaller, transferor, parkLot, parkOrbit)
+		default:
+			err = s.flowActions.referBlindTransfer(d, fromCaller, target.user)
+		}
+		if err != nil {
+			code, reason := transferFailureStatus(err)
+			s.logger.Warn("call transfer failed",
+				"call_id", callID,
+				"target", target.user,
+				"status", code,
+				"error", err,
+			)
+			s.flowActions.sendReferNotify(transferorLeg, code, reason)
+			return
+		}
+
+		s.flowActions.sendReferNotify(transferorLeg, 200, "OK")
+		s.flowActions.sendLegBYE(transferorLeg, callID)
+	}()
+}
+
+// transferTarget is a transfer destination resolved to either a local
+// extension with active contacts or an external number with trunks.
+type transferTarget struct {
+	destination string
+	extension   *models.Extension
+	contacts    []models.Registration
+
+	// trunks and number are the trunks an external destination is tried
+	// on and the number sent to them, as routed by the dial plan.
+	trunks []models.Trunk
+	number string
+
+	// flowID and flowNode are set for a call flow entered at a node,
+	// which is dialled as a local flow call.
+	flowID   int64
+	flowNode string
+}
+
+// resolveTransferTarget routes a transfer destination (an extension,
+// number, or SIP URI) through the CallRouter for local extensions or the
+// OutboundRouter for external numbers. caller, when set, is the extension
+// the call is placed for; its class of service must permit an external
+// destination.
+func (a *FlowSIPActions) resolveTransferTarget(ctx context.Context, destination string, caller *models.Extension) (*transferTarget, error) {
+	user := destination
+	if strings.Contains(destination, ":") {
+		var uri sip.Uri
+		if err := sip.ParseUri(strings.Trim(destination, "<>"), &uri); err != nil {
+			return nil, fmt.Errorf("parsing transfer destination %q: %w", destination, err)
+		}
+		user = uri.User
+	}
+	if user == "" {
+		return nil, fmt.Errorf("transfer destination %q has no user part", destination)
+	}
+
+	ext, err := a.extensions.GetByExtension(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("looking up extension %s: %w", user, err)
+	}
+	if ext != nil {
+		route, err := a.router.RouteInternalCall(ctx, &InviteContext{
+			CallType:        CallTypeInternal,
+			TargetExtension: ext,
+			RequestURI:      user,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &transferTarget{
+			destination: user,
+			extension:   ext,
+			contacts:    route.Contacts,
+		}, nil
+	}
+
+	plan, err := a.outboundRouter.Route(ctx, user, caller)
+	if err != nil {
+		return nil, err
+	}
+	return &transferTarget{
+		destination: user,
+		trunks:      plan.Trunks,
+		number:      plan.Number,
+	}, nil
+}
+
+// referBlindTransfer replaces the transferor's leg of d with the REFER
+// target, keeping the transferee connected to the same media session.
+func (a *FlowSIPActions) referBlindTransfer(d *Dialog, transferorIsCaller bool, destination string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), transferRingTimeout)
+	defer cancel()
+
+	transferor := d.Callee.Extension
+	if transferorIsCaller {
+		transferor = d.Caller.Extension
+	}
+	target, err := a.resolveTransferTarget(ctx, destination, transferor)
+	if err != nil {
+		return err
+	}
+	return a.transferLeg(ctx, d, transferorIsCaller, target)
+}
+
+// transferAnswer is the answered INVITE leg to a transfer target.
+type transferAnswer struct {
+	req     *sip.Request
+	res     *sip.Response
+	contact *models.Registration
+}
+
+// leg returns the signalling state of the answered INVITE, for sending
+// requests within its dialog.
+func (t *transferAnswer) leg() dialogLeg {
+	leg := dialogLeg{req: t.req, res: t.res, seq: new(atomic.Uint32)}
+	if contact := t.res.Contact(); contact != nil {
+		leg.target = contact.Address.Clone()
+	}
+	return leg
+}
+
+// callLeg describes the answered device as a dialog leg. ext is the
+// extension it belongs to, nil for a trunk.
+func (t *transferAnswer) callLeg(ext *models.Extension, remoteTarget *sip.Uri) CallLeg {
+	leg := CallLeg{
+		Extension:    ext,
+		Registration: t.contact,
+		RemoteTarget: remoteTarget,
+	}
+	if t.contact != nil {
+		leg.ContactURI = t.contact.ContactURI
+	}
+	leg.CallID, _ = requestDialogID(t.req)
+	if from := t.req.From(); from != nil {
+		leg.FromTag, _ = from.Params.Get("tag")
+	}
+	if to := t.res.To(); to != nil {
+		leg.ToTag, _ = to.Params.Get("tag")
+	}
+	return leg
+}
+
+// transferLeg dials target on behalf of the party that stays on the call
+// and, once it answers, swaps it in for the other side of d: the caller
+// side when replaceCaller is true, otherwise the callee side. The media
+// relay is re-pointed at the target and the dialog and CDR are updated.
+// The replaced leg is retired but not sent a BYE.
+func (a *FlowSIPActions) transferLeg(ctx context.Context, d *Dialog, replaceCaller bool, target *transferTarget) error {
+	if err := a.connectLeg(ctx, d, replaceCaller, target); err != nil {
+		return err
+	}
+
+	d.TransferredTo = target.destination
+	a.recordTransfer(d.CallID, target.destination)
+
+	a.logger.Info("call transferred",
+		"call_id", d.CallID,
+		"target", target.destination,
+		"caller_side", replaceCaller,
+	)
+	return nil
+}
+
+// connectLeg dials target on behalf of the party that stays on the call
+// and, once it answers, swaps it in for the other side of d, re-pointing
+// the media relay at it. A call with no callee leg yet has its relay
+// started instead.
+func (a *FlowSIPActions) connectLeg(ctx context.Context, d *Dialog, replaceCaller bool, target *transferTarget) error {
+	if d.Media == nil {
+		return errTransferNoMedia
+	}
+
+	// Offer the target the remaining party's media description, pointed
+	// at the proxy socket the replaced leg was using.
+	offer := d.leg(!replaceCaller).remoteSDP()
+	if len(offer) == 0 {
+		return errTransferNoMedia
+	}
+	port := d.Media.CalleeRTPPort()
+	if replaceCaller {
+		port = d.Media.CallerRTPPort()
+	}
+	offerSD, err := media.ParseSDP(offer)
+	if err != nil {
+		return fmt.Errorf("rewriting sdp for transfer target: %w", err)
+	}
+	targetSD := media.RewriteSDP(offerSD, a.proxyIP, port)
+	var targetKeys []media.CryptoAttribute
+	if audio := targetSD.AudioMedia(); audio != nil {
+		// The target gets keys of its own, not the remaining party's.
+		if targetKeys, err = offerSRTP(audio, srtpModeOptional); err != nil {
+			return fmt.Errorf("rewriting sdp for transfer target: %w", err)
+		}
+	}
+	sdp := targetSD.Marshal()
+
+	cidName, cidNum := d.CallerIDName, d.CallerIDNum
+	if replaceCaller {
+		cidName, cidNum = "", d.CalledNum
+		if ext := d.Callee.Extension; ext != nil {
+			cidName, cidNum = ext.Name, ext.Extension
+		}
+	}
+
+	answer, err := a.dialTransferTarget(ctx, d.callerInvite(), d.CallID, target, sdp, cidName, cidNum)
+	if err != nil {
+		return err
+	}
+
+	newLeg := answer.leg()
+
+	answerSD, err := media.ParseSDP(answer.res.Body())
+	var remote *net.UDPAddr
+	if err == nil {
+		remote, err = extractRTPAddr(answerSD)
+	}
+	var targetSRTP *media.LegSRTP
+	if err == nil {
+		targetSRTP, err = acceptSRTPAnswer(answerSD.AudioMedia(), targetKeys, srtpModeOptional)
+	}
+	if err != nil {
+		a.sendLegBYE(newLeg, d.CallID)
+		return fmt.Errorf("reading transfer target sdp: %w", err)
+	}
+	if err := d.Media.SetLegSRTP(replaceCaller, targetSRTP); err != nil {
+		a.sendLegBYE(newLeg, d.CallID)
+		return fmt.Errorf("setting transfer target srtp: %w", err)
+	}
+
+	switch {
+	case replaceCaller:
+		err = d.Media.SetCallerRemote(remote)
+	case !d.hasCallee():
+		err = a.startTransferRelay(d, answerSD, remote)
+	default:
+		err = d.Media.SetCalleeRemote(remote)
+	}
+	if err != nil {
+		a.sendLegBYE(newLeg, d.CallID)
+		return fmt.Errorf("re-pointing media to transfer target: %w", err)
+	}
+
+	leg := answer.callLeg(target.extension, newLeg.target)
+	if !a.dialogMgr.ReplaceLeg(d, replaceCaller, leg, answer.req, answer.res) {
+		a.sendLegBYE(newLeg, d.CallID)
+		return errTransferCallEnded
+	}
+	return nil
+}
+
+// startTransferRelay connects the caller of a call the PBX answered itself
+// (which has no relay yet) to a transfer target that answered with
+// calleeSD. The caller's stream is detached first so the relay can take
+// over the caller leg socket.
+func (a *FlowSIPActions) startTransferRelay(d *Dialog, calleeSD *media.SessionDescription, calleeRemote *net.UDPAddr) error {
+	callerSD, err := media.ParseSDP(d.leg(true).remoteSDP())
+	if err != nil {
+		return fmt.Errorf("parsing caller sdp: %w", err)
+	}
+	callerRemote, err := extractRTPAddr(callerSD)
+	if err != nil {
+		return fmt.Errorf("extracting caller rtp address: %w", err)
+	}
+	codecPT, _, err := negotiateAudioCodec(callerSD, calleeSD)
+	if err != nil {
+		return fmt.Errorf("codec negotiation failed: %w", err)
+	}
+
+	a.detachEarlyMedia(d.CallID)
+	return d.Media.StartRelay(callerRemote, calleeRemote, []int{codecPT, media.PayloadTelephoneEvent})
+}
+
+// dialTransferTarget sends an INVITE with the given SDP offer to a transfer
+// target and waits for it to answer. Extensions are forked to all their
+// contacts; external numbers are tried on each trunk in priority order;
+// call flows are entered through a local flow call.
+// Ringing is not relayed anywhere: the transferee stays connected to the
+// original call until the target answers. origin is the INVITE of the
+// call the target is dialled for, identified by callID.
+func (a *FlowSIPActions) dialTransferTarget(ctx context.Context, origin *sip.Request, callID string, target *transferTarget, sdp []byte, cidName, cidNum string) (*transferAnswer, error) {
+	if target.flowID != 0 {
+		return a.dialLocalFlow(ctx, origin, callID, target, sdp, cidName, cidNum)
+	}
+	if target.extension != nil {
+		caller := &models.Extension{Name: cidName, Extension: cidNum}
+		result := a.forker.Fork(ctx, origin, nil, target.contacts, caller, callID, sdp)
+		if result.Error != nil {
+			return nil, fmt.Errorf("ringing extension %s: %w", target.destination, result.Error)
+		}
+		if !result.Answered {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if result.AllBusy {
+				return nil, &transferStatusError{code: 486, reason: "Busy Here"}
+			}
+			return nil, &transferStatusError{code: 480, reason: "Temporarily Unavailable"}
+		}
+
+		ackReq := buildACKFor2xx(result.AnsweringLeg.req, result.AnswerResponse)
+		if err := a.forker.Client().WriteRequest(ackReq); err != nil {
+			result.AnsweringTx.Terminate()
+			return nil, fmt.Errorf("sending ack to transfer target: %w", err)
+		}
+		return &transferAnswer{
+			req:     result.AnsweringLeg.req,
+			res:     result.AnswerResponse,
+			contact: result.AnsweringContact,
+		}, nil
+	}
+
+	// External number: a fresh Call-ID keeps the new trunk leg distinct
+	// from any trunk leg already on the call.
+	legCallID := uuid.New().String()
+	var last *outboundResult
+	for i := range target.trunks {
+		trunk := &target.trunks[i]
+
+		if trunk.MaxChannels > 0 && a.dialogMgr.ActiveCallCountForTrunk(trunk.ID) >= trunk.MaxChannels {
+			continue
+		}
+
+		last = a.sendFollowMeInvite(ctx, origin, nil, trunk, target.number, legCallID, sdp, cidName, cidNum)
+		if ctx.Err() != nil {
+			break
+		}
+		if last.answered {
+			ackReq := buildACKFor2xx(last.req, last.res)
+			if err := a.forker.Client().WriteRequest(ackReq); err != nil {
+				last.tx.Terminate()
+				return nil, fmt.Errorf("sending ack to trunk: %w", err)
+			}
+			return &transferAnswer{req: last.req, res: last.res}, nil
+		}
+		if last.err == nil && isCalleeFailure(last.statusCode) {
+			break
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case last == nil:
+		return nil, ErrNoTrunksAvailable
+	case last.err != nil:
+		return nil, last.err
+	default:
+		return nil, &transferStatusError{code: last.statusCode, reason: last.reason}
+	}
+}
+
+// attendedTransfer joins the transferee on d to the target on the
+// transferor's consultation call. Both calls keep their media sessions;
+// the sockets that faced the transferor are pointed at each other so RTP
+// flows transferee → d's relay → consult's relay → target. The
+// transferor's leg on the consultation call gets a BYE.
+func (a *FlowSIPActions) attendedTransfer(d *Dialog, dCallerRemains bool, consult *Dialog, consultCallerRemains bool, destination string) error {
+	if d.Media == nil || consult.Media == nil {
+		return errTransferNoMedia
+	}
+
+	consultTransferor := consult.leg(!consultCallerRemains)
+
+	dPort := d.Media.CallerRTPPort()
+	if dCallerRemains {
+		dPort = d.Media.CalleeRTPPort()
+	}
+	consultPort := consult.Media.CallerRTPPort()
+	if consultCallerRemains {
+		consultPort = consult.Media.CalleeRTPPort()
+	}
+
+	if err := setLegRemote(d.Media, !dCallerRemains, loopbackRTPAddr(consultPort)); err != nil {
+		return fmt.Errorf("joining media: %w", err)
+	}
+	if err := setLegRemote(consult.Media, !consultCallerRemains, loopbackRTPAddr(dPort)); err != nil {
+		return fmt.Errorf("joining media: %w", err)
+	}
+
+	if !a.dialogMgr.JoinDialogs(d, dCallerRemains, consult, consultCallerRemains) {
+		return errTransferCallEnded
+	}
+
+	d.TransferredTo = destination
+	a.recordTransfer(d.CallID, destination)
+	a.sendLegBYE(consultTransferor, consult.CallID)
+
+	a.logger.Info("attended transfer completed",
+		"call_id", d.CallID,
+		"consult_call_id", consult.CallID,
+		"target", destination,
+	)
+	return nil
+}
+
+// setLegRemote re-points one side of a media session's relay.
+func setLegRemote(ms *media.MediaSession, callerSide bool, addr *net.UDPAddr) error {
+	if callerSide {
+		return ms.SetCallerRemote(addr)
+	}
+	return ms.SetCalleeRemote(addr)
+}
+
+// loopbackRTPAddr is the address of a local proxy RTP socket. Proxy
+// sockets listen on all interfaces, so joined relays exchange RTP over
+// loopback.
+func loopbackRTPAddr(port int) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+}
+
+// sendReferNotify reports transfer progress to the transferor in a NOTIFY
+// with a message/sipfrag body. A final status terminates the implicit
+// subscription created by the REFER.
+func (a *FlowSIPActions) sendReferNotify(leg dialogLeg, code int, reason string) {
+	req := leg.newRequest(sip.NOTIFY)
+	req.AppendHeader(sip.NewHeader("Event", "refer"))
+	state := "active;expires=60"
+	if code >= 200 {
+		state = "terminated;reason=noresource"
+	}
+	req.AppendHeader(sip.NewHeader("Subscription-State", state))
+	req.AppendHeader(sip.NewHeader("Content-Type", "message/sipfrag;version=2.0"))
+	req.SetBody(referSipfrag(code, reason))
+
+	ctx, cancel := context.WithTimeout(context.Background(), transferNotifyTimeout)
+	defer cancel()
+
+	callID, _ := requestDialogID(req)
+	tx, err := a.forker.Client().TransactionRequest(ctx, req, sipgo.ClientRequestBuild)
+	if err != nil {
+		a.logger.Error("failed to send refer notify",
+			"call_id", callID,
+			"error", err,
+		)
+		return
+	}
+	defer tx.Terminate()
+
+	select {
+	case res := <-tx.Responses():
+		if res.StatusCode >= 300 {
+			a.logger.Warn("refer notify rejected",
+				"call_id", callID,
+				"status", res.StatusCode,
+			)
+		}
+	case <-tx.Done():
+	case <-ctx.Done():
+		a.logger.Warn("refer notify timed out",
+			"call_id", callID,
+		)
+	}
+}
+
+// sendLegBYE sends a BYE on a single leg, e.g. to a transferor once its
+// call has been handed over.
+func (a *FlowSIPActions) sendLegBYE(leg dialogLeg, callID string) {
+	if err := a.forker.Client().WriteRequest(leg.newRequest(sip.BYE)); err != nil {
+		a.logger.Error("failed to send bye to transferred leg",
+			"call_id", callID,
+			"error", err,
+		)
+	}
+}
+
+// recordTransfer stores the transfer destination on the call's CDR.
+func (a *FlowSIPActions) recordTransfer(callID, destination string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cdr, err := a.cdrs.GetByCallID(ctx, callID)
+	if err != nil {
+		a.logger.Error("failed to fetch cdr for transfer",
+			"call_id", callID,
+			"error", err,
+		)
+		return
+	}
+	if cdr == nil {
+		return
+	}
+
+	cdr.TransferredTo = destination
+	if err := a.cdrs.Update(ctx, cdr); err != nil {
+		a.logger.Error("failed to update cdr for transfer",
+			"call_id", callID,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,300 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/database/models"
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+func TestParseReferTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantUser string
+		wantRepl *replacesParams
+		wantErr  bool
+	}{
+		{
+			name:     "blind transfer",
+			value:    "<sip:103@pbx.example.com>",
+			wantUser: "103",
+		},
+		{
+			name:     "display name and uri params",
+			value:    `"Reception" <sip:0412345678@pbx.example.com;user=phone>`,
+			wantUser: "0412345678",
+		},
+		{
+			name:     "bare uri",
+			value:    "sip:104@10.0.0.1",
+			wantUser: "104",
+		},
+		{
+			name:     "attended transfer with escaped replaces",
+			value:    "<sip:105@pbx.example.com?Replaces=abc123%4010.0.0.5%3Bto-tag%3Dt1%3Bfrom-tag%3Df1>",
+			wantUser: "105",
+			wantRepl: &replacesParams{callID: "abc123@10.0.0.5", toTag: "t1", fromTag: "f1"},
+		},
+		{
+			name:    "no user part",
+			value:   "<sip:pbx.example.com>",
+			wantErr: true,
+		},
+		{
+			name:    "incomplete replaces",
+			value:   "<sip:105@pbx.example.com?Replaces=abc123%3Bto-tag%3Dt1>",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated uri",
+			value:   "<sip:105@pbx.example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReferTo(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.user != tt.wantUser {
+				t.Errorf("user = %q, want %q", got.user, tt.wantUser)
+			}
+			switch {
+			case tt.wantRepl == nil && got.replaces != nil:
+				t.Errorf("unexpected replaces %+v", got.replaces)
+			case tt.wantRepl != nil && (got.replaces == nil || *got.replaces != *tt.wantRepl):
+				t.Errorf("replaces = %+v, want %+v", got.replaces, tt.wantRepl)
+			}
+		})
+	}
+}
+
+func TestReferSipfrag(t *testing.T) {
+	if got := string(referSipfrag(200, "OK")); got != "SIP/2.0 200 OK\r\n" {
+		t.Errorf("sipfrag = %q", got)
+	}
+}
+
+func TestTransferFailureStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{&transferStatusError{code: 486, reason: "Busy Here"}, 486},
+		{fmt.Errorf("ringing: %w", &transferStatusError{code: 603, reason: "Decline"}), 603},
+		{ErrExtensionNotFound, 404},
+		{ErrDND, 486},
+		{ErrNoRegistrations, 480},
+		{ErrNoTrunksAvailable, 503},
+		{context.DeadlineExceeded, 408},
+		{errTransferCallEnded, 487},
+		{fmt.Errorf("boom"), 500},
+	}
+
+	for _, tt := range tests {
+		if got, _ := transferFailureStatus(tt.err); got != tt.want {
+			t.Errorf("transferFailureStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+// newTestDialogRequest builds an INVITE with the dialog headers used by
+// the dialog manager.
+func newTestDialogRequest(callID, fromUser, fromTag, toUser string) *sip.Request {
+	req := sip.NewRequest(sip.INVITE, sip.Uri{User: toUser, Host: "10.0.0.1"})
+	from := &sip.FromHeader{Address: sip.Uri{User: fromUser, Host: "10.0.0.2"}, Params: sip.NewParams()}
+	from.Params.Add("tag", fromTag)
+	req.AppendHeader(from)
+	req.AppendHeader(&sip.ToHeader{Address: sip.Uri{User: toUser, Host: "10.0.0.1"}, Params: sip.NewParams()})
+	cid := sip.CallIDHeader(callID)
+	req.AppendHeader(&cid)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: sip.INVITE})
+	req.AppendHeader(&sip.ContactHeader{Address: sip.Uri{User: fromUser, Host: "10.0.0.2"}})
+	return req
+}
+
+// newTestAnswer builds a 200 OK to req with the given To tag.
+func newTestAnswer(req *sip.Request, toTag string) *sip.Response {
+	res := sip.NewResponseFromRequest(req, 200, "OK", nil)
+	res.To().Params.Add("tag", toTag)
+	return res
+}
+
+func newTestDialog(dm *DialogManager) *Dialog {
+	callerReq := newTestDialogRequest("caller-call", "101", "caller-tag", "102")
+	calleeReq := newTestDialogRequest("callee-call", "101", "pbx-tag", "102")
+	d := &Dialog{
+		CallID:    "caller-call",
+		Caller:    CallLeg{FromTag: "caller-tag", ToTag: "pbx-local"},
+		Callee:    CallLeg{FromTag: "pbx-tag", ToTag: "callee-tag"},
+		CallerReq: callerReq,
+		CalleeReq: calleeReq,
+		CalleeRes: newTestAnswer(calleeReq, "callee-tag"),
+	}
+	dm.CreateDialog(d)
+	return d
+}
+
+func TestDialogManagerFindAndReplaceLeg(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+	d := newTestDialog(dm)
+
+	if got := dm.FindDialog("callee-call", ""); got != d {
+		t.Fatal("expected dialog to be found by callee leg call-id")
+	}
+	if !d.isCallerLeg("caller-call", "caller-tag") {
+		t.Error("expected caller call-id to identify the caller leg")
+	}
+	if d.isCallerLeg("callee-call", "callee-tag") {
+		t.Error("expected callee call-id to identify the callee leg")
+	}
+
+	newReq := newTestDialogRequest("target-call", "101", "pbx-tag-2", "103")
+	newRes := newTestAnswer(newReq, "target-tag")
+	leg := CallLeg{CallID: "target-call", FromTag: "pbx-tag-2", ToTag: "target-tag"}
+	if !dm.ReplaceLeg(d, false, leg, newReq, newRes) {
+		t.Fatal("expected ReplaceLeg to succeed on active dialog")
+	}
+
+	if dm.FindDialog("callee-call", "") != nil {
+		t.Error("expected old callee leg to be unindexed")
+	}
+	if !dm.IsRetiredLeg("callee-call", "callee-tag") {
+		t.Error("expected old callee leg to be retired")
+	}
+	if got := dm.FindDialog("target-call", ""); got != d {
+		t.Error("expected dialog to be found by new callee leg call-id")
+	}
+	if d.CalleeReq != newReq || d.Callee.CallID != "target-call" {
+		t.Error("expected callee leg to be replaced")
+	}
+
+	dm.TerminateDialog(d.CallID, "test")
+	if dm.ReplaceLeg(d, false, leg, newReq, newRes) {
+		t.Error("expected ReplaceLeg to fail on terminated dialog")
+	}
+	if dm.FindDialog("target-call", "") != nil {
+		t.Error("expected leg index to be cleared on terminate")
+	}
+}
+
+func TestDialogLegNewRequest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+	d := newTestDialog(dm)
+
+	// Callee leg: the PBX is the UAC, so requests follow the INVITE's
+	// direction with increasing CSeq numbers.
+	callee := d.leg(false)
+	first := callee.newRequest(sip.NOTIFY)
+	second := callee.newRequest(sip.BYE)
+	if first.CSeq().SeqNo != 2 || second.CSeq().SeqNo != 3 {
+		t.Errorf("callee cseq = %d, %d; want 2, 3", first.CSeq().SeqNo, second.CSeq().SeqNo)
+	}
+	if got := first.CallID().Value(); got != "callee-call" {
+		t.Errorf("callee request call-id = %q", got)
+	}
+	if tag, _ := first.To().Params.Get("tag"); tag != "callee-tag" {
+		t.Errorf("callee request to-tag = %q, want %q", tag, "callee-tag")
+	}
+
+	// Caller leg: the PBX is the UAS, so From/To are swapped and the From
+	// carries the tag the PBX answered with.
+	caller := d.leg(true)
+	req := caller.newRequest(sip.NOTIFY)
+	if tag, _ := req.From().Params.Get("tag"); tag != "pbx-local" {
+		t.Errorf("caller request from-tag = %q, want %q", tag, "pbx-local")
+	}
+	if tag, _ := req.To().Params.Get("tag"); tag != "caller-tag" {
+		t.Errorf("caller request to-tag = %q, want %q", tag, "caller-tag")
+	}
+	if req.Method != sip.NOTIFY {
+		t.Errorf("method = %s, want NOTIFY", req.Method)
+	}
+}
+
+// recordingTx is a server transaction that records the response status.
+type recordingTx struct {
+	sip.ServerTransaction
+	status int
+}
+
+func (tx *recordingTx) Respond(res *sip.Response) error {
+	tx.status = res.StatusCode
+	return nil
+}
+
+func TestReferReplacesForeignCall(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	dm := NewDialogManager(logger)
+	s := &Server{dialogMgr: dm, logger: logger}
+
+	// 101 is talking to 102 and sends the REFER.
+	d := newTestDialog(dm)
+	d.Caller.Extension = &models.Extension{ID: 1, Extension: "101"}
+	d.Callee.Extension = &models.Extension{ID: 2, Extension: "102"}
+	d.Media = &media.MediaSession{}
+
+	// 103 is talking to 104; 101 is not a party to the call but can learn
+	// its Call-ID from BLF.
+	otherReq := newTestDialogRequest("other-call", "103", "other-tag", "104")
+	otherCalleeReq := newTestDialogRequest("other-callee-call", "103", "other-pbx-tag", "104")
+	other := &Dialog{
+		CallID:    "other-call",
+		Caller:    CallLeg{Extension: &models.Extension{ID: 3, Extension: "103"}, FromTag: "other-tag", ToTag: "other-local"},
+		Callee:    CallLeg{Extension: &models.Extension{ID: 4, Extension: "104"}, CallID: "other-callee-call", FromTag: "other-pbx-tag", ToTag: "104-tag"},
+		CallerReq: otherReq,
+		CalleeReq: otherCalleeReq,
+		CalleeRes: newTestAnswer(otherCalleeReq, "104-tag"),
+		Media:     &media.MediaSession{},
+	}
+	dm.CreateDialog(other)
+
+	tests := []struct {
+		name     string
+		replaces string
+		want     int
+	}{
+		{"caller leg of another call", "other-call;to-tag=other-local;from-tag=other-tag", 403},
+		{"callee leg of another call", "other-callee-call;to-tag=other-pbx-tag;from-tag=104-tag", 403},
+		{"tags do not match", "other-call;to-tag=guess;from-tag=other-tag", 481},
+		{"tags swapped", "other-call;to-tag=other-tag;from-tag=other-local", 481},
+		{"own call", "caller-call;to-tag=pbx-local;from-tag=caller-tag", 481},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := sip.NewRequest(sip.REFER, sip.Uri{User: "102", Host: "10.0.0.1"})
+			from := &sip.FromHeader{Address: sip.Uri{User: "101", Host: "10.0.0.2"}, Params: sip.NewParams()}
+			from.Params.Add("tag", "caller-tag")
+			req.AppendHeader(from)
+			cid := sip.CallIDHeader("caller-call")
+			req.AppendHeader(&cid)
+			req.AppendHeader(sip.NewHeader("Refer-To", "<sip:104@10.0.0.1?Replaces="+url.QueryEscape(tt.replaces)+">"))
+
+			tx := &recordingTx{}
+			s.handleREFER(req, tx)
+			if tx.status != tt.want {
+				t.Errorf("status = %d, want %d", tx.status, tt.want)
+			}
+			if d.transferring.Load() {
+				t.Error("rejected transfer left the call marked as transferring")
+			}
+		})
+	}
+}
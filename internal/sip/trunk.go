@@ -57,8 +57,17 @@ type TrunkRegistrar struct {
 
 	mu     sync.RWMutex
 	states map[int64]*trunkEntry // keyed by trunk ID
+
+	// onStatus, if set, is called with a trunk's state when its status
+	// changes.
+	onStatus TrunkStatusListener
 }
 
+// TrunkStatusListener is called with a trunk's state when its status
+// changes. It is called with the registrar's lock held and must not call
+// back into the registrar.
+type TrunkStatusListener func(state TrunkState)
+
 const (
 	// healthCheckInterval is how often we send OPTIONS pings to trunks.
 	healthCheckInterval = 30 * time.Second
@@ -131,6 +140,20 @@ func (tr *TrunkRegistrar) MatchRegisterTrunk(sourceIP string) (int64, string, bo
 	return 0, "", false
 }
 
+// OnStatusChange registers a listener for trunk status changes. Must be
+// called before trunks are started.
+func (tr *TrunkRegistrar) OnStatusChange(fn TrunkStatusListener) {
+	tr.onStatus = fn
+}
+
+// notifyStatus reports a trunk's state to the listener if its status is no
+// longer prev. Must be called with tr.mu held.
+func (tr *TrunkRegistrar) notifyStatus(e *trunkEntry, prev TrunkStatus) {
+	if tr.onStatus != nil && e.state.Status != prev {
+		tr.onStatus(e.state)
+	}
+}
+
 // StartTrunk begins registration for a register-type trunk.
 // If the trunk is already running, it is stopped first.
 func (tr *TrunkRegistrar) StartTrunk(ctx context.Context, trunk models.Trunk) error {
@@ -301,12 +324,14 @@ func (tr *TrunkRegistrar) registrationLoop(ctx context.Context, entry *trunkEntr
 			now := time.Now()
 			tr.mu.Lock()
 			if e, ok := tr.states[trunk.ID]; ok {
+				prev := e.state.Status
 				e.state.Status = TrunkStatusFailed
 				e.state.LastError = err.Error()
 				e.state.RetryAttempt = backoff.attempt
 				if e.state.FailedAt == nil {
 					e.state.FailedAt = &now
 				}
+				tr.notifyStatus(e, prev)
 			}
 			tr.mu.Unlock()
 
@@ -324,12 +349,14 @@ func (tr *TrunkRegistrar) registrationLoop(ctx context.Context, entry *trunkEntr
 		expiresAt := now.Add(time.Duration(grantedExpiry) * time.Second)
 		tr.mu.Lock()
 		if e, ok := tr.states[trunk.ID]; ok {
+			prev := e.state.Status
 			e.state.Status = TrunkStatusRegistered
 			e.state.LastError = ""
 			e.state.RetryAttempt = 0
 			e.state.FailedAt = nil
 			e.state.RegisteredAt = &now
 			e.state.ExpiresAt = &expiresAt
+			tr.notifyStatus(e, prev)
 		}
 		tr.mu.Unlock()
 
@@ -561,6 +588,7 @@ func (tr *TrunkRegistrar) healthCheckLoop(ctx context.Context, entry *trunkEntry
 		tr.mu.Lock()
 		if e, ok := tr.states[trunk.ID]; ok {
 			now := time.Now()
+			prev := e.state.Status
 			if err == nil {
 				e.state.OptionsHealthy = true
 				e.state.LastOptionsAt = &now
@@ -588,6 +616,7 @@ func (tr *TrunkRegistrar) healthCheckLoop(ctx context.Context, entry *trunkEntry
 					"error", err,
 				)
 			}
+			tr.notifyStatus(e, prev)
 		}
 		tr.mu.Unlock()
 
@@ -710,11 +739,13 @@ func (tr *TrunkRegistrar) setStatus(trunkID int64, name, trunkType string, statu
 	defer tr.mu.Unlock()
 
 	entry, ok := tr.states[trunkID]
+	var prev TrunkStatus
 	if ok {
+		prev = entry.state.Status
 		entry.state.Status = status
 		entry.state.LastError = lastErr
 	} else {
-		tr.states[trunkID] = &trunkEntry{
+		entry = &trunkEntry{
 			state: TrunkState{
 				TrunkID:   trunkID,
 				Name:      name,
@@ -723,7 +754,9 @@ func (tr *TrunkRegistrar) setStatus(trunkID int64, name, trunkType string, statu
 				LastError: lastErr,
 			},
 		}
+		tr.states[trunkID] = entry
 	}
+	tr.notifyStatus(entry, prev)
 }
 
 // getResponse waits for the first response from a SIP client transaction.
@@ -1,6 +1,8 @@
 package sip
 
 import (
+	"io"
+	"log/slog"
 	"testing"
 	"time"
 )
@@ -126,3 +128,23 @@ func TestParseExpiresHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestTrunkRegistrarNotifiesStatusChanges(t *testing.T) {
+	tr := NewTrunkRegistrar(nil, "", 5060, 5061, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var got []TrunkStatus
+	tr.OnStatusChange(func(st TrunkState) {
+		if st.TrunkID != 1 || st.Name != "carrier" {
+			t.Errorf("state = %+v", st)
+		}
+		got = append(got, st.Status)
+	})
+
+	tr.setStatus(1, "carrier", "register", TrunkStatusDisabled, "")
+	tr.setStatus(1, "carrier", "register", TrunkStatusDisabled, "")
+	tr.setStatus(1, "carrier", "register", TrunkStatusFailed, "timeout")
+
+	if len(got) != 2 || got[0] != TrunkStatusDisabled || got[1] != TrunkStatusFailed {
+		t.Errorf("status changes = %v, want [disabled failed]", got)
+	}
+}
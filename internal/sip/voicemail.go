@@ -0,0 +1,100 @@
+package sip
+
+import (
+	"context"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/flowpbx/flowpbx/internal/flow"
+)
+
+// Voicemail retrieval feature codes.
+const (
+	// ownVoicemailCode opens the mailbox linked to the calling extension.
+	ownVoicemailCode = "*97"
+
+	// anyVoicemailCode asks the caller which mailbox to open.
+	anyVoicemailCode = "*98"
+)
+
+// parseVoicemailCode reports whether a dialled number is a voicemail
+// retrieval feature code, and whether it is for the caller's own mailbox.
+func parseVoicemailCode(user string) (own bool, ok bool) {
+	switch user {
+	case ownVoicemailCode:
+		return true, true
+	case anyVoicemailCode:
+		return false, true
+	}
+	return false, false
+}
+
+// voicemailNode builds the voicemail retrieval flow node run for a
+// voicemail feature code.
+func voicemailNode(own bool) flow.Node {
+	config := map[string]any{}
+	if own {
+		config["mailbox"] = "caller"
+	}
+	return flow.Node{
+		ID:   "feature_voicemail",
+		Type: "voicemail_retrieval",
+		Data: flow.NodeData{
+			Label:  "Voicemail",
+			Config: config,
+		},
+	}
+}
+
+// handleVoicemailCall runs voicemail retrieval for an extension that dialled
+// a voicemail feature code. The node answers the call itself; whatever is
+// left of the call when it finishes is hung up.
+func (h *InviteHandler) handleVoicemailCall(req *sip.Request, tx sip.ServerTransaction, ic *InviteContext, callID string) {
+	if h.flowEngine == nil || h.flowActions == nil {
+		h.respondErrorWithCDR(req, tx, 501, "Not Implemented", callID)
+		return
+	}
+
+	h.logger.Info("voicemail retrieval started",
+		"call_id", callID,
+		"caller", ic.CallerIDNum,
+		"own_mailbox", ic.VoicemailOwn,
+	)
+
+	callCtx := flow.NewCallContext(
+		callID,
+		ic.CallerIDName,
+		ic.CallerIDNum,
+		ic.RequestURI,
+		nil,
+		ic.TrunkID,
+		req,
+		tx,
+	)
+
+	_, err := h.flowEngine.ExecuteNode(callCtx, voicemailNode(ic.VoicemailOwn))
+	answered := h.flowActions.pbxAnswered(callID)
+	switch {
+	case err != nil && answered:
+		h.logger.Error("voicemail retrieval failed",
+			"call_id", callID,
+			"error", err,
+		)
+		if err := h.flowActions.HangupCall(context.Background(), callCtx, 500, "Internal Server Error"); err != nil {
+			h.logger.Error("failed to hang up voicemail call",
+				"call_id", callID,
+				"error", err,
+			)
+		}
+	case err != nil:
+		h.logger.Error("voicemail retrieval failed",
+			"call_id", callID,
+			"error", err,
+		)
+		h.respondErrorWithCDR(req, tx, 500, "Internal Server Error", callID)
+	case !answered:
+		// The caller hung up before the call was answered.
+		h.finalizeCDRFailed(callID, 487)
+	}
+
+	h.flowActions.releaseEarlyMedia(callID)
+}
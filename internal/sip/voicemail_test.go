@@ -0,0 +1,23 @@
+package sip
+
+import "testing"
+
+func TestParseVoicemailCode(t *testing.T) {
+	tests := []struct {
+		user    string
+		wantOwn bool
+		wantOK  bool
+	}{
+		{"*97", true, true},
+		{"*98", false, true},
+		{"*9", false, false},
+		{"*970", false, false},
+		{"97", false, false},
+	}
+	for _, tt := range tests {
+		own, ok := parseVoicemailCode(tt.user)
+		if own != tt.wantOwn || ok != tt.wantOK {
+			t.Errorf("parseVoicemailCode(%q) = %v, %v; want %v, %v", tt.user, own, ok, tt.wantOwn, tt.wantOK)
+		}
+	}
+}
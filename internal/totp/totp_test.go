@@ -0,0 +1,142 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 test key of RFC 6238 appendix B
+// ("12345678901234567890") in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// RFC 6238 lists 8-digit codes; a 6-digit code is the last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	step, ok := Validate(rfcSecret, "050471", now)
+	if !ok {
+		t.Fatal("current code rejected")
+	}
+	if step != 1111111111/30 {
+		t.Errorf("step = %d, want %d", step, 1111111111/30)
+	}
+
+	// The previous period's code is accepted for clock drift.
+	prev, _ := Code(rfcSecret, now.Add(-30*time.Second))
+	if s, ok := Validate(rfcSecret, prev, now); !ok || s != step-1 {
+		t.Errorf("previous period code: step %d ok %v", s, ok)
+	}
+
+	// Two periods away is too far.
+	old, _ := Code(rfcSecret, now.Add(-90*time.Second))
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Error("code from two periods ago accepted")
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(rfcSecret, bad, now); ok {
+			t.Errorf("Validate(%q) accepted", bad)
+		}
+	}
+	if _, ok := Validate("not base32!", "050471", now); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestValidateLenientSecret(t *testing.T) {
+	now := time.Unix(59, 0)
+	lower := strings.ToLower(rfcSecret[:16]) + " " + rfcSecret[16:]
+	if _, ok := Validate(lower, "287082", now); !ok {
+		t.Error("lower case secret with spaces rejected")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	s1, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	s2, _ := GenerateSecret()
+	if s1 == s2 {
+		t.Error("two secrets are equal")
+	}
+	if len(s1) != 32 {
+		t.Errorf("secret length = %d, want 32", len(s1))
+	}
+
+	now := time.Now()
+	c, err := Code(s1, now)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	if _, ok := Validate(s1, c, now); !ok {
+		t.Error("generated secret does not validate its own code")
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("FlowPBX", "alice", rfcSecret)
+	want := "otpauth://totp/FlowPBX:alice?algorithm=SHA1&digits=6&issuer=FlowPBX&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("URI = %s\nwant  %s", got, want)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		if len(c) != 11 || c[5] != '-' {
+			t.Errorf("code %q is not of the form xxxxx-xxxxx", c)
+		}
+		if seen[c] {
+			t.Errorf("duplicate code %q", c)
+		}
+		seen[c] = true
+		hashes[i] = HashRecoveryCode(c)
+	}
+
+	if i := MatchRecoveryCode(hashes, codes[3]); i != 3 {
+		t.Errorf("MatchRecoveryCode = %d, want 3", i)
+	}
+	// Case, spaces and dashes are ignored.
+	typed := " " + strings.ToUpper(strings.ReplaceAll(codes[7], "-", "")) + " "
+	if i := MatchRecoveryCode(hashes, typed); i != 7 {
+		t.Errorf("MatchRecoveryCode(%q) = %d, want 7", typed, i)
+	}
+	if i := MatchRecoveryCode(hashes, "aaaaa-aaaaa"); i != -1 {
+		t.Errorf("unknown code matched %d", i)
+	}
+}
@@ -0,0 +1,125 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// Placeholders substituted in CommandSynthesizer arguments.
+const (
+	placeholderText   = "{text}"
+	placeholderVoice  = "{voice}"
+	placeholderOutput = "{output}"
+)
+
+// CommandSynthesizer runs a local speech engine such as espeak-ng or piper,
+// so speech can be rendered without any network access. The engine must
+// produce a WAV file; its output is converted to G.711.
+//
+// The command line is split on whitespace and run without a shell. In its
+// arguments, {voice} is replaced by the configured voice, {text} by the text
+// to speak and {output} by the path of a temporary file the engine should
+// write. Without {text} the text is written to the engine's stdin, which is
+// the safer choice since the text may come from the caller (e.g. a caller ID
+// name); without {output} the WAV is read from its stdout. For example:
+//
+//	espeak-ng --stdout -v {voice} --stdin
+//	espeak-ng --stdout -v {voice} -- {text}
+//	piper --model /opt/piper/en_US-lessac-medium.onnx --output_file {output}
+//
+// Text starting with "-" is never passed where the engine could read it as
+// an option: unless a "--" argument comes before it, it is sent with a
+// leading space.
+type CommandSynthesizer struct {
+	path  string
+	args  []string
+	voice string
+}
+
+// NewCommandSynthesizer creates a synthesizer running the given command
+// line with the given voice.
+func NewCommandSynthesizer(command, voice string) (*CommandSynthesizer, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("tts: empty command")
+	}
+	return &CommandSynthesizer{
+		path:  fields[0],
+		args:  fields[1:],
+		voice: voice,
+	}, nil
+}
+
+// Synthesize runs the engine and converts its output to G.711.
+func (s *CommandSynthesizer) Synthesize(ctx context.Context, text string, payloadType int) ([]byte, error) {
+	var output string
+	args := make([]string, len(s.args))
+	textArg := false
+	endOfOptions := false
+	for i, arg := range s.args {
+		if strings.Contains(arg, placeholderOutput) && output == "" {
+			dir, err := os.MkdirTemp("", "flowpbx-tts-")
+			if err != nil {
+				return nil, fmt.Errorf("tts: creating output directory: %w", err)
+			}
+			defer os.RemoveAll(dir)
+			output = filepath.Join(dir, "speech.wav")
+		}
+		argText := text
+		if strings.Contains(arg, placeholderText) {
+			textArg = true
+			if !endOfOptions && strings.HasPrefix(arg, placeholderText) && strings.HasPrefix(text, "-") {
+				argText = " " + text
+			}
+		}
+		if arg == "--" {
+			endOfOptions = true
+		}
+		arg = strings.ReplaceAll(arg, placeholderOutput, output)
+		arg = strings.ReplaceAll(arg, placeholderVoice, s.voice)
+		args[i] = strings.ReplaceAll(arg, placeholderText, argText)
+	}
+
+	cmd := exec.CommandContext(ctx, s.path, args...)
+	if !textArg {
+		cmd.Stdin = strings.NewReader(text)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("tts: running %s: %w: %s", s.path, err, msg)
+		}
+		return nil, fmt.Errorf("tts: running %s: %w", s.path, err)
+	}
+
+	audio := stdout.Bytes()
+	if output != "" {
+		data, err := os.ReadFile(output)
+		if err != nil {
+			return nil, fmt.Errorf("tts: reading %s output: %w", s.path, err)
+		}
+		audio = data
+	}
+
+	wav, err := media.ConvertWAV(audio, payloadType)
+	if err != nil {
+		return nil, fmt.Errorf("tts: converting %s output: %w", s.path, err)
+	}
+	return wav, nil
+}
+
+// CacheKey identifies the command line and voice.
+func (s *CommandSynthesizer) CacheKey() string {
+	return "command\x00" + s.path + "\x00" + strings.Join(s.args, " ") + "\x00" + s.voice
+}
@@ -0,0 +1,99 @@
+package tts
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// writeEngineScript writes a shell script standing in for a speech engine.
+func writeEngineScript(t *testing.T, body string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "speech.wav"), testWAV(1600), 0640); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "engine.sh")
+	script := "#!/bin/sh\nWAV=" + filepath.Join(dir, "speech.wav") + "\n" + body + "\n"
+	if err := os.WriteFile(path, []byte(script), 0750); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommandSynthesizerStdout(t *testing.T) {
+	// Text arrives on stdin; the WAV goes to stdout.
+	engine := writeEngineScript(t, `read text; [ "$text" = "Hello there" ] || exit 3; cat "$WAV"`)
+
+	s, err := NewCommandSynthesizer(engine, "en")
+	if err != nil {
+		t.Fatalf("NewCommandSynthesizer: %v", err)
+	}
+	wav, err := s.Synthesize(context.Background(), "Hello there", media.PayloadPCMA)
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if err := media.ValidateWAVData(wav); err != nil {
+		t.Errorf("output invalid: %v", err)
+	}
+}
+
+func TestCommandSynthesizerPlaceholders(t *testing.T) {
+	// Voice and text are passed as arguments; the WAV is written to {output}.
+	engine := writeEngineScript(t, `[ "$1" = "-v" ] && [ "$2" = "en-au" ] && [ "$3" = "Hello there" ] || exit 3; cp "$WAV" "$4"`)
+
+	s, err := NewCommandSynthesizer(engine+" -v {voice} {text} {output}", "en-au")
+	if err != nil {
+		t.Fatalf("NewCommandSynthesizer: %v", err)
+	}
+	wav, err := s.Synthesize(context.Background(), "Hello there", media.PayloadPCMU)
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if err := media.ValidateWAVData(wav); err != nil {
+		t.Errorf("output invalid: %v", err)
+	}
+}
+
+func TestCommandSynthesizerOptionLikeText(t *testing.T) {
+	// The engine fails if the text reaches it as an option. getopts stops
+	// at the first non-option, so " -w/tmp/x" is an operand but "-w/tmp/x"
+	// is option -w.
+	engine := writeEngineScript(t, `while getopts w: opt; do exit 3; done; cat "$WAV"`)
+	guarded := writeEngineScript(t, `[ "$1" = "--" ] && [ "$2" = "-w/tmp/x" ] || exit 3; cat "$WAV"`)
+
+	for _, command := range []string{engine + " {text}", guarded + " -- {text}"} {
+		s, err := NewCommandSynthesizer(command, "")
+		if err != nil {
+			t.Fatalf("NewCommandSynthesizer: %v", err)
+		}
+		if _, err := s.Synthesize(context.Background(), "-w/tmp/x", media.PayloadPCMU); err != nil {
+			t.Errorf("%s: Synthesize: %v", command, err)
+		}
+	}
+}
+
+func TestCommandSynthesizerFailure(t *testing.T) {
+	engine := writeEngineScript(t, `echo "voice not found" >&2; exit 1`)
+
+	s, err := NewCommandSynthesizer(engine, "")
+	if err != nil {
+		t.Fatalf("NewCommandSynthesizer: %v", err)
+	}
+	_, err = s.Synthesize(context.Background(), "Hello", media.PayloadPCMU)
+	if err == nil || !strings.Contains(err.Error(), "voice not found") {
+		t.Errorf("error = %v, want engine stderr", err)
+	}
+
+	if _, err := NewCommandSynthesizer("  ", ""); err == nil {
+		t.Error("expected error for empty command")
+	}
+}
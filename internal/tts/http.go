@@ -0,0 +1,103 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// maxHTTPAudioSize bounds the response read from an HTTP speech engine.
+const maxHTTPAudioSize = 32 << 20
+
+// httpRequest is the JSON body posted to an HTTP speech engine.
+type httpRequest struct {
+	Text       string `json:"text"`
+	Voice      string `json:"voice,omitempty"`
+	SampleRate int    `json:"sample_rate"`
+}
+
+// HTTPSynthesizer renders speech with an external engine over HTTP. It
+// POSTs a JSON body of the form
+//
+//	{"text": "Hello", "voice": "en-au", "sample_rate": 8000}
+//
+// and expects a WAV file in reply, which is converted to G.711. When an API
+// key is configured it is sent as a bearer token.
+type HTTPSynthesizer struct {
+	httpClient *http.Client
+	url        string
+	voice      string
+	apiKey     string
+}
+
+// NewHTTPSynthesizer creates a synthesizer posting to the given URL.
+func NewHTTPSynthesizer(url, voice, apiKey string) *HTTPSynthesizer {
+	return &HTTPSynthesizer{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		url:        url,
+		voice:      voice,
+		apiKey:     apiKey,
+	}
+}
+
+// Synthesize requests speech from the engine and converts it to G.711.
+func (s *HTTPSynthesizer) Synthesize(ctx context.Context, text string, payloadType int) ([]byte, error) {
+	body, err := json.Marshal(httpRequest{
+		Text:       text,
+		Voice:      s.voice,
+		SampleRate: 8000,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tts: marshalling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("tts: creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "audio/wav")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tts: sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	audio, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPAudioSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("tts: reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(audio))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return nil, fmt.Errorf("tts: engine returned status %d: %s", resp.StatusCode, msg)
+	}
+	if len(audio) > maxHTTPAudioSize {
+		return nil, errors.New("tts: engine response too large")
+	}
+
+	wav, err := media.ConvertWAV(audio, payloadType)
+	if err != nil {
+		return nil, fmt.Errorf("tts: converting engine response: %w", err)
+	}
+	return wav, nil
+}
+
+// CacheKey identifies the engine URL and voice.
+func (s *HTTPSynthesizer) CacheKey() string {
+	return "http\x00" + s.url + "\x00" + s.voice
+}
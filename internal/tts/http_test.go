@@ -0,0 +1,53 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+func TestHTTPSynthesizer(t *testing.T) {
+	var got httpRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Write(testWAV(1600))
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSynthesizer(srv.URL, "en-au", "secret")
+	wav, err := s.Synthesize(context.Background(), "Hello there", media.PayloadPCMU)
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if err := media.ValidateWAVData(wav); err != nil {
+		t.Errorf("output invalid: %v", err)
+	}
+	if got.Text != "Hello there" || got.Voice != "en-au" || got.SampleRate != 8000 {
+		t.Errorf("request = %+v", got)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", auth)
+	}
+}
+
+func TestHTTPSynthesizerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSynthesizer(srv.URL, "", "")
+	if _, err := s.Synthesize(context.Background(), "Hello", media.PayloadPCMU); err == nil {
+		t.Error("expected error for non-200 response")
+	}
+}
@@ -0,0 +1,203 @@
+// Package tts renders text prompts to speech for playback on calls. Speech
+// is produced by a pluggable Synthesizer and cached on disk by content, so
+// each distinct prompt is only synthesized once.
+package tts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// Synthesizer renders text to speech.
+type Synthesizer interface {
+	// Synthesize renders text as an 8 kHz mono G.711 WAV file in the law
+	// given by payloadType (media.PayloadPCMU or media.PayloadPCMA).
+	Synthesize(ctx context.Context, text string, payloadType int) ([]byte, error)
+
+	// CacheKey identifies the engine and voice, so cached speech is
+	// rendered again when either changes.
+	CacheKey() string
+}
+
+// ErrEmptyText is returned when asked to speak empty text.
+var ErrEmptyText = errors.New("tts: empty text")
+
+// Cache renders text through a Synthesizer and keeps the results as WAV
+// files under $DATA_DIR/tts, named by a hash of the engine, voice, codec
+// and text. Files are rendered once and reused across calls and restarts.
+type Cache struct {
+	synth       Synthesizer
+	dir         string
+	payloadType int
+	logger      *slog.Logger
+
+	// inFlight serializes rendering of the same prompt by concurrent
+	// calls, keyed by cache key.
+	mu       sync.Mutex
+	inFlight map[string]*renderLock
+}
+
+// renderLock guards the rendering of one prompt.
+type renderLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewCache creates a speech cache in dataDir/tts that renders prompts in
+// the given G.711 payload type.
+func NewCache(synth Synthesizer, dataDir string, payloadType int, logger *slog.Logger) *Cache {
+	return &Cache{
+		synth:       synth,
+		dir:         CacheDir(dataDir),
+		payloadType: payloadType,
+		logger:      logger.With("subsystem", "tts"),
+		inFlight:    make(map[string]*renderLock),
+	}
+}
+
+// CacheDir returns the directory cached speech is stored in.
+func CacheDir(dataDir string) string {
+	return filepath.Join(dataDir, "tts")
+}
+
+// Render returns the path of a WAV file speaking text, synthesizing it if
+// it is not already cached.
+func (c *Cache) Render(ctx context.Context, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrEmptyText
+	}
+
+	key := c.key(text)
+	path := filepath.Join(c.dir, key+".wav")
+
+	c.acquire(key)
+	defer c.release(key)
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	audio, err := c.synth.Synthesize(ctx, text, c.payloadType)
+	if err != nil {
+		return "", fmt.Errorf("tts: synthesizing speech: %w", err)
+	}
+	if err := media.ValidateWAVData(audio); err != nil {
+		return "", fmt.Errorf("tts: synthesizer output: %w", err)
+	}
+
+	if err := os.MkdirAll(c.dir, 0750); err != nil {
+		return "", fmt.Errorf("tts: creating cache directory: %w", err)
+	}
+	// Write to a temporary file first so a partial render is never played.
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("tts: creating cache file: %w", err)
+	}
+	if _, err := tmp.Write(audio); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("tts: writing cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("tts: writing cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("tts: saving cache file: %w", err)
+	}
+
+	c.logger.Debug("speech rendered",
+		"file", path,
+		"chars", len(text),
+		"bytes", len(audio),
+	)
+	return path, nil
+}
+
+// key returns the content address of text rendered by this cache.
+func (c *Cache) key(text string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s", c.synth.CacheKey(), c.payloadType, text)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// acquire waits until no other call is rendering the given key.
+func (c *Cache) acquire(key string) {
+	c.mu.Lock()
+	l, ok := c.inFlight[key]
+	if !ok {
+		l = &renderLock{}
+		c.inFlight[key] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+
+	l.Lock()
+}
+
+// release ends rendering of the given key.
+func (c *Cache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.inFlight[key]
+	l.refs--
+	if l.refs == 0 {
+		delete(c.inFlight, key)
+	}
+	l.Unlock()
+}
+
+// Supported speech engines.
+const (
+	EngineCommand = "command"
+	EngineHTTP    = "http"
+)
+
+// Config selects and configures the speech engine.
+type Config struct {
+	// Engine is EngineCommand, EngineHTTP, or empty to disable speech.
+	Engine string
+
+	// Command is the command line run by the command engine.
+	Command string
+
+	// URL is the endpoint of the HTTP engine.
+	URL string
+
+	// Voice is passed to the engine to select a voice.
+	Voice string
+
+	// APIKey is sent to the HTTP engine as a bearer token.
+	APIKey string
+}
+
+// NewSynthesizer creates the synthesizer selected by cfg. It returns nil
+// if no engine is configured.
+func NewSynthesizer(cfg Config) (Synthesizer, error) {
+	switch cfg.Engine {
+	case "":
+		return nil, nil
+	case EngineCommand:
+		return NewCommandSynthesizer(cfg.Command, cfg.Voice)
+	case EngineHTTP:
+		if cfg.URL == "" {
+			return nil, errors.New("tts: http engine requires a url")
+		}
+		return NewHTTPSynthesizer(cfg.URL, cfg.Voice, cfg.APIKey), nil
+	default:
+		return nil, fmt.Errorf("tts: unknown engine %q", cfg.Engine)
+	}
+}
@@ -0,0 +1,166 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/flowpbx/flowpbx/internal/media"
+)
+
+// testWAV builds a 16 kHz 16-bit mono PCM WAV of the given length, as a
+// speech engine would produce.
+func testWAV(samples int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+2*samples))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint32(16000))
+	binary.Write(&buf, binary.LittleEndian, uint32(32000))
+	binary.Write(&buf, binary.LittleEndian, uint16(2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(2*samples))
+	for i := 0; i < samples; i++ {
+		binary.Write(&buf, binary.LittleEndian, int16(i%100*100))
+	}
+	return buf.Bytes()
+}
+
+// fakeSynthesizer renders every text as the same short WAV and counts
+// calls.
+type fakeSynthesizer struct {
+	mu    sync.Mutex
+	calls int
+	key   string
+	err   error
+	raw   []byte
+}
+
+func (f *fakeSynthesizer) Synthesize(_ context.Context, _ string, payloadType int) ([]byte, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.raw != nil {
+		return f.raw, nil
+	}
+	return media.ConvertWAV(testWAV(1600), payloadType)
+}
+
+func (f *fakeSynthesizer) CacheKey() string { return f.key }
+
+func newTestCache(synth Synthesizer, dataDir string) *Cache {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	return NewCache(synth, dataDir, media.PayloadPCMU, logger)
+}
+
+func TestCacheRendersOnce(t *testing.T) {
+	dataDir := t.TempDir()
+	synth := &fakeSynthesizer{key: "voice-a"}
+	c := newTestCache(synth, dataDir)
+
+	var wg sync.WaitGroup
+	paths := make([]string, 5)
+	for i := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := c.Render(context.Background(), "Welcome to FlowPBX")
+			if err != nil {
+				t.Errorf("Render: %v", err)
+			}
+			paths[i] = p
+		}()
+	}
+	wg.Wait()
+
+	if synth.calls != 1 {
+		t.Errorf("synthesizer called %d times, want 1", synth.calls)
+	}
+	for _, p := range paths {
+		if p != paths[0] {
+			t.Fatalf("paths differ: %v", paths)
+		}
+	}
+	if filepath.Dir(paths[0]) != CacheDir(dataDir) {
+		t.Errorf("path %s not under %s", paths[0], CacheDir(dataDir))
+	}
+	if pt, _, err := media.ValidateWAVFile(paths[0]); err != nil || pt != media.PayloadPCMU {
+		t.Errorf("cached file = pt %d, %v; want valid PCMU", pt, err)
+	}
+
+	// Surrounding whitespace does not change the prompt.
+	if p, err := c.Render(context.Background(), "  Welcome to FlowPBX\n"); err != nil || p != paths[0] {
+		t.Errorf("Render with whitespace = %q, %v; want cached %q", p, err, paths[0])
+	}
+
+	// A different voice renders again into a different file.
+	other := newTestCache(&fakeSynthesizer{key: "voice-b"}, dataDir)
+	p, err := other.Render(context.Background(), "Welcome to FlowPBX")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if p == paths[0] {
+		t.Error("different voice reused the cached file")
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	dataDir := t.TempDir()
+
+	c := newTestCache(&fakeSynthesizer{}, dataDir)
+	if _, err := c.Render(context.Background(), "   "); !errors.Is(err, ErrEmptyText) {
+		t.Errorf("empty text error = %v, want ErrEmptyText", err)
+	}
+
+	engineErr := errors.New("engine down")
+	c = newTestCache(&fakeSynthesizer{err: engineErr}, dataDir)
+	if _, err := c.Render(context.Background(), "Hello"); !errors.Is(err, engineErr) {
+		t.Errorf("engine error = %v, want %v", err, engineErr)
+	}
+
+	c = newTestCache(&fakeSynthesizer{raw: testWAV(100)}, dataDir)
+	if _, err := c.Render(context.Background(), "Hello"); err == nil {
+		t.Error("expected error for synthesizer output that is not G.711")
+	}
+
+	entries, _ := os.ReadDir(CacheDir(dataDir))
+	if len(entries) != 0 {
+		t.Errorf("cache holds %d files after failed renders, want 0", len(entries))
+	}
+}
+
+func TestNewSynthesizer(t *testing.T) {
+	if s, err := NewSynthesizer(Config{}); s != nil || err != nil {
+		t.Errorf("no engine = %v, %v; want nil, nil", s, err)
+	}
+	if _, err := NewSynthesizer(Config{Engine: EngineCommand}); err == nil {
+		t.Error("expected error for command engine without a command")
+	}
+	if _, err := NewSynthesizer(Config{Engine: EngineHTTP}); err == nil {
+		t.Error("expected error for http engine without a url")
+	}
+	if _, err := NewSynthesizer(Config{Engine: "festival"}); err == nil {
+		t.Error("expected error for unknown engine")
+	}
+	s, err := NewSynthesizer(Config{Engine: EngineHTTP, URL: "http://tts.local/speak", Voice: "en-au"})
+	if err != nil {
+		t.Fatalf("NewSynthesizer: %v", err)
+	}
+	if _, ok := s.(*HTTPSynthesizer); !ok {
+		t.Errorf("synthesizer = %T, want *HTTPSynthesizer", s)
+	}
+}
@@ -0,0 +1,32 @@
+import { get, post, put, del } from './client'
+import type { AdminUser, AdminUserRequest } from './types'
+
+/** List all admin users. */
+export function listAdminUsers(): Promise<AdminUser[]> {
+  return get<AdminUser[]>('/admin-users')
+}
+
+/** Get a single admin user by ID. */
+export function getAdminUser(id: number): Promise<AdminUser> {
+  return get<AdminUser>(`/admin-users/${id}`)
+}
+
+/** Create a new admin user. */
+export function createAdminUser(data: AdminUserRequest): Promise<AdminUser> {
+  return post<AdminUser>('/admin-users', data)
+}
+
+/** Update an existing admin user. */
+export function updateAdminUser(id: number, data: AdminUserRequest): Promise<AdminUser> {
+  return put<AdminUser>(`/admin-users/${id}`, data)
+}
+
+/** Delete an admin user. */
+export function deleteAdminUser(id: number): Promise<null> {
+  return del(`/admin-users/${id}`)
+}
+
+/** Turn off two-factor login for an admin user who lost their codes. */
+export function resetAdminUserTOTP(id: number): Promise<null> {
+  return del(`/admin-users/${id}/totp`)
+}
@@ -1,16 +1,32 @@
 import { get, post } from './client'
-import type { AuthUser, HealthResponse, LoginRequest, LoginResponse, SetupRequest } from './types'
+import type {
+  AuthUser,
+  ExtensionLoginRequest,
+  ExtensionLoginResponse,
+  HealthResponse,
+  LoginRequest,
+  LoginResponse,
+  RecoveryCodes,
+  SetupRequest,
+  TOTPEnrollment,
+} from './types'
 
 /** Check system health and whether setup is needed. */
 export function getHealth(): Promise<HealthResponse> {
   return get<HealthResponse>('/health')
 }
 
-/** Log in with username and password. */
+/** Log in with username and password, plus a TOTP or recovery code when
+ *  the first attempt answers totp_required. */
 export function login(credentials: LoginRequest): Promise<LoginResponse> {
   return post<LoginResponse>('/auth/login', credentials)
 }
 
+/** Log in as an extension user for self-service with SIP credentials. */
+export function extensionLogin(credentials: ExtensionLoginRequest): Promise<ExtensionLoginResponse> {
+  return post<ExtensionLoginResponse>('/auth/extension-login', credentials)
+}
+
 /** Log out the current session. */
 export function logout(): Promise<null> {
   return post<null>('/auth/logout')
@@ -25,3 +41,23 @@ export function getMe(): Promise<AuthUser> {
 export function setup(data: SetupRequest): Promise<null> {
   return post<null>('/setup', data)
 }
+
+/** Start TOTP enrollment for the current admin. */
+export function enrollTOTP(): Promise<TOTPEnrollment> {
+  return post<TOTPEnrollment>('/auth/totp/enroll')
+}
+
+/** Verify a code from the enrolled secret, turning on two-factor login. */
+export function verifyTOTP(code: string): Promise<RecoveryCodes> {
+  return post<RecoveryCodes>('/auth/totp/verify', { code })
+}
+
+/** Turn off two-factor login for the current admin. */
+export function disableTOTP(password: string): Promise<null> {
+  return post<null>('/auth/totp/disable', { password })
+}
+
+/** Replace the current admin's recovery codes. */
+export function regenerateRecoveryCodes(password: string): Promise<RecoveryCodes> {
+  return post<RecoveryCodes>('/auth/totp/recovery-codes', { password })
+}
@@ -0,0 +1,27 @@
+import { get, post, put, del } from './client'
+import type { CallerFilter, CallerFilterRequest } from './types'
+
+/** List all caller blocklist and allowlist entries. */
+export function listCallerFilters(): Promise<CallerFilter[]> {
+  return get<CallerFilter[]>('/caller-filters')
+}
+
+/** Get a single caller filter entry by ID. */
+export function getCallerFilter(id: number): Promise<CallerFilter> {
+  return get<CallerFilter>(`/caller-filters/${id}`)
+}
+
+/** Create a new caller filter entry. */
+export function createCallerFilter(data: CallerFilterRequest): Promise<CallerFilter> {
+  return post<CallerFilter>('/caller-filters', data)
+}
+
+/** Update an existing caller filter entry. */
+export function updateCallerFilter(id: number, data: CallerFilterRequest): Promise<CallerFilter> {
+  return put<CallerFilter>(`/caller-filters/${id}`, data)
+}
+
+/** Delete a caller filter entry. */
+export function deleteCallerFilter(id: number): Promise<null> {
+  return del(`/caller-filters/${id}`)
+}
@@ -1,5 +1,5 @@
 import { list, get } from './client'
-import type { PaginatedResponse, CDR } from './types'
+import type { PaginatedResponse, CDR, ExtensionCost } from './types'
 
 export interface CDRListParams {
   limit?: number
@@ -20,6 +20,20 @@ export function getCDR(id: number): Promise<CDR> {
   return get<CDR>(`/cdrs/${id}`)
 }
 
+/** Total the cost of rated calls per calling extension. */
+export function listCDRCosts(params?: Omit<CDRListParams, 'limit' | 'offset'>): Promise<ExtensionCost[]> {
+  const query = new URLSearchParams()
+  if (params) {
+    for (const [key, value] of Object.entries(params)) {
+      if (value !== undefined && value !== '') {
+        query.set(key, String(value))
+      }
+    }
+  }
+  const qs = query.toString()
+  return get<ExtensionCost[]>(qs ? `/cdrs/costs?${qs}` : '/cdrs/costs')
+}
+
 /** Build the CSV export URL with current filters. */
 export function buildExportURL(params?: Omit<CDRListParams, 'limit' | 'offset'>): string {
   const url = new URL('/api/v1/cdrs/export', window.location.origin)
@@ -0,0 +1,85 @@
+/** Topic of the real-time event stream. */
+export type EventTopic = 'calls' | 'registrations' | 'trunks' | 'conferences' | 'voicemail'
+
+/** A real-time event from GET /events. */
+export interface PbxEvent<T = unknown> {
+  type: string
+  topic: EventTopic
+  time: string
+  extensions?: string[]
+  data: T
+}
+
+/** Delay before reconnecting a dropped stream. */
+const RECONNECT_MS = 5000
+
+/**
+ * Subscribe to real-time events on the given topics. Uses a WebSocket, and
+ * falls back to server-sent events if the WebSocket cannot be opened.
+ * Dropped connections are retried. Returns a function that closes the
+ * stream.
+ */
+export function subscribeEvents(topics: EventTopic[], onEvent: (event: PbxEvent) => void): () => void {
+  const query = `topics=${encodeURIComponent(topics.join(','))}`
+  let closed = false
+  let useSSE = typeof WebSocket === 'undefined'
+  let socket: WebSocket | null = null
+  let source: EventSource | null = null
+  let timer: ReturnType<typeof setTimeout> | null = null
+
+  function reconnect() {
+    if (!closed) timer = setTimeout(connect, RECONNECT_MS)
+  }
+
+  function connect() {
+    if (useSSE) {
+      source = new EventSource(`/api/v1/events?${query}`)
+      source.addEventListener('error', () => {
+        source?.close()
+        reconnect()
+      })
+      // SSE events are named by their type, so each type needs a listener.
+      const dispatch = (e: MessageEvent) => onEvent(JSON.parse(e.data) as PbxEvent)
+      for (const type of EVENT_TYPES) {
+        source.addEventListener(type, dispatch)
+      }
+      return
+    }
+
+    const scheme = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
+    let opened = false
+    socket = new WebSocket(`${scheme}//${window.location.host}/api/v1/events?${query}`)
+    socket.onopen = () => {
+      opened = true
+    }
+    socket.onmessage = (e) => onEvent(JSON.parse(e.data) as PbxEvent)
+    socket.onclose = () => {
+      // A socket that never opened is likely blocked by a proxy.
+      if (!opened) useSSE = true
+      reconnect()
+    }
+  }
+
+  connect()
+
+  return () => {
+    closed = true
+    if (timer) clearTimeout(timer)
+    socket?.close()
+    source?.close()
+  }
+}
+
+/** All event types published by the server, for the SSE fallback. */
+const EVENT_TYPES = [
+  'call.ringing',
+  'call.ringing_stopped',
+  'call.answered',
+  'call.ended',
+  'registration.up',
+  'registration.down',
+  'trunk.status',
+  'conference.join',
+  'conference.leave',
+  'voicemail.new',
+]
@@ -1,5 +1,5 @@
 import { get, post, put, del } from './client'
-import type { CallFlow, CallFlowRequest, FlowValidationResult } from './types'
+import type { CallFlow, CallFlowRequest, CallFlowRevision, FlowDiff, FlowSimulateRequest, FlowSimulationResult, FlowValidationResult } from './types'
 
 /** List all call flows. */
 export function listFlows(): Promise<CallFlow[]> {
@@ -35,3 +35,36 @@ export function publishFlow(id: number): Promise<CallFlow> {
 export function validateFlow(id: number): Promise<FlowValidationResult> {
   return post<FlowValidationResult>(`/flows/${id}/validate`)
 }
+
+/** List the published revisions of a call flow, newest first. */
+export function listFlowRevisions(id: number): Promise<CallFlowRevision[]> {
+  return get<CallFlowRevision[]>(`/flows/${id}/revisions`)
+}
+
+/** Get a single revision of a call flow with its flow data. */
+export function getFlowRevision(id: number, revision: number): Promise<CallFlowRevision> {
+  return get<CallFlowRevision>(`/flows/${id}/revisions/${revision}`)
+}
+
+/** Republish an earlier revision of a call flow and reset the draft to it. */
+export function rollbackFlow(id: number, revision: number): Promise<CallFlow> {
+  return post<CallFlow>(`/flows/${id}/revisions/${revision}/rollback`)
+}
+
+/**
+ * Diff two versions of a call flow. Each of from and to is a revision
+ * number or 'draft'; by default the published revision is compared with
+ * the draft.
+ */
+export function diffFlow(id: number, from?: number | 'draft', to?: number | 'draft'): Promise<FlowDiff> {
+  const params = new URLSearchParams()
+  if (from !== undefined) params.set('from', String(from))
+  if (to !== undefined) params.set('to', String(to))
+  const qs = params.toString()
+  return get<FlowDiff>(`/flows/${id}/diff${qs ? `?${qs}` : ''}`)
+}
+
+/** Dry-run a call flow for a scripted caller without placing any calls. */
+export function simulateFlow(id: number, data: FlowSimulateRequest): Promise<FlowSimulationResult> {
+  return post<FlowSimulationResult>(`/flows/${id}/simulate`, data)
+}
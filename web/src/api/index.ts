@@ -1,18 +1,26 @@
 export { ApiError, get, post, put, del, list } from './client'
-export { getHealth, login, logout, getMe, setup } from './auth'
+export { getHealth, login, extensionLogin, logout, getMe, setup, enrollTOTP, verifyTOTP, disableTOTP, regenerateRecoveryCodes } from './auth'
+export { listAdminUsers, getAdminUser, createAdminUser, updateAdminUser, deleteAdminUser, resetAdminUserTOTP } from './admin_users'
+export { getSelf, updateSelf, listSelfVoicemail, markSelfVoicemailRead, selfVoicemailAudioURL } from './self'
 export { listExtensions, getExtension, createExtension, updateExtension, deleteExtension } from './extensions'
-export { listTrunks, getTrunk, createTrunk, updateTrunk, deleteTrunk, listTrunkStatuses } from './trunks'
+export { listTrunks, getTrunk, createTrunk, updateTrunk, deleteTrunk, listTrunkStatuses, listTrunkRates, importTrunkRates, deleteTrunkRates } from './trunks'
+export { listOutboundRoutes, getOutboundRoute, createOutboundRoute, updateOutboundRoute, deleteOutboundRoute } from './outbound_routes'
 export { listVoicemailBoxes, getVoicemailBox, createVoicemailBox, updateVoicemailBox, deleteVoicemailBox, listVoicemailMessages, deleteVoicemailMessage, markVoicemailMessageRead, voicemailAudioURL } from './voicemail'
 export { listInboundNumbers, getInboundNumber, createInboundNumber, updateInboundNumber, deleteInboundNumber } from './inbound_numbers'
-export { listCDRs, getCDR, buildExportURL } from './cdrs'
+export { listCallerFilters, getCallerFilter, createCallerFilter, updateCallerFilter, deleteCallerFilter } from './caller_filters'
+export { listParkLots, getParkLot, createParkLot, updateParkLot, deleteParkLot } from './park_lots'
+export { listCDRs, getCDR, listCDRCosts, buildExportURL } from './cdrs'
 export { listPrompts, uploadPrompt, deletePrompt, promptAudioURL } from './prompts'
+export { listMOHClasses, getMOHClass, createMOHClass, updateMOHClass, deleteMOHClass } from './moh_classes'
 export { getSettings, updateSettings } from './settings'
 export { reloadSystem } from './system'
+export { subscribeEvents } from './events'
+export type { EventTopic, PbxEvent } from './events'
 export type { ReloadResponse } from './system'
-export { listFlows, getFlow, createFlow, updateFlow, deleteFlow, publishFlow, validateFlow } from './flows'
+export { listFlows, getFlow, createFlow, updateFlow, deleteFlow, publishFlow, validateFlow, listFlowRevisions, getFlowRevision, rollbackFlow, diffFlow, simulateFlow } from './flows'
 export { listRingGroups, getRingGroup, createRingGroup, updateRingGroup, deleteRingGroup } from './ring_groups'
 export { listIVRMenus, getIVRMenu, createIVRMenu, updateIVRMenu, deleteIVRMenu } from './ivr_menus'
-export { listTimeSwitches, getTimeSwitch, createTimeSwitch, updateTimeSwitch, deleteTimeSwitch } from './time_switches'
+export { listTimeSwitches, getTimeSwitch, createTimeSwitch, updateTimeSwitch, deleteTimeSwitch, previewTimeSwitch, listTimeSwitchCalendars, subscribeTimeSwitchCalendar, uploadTimeSwitchCalendar, updateTimeSwitchCalendar, deleteTimeSwitchCalendar, refreshTimeSwitchCalendar } from './time_switches'
 export { listConferenceBridges, getConferenceBridge, createConferenceBridge, updateConferenceBridge, deleteConferenceBridge, listConferenceParticipants, muteConferenceParticipant, kickConferenceParticipant } from './conferences'
 export { listRecordings, deleteRecording, recordingDownloadURL } from './recordings'
 export type {
@@ -22,6 +30,15 @@ export type {
   LoginRequest,
   LoginResponse,
   AuthUser,
+  AdminRole,
+  AdminUser,
+  AdminUserRequest,
+  ExtensionLoginRequest,
+  ExtensionLoginResponse,
+  SelfProfile,
+  SelfProfileRequest,
+  TOTPEnrollment,
+  RecoveryCodes,
   SetupRequest,
   HealthResponse,
   Extension,
@@ -29,13 +46,21 @@ export type {
   FollowMeNumber,
   InboundNumber,
   InboundNumberRequest,
+  CallerFilter,
+  CallerFilterRequest,
+  ParkLot,
+  ParkLotRequest,
   Trunk,
   TrunkRequest,
   TrunkStatusEntry,
+  OutboundRoute,
+  OutboundRouteRequest,
   VoicemailBox,
   VoicemailBoxRequest,
   VoicemailMessage,
   AudioPrompt,
+  MOHClass,
+  MOHClassRequest,
   RingGroup,
   RingGroupRequest,
   IVRMenu,
@@ -44,13 +69,29 @@ export type {
   TimeSwitchRequest,
   TimeSwitchRule,
   TimeSwitchOverride,
+  TimeSwitchCalendar,
+  TimeSwitchCalendarRequest,
+  TimeSwitchPreview,
   ConferenceBridge,
   ConferenceBridgeRequest,
   ConferenceParticipant,
   CDR,
+  ExtensionCost,
+  TrunkRate,
+  TrunkRateImport,
   Recording,
   CallFlow,
   CallFlowRequest,
+  CallFlowRevision,
+  FlowChangeKind,
+  FlowNodeChange,
+  FlowEdgeChange,
+  FlowDiff,
+  SimulatedWebhook,
+  FlowSimulateRequest,
+  SimulationOutcome,
+  SimulatedAction,
+  FlowSimulationResult,
   FlowValidationIssue,
   FlowValidationResult,
 } from './types'
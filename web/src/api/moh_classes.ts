@@ -0,0 +1,27 @@
+import { get, post, put, del } from './client'
+import type { MOHClass, MOHClassRequest } from './types'
+
+/** List all music on hold classes. */
+export function listMOHClasses(): Promise<MOHClass[]> {
+  return get<MOHClass[]>('/moh-classes')
+}
+
+/** Get a single music on hold class by ID. */
+export function getMOHClass(id: number): Promise<MOHClass> {
+  return get<MOHClass>(`/moh-classes/${id}`)
+}
+
+/** Create a new music on hold class. */
+export function createMOHClass(data: MOHClassRequest): Promise<MOHClass> {
+  return post<MOHClass>('/moh-classes', data)
+}
+
+/** Update an existing music on hold class. */
+export function updateMOHClass(id: number, data: MOHClassRequest): Promise<MOHClass> {
+  return put<MOHClass>(`/moh-classes/${id}`, data)
+}
+
+/** Delete a music on hold class. */
+export function deleteMOHClass(id: number): Promise<null> {
+  return del(`/moh-classes/${id}`)
+}
@@ -0,0 +1,27 @@
+import { get, post, put, del } from './client'
+import type { OutboundRoute, OutboundRouteRequest } from './types'
+
+/** List all outbound routes in the order they are matched. */
+export function listOutboundRoutes(): Promise<OutboundRoute[]> {
+  return get<OutboundRoute[]>('/outbound-routes')
+}
+
+/** Get a single outbound route by ID. */
+export function getOutboundRoute(id: number): Promise<OutboundRoute> {
+  return get<OutboundRoute>(`/outbound-routes/${id}`)
+}
+
+/** Create a new outbound route. */
+export function createOutboundRoute(data: OutboundRouteRequest): Promise<OutboundRoute> {
+  return post<OutboundRoute>('/outbound-routes', data)
+}
+
+/** Update an existing outbound route. */
+export function updateOutboundRoute(id: number, data: OutboundRouteRequest): Promise<OutboundRoute> {
+  return put<OutboundRoute>(`/outbound-routes/${id}`, data)
+}
+
+/** Delete an outbound route. */
+export function deleteOutboundRoute(id: number): Promise<null> {
+  return del(`/outbound-routes/${id}`)
+}
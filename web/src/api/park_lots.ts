@@ -0,0 +1,27 @@
+import { get, post, put, del } from './client'
+import type { ParkLot, ParkLotRequest } from './types'
+
+/** List all call park lots. */
+export function listParkLots(): Promise<ParkLot[]> {
+  return get<ParkLot[]>('/park-lots')
+}
+
+/** Get a single park lot by ID. */
+export function getParkLot(id: number): Promise<ParkLot> {
+  return get<ParkLot>(`/park-lots/${id}`)
+}
+
+/** Create a new park lot. */
+export function createParkLot(data: ParkLotRequest): Promise<ParkLot> {
+  return post<ParkLot>('/park-lots', data)
+}
+
+/** Update an existing park lot. */
+export function updateParkLot(id: number, data: ParkLotRequest): Promise<ParkLot> {
+  return put<ParkLot>(`/park-lots/${id}`, data)
+}
+
+/** Delete a park lot. */
+export function deleteParkLot(id: number): Promise<null> {
+  return del(`/park-lots/${id}`)
+}
@@ -0,0 +1,27 @@
+import { get, put } from './client'
+import type { SelfProfile, SelfProfileRequest, VoicemailMessage } from './types'
+
+/** Get the extension of the current self-service session. */
+export function getSelf(): Promise<SelfProfile> {
+  return get<SelfProfile>('/self/me')
+}
+
+/** Update the extension's own settings. */
+export function updateSelf(data: SelfProfileRequest): Promise<SelfProfile> {
+  return put<SelfProfile>('/self/me', data)
+}
+
+/** List the voicemail messages of the boxes that notify the extension. */
+export function listSelfVoicemail(): Promise<VoicemailMessage[]> {
+  return get<VoicemailMessage[]>('/self/voicemail')
+}
+
+/** Mark one of the extension's voicemail messages as read. */
+export function markSelfVoicemailRead(msgId: number): Promise<VoicemailMessage> {
+  return put<VoicemailMessage>(`/self/voicemail/${msgId}/read`)
+}
+
+/** Build the audio URL for one of the extension's voicemail messages. */
+export function selfVoicemailAudioURL(msgId: number): string {
+  return `/api/v1/self/voicemail/${msgId}/audio`
+}
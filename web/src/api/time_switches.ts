@@ -1,5 +1,11 @@
 import { get, post, put, del } from './client'
-import type { TimeSwitch, TimeSwitchRequest } from './types'
+import type {
+  TimeSwitch,
+  TimeSwitchRequest,
+  TimeSwitchCalendar,
+  TimeSwitchCalendarRequest,
+  TimeSwitchPreview,
+} from './types'
 
 /** List all time switches. */
 export function listTimeSwitches(): Promise<TimeSwitch[]> {
@@ -25,3 +31,87 @@ export function updateTimeSwitch(id: number, data: TimeSwitchRequest): Promise<T
 export function deleteTimeSwitch(id: number): Promise<null> {
   return del(`/time-switches/${id}`)
 }
+
+/**
+ * Preview the edge a time switch takes at a moment: an RFC 3339 timestamp
+ * or a local "YYYY-MM-DDTHH:MM" in the switch's timezone. Defaults to now.
+ */
+export function previewTimeSwitch(id: number, at?: string): Promise<TimeSwitchPreview> {
+  const query = at ? `?at=${encodeURIComponent(at)}` : ''
+  return get<TimeSwitchPreview>(`/time-switches/${id}/preview${query}`)
+}
+
+/** List the calendars imported into a time switch. */
+export function listTimeSwitchCalendars(id: number): Promise<TimeSwitchCalendar[]> {
+  return get<TimeSwitchCalendar[]>(`/time-switches/${id}/calendars`)
+}
+
+/** Subscribe a time switch to a calendar URL, refreshed periodically. */
+export function subscribeTimeSwitchCalendar(id: number, data: TimeSwitchCalendarRequest): Promise<TimeSwitchCalendar> {
+  return post<TimeSwitchCalendar>(`/time-switches/${id}/calendars`, data)
+}
+
+/** Import an uploaded .ics file into a time switch via multipart form data. */
+export async function uploadTimeSwitchCalendar(
+  id: number,
+  file: File,
+  label: string,
+  name?: string,
+): Promise<TimeSwitchCalendar> {
+  const formData = new FormData()
+  formData.append('file', file)
+  formData.append('label', label)
+  if (name) {
+    formData.append('name', name)
+  }
+
+  // Use raw fetch for multipart upload (the JSON client sets Content-Type).
+  const csrf = document.cookie
+    .split('; ')
+    .find((row) => row.startsWith('flowpbx_csrf='))
+  const csrfToken = csrf ? csrf.split('=')[1] : null
+
+  const headers: Record<string, string> = { Accept: 'application/json' }
+  if (csrfToken) {
+    headers['X-CSRF-Token'] = csrfToken
+  }
+
+  const res = await fetch(`/api/v1/time-switches/${id}/calendars/upload`, {
+    method: 'POST',
+    headers,
+    credentials: 'same-origin',
+    body: formData,
+  })
+
+  if (res.status === 401) {
+    window.location.href = '/login'
+    throw new Error('authentication required')
+  }
+
+  const envelope = await res.json()
+
+  if (!res.ok || envelope.error) {
+    throw new Error(envelope.error ?? `upload failed with status ${res.status}`)
+  }
+
+  return envelope.data as TimeSwitchCalendar
+}
+
+/** Update a time switch calendar. */
+export function updateTimeSwitchCalendar(
+  id: number,
+  calendarID: number,
+  data: TimeSwitchCalendarRequest,
+): Promise<TimeSwitchCalendar> {
+  return put<TimeSwitchCalendar>(`/time-switches/${id}/calendars/${calendarID}`, data)
+}
+
+/** Remove a calendar from a time switch. */
+export function deleteTimeSwitchCalendar(id: number, calendarID: number): Promise<null> {
+  return del(`/time-switches/${id}/calendars/${calendarID}`)
+}
+
+/** Fetch a URL calendar now instead of waiting for its refresh interval. */
+export function refreshTimeSwitchCalendar(id: number, calendarID: number): Promise<TimeSwitchCalendar> {
+  return post<TimeSwitchCalendar>(`/time-switches/${id}/calendars/${calendarID}/refresh`)
+}
@@ -1,5 +1,5 @@
 import { get, post, put, del, list } from './client'
-import type { Trunk, TrunkRequest, TrunkStatusEntry, PaginatedResponse, PaginationParams } from './types'
+import type { Trunk, TrunkRequest, TrunkStatusEntry, TrunkRate, TrunkRateImport, PaginatedResponse, PaginationParams } from './types'
 
 /** List trunks with pagination. */
 export function listTrunks(params?: PaginationParams): Promise<PaginatedResponse<Trunk>> {
@@ -30,3 +30,50 @@ export function deleteTrunk(id: number): Promise<null> {
 export function listTrunkStatuses(): Promise<TrunkStatusEntry[]> {
   return get<TrunkStatusEntry[]>('/trunks/status')
 }
+
+/** List the rate deck of a trunk. */
+export function listTrunkRates(id: number): Promise<TrunkRate[]> {
+  return get<TrunkRate[]>(`/trunks/${id}/rates`)
+}
+
+/** Replace the rate deck of a trunk with a CSV of prefix,rate[,description] rows. */
+export async function importTrunkRates(id: number, file: File): Promise<TrunkRateImport> {
+  const formData = new FormData()
+  formData.append('file', file)
+
+  // Use raw fetch for multipart upload (the JSON client sets Content-Type).
+  const csrf = document.cookie
+    .split('; ')
+    .find((row) => row.startsWith('flowpbx_csrf='))
+  const csrfToken = csrf ? csrf.split('=')[1] : null
+
+  const headers: Record<string, string> = { Accept: 'application/json' }
+  if (csrfToken) {
+    headers['X-CSRF-Token'] = csrfToken
+  }
+
+  const res = await fetch(`/api/v1/trunks/${id}/rates`, {
+    method: 'POST',
+    headers,
+    credentials: 'same-origin',
+    body: formData,
+  })
+
+  if (res.status === 401) {
+    window.location.href = '/login'
+    throw new Error('authentication required')
+  }
+
+  const envelope = await res.json()
+
+  if (!res.ok || envelope.error) {
+    throw new Error(envelope.error ?? `import failed with status ${res.status}`)
+  }
+
+  return envelope.data as TrunkRateImport
+}
+
+/** Delete the rate deck of a trunk. */
+export function deleteTrunkRates(id: number): Promise<null> {
+  return del(`/trunks/${id}/rates`)
+}
@@ -19,22 +19,99 @@ export interface PaginationParams {
   offset?: number
 }
 
-/** Login request body. */
+/** Admin user role. */
+export type AdminRole = 'owner' | 'admin' | 'operator' | 'read-only' | 'billing'
+
+/** Login request body. totp_code or recovery_code is sent on the second
+ *  step of a two-factor login. */
 export interface LoginRequest {
   username: string
   password: string
+  totp_code?: string
+  recovery_code?: string
 }
 
-/** Login response data. */
+/** Login response data. When totp_required is set no session was created;
+ *  repeat the login with a code. */
 export interface LoginResponse {
-  user_id: number
-  username: string
+  user_id?: number
+  username?: string
+  role?: AdminRole
+  totp_required?: boolean
 }
 
-/** Current authenticated user. */
+/** Current authenticated user: an admin, or an extension user of a
+ *  self-service session (role "extension"). */
 export interface AuthUser {
-  user_id: number
+  user_id?: number
+  username?: string
+  role: AdminRole | 'extension'
+  permissions: string[]
+  totp_enabled?: boolean
+  extension_id?: number
+  extension?: string
+}
+
+/** Extension self-service login request body. */
+export interface ExtensionLoginRequest {
+  extension: string
+  password: string
+}
+
+/** Extension self-service login response data. */
+export interface ExtensionLoginResponse {
+  role: 'extension'
+  extension_id: number
+  extension: string
+}
+
+/** Profile of the extension of a self-service session. */
+export interface SelfProfile {
+  id: number
+  extension: string
+  name: string
+  email: string
+  dnd: boolean
+  follow_me_enabled: boolean
+  follow_me_numbers: FollowMeNumber[]
+  follow_me_strategy: string
+  follow_me_confirm: boolean
+  updated_at: string
+}
+
+/** Self-service settings update; set at least one field. */
+export interface SelfProfileRequest {
+  dnd?: boolean
+  follow_me_enabled?: boolean
+}
+
+/** TOTP enrollment data: the secret and its otpauth:// URI for a QR code. */
+export interface TOTPEnrollment {
+  secret: string
+  uri: string
+}
+
+/** Newly issued recovery codes, shown once. */
+export interface RecoveryCodes {
+  recovery_codes: string[]
+}
+
+/** Admin user as returned by the API. */
+export interface AdminUser {
+  id: number
   username: string
+  role: AdminRole
+  totp_enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+/** Admin user create/update request body. On update an empty password
+ *  keeps the current one. */
+export interface AdminUserRequest {
+  username: string
+  password?: string
+  role: AdminRole
 }
 
 /** Setup wizard request body. */
@@ -76,6 +153,12 @@ export interface Extension {
   follow_me_confirm: boolean
   recording_mode: string
   max_registrations: number
+  pickup_group: string
+  srtp_mode: string
+  supervisor: boolean
+  class_of_service: string
+  moh_class_id: number | null
+  directory_exclude: boolean
   created_at: string
   updated_at: string
 }
@@ -95,6 +178,12 @@ export interface ExtensionRequest {
   follow_me_confirm?: boolean
   recording_mode?: string
   max_registrations?: number
+  pickup_group?: string
+  srtp_mode?: string
+  supervisor?: boolean
+  class_of_service?: string
+  moh_class_id?: number | null // 0 clears it on update
+  directory_exclude?: boolean
 }
 
 /** Trunk resource. */
@@ -118,6 +207,8 @@ export interface Trunk {
   prefix_strip: number
   prefix_add: string
   priority: number
+  recording_mode: string
+  srtp_mode: string
   status?: string
   created_at: string
   updated_at: string
@@ -154,6 +245,7 @@ export interface TrunkRequest {
   local_host?: string
   codecs?: string[]
   recording_mode?: string
+  srtp_mode?: string
   max_channels?: number
   caller_id_name?: string
   caller_id_num?: string
@@ -162,6 +254,33 @@ export interface TrunkRequest {
   priority?: number
 }
 
+/** Outbound dial plan route. */
+export interface OutboundRoute {
+  id: number
+  name: string
+  patterns: string[]
+  trunk_ids: number[]
+  class: string
+  prefix_strip: number
+  prefix_add: string
+  priority: number
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+/** Outbound route create/update request. */
+export interface OutboundRouteRequest {
+  name: string
+  patterns: string[]
+  trunk_ids: number[]
+  class: string
+  prefix_strip?: number
+  prefix_add?: string
+  priority?: number
+  enabled?: boolean
+}
+
 /** Inbound number resource. */
 export interface InboundNumber {
   id: number
@@ -171,6 +290,7 @@ export interface InboundNumber {
   flow_id: number | null
   flow_entry_node: string
   enabled: boolean
+  moh_class_id: number | null
   created_at: string
   updated_at: string
 }
@@ -183,6 +303,67 @@ export interface InboundNumberRequest {
   flow_id?: number | null
   flow_entry_node?: string
   enabled?: boolean
+  moh_class_id?: number | null
+}
+
+/** Inbound caller blocklist/allowlist entry. */
+export interface CallerFilter {
+  id: number
+  inbound_number_id: number | null
+  list: 'block' | 'allow'
+  match_type: 'exact' | 'prefix' | 'regex' | 'anonymous'
+  pattern: string
+  action: '' | 'reject' | 'voicemail' | 'flow'
+  voicemail_box_id: number | null
+  flow_id: number | null
+  flow_node: string
+  description: string
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+/** Caller filter create/update request. */
+export interface CallerFilterRequest {
+  inbound_number_id?: number | null
+  list: 'block' | 'allow'
+  match_type: 'exact' | 'prefix' | 'regex' | 'anonymous'
+  pattern?: string
+  action?: 'reject' | 'voicemail' | 'flow'
+  voicemail_box_id?: number | null
+  flow_id?: number | null
+  flow_node?: string
+  description?: string
+  enabled?: boolean
+}
+
+/** Call park lot: a park code and a range of orbit numbers. */
+export interface ParkLot {
+  id: number
+  name: string
+  park_code: string
+  orbit_start: number
+  orbit_end: number
+  timeout: number
+  timeout_action: 'return' | 'flow'
+  timeout_flow_id: number | null
+  timeout_flow_node: string
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+/** Park lot create/update request. */
+export interface ParkLotRequest {
+  name: string
+  park_code: string
+  orbit_start: number
+  orbit_end: number
+  timeout?: number
+  timeout_action?: 'return' | 'flow'
+  timeout_flow_id?: number | null
+  timeout_flow_node?: string
+  enabled?: boolean
 }
 
 /** Voicemail box resource. */
@@ -198,6 +379,7 @@ export interface VoicemailBox {
   max_messages: number
   retention_days: number
   notify_extension_id: number | null
+  name_file: string
   created_at: string
   updated_at: string
 }
@@ -231,6 +413,23 @@ export interface VoicemailMessage {
   created_at: string
 }
 
+/** Music on hold class: a playlist of audio prompts. */
+export interface MOHClass {
+  id: number
+  name: string
+  mode: 'sequential' | 'shuffle'
+  prompt_ids: number[]
+  created_at: string
+  updated_at: string
+}
+
+/** Music on hold class create/update request. */
+export interface MOHClassRequest {
+  name: string
+  mode?: 'sequential' | 'shuffle'
+  prompt_ids: number[]
+}
+
 /** Audio prompt resource. */
 export interface AudioPrompt {
   id: number
@@ -249,10 +448,100 @@ export interface CallFlow {
   version: number
   published: boolean
   published_at?: string
+  published_revision?: number
   created_at: string
   updated_at: string
 }
 
+/** Published call flow revision. flow_data is only set on a single revision. */
+export interface CallFlowRevision {
+  id: number
+  flow_id: number
+  revision: number
+  name: string
+  flow_data?: string
+  author: string
+  restored_from?: number
+  published: boolean
+  created_at: string
+}
+
+/** How a node or edge differs between two flow versions. */
+export type FlowChangeKind = 'added' | 'removed' | 'modified'
+
+/** Node change in a flow diff. */
+export interface FlowNodeChange {
+  node_id: string
+  change: FlowChangeKind
+  fields?: string[]
+  from?: Record<string, unknown>
+  to?: Record<string, unknown>
+}
+
+/** Edge change in a flow diff. */
+export interface FlowEdgeChange {
+  edge_id: string
+  change: FlowChangeKind
+  fields?: string[]
+  from?: Record<string, unknown>
+  to?: Record<string, unknown>
+}
+
+/** Flow diff from GET /flows/:id/diff. from and to are revision numbers or "draft". */
+export interface FlowDiff {
+  from: string
+  to: string
+  nodes: FlowNodeChange[]
+  edges: FlowEdgeChange[]
+}
+
+/** Scripted webhook response in a flow simulation. body may be a JSON value or a string. */
+export interface SimulatedWebhook {
+  status?: number
+  body?: unknown
+  timed_out?: boolean
+}
+
+/**
+ * Flow simulation request for POST /flows/:id/simulate. The draft is run
+ * unless a revision is given; dtmf holds the digits entered at each prompt
+ * in turn and answer the extensions and numbers that pick up.
+ */
+export interface FlowSimulateRequest {
+  revision?: number
+  entry_node?: string
+  caller_id_name?: string
+  caller_id_num?: string
+  did?: string
+  time?: string
+  dtmf?: string[]
+  answer?: string[]
+  webhooks?: Record<string, SimulatedWebhook>
+}
+
+/** Terminal outcome of a simulated call. */
+export type SimulationOutcome = 'answered' | 'voicemail' | 'transferred' | 'conference' | 'hangup' | 'ended' | 'dead_end' | 'error'
+
+/** A call action taken by a node during a flow simulation. */
+export interface SimulatedAction {
+  node_id: string
+  action: string
+  detail?: string
+}
+
+/** Flow simulation result from POST /flows/:id/simulate. */
+export interface FlowSimulationResult {
+  revision?: number
+  entry_node: string
+  flow_path: string[]
+  variables: Record<string, string>
+  actions: SimulatedAction[]
+  outcome: SimulationOutcome
+  node_id?: string
+  detail?: string
+  error?: string
+}
+
 /** Call flow create/update request. */
 export interface CallFlowRequest {
   name: string
@@ -325,6 +614,8 @@ export interface TimeSwitchRule {
   start: string
   end: string
   dest_node: string
+  rrule?: string    // iCalendar RRULE, e.g. "FREQ=MONTHLY;BYDAY=1MO"; replaces days
+  dtstart?: string  // "YYYY-MM-DD", required with rrule
 }
 
 /** Holiday or specific-date override. Evaluated before regular rules. */
@@ -357,6 +648,42 @@ export interface TimeSwitchRequest {
   default_dest?: string
 }
 
+/** Calendar imported into a time switch as an override set. */
+export interface TimeSwitchCalendar {
+  id: number
+  time_switch_id: number
+  name: string
+  label: string
+  source: 'upload' | 'url'
+  url: string
+  refresh_minutes: number
+  event_count: number
+  refreshed_at: string | null
+  last_error: string
+  created_at: string
+  updated_at: string
+}
+
+/** Time switch calendar subscribe/update request. */
+export interface TimeSwitchCalendarRequest {
+  name: string
+  label: string
+  url?: string
+  refresh_minutes?: number
+}
+
+/** Edge a time switch takes at a given moment and what selected it. */
+export interface TimeSwitchPreview {
+  edge: string
+  match: 'override' | 'calendar' | 'rule' | 'default'
+  label?: string
+  date?: string
+  calendar?: string
+  event?: string
+  local_time: string
+  timezone: string
+}
+
 /** Conference bridge resource. */
 export interface ConferenceBridge {
   id: number
@@ -408,6 +735,33 @@ export interface CDR {
   recording_file?: string
   flow_path?: string
   hangup_cause: string
+  transferred_to?: string
+  rate?: number | null
+  cost?: number | null
+}
+
+/** Total cost of one extension's rated calls. */
+export interface ExtensionCost {
+  extension: string
+  calls: number
+  billable_sec: number
+  cost: number
+}
+
+/** One prefix of a trunk's rate deck. */
+export interface TrunkRate {
+  id: number
+  trunk_id: number
+  prefix: string
+  description: string
+  rate: number
+  created_at: string
+}
+
+/** Result of importing a trunk rate deck. */
+export interface TrunkRateImport {
+  trunk_id: number
+  imported: number
 }
 
 /** Recording resource (CDR with a recording file). */
@@ -13,6 +13,7 @@ const navSections = [
     items: [
       { to: '/call-flows', label: 'Call Flows', icon: FlowIcon },
       { to: '/inbound-numbers', label: 'Inbound Numbers', icon: PhoneInIcon },
+      { to: '/outbound-routes', label: 'Outbound Routes', icon: PhoneOutIcon },
       { to: '/time-switches', label: 'Time Switches', icon: ClockIcon },
       { to: '/ivr-menus', label: 'IVR Menus', icon: MenuIcon },
       { to: '/ring-groups', label: 'Ring Groups', icon: GroupIcon },
@@ -182,6 +183,15 @@ function PhoneInIcon({ className }: { className?: string }) {
   )
 }
 
+function PhoneOutIcon({ className }: { className?: string }) {
+  return (
+    <svg className={className} viewBox="0 0 20 20" fill="currentColor">
+      <path d="M17.924 2.617a.997.997 0 00-.215-.322l-.004-.004A.997.997 0 0017 2h-4a1 1 0 100 2h1.586l-3.293 3.293a1 1 0 001.414 1.414L16 5.414V7a1 1 0 102 0V3a.997.997 0 00-.076-.383z" />
+      <path d="M2 3a1 1 0 011-1h2.153a1 1 0 01.986.836l.74 4.435a1 1 0 01-.54 1.06l-1.548.773a11.037 11.037 0 006.105 6.105l.774-1.548a1 1 0 011.059-.54l4.435.74a1 1 0 01.836.986V17a1 1 0 01-1 1h-2C7.82 18 2 12.18 2 5V3z" />
+    </svg>
+  )
+}
+
 function ClockIcon({ className }: { className?: string }) {
   return (
     <svg className={className} viewBox="0 0 20 20" fill="currentColor">
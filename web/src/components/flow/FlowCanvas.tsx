@@ -31,12 +31,17 @@ const nodeTypes: NodeTypes = {
   ivr_menu: FlowNodeComponent,
   ring_group: FlowNodeComponent,
   extension: FlowNodeComponent,
+  directory: FlowNodeComponent,
   voicemail: FlowNodeComponent,
   play_message: FlowNodeComponent,
   conference: FlowNodeComponent,
   transfer: FlowNodeComponent,
   hangup: FlowNodeComponent,
   set_caller_id: FlowNodeComponent,
+  set_variable: FlowNodeComponent,
+  condition: FlowNodeComponent,
+  sub_flow: FlowNodeComponent,
+  return: FlowNodeComponent,
 }
 
 const edgeTypes: EdgeTypes = {
@@ -196,7 +201,7 @@ export default function FlowCanvas({ initialNodes, initialEdges, onChange, valid
 
       // Build initial output handles for dynamic nodes
       let outputHandles: { id: string; label: string }[] | undefined
-      if (type === 'time_switch') {
+      if (type === 'time_switch' || type === 'condition') {
         outputHandles = [{ id: 'default', label: 'Default' }]
       } else if (type === 'ivr_menu') {
         outputHandles = [
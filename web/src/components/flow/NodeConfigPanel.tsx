@@ -3,7 +3,7 @@ import type { Node } from '@xyflow/react'
 import type { FlowNodeData } from './FlowNode'
 import { getNodeTypeInfo, NODE_COLORS } from './nodeTypes'
 import EntitySelector from './EntitySelector'
-import { TextInput } from '../FormFields'
+import { TextInput, SelectField } from '../FormFields'
 
 interface Props {
   node: Node
@@ -147,6 +147,130 @@ export default function NodeConfigPanel({ node, onUpdate, onClose }: Props) {
           />
         )}
 
+        {node.type === 'directory' && (
+          <>
+            <SelectField
+              label="Search By"
+              id="directory-search-by"
+              value={(data.config?.search_by as string) ?? 'last'}
+              onChange={(e) => handleConfigChange('search_by', e.currentTarget.value)}
+            >
+              <option value="last">Last name</option>
+              <option value="first">First name</option>
+            </SelectField>
+            <TextInput
+              label="Letters"
+              id="directory-letters"
+              type="number"
+              min={1}
+              max={10}
+              value={(data.config?.letters as number | undefined)?.toString() ?? ''}
+              onChange={(e) => {
+                const v = e.currentTarget.value
+                handleConfigChange('letters', v === '' ? undefined : Number(v))
+              }}
+              placeholder="3 (default)"
+            />
+            <TextInput
+              label="Max Attempts"
+              id="directory-max-attempts"
+              type="number"
+              min={1}
+              value={(data.config?.max_attempts as number | undefined)?.toString() ?? ''}
+              onChange={(e) => {
+                const v = e.currentTarget.value
+                handleConfigChange('max_attempts', v === '' ? undefined : Number(v))
+              }}
+              placeholder="3 (default)"
+            />
+            <TextInput
+              label="Ring Timeout (seconds)"
+              id="directory-ring-timeout"
+              type="number"
+              min={1}
+              value={(data.config?.ring_timeout as number | undefined)?.toString() ?? ''}
+              onChange={(e) => {
+                const v = e.currentTarget.value
+                handleConfigChange('ring_timeout', v === '' ? undefined : Number(v))
+              }}
+              placeholder="Extension default"
+            />
+          </>
+        )}
+
+        {node.type === 'set_variable' && (
+          <ExpressionListEditor
+            title="Variables"
+            items={(data.config?.variables as ExpressionItem[]) ?? []}
+            keyField="name"
+            keyPlaceholder="Variable name"
+            addLabel="Add Variable"
+            onChange={(variables) => handleConfigChange('variables', variables)}
+          />
+        )}
+
+        {/* Ordered cases for condition; each case is an output */}
+        {node.type === 'condition' && (
+          <ExpressionListEditor
+            title="Cases"
+            items={(data.config?.cases as ExpressionItem[]) ?? []}
+            keyField="label"
+            keyPlaceholder="Case label"
+            addLabel="Add Case"
+            onChange={(cases) =>
+              onUpdate(node.id, {
+                config: { ...data.config, cases },
+                outputHandles: [
+                  ...cases.filter((c) => c.label).map((c) => ({ id: c.label as string, label: c.label as string })),
+                  { id: 'default', label: 'Default' },
+                ],
+              })
+            }
+          />
+        )}
+
+        {/* Target flow for sub_flow; each exit its Return nodes use is an output */}
+        {node.type === 'sub_flow' && (
+          <>
+            <TextInput
+              label="Flow ID"
+              id="sub-flow-id"
+              type="number"
+              min={1}
+              value={(data.config?.flow_id as number | undefined)?.toString() ?? ''}
+              onChange={(e) => {
+                const v = e.currentTarget.value
+                handleConfigChange('flow_id', v === '' ? undefined : Number(v))
+              }}
+              placeholder="Published flow to enter"
+            />
+            <TextInput
+              label="Entry Node"
+              id="sub-flow-entry"
+              value={(data.config?.entry_node as string) ?? ''}
+              onChange={(e) => handleConfigChange('entry_node', e.currentTarget.value)}
+              placeholder="Node ID in that flow"
+            />
+            <DynamicOutputEditor
+              handles={data.outputHandles ?? []}
+              onAdd={addOutputHandle}
+              onRemove={removeOutputHandle}
+              addLabel="Add Exit"
+              idPrefix=""
+            />
+          </>
+        )}
+
+        {node.type === 'return' && (
+          <TextInput
+            label="Exit"
+            id="return-exit"
+            value={(data.config?.exit as string) ?? ''}
+            onChange={(e) => handleConfigChange('exit', e.currentTarget.value.trim().toLowerCase().replace(/[^a-z0-9]/g, '_'))}
+            placeholder="e.g. done"
+          />
+        )}
+
         {/* Dynamic outputs for time_switch */}
         {node.type === 'time_switch' && (
           <DynamicOutputEditor
@@ -260,3 +384,70 @@ function DynamicOutputEditor({
     </div>
   )
 }
+
+/** A named expression: a set_variable assignment or a condition case. */
+type ExpressionItem = { name?: string; label?: string; expression: string }
+
+/** Editor for an ordered list of named expressions. */
+function ExpressionListEditor({
+  title,
+  items,
+  keyField,
+  keyPlaceholder,
+  addLabel,
+  onChange,
+}: {
+  title: string
+  items: ExpressionItem[]
+  keyField: 'name' | 'label'
+  keyPlaceholder: string
+  addLabel: string
+  onChange: (items: ExpressionItem[]) => void
+}) {
+  function update(index: number, patch: Partial<ExpressionItem>) {
+    onChange(items.map((item, i) => (i === index ? { ...item, ...patch } : item)))
+  }
+
+  const inputClass =
+    'w-full text-sm rounded border border-gray-300 px-2 py-1 focus:border-blue-500 focus:outline-none focus:ring-1 focus:ring-blue-500'
+
+  return (
+    <div className="space-y-2">
+      <p className="text-xs font-medium text-gray-500 uppercase tracking-wider">{title}</p>
+      {items.map((item, i) => (
+        <div key={i} className="space-y-1 rounded border border-gray-200 p-2">
+          <div className="flex gap-2">
+            <input
+              type="text"
+              value={item[keyField] ?? ''}
+              onChange={(e) => update(i, { [keyField]: e.target.value })}
+              placeholder={keyPlaceholder}
+              className={inputClass}
+            />
+            <button
+              type="button"
+              onClick={() => onChange(items.filter((_, j) => j !== i))}
+              className="text-xs text-red-500 hover:text-red-700"
+            >
+              Remove
+            </button>
+          </div>
+          <input
+            type="text"
+            value={item.expression}
+            onChange={(e) => update(i, { expression: e.target.value })}
+            placeholder='e.g. tier == "gold"'
+            className={`${inputClass} font-mono`}
+          />
+        </div>
+      ))}
+      <button
+        type="button"
+        onClick={() => onChange([...items, { [keyField]: '', expression: '' }])}
+        className="text-xs text-blue-600 hover:text-blue-800 font-medium"
+      >
+        {addLabel}
+      </button>
+    </div>
+  )
+}
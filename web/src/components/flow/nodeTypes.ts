@@ -72,6 +72,19 @@ export const NODE_TYPES: NodeTypeInfo[] = [
     iconPath: 'M10 9a3 3 0 100-6 3 3 0 000 6zm-7 9a7 7 0 1114 0H3z',
     color: 'sky',
   },
+  {
+    type: 'directory',
+    label: 'Directory',
+    description: 'Dial by name — spell a first or last name on the keypad',
+    outputs: 3,
+    outputHandles: [
+      { id: 'answered', label: 'Answered' },
+      { id: 'no_answer', label: 'No Answer' },
+      { id: 'not_found', label: 'Not Found' },
+    ],
+    iconPath: 'M9 2a1 1 0 000 2h2a1 1 0 100-2H9zM4 5a2 2 0 012-2 3 3 0 003 3h2a3 3 0 003-3 2 2 0 012 2v11a2 2 0 01-2 2H6a2 2 0 01-2-2V5zm3 4a1 1 0 000 2h6a1 1 0 100-2H7zm0 4a1 1 0 100 2h4a1 1 0 100-2H7z',
+    color: 'sky',
+  },
   {
     type: 'voicemail',
     label: 'Voicemail',
@@ -126,6 +139,39 @@ export const NODE_TYPES: NodeTypeInfo[] = [
     iconPath: 'M17.414 2.586a2 2 0 00-2.828 0L7 10.172V13h2.828l7.586-7.586a2 2 0 000-2.828z M2 6a2 2 0 012-2h4a1 1 0 010 2H4v10h10v-4a1 1 0 112 0v4a2 2 0 01-2 2H4a2 2 0 01-2-2V6z',
     color: 'pink',
   },
+  {
+    type: 'set_variable',
+    label: 'Set Variable',
+    description: 'Set flow variables from expressions',
+    outputs: 1,
+    outputHandles: [{ id: 'next', label: 'Next' }],
+    iconPath: 'M4 4a2 2 0 012-2h8a2 2 0 012 2v12a2 2 0 01-2 2H6a2 2 0 01-2-2V4zm3 3a1 1 0 000 2h6a1 1 0 100-2H7zm0 4a1 1 0 100 2h6a1 1 0 100-2H7z',
+    color: 'slate',
+  },
+  {
+    type: 'condition',
+    label: 'Condition',
+    description: 'Branch on expressions, first matching case wins',
+    outputs: 'dynamic',
+    iconPath: 'M5 3a2 2 0 100 4 2 2 0 000-4zm1 5.874A4.002 4.002 0 0010 12h1.126a2 2 0 110 2H10a6 6 0 01-4-1.528V17a1 1 0 11-2 0V8.874a2 2 0 012 0zM15 3a2 2 0 100 4 2 2 0 000-4z',
+    color: 'lime',
+  },
+  {
+    type: 'sub_flow',
+    label: 'Sub-Flow',
+    description: 'Enter another published flow, continuing on the exit it returns',
+    outputs: 'dynamic',
+    iconPath: 'M3 4a1 1 0 011-1h5a1 1 0 010 2H5v10h4a1 1 0 110 2H4a1 1 0 01-1-1V4zm9.293 2.293a1 1 0 011.414 0l3 3a1 1 0 010 1.414l-3 3a1 1 0 01-1.414-1.414L13.586 11H8a1 1 0 110-2h5.586l-1.293-1.293a1 1 0 010-1.414z',
+    color: 'fuchsia',
+  },
+  {
+    type: 'return',
+    label: 'Return',
+    description: 'Leave a sub-flow on a named exit',
+    outputs: 0,
+    iconPath: 'M7.707 3.293a1 1 0 010 1.414L5.414 7H11a7 7 0 017 7v2a1 1 0 11-2 0v-2a5 5 0 00-5-5H5.414l2.293 2.293a1 1 0 11-1.414 1.414l-4-4a1 1 0 010-1.414l4-4a1 1 0 011.414 0z',
+    color: 'fuchsia',
+  },
 ]
 
 /** Look up node type info by type string. */
@@ -146,4 +192,7 @@ export const NODE_COLORS: Record<string, { bg: string; border: string; text: str
   cyan:    { bg: 'bg-cyan-50',    border: 'border-cyan-300',    text: 'text-cyan-700',    handle: 'bg-cyan-500' },
   red:     { bg: 'bg-red-50',     border: 'border-red-300',     text: 'text-red-700',     handle: 'bg-red-500' },
   pink:    { bg: 'bg-pink-50',    border: 'border-pink-300',    text: 'text-pink-700',    handle: 'bg-pink-500' },
+  slate:   { bg: 'bg-slate-50',   border: 'border-slate-300',   text: 'text-slate-700',   handle: 'bg-slate-500' },
+  lime:    { bg: 'bg-lime-50',    border: 'border-lime-300',    text: 'text-lime-700',    handle: 'bg-lime-500' },
+  fuchsia: { bg: 'bg-fuchsia-50', border: 'border-fuchsia-300', text: 'text-fuchsia-700', handle: 'bg-fuchsia-500' },
 }
@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react'
-import { listCDRs, buildExportURL } from '../api'
-import type { CDR } from '../api'
+import { listCDRs, listCDRCosts, buildExportURL } from '../api'
+import type { CDR, ExtensionCost } from '../api'
 import DataTable, { type Column } from '../components/DataTable'
 
 const PAGE_SIZE = 20
@@ -10,6 +10,7 @@ export default function CallHistory() {
   const [total, setTotal] = useState(0)
   const [offset, setOffset] = useState(0)
   const [loading, setLoading] = useState(true)
+  const [costs, setCosts] = useState<ExtensionCost[]>([])
 
   // Filter state.
   const [search, setSearch] = useState('')
@@ -19,13 +20,19 @@ export default function CallHistory() {
 
   function load(newOffset: number) {
     setLoading(true)
-    listCDRs({
-      limit: PAGE_SIZE,
-      offset: newOffset,
+    const filters = {
       search: search || undefined,
       direction: direction || undefined,
       start_date: startDate || undefined,
       end_date: endDate || undefined,
+    }
+    listCDRCosts(filters)
+      .then(setCosts)
+      .catch(() => setCosts([]))
+    listCDRs({
+      limit: PAGE_SIZE,
+      offset: newOffset,
+      ...filters,
     })
       .then((res) => {
         setCdrs(res.items)
@@ -64,6 +71,11 @@ export default function CallHistory() {
     return `${m}:${s.toString().padStart(2, '0')}`
   }
 
+  function formatCost(cost?: number | null): string {
+    if (cost === undefined || cost === null) return '—'
+    return cost.toFixed(4)
+  }
+
   function formatTime(iso: string): string {
     return new Date(iso).toLocaleString()
   }
@@ -106,6 +118,15 @@ export default function CallHistory() {
       header: 'Duration',
       render: (r) => formatDuration(r.duration),
     },
+    {
+      key: 'cost',
+      header: 'Cost',
+      render: (r) => (
+        <span className="whitespace-nowrap" title={r.rate != null ? `${r.rate}/min` : undefined}>
+          {formatCost(r.cost)}
+        </span>
+      ),
+    },
     {
       key: 'disposition',
       header: 'Status',
@@ -174,6 +195,8 @@ export default function CallHistory() {
             <option value="inbound">Inbound</option>
             <option value="outbound">Outbound</option>
             <option value="internal">Internal</option>
+            <option value="originate">Originate</option>
+            <option value="flow">Flow</option>
           </select>
         </div>
         <div>
@@ -244,6 +267,34 @@ export default function CallHistory() {
           emptyMessage="No call records found."
         />
       )}
+
+      {costs.length > 0 && (
+        <div className="mt-8">
+          <h2 className="text-lg font-semibold text-gray-900 mb-3">Cost by Extension</h2>
+          <div className="overflow-hidden rounded-lg border border-gray-200 bg-white">
+            <table className="min-w-full divide-y divide-gray-200 text-sm">
+              <thead className="bg-gray-50">
+                <tr>
+                  <th className="px-4 py-2 text-left font-medium text-gray-500">Extension</th>
+                  <th className="px-4 py-2 text-right font-medium text-gray-500">Calls</th>
+                  <th className="px-4 py-2 text-right font-medium text-gray-500">Billable</th>
+                  <th className="px-4 py-2 text-right font-medium text-gray-500">Cost</th>
+                </tr>
+              </thead>
+              <tbody className="divide-y divide-gray-100">
+                {costs.map((c) => (
+                  <tr key={c.extension}>
+                    <td className="px-4 py-2 font-medium text-gray-900">{c.extension || '—'}</td>
+                    <td className="px-4 py-2 text-right text-gray-700">{c.calls}</td>
+                    <td className="px-4 py-2 text-right text-gray-700">{formatDuration(c.billable_sec)}</td>
+                    <td className="px-4 py-2 text-right text-gray-700">{formatCost(c.cost)}</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        </div>
+      )}
     </div>
   )
 }
@@ -253,11 +304,15 @@ function DirectionBadge({ direction }: { direction: string }) {
     inbound: 'bg-blue-50 text-blue-700',
     outbound: 'bg-emerald-50 text-emerald-700',
     internal: 'bg-gray-100 text-gray-700',
+    originate: 'bg-violet-50 text-violet-700',
+    flow: 'bg-amber-50 text-amber-700',
   }
   const labels: Record<string, string> = {
     inbound: 'In',
     outbound: 'Out',
     internal: 'Int',
+    originate: 'C2C',
+    flow: 'Flow',
   }
   return (
     <span
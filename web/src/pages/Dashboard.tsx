@@ -1,5 +1,6 @@
 import { useState, useEffect } from 'react'
 import { get } from '../api/client'
+import { subscribeEvents } from '../api/events'
 
 interface DashboardStats {
   active_calls: number
@@ -33,18 +34,27 @@ export default function Dashboard() {
 
   useEffect(() => {
     let cancelled = false
-    get<DashboardStats>('/dashboard/stats')
-      .then((data) => {
-        if (!cancelled) setStats(data)
-      })
-      .catch(() => {
-        // API not available yet — show zeros
-      })
-      .finally(() => {
-        if (!cancelled) setLoading(false)
-      })
+    function load() {
+      get<DashboardStats>('/dashboard/stats')
+        .then((data) => {
+          if (!cancelled) setStats(data)
+        })
+        .catch(() => {
+          // API not available yet — show zeros
+        })
+        .finally(() => {
+          if (!cancelled) setLoading(false)
+        })
+    }
+    load()
+
+    // Refresh when calls start or end and devices come and go.
+    const unsubscribe = subscribeEvents(['calls', 'registrations'], (event) => {
+      if (event.type !== 'call.ringing_stopped') load()
+    })
     return () => {
       cancelled = true
+      unsubscribe()
     }
   }, [])
 
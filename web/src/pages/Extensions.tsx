@@ -33,6 +33,10 @@ export default function Extensions() {
       follow_me_confirm: false,
       recording_mode: 'off',
       max_registrations: 5,
+      srtp_mode: 'optional',
+      supervisor: false,
+      directory_exclude: false,
+      class_of_service: 'international',
     }
   }
 
@@ -94,6 +98,10 @@ export default function Extensions() {
       follow_me_confirm: ext.follow_me_confirm ?? false,
       recording_mode: ext.recording_mode,
       max_registrations: ext.max_registrations,
+      srtp_mode: ext.srtp_mode || 'optional',
+      supervisor: ext.supervisor ?? false,
+      directory_exclude: ext.directory_exclude ?? false,
+      class_of_service: ext.class_of_service || 'international',
     })
     setEditing(ext)
     setCreating(true)
@@ -284,6 +292,30 @@ export default function Extensions() {
             <option value="on_demand">On Demand</option>
           </SelectField>
 
+          <SelectField
+            label="Media Encryption (SRTP)"
+            id="srtp_mode"
+            value={form.srtp_mode ?? 'optional'}
+            onChange={(e) => setForm({ ...form, srtp_mode: e.currentTarget.value })}
+          >
+            <option value="off">Off</option>
+            <option value="optional">Optional</option>
+            <option value="required">Required</option>
+          </SelectField>
+
+          <SelectField
+            label="Outbound Calling"
+            id="class_of_service"
+            value={form.class_of_service ?? 'international'}
+            onChange={(e) => setForm({ ...form, class_of_service: e.currentTarget.value })}
+          >
+            <option value="internal">Internal only</option>
+            <option value="local">Local</option>
+            <option value="national">National</option>
+            <option value="international">International</option>
+            <option value="premium">Premium</option>
+          </SelectField>
+
           <div className="flex gap-6">
             <Toggle
               label="Do Not Disturb"
@@ -295,6 +327,16 @@ export default function Extensions() {
               checked={form.follow_me_enabled ?? false}
               onChange={(v) => setForm({ ...form, follow_me_enabled: v })}
             />
+            <Toggle
+              label="Supervisor"
+              checked={form.supervisor ?? false}
+              onChange={(v) => setForm({ ...form, supervisor: v })}
+            />
+            <Toggle
+              label="Hide from Directory"
+              checked={form.directory_exclude ?? false}
+              onChange={(v) => setForm({ ...form, directory_exclude: v })}
+            />
           </div>
 
           {form.follow_me_enabled && (
@@ -7,6 +7,8 @@ export default function Login() {
   const navigate = useNavigate()
   const [username, setUsername] = useState('')
   const [password, setPassword] = useState('')
+  const [totpRequired, setTotpRequired] = useState(false)
+  const [code, setCode] = useState('')
   const [error, setError] = useState('')
   const [loading, setLoading] = useState(false)
   const [checking, setChecking] = useState(true)
@@ -37,7 +39,18 @@ export default function Login() {
     setLoading(true)
 
     try {
-      await login({ username, password })
+      // A 6-digit code is from the authenticator app; anything else is
+      // taken as a recovery code.
+      const second = totpRequired
+        ? /^\d{6}$/.test(code.trim())
+          ? { totp_code: code.trim() }
+          : { recovery_code: code.trim() }
+        : {}
+      const res = await login({ username, password, ...second })
+      if (res.totp_required) {
+        setTotpRequired(true)
+        return
+      }
       navigate('/', { replace: true })
     } catch (err) {
       if (err instanceof ApiError) {
@@ -110,12 +123,34 @@ export default function Login() {
             />
           </div>
 
+          {totpRequired && (
+            <div>
+              <label htmlFor="code" className="block text-sm font-medium text-gray-700 mb-1">
+                Authentication code
+              </label>
+              <input
+                id="code"
+                type="text"
+                required
+                autoComplete="one-time-code"
+                autoFocus
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                className="block w-full rounded-md border border-gray-300 px-3 py-2 text-sm text-gray-900 placeholder-gray-400 focus:border-blue-500 focus:outline-none focus:ring-1 focus:ring-blue-500"
+                placeholder="123456"
+              />
+              <p className="mt-1 text-xs text-gray-500">
+                Enter the code from your authenticator app, or one of your recovery codes.
+              </p>
+            </div>
+          )}
+
           <button
             type="submit"
             disabled={loading}
             className="w-full rounded-md bg-blue-600 px-3 py-2 text-sm font-medium text-white hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:ring-offset-2 disabled:opacity-50 disabled:cursor-not-allowed transition-colors"
           >
-            {loading ? 'Signing in…' : 'Sign in'}
+            {loading ? 'Signing in…' : totpRequired ? 'Verify' : 'Sign in'}
           </button>
         </form>
       </div>
@@ -0,0 +1,698 @@
+import { useState, useEffect, type FormEvent } from 'react'
+import {
+  listOutboundRoutes,
+  createOutboundRoute,
+  updateOutboundRoute,
+  deleteOutboundRoute,
+  listTrunks,
+  listTrunkRates,
+  importTrunkRates,
+  deleteTrunkRates,
+  listExtensions,
+  updateExtension,
+  ApiError,
+} from '../api'
+import type { OutboundRoute, OutboundRouteRequest, Trunk, TrunkRate, Extension, ExtensionRequest } from '../api'
+import DataTable, { type Column } from '../components/DataTable'
+import { TextInput, NumberInput, SelectField, Toggle } from '../components/FormFields'
+
+type Tab = 'routes' | 'cos' | 'rates'
+
+const PAGE_SIZE = 20
+
+/** Number classes a route can be assigned, with their labels. */
+const ROUTE_CLASSES: Record<string, string> = {
+  emergency: 'Emergency',
+  local: 'Local',
+  national: 'National',
+  international: 'International',
+  premium: 'Premium',
+}
+
+/** Classes of service an extension can have, narrowest first. Each
+ *  permits the route classes of every level before it; emergency routes
+ *  are always permitted. */
+const CLASSES_OF_SERVICE: Record<string, string> = {
+  internal: 'Internal only',
+  local: 'Local',
+  national: 'National',
+  international: 'International',
+  premium: 'Premium',
+}
+
+const tabs: { id: Tab; label: string }[] = [
+  { id: 'routes', label: 'Routes' },
+  { id: 'cos', label: 'Class of Service' },
+  { id: 'rates', label: 'LCR Rates' },
+]
+
+export default function OutboundRoutes() {
+  const [tab, setTab] = useState<Tab>('routes')
+  const [trunks, setTrunks] = useState<Trunk[]>([])
+
+  useEffect(() => {
+    listTrunks({ limit: 100 })
+      .then((res) => setTrunks(res.items))
+      .catch(() => {})
+  }, [])
+
+  return (
+    <div>
+      <div className="mb-6">
+        <h1 className="text-2xl font-bold text-gray-900">Outbound Routes</h1>
+        <p className="mt-1 text-sm text-gray-500">
+          Match dialled numbers to trunks, control which extensions may call them, and rank trunks by cost.
+        </p>
+      </div>
+
+      <div className="mb-6 border-b border-gray-200">
+        <nav className="-mb-px flex gap-6">
+          {tabs.map((t) => (
+            <button
+              key={t.id}
+              type="button"
+              onClick={() => setTab(t.id)}
+              className={`border-b-2 px-1 pb-2 text-sm font-medium transition-colors ${
+                tab === t.id
+                  ? 'border-blue-600 text-blue-600'
+                  : 'border-transparent text-gray-500 hover:border-gray-300 hover:text-gray-700'
+              }`}
+            >
+              {t.label}
+            </button>
+          ))}
+        </nav>
+      </div>
+
+      {tab === 'routes' && <RoutesTab trunks={trunks} />}
+      {tab === 'cos' && <ClassOfServiceTab />}
+      {tab === 'rates' && <RatesTab trunks={trunks} />}
+    </div>
+  )
+}
+
+function RoutesTab({ trunks }: { trunks: Trunk[] }) {
+  const [routes, setRoutes] = useState<OutboundRoute[]>([])
+  const [loading, setLoading] = useState(true)
+  const [editing, setEditing] = useState<OutboundRoute | null>(null)
+  const [creating, setCreating] = useState(false)
+  const [error, setError] = useState('')
+  const [saving, setSaving] = useState(false)
+
+  const [form, setForm] = useState<OutboundRouteRequest>(emptyForm())
+  // Patterns are edited one per line.
+  const [patterns, setPatterns] = useState('')
+
+  function emptyForm(): OutboundRouteRequest {
+    return {
+      name: '',
+      patterns: [],
+      trunk_ids: [],
+      class: 'national',
+      prefix_strip: 0,
+      prefix_add: '',
+      priority: 0,
+      enabled: true,
+    }
+  }
+
+  function load() {
+    setLoading(true)
+    listOutboundRoutes()
+      .then((items) => setRoutes(items))
+      .catch(() => setRoutes([]))
+      .finally(() => setLoading(false))
+  }
+
+  useEffect(() => {
+    load()
+  }, [])
+
+  function openCreate() {
+    setForm(emptyForm())
+    setPatterns('')
+    setEditing(null)
+    setCreating(true)
+    setError('')
+  }
+
+  function openEdit(route: OutboundRoute) {
+    setForm({
+      name: route.name,
+      patterns: route.patterns,
+      trunk_ids: route.trunk_ids,
+      class: route.class,
+      prefix_strip: route.prefix_strip,
+      prefix_add: route.prefix_add,
+      priority: route.priority,
+      enabled: route.enabled,
+    })
+    setPatterns(route.patterns.join('\n'))
+    setEditing(route)
+    setCreating(true)
+    setError('')
+  }
+
+  function closeForm() {
+    setCreating(false)
+    setEditing(null)
+    setError('')
+  }
+
+  async function handleSubmit(e: FormEvent) {
+    e.preventDefault()
+    setError('')
+    setSaving(true)
+
+    const data = {
+      ...form,
+      patterns: patterns.split('\n').map((p) => p.trim()).filter((p) => p !== ''),
+    }
+    try {
+      if (editing) {
+        await updateOutboundRoute(editing.id, data)
+      } else {
+        await createOutboundRoute(data)
+      }
+      closeForm()
+      load()
+    } catch (err) {
+      setError(err instanceof ApiError ? err.message : 'unable to save outbound route')
+    } finally {
+      setSaving(false)
+    }
+  }
+
+  async function handleDelete(route: OutboundRoute) {
+    if (!confirm(`Delete outbound route "${route.name}"?`)) return
+    try {
+      await deleteOutboundRoute(route.id)
+      load()
+    } catch (err) {
+      alert(err instanceof ApiError ? err.message : 'unable to delete outbound route')
+    }
+  }
+
+  function trunkName(trunkId: number): string {
+    const t = trunks.find((tr) => tr.id === trunkId)
+    return t ? t.name : `#${trunkId}`
+  }
+
+  // Move the trunk at index i of the route's trunk list by delta places.
+  function moveTrunk(i: number, delta: number) {
+    const ids = [...form.trunk_ids]
+    const j = i + delta
+    if (j < 0 || j >= ids.length) return
+    ;[ids[i], ids[j]] = [ids[j], ids[i]]
+    setForm({ ...form, trunk_ids: ids })
+  }
+
+  const columns: Column<OutboundRoute>[] = [
+    { key: 'priority', header: 'Priority', className: 'w-20', render: (r) => r.priority },
+    { key: 'name', header: 'Name', render: (r) => r.name },
+    {
+      key: 'patterns',
+      header: 'Patterns',
+      render: (r) => <span className="font-mono text-xs">{r.patterns.join(', ')}</span>,
+    },
+    { key: 'class', header: 'Class', render: (r) => ROUTE_CLASSES[r.class] ?? r.class },
+    { key: 'trunks', header: 'Trunks', render: (r) => r.trunk_ids.map(trunkName).join(' → ') },
+    {
+      key: 'enabled',
+      header: 'Status',
+      render: (r) => (
+        <span className={`inline-flex items-center rounded-full px-2 py-0.5 text-xs font-medium ${r.enabled ? 'bg-green-50 text-green-700' : 'bg-gray-100 text-gray-500'}`}>
+          {r.enabled ? 'Enabled' : 'Disabled'}
+        </span>
+      ),
+    },
+    {
+      key: 'actions',
+      header: '',
+      className: 'w-24',
+      render: (r) => (
+        <div className="flex gap-2">
+          <button
+            type="button"
+            onClick={(e) => { e.stopPropagation(); openEdit(r) }}
+            className="text-sm text-blue-600 hover:text-blue-800"
+          >
+            Edit
+          </button>
+          <button
+            type="button"
+            onClick={(e) => { e.stopPropagation(); handleDelete(r) }}
+            className="text-sm text-red-600 hover:text-red-800"
+          >
+            Delete
+          </button>
+        </div>
+      ),
+    },
+  ]
+
+  if (creating) {
+    const unused = trunks.filter((t) => !form.trunk_ids.includes(t.id))
+    return (
+      <div>
+        <div className="flex items-center justify-between mb-6">
+          <h2 className="text-lg font-semibold text-gray-900">
+            {editing ? 'Edit Outbound Route' : 'New Outbound Route'}
+          </h2>
+          <button
+            type="button"
+            onClick={closeForm}
+            className="text-sm text-gray-500 hover:text-gray-700"
+          >
+            Cancel
+          </button>
+        </div>
+
+        <form onSubmit={handleSubmit} className="max-w-lg space-y-4">
+          {error && (
+            <div className="rounded-md bg-red-50 border border-red-200 px-3 py-2">
+              <p className="text-sm text-red-700">{error}</p>
+            </div>
+          )}
+
+          <div className="grid grid-cols-2 gap-4">
+            <TextInput
+              label="Name"
+              id="name"
+              required
+              value={form.name}
+              onChange={(e) => setForm({ ...form, name: e.currentTarget.value })}
+              placeholder="National"
+            />
+            <SelectField
+              label="Class"
+              id="class"
+              value={form.class}
+              onChange={(e) => setForm({ ...form, class: e.currentTarget.value })}
+            >
+              {Object.entries(ROUTE_CLASSES).map(([value, label]) => (
+                <option key={value} value={value}>{label}</option>
+              ))}
+            </SelectField>
+          </div>
+
+          <div>
+            <label htmlFor="patterns" className="block text-sm font-medium text-gray-700 mb-1">
+              Dial Patterns (one per line)
+            </label>
+            <textarea
+              id="patterns"
+              rows={4}
+              required
+              value={patterns}
+              onChange={(e) => setPatterns(e.currentTarget.value)}
+              placeholder={'_1NXXNXXXXXX\n_0[2-9]XXXXXXXX'}
+              className="block w-full rounded-md border border-gray-300 px-3 py-2 font-mono text-sm text-gray-900 placeholder-gray-400 focus:border-blue-500 focus:outline-none focus:ring-1 focus:ring-blue-500"
+            />
+            <p className="mt-1 text-xs text-gray-400">
+              Asterisk-style patterns start with _ (X any digit, Z 1-9, N 2-9, [15-7] a set, . one or more); a regex is written /like this/; anything else matches exactly.
+            </p>
+          </div>
+
+          <div>
+            <span className="block text-sm font-medium text-gray-700 mb-1">Trunks (tried in order)</span>
+            {form.trunk_ids.length === 0 ? (
+              <p className="text-sm text-gray-400">No trunks selected.</p>
+            ) : (
+              <ol className="divide-y divide-gray-100 rounded-md border border-gray-200">
+                {form.trunk_ids.map((id, i) => (
+                  <li key={id} className="flex items-center justify-between px-3 py-2 text-sm">
+                    <span>
+                      <span className="mr-2 text-gray-400">{i + 1}.</span>
+                      {trunkName(id)}
+                    </span>
+                    <span className="flex gap-2">
+                      <button
+                        type="button"
+                        onClick={() => moveTrunk(i, -1)}
+                        disabled={i === 0}
+                        className="text-gray-500 hover:text-gray-700 disabled:opacity-30"
+                        title="Move up"
+                      >
+                        ↑
+                      </button>
+                      <button
+                        type="button"
+                        onClick={() => moveTrunk(i, 1)}
+                        disabled={i === form.trunk_ids.length - 1}
+                        className="text-gray-500 hover:text-gray-700 disabled:opacity-30"
+                        title="Move down"
+                      >
+                        ↓
+                      </button>
+                      <button
+                        type="button"
+                        onClick={() => setForm({ ...form, trunk_ids: form.trunk_ids.filter((t) => t !== id) })}
+                        className="text-red-600 hover:text-red-800"
+                      >
+                        Remove
+                      </button>
+                    </span>
+                  </li>
+                ))}
+              </ol>
+            )}
+            {unused.length > 0 && (
+              <select
+                aria-label="Add trunk"
+                value=""
+                onChange={(e) => setForm({ ...form, trunk_ids: [...form.trunk_ids, Number(e.currentTarget.value)] })}
+                className="mt-2 block w-full rounded-md border border-gray-300 px-3 py-2 text-sm text-gray-900 focus:border-blue-500 focus:outline-none focus:ring-1 focus:ring-blue-500"
+              >
+                <option value="">Add trunk...</option>
+                {unused.map((t) => (
+                  <option key={t.id} value={t.id}>{t.name}</option>
+                ))}
+              </select>
+            )}
+            <p className="mt-1 text-xs text-gray-400">Trunks with a rate for the dialled number are tried cheapest first.</p>
+          </div>
+
+          <div className="grid grid-cols-3 gap-4">
+            <NumberInput
+              label="Strip Digits"
+              id="prefix_strip"
+              min={0}
+              max={20}
+              value={form.prefix_strip ?? 0}
+              onChange={(e) => setForm({ ...form, prefix_strip: Number(e.currentTarget.value) })}
+            />
+            <TextInput
+              label="Add Prefix"
+              id="prefix_add"
+              value={form.prefix_add ?? ''}
+              onChange={(e) => setForm({ ...form, prefix_add: e.currentTarget.value })}
+              placeholder="+1"
+            />
+            <NumberInput
+              label="Priority"
+              id="priority"
+              min={0}
+              max={1000}
+              value={form.priority ?? 0}
+              onChange={(e) => setForm({ ...form, priority: Number(e.currentTarget.value) })}
+            />
+          </div>
+          <p className="text-xs text-gray-400">Routes are matched in priority order, lowest first.</p>
+
+          <Toggle
+            label="Enabled"
+            checked={form.enabled ?? true}
+            onChange={(v) => setForm({ ...form, enabled: v })}
+          />
+
+          <div className="pt-4 border-t border-gray-100">
+            <button
+              type="submit"
+              disabled={saving}
+              className="rounded-md bg-blue-600 px-4 py-2 text-sm font-medium text-white hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:ring-offset-2 disabled:opacity-50 disabled:cursor-not-allowed transition-colors"
+            >
+              {saving ? 'Saving...' : editing ? 'Update Route' : 'Create Route'}
+            </button>
+          </div>
+        </form>
+      </div>
+    )
+  }
+
+  return (
+    <div>
+      <div className="flex justify-end mb-4">
+        <button
+          type="button"
+          onClick={openCreate}
+          className="rounded-md bg-blue-600 px-4 py-2 text-sm font-medium text-white hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:ring-offset-2 transition-colors"
+        >
+          Add Route
+        </button>
+      </div>
+
+      {loading ? (
+        <p className="text-sm text-gray-400">Loading...</p>
+      ) : (
+        <DataTable
+          columns={columns}
+          rows={routes}
+          keyFn={(r) => r.id}
+          total={routes.length}
+          limit={routes.length || 1}
+          offset={0}
+          onPageChange={() => {}}
+          onRowClick={openEdit}
+          emptyMessage="No outbound routes configured yet."
+        />
+      )}
+    </div>
+  )
+}
+
+// extensionRequest returns the update request that changes an extension's
+// class of service. Extensions are updated whole, so its other settings
+// are sent as they are; the SIP password is left unchanged.
+function extensionRequest(ext: Extension, classOfService: string): ExtensionRequest {
+  return {
+    extension: ext.extension,
+    name: ext.name,
+    email: ext.email,
+    sip_username: ext.sip_username,
+    sip_password: '',
+    ring_timeout: ext.ring_timeout,
+    dnd: ext.dnd,
+    follow_me_enabled: ext.follow_me_enabled,
+    follow_me_numbers: ext.follow_me_numbers ?? [],
+    follow_me_strategy: ext.follow_me_strategy || 'sequential',
+    follow_me_confirm: ext.follow_me_confirm ?? false,
+    recording_mode: ext.recording_mode,
+    max_registrations: ext.max_registrations,
+    srtp_mode: ext.srtp_mode || 'optional',
+    supervisor: ext.supervisor ?? false,
+    directory_exclude: ext.directory_exclude ?? false,
+    class_of_service: classOfService,
+  }
+}
+
+function ClassOfServiceTab() {
+  const [extensions, setExtensions] = useState<Extension[]>([])
+  const [total, setTotal] = useState(0)
+  const [offset, setOffset] = useState(0)
+  const [loading, setLoading] = useState(true)
+  const [savingId, setSavingId] = useState<number | null>(null)
+
+  function load(newOffset: number) {
+    setLoading(true)
+    listExtensions({ limit: PAGE_SIZE, offset: newOffset })
+      .then((res) => {
+        setExtensions(res.items)
+        setTotal(res.total)
+        setOffset(newOffset)
+      })
+      .catch(() => {
+        setExtensions([])
+        setTotal(0)
+      })
+      .finally(() => setLoading(false))
+  }
+
+  useEffect(() => {
+    load(0)
+  }, [])
+
+  async function changeClass(ext: Extension, classOfService: string) {
+    setSavingId(ext.id)
+    try {
+      const updated = await updateExtension(ext.id, extensionRequest(ext, classOfService))
+      setExtensions((prev) => prev.map((e) => (e.id === updated.id ? updated : e)))
+    } catch (err) {
+      alert(err instanceof ApiError ? err.message : 'unable to update class of service')
+    } finally {
+      setSavingId(null)
+    }
+  }
+
+  const columns: Column<Extension>[] = [
+    { key: 'extension', header: 'Extension', render: (r) => r.extension },
+    { key: 'name', header: 'Name', render: (r) => r.name || '—' },
+    {
+      key: 'class_of_service',
+      header: 'Outbound Calling',
+      className: 'w-56',
+      render: (r) => (
+        <select
+          aria-label={`Class of service for ${r.extension}`}
+          value={r.class_of_service || 'international'}
+          disabled={savingId === r.id}
+          onChange={(e) => changeClass(r, e.currentTarget.value)}
+          className="block w-full rounded-md border border-gray-300 px-2 py-1 text-sm text-gray-900 focus:border-blue-500 focus:outline-none focus:ring-1 focus:ring-blue-500 disabled:opacity-50"
+        >
+          {Object.entries(CLASSES_OF_SERVICE).map(([value, label]) => (
+            <option key={value} value={value}>{label}</option>
+          ))}
+        </select>
+      ),
+    },
+  ]
+
+  return (
+    <div>
+      <p className="mb-4 text-sm text-gray-500">
+        An extension may call routes of its class and every narrower one: Local, then National, International and
+        Premium. Internal only extensions cannot call out. Emergency routes are always allowed.
+      </p>
+
+      {loading ? (
+        <p className="text-sm text-gray-400">Loading...</p>
+      ) : (
+        <DataTable
+          columns={columns}
+          rows={extensions}
+          keyFn={(r) => r.id}
+          total={total}
+          limit={PAGE_SIZE}
+          offset={offset}
+          onPageChange={load}
+          emptyMessage="No extensions configured yet."
+        />
+      )}
+    </div>
+  )
+}
+
+function RatesTab({ trunks }: { trunks: Trunk[] }) {
+  const [trunkId, setTrunkId] = useState<number | null>(null)
+  const [rates, setRates] = useState<TrunkRate[]>([])
+  const [loading, setLoading] = useState(false)
+  const [error, setError] = useState('')
+  const [importing, setImporting] = useState(false)
+
+  const trunk = trunks.find((t) => t.id === trunkId) ?? null
+
+  useEffect(() => {
+    if (trunkId == null && trunks.length > 0) setTrunkId(trunks[0].id)
+  }, [trunks, trunkId])
+
+  function load(id: number) {
+    setLoading(true)
+    setError('')
+    listTrunkRates(id)
+      .then((items) => setRates(items))
+      .catch((err) => {
+        setRates([])
+        setError(err instanceof ApiError ? err.message : 'unable to load rates')
+      })
+      .finally(() => setLoading(false))
+  }
+
+  useEffect(() => {
+    if (trunkId != null) load(trunkId)
+  }, [trunkId])
+
+  // Prompt for a rate deck CSV (prefix,rate[,description] per line) and
+  // replace the trunk's rate deck with it.
+  function handleImport() {
+    if (!trunk) return
+    const input = document.createElement('input')
+    input.type = 'file'
+    input.accept = '.csv,text/csv'
+    input.onchange = async () => {
+      const file = input.files?.[0]
+      if (!file) return
+      setImporting(true)
+      try {
+        const res = await importTrunkRates(trunk.id, file)
+        alert(`Imported ${res.imported} rates for "${trunk.name}".`)
+        load(trunk.id)
+      } catch (err) {
+        alert(err instanceof Error ? err.message : 'unable to import rates')
+      } finally {
+        setImporting(false)
+      }
+    }
+    input.click()
+  }
+
+  async function handleDeleteAll() {
+    if (!trunk) return
+    if (!confirm(`Delete the rate deck of "${trunk.name}"?`)) return
+    try {
+      await deleteTrunkRates(trunk.id)
+      load(trunk.id)
+    } catch (err) {
+      alert(err instanceof ApiError ? err.message : 'unable to delete rates')
+    }
+  }
+
+  const columns: Column<TrunkRate>[] = [
+    { key: 'prefix', header: 'Prefix', render: (r) => <span className="font-mono">{r.prefix}</span> },
+    { key: 'description', header: 'Description', render: (r) => r.description || '—' },
+    { key: 'rate', header: 'Rate / min', className: 'w-32', render: (r) => r.rate.toFixed(4) },
+  ]
+
+  return (
+    <div>
+      <p className="mb-4 text-sm text-gray-500">
+        Each trunk's rate deck prices calls by the longest matching prefix. A route tries the trunks that have a rate
+        for the dialled number cheapest first, then the rest in route order.
+      </p>
+
+      <div className="flex items-end justify-between gap-4 mb-4">
+        <div className="w-64">
+          <SelectField
+            label="Trunk"
+            id="rates_trunk_id"
+            value={trunkId ?? ''}
+            onChange={(e) => setTrunkId(e.currentTarget.value ? Number(e.currentTarget.value) : null)}
+          >
+            {trunks.length === 0 && <option value="">No trunks</option>}
+            {trunks.map((t) => (
+              <option key={t.id} value={t.id}>{t.name}</option>
+            ))}
+          </SelectField>
+        </div>
+        <div className="flex gap-2">
+          <button
+            type="button"
+            onClick={handleDeleteAll}
+            disabled={!trunk || rates.length === 0}
+            className="rounded-md border border-gray-300 px-4 py-2 text-sm font-medium text-red-600 hover:bg-red-50 disabled:opacity-50 disabled:cursor-not-allowed transition-colors"
+          >
+            Delete Rates
+          </button>
+          <button
+            type="button"
+            onClick={handleImport}
+            disabled={!trunk || importing}
+            className="rounded-md bg-blue-600 px-4 py-2 text-sm font-medium text-white hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:ring-offset-2 disabled:opacity-50 disabled:cursor-not-allowed transition-colors"
+          >
+            {importing ? 'Importing...' : 'Import CSV'}
+          </button>
+        </div>
+      </div>
+
+      {error && (
+        <div className="mb-4 rounded-md bg-red-50 border border-red-200 px-3 py-2">
+          <p className="text-sm text-red-700">{error}</p>
+        </div>
+      )}
+
+      {loading ? (
+        <p className="text-sm text-gray-400">Loading...</p>
+      ) : (
+        <DataTable
+          columns={columns}
+          rows={rates}
+          keyFn={(r) => r.id}
+          total={rates.length}
+          limit={rates.length || 1}
+          offset={0}
+          onPageChange={() => {}}
+          emptyMessage="No rates imported for this trunk."
+        />
+      )}
+    </div>
+  )
+}
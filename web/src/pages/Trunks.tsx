@@ -1,5 +1,5 @@
-import { useState, useEffect, useRef, useCallback, type FormEvent } from 'react'
-import { listTrunks, listTrunkStatuses, createTrunk, updateTrunk, deleteTrunk, ApiError } from '../api'
+import { useState, useEffect, useCallback, type FormEvent } from 'react'
+import { listTrunks, listTrunkStatuses, createTrunk, updateTrunk, deleteTrunk, importTrunkRates, subscribeEvents, ApiError } from '../api'
 import type { Trunk, TrunkRequest, TrunkStatusEntry } from '../api'
 import DataTable, { type Column } from '../components/DataTable'
 import { TextInput, NumberInput, SelectField, Toggle } from '../components/FormFields'
@@ -17,7 +17,6 @@ export default function Trunks() {
   const [saving, setSaving] = useState(false)
 
   const [statuses, setStatuses] = useState<Record<number, TrunkStatusEntry>>({})
-  const statusInterval = useRef<ReturnType<typeof setInterval> | null>(null)
 
   const [form, setForm] = useState<TrunkRequest>(emptyForm())
 
@@ -76,11 +75,8 @@ export default function Trunks() {
     load(0)
     fetchStatuses()
 
-    // Poll trunk statuses every 10 seconds while viewing the list.
-    statusInterval.current = setInterval(fetchStatuses, 10000)
-    return () => {
-      if (statusInterval.current) clearInterval(statusInterval.current)
-    }
+    // Refresh statuses when the event stream reports a trunk status change.
+    return subscribeEvents(['trunks'], fetchStatuses)
   }, [fetchStatuses])
 
   function openCreate() {
@@ -153,6 +149,25 @@ export default function Trunks() {
     }
   }
 
+  // Prompt for a rate deck CSV (prefix,rate[,description] per line) and
+  // replace the trunk's rate deck with it.
+  function handleImportRates(trunk: Trunk) {
+    const input = document.createElement('input')
+    input.type = 'file'
+    input.accept = '.csv,text/csv'
+    input.onchange = async () => {
+      const file = input.files?.[0]
+      if (!file) return
+      try {
+        const res = await importTrunkRates(trunk.id, file)
+        alert(`Imported ${res.imported} rates for "${trunk.name}".`)
+      } catch (err) {
+        alert(err instanceof Error ? err.message : 'unable to import rates')
+      }
+    }
+    input.click()
+  }
+
   const columns: Column<Trunk>[] = [
     { key: 'name', header: 'Name', render: (r) => r.name },
     { key: 'type', header: 'Type', render: (r) => r.type === 'register' ? 'Registration' : 'IP Auth' },
@@ -212,7 +227,7 @@ export default function Trunks() {
     {
       key: 'actions',
       header: '',
-      className: 'w-24',
+      className: 'w-36',
       render: (r) => (
         <div className="flex gap-2">
           <button
@@ -222,6 +237,14 @@ export default function Trunks() {
           >
             Edit
           </button>
+          <button
+            type="button"
+            onClick={(e) => { e.stopPropagation(); handleImportRates(r) }}
+            className="text-sm text-blue-600 hover:text-blue-800"
+            title="Import a rate deck CSV for least-cost routing"
+          >
+            Rates
+          </button>
           <button
             type="button"
             onClick={(e) => { e.stopPropagation(); handleDelete(r) }}
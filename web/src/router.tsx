@@ -7,6 +7,7 @@ import Dashboard from './pages/Dashboard'
 import CallFlows from './pages/CallFlows'
 import Trunks from './pages/Trunks'
 import InboundNumbers from './pages/InboundNumbers'
+import OutboundRoutes from './pages/OutboundRoutes'
 import Extensions from './pages/Extensions'
 import VoicemailBoxes from './pages/VoicemailBoxes'
 import RingGroups from './pages/RingGroups'
@@ -32,6 +33,7 @@ const router = createBrowserRouter([
       { path: '/call-flows', element: <CallFlows /> },
       { path: '/trunks', element: <Trunks /> },
       { path: '/inbound-numbers', element: <InboundNumbers /> },
+      { path: '/outbound-routes', element: <OutboundRoutes /> },
       { path: '/extensions', element: <Extensions /> },
       { path: '/voicemail', element: <VoicemailBoxes /> },
       { path: '/ring-groups', element: <RingGroups /> },